@@ -0,0 +1,22 @@
+// Package assets embeds the images of the GUI and the tray icon into the binary, so they
+// are found wherever LinuxPods is installed or started from.
+package assets
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed *.png models
+var files embed.FS
+
+// Read returns the content of an image, e.g. "tray_icon3.png" or "models/2720_left.png"
+func Read(name string) ([]byte, error) {
+	return files.ReadFile(name)
+}
+
+// Exists checks if an image is embedded
+func Exists(name string) bool {
+	_, err := fs.Stat(files, name)
+	return err == nil
+}
@@ -0,0 +1,140 @@
+// aap_replay is a tool for replaying captured AAP traffic through the packet parsers.
+//
+// Captures are recorded with debug_aap (see its optional CAPTURE_FILE argument) and
+// contain one JSON object per packet with a timestamp, direction and hex data.
+// Replaying a capture runs every inbound packet through the same parsers the GUI uses,
+// which makes it possible to reverse-engineer new packet types from user reports
+// without access to the reporter's AirPods.
+//
+// Usage:
+//
+//	go run ./cmd/aap_replay <CAPTURE_FILE> [-all]
+//
+// Examples:
+//
+//	# Show inbound packets and their parse results
+//	go run ./cmd/aap_replay capture.jsonl
+//
+//	# Also show outbound packets (requests sent by LinuxPods)
+//	go run ./cmd/aap_replay capture.jsonl -all
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"linuxpods/internal/aap"
+)
+
+func main() {
+	if len(os.Args) < 2 || len(os.Args) > 3 || (len(os.Args) == 3 && os.Args[2] != "-all") {
+		fmt.Fprintf(os.Stderr, "Usage: %s <CAPTURE_FILE> [-all]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Example: %s capture.jsonl\n", os.Args[0])
+		os.Exit(1)
+	}
+	showAll := len(os.Args) == 3
+
+	file, err := os.Open(os.Args[1])
+	if err != nil {
+		log.Fatalf("Failed to open capture: %v", err)
+	}
+	defer file.Close()
+
+	packets, err := aap.ReadCapture(file)
+	if err != nil {
+		log.Fatalf("Failed to read capture: %v", err)
+	}
+
+	log.Printf("=== Replaying %d packets from %s ===", len(packets), os.Args[1])
+	if len(packets) == 0 {
+		return
+	}
+	start := packets[0].Time
+
+	stats := map[string]int{}
+	var inbound [][]byte
+	for i, packet := range packets {
+		if packet.Direction != aap.CaptureInbound && !showAll {
+			continue
+		}
+
+		offset := packet.Time.Sub(start)
+		fmt.Printf("#%-4d +%-12s %-3s (%3d bytes) %s\n", i+1, offset, packet.Direction, len(packet.Data), hex.EncodeToString(packet.Data))
+
+		if packet.Direction != aap.CaptureInbound {
+			continue
+		}
+
+		kind := parsePacket(packet.Data)
+		stats[kind]++
+		inbound = append(inbound, packet.Data)
+	}
+
+	fmt.Println()
+	fmt.Println("=== Summary (inbound packets) ===")
+	kinds := make([]string, 0, len(stats))
+	for kind := range stats {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Printf("  %-10s %d\n", kind+":", stats[kind])
+	}
+
+	fmt.Println()
+	fmt.Println("=== Device Settings ===")
+	fmt.Printf("  %s\n", aap.ParseDeviceSettings(inbound))
+}
+
+// parsePacket runs a packet through the known parsers, prints the result and
+// returns the packet kind for the summary
+func parsePacket(packet []byte) string {
+	switch {
+	case aap.IsBatteryPacket(packet):
+		info, err := aap.ParseBatteryPacket(packet)
+		if err != nil {
+			fmt.Printf("      ⚠️  Battery parse error: %v\n", err)
+			return "invalid"
+		}
+		fmt.Printf("      ✨ %s", info.String())
+		return "battery"
+
+	case aap.IsKeyPacket(packet):
+		keys, err := aap.ParseProximityKeys(packet)
+		if err != nil {
+			fmt.Printf("      ⚠️  Key parse error: %v\n", err)
+			return "invalid"
+		}
+		for _, key := range keys {
+			fmt.Printf("      🔑 %s: %s\n", key.Type, hex.EncodeToString(key.Data))
+		}
+		return "keys"
+
+	case aap.IsControlCommandPacket(packet):
+		cmd, err := aap.ParseControlCommand(packet)
+		if err != nil {
+			fmt.Printf("      ⚠️  Control command parse error: %v\n", err)
+			return "invalid"
+		}
+		fmt.Printf("      ⚙️  %s = % X\n", cmd.ID, cmd.Value)
+		return "setting"
+
+	case aap.IsEarStatusPacket(packet):
+		info, err := aap.ParseEarStatusPacket(packet)
+		if err != nil {
+			fmt.Printf("      ⚠️  Ear status parse error: %v\n", err)
+			return "invalid"
+		}
+		fmt.Printf("      👂 %s\n", info)
+		return "ear"
+
+	default:
+		if len(packet) > 4 {
+			fmt.Printf("      ? Unknown packet type 0x%02X\n", packet[4])
+		}
+		return "unknown"
+	}
+}
@@ -0,0 +1,133 @@
+// ble_record is a tool for recording raw AirPods BLE advertisements and replaying them.
+//
+// Recording writes every Apple manufacturer data frame with its timestamp, random BLE
+// address and RSSI to a file, one JSON object per line. Frames are stored before parsing,
+// so recordings of unsupported models can be attached to bug reports.
+// Replaying feeds a recording back through the same parser the GUI uses, which makes
+// it possible to check parser changes against real captures without AirPods nearby.
+//
+// Usage:
+//
+//	go run ./cmd/ble_record <RECORDING_FILE>
+//	go run ./cmd/ble_record -replay <RECORDING_FILE> [SPEED]
+//
+// Examples:
+//
+//	# Record advertisements until Ctrl+C
+//	go run ./cmd/ble_record airpods.jsonl
+//
+//	# Replay a recording as fast as possible
+//	go run ./cmd/ble_record -replay airpods.jsonl
+//
+//	# Replay a recording in real time
+//	go run ./cmd/ble_record -replay airpods.jsonl 1
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"linuxpods/internal/ble"
+)
+
+func main() {
+	switch {
+	case len(os.Args) == 2 && os.Args[1] != "-replay":
+		record(os.Args[1])
+
+	case (len(os.Args) == 3 || len(os.Args) == 4) && os.Args[1] == "-replay":
+		speed := 0.0
+		if len(os.Args) == 4 {
+			var err error
+			speed, err = strconv.ParseFloat(os.Args[3], 64)
+			if err != nil || speed < 0 {
+				log.Fatalf("Invalid replay speed: %s", os.Args[3])
+			}
+		}
+		replay(os.Args[2], speed)
+
+	default:
+		fmt.Fprintf(os.Stderr, "Usage: %s <RECORDING_FILE>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s -replay <RECORDING_FILE> [SPEED]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Example: %s airpods.jsonl\n", os.Args[0])
+		os.Exit(1)
+	}
+}
+
+// record writes all received advertisements to the file until Ctrl+C
+func record(path string) {
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("Failed to create recording: %v", err)
+	}
+	defer file.Close()
+
+	scanner, err := ble.NewScanner()
+	if err != nil {
+		log.Fatalf("Failed to create scanner: %v", err)
+	}
+	defer scanner.Close()
+
+	scanner.Record(file)
+	advertisements := scanner.Subscribe()
+
+	if err := scanner.StartDiscovery(); err != nil {
+		log.Fatalf("Failed to start discovery: %v", err)
+	}
+
+	log.Printf("=== Recording Apple advertisements to %s ===", path)
+	log.Println("Press Ctrl+C to stop")
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	for {
+		select {
+		case <-sigChan:
+			metrics := scanner.Metrics()
+			log.Printf("Recorded %d frames (%d AirPods advertisements)", metrics.Advertisements, metrics.ParseSuccesses)
+			return
+
+		case ad, ok := <-advertisements:
+			if !ok {
+				log.Println("Scanner closed")
+				return
+			}
+			log.Printf("%s (RSSI %d dBm): %s", ad.Address, ad.Data.RSSI, ble.DecodeModelName(ad.Data.DeviceModel))
+		}
+	}
+}
+
+// replay parses all frames of the recording and prints the results
+func replay(path string, speed float64) {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open recording: %v", err)
+	}
+	frames, err := ble.ReadRecording(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("Failed to read recording: %v", err)
+	}
+
+	log.Printf("=== Replaying %d frames from %s ===", len(frames), path)
+
+	source := ble.NewReplaySource(frames, speed)
+	defer source.Close()
+
+	for ad := range source.Subscribe() {
+		fmt.Printf("━━━━━━━━━━ %s (RSSI %d dBm) ━━━━━━━━━━━━\n", ad.Address, ad.Data.RSSI)
+		fmt.Println(ad.Data.String())
+		fmt.Println()
+	}
+
+	metrics := source.Metrics()
+	fmt.Println("=== Summary ===")
+	fmt.Printf("  Frames:         %d\n", metrics.Advertisements)
+	fmt.Printf("  Parsed:         %d\n", metrics.ParseSuccesses)
+	fmt.Printf("  Not recognized: %d\n", metrics.ParseFailures)
+}
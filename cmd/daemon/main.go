@@ -0,0 +1,47 @@
+// daemon runs LinuxPods in the background without a window.
+//
+// It scans for AirPods, keeps the AAP connection to connected AirPods, reports their battery
+// to GNOME Settings via the BlueZ battery provider and publishes the state on the session bus
+// (org.linuxpods.Daemon1, see docs/dbus-api.md). The GUI connects to a running daemon instead
+// of starting its own coordinator, so battery reporting continues when the window is closed.
+//
+// It is configured with the same LINUXPODS_* environment variables as the GUI and stops on
+// SIGINT or SIGTERM. data/linuxpods-daemon.service runs it as a systemd user service.
+//
+// Usage:
+//
+//	go run ./cmd/daemon
+//
+// Examples:
+//
+//	# Run as a systemd user service
+//	go build -o ~/.local/bin/linuxpods-daemon ./cmd/daemon
+//	cp data/linuxpods-daemon.service ~/.config/systemd/user/
+//	systemctl --user enable --now linuxpods-daemon
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"linuxpods/internal/daemon"
+)
+
+func main() {
+	d, err := daemon.Start()
+	if err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
+	log.Printf("LinuxPods daemon started")
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+
+	log.Printf("Received %s, stopping", sig)
+	if err := d.Close(); err != nil {
+		log.Printf("Failed to stop cleanly: %v", err)
+	}
+}
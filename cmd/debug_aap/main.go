@@ -6,12 +6,19 @@
 //
 // Usage:
 //
-//	go run ./cmd/debug_aap <MAC_ADDRESS>
+//	go run ./cmd/debug_aap <MAC_ADDRESS> [CAPTURE_FILE]
 //
-// Example:
+// Examples:
 //
 //	go run ./cmd/debug_aap 90:62:3F:59:00:2F
 //
+//	# Record all inbound/outbound packets as JSON lines for later replay
+//	go run ./cmd/debug_aap 90:62:3F:59:00:2F capture.jsonl
+//	go run ./cmd/aap_replay capture.jsonl
+//
+//	# Let BlueZ open the channel via a Profile1 registration (works without raw socket access)
+//	LINUXPODS_AAP_TRANSPORT=profile go run ./cmd/debug_aap 90:62:3F:59:00:2F
+//
 // Requirements:
 //   - AirPods must be paired and connected to this Linux device via Bluetooth
 //   - BlueZ Bluetooth stack must be running
@@ -34,10 +41,11 @@ import (
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: aap <MAC_ADDRESS>")
+	if len(os.Args) < 2 || len(os.Args) > 3 {
+		fmt.Println("Usage: aap <MAC_ADDRESS> [CAPTURE_FILE]")
 		fmt.Println()
 		fmt.Println("Example: aap 90:62:3F:59:00:2F")
+		fmt.Println("Example: aap 90:62:3F:59:00:2F capture.jsonl")
 		fmt.Println()
 		fmt.Println("This tool connects to AirPods via the Apple Accessory Protocol (AAP)")
 		fmt.Println("to retrieve battery status and other proprietary features.")
@@ -49,23 +57,40 @@ func main() {
 	log.Printf("=== AAP Client for AirPods ===\n")
 	log.Printf("Connecting to: %s\n\n", macAddr)
 
+	transport, err := aap.ParseTransport(os.Getenv("LINUXPODS_AAP_TRANSPORT"))
+	if err != nil {
+		log.Fatalf("Invalid transport: %v", err)
+	}
+
 	// Create AAP client
-	client, err := aap.NewClient(macAddr)
+	client, err := aap.NewClient(macAddr, aap.WithTransport(transport))
 	if err != nil {
 		log.Fatalf("Failed to create client: %v", err)
 	}
 
+	// Optionally record all traffic to a capture file
+	var conn aap.Conn = client
+	if len(os.Args) == 3 {
+		captureFile, err := os.Create(os.Args[2])
+		if err != nil {
+			log.Fatalf("Failed to create capture file: %v", err)
+		}
+		defer captureFile.Close()
+		conn = aap.NewCaptureConn(client, captureFile)
+		log.Printf("Capturing traffic to: %s\n\n", os.Args[2])
+	}
+
 	// Connect to AirPods
-	log.Println("1. Opening L2CAP connection (PSM 4097)...")
-	if err := client.Connect(); err != nil {
+	log.Printf("1. Opening L2CAP connection (PSM 4097, transport: %s)...\n", transport)
+	if err := conn.Connect(); err != nil {
 		log.Fatalf("Failed to connect: %v", err)
 	}
-	defer client.Close()
+	defer conn.Close()
 	log.Println("   ✓ Connected successfully")
 
 	// Send handshake
 	log.Println("\n2. Sending handshake packet...")
-	if err := client.Handshake(); err != nil {
+	if err := conn.Handshake(); err != nil {
 		log.Fatalf("Failed to send handshake: %v", err)
 	}
 	log.Println("   ✓ Handshake sent")
@@ -75,7 +100,7 @@ func main() {
 
 	// Request battery status
 	log.Println("\n3. Requesting battery status notifications...")
-	if err := client.RequestBatteryStatus(); err != nil {
+	if err := aap.RequestBatteryStatus(conn); err != nil {
 		log.Fatalf("Failed to request battery: %v", err)
 	}
 	log.Println("   ✓ Battery notifications enabled")
@@ -85,7 +110,7 @@ func main() {
 
 	// Enable special features
 	log.Println("\n4. Enabling special features...")
-	if err := client.EnableSpecialFeatures(); err != nil {
+	if err := aap.EnableSpecialFeatures(conn); err != nil {
 		log.Fatalf("Failed to enable features: %v", err)
 	}
 	log.Println("   ✓ Special features enabled")
@@ -96,7 +121,7 @@ func main() {
 
 	packetCount := 0
 	for {
-		packet, err := client.ReadPacket()
+		packet, err := conn.ReadPacket()
 		if err != nil {
 			log.Printf("Error reading packet: %v", err)
 			continue
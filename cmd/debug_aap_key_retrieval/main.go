@@ -6,13 +6,15 @@
 //
 // Usage:
 //
-//	go run ./cmd/debug_aap_key_retrieval <MAC_ADDRESS>
+//	go run ./cmd/debug_aap_key_retrieval <MAC_ADDRESS> [TIMEOUT] [ATTEMPTS]
 //	Example: go run ./cmd/debug_aap_key_retrieval 90:62:3F:59:00:2F
+//	Example: go run ./cmd/debug_aap_key_retrieval 90:62:3F:59:00:2F 5s 5
 //
 // The tool will:
 // 1. Connect to AirPods via AAP (L2CAP PSM 4097)
 // 2. Send handshake packet
-// 3. Request proximity keys
+// 3. Request proximity keys, repeating the request if no response arrives within
+// TIMEOUT (default 3s), up to ATTEMPTS requests (default 3)
 // 4. Parse and display IRK (Identity Resolving Key) and ENC_KEY
 //
 // These keys can then be used to decrypt the encrypted 16-byte payload
@@ -26,64 +28,35 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"linuxpods/internal/aap"
 )
 
-// readProximityKeys reads packets from the AirPods until a key response is received.
-// The AirPods may send several non-key packets before the key packet arrives.
-//
-// This function will block until:
-//   - A key packet is received and successfully parsed (returns keys, nil)
-//   - maxAttempts packets have been read without finding keys (returns nil, error)
-//   - A read error occurs (returns nil, error)
-func readProximityKeys(client *aap.Client, maxAttempts int) ([]aap.ProximityKey, error) {
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		packet, err := client.ReadPacket()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read packet (attempt %d/%d): %w", attempt, maxAttempts, err)
-		}
-
-		if !aap.IsKeyPacket(packet) {
-			continue // Not a key packet, keep waiting
-		}
-
-		keys, err := aap.ParseProximityKeys(packet)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse key packet: %w", err)
-		}
-
-		return keys, nil
-	}
-
-	return nil, fmt.Errorf("no key packet received after %d attempts", maxAttempts)
-}
-
-// retrieveProximityKeys is a convenience function that combines RequestProximityKeys()
-// and readProximityKeys() into a single call.
-//
-// This function:
-//  1. Sends the key request packet
-//  2. Waits for and parses the key response (up to maxAttempts packets)
-//  3. Returns the parsed keys
-//
-// The client must be connected, and handshake must be completed before calling this.
-func retrieveProximityKeys(client *aap.Client, maxAttempts int) ([]aap.ProximityKey, error) {
-	if err := client.RequestProximityKeys(); err != nil {
-		return nil, err
-	}
-
-	return readProximityKeys(client, maxAttempts)
-}
-
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s <MAC_ADDRESS>\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "Example: %s 90:62:3F:59:00:2F\n", os.Args[0])
+	if len(os.Args) < 2 || len(os.Args) > 4 {
+		fmt.Fprintf(os.Stderr, "Usage: %s <MAC_ADDRESS> [TIMEOUT] [ATTEMPTS]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Example: %s 90:62:3F:59:00:2F 5s 5\n", os.Args[0])
 		os.Exit(1)
 	}
 
 	macAddr := os.Args[1]
+	config := aap.DefaultKeyRetrieval
+	if len(os.Args) > 2 {
+		timeout, err := time.ParseDuration(os.Args[2])
+		if err != nil {
+			log.Fatalf("Invalid timeout: %v", err)
+		}
+		config.Timeout = timeout
+	}
+	if len(os.Args) > 3 {
+		attempts, err := strconv.Atoi(os.Args[3])
+		if err != nil || attempts < 1 {
+			log.Fatalf("Invalid number of attempts: %s", os.Args[3])
+		}
+		config.Attempts = attempts
+	}
 	log.Printf("Retrieving proximity keys from %s...", macAddr)
 
 	// Create AAP client
@@ -106,8 +79,8 @@ func main() {
 	}
 
 	// Retrieve keys
-	log.Println("Requesting proximity keys...")
-	keys, err := retrieveProximityKeys(client, 100)
+	log.Printf("Requesting proximity keys (timeout %v, %d attempts)...", config.Timeout, config.Attempts)
+	keys, err := aap.RetrieveProximityKeys(client, aap.KeyResponses(client), config)
 	if err != nil {
 		log.Fatalf("Failed to retrieve keys: %v", err)
 	}
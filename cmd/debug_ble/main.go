@@ -18,18 +18,19 @@
 //
 // The scanner works even when AirPods are connected to another device (like an iPhone),
 // making it useful for testing BLE advertisement parsing and understanding the protocol.
+// Advertisements are printed as they arrive, repeated advertisements are skipped.
 //
 // Press Ctrl+C to stop scanning.
 package main
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"linuxpods/internal/ble"
 )
@@ -92,9 +93,9 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	// Scan loop
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
+	// Print every advertisement that differs from the last one of the same address
+	advertisements := scanner.Subscribe()
+	lastPayload := make(map[string][]byte)
 
 	for {
 		select {
@@ -102,20 +103,19 @@ func main() {
 			log.Println("\nStopping scanner...")
 			return
 
-		case <-ticker.C:
-			// Try to scan for AirPods
-			log.Println("Scanning...")
-			data, tempMacAdress, err := scanner.ScanForAirPods(5 * time.Second)
-			if err != nil {
-				log.Printf("  No AirPods found in this scan window")
+		case ad, ok := <-advertisements:
+			if !ok {
+				log.Println("Scanner closed")
+				return
+			}
+			data, tempMacAdress := ad.Data, ad.Address
+			if bytes.Equal(lastPayload[tempMacAdress], data.RawData) {
 				continue
 			}
+			lastPayload[tempMacAdress] = data.RawData
 
 			// If encryption key is available, decrypt and merge
-			if hasKey && len(data.RawData) >= 16 {
-				// Extract encrypted portion (last 16 bytes)
-				encryptedData := data.RawData[len(data.RawData)-16:]
-
+			if encryptedData := data.EncryptedPayload(); hasKey && encryptedData != nil {
 				// Decrypt
 				decrypted, err := ble.DecryptProximityPayload(encryptedData, encryptionKey)
 				if err != nil {
@@ -130,7 +130,7 @@ func main() {
 
 			// Display the data (will show "Decrypted" accuracy if decryption succeeded)
 			fmt.Println()
-			fmt.Printf("━━━━━━━━━━ %s ━━━━━━━━━━━━\n", tempMacAdress)
+			fmt.Printf("━━━━━━━━━━ %s (RSSI %d dBm) ━━━━━━━━━━━━\n", tempMacAdress, data.RSSI)
 			fmt.Println(data.String())
 			fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 			fmt.Println()
@@ -1,7 +1,7 @@
 // debug_bluez_dbus_discover is a debugging tool for discovering and inspecting AirPods devices via BlueZ D-Bus.
 //
 // This tool queries the BlueZ D-Bus API (org.freedesktop.DBus.ObjectManager) to discover
-// all paired Bluetooth devices and displays detailed information about any AirPods devices found.
+// all paired Bluetooth devices and displays detailed information about any AirPods or Beats devices found.
 //
 // Usage:
 //
@@ -13,6 +13,7 @@
 //   - All device properties (MAC address, RSSI, etc.)
 //   - Available D-Bus interfaces (Device1, Battery1, etc.)
 //   - Battery information (if org.bluez.Battery1 interface is present)
+//   - The matching UPower device (used by XFCE, MATE and other desktops)
 //   - Bluetooth service UUIDs (Audio Sink, Apple Continuity, etc.)
 //
 // This is useful for:
@@ -23,7 +24,7 @@
 //   - Verifying battery provider integration
 //
 // Requirements:
-//   - AirPods must be paired with this Linux device
+//   - AirPods or Beats must be paired with this Linux device
 //   - BlueZ Bluetooth stack must be running
 package main
 
@@ -33,6 +34,9 @@ import (
 	"strings"
 
 	"github.com/godbus/dbus/v5"
+
+	"linuxpods/internal/bluez"
+	"linuxpods/internal/upower"
 )
 
 func main() {
@@ -60,8 +64,8 @@ func main() {
 		if deviceProps, ok := interfaces["org.bluez.Device1"]; ok {
 			alias := getStringProp(deviceProps, "Alias")
 
-			// Check if it's an AirPods device
-			if containsAirPods(alias) {
+			// Check if it's an AirPods or Beats device
+			if bluez.IsSupportedDevice(bluez.DeviceInfoFromProperties(deviceProps)) {
 				found = true
 				connected := getBoolProp(deviceProps, "Connected")
 
@@ -89,6 +93,18 @@ func main() {
 					}
 				}
 
+				// UPower creates its devices from org.bluez.Battery1
+				if address := getStringProp(deviceProps, "Address"); address != "" {
+					fmt.Printf("\n--- UPower ---\n")
+					if device, found, err := upower.FindDevice(address); err != nil {
+						fmt.Printf("  Not available: %v\n", err)
+					} else if !found {
+						fmt.Printf("  Not listed\n")
+					} else {
+						fmt.Printf("  Path: %s\n  Model: %s\n  Percentage: %.0f%%\n", device.Path, device.Model, device.Percentage)
+					}
+				}
+
 				// Check available UUIDs (services)
 				if uuids := getStringArrayProp(deviceProps, "UUIDs"); len(uuids) > 0 {
 					fmt.Printf("\n--- Available Services (UUIDs) ---\n")
@@ -137,10 +153,6 @@ func getStringArrayProp(props map[string]dbus.Variant, key string) []string {
 	return nil
 }
 
-func containsAirPods(s string) bool {
-	return strings.Contains(s, "AirPods")
-}
-
 func getServiceName(uuid string) string {
 	// Common Bluetooth service UUIDs
 	services := map[string]string{
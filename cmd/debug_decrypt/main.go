@@ -23,6 +23,7 @@ import (
 	"log"
 	"os"
 
+	"linuxpods/internal/annotator"
 	"linuxpods/internal/ble"
 )
 
@@ -104,7 +105,7 @@ func main() {
 
 	// Show raw unencrypted bytes with detailed breakdown
 	fmt.Println("=== Unencrypted Raw Bytes (Detailed) ===")
-	showUnencryptedBytes(data)
+	fmt.Print(annotator.Format(data.RawData, annotator.ProximityPayload(data.RawData)))
 	fmt.Println()
 
 	// Only proceed with decryption if encryption key was provided
@@ -148,173 +149,7 @@ func main() {
 	fmt.Println(data.String())
 	fmt.Println()
 
-	// Show detailed battery byte analysis
-	fmt.Println("=== Decrypted Battery Bytes (Detailed) ===")
-	analyzeBatteryBytes(decrypted, data.IsFlipped)
-	fmt.Println()
-
 	// Full breakdown of all decrypted bytes
 	fmt.Println("=== All 16 Decrypted Bytes ===")
-	for i, b := range decrypted {
-		fmt.Printf("Byte %2d: 0x%02X (%3d) %08b", i, b, b, b)
-
-		// Add annotations
-		switch i {
-		case 1:
-			fmt.Printf("  ← First pod battery")
-		case 2:
-			fmt.Printf("  ← Second pod battery")
-		case 3:
-			fmt.Printf("  ← Case battery")
-		}
-		fmt.Println()
-	}
-}
-
-// showUnencryptedBytes shows detailed breakdown of unencrypted fields
-func showUnencryptedBytes(pd *ble.ProximityData) {
-	rawData := pd.RawData
-	if len(rawData) < 9 {
-		fmt.Println("Unencrypted data too short")
-		return
-	}
-
-	fmt.Printf("Byte 0 (Prefix):       0x%02X\n", rawData[0])
-	fmt.Printf("Bytes 1-2 (Model):     0x%04X\n", pd.DeviceModel)
-	fmt.Printf("Byte 3 (Status):       0x%02X (%08b)\n", pd.Status, pd.Status)
-
-	// Status byte breakdown
-	statusByte := pd.Status
-	primaryLeft := ((statusByte >> 5) & 0x01) == 1
-	fmt.Printf("  Bit 5 (primary):     %v (left pod is primary: %v)\n", primaryLeft, primaryLeft)
-	fmt.Printf("  Bit 6 (in case):     %v\n", ((statusByte>>6)&0x01) != 0)
-	fmt.Printf("  Bit 3 (left ear):    %v\n", pd.LeftInEar)
-	fmt.Printf("  Bit 1 (right ear):   %v\n", pd.RightInEar)
-
-	// Parse battery from byte 4 using parser's DecodeBattery
-	leftNibble := (rawData[4] >> 4) & 0x0F
-	rightNibble := rawData[4] & 0x0F
-	fmt.Printf("Byte 4 (Battery):      0x%02X (nibbles: left=0x%X, right=0x%X)\n",
-		rawData[4], leftNibble, rightNibble)
-
-	// Show decoded values using parser
-	leftBat := ble.DecodeBattery(leftNibble)
-	rightBat := ble.DecodeBattery(rightNibble)
-	fmt.Printf("  Decoded left:        ")
-	if leftBat != nil {
-		fmt.Printf("%d%%\n", *leftBat)
-	} else {
-		fmt.Printf("Unknown\n")
-	}
-	fmt.Printf("  Decoded right:       ")
-	if rightBat != nil {
-		fmt.Printf("%d%%\n", *rightBat)
-	} else {
-		fmt.Printf("Unknown\n")
-	}
-
-	fmt.Printf("Byte 5 (Charging):     0x%02X (%08b)\n", rawData[5], rawData[5])
-	fmt.Printf("  Bit 6 (case):        %v\n", pd.CaseCharging)
-	fmt.Printf("  Bit 5 (left):        %v\n", pd.LeftCharging)
-	fmt.Printf("  Bit 4 (right):       %v\n", pd.RightCharging)
-
-	fmt.Printf("Byte 6 (Lid counter?): 0x%02X\n", rawData[6])
-	fmt.Printf("Byte 7 (Color):        0x%02X (%s)\n", pd.Color, ble.DecodeColor(pd.Color))
-	if len(rawData) > 8 {
-		fmt.Printf("Byte 8 (Lid/Conn):     0x%02X\n", rawData[8])
-		if pd.LidOpen {
-			fmt.Printf("  Lid: Open\n")
-		} else {
-			fmt.Printf("  Lid: Closed\n")
-		}
-	}
-}
-
-// analyzeBatteryBytes shows detailed breakdown of decrypted battery bytes
-func analyzeBatteryBytes(data []byte, isFlipped bool) {
-	if len(data) < 4 {
-		fmt.Println("Data too short for battery analysis")
-		return
-	}
-
-	// Byte 1 - First pod
-	byte1 := int(data[1])
-	charging1 := (byte1 & 0x80) != 0
-	battery1 := byte1 & 0x7F
-
-	// Byte 2 - Second pod
-	byte2 := int(data[2])
-	charging2 := (byte2 & 0x80) != 0
-	battery2 := byte2 & 0x7F
-
-	// Byte 3 - Case
-	byte3 := int(data[3])
-	caseCharging := (byte3 & 0x80) != 0
-	caseBattery := byte3 & 0x7F
-	caseValid := byte3 != 0xFF && !(caseCharging && caseBattery == 127)
-
-	fmt.Printf("Byte 1: 0x%02X (%08b)\n", data[1], data[1])
-	fmt.Printf("  Bit 7 (charging): %v\n", charging1)
-	fmt.Printf("  Bits 0-6 (level): %d%%\n", battery1)
-	fmt.Println()
-
-	fmt.Printf("Byte 2: 0x%02X (%08b)\n", data[2], data[2])
-	fmt.Printf("  Bit 7 (charging): %v\n", charging2)
-	fmt.Printf("  Bits 0-6 (level): %d%%\n", battery2)
-	fmt.Println()
-
-	fmt.Printf("Byte 3: 0x%02X (%08b)\n", data[3], data[3])
-	fmt.Printf("  Bit 7 (charging): %v\n", caseCharging)
-	fmt.Printf("  Bits 0-6 (level): %d%%\n", caseBattery)
-	fmt.Printf("  Valid: %v", caseValid)
-	if !caseValid {
-		fmt.Printf(" (0xFF or charging+127 = unavailable)")
-	}
-	fmt.Println()
-	fmt.Println()
-
-	// Show correct interpretation based on flip status (parser handles this automatically)
-	orientation := "NOT flipped (left pod is primary)"
-	if isFlipped {
-		orientation = "flipped (right pod is primary)"
-	}
-	fmt.Printf("Actual interpretation (%s):\n", orientation)
-
-	if isFlipped {
-		// When flipped: byte1=right, byte2=left
-		fmt.Printf("  Left:  Byte 2 → %d%%", battery2)
-		if charging2 {
-			fmt.Printf(" ⚡")
-		}
-		fmt.Println()
-
-		fmt.Printf("  Right: Byte 1 → %d%%", battery1)
-		if charging1 {
-			fmt.Printf(" ⚡")
-		}
-		fmt.Println()
-	} else {
-		// When NOT flipped: byte1=left, byte2=right
-		fmt.Printf("  Left:  Byte 1 → %d%%", battery1)
-		if charging1 {
-			fmt.Printf(" ⚡")
-		}
-		fmt.Println()
-
-		fmt.Printf("  Right: Byte 2 → %d%%", battery2)
-		if charging2 {
-			fmt.Printf(" ⚡")
-		}
-		fmt.Println()
-	}
-
-	if caseValid {
-		fmt.Printf("  Case:  Byte 3 → %d%%", caseBattery)
-		if caseCharging {
-			fmt.Printf(" ⚡")
-		}
-		fmt.Println()
-	} else {
-		fmt.Printf("  Case:  N/A\n")
-	}
+	fmt.Print(annotator.Format(decrypted, annotator.DecryptedPayload(decrypted, data.IsFlipped)))
 }
@@ -1,24 +1,40 @@
 package main
 
 import (
-	"linuxpods/internal/util"
+	"context"
 	"log"
 	"os"
+	"slices"
+	"sort"
 
+	"linuxpods/internal/aap"
 	"linuxpods/internal/bluez"
+	"linuxpods/internal/config"
+	"linuxpods/internal/daemon"
+	"linuxpods/internal/dbusapi"
+	"linuxpods/internal/debuginfo"
+	"linuxpods/internal/demo"
+	"linuxpods/internal/i18n"
 	"linuxpods/internal/indicator"
 	"linuxpods/internal/podstate"
 	"linuxpods/internal/ui"
 
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 )
 
 const appID = "com.linuxpods.app"
 
 var (
-	app    *adw.Application
-	window *adw.ApplicationWindow
+	app        *adw.Application
+	window     *adw.ApplicationWindow
+	miniWindow *adw.Window
+
+	firstRunChecked bool
+	startHidden     bool // --hidden: the first activation doesn't show the window
+	debug           bool // --debug: show the Debug tab
+	demoMode        bool // --demo: show simulated AirPods instead of real ones
 )
 
 func main() {
@@ -26,112 +42,309 @@ func main() {
 }
 
 func run() int {
-	// Create a centralized AirPods state coordinator
-	// This coordinates BLE scanning, AAP connections, and notifies all components via callbacks
-	podCoord, err := podstate.NewPodStateCoordinator()
+	// Keep the recent log output for the debug info of bug reports
+	debuginfo.CaptureLogs()
+
+	// Load translations for the tray and other non-GTK surfaces
+	i18n.Init()
+
+	configPath := config.Path()
+	cfg := config.LoadOrDefault(configPath)
+
+	// --demo is needed before GApplication parses the options, to pick the backend
+	demoMode = slices.Contains(os.Args[1:], "--demo")
+
+	// Use the background daemon if it is running, run the coordinator in-process otherwise
+	podCoord, closeBackend := openBackend()
+	defer closeBackend()
+
+	// === Create System Tray ===
+	// LINUXPODS_TRAY selects the tray mode: auto (default), tray, window or off.
+	// It overrides tray.mode of the config file.
+	mode := os.Getenv("LINUXPODS_TRAY")
+	if mode == "" {
+		mode = cfg.Tray.Mode
+	}
+	trayMode, err := indicator.ParseMode(mode)
 	if err != nil {
-		log.Fatalf("Failed to create pod state coordinator: %v", err)
+		log.Printf("Warning: %v", err)
+	}
+	trayMode = indicator.ResolveMode(trayMode)
+	var tray *indicator.Indicator
+	if trayMode == indicator.ModeTray {
+		tray = createTrayIndicator(podCoord)
+		setTrayIconStyle(tray, cfg.Tray)
+		defer tray.Stop()
 	}
-	defer func() { _ = podCoord.Close() }()
 
-	// === Create Bluez Provider ===
-	bluezProvider := createBluezBatteryProvider(podCoord)
-	if bluezProvider != nil {
-		defer func() { _ = bluezProvider.Close() }()
+	// === Create GUI App ===
+	// The demo runs as a separate application next to a running LinuxPods
+	id := appID
+	if demoMode {
+		id += ".Demo"
 	}
+	app = adw.NewApplication(id, 0)
+	notifier := ui.NewLowBatteryNotifier(app, podCoord, cfg.Notifications)
+	switchNotifier := ui.NewDeviceSwitchedNotifier(app, podCoord, cfg.Notifications)
+	leftBehindNotifier := ui.NewLeftBehindNotifier(app, podCoord, cfg.Notifications)
 
-	// === Create System Tray ===
-	tray := createTrayIndicator(podCoord)
-	defer tray.Stop()
+	// Closing the window hides it while the tray or the mini window can bring it back
+	closeToTray := cfg.Tray.CloseToTray
+	hideOnClose := func() bool {
+		return closeToTray && trayMode != indicator.ModeOff
+	}
 
-	// === Create GUI App ===
-	app = adw.NewApplication(appID, 0)
-	app.ConnectActivate(func() {
-		window = ui.Activate(app, podCoord)
+	// Apply changes of the config file. The daemon applies its own settings.
+	stopWatch := config.Watch(configPath, func(cfg config.Config) {
+		notifier.SetConfig(cfg.Notifications)
+		switchNotifier.SetConfig(cfg.Notifications)
+		leftBehindNotifier.SetConfig(cfg.Notifications)
+		if tray != nil {
+			setTrayIconStyle(tray, cfg.Tray)
+		}
+		glib.IdleAdd(func() {
+			closeToTray = cfg.Tray.CloseToTray
+			if window != nil {
+				window.SetHideOnClose(hideOnClose())
+			}
+		})
 	})
+	defer stopWatch()
 
-	return app.Run(os.Args)
-}
+	// --hidden starts in the background, e.g. at login. A second instance started with it
+	// leaves the running one alone. --debug shows the Debug tab, --demo shows simulated AirPods.
+	app.AddMainOption("hidden", 0, glib.OptionFlagNone, glib.OptionArgNone, "Start without showing the window", "")
+	app.AddMainOption("debug", 0, glib.OptionFlagNone, glib.OptionArgNone, "Show the Debug tab with live packets", "")
+	app.AddMainOption("demo", 0, glib.OptionFlagNone, glib.OptionArgNone, "Show simulated AirPods, without Bluetooth", "")
+	app.ConnectHandleLocalOptions(func(options *glib.VariantDict) int {
+		debug = options.Contains("debug")
+		if !options.Contains("hidden") {
+			return -1 // Continue
+		}
+		if err := app.Register(context.Background()); err != nil {
+			log.Printf("Warning: Failed to register the application: %v", err)
+			return 1
+		}
+		if app.IsRemote() {
+			return 0
+		}
+		startHidden = true
+		return -1
+	})
 
-// createBluezBatteryProvider creates and configures the BlueZ battery provider
-func createBluezBatteryProvider(podCoord *podstate.PodStateCoordinator) *bluez.BluezBatteryProvider {
-	bluezProvider, err := bluez.NewBluezBatteryProvider()
-	if err != nil {
-		log.Printf("Warning: Failed to create BlueZ battery provider: %v", err)
-		log.Println("Battery won't appear in GNOME Settings, but UI will still work")
-		return nil
-	}
+	app.ConnectStartup(func() {
+		// app.quit quits from the window's menu and with <Control>q, closing the window only
+		// hides it to the tray
+		quit := gio.NewSimpleAction("quit", nil)
+		quit.ConnectActivate(func(*glib.Variant) { app.Quit() })
+		app.AddAction(quit)
 
-	// Set connection callback to manage AAP connection
-	bluezProvider.SetConnectionCallback(func(connected bool, devicePath string, macAddr string) {
-		if connected {
-			log.Printf("AirPods connected: %s (MAC: %s)", devicePath, macAddr)
-			if err := podCoord.ConnectAAP(macAddr); err != nil {
-				log.Printf("Warning: Failed to connect AAP: %v", err)
-				log.Println("Falling back to BLE for battery monitoring (approximate)")
-			}
-		} else {
-			log.Printf("AirPods disconnected: %s", devicePath)
-			podCoord.DisconnectAAP()
+		// --gapplication-service (D-Bus activation) starts without an activation, like --hidden
+		if app.Flags()&gio.ApplicationIsService != 0 {
+			startInBackground(podCoord, trayMode)
 		}
 	})
 
-	// Watch for AirPods connections
-	if err := bluezProvider.WatchForAirPods(); err != nil {
-		log.Printf("Warning: Failed to watch for AirPods: %v", err)
-	}
+	app.ConnectActivate(func() {
+		if startHidden {
+			startHidden = false
+			startInBackground(podCoord, trayMode)
+			return
+		}
 
-	// Register a callback to update BlueZ provider when state data changes
-	podCoord.RegisterCallback(func(states map[string]*podstate.PodState) {
-		// For now, just use the first device in the map
-		for _, state := range states {
-			// Use the lowest battery for GNOME Settings (most useful for knowing when to charge)
-			var batteryLevel = util.MinOr(state.LeftBattery, state.RightBattery, 0)
-			if err := bluezProvider.UpdateBatteryPercentage("airpods_battery", uint8(batteryLevel)); err != nil {
-				log.Printf("Update BlueZ battery: %v", err)
+		// The window is kept while it is hidden in the tray, activating shows it again
+		if window != nil {
+			window.Present()
+			return
+		}
+		window = ui.Activate(app, podCoord, notifier, debug)
+		window.SetHideOnClose(hideOnClose())
+		window.ConnectDestroy(func() { window = nil })
+
+		// First start: guide through pairing if no AirPods are paired yet
+		if !firstRunChecked && !demoMode {
+			firstRunChecked = true
+			if paired, err := bluez.HasPairedAirPods(); err != nil {
+				log.Printf("Warning: Failed to look for paired AirPods: %v", err)
+			} else if !paired {
+				ui.ShowPairingWizard(&window.Window, podCoord)
 			}
-			break // Only use the first device
 		}
+
+		showMiniWindow(podCoord, trayMode)
 	})
 
-	return bluezProvider
+	return app.Run(os.Args)
+}
+
+// startInBackground keeps the application running without a window, with only the tray
+// icon or the mini window
+func startInBackground(podCoord podstate.Backend, trayMode indicator.Mode) {
+	app.Hold()
+	showMiniWindow(podCoord, trayMode)
+}
+
+// showMiniWindow opens the floating mini window once, as the fallback when no tray is available
+func showMiniWindow(podCoord podstate.Backend, trayMode indicator.Mode) {
+	if trayMode == indicator.ModeWindow && miniWindow == nil {
+		miniWindow = ui.ActivateMiniWindow(app, podCoord, showWindow)
+	}
+}
+
+// openBackend connects to the running daemon (cmd/daemon), or starts the coordinator and
+// its background services in-process if there is none. In-process, they stop with the GUI.
+// In demo mode, the coordinator shows simulated AirPods instead (see internal/demo).
+func openBackend() (podstate.Backend, func()) {
+	if demoMode {
+		d, err := demo.Start()
+		if err != nil {
+			log.Fatalf("Failed to start the demo: %v", err)
+		}
+		return d.Coordinator, func() { _ = d.Close() }
+	}
+
+	if client, err := dbusapi.NewClient(); err == nil {
+		log.Printf("Using the running LinuxPods daemon")
+		return client, func() { _ = client.Close() }
+	}
+
+	d, err := daemon.Start()
+	if err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
+	return d.Coordinator, func() { _ = d.Close() }
 }
 
 // createTrayIndicator creates and configures the system tray indicator
-func createTrayIndicator(podCoord *podstate.PodStateCoordinator) *indicator.Indicator {
+func createTrayIndicator(podCoord podstate.Backend) *indicator.Indicator {
 	tray := indicator.New(
 		showWindow,
 		quitApp,
-		func(mode indicator.NoiseMode) {
-			log.Printf("Noise mode changed from tray: %s", mode)
+		func(macAddr string, mode indicator.NoiseMode) {
+			if macAddr == "" {
+				return // Not connected via AAP, nothing to send the mode to
+			}
+			if err := podCoord.SetNoiseMode(macAddr, trayNoiseModes[mode]); err != nil {
+				log.Printf("Failed to set the noise mode from the tray: %v", err)
+			}
+		},
+		func(macAddr string) {
+			if err := podCoord.ConnectBluetooth(macAddr); err != nil {
+				log.Printf("Failed to reconnect from the tray: %v", err)
+			}
 		},
 	)
 	tray.Start()
 
 	// Register callback to update the tray when state data changes
-	podCoord.RegisterCallback(func(states map[string]*podstate.PodState) {
-		// For now, just use the first device in the map
-		for _, state := range states {
-			tray.UpdateBatteryLevels(
-				state.LeftBattery,
-				state.RightBattery,
-				state.CaseBattery,
-				state.LeftCharging,
-				state.RightCharging,
-				state.CaseCharging,
-			)
-			break // Only use the first device
+	podCoord.Subscribe(podstate.OnStatesChanged(func(states map[string]*podstate.PodState) {
+		tray.UpdateDevices(trayDevices(states))
+	}))
+
+	// Check the noise control mode the devices report, including the ones already known
+	podCoord.RegisterSettingsCallback(func(macAddr string, cmd aap.ControlCommand) {
+		if event, ok := podstate.SettingEvent(macAddr, cmd); ok {
+			if e, ok := event.(podstate.NoiseModeChanged); ok {
+				tray.SetNoiseMode(e.Address, trayNoiseMode(e.Mode))
+			}
+		}
+	})
+	podCoord.Subscribe(func(event podstate.Event) {
+		switch e := event.(type) {
+		case podstate.DeviceDisconnected:
+			tray.SetNoiseMode(e.Address, "")
+		case podstate.DeviceSuspended:
+			tray.SetNoiseMode(e.Address, "")
 		}
 	})
 
 	return tray
 }
 
-// showWindow displays the main application window
+// trayNoiseModes maps the noise control modes of the tray menu to the AAP modes
+var trayNoiseModes = map[indicator.NoiseMode]aap.NoiseControlMode{
+	indicator.Transparency:    aap.NoiseControlTransparency,
+	indicator.Adaptive:        aap.NoiseControlAdaptive,
+	indicator.NoiseCancelling: aap.NoiseControlANC,
+	indicator.Off:             aap.NoiseControlOff,
+}
+
+// trayNoiseMode returns the tray menu mode of an AAP noise control mode, "" if it has none
+func trayNoiseMode(mode aap.NoiseControlMode) indicator.NoiseMode {
+	for trayMode, m := range trayNoiseModes {
+		if m == mode {
+			return trayMode
+		}
+	}
+	return ""
+}
+
+// trayDevices returns the devices shown in the tray: first the connected (or closest) device,
+// then the user's other devices by name. AirPods of other people nearby are only listed in the
+// window.
+func trayDevices(states map[string]*podstate.PodState) []indicator.Device {
+	selected, state := podstate.SelectDevice(states)
+	if state == nil {
+		return nil
+	}
+	devices := []indicator.Device{trayDevice(state)}
+
+	var others []indicator.Device
+	for macAddr, state := range states {
+		if macAddr != selected && state.IsOwnDevice && state.RealMac != "" {
+			others = append(others, trayDevice(state))
+		}
+	}
+	sort.Slice(others, func(i, j int) bool {
+		if others[i].Name != others[j].Name {
+			return others[i].Name < others[j].Name
+		}
+		return others[i].Address < others[j].Address
+	})
+	return append(devices, others...)
+}
+
+// trayDevice converts the state of a device for the tray
+func trayDevice(state *podstate.PodState) indicator.Device {
+	caps := state.Capabilities
+	return indicator.Device{
+		Address: state.RealMac,
+		Name:    state.DisplayName(),
+		Batteries: indicator.BatteryLevels{
+			Left:          state.LeftBattery,
+			Right:         state.RightBattery,
+			Case:          state.CaseBattery,
+			LeftCharging:  state.LeftCharging,
+			RightCharging: state.RightCharging,
+			CaseCharging:  state.CaseCharging,
+		},
+		Headphones: caps.SingleBattery(),
+		Battery:    state.Battery,
+		Charging:   state.Charging,
+		Stale:      state.Stale,
+		NoiseModes: map[indicator.NoiseMode]bool{
+			indicator.Transparency:    caps.SupportsTransparency,
+			indicator.Adaptive:        caps.SupportsAdaptive,
+			indicator.NoiseCancelling: caps.SupportsANC,
+			indicator.Off:             caps.SupportsNoiseControl(),
+		},
+	}
+}
+
+// setTrayIconStyle applies tray.icon_style of the config file
+func setTrayIconStyle(tray *indicator.Indicator, cfg config.TrayConfig) {
+	style, err := indicator.ParseIconStyle(cfg.IconStyle)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	tray.SetIconStyle(style)
+}
+
+// showWindow displays the main application window, creating it if it was never shown or closed
 func showWindow() {
-	if window != nil {
+	if app != nil {
 		glib.IdleAdd(func() {
-			window.Present()
+			app.Activate()
 		})
 	}
 }
@@ -0,0 +1,182 @@
+// i18n_extract writes the translation template po/linuxpods.pot.
+//
+// It collects the strings passed to i18n.T, i18n.Tf and i18n.N in the Go sources, in the
+// order they appear. Only string literals (and concatenations of them) are extracted, so
+// strings that are computed at runtime must be marked with i18n.N where they are defined.
+// Strings passed to i18n.Tf are flagged as c-format, which lets msgfmt check that
+// translations keep the formatting verbs.
+//
+// Usage:
+//
+//	go run ./cmd/i18n_extract [OUTPUT]
+//
+// Examples:
+//
+//	# Update the template after adding or changing strings
+//	go run ./cmd/i18n_extract po/linuxpods.pot
+//
+//	# Merge the changes into a translation
+//	msgmerge -U po/de.po po/linuxpods.pot
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// header is the start of the template, followed by one entry per string
+const header = `# LinuxPods translation template.
+# Translations are loaded by internal/i18n. Create a catalog with:
+#   msginit -i po/linuxpods.pot -o po/<lang>.po -l <lang>
+# and compile all catalogs with ` + "`make translations`" + `.
+# Generated by cmd/i18n_extract, do not edit.
+msgid ""
+msgstr ""
+"Project-Id-Version: linuxpods\n"
+"Content-Type: text/plain; charset=UTF-8\n"
+"Content-Transfer-Encoding: 8bit\n"
+`
+
+// translationFuncs are the functions of the i18n package whose first argument is a msgid
+var translationFuncs = map[string]bool{"T": true, "Tf": true, "N": true}
+
+// message is a msgid with the files it is used in
+type message struct {
+	msgid  string
+	files  []string
+	format bool // Used as a format string (i18n.Tf)
+}
+
+func main() {
+	output := "po/linuxpods.pot"
+	if len(os.Args) > 1 {
+		output = os.Args[1]
+	}
+
+	messages, err := extract(".")
+	if err != nil {
+		log.Fatalf("Failed to extract strings: %v", err)
+	}
+	if err := os.WriteFile(output, []byte(template(messages)), 0644); err != nil {
+		log.Fatalf("Failed to write template: %v", err)
+	}
+	fmt.Printf("Wrote %d strings to %s\n", len(messages), output)
+}
+
+// extract collects the translatable strings of the Go files below root
+func extract(root string) ([]*message, error) {
+	var messages []*message
+	byID := make(map[string]*message)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			msgid, format, ok := translationCall(n)
+			if !ok {
+				return true
+			}
+			m, known := byID[msgid]
+			if !known {
+				m = &message{msgid: msgid}
+				byID[msgid] = m
+				messages = append(messages, m)
+			}
+			m.format = m.format || format
+			if len(m.files) == 0 || m.files[len(m.files)-1] != path {
+				m.files = append(m.files, filepath.ToSlash(path))
+			}
+			return true
+		})
+		return nil
+	})
+	return messages, err
+}
+
+// translationCall returns the msgid of a call to a translation function with a literal msgid,
+// and whether the msgid is a format string
+func translationCall(n ast.Node) (msgid string, format bool, ok bool) {
+	call, isCall := n.(*ast.CallExpr)
+	if !isCall || len(call.Args) == 0 {
+		return "", false, false
+	}
+	sel, isSel := call.Fun.(*ast.SelectorExpr)
+	if !isSel || !translationFuncs[sel.Sel.Name] {
+		return "", false, false
+	}
+	if pkg, isIdent := sel.X.(*ast.Ident); !isIdent || pkg.Name != "i18n" {
+		return "", false, false
+	}
+	msgid, ok = stringLiteral(call.Args[0])
+	return msgid, sel.Sel.Name == "Tf", ok
+}
+
+// stringLiteral returns the value of a string literal or a concatenation of string literals
+func stringLiteral(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.STRING {
+			return "", false
+		}
+		s, err := strconv.Unquote(e.Value)
+		return s, err == nil
+	case *ast.BinaryExpr:
+		if e.Op != token.ADD {
+			return "", false
+		}
+		x, ok := stringLiteral(e.X)
+		if !ok {
+			return "", false
+		}
+		y, ok := stringLiteral(e.Y)
+		return x + y, ok
+	case *ast.ParenExpr:
+		return stringLiteral(e.X)
+	}
+	return "", false
+}
+
+// template formats the messages as a gettext template
+func template(messages []*message) string {
+	var b strings.Builder
+	b.WriteString(header)
+	for _, m := range messages {
+		fmt.Fprintf(&b, "\n#: %s\n", strings.Join(m.files, " "))
+		if m.format {
+			b.WriteString("#, c-format\n")
+		}
+		fmt.Fprintf(&b, "msgid %s\nmsgstr \"\"\n", poString(m.msgid))
+	}
+	return b.String()
+}
+
+// poString quotes a string for a .po file
+func poString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
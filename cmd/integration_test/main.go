@@ -0,0 +1,402 @@
+//go:build integration
+
+// integration_test runs the pod state coordinator end to end against a simulated device.
+//
+// The coordinator is created with the simulator's advertisement source instead of the
+// BlueZ scanner and dials AAP connections to the simulated device instead of opening
+// L2CAP sockets, so no Bluetooth hardware (or D-Bus) is needed. The test walks through
+// the complete flow and asserts the state updates the coordinator emits to its callbacks:
+//
+//  1. BLE: unencrypted advertisements from the random address (10% precision), not yet
+//     known to be an own device
+//  2. AAP connect: battery (1% precision) and settings notifications
+//  3. Key fetch: the ENC_KEY arrives via AAP and is attached to the device state
+//  4. Battery change: the device pushes a new battery notification
+//  5. Role switch: the right pod becomes primary
+//  6. Ear status: a pod is taken out and the lid closed, reported via AAP
+//  7. Disconnect and decrypt: advertisements are decrypted with the fetched key and
+//     attributed to the real MAC address as an own device, with 1% precision and the
+//     ear and lid state of step 6
+//  8. Address rotation: a new resolvable address is resolved with the IRK fetched in step 3
+//  9. Device preferences: the alias is shown, an ignored device is removed and comes back
+//     when it is no longer ignored
+//  10. Shutdown: Close stops an open session's read loop and returns
+//
+// Each step has a timeout. The tool exits with status 1 on the first failed step.
+//
+// Usage:
+//
+//	go run -tags integration ./cmd/integration_test [-v]
+//
+// Use -v to show the coordinator's log output.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/podstate"
+	"linuxpods/internal/simulator"
+)
+
+const (
+	deviceMac = "AA:BB:CC:DD:EE:FF"
+	bleMac    = "5A:3C:11:22:33:44"
+
+	// AirPods Pro (2nd generation, USB-C)
+	deviceModel = 0x2420
+
+	advertisementInterval = 100 * time.Millisecond
+	stepTimeout           = 5 * time.Second
+)
+
+var (
+	encKey = []byte{0x10, 0x21, 0x32, 0x43, 0x54, 0x65, 0x76, 0x87, 0x98, 0xA9, 0xBA, 0xCB, 0xDC, 0xED, 0xFE, 0x0F}
+	irk    = []byte{0xEC, 0x02, 0x34, 0xA3, 0x57, 0xC8, 0xAD, 0x05, 0x34, 0x10, 0x10, 0xA6, 0x0A, 0x39, 0x7D, 0x9B}
+)
+
+// recorder records the state updates and the types of the other events emitted by the coordinator
+type recorder struct {
+	mu     sync.Mutex
+	events []map[string]*podstate.PodState
+	types  map[string]bool
+}
+
+func (r *recorder) record(event podstate.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := event.(podstate.StatesChanged); ok {
+		r.events = append(r.events, e.States)
+		return
+	}
+	if r.types == nil {
+		r.types = make(map[string]bool)
+	}
+	r.types[fmt.Sprintf("%T", event)] = true
+}
+
+// missingTypes returns the event types that were not recorded
+func (r *recorder) missingTypes(events ...podstate.Event) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var missing []string
+	for _, event := range events {
+		if name := fmt.Sprintf("%T", event); !r.types[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+// waitFor waits until an event recorded after the first `from` events contains a state of
+// macAddr that satisfies check. It returns the number of events seen so far, which is
+// used as `from` by the next step.
+func (r *recorder) waitFor(from int, macAddr string, check func(*podstate.PodState) bool) (int, error) {
+	deadline := time.Now().Add(stepTimeout)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		events := r.events
+		r.mu.Unlock()
+
+		for i := from; i < len(events); i++ {
+			if state, ok := events[i][macAddr]; ok && check(state) {
+				return i + 1, nil
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return 0, fmt.Errorf("no matching state update for %s within %v", macAddr, stepTimeout)
+}
+
+// waitForRemoval waits until an event recorded after the first `from` events no longer
+// contains a state of macAddr, see waitFor
+func (r *recorder) waitForRemoval(from int, macAddr string) (int, error) {
+	deadline := time.Now().Add(stepTimeout)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		events := r.events
+		r.mu.Unlock()
+
+		for i := from; i < len(events); i++ {
+			if _, ok := events[i][macAddr]; !ok {
+				return i + 1, nil
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return 0, fmt.Errorf("state of %s not removed within %v", macAddr, stepTimeout)
+}
+
+func main() {
+	verbose := flag.Bool("v", false, "show coordinator log output")
+	flag.Parse()
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	if err := run(); err != nil {
+		fmt.Printf("FAIL: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("PASS")
+}
+
+func run() error {
+	device, err := simulator.NewDevice(deviceMac, deviceModel, encKey, irk)
+	if err != nil {
+		return fmt.Errorf("failed to create simulated device: %w", err)
+	}
+	source := device.NewAdvertisementSource(bleMac, advertisementInterval)
+
+	podCoord := podstate.NewPodStateCoordinatorWithSource(source, podstate.WithAAPDialer(device.Dial))
+	defer func() { _ = podCoord.Close() }()
+	// Scan at the fast cadence, so each step only takes a few advertisements
+	podCoord.StartFastScan()
+
+	events := &recorder{}
+	podCoord.Subscribe(events.record)
+
+	settings := make(chan aap.ControlCommand, 16)
+	podCoord.RegisterSettingsCallback(func(macAddr string, cmd aap.ControlCommand) {
+		if macAddr != deviceMac {
+			return
+		}
+		select {
+		case settings <- cmd:
+		default: // Don't block the coordinator if nobody is waiting
+		}
+	})
+
+	battery := device.Battery()
+	seen := 0
+
+	// 1. Unknown device: approximate battery from the unencrypted advertisement
+	step("BLE advertisement from random address")
+	seen, err = events.waitFor(seen, bleMac, func(state *podstate.PodState) bool {
+		return state.Source == podstate.DataSourceBLE &&
+			!state.IsOwnDevice &&
+			state.ModelName == "AirPods Pro (2nd gen, USB-C)" &&
+			isLevel(state.LeftBattery, int(battery.Left)/10*10) &&
+			isLevel(state.RightBattery, int(battery.Right)/10*10) &&
+			state.CaseCharging == battery.CaseCharging
+	})
+	if err != nil {
+		return err
+	}
+
+	// 2. AAP session: accurate battery and the settings dump
+	step("AAP connect, device information and battery notification")
+	if err := podCoord.ConnectAAP(deviceMac); err != nil {
+		return fmt.Errorf("failed to connect AAP: %w", err)
+	}
+	seen, err = events.waitFor(seen, deviceMac, func(state *podstate.PodState) bool {
+		return state.Source == podstate.DataSourceAAP &&
+			state.PrimaryPod == podstate.PodSideLeft &&
+			matchesBattery(state, battery) &&
+			state.DeviceInfo != nil && state.DeviceInfo.FirmwareVersion == simulator.FirmwareVersion
+	})
+	if err != nil {
+		return err
+	}
+
+	step("Settings notification")
+	if err := waitForSetting(settings, aap.ControlListeningMode, byte(aap.NoiseControlANC)); err != nil {
+		return err
+	}
+
+	// 3. Key fetch
+	step("Encryption key retrieval")
+	if err := podCoord.RequestEncryptionKeys(deviceMac); err != nil {
+		return fmt.Errorf("failed to request keys: %w", err)
+	}
+	seen, err = events.waitFor(seen, deviceMac, func(state *podstate.PodState) bool {
+		return bytes.Equal(state.EncryptionKey, encKey)
+	})
+	if err != nil {
+		return err
+	}
+
+	// 4. Battery notification pushed by the device
+	step("Battery change notification")
+	battery = simulator.Battery{Left: 42, Right: 37, Case: 71, LeftCharging: true}
+	device.SetBattery(battery)
+	seen, err = events.waitFor(seen, deviceMac, func(state *podstate.PodState) bool {
+		return state.Source == podstate.DataSourceAAP && matchesBattery(state, battery)
+	})
+	if err != nil {
+		return err
+	}
+
+	// 5. Role switch, reported with a new battery notification
+	step("Primary pod switch")
+	device.SetPrimary(aap.ComponentRight)
+	seen, err = events.waitFor(seen, deviceMac, func(state *podstate.PodState) bool {
+		return state.Source == podstate.DataSourceAAP &&
+			state.PrimaryPod == podstate.PodSideRight &&
+			matchesBattery(state, battery)
+	})
+	if err != nil {
+		return err
+	}
+
+	// 6. Ear status notification pushed by the device
+	step("Ear status notification")
+	placement := simulator.Placement{Left: aap.EarStatusOutOfEar, Right: aap.EarStatusInEar}
+	device.SetPlacement(placement)
+	seen, err = events.waitFor(seen, deviceMac, func(state *podstate.PodState) bool {
+		return state.Source == podstate.DataSourceAAP && matchesPlacement(state, placement)
+	})
+	if err != nil {
+		return err
+	}
+
+	// 7. Without AAP, advertisements are decrypted and attributed to the real device
+	step("AAP disconnect and BLE decryption")
+	podCoord.DisconnectAAP(deviceMac)
+	seen, err = events.waitFor(seen, deviceMac, func(state *podstate.PodState) bool {
+		return state.Source == podstate.DataSourceBLE &&
+			state.IsOwnDevice &&
+			state.CurrentBLEMac == bleMac &&
+			state.PrimaryPod == podstate.PodSideRight &&
+			matchesBattery(state, battery) &&
+			matchesPlacement(state, placement) &&
+			!state.LidOpen
+	})
+	if err != nil {
+		return err
+	}
+
+	// 8. A rotated resolvable address is attributed to the device with the IRK retrieved via AAP
+	step("BLE address rotation and IRK resolution")
+	rotatedMac, err := device.ResolvableAddress()
+	if err != nil {
+		return fmt.Errorf("failed to generate resolvable address: %w", err)
+	}
+	source.SetBLEMac(rotatedMac)
+	seen, err = events.waitFor(seen, deviceMac, func(state *podstate.PodState) bool {
+		return state.Source == podstate.DataSourceBLE && state.CurrentBLEMac == rotatedMac
+	})
+	if err != nil {
+		return err
+	}
+
+	// 9. The preferences from the config file apply to the device's state
+	step("Device preferences")
+	podCoord.SetDevicePreferences(map[string]podstate.DevicePreferences{
+		deviceMac: {Alias: "Test AirPods", AutoConnect: true},
+	})
+	seen, err = events.waitFor(seen, deviceMac, func(state *podstate.PodState) bool {
+		return state.DisplayName() == "Test AirPods"
+	})
+	if err != nil {
+		return err
+	}
+	podCoord.SetDevicePreferences(map[string]podstate.DevicePreferences{
+		deviceMac: {Ignored: true},
+	})
+	if podCoord.ShouldAutoConnect(deviceMac) {
+		return fmt.Errorf("ignored device would be connected automatically")
+	}
+	if seen, err = events.waitForRemoval(seen, deviceMac); err != nil {
+		return err
+	}
+	podCoord.SetDevicePreferences(nil)
+	seen, err = events.waitFor(seen, deviceMac, func(state *podstate.PodState) bool {
+		return state.Source == podstate.DataSourceBLE && state.Alias == ""
+	})
+	if err != nil {
+		return err
+	}
+
+	step("Granular events")
+	if missing := events.missingTypes(podstate.BatteryChanged{}, podstate.DeviceConnecting{}, podstate.DeviceConnected{},
+		podstate.DeviceDisconnected{}, podstate.KeysStored{}, podstate.NoiseModeChanged{}); len(missing) > 0 {
+		return fmt.Errorf("events not emitted: %v", missing)
+	}
+
+	metrics := podCoord.Metrics()
+	if metrics.DecryptSuccesses == 0 {
+		return fmt.Errorf("no successful decryption recorded in metrics")
+	}
+	if metrics.AddressResolutions == 0 {
+		return fmt.Errorf("no IRK address resolution recorded in metrics")
+	}
+	fmt.Printf("  %d state updates, %d advertisements, %d/%d decryptions, %d address resolutions\n",
+		events.count(), metrics.Scanner.Advertisements, metrics.DecryptSuccesses, metrics.DecryptAttempts,
+		metrics.AddressResolutions)
+
+	// 10. Close stops the read loop of an open session and all other goroutines
+	step("Clean shutdown")
+	if err := podCoord.ConnectAAP(deviceMac); err != nil {
+		return fmt.Errorf("failed to reconnect AAP: %w", err)
+	}
+	closed := make(chan error, 1)
+	go func() { closed <- podCoord.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			return fmt.Errorf("failed to close coordinator: %w", err)
+		}
+	case <-time.After(stepTimeout):
+		return fmt.Errorf("coordinator not closed within %v", stepTimeout)
+	}
+
+	return nil
+}
+
+// step prints the name of the test step
+func step(name string) {
+	fmt.Printf("=== %s\n", name)
+}
+
+// waitForSetting waits until the setting is reported with the expected value
+func waitForSetting(settings <-chan aap.ControlCommand, id aap.ControlCommandID, value byte) error {
+	timeout := time.After(stepTimeout)
+	for {
+		select {
+		case cmd := <-settings:
+			if cmd.ID == id && cmd.Value[0] == value {
+				return nil
+			}
+		case <-timeout:
+			return fmt.Errorf("setting %s not reported as 0x%02X within %v", id, value, stepTimeout)
+		}
+	}
+}
+
+// matchesBattery reports whether the state has exactly the simulated battery levels and charging states
+func matchesBattery(state *podstate.PodState, battery simulator.Battery) bool {
+	return isLevel(state.LeftBattery, int(battery.Left)) &&
+		isLevel(state.RightBattery, int(battery.Right)) &&
+		isLevel(state.CaseBattery, int(battery.Case)) &&
+		state.LeftCharging == battery.LeftCharging &&
+		state.RightCharging == battery.RightCharging &&
+		state.CaseCharging == battery.CaseCharging
+}
+
+// matchesPlacement reports whether the ear and case state of a device state is the placement
+// of the simulated pods
+func matchesPlacement(state *podstate.PodState, placement simulator.Placement) bool {
+	return state.LeftInEar == (placement.Left == aap.EarStatusInEar) &&
+		state.RightInEar == (placement.Right == aap.EarStatusInEar) &&
+		state.LeftInCase == (placement.Left == aap.EarStatusInCase) &&
+		state.RightInCase == (placement.Right == aap.EarStatusInCase)
+}
+
+// isLevel reports whether the battery level is known and equal to expected
+func isLevel(level *int, expected int) bool {
+	return level != nil && *level == expected
+}
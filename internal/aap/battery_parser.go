@@ -9,6 +9,7 @@ type BatteryComponent uint8
 
 const (
 	ComponentUnknown BatteryComponent = 0
+	ComponentSingle  BatteryComponent = 1 // Single battery of headphones such as AirPods Max
 	ComponentRight   BatteryComponent = 2
 	ComponentLeft    BatteryComponent = 4
 	ComponentCase    BatteryComponent = 8
@@ -16,6 +17,8 @@ const (
 
 func (c BatteryComponent) String() string {
 	switch c {
+	case ComponentSingle:
+		return "Headphones"
 	case ComponentRight:
 		return "Right"
 	case ComponentLeft:
@@ -62,6 +65,15 @@ type BatteryInfo struct {
 	Left  *Battery
 	Right *Battery
 	Case  *Battery
+
+	// Single is the battery of headphones with a single battery (AirPods Max).
+	// Left, Right and Case are nil for these devices.
+	Single *Battery
+
+	// Primary is the pod the AirPods report first, which is the primary pod.
+	// The AirPods resend the battery status when the pods switch roles, so this also
+	// reports role switches. It is ComponentUnknown if no pod is reported.
+	Primary BatteryComponent
 }
 
 // ParseBatteryPacket parses a battery status packet
@@ -96,10 +108,18 @@ func ParseBatteryPacket(packet []byte) (*BatteryInfo, error) {
 		switch component {
 		case ComponentLeft:
 			info.Left = battery
+			if info.Primary == ComponentUnknown {
+				info.Primary = ComponentLeft
+			}
 		case ComponentRight:
 			info.Right = battery
+			if info.Primary == ComponentUnknown {
+				info.Primary = ComponentRight
+			}
 		case ComponentCase:
 			info.Case = battery
+		case ComponentSingle:
+			info.Single = battery
 		}
 
 		offset += 5
@@ -118,6 +138,9 @@ func IsBatteryPacket(packet []byte) bool {
 
 func (bi *BatteryInfo) String() string {
 	result := "Battery Status:\n"
+	if bi.Single != nil {
+		result += fmt.Sprintf("  Headphones: %d%% (%s)\n", bi.Single.Level, bi.Single.Status)
+	}
 	if bi.Left != nil {
 		result += fmt.Sprintf("  Left:  %d%% (%s)\n", bi.Left.Level, bi.Left.Status)
 	}
@@ -127,5 +150,8 @@ func (bi *BatteryInfo) String() string {
 	if bi.Case != nil {
 		result += fmt.Sprintf("  Case:  %d%% (%s)\n", bi.Case.Level, bi.Case.Status)
 	}
+	if bi.Primary != ComponentUnknown {
+		result += fmt.Sprintf("  Primary: %s\n", bi.Primary)
+	}
 	return result
 }
@@ -0,0 +1,49 @@
+package aap
+
+// Capabilities describes which features an AirPods model supports.
+// The UI and tray use it to hide controls the connected model doesn't support.
+type Capabilities struct {
+	SupportsANC                   bool // Active noise cancellation
+	SupportsTransparency          bool // Transparency mode
+	SupportsAdaptive              bool // Adaptive audio (mix of ANC and transparency)
+	SupportsConversationAwareness bool // Lowers media volume when speaking
+	SupportsPressAndHold          bool // Force sensor in the stem with configurable press and hold action
+	SupportsVolumeSwipe           bool // Volume control by swiping the stem
+	SupportsHeadGestures          bool // Answering calls by nodding or shaking the head
+	SupportsEarDetection          bool // Automatic in-ear detection
+	HasCase                       bool // Has a charging case with its own battery
+}
+
+// SupportsNoiseControl reports whether the model has any noise control mode to select
+func (c Capabilities) SupportsNoiseControl() bool {
+	return c.SupportsANC || c.SupportsTransparency
+}
+
+// SingleBattery reports whether the model has a single battery instead of two pods and a case,
+// e.g. AirPods Max. Batteries of such models are reported as one level.
+func (c Capabilities) SingleBattery() bool {
+	return !c.HasCase
+}
+
+// AllCapabilities is used for unknown models, so no control is hidden by mistake
+var AllCapabilities = Capabilities{
+	SupportsANC:                   true,
+	SupportsTransparency:          true,
+	SupportsAdaptive:              true,
+	SupportsConversationAwareness: true,
+	SupportsPressAndHold:          true,
+	SupportsVolumeSwipe:           true,
+	SupportsHeadGestures:          true,
+	SupportsEarDetection:          true,
+	HasCase:                       true,
+}
+
+// ModelCapabilities returns the capabilities of a device model.
+// The second return value is false for unknown models, in which case AllCapabilities is returned.
+func ModelCapabilities(deviceModel uint16) (Capabilities, bool) {
+	model, ok := LookupModel(deviceModel)
+	if !ok {
+		return AllCapabilities, false
+	}
+	return model.Capabilities, true
+}
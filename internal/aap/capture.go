@@ -0,0 +1,147 @@
+package aap
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// CaptureDirection indicates whether a captured packet was sent or received
+type CaptureDirection string
+
+const (
+	CaptureInbound  CaptureDirection = "in"  // Packet received from the AirPods
+	CaptureOutbound CaptureDirection = "out" // Packet sent to the AirPods
+)
+
+// CapturedPacket is a single packet in an AAP traffic capture.
+//
+// Captures are stored as JSON lines, one packet per line:
+//
+//	{"time":"2025-01-01T12:00:00.123456789Z","dir":"in","data":"040004000400..."}
+type CapturedPacket struct {
+	Time      time.Time        `json:"time"`
+	Direction CaptureDirection `json:"dir"`
+	Data      HexBytes         `json:"data"`
+}
+
+// HexBytes is a byte slice that is encoded as a hex string in JSON
+type HexBytes []byte
+
+// MarshalJSON encodes the bytes as a hex string
+func (h HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(h))
+}
+
+// UnmarshalJSON decodes the bytes from a hex string
+func (h *HexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("invalid hex data: %w", err)
+	}
+	*h = decoded
+	return nil
+}
+
+// CaptureConn wraps a Conn and records all inbound and outbound packets to a writer.
+// This is used to collect traffic captures for reverse-engineering new packet types.
+type CaptureConn struct {
+	Conn
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewCaptureConn wraps conn so that every packet sent or received is written to w as JSON lines
+func NewCaptureConn(conn Conn, w io.Writer) *CaptureConn {
+	return &CaptureConn{
+		Conn: conn,
+		enc:  json.NewEncoder(w),
+	}
+}
+
+// Handshake sends the handshake packet and records it
+func (c *CaptureConn) Handshake() error {
+	if err := c.Conn.Handshake(); err != nil {
+		return err
+	}
+	c.record(CaptureOutbound, packetHandshake[:])
+	return nil
+}
+
+// Send sends a packet and records it
+func (c *CaptureConn) Send(packet []byte) error {
+	if err := c.Conn.Send(packet); err != nil {
+		return err
+	}
+	c.record(CaptureOutbound, packet)
+	return nil
+}
+
+// ReadPacket reads a packet and records it
+func (c *CaptureConn) ReadPacket() ([]byte, error) {
+	packet, err := c.Conn.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+	c.record(CaptureInbound, packet)
+	return packet, nil
+}
+
+// record writes a single packet to the capture. Write errors are ignored so that
+// a failing capture file never breaks the underlying connection.
+func (c *CaptureConn) record(direction CaptureDirection, packet []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_ = c.enc.Encode(CapturedPacket{
+		Time:      time.Now(),
+		Direction: direction,
+		Data:      append(HexBytes(nil), packet...),
+	})
+}
+
+// ReadCapture reads all packets from a JSON lines capture
+func ReadCapture(r io.Reader) ([]CapturedPacket, error) {
+	var packets []CapturedPacket
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var packet CapturedPacket
+		if err := json.Unmarshal(scanner.Bytes(), &packet); err != nil {
+			return nil, fmt.Errorf("invalid capture line %d: %w", line, err)
+		}
+		packets = append(packets, packet)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read capture: %w", err)
+	}
+
+	return packets, nil
+}
+
+// NewFakeConnFromCapture creates a FakeConn that replays the inbound packets of a capture
+func NewFakeConnFromCapture(packets []CapturedPacket) *FakeConn {
+	var inbound [][]byte
+	for _, p := range packets {
+		if p.Direction == CaptureInbound {
+			inbound = append(inbound, p.Data)
+		}
+	}
+	return NewFakeConn(inbound...)
+}
@@ -9,7 +9,8 @@
 //   - Head gestures
 //
 // Communication happens over L2CAP (Logical Link Control and Adaptation Protocol)
-// on PSM (Protocol/Service Multiplexer) 4097 (0x1001).
+// on PSM (Protocol/Service Multiplexer) 4097 (0x1001). The channel is either opened
+// with a raw Bluetooth socket or by BlueZ through a registered Profile1 (see Transport).
 //
 // Protocol Flow:
 //  1. Open L2CAP connection to AirPods (PSM 4097)
@@ -25,6 +26,8 @@ package aap
 import (
 	"encoding/hex"
 	"fmt"
+	"os"
+	"sync"
 	"syscall"
 	"unsafe"
 )
@@ -63,9 +66,22 @@ var (
 
 // Client represents an AAP client connected to AirPods
 type Client struct {
-	fd     int    // L2CAP socket file descriptor
-	addr   string // Bluetooth MAC address of AirPods
-	isOpen bool
+	addr      string // Bluetooth MAC address of AirPods
+	transport Transport
+
+	mu      sync.Mutex
+	socket  *os.File           // L2CAP socket, nil while not connected
+	profile *profileConnection // Set while connected via TransportProfile
+}
+
+// ClientOption configures optional Client behavior
+type ClientOption func(*Client)
+
+// WithTransport selects how the client opens the L2CAP channel (default: TransportL2CAP)
+func WithTransport(transport Transport) ClientOption {
+	return func(c *Client) {
+		c.transport = transport
+	}
 }
 
 // bdaddr_t represents a Bluetooth device address
@@ -81,24 +97,44 @@ type sockaddr_l2 struct {
 }
 
 // NewClient creates a new AAP client for the given Bluetooth MAC address
-func NewClient(macAddr string) (*Client, error) {
-	return &Client{
-		addr: macAddr,
-	}, nil
+func NewClient(macAddr string, opts ...ClientOption) (*Client, error) {
+	c := &Client{
+		addr:      macAddr,
+		transport: TransportL2CAP,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
 }
 
-// Connect opens an L2CAP connection to the AirPods
+// Connect opens an L2CAP connection to the AirPods using the configured transport
 func (c *Client) Connect() error {
-	if c.isOpen {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.socket != nil {
 		return fmt.Errorf("already connected")
 	}
 
+	if c.transport == TransportProfile {
+		profile, err := connectProfile(c.addr)
+		if err != nil {
+			return err
+		}
+		socket, err := newSocketFile(profile.fd)
+		if err != nil {
+			profile.close()
+			return err
+		}
+		c.socket, c.profile = socket, profile
+		return nil
+	}
+
 	// Create L2CAP socket
 	fd, err := syscall.Socket(AF_BLUETOOTH, SOCK_SEQPACKET, BTPROTO_L2CAP)
 	if err != nil {
 		return fmt.Errorf("failed to create L2CAP socket: %w", err)
 	}
-	c.fd = fd
 
 	bdAddr, err := parseMACAddress(c.addr)
 	if err != nil {
@@ -120,11 +156,31 @@ func (c *Client) Connect() error {
 		uintptr(unsafe.Pointer(&addr)), unsafe.Sizeof(addr))
 	if errno != 0 {
 		_ = syscall.Close(fd)
-		return fmt.Errorf("failed to connect to AirPods: %v", errno)
+		return fmt.Errorf("failed to connect to AirPods: %w", errno)
 	}
 
-	c.isOpen = true
-	return nil
+	c.socket, err = newSocketFile(fd)
+	return err
+}
+
+// newSocketFile wraps a connected socket in a file using the runtime's poller, so that
+// closing it interrupts a blocked ReadPacket instead of leaving the read loop hanging
+func newSocketFile(fd int) (*os.File, error) {
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("failed to configure L2CAP socket: %w", err)
+	}
+	return os.NewFile(uintptr(fd), "aap"), nil
+}
+
+// connectedSocket returns the socket, or an error if the client is not connected
+func (c *Client) connectedSocket() (*os.File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.socket == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	return c.socket, nil
 }
 
 // Handshake sends the initial handshake packet to enable AAP communication
@@ -146,19 +202,25 @@ func (c *Client) EnableSpecialFeatures() error {
 // This packet requests the encryption keys (IRK and ENC_KEY) used to decrypt
 // BLE proximity pairing advertisements.
 //
-// After calling this, use ReadProximityKeys() to wait for and parse the response.
+// Use RetrieveProximityKeys to also wait for and parse the response.
 func (c *Client) RequestProximityKeys() error {
 	return c.sendPacket(packetKeyRequest[:], "key request")
 }
 
+// Send sends a raw AAP packet to the AirPods
+func (c *Client) Send(packet []byte) error {
+	return c.sendPacket(packet, "packet")
+}
+
 // sendPacket sends a packet to the AirPods and verifies it was fully written.
 // This is a common helper method used by all request methods.
 func (c *Client) sendPacket(packet []byte, packetType string) error {
-	if !c.isOpen {
-		return fmt.Errorf("not connected")
+	socket, err := c.connectedSocket()
+	if err != nil {
+		return err
 	}
 
-	n, err := syscall.Write(c.fd, packet)
+	n, err := socket.Write(packet)
 	if err != nil {
 		return fmt.Errorf("failed to send %s: %w", packetType, err)
 	}
@@ -169,14 +231,16 @@ func (c *Client) sendPacket(packet []byte, packetType string) error {
 	return nil
 }
 
-// ReadPacket reads a single AAP packet from the AirPods
+// ReadPacket reads a single AAP packet from the AirPods. It returns an error
+// (wrapping os.ErrClosed) when the client is closed while waiting.
 func (c *Client) ReadPacket() ([]byte, error) {
-	if !c.isOpen {
-		return nil, fmt.Errorf("not connected")
+	socket, err := c.connectedSocket()
+	if err != nil {
+		return nil, err
 	}
 
 	buf := make([]byte, 1024)
-	n, err := syscall.Read(c.fd, buf)
+	n, err := socket.Read(buf)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read packet: %w", err)
 	}
@@ -184,14 +248,20 @@ func (c *Client) ReadPacket() ([]byte, error) {
 	return buf[:n], nil
 }
 
-// Close closes the L2CAP connection
+// Close closes the L2CAP connection, interrupting a pending ReadPacket
 func (c *Client) Close() error {
-	if !c.isOpen {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.socket == nil {
 		return nil
 	}
 
-	err := syscall.Close(c.fd)
-	c.isOpen = false
+	err := c.socket.Close()
+	c.socket = nil
+	if c.profile != nil {
+		c.profile.close()
+		c.profile = nil
+	}
 	return err
 }
 
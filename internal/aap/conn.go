@@ -0,0 +1,77 @@
+package aap
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// Conn is a connection that exchanges AAP packets with AirPods.
+//
+// Client implements Conn over an L2CAP socket. FakeConn implements it in memory
+// by replaying canned packet sequences, which allows code built on top of Conn
+// (such as the podstate coordinator) to be exercised without hardware.
+type Conn interface {
+	// Connect opens the connection to the AirPods
+	Connect() error
+	// Handshake sends the initial handshake packet to enable AAP communication
+	Handshake() error
+	// Send writes a single raw AAP packet
+	Send(packet []byte) error
+	// ReadPacket blocks until a single AAP packet is received
+	ReadPacket() ([]byte, error)
+	// Close closes the connection, unblocking any pending ReadPacket
+	Close() error
+}
+
+// Compile-time checks that both implementations satisfy Conn
+var (
+	_ Conn = (*Client)(nil)
+	_ Conn = (*FakeConn)(nil)
+)
+
+// RequestBatteryStatus requests battery status notifications over conn
+func RequestBatteryStatus(conn Conn) error {
+	return sendRequest(conn, packetBatteryRequest[:], "battery request")
+}
+
+// EnableSpecialFeatures enables conversational awareness and adaptive transparency over conn
+func EnableSpecialFeatures(conn Conn) error {
+	return sendRequest(conn, packetEnableFeatures[:], "feature enable")
+}
+
+// RequestProximityKeys requests the proximity pairing encryption keys over conn.
+// The response arrives asynchronously as a key packet (see IsKeyPacket).
+func RequestProximityKeys(conn Conn) error {
+	return sendRequest(conn, packetKeyRequest[:], "key request")
+}
+
+// sendRequest sends a request packet over conn, wrapping errors with the packet type
+func sendRequest(conn Conn, packet []byte, packetType string) error {
+	if err := conn.Send(packet); err != nil {
+		return fmt.Errorf("failed to send %s: %w", packetType, err)
+	}
+	return nil
+}
+
+// IsTransient reports whether a connection error is likely to go away when retrying, e.g.
+// while the device is still negotiating its profiles right after connecting (EHOSTDOWN,
+// EBUSY), or when BlueZ didn't hand over the AAP channel in time (ErrProfileConnectTimeout).
+// All other errors are permanent: socket errors like a missing Bluetooth adapter or denied
+// permissions, an invalid MAC address, BlueZ error replies and failed handshakes.
+func IsTransient(err error) bool {
+	if errors.Is(err, ErrProfileConnectTimeout) {
+		return true
+	}
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	switch errno {
+	case syscall.EHOSTDOWN, syscall.EBUSY, syscall.EAGAIN, syscall.EALREADY, syscall.EINPROGRESS,
+		syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.ETIMEDOUT, syscall.EHOSTUNREACH, syscall.ENOTCONN:
+		return true
+	default:
+		return false
+	}
+}
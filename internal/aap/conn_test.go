@@ -0,0 +1,37 @@
+package aap_test
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+
+	"linuxpods/internal/aap"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"host down", syscall.EHOSTDOWN, true},
+		{"busy", syscall.EBUSY, true},
+		{"wrapped connection refused", fmt.Errorf("failed to connect to AirPods: %w", syscall.ECONNREFUSED), true},
+		{"profile connect timeout", fmt.Errorf("failed to create AAP client: %w", aap.ErrProfileConnectTimeout), true},
+		{"permission denied", fmt.Errorf("failed to create L2CAP socket: %w", syscall.EACCES), false},
+		{"no adapter", syscall.ENODEV, false},
+		{"invalid MAC address", fmt.Errorf("invalid MAC address: %w", errors.New("invalid MAC address length")), false},
+		{"BlueZ error reply", fmt.Errorf("failed to connect AAP profile: %w", dbus.NewError("org.bluez.Error.NotAvailable", nil)), false},
+		{"handshake failed", fmt.Errorf("failed to send handshake: %w", errors.New("not connected")), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := aap.IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package aap
+
+import (
+	"fmt"
+)
+
+// ControlCommandID identifies a device setting that is read and written with control commands.
+//
+// Control command packets are used in both directions: LinuxPods sends them to change a
+// setting and the AirPods send them to notify about the current value of a setting.
+//
+// Packet format:
+//
+//	Offset 0-5: Header (04 00 04 00 09 00)
+//	Offset 6:   Setting identifier
+//	Offset 7-10: Setting value (4 bytes, unused bytes are zero)
+//
+// Identifiers are based on LibrePods (ControlCommandIdentifiers).
+type ControlCommandID uint8
+
+const (
+	ControlMicrophoneMode       ControlCommandID = 0x01 // Which bud's microphone is used
+	ControlEarDetection         ControlCommandID = 0x0A // Automatic ear detection on/off
+	ControlListeningMode        ControlCommandID = 0x0D // Current noise control mode
+	ControlPressAndHoldMode     ControlCommandID = 0x16 // Action of the press and hold gesture of each bud
+	ControlPressSpeed           ControlCommandID = 0x17 // Speed of double and triple presses
+	ControlHoldDuration         ControlCommandID = 0x18 // Duration of the press and hold gesture
+	ControlListeningModeConfigs ControlCommandID = 0x1A // Noise control modes included in the press and hold cycle
+	ControlChimeVolume          ControlCommandID = 0x1F // Volume of tones and alerts played by the AirPods
+	ControlVolumeSwipe          ControlCommandID = 0x25 // Volume control by swiping the stem on/off
+)
+
+func (id ControlCommandID) String() string {
+	switch id {
+	case ControlMicrophoneMode:
+		return "Microphone Mode"
+	case ControlEarDetection:
+		return "Ear Detection"
+	case ControlListeningMode:
+		return "Listening Mode"
+	case ControlPressAndHoldMode:
+		return "Press and Hold"
+	case ControlPressSpeed:
+		return "Press Speed"
+	case ControlHoldDuration:
+		return "Hold Duration"
+	case ControlListeningModeConfigs:
+		return "Listening Mode Cycle"
+	case ControlChimeVolume:
+		return "Tone Volume"
+	case ControlVolumeSwipe:
+		return "Volume Swipe"
+	default:
+		return fmt.Sprintf("Unknown (0x%02X)", uint8(id))
+	}
+}
+
+// controlCommandOpcode is the packet type (byte 4) of control command packets
+const controlCommandOpcode = 0x09
+
+// controlCommandLength is the length of a control command packet (header + id + 4 value bytes)
+const controlCommandLength = 11
+
+// ControlCommand is a parsed control command packet
+type ControlCommand struct {
+	ID    ControlCommandID
+	Value [4]byte
+}
+
+// BuildControlCommand builds a control command packet setting id to the given value bytes.
+// At most 4 value bytes are used, missing bytes are zero.
+func BuildControlCommand(id ControlCommandID, value ...byte) []byte {
+	packet := []byte{0x04, 0x00, 0x04, 0x00, controlCommandOpcode, 0x00, byte(id), 0x00, 0x00, 0x00, 0x00}
+	copy(packet[7:], value)
+	return packet
+}
+
+// IsControlCommandPacket checks if a packet is a control command (setting) packet
+func IsControlCommandPacket(packet []byte) bool {
+	return len(packet) >= 7 &&
+		packet[0] == 0x04 && packet[1] == 0x00 &&
+		packet[2] == 0x04 && packet[3] == 0x00 &&
+		packet[4] == controlCommandOpcode && packet[5] == 0x00
+}
+
+// ParseControlCommand parses a control command packet
+func ParseControlCommand(packet []byte) (*ControlCommand, error) {
+	if !IsControlCommandPacket(packet) {
+		return nil, fmt.Errorf("not a control command packet")
+	}
+
+	cmd := &ControlCommand{ID: ControlCommandID(packet[6])}
+	// Some firmware versions send fewer value bytes, missing bytes are treated as zero
+	copy(cmd.Value[:], packet[7:min(len(packet), controlCommandLength)])
+
+	return cmd, nil
+}
+
+// SendControlCommand sets a device setting over conn
+func SendControlCommand(conn Conn, id ControlCommandID, value ...byte) error {
+	return sendRequest(conn, BuildControlCommand(id, value...), fmt.Sprintf("%s command", id))
+}
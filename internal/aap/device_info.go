@@ -0,0 +1,102 @@
+package aap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// deviceInfoOpcode is the packet type (byte 4) of device information packets
+const deviceInfoOpcode = 0x1D
+
+// DeviceInfo is a parsed device information packet. The AirPods send it once after the
+// handshake. Fields that a device doesn't send are empty.
+type DeviceInfo struct {
+	Name              string // Name of the device, as set on the iPhone
+	ModelNumber       string // Apple model number, e.g. "A2698"
+	Manufacturer      string
+	SerialNumber      string // Serial number of the case, or of headphones without a case
+	FirmwareVersion   string // e.g. "6F21"
+	HardwareRevision  string
+	LeftSerialNumber  string
+	RightSerialNumber string
+}
+
+// deviceInfoFields are the fields of DeviceInfo in the order of the strings in the packet.
+// The strings at the indices without a field (a second firmware version and an updater
+// identifier) are skipped.
+var deviceInfoFields = []func(*DeviceInfo) *string{
+	0: func(d *DeviceInfo) *string { return &d.Name },
+	1: func(d *DeviceInfo) *string { return &d.ModelNumber },
+	2: func(d *DeviceInfo) *string { return &d.Manufacturer },
+	3: func(d *DeviceInfo) *string { return &d.SerialNumber },
+	4: func(d *DeviceInfo) *string { return &d.FirmwareVersion },
+	6: func(d *DeviceInfo) *string { return &d.HardwareRevision },
+	8: func(d *DeviceInfo) *string { return &d.LeftSerialNumber },
+	9: func(d *DeviceInfo) *string { return &d.RightSerialNumber },
+}
+
+// IsDeviceInfoPacket checks if a packet is a device information packet
+func IsDeviceInfoPacket(packet []byte) bool {
+	return len(packet) >= 6 &&
+		packet[0] == 0x04 && packet[1] == 0x00 &&
+		packet[2] == 0x04 && packet[3] == 0x00 &&
+		packet[4] == deviceInfoOpcode && packet[5] == 0x00
+}
+
+// ParseDeviceInfoPacket parses a device information packet
+// Format: 04 00 04 00 1D 00 [header bytes] [name] 00 [model number] 00 [manufacturer] 00 ...
+// The strings are null-terminated. The header bytes before the name aren't printable and are
+// skipped, as are non-printable characters within the strings.
+func ParseDeviceInfoPacket(packet []byte) (*DeviceInfo, error) {
+	if !IsDeviceInfoPacket(packet) {
+		return nil, fmt.Errorf("not a device information packet")
+	}
+
+	payload := packet[6:]
+	start := bytes.IndexFunc(payload, func(r rune) bool { return r >= 0x20 && r < 0x7F })
+	if start < 0 {
+		return nil, fmt.Errorf("device information packet has no strings")
+	}
+
+	info := &DeviceInfo{}
+	for i, raw := range bytes.Split(payload[start:], []byte{0x00}) {
+		if i >= len(deviceInfoFields) {
+			break
+		}
+		if field := deviceInfoFields[i]; field != nil {
+			*field(info) = printable(raw)
+		}
+	}
+	return info, nil
+}
+
+// printable returns the printable ASCII characters of a string
+func printable(raw []byte) string {
+	var b strings.Builder
+	for _, c := range raw {
+		if c >= 0x20 && c < 0x7F {
+			b.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func (d *DeviceInfo) String() string {
+	result := "Device Information:"
+	for _, field := range []struct{ name, value string }{
+		{"Name", d.Name},
+		{"Model number", d.ModelNumber},
+		{"Manufacturer", d.Manufacturer},
+		{"Serial number", d.SerialNumber},
+		{"Firmware", d.FirmwareVersion},
+		{"Hardware revision", d.HardwareRevision},
+		{"Left serial number", d.LeftSerialNumber},
+		{"Right serial number", d.RightSerialNumber},
+	} {
+		if field.value != "" {
+			result += fmt.Sprintf("\n  %s: %s", field.name, field.value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+package aap
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Diagnostics-only packet inspection.
+//
+// The meaning of many AAP status packets is not known yet. Newer models are believed to
+// report sensor data (e.g. bud temperature) in some of them, but no field layout has been
+// confirmed. Instead of guessing, packets that none of the parsers understand are collected
+// per opcode, so advanced users can watch the raw payloads (e.g. while a bud heats up) and
+// help identify the fields. Nothing here is used for regular state updates.
+
+// UnparsedPacket summarizes the packets received with an opcode no parser understands
+type UnparsedPacket struct {
+	Opcode   uint8     // Packet type (byte 4)
+	Count    uint64    // Number of packets received with this opcode
+	Last     HexBytes  // Most recent packet
+	LastSeen time.Time // When the most recent packet was received
+}
+
+// PacketOpcode returns the opcode (byte 4) of an AAP packet with the standard 04 00 04 00 header
+func PacketOpcode(packet []byte) (uint8, bool) {
+	if len(packet) < 6 ||
+		packet[0] != 0x04 || packet[1] != 0x00 ||
+		packet[2] != 0x04 || packet[3] != 0x00 {
+		return 0, false
+	}
+	return packet[4], true
+}
+
+// IsParsedPacket reports whether one of the packet parsers understands the packet
+func IsParsedPacket(packet []byte) bool {
+	return IsBatteryPacket(packet) || IsControlCommandPacket(packet) || IsKeyPacket(packet) ||
+		IsEarStatusPacket(packet)
+}
+
+// PacketRecorder collects unparsed packets by opcode. It is safe for concurrent use.
+type PacketRecorder struct {
+	mu      sync.Mutex
+	packets map[uint8]*UnparsedPacket
+}
+
+// Record records the packet if it has an AAP header but no parser understands it
+func (r *PacketRecorder) Record(packet []byte) {
+	opcode, ok := PacketOpcode(packet)
+	if !ok || IsParsedPacket(packet) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.packets == nil {
+		r.packets = make(map[uint8]*UnparsedPacket)
+	}
+	entry, ok := r.packets[opcode]
+	if !ok {
+		entry = &UnparsedPacket{Opcode: opcode}
+		r.packets[opcode] = entry
+	}
+	entry.Count++
+	entry.Last = append(HexBytes(nil), packet...)
+	entry.LastSeen = time.Now()
+}
+
+// Snapshot returns the recorded packets sorted by opcode
+func (r *PacketRecorder) Snapshot() []UnparsedPacket {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	packets := make([]UnparsedPacket, 0, len(r.packets))
+	for _, entry := range r.packets {
+		packets = append(packets, *entry)
+	}
+	sort.Slice(packets, func(i, j int) bool { return packets[i].Opcode < packets[j].Opcode })
+	return packets
+}
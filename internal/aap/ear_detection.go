@@ -0,0 +1,37 @@
+package aap
+
+import (
+	"fmt"
+)
+
+// Ear detection setting values
+const (
+	earDetectionEnabled  = 0x01
+	earDetectionDisabled = 0x02
+)
+
+// ParseEarDetection extracts whether automatic ear detection is enabled from a control command notification
+func ParseEarDetection(cmd *ControlCommand) (bool, error) {
+	if cmd.ID != ControlEarDetection {
+		return false, fmt.Errorf("not an ear detection command: %s", cmd.ID)
+	}
+	switch cmd.Value[0] {
+	case earDetectionEnabled:
+		return true, nil
+	case earDetectionDisabled:
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid ear detection value 0x%02X", cmd.Value[0])
+	}
+}
+
+// SetEarDetection enables or disables automatic ear detection on the device.
+// With ear detection disabled, the AirPods no longer pause playback when taken out
+// and always route audio to both buds.
+func SetEarDetection(conn Conn, enabled bool) error {
+	value := uint8(earDetectionDisabled)
+	if enabled {
+		value = earDetectionEnabled
+	}
+	return SendControlCommand(conn, ControlEarDetection, value)
+}
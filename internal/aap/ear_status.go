@@ -0,0 +1,62 @@
+package aap
+
+import (
+	"fmt"
+)
+
+// earStatusOpcode is the packet type (byte 4) of ear status notifications
+const earStatusOpcode = 0x06
+
+// EarStatus is the position of a single pod as reported by ear status notifications
+type EarStatus uint8
+
+const (
+	EarStatusInEar    EarStatus = 0x00
+	EarStatusOutOfEar EarStatus = 0x01
+	EarStatusInCase   EarStatus = 0x02
+)
+
+func (s EarStatus) String() string {
+	switch s {
+	case EarStatusInEar:
+		return "In Ear"
+	case EarStatusOutOfEar:
+		return "Out of Ear"
+	case EarStatusInCase:
+		return "In Case"
+	default:
+		return fmt.Sprintf("Unknown (0x%02X)", uint8(s))
+	}
+}
+
+// EarStatusInfo is a parsed ear status notification.
+// The AirPods send it whenever a pod is put in or taken out of an ear or the case.
+// Pods are identified by role, use BatteryInfo.Primary to map them to left and right.
+type EarStatusInfo struct {
+	Primary   EarStatus
+	Secondary EarStatus
+}
+
+// IsEarStatusPacket checks if a packet is an ear status notification
+func IsEarStatusPacket(packet []byte) bool {
+	return len(packet) >= 8 &&
+		packet[0] == 0x04 && packet[1] == 0x00 &&
+		packet[2] == 0x04 && packet[3] == 0x00 &&
+		packet[4] == earStatusOpcode && packet[5] == 0x00
+}
+
+// ParseEarStatusPacket parses an ear status notification
+// Format: 04 00 04 00 06 00 [primary] [secondary]
+func ParseEarStatusPacket(packet []byte) (*EarStatusInfo, error) {
+	if !IsEarStatusPacket(packet) {
+		return nil, fmt.Errorf("not an ear status packet")
+	}
+	return &EarStatusInfo{
+		Primary:   EarStatus(packet[6]),
+		Secondary: EarStatus(packet[7]),
+	}, nil
+}
+
+func (e *EarStatusInfo) String() string {
+	return fmt.Sprintf("Ear Status: primary %s, secondary %s", e.Primary, e.Secondary)
+}
@@ -0,0 +1,134 @@
+package aap
+
+import (
+	"fmt"
+	"sync"
+)
+
+// FakeConn is an in-memory Conn that replays a canned sequence of packets.
+//
+// ReadPacket returns the queued packets in order. Once the queue is drained it
+// blocks like a real socket until more packets are pushed or the connection is
+// closed. All packets written with Send (and the handshake) are recorded and can
+// be inspected with Sent. If Responder is set, the packets it returns for a sent
+// packet are queued as if the device had answered.
+type FakeConn struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	incoming  [][]byte
+	sent      [][]byte
+	connected bool
+	closed    bool
+
+	// ConnectErr, if set, is returned by Connect to simulate connection failures
+	ConnectErr error
+
+	// Responder, if set, is called with every sent packet and returns the packets
+	// the simulated device replies with. It must not call methods of the FakeConn.
+	Responder func(packet []byte) [][]byte
+}
+
+// NewFakeConn creates a FakeConn that will replay the given packets in order
+func NewFakeConn(packets ...[]byte) *FakeConn {
+	f := &FakeConn{}
+	f.cond = sync.NewCond(&f.mu)
+	for _, p := range packets {
+		f.incoming = append(f.incoming, append([]byte(nil), p...))
+	}
+	return f
+}
+
+// Connect marks the fake connection as open
+func (f *FakeConn) Connect() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.ConnectErr != nil {
+		return f.ConnectErr
+	}
+	if f.connected {
+		return fmt.Errorf("already connected")
+	}
+	f.connected = true
+	f.closed = false
+	return nil
+}
+
+// Handshake records the handshake packet
+func (f *FakeConn) Handshake() error {
+	return f.Send(packetHandshake[:])
+}
+
+// Send records an outgoing packet
+func (f *FakeConn) Send(packet []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.connected {
+		return fmt.Errorf("not connected")
+	}
+	f.sent = append(f.sent, append([]byte(nil), packet...))
+
+	if f.Responder != nil {
+		for _, reply := range f.Responder(packet) {
+			f.incoming = append(f.incoming, append([]byte(nil), reply...))
+		}
+		f.cond.Broadcast()
+	}
+	return nil
+}
+
+// ReadPacket returns the next queued packet, blocking until one is available
+// or the connection is closed
+func (f *FakeConn) ReadPacket() ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.incoming) == 0 && !f.closed {
+		if !f.connected {
+			return nil, fmt.Errorf("not connected")
+		}
+		f.cond.Wait()
+	}
+	if f.closed {
+		return nil, fmt.Errorf("connection closed")
+	}
+
+	packet := f.incoming[0]
+	f.incoming = f.incoming[1:]
+	return packet, nil
+}
+
+// Push queues additional packets to be returned by ReadPacket
+func (f *FakeConn) Push(packets ...[]byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, p := range packets {
+		f.incoming = append(f.incoming, append([]byte(nil), p...))
+	}
+	f.cond.Broadcast()
+}
+
+// Sent returns a copy of all packets written to the connection
+func (f *FakeConn) Sent() [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sent := make([][]byte, len(f.sent))
+	for i, p := range f.sent {
+		sent[i] = append([]byte(nil), p...)
+	}
+	return sent
+}
+
+// Close closes the fake connection and unblocks pending reads
+func (f *FakeConn) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.connected = false
+	f.closed = true
+	f.cond.Broadcast()
+	return nil
+}
@@ -0,0 +1,72 @@
+package aap
+
+import (
+	"fmt"
+	"time"
+)
+
+// KeyRetrieval configures how RetrieveProximityKeys waits for the proximity keys
+type KeyRetrieval struct {
+	Timeout  time.Duration // How long to wait for the key response after each request
+	Attempts int           // Number of key requests to send before giving up
+}
+
+// DefaultKeyRetrieval is used when no other configuration is given.
+// The AirPods usually answer within a few hundred milliseconds, but may drop the
+// request while busy (e.g. right after connecting).
+var DefaultKeyRetrieval = KeyRetrieval{
+	Timeout:  3 * time.Second,
+	Attempts: 3,
+}
+
+// KeyResponses reads packets from conn in the background and delivers every parsed key
+// response on the returned channel. Other packets are discarded. The channel is closed
+// when reading fails, e.g. when conn is closed.
+//
+// Use it when nothing else reads from conn. If a read loop already owns the connection,
+// it should deliver the key responses itself.
+func KeyResponses(conn Conn) <-chan []ProximityKey {
+	responses := make(chan []ProximityKey, 1)
+	go func() {
+		defer close(responses)
+		for {
+			packet, err := conn.ReadPacket()
+			if err != nil {
+				return
+			}
+			if !IsKeyPacket(packet) {
+				continue // Not a key packet, keep waiting
+			}
+			if keys, err := ParseProximityKeys(packet); err == nil {
+				responses <- keys
+			}
+		}
+	}()
+	return responses
+}
+
+// RetrieveProximityKeys requests the proximity keys (IRK and ENC_KEY) over conn and waits
+// for them to arrive on responses (see KeyResponses). If no response arrives within the
+// timeout, the request is sent again, up to config.Attempts requests in total.
+//
+// The connection must be connected and the handshake must be completed before calling this.
+func RetrieveProximityKeys(conn Conn, responses <-chan []ProximityKey, config KeyRetrieval) ([]ProximityKey, error) {
+	attempts := max(config.Attempts, 1)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err := RequestProximityKeys(conn); err != nil {
+			return nil, err
+		}
+
+		select {
+		case keys, ok := <-responses:
+			if !ok {
+				return nil, fmt.Errorf("connection closed while waiting for keys")
+			}
+			return keys, nil
+		case <-time.After(config.Timeout):
+			// No response, the request may have been dropped - send it again
+		}
+	}
+
+	return nil, fmt.Errorf("no key response after %d attempts (timeout %v each)", attempts, config.Timeout)
+}
@@ -0,0 +1,54 @@
+package aap
+
+import (
+	"fmt"
+)
+
+// MicrophoneMode selects which bud's microphone is used
+type MicrophoneMode uint8
+
+const (
+	MicrophoneAutomatic   MicrophoneMode = 0x00 // Switch automatically between both buds
+	MicrophoneAlwaysRight MicrophoneMode = 0x01 // Always use the right bud
+	MicrophoneAlwaysLeft  MicrophoneMode = 0x02 // Always use the left bud
+)
+
+// MicrophoneModes lists all microphone modes in display order
+var MicrophoneModes = []MicrophoneMode{
+	MicrophoneAutomatic,
+	MicrophoneAlwaysLeft,
+	MicrophoneAlwaysRight,
+}
+
+func (m MicrophoneMode) String() string {
+	switch m {
+	case MicrophoneAutomatic:
+		return "Automatic"
+	case MicrophoneAlwaysRight:
+		return "Always Right"
+	case MicrophoneAlwaysLeft:
+		return "Always Left"
+	default:
+		return fmt.Sprintf("Unknown (0x%02X)", uint8(m))
+	}
+}
+
+// ParseMicrophoneMode extracts the microphone mode from a control command notification
+func ParseMicrophoneMode(cmd *ControlCommand) (MicrophoneMode, error) {
+	if cmd.ID != ControlMicrophoneMode {
+		return 0, fmt.Errorf("not a microphone mode command: %s", cmd.ID)
+	}
+	mode := MicrophoneMode(cmd.Value[0])
+	if mode > MicrophoneAlwaysLeft {
+		return 0, fmt.Errorf("invalid microphone mode 0x%02X", cmd.Value[0])
+	}
+	return mode, nil
+}
+
+// SetMicrophoneMode selects which bud's microphone is used
+func SetMicrophoneMode(conn Conn, mode MicrophoneMode) error {
+	if mode > MicrophoneAlwaysLeft {
+		return fmt.Errorf("invalid microphone mode 0x%02X", uint8(mode))
+	}
+	return SendControlCommand(conn, ControlMicrophoneMode, uint8(mode))
+}
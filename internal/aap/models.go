@@ -0,0 +1,116 @@
+package aap
+
+import (
+	"fmt"
+)
+
+// ModelFamily groups device models with the same form factor and feature set
+type ModelFamily uint8
+
+const (
+	FamilyAirPods    ModelFamily = iota // AirPods (non-Pro) earbuds
+	FamilyAirPodsPro                    // AirPods Pro earbuds
+	FamilyAirPodsMax                    // AirPods Max over-ear headphones
+	FamilyBeats                         // Beats earbuds and headphones
+)
+
+func (f ModelFamily) String() string {
+	switch f {
+	case FamilyAirPods:
+		return "AirPods"
+	case FamilyAirPodsPro:
+		return "AirPods Pro"
+	case FamilyAirPodsMax:
+		return "AirPods Max"
+	case FamilyBeats:
+		return "Beats"
+	default:
+		return fmt.Sprintf("Unknown (%d)", uint8(f))
+	}
+}
+
+// Model describes a device model as identified by the model code in BLE proximity pairing advertisements
+type Model struct {
+	ID           uint16 // Model code (bytes 1-2 of the advertisement, big-endian)
+	Name         string
+	Family       ModelFamily
+	Generation   int // Generation within the family, 0 for Beats models
+	Capabilities Capabilities
+}
+
+// Capabilities of the earbuds and headphones without noise control
+var (
+	basicEarbuds    = Capabilities{SupportsEarDetection: true, HasCase: true}
+	stemEarbuds     = Capabilities{SupportsPressAndHold: true, SupportsEarDetection: true, HasCase: true}
+	basicHeadphones = Capabilities{}
+	ancEarbuds      = Capabilities{SupportsANC: true, SupportsTransparency: true, SupportsPressAndHold: true, SupportsEarDetection: true, HasCase: true}
+	ancHeadphones   = Capabilities{SupportsANC: true, SupportsTransparency: true, SupportsEarDetection: true}
+)
+
+// Models is the table of known device models.
+//
+// Model codes are based on LibrePods and the furiousMAC Continuity protocol research.
+var Models = []Model{
+	// AirPods
+	{ID: 0x0220, Name: "AirPods (1st gen)", Family: FamilyAirPods, Generation: 1, Capabilities: basicEarbuds},
+	{ID: 0x0f20, Name: "AirPods (2nd gen)", Family: FamilyAirPods, Generation: 2, Capabilities: basicEarbuds},
+	{ID: 0x1320, Name: "AirPods (3rd gen)", Family: FamilyAirPods, Generation: 3, Capabilities: stemEarbuds},
+	{ID: 0x1920, Name: "AirPods 4", Family: FamilyAirPods, Generation: 4, Capabilities: Capabilities{
+		SupportsPressAndHold: true,
+		SupportsHeadGestures: true,
+		SupportsEarDetection: true,
+		HasCase:              true,
+	}},
+	{ID: 0x1b20, Name: "AirPods 4 (ANC)", Family: FamilyAirPods, Generation: 4, Capabilities: Capabilities{
+		SupportsANC:                   true,
+		SupportsTransparency:          true,
+		SupportsAdaptive:              true,
+		SupportsConversationAwareness: true,
+		SupportsPressAndHold:          true,
+		SupportsHeadGestures:          true,
+		SupportsEarDetection:          true,
+		HasCase:                       true,
+	}},
+
+	// AirPods Pro
+	{ID: 0x0e20, Name: "AirPods Pro", Family: FamilyAirPodsPro, Generation: 1, Capabilities: ancEarbuds},
+	{ID: 0x1420, Name: "AirPods Pro (2nd gen)", Family: FamilyAirPodsPro, Generation: 2, Capabilities: AllCapabilities},
+	{ID: 0x2420, Name: "AirPods Pro (2nd gen, USB-C)", Family: FamilyAirPodsPro, Generation: 2, Capabilities: AllCapabilities},
+	{ID: 0x2720, Name: "AirPods Pro 3", Family: FamilyAirPodsPro, Generation: 3, Capabilities: AllCapabilities},
+
+	// AirPods Max
+	{ID: 0x0a20, Name: "AirPods Max", Family: FamilyAirPodsMax, Generation: 1, Capabilities: ancHeadphones},
+	{ID: 0x1f20, Name: "AirPods Max (USB-C)", Family: FamilyAirPodsMax, Generation: 1, Capabilities: ancHeadphones},
+
+	// Beats
+	{ID: 0x0320, Name: "Powerbeats3", Family: FamilyBeats, Capabilities: basicHeadphones},
+	{ID: 0x0520, Name: "BeatsX", Family: FamilyBeats, Capabilities: basicHeadphones},
+	{ID: 0x0620, Name: "Beats Solo3", Family: FamilyBeats, Capabilities: basicHeadphones},
+	{ID: 0x0920, Name: "Beats Studio3", Family: FamilyBeats, Capabilities: Capabilities{SupportsANC: true}},
+	{ID: 0x0b20, Name: "Powerbeats Pro", Family: FamilyBeats, Capabilities: basicEarbuds},
+	{ID: 0x0c20, Name: "Beats Solo Pro", Family: FamilyBeats, Capabilities: Capabilities{SupportsANC: true, SupportsTransparency: true}},
+	{ID: 0x0d20, Name: "Powerbeats", Family: FamilyBeats, Capabilities: basicHeadphones},
+	{ID: 0x1020, Name: "Beats Flex", Family: FamilyBeats, Capabilities: basicHeadphones},
+	{ID: 0x1120, Name: "Beats Studio Buds", Family: FamilyBeats, Capabilities: Capabilities{SupportsANC: true, SupportsTransparency: true, HasCase: true}},
+	{ID: 0x1220, Name: "Beats Fit Pro", Family: FamilyBeats, Capabilities: ancEarbuds},
+	{ID: 0x1620, Name: "Beats Studio Buds+", Family: FamilyBeats, Capabilities: Capabilities{SupportsANC: true, SupportsTransparency: true, HasCase: true}},
+	{ID: 0x1720, Name: "Beats Studio Pro", Family: FamilyBeats, Capabilities: Capabilities{SupportsANC: true, SupportsTransparency: true}},
+	{ID: 0x1d20, Name: "Powerbeats Pro 2", Family: FamilyBeats, Capabilities: ancEarbuds},
+	{ID: 0x2520, Name: "Beats Solo 4", Family: FamilyBeats, Capabilities: basicHeadphones},
+	{ID: 0x2620, Name: "Beats Solo Buds", Family: FamilyBeats, Capabilities: Capabilities{HasCase: true}},
+}
+
+// modelsByID indexes Models by model code
+var modelsByID = func() map[uint16]Model {
+	byID := make(map[uint16]Model, len(Models))
+	for _, model := range Models {
+		byID[model.ID] = model
+	}
+	return byID
+}()
+
+// LookupModel returns the model with the given model code
+func LookupModel(deviceModel uint16) (Model, bool) {
+	model, ok := modelsByID[deviceModel]
+	return model, ok
+}
@@ -0,0 +1,130 @@
+package aap_test
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/annotator"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the parser corpus")
+
+// capture is a corpus file of testdata and the inbound packets read from it
+type capture struct {
+	path    string
+	packets [][]byte
+}
+
+// loadCaptures reads the AAP captures (see debug_aap) of testdata. The synthetic ones are not
+// captured from devices, see testdata/README.md.
+func loadCaptures(tb testing.TB) []capture {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.jsonl"))
+	if err != nil {
+		tb.Fatalf("Failed to list corpus: %v", err)
+	}
+	if len(paths) == 0 {
+		tb.Fatal("No captures in testdata")
+	}
+
+	var captures []capture
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			tb.Fatalf("Failed to open corpus file: %v", err)
+		}
+		packets, err := aap.ReadCapture(file)
+		file.Close()
+		if err != nil {
+			tb.Fatalf("Failed to read %s: %v", path, err)
+		}
+
+		c := capture{path: path}
+		for _, packet := range packets {
+			if packet.Direction == aap.CaptureInbound {
+				c.packets = append(c.packets, packet.Data)
+			}
+		}
+		captures = append(captures, c)
+	}
+	return captures
+}
+
+// TestGolden compares the parse results of the corpus with the golden files next to it.
+// Run with -update to rewrite them after an intended parser change.
+func TestGolden(t *testing.T) {
+	for _, c := range loadCaptures(t) {
+		t.Run(filepath.Base(c.path), func(t *testing.T) {
+			var out strings.Builder
+			for i, packet := range c.packets {
+				fmt.Fprintf(&out, "=== #%d %s\n%s\n", i+1, hex.EncodeToString(packet), annotator.DescribeAAPPacket(packet))
+			}
+
+			goldenPath := strings.TrimSuffix(c.path, ".jsonl") + ".golden"
+			if *update {
+				if err := os.WriteFile(goldenPath, []byte(out.String()), 0o644); err != nil {
+					t.Fatalf("Failed to write golden file: %v", err)
+				}
+				return
+			}
+
+			golden, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("Failed to read golden file: %v (run with -update to create it)", err)
+			}
+			expected := strings.Split(string(golden), "\n")
+			actual := strings.Split(out.String(), "\n")
+			for i := range max(len(expected), len(actual)) {
+				var e, a string
+				if i < len(expected) {
+					e = expected[i]
+				}
+				if i < len(actual) {
+					a = actual[i]
+				}
+				if e != a {
+					t.Fatalf("Parse results differ from %s at line %d:\n  expected: %s\n  actual:   %s", goldenPath, i+1, e, a)
+				}
+			}
+		})
+	}
+}
+
+// FuzzParsePacket checks that packets from the device neither panic the parsers nor
+// produce implausible values
+func FuzzParsePacket(f *testing.F) {
+	for _, c := range loadCaptures(f) {
+		for _, packet := range c.packets {
+			f.Add(packet)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, packet []byte) {
+		if info, err := aap.ParseBatteryPacket(packet); err == nil {
+			_ = info.String()
+		}
+		if keys, err := aap.ParseProximityKeys(packet); err == nil {
+			for _, key := range keys {
+				if len(key.Data) > len(packet) {
+					t.Errorf("Key of %d bytes in a %d byte packet", len(key.Data), len(packet))
+				}
+			}
+		}
+		if aap.IsControlCommandPacket(packet) {
+			_, _ = aap.ParseControlCommand(packet)
+		}
+		if aap.IsEarStatusPacket(packet) {
+			_, _ = aap.ParseEarStatusPacket(packet)
+		}
+		if info, err := aap.ParseDeviceInfoPacket(packet); err == nil {
+			_ = info.String()
+		}
+		_ = aap.ParseDeviceSettings([][]byte{packet})
+		_ = annotator.AAPPacket(packet)
+	})
+}
@@ -0,0 +1,210 @@
+package aap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Transport selects how the AAP L2CAP channel is opened
+type Transport string
+
+const (
+	// TransportL2CAP opens a raw AF_BLUETOOTH socket directly.
+	// This requires access to Bluetooth sockets, which is unavailable in sandboxes such as Flatpak.
+	TransportL2CAP Transport = "l2cap"
+
+	// TransportProfile registers an org.bluez.Profile1 for the AAP PSM over D-Bus and
+	// lets BlueZ open the channel. BlueZ hands the connected socket to the profile as a
+	// file descriptor, so only system bus access is needed.
+	TransportProfile Transport = "profile"
+)
+
+const (
+	// AAPServiceUUID identifies the AAP service when registering the BlueZ profile
+	AAPServiceUUID = "74ec2172-0bad-4d01-8f77-997b2be0722a"
+
+	profileManagerIface = "org.bluez.ProfileManager1"
+	profileIface        = "org.bluez.Profile1"
+	profilePathPrefix   = "/com/github/mstroecker/linuxpods/aap"
+
+	// profileConnectTimeout bounds the wait for BlueZ to hand over the socket
+	profileConnectTimeout = 10 * time.Second
+)
+
+// ErrProfileConnectTimeout is returned when BlueZ doesn't hand over the AAP channel in time.
+// The device may still be busy connecting its audio profiles, so the connection can be retried.
+var ErrProfileConnectTimeout = errors.New("timed out waiting for BlueZ to open the AAP channel")
+
+// ParseTransport parses a transport name. An empty string selects TransportL2CAP.
+func ParseTransport(s string) (Transport, error) {
+	switch Transport(s) {
+	case "", TransportL2CAP:
+		return TransportL2CAP, nil
+	case TransportProfile:
+		return TransportProfile, nil
+	default:
+		return TransportL2CAP, fmt.Errorf("unknown AAP transport %q (expected l2cap or profile)", s)
+	}
+}
+
+// profileConnection is an AAP channel opened by BlueZ on behalf of a registered Profile1
+type profileConnection struct {
+	conn *dbus.Conn
+	path dbus.ObjectPath
+	fd   int
+}
+
+// profileHandler implements the org.bluez.Profile1 interface.
+// BlueZ calls NewConnection with the connected socket once ConnectProfile succeeds.
+type profileHandler struct {
+	fds chan int
+
+	mu     sync.Mutex
+	gaveUp bool // The caller stopped waiting, sockets arriving now are closed
+}
+
+// Release is called when BlueZ unregisters the profile
+func (h *profileHandler) Release() *dbus.Error {
+	return nil
+}
+
+// NewConnection receives the connected L2CAP socket from BlueZ
+func (h *profileHandler) NewConnection(device dbus.ObjectPath, fd dbus.UnixFD, properties map[string]dbus.Variant) *dbus.Error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.gaveUp {
+		_ = syscall.Close(int(fd))
+		return nil
+	}
+	select {
+	case h.fds <- int(fd):
+	default:
+		// A socket is already waiting to be picked up
+		_ = syscall.Close(int(fd))
+	}
+	return nil
+}
+
+// giveUp is called when connectProfile stops waiting. It closes the socket if one arrived in
+// the meantime and any socket BlueZ hands over later.
+func (h *profileHandler) giveUp() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.gaveUp = true
+	select {
+	case fd := <-h.fds:
+		_ = syscall.Close(fd)
+	default:
+	}
+}
+
+// RequestDisconnection is called when BlueZ disconnects the profile from a device
+func (h *profileHandler) RequestDisconnection(device dbus.ObjectPath) *dbus.Error {
+	return nil
+}
+
+// connectProfile registers an AAP profile with BlueZ, asks BlueZ to connect it to the
+// given device and waits for the socket file descriptor.
+func connectProfile(macAddr string) (*profileConnection, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	if !conn.SupportsUnixFDs() {
+		_ = conn.Close()
+		return nil, fmt.Errorf("system bus connection does not support file descriptor passing")
+	}
+
+	devicePath, err := findDevicePath(conn, macAddr)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	// Each client registers its own profile object so several devices can be connected at once
+	path := dbus.ObjectPath(profilePathPrefix + "/dev_" + strings.ReplaceAll(macAddr, ":", "_"))
+	handler := &profileHandler{fds: make(chan int, 1)}
+	if err := conn.Export(handler, path, profileIface); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to export profile: %w", err)
+	}
+
+	options := map[string]dbus.Variant{
+		"Name":                  dbus.MakeVariant("LinuxPods AAP"),
+		"Role":                  dbus.MakeVariant("client"),
+		"PSM":                   dbus.MakeVariant(uint16(AAPPSM)),
+		"RequireAuthentication": dbus.MakeVariant(false),
+		"RequireAuthorization":  dbus.MakeVariant(false),
+		"AutoConnect":           dbus.MakeVariant(false),
+	}
+	manager := conn.Object("org.bluez", "/org/bluez")
+	if err := manager.Call(profileManagerIface+".RegisterProfile", 0, path, AAPServiceUUID, options).Err; err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to register AAP profile: %w", err)
+	}
+
+	pc := &profileConnection{conn: conn, path: path, fd: -1}
+
+	device := conn.Object("org.bluez", devicePath)
+	call := device.Go("org.bluez.Device1.ConnectProfile", 0, nil, AAPServiceUUID)
+
+	select {
+	case fd := <-handler.fds:
+		pc.fd = fd
+		return pc, nil
+	case <-call.Done:
+		if call.Err != nil {
+			handler.giveUp()
+			pc.close()
+			return nil, fmt.Errorf("failed to connect AAP profile: %w", call.Err)
+		}
+		// ConnectProfile may return before NewConnection is delivered
+		select {
+		case fd := <-handler.fds:
+			pc.fd = fd
+			return pc, nil
+		case <-time.After(profileConnectTimeout):
+		}
+	case <-time.After(profileConnectTimeout):
+	}
+
+	handler.giveUp()
+	pc.close()
+	return nil, ErrProfileConnectTimeout
+}
+
+// close unregisters the profile and closes the D-Bus connection.
+// The socket itself is owned and closed by the Client.
+func (pc *profileConnection) close() {
+	manager := pc.conn.Object("org.bluez", "/org/bluez")
+	_ = manager.Call(profileManagerIface+".UnregisterProfile", 0, pc.path).Err
+	_ = pc.conn.Export(nil, pc.path, profileIface)
+	_ = pc.conn.Close()
+}
+
+// findDevicePath looks up the BlueZ object path of the device with the given MAC address
+func findDevicePath(conn *dbus.Conn, macAddr string) (dbus.ObjectPath, error) {
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	obj := conn.Object("org.bluez", "/")
+	if err := obj.Call("org.freedesktop.DBus.ObjectManager.GetManagedObjects", 0).Store(&objects); err != nil {
+		return "", fmt.Errorf("failed to get managed objects: %w", err)
+	}
+
+	for path, interfaces := range objects {
+		deviceProps, ok := interfaces["org.bluez.Device1"]
+		if !ok {
+			continue
+		}
+		if address, ok := deviceProps["Address"].Value().(string); ok && strings.EqualFold(address, macAddr) {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("device %s not known to BlueZ", macAddr)
+}
@@ -0,0 +1,142 @@
+package aap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DeviceSettings is a snapshot of the device settings reported via control commands.
+// Fields are nil while the device hasn't reported the setting.
+//
+// AirPods have no dedicated "dump settings" request. Instead, they report the current
+// value of every setting as a burst of control command notifications right after
+// notifications are requested (see RequestDeviceSettings). Collecting that burst yields
+// the complete settings, so the UI can initialize all controls on connect.
+type DeviceSettings struct {
+	ListeningMode     *NoiseControlMode
+	NoiseControlCycle *NoiseControlCycle
+	MicrophoneMode    *MicrophoneMode
+	EarDetection      *bool
+	ToneVolume        *uint8
+	PressAndHold      *PressAndHold
+	PressSpeed        *PressSpeed
+	HoldDuration      *HoldDuration
+	VolumeSwipe       *bool
+
+	// Raw holds the values of all reported settings, including settings without a parser
+	Raw map[ControlCommandID][4]byte
+}
+
+// RequestDeviceSettings requests notifications for all settings. The device answers with
+// the current value of each setting as a control command packet.
+func RequestDeviceSettings(conn Conn) error {
+	return sendRequest(conn, packetBatteryRequest[:], "settings request")
+}
+
+// ParseNoiseControlMode extracts the current noise control mode from a control command notification
+func ParseNoiseControlMode(cmd *ControlCommand) (NoiseControlMode, error) {
+	if cmd.ID != ControlListeningMode {
+		return NoiseControlUnknown, fmt.Errorf("not a listening mode command: %s", cmd.ID)
+	}
+	mode := NoiseControlMode(cmd.Value[0])
+	if mode < NoiseControlOff || mode > NoiseControlAdaptive {
+		return NoiseControlUnknown, fmt.Errorf("invalid listening mode 0x%02X", cmd.Value[0])
+	}
+	return mode, nil
+}
+
+// Apply updates the settings with a control command notification.
+// Invalid values are only kept in Raw.
+func (s *DeviceSettings) Apply(cmd *ControlCommand) {
+	if s.Raw == nil {
+		s.Raw = make(map[ControlCommandID][4]byte)
+	}
+	s.Raw[cmd.ID] = cmd.Value
+
+	switch cmd.ID {
+	case ControlListeningMode:
+		if mode, err := ParseNoiseControlMode(cmd); err == nil {
+			s.ListeningMode = &mode
+		}
+	case ControlListeningModeConfigs:
+		if cycle, err := ParseNoiseControlCycle(cmd); err == nil {
+			s.NoiseControlCycle = &cycle
+		}
+	case ControlMicrophoneMode:
+		if mode, err := ParseMicrophoneMode(cmd); err == nil {
+			s.MicrophoneMode = &mode
+		}
+	case ControlEarDetection:
+		if enabled, err := ParseEarDetection(cmd); err == nil {
+			s.EarDetection = &enabled
+		}
+	case ControlChimeVolume:
+		if volume, err := ParseToneVolume(cmd); err == nil {
+			s.ToneVolume = &volume
+		}
+	case ControlPressAndHoldMode:
+		if pressAndHold, err := ParsePressAndHold(cmd); err == nil {
+			s.PressAndHold = &pressAndHold
+		}
+	case ControlPressSpeed:
+		if speed, err := ParsePressSpeed(cmd); err == nil {
+			s.PressSpeed = &speed
+		}
+	case ControlHoldDuration:
+		if duration, err := ParseHoldDuration(cmd); err == nil {
+			s.HoldDuration = &duration
+		}
+	case ControlVolumeSwipe:
+		if enabled, err := ParseVolumeSwipe(cmd); err == nil {
+			s.VolumeSwipe = &enabled
+		}
+	}
+}
+
+// ParseDeviceSettings collects the control command packets of a settings dump.
+// Packets that are not control commands are ignored.
+func ParseDeviceSettings(packets [][]byte) *DeviceSettings {
+	settings := &DeviceSettings{Raw: make(map[ControlCommandID][4]byte)}
+	for _, packet := range packets {
+		if !IsControlCommandPacket(packet) {
+			continue
+		}
+		if cmd, err := ParseControlCommand(packet); err == nil {
+			settings.Apply(cmd)
+		}
+	}
+	return settings
+}
+
+func (s *DeviceSettings) String() string {
+	var parts []string
+	if s.ListeningMode != nil {
+		parts = append(parts, fmt.Sprintf("Listening Mode: %s", *s.ListeningMode))
+	}
+	if s.NoiseControlCycle != nil {
+		parts = append(parts, fmt.Sprintf("Cycle: %s", *s.NoiseControlCycle))
+	}
+	if s.MicrophoneMode != nil {
+		parts = append(parts, fmt.Sprintf("Microphone: %s", *s.MicrophoneMode))
+	}
+	if s.EarDetection != nil {
+		parts = append(parts, fmt.Sprintf("Ear Detection: %t", *s.EarDetection))
+	}
+	if s.ToneVolume != nil {
+		parts = append(parts, fmt.Sprintf("Tone Volume: %d%%", *s.ToneVolume))
+	}
+	if s.PressAndHold != nil {
+		parts = append(parts, fmt.Sprintf("Press and Hold: %s", *s.PressAndHold))
+	}
+	if s.PressSpeed != nil {
+		parts = append(parts, fmt.Sprintf("Press Speed: %s", *s.PressSpeed))
+	}
+	if s.HoldDuration != nil {
+		parts = append(parts, fmt.Sprintf("Hold Duration: %s", *s.HoldDuration))
+	}
+	if s.VolumeSwipe != nil {
+		parts = append(parts, fmt.Sprintf("Volume Swipe: %t", *s.VolumeSwipe))
+	}
+	parts = append(parts, fmt.Sprintf("%d settings reported", len(s.Raw)))
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,188 @@
+package aap
+
+import (
+	"fmt"
+)
+
+// PressAndHoldAction is what pressing and holding the stem of a bud does
+type PressAndHoldAction uint8
+
+const (
+	PressAndHoldSiri         PressAndHoldAction = 0x01 // Activate Siri, or the voice assistant of the connected device
+	PressAndHoldNoiseControl PressAndHoldAction = 0x05 // Cycle through the noise control modes (see NoiseControlCycle)
+)
+
+// PressAndHoldActions lists all press and hold actions in display order
+var PressAndHoldActions = []PressAndHoldAction{
+	PressAndHoldNoiseControl,
+	PressAndHoldSiri,
+}
+
+func (a PressAndHoldAction) String() string {
+	switch a {
+	case PressAndHoldSiri:
+		return "Siri"
+	case PressAndHoldNoiseControl:
+		return "Noise Control"
+	default:
+		return fmt.Sprintf("Unknown (0x%02X)", uint8(a))
+	}
+}
+
+// PressAndHold is the press and hold action of each bud
+type PressAndHold struct {
+	Left  PressAndHoldAction
+	Right PressAndHoldAction
+}
+
+func (p PressAndHold) String() string {
+	return fmt.Sprintf("Left: %s, Right: %s", p.Left, p.Right)
+}
+
+// validPressAndHoldAction reports whether a is a known press and hold action
+func validPressAndHoldAction(a PressAndHoldAction) bool {
+	return a == PressAndHoldSiri || a == PressAndHoldNoiseControl
+}
+
+// ParsePressAndHold extracts the press and hold action of each bud from a control command
+// notification. The first value byte is the left bud, the second the right bud.
+func ParsePressAndHold(cmd *ControlCommand) (PressAndHold, error) {
+	if cmd.ID != ControlPressAndHoldMode {
+		return PressAndHold{}, fmt.Errorf("not a press and hold command: %s", cmd.ID)
+	}
+	p := PressAndHold{Left: PressAndHoldAction(cmd.Value[0]), Right: PressAndHoldAction(cmd.Value[1])}
+	if !validPressAndHoldAction(p.Left) || !validPressAndHoldAction(p.Right) {
+		return PressAndHold{}, fmt.Errorf("invalid press and hold actions 0x%02X 0x%02X", cmd.Value[0], cmd.Value[1])
+	}
+	return p, nil
+}
+
+// SetPressAndHold sets what pressing and holding the stem of each bud does
+func SetPressAndHold(conn Conn, p PressAndHold) error {
+	if !validPressAndHoldAction(p.Left) || !validPressAndHoldAction(p.Right) {
+		return fmt.Errorf("invalid press and hold actions %s", p)
+	}
+	return SendControlCommand(conn, ControlPressAndHoldMode, uint8(p.Left), uint8(p.Right))
+}
+
+// PressSpeed is how fast the stem must be pressed twice or three times to count as a
+// double or triple press
+type PressSpeed uint8
+
+const (
+	PressSpeedDefault PressSpeed = 0x00
+	PressSpeedSlower  PressSpeed = 0x01
+	PressSpeedSlowest PressSpeed = 0x02
+)
+
+// PressSpeeds lists all press speeds in display order
+var PressSpeeds = []PressSpeed{PressSpeedDefault, PressSpeedSlower, PressSpeedSlowest}
+
+func (s PressSpeed) String() string {
+	switch s {
+	case PressSpeedDefault:
+		return "Default"
+	case PressSpeedSlower:
+		return "Slower"
+	case PressSpeedSlowest:
+		return "Slowest"
+	default:
+		return fmt.Sprintf("Unknown (0x%02X)", uint8(s))
+	}
+}
+
+// ParsePressSpeed extracts the press speed from a control command notification
+func ParsePressSpeed(cmd *ControlCommand) (PressSpeed, error) {
+	if cmd.ID != ControlPressSpeed {
+		return 0, fmt.Errorf("not a press speed command: %s", cmd.ID)
+	}
+	speed := PressSpeed(cmd.Value[0])
+	if speed > PressSpeedSlowest {
+		return 0, fmt.Errorf("invalid press speed 0x%02X", cmd.Value[0])
+	}
+	return speed, nil
+}
+
+// SetPressSpeed sets how fast the stem must be pressed repeatedly
+func SetPressSpeed(conn Conn, speed PressSpeed) error {
+	if speed > PressSpeedSlowest {
+		return fmt.Errorf("invalid press speed 0x%02X", uint8(speed))
+	}
+	return SendControlCommand(conn, ControlPressSpeed, uint8(speed))
+}
+
+// HoldDuration is how long the stem must be held to count as press and hold
+type HoldDuration uint8
+
+const (
+	HoldDurationDefault  HoldDuration = 0x00
+	HoldDurationShorter  HoldDuration = 0x01
+	HoldDurationShortest HoldDuration = 0x02
+)
+
+// HoldDurations lists all hold durations in display order
+var HoldDurations = []HoldDuration{HoldDurationDefault, HoldDurationShorter, HoldDurationShortest}
+
+func (d HoldDuration) String() string {
+	switch d {
+	case HoldDurationDefault:
+		return "Default"
+	case HoldDurationShorter:
+		return "Shorter"
+	case HoldDurationShortest:
+		return "Shortest"
+	default:
+		return fmt.Sprintf("Unknown (0x%02X)", uint8(d))
+	}
+}
+
+// ParseHoldDuration extracts the hold duration from a control command notification
+func ParseHoldDuration(cmd *ControlCommand) (HoldDuration, error) {
+	if cmd.ID != ControlHoldDuration {
+		return 0, fmt.Errorf("not a hold duration command: %s", cmd.ID)
+	}
+	duration := HoldDuration(cmd.Value[0])
+	if duration > HoldDurationShortest {
+		return 0, fmt.Errorf("invalid hold duration 0x%02X", cmd.Value[0])
+	}
+	return duration, nil
+}
+
+// SetHoldDuration sets how long the stem must be held to count as press and hold
+func SetHoldDuration(conn Conn, duration HoldDuration) error {
+	if duration > HoldDurationShortest {
+		return fmt.Errorf("invalid hold duration 0x%02X", uint8(duration))
+	}
+	return SendControlCommand(conn, ControlHoldDuration, uint8(duration))
+}
+
+// Volume swipe setting values
+const (
+	volumeSwipeEnabled  = 0x01
+	volumeSwipeDisabled = 0x02
+)
+
+// ParseVolumeSwipe extracts whether the volume can be changed by swiping the stem from a
+// control command notification
+func ParseVolumeSwipe(cmd *ControlCommand) (bool, error) {
+	if cmd.ID != ControlVolumeSwipe {
+		return false, fmt.Errorf("not a volume swipe command: %s", cmd.ID)
+	}
+	switch cmd.Value[0] {
+	case volumeSwipeEnabled:
+		return true, nil
+	case volumeSwipeDisabled:
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid volume swipe value 0x%02X", cmd.Value[0])
+	}
+}
+
+// SetVolumeSwipe enables or disables changing the volume by swiping the stem
+func SetVolumeSwipe(conn Conn, enabled bool) error {
+	value := uint8(volumeSwipeDisabled)
+	if enabled {
+		value = volumeSwipeEnabled
+	}
+	return SendControlCommand(conn, ControlVolumeSwipe, value)
+}
@@ -0,0 +1,35 @@
+package aap
+
+import (
+	"fmt"
+)
+
+// Tone volume range in percent of the default system volume
+const (
+	MinToneVolume = 0
+	MaxToneVolume = 100
+)
+
+// toneVolumeSuffix is the second value byte LibrePods sends along with the tone volume
+const toneVolumeSuffix = 0x50
+
+// ParseToneVolume extracts the tone volume from a control command notification
+func ParseToneVolume(cmd *ControlCommand) (uint8, error) {
+	if cmd.ID != ControlChimeVolume {
+		return 0, fmt.Errorf("not a tone volume command: %s", cmd.ID)
+	}
+	volume := cmd.Value[0]
+	if volume > MaxToneVolume {
+		return 0, fmt.Errorf("invalid tone volume %d", volume)
+	}
+	return volume, nil
+}
+
+// SetToneVolume sets the volume of the tones and alerts played by the AirPods,
+// e.g. the connection chime and the noise control mode change tones
+func SetToneVolume(conn Conn, volume uint8) error {
+	if volume > MaxToneVolume {
+		return fmt.Errorf("invalid tone volume %d (must be %d-%d)", volume, MinToneVolume, MaxToneVolume)
+	}
+	return SendControlCommand(conn, ControlChimeVolume, volume, toneVolumeSuffix)
+}
@@ -0,0 +1,150 @@
+package annotator
+
+import (
+	"fmt"
+
+	"linuxpods/internal/aap"
+)
+
+// opcodeNames names the AAP opcodes (byte 4) that LinuxPods sends or understands
+var opcodeNames = map[uint8]string{
+	0x04: "Battery Status",
+	0x06: "Ear Status",
+	0x09: "Control Command",
+	0x0F: "Notification Request",
+	0x1D: "Device Information",
+	0x30: "Proximity Key Request",
+	0x31: "Proximity Keys",
+	0x4D: "Enable Features",
+}
+
+// AAPPacket annotates an AAP packet. The payload of battery, control command, proximity key
+// and ear status packets is decoded, the payload of other packets is annotated as a single
+// unknown field.
+func AAPPacket(packet []byte) []Annotation {
+	opcode, ok := aap.PacketOpcode(packet)
+	if !ok {
+		if len(packet) >= 4 && packet[0] == 0x00 && packet[1] == 0x00 && packet[2] == 0x04 && packet[3] == 0x00 {
+			return []Annotation{{Offset: 0, Length: len(packet), Name: "Handshake"}}
+		}
+		return remainder(packet, 0, "Unknown")
+	}
+
+	name, known := opcodeNames[opcode]
+	if !known {
+		name = "Unknown"
+	}
+	annotations := []Annotation{
+		{Offset: 0, Length: 4, Name: "Header"},
+		{Offset: 4, Length: 1, Name: "Opcode", Value: name},
+		{Offset: 5, Length: 1, Name: "Reserved"},
+	}
+
+	switch {
+	case aap.IsBatteryPacket(packet):
+		annotations = append(annotations, batteryAnnotations(packet)...)
+	case aap.IsControlCommandPacket(packet):
+		annotations = append(annotations, controlCommandAnnotations(packet)...)
+	case aap.IsKeyPacket(packet):
+		annotations = append(annotations, keyAnnotations(packet)...)
+	case aap.IsEarStatusPacket(packet):
+		annotations = append(annotations,
+			Annotation{Offset: 6, Length: 1, Name: "Primary pod", Value: aap.EarStatus(packet[6]).String()},
+			Annotation{Offset: 7, Length: 1, Name: "Secondary pod", Value: aap.EarStatus(packet[7]).String()})
+		annotations = append(annotations, remainder(packet, 8, "Unknown")...)
+	default:
+		annotations = append(annotations, remainder(packet, 6, "Payload")...)
+	}
+	return annotations
+}
+
+// batteryAnnotations annotates the payload of a battery packet
+// Format: 04 00 04 00 04 00 [count] ([component] 01 [level] [status] 01)...
+func batteryAnnotations(packet []byte) []Annotation {
+	count := int(packet[6])
+	annotations := []Annotation{{Offset: 6, Length: 1, Name: "Count", Value: fmt.Sprintf("%d components", count)}}
+
+	offset := 7
+	for i := 0; i < count && offset+5 <= len(packet); i++ {
+		component := aap.BatteryComponent(packet[offset])
+		annotations = append(annotations, Annotation{
+			Offset: offset,
+			Length: 5,
+			Name:   fmt.Sprintf("Battery %d", i+1),
+			Value: fmt.Sprintf("%s: %d%% (%s)", component, packet[offset+2],
+				aap.BatteryStatus(packet[offset+3])),
+		})
+		offset += 5
+	}
+	return append(annotations, remainder(packet, offset, "Unknown")...)
+}
+
+// controlCommandAnnotations annotates the payload of a control command packet
+func controlCommandAnnotations(packet []byte) []Annotation {
+	cmd, err := aap.ParseControlCommand(packet)
+	if err != nil {
+		return remainder(packet, 6, "Payload")
+	}
+
+	annotations := []Annotation{{Offset: 6, Length: 1, Name: "Setting", Value: cmd.ID.String()}}
+	if len(packet) > 7 {
+		length := min(len(packet), 11) - 7
+		annotations = append(annotations, Annotation{Offset: 7, Length: length, Name: "Value", Value: controlValue(cmd)})
+	}
+	return annotations
+}
+
+// controlValue decodes the value of a control command with the parser of its setting
+func controlValue(cmd *aap.ControlCommand) string {
+	settings := &aap.DeviceSettings{}
+	settings.Apply(cmd)
+
+	switch {
+	case settings.ListeningMode != nil:
+		return settings.ListeningMode.String()
+	case settings.NoiseControlCycle != nil:
+		return settings.NoiseControlCycle.String()
+	case settings.MicrophoneMode != nil:
+		return settings.MicrophoneMode.String()
+	case settings.EarDetection != nil:
+		return fmt.Sprintf("Enabled: %t", *settings.EarDetection)
+	case settings.ToneVolume != nil:
+		return fmt.Sprintf("%d%%", *settings.ToneVolume)
+	case settings.PressAndHold != nil:
+		return settings.PressAndHold.String()
+	case settings.PressSpeed != nil:
+		return settings.PressSpeed.String()
+	case settings.HoldDuration != nil:
+		return settings.HoldDuration.String()
+	case settings.VolumeSwipe != nil:
+		return fmt.Sprintf("Enabled: %t", *settings.VolumeSwipe)
+	default:
+		return ""
+	}
+}
+
+// keyAnnotations annotates the payload of a proximity key packet. Key data is not decoded.
+func keyAnnotations(packet []byte) []Annotation {
+	count := int(packet[6])
+	annotations := []Annotation{{Offset: 6, Length: 1, Name: "Key count", Value: fmt.Sprintf("%d keys", count)}}
+
+	offset := 7
+	for i := 0; i < count && offset+4 <= len(packet); i++ {
+		keyType := aap.ProximityKeyType(packet[offset])
+		keyLength := int(packet[offset+2])
+		annotations = append(annotations, Annotation{
+			Offset: offset,
+			Length: 4,
+			Name:   fmt.Sprintf("Key %d header", i+1),
+			Value:  fmt.Sprintf("%s, %d bytes", keyType, keyLength),
+		})
+		offset += 4
+
+		if offset+keyLength > len(packet) {
+			break
+		}
+		annotations = append(annotations, Annotation{Offset: offset, Length: keyLength, Name: keyType.String()})
+		offset += keyLength
+	}
+	return append(annotations, remainder(packet, offset, "Unknown")...)
+}
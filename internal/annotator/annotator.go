@@ -0,0 +1,79 @@
+// Package annotator describes the byte layout of BLE proximity pairing advertisements and
+// AAP packets.
+//
+// Each annotation covers a byte range of a packet and carries its name and decoded value.
+// The decoded values come from the same parsers the coordinator uses, so the field maps shown
+// by the debugging tools (e.g. cmd/debug_decrypt) and the GUI diagnostics tab are always
+// identical and current. Annotations can be rendered as text with Format, or consumed as
+// structured data (e.g. one row per field). DescribeAdvertisement and DescribeAAPPacket
+// summarize the parse result of a whole packet.
+package annotator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Annotation describes a field of a packet
+type Annotation struct {
+	Offset  int      // Offset of the first byte of the field
+	Length  int      // Number of bytes of the field
+	Name    string   // Field name, e.g. "Status"
+	Value   string   // Decoded value, empty if the meaning of the field is unknown
+	Details []string // Decoded sub-fields, e.g. individual bits
+}
+
+// Label returns the byte range and name of the field, e.g. "Bytes 1-2 (Model)"
+func (a Annotation) Label() string {
+	if a.Length == 1 {
+		return fmt.Sprintf("Byte %d (%s)", a.Offset, a.Name)
+	}
+	return fmt.Sprintf("Bytes %d-%d (%s)", a.Offset, a.Offset+a.Length-1, a.Name)
+}
+
+// Raw returns the raw bytes of the field from the annotated packet as hex.
+// Single bytes also show their bits.
+func (a Annotation) Raw(packet []byte) string {
+	if a.Offset+a.Length > len(packet) {
+		return ""
+	}
+	raw := packet[a.Offset : a.Offset+a.Length]
+	if len(raw) == 1 {
+		return fmt.Sprintf("0x%02X (%08b)", raw[0], raw[0])
+	}
+	return hex.EncodeToString(raw)
+}
+
+// Format renders the annotations of a packet as text, one field per line followed by its details:
+//
+//	Byte 3 (Status):       0x0B (00001011)  Left pod primary
+//	  Bit 5 (primary):     true
+func Format(packet []byte, annotations []Annotation) string {
+	width := 0
+	for _, a := range annotations {
+		width = max(width, len(a.Label())+1)
+	}
+
+	var b strings.Builder
+	for _, a := range annotations {
+		line := fmt.Sprintf("%-*s %s", width, a.Label()+":", a.Raw(packet))
+		if a.Value != "" {
+			line += "  " + a.Value
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+		for _, detail := range a.Details {
+			b.WriteString("  " + detail + "\n")
+		}
+	}
+	return b.String()
+}
+
+// remainder annotates the bytes following offset as a single field of unknown meaning
+func remainder(packet []byte, offset int, name string) []Annotation {
+	if offset >= len(packet) {
+		return nil
+	}
+	return []Annotation{{Offset: offset, Length: len(packet) - offset, Name: name}}
+}
@@ -0,0 +1,116 @@
+package annotator
+
+import (
+	"fmt"
+
+	"linuxpods/internal/ble"
+)
+
+// ProximityPayload annotates a proximity pairing payload (ble.ProximityData.RawData, starting
+// with the 0x01 prefix). Bytes 9-24 are annotated as a single encrypted field, use
+// DecryptedPayload for their decrypted content.
+func ProximityPayload(payload []byte) []Annotation {
+	pd, err := ble.ParseProximityData(append([]byte{0x07, byte(len(payload))}, payload...))
+	if err != nil {
+		return []Annotation{{Offset: 0, Length: len(payload), Name: "Payload", Value: err.Error()}}
+	}
+
+	primary := "Left pod primary"
+	if pd.IsFlipped {
+		primary = "Right pod primary"
+	}
+
+	annotations := []Annotation{
+		{Offset: 0, Length: 1, Name: "Prefix"},
+		{Offset: 1, Length: 2, Name: "Model", Value: ble.DecodeModelName(pd.DeviceModel)},
+		{Offset: 3, Length: 1, Name: "Status", Value: primary, Details: []string{
+			fmt.Sprintf("Bit 5 (primary):   %v (left pod is primary: %v)", !pd.IsFlipped, !pd.IsFlipped),
+			fmt.Sprintf("Bit 6 (in case):   %v", (pd.Status>>6)&0x01 != 0),
+			fmt.Sprintf("Left in ear:       %v", pd.LeftInEar),
+			fmt.Sprintf("Right in ear:      %v", pd.RightInEar),
+			fmt.Sprintf("Left in case:      %v", pd.LeftInCase),
+			fmt.Sprintf("Right in case:     %v", pd.RightInCase),
+		}},
+		{Offset: 4, Length: 1, Name: "Battery", Value: fmt.Sprintf("Left %s, Right %s",
+			formatLevel(pd.LeftBattery), formatLevel(pd.RightBattery)), Details: []string{
+			fmt.Sprintf("Nibbles: high=0x%X, low=0x%X (swapped if the right pod is primary)",
+				payload[4]>>4, payload[4]&0x0F),
+		}},
+		{Offset: 5, Length: 1, Name: "Charging", Value: "Case " + formatLevel(pd.CaseBattery), Details: []string{
+			fmt.Sprintf("Case charging:     %v", pd.CaseCharging),
+			fmt.Sprintf("Left charging:     %v", pd.LeftCharging),
+			fmt.Sprintf("Right charging:    %v", pd.RightCharging),
+		}},
+	}
+	if pd.Format == ble.PayloadShort {
+		// Older firmware doesn't send the lid counter, color and lid state
+		return append(annotations, remainder(payload, 6, "Unknown")...)
+	}
+
+	annotations = append(annotations,
+		Annotation{Offset: 6, Length: 1, Name: "Lid counter", Value: fmt.Sprintf("%d", pd.LidOpenCount), Details: []string{
+			fmt.Sprintf("Unknown bits:      0x%02X", payload[6]&^ble.LidOpenCountMask),
+		}},
+		Annotation{Offset: 7, Length: 1, Name: "Color", Value: ble.DecodeColor(pd.Color)},
+		Annotation{Offset: 8, Length: 1, Name: "Lid", Value: lidState(pd.LidOpen)},
+	)
+	if len(payload) >= 25 {
+		annotations = append(annotations, Annotation{Offset: 9, Length: 16, Name: "Encrypted"})
+		annotations = append(annotations, remainder(payload, 25, "Unknown")...)
+	} else {
+		annotations = append(annotations, remainder(payload, 9, "Unknown")...)
+	}
+	return annotations
+}
+
+// DecryptedPayload annotates the 16 decrypted bytes of a proximity pairing payload.
+// flipped is ble.ProximityData.IsFlipped, which determines which pod the battery bytes belong to.
+func DecryptedPayload(decrypted []byte, flipped bool) []Annotation {
+	if len(decrypted) != 16 {
+		return []Annotation{{Offset: 0, Length: len(decrypted), Name: "Decrypted",
+			Value: fmt.Sprintf("expected 16 bytes, got %d", len(decrypted))}}
+	}
+
+	first, second := "Left", "Right"
+	if flipped {
+		first, second = second, first
+	}
+
+	return []Annotation{
+		{Offset: 0, Length: 1, Name: "Unknown", Value: "Upper nibble is 0 if decrypted correctly"},
+		{Offset: 1, Length: 1, Name: "First pod battery", Value: formatBatteryByte(first, decrypted[1])},
+		{Offset: 2, Length: 1, Name: "Second pod battery", Value: formatBatteryByte(second, decrypted[2])},
+		{Offset: 3, Length: 1, Name: "Case battery", Value: formatBatteryByte("Case", decrypted[3])},
+		{Offset: 4, Length: 1, Name: "Validation", Value: "0x2D if decrypted correctly"},
+		{Offset: 5, Length: 11, Name: "Unknown"},
+	}
+}
+
+// formatBatteryByte decodes a decrypted battery byte (bit 7 = charging, bits 0-6 = level)
+func formatBatteryByte(component string, b byte) string {
+	level := b & 0x7F
+	if level > 100 {
+		return component + ": unavailable"
+	}
+	value := fmt.Sprintf("%s: %d%%", component, level)
+	if b&0x80 != 0 {
+		value += " (Charging)"
+	}
+	return value
+}
+
+// formatLevel formats an optional battery level
+func formatLevel(level *uint8) string {
+	if level == nil {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d%%", *level)
+}
+
+// lidState formats the lid state
+func lidState(open bool) string {
+	if open {
+		return "Open"
+	}
+	return "Closed"
+}
@@ -0,0 +1,62 @@
+package annotator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/ble"
+)
+
+// DescribeAdvertisement returns the parse result of an Apple manufacturer data frame
+// (as recorded by ble_record), or the parse error
+func DescribeAdvertisement(frame []byte) string {
+	data, err := ble.ParseProximityData(frame)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return data.String()
+}
+
+// DescribeAAPPacket returns the parse result of an AAP packet, or the parse error
+func DescribeAAPPacket(packet []byte) string {
+	switch {
+	case aap.IsBatteryPacket(packet):
+		info, err := aap.ParseBatteryPacket(packet)
+		if err != nil {
+			return "battery error: " + err.Error()
+		}
+		return info.String()
+	case aap.IsKeyPacket(packet):
+		keys, err := aap.ParseProximityKeys(packet)
+		if err != nil {
+			return "keys error: " + err.Error()
+		}
+		var lines []string
+		for _, key := range keys {
+			lines = append(lines, fmt.Sprintf("%s: %s", key.Type, hex.EncodeToString(key.Data)))
+		}
+		return strings.Join(lines, "\n")
+	case aap.IsControlCommandPacket(packet):
+		cmd, err := aap.ParseControlCommand(packet)
+		if err != nil {
+			return "control error: " + err.Error()
+		}
+		return fmt.Sprintf("%s = % X", cmd.ID, cmd.Value)
+	case aap.IsEarStatusPacket(packet):
+		info, err := aap.ParseEarStatusPacket(packet)
+		if err != nil {
+			return "ear status error: " + err.Error()
+		}
+		return info.String()
+	case aap.IsDeviceInfoPacket(packet):
+		info, err := aap.ParseDeviceInfoPacket(packet)
+		if err != nil {
+			return "device information error: " + err.Error()
+		}
+		return info.String()
+	default:
+		return "unknown packet"
+	}
+}
@@ -0,0 +1,309 @@
+package audio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"linuxpods/internal/config"
+	"linuxpods/internal/podstate"
+	"linuxpods/internal/retry"
+	"linuxpods/internal/util"
+)
+
+// volumeCheckInterval is how often the volume of a connected device's output is read, to
+// remember it for the next connection (the output is gone once the device disconnected)
+const volumeCheckInterval = 15 * time.Second
+
+// sinkBackoff waits for the output of a device, which PipeWire creates a few seconds after
+// the device connected, once the audio profile is set up
+var sinkBackoff = retry.Backoff{
+	Initial:     500 * time.Millisecond,
+	Max:         3 * time.Second,
+	Multiplier:  2,
+	Jitter:      0.2,
+	MaxAttempts: 8,
+}
+
+// DefaultVolumesPath returns the file the volumes of the devices are kept in:
+// $XDG_DATA_HOME/linuxpods/volumes.json (~/.local/share/linuxpods/volumes.json)
+func DefaultVolumesPath() (string, error) {
+	dir, err := util.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "volumes.json"), nil
+}
+
+// output is the audio output of a connected device
+type output struct {
+	previous string             // Default sink before switching to the device, empty if not switched
+	cancel   context.CancelFunc // Stops following the device (connecting or volume checks)
+}
+
+// OutputSwitcher makes the audio output of a device the default when it connects via AAP and
+// restores the previous default when it disconnects. It remembers the volume of each device
+// and sets it again when the device connects.
+type OutputSwitcher struct {
+	volumesPath string // Empty to keep the volumes in memory only
+
+	mu      sync.Mutex
+	config  config.AudioConfig
+	outputs map[string]*output // MAC address -> output of a connected device
+	volumes map[string]int     // MAC address -> last volume in percent
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewOutputSwitcher creates an output switcher for the events of backend. The volumes are
+// loaded from and saved to volumesPath, unless it is empty.
+func NewOutputSwitcher(backend podstate.Backend, cfg config.AudioConfig, volumesPath string) *OutputSwitcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &OutputSwitcher{
+		volumesPath: volumesPath,
+		config:      cfg,
+		outputs:     make(map[string]*output),
+		volumes:     make(map[string]int),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	if volumesPath != "" {
+		if err := s.loadVolumes(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	backend.Subscribe(func(event podstate.Event) {
+		switch e := event.(type) {
+		case podstate.DeviceConnected:
+			s.deviceConnected(e.Address)
+		case podstate.DeviceDisconnected:
+			s.deviceDisconnected(e.Address)
+		case podstate.DeviceSuspended:
+			// The pods in the closed case play nothing
+			s.deviceDisconnected(e.Address)
+		}
+	})
+	return s
+}
+
+// SetConfig changes the settings, e.g. after the config file was reloaded. They apply from
+// the next connection.
+func (s *OutputSwitcher) SetConfig(cfg config.AudioConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = cfg
+}
+
+// Close stops following the connected devices. The default output is left as it is.
+func (s *OutputSwitcher) Close() error {
+	// Canceled under the lock, so that no goroutines are started while waiting for them
+	s.mu.Lock()
+	s.cancel()
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// deviceConnected starts waiting for the output of a device in the background
+func (s *OutputSwitcher) deviceConnected(macAddr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.outputs[macAddr]; ok || s.ctx.Err() != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	out := &output{cancel: cancel}
+	s.outputs[macAddr] = out
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.follow(ctx, macAddr, out)
+	}()
+}
+
+// follow switches to the output of a device once it appears and keeps track of its volume
+// until the device disconnects
+func (s *OutputSwitcher) follow(ctx context.Context, macAddr string, out *output) {
+	var sink Sink
+	err := retry.Do(ctx, sinkBackoff, func(ctx context.Context) error {
+		found, ok, err := FindSink(macAddr)
+		if err != nil {
+			return retry.Permanent(err) // pactl missing or no sound server
+		}
+		if !ok {
+			return fmt.Errorf("no audio output of %s", macAddr)
+		}
+		sink = found
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Audio output not switched: %v", err)
+		}
+		return
+	}
+
+	s.mu.Lock()
+	cfg := s.config
+	volume, knownVolume := s.volumes[macAddr]
+	s.mu.Unlock()
+
+	if cfg.RememberVolume && knownVolume && volume != sink.Volume {
+		if err := SetSinkVolume(sink.Name, volume); err != nil {
+			log.Printf("Warning: Failed to restore the volume of %s: %v", macAddr, err)
+		} else {
+			sink.Volume = volume
+		}
+	}
+
+	previous := ""
+	if cfg.SwitchOutput {
+		current, err := DefaultSink()
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		} else if current != sink.Name {
+			if err := SetDefaultSink(sink.Name); err != nil {
+				log.Printf("Warning: Failed to switch the audio output to %s: %v", macAddr, err)
+			} else {
+				log.Printf("Audio output switched to %s (%s), previously %s", sink.Description, macAddr, current)
+				previous = current
+			}
+		}
+	}
+
+	s.mu.Lock()
+	out.previous = previous
+	s.mu.Unlock()
+	s.rememberVolume(macAddr, sink.Volume)
+
+	ticker := time.NewTicker(volumeCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if current, ok, err := FindSink(macAddr); err == nil && ok {
+			s.rememberVolume(macAddr, current.Volume)
+		}
+	}
+}
+
+// deviceDisconnected stops following a device and restores the previous default output
+func (s *OutputSwitcher) deviceDisconnected(macAddr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out, ok := s.outputs[macAddr]
+	if !ok {
+		return
+	}
+	delete(s.outputs, macAddr)
+	out.cancel()
+
+	if out.previous == "" || s.ctx.Err() != nil {
+		return
+	}
+	previous := out.previous
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		restoreDefault(previous)
+	}()
+}
+
+// restoreDefault makes previous the default output again, unless it is gone meanwhile
+func restoreDefault(previous string) {
+	current, err := DefaultSink()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	if current == previous {
+		return
+	}
+	sinks, err := Sinks()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	for _, sink := range sinks {
+		if sink.Name == previous {
+			if err := SetDefaultSink(previous); err != nil {
+				log.Printf("Warning: Failed to restore the audio output: %v", err)
+				return
+			}
+			log.Printf("Audio output restored to %s", sink.Description)
+			return
+		}
+	}
+}
+
+// rememberVolume stores the volume of a device if it changed
+func (s *OutputSwitcher) rememberVolume(macAddr string, volume int) {
+	s.mu.Lock()
+	if previous, ok := s.volumes[macAddr]; ok && previous == volume {
+		s.mu.Unlock()
+		return
+	}
+	s.volumes[macAddr] = volume
+	s.mu.Unlock()
+
+	if s.volumesPath != "" {
+		if err := s.saveVolumes(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// loadVolumes reads the volumes file. A missing file is not an error.
+func (s *OutputSwitcher) loadVolumes() error {
+	data, err := os.ReadFile(s.volumesPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read volumes: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := json.Unmarshal(data, &s.volumes); err != nil {
+		return fmt.Errorf("invalid volumes file %s: %w", s.volumesPath, err)
+	}
+	return nil
+}
+
+// saveVolumes writes the volumes file
+func (s *OutputSwitcher) saveVolumes() error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s.volumes, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode volumes: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.volumesPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmpPath := s.volumesPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save volumes: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.volumesPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to save volumes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+// Package audio moves the default audio output to the AirPods when they connect and back
+// when they disconnect (see OutputSwitcher), and follows their Bluetooth profile while
+// applications use the microphone (see ProfileMonitor). It controls PipeWire (via
+// pipewire-pulse) or PulseAudio with pactl, which both provide, as PipeWire has no D-Bus API.
+package audio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sink is an audio output
+type Sink struct {
+	Name        string
+	Description string
+	Address     string // MAC address of Bluetooth sinks (uppercase), empty otherwise
+	Volume      int    // Percent, averaged over the channels
+}
+
+// pactlSink is a sink as listed by pactl -f json
+type pactlSink struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Properties  map[string]string `json:"properties"`
+	Volume      map[string]struct {
+		ValuePercent string `json:"value_percent"`
+	} `json:"volume"`
+}
+
+// pactl runs pactl with the given arguments and returns its output
+func pactl(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("pactl", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("pactl %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Sinks returns all audio outputs
+func Sinks() ([]Sink, error) {
+	out, err := pactl("-f", "json", "list", "sinks")
+	if err != nil {
+		return nil, err
+	}
+	var listed []pactlSink
+	if err := json.Unmarshal(out, &listed); err != nil {
+		return nil, fmt.Errorf("failed to parse pactl output: %w", err)
+	}
+
+	sinks := make([]Sink, 0, len(listed))
+	for _, s := range listed {
+		sinks = append(sinks, Sink{
+			Name:        s.Name,
+			Description: s.Description,
+			Address:     bluetoothAddress(s.Properties),
+			Volume:      averageVolume(s),
+		})
+	}
+	return sinks, nil
+}
+
+// averageVolume returns the average volume of a sink's channels in percent
+func averageVolume(s pactlSink) int {
+	total, channels := 0, 0
+	for _, channel := range s.Volume {
+		percent, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(channel.ValuePercent), "%"))
+		if err != nil {
+			continue
+		}
+		total += percent
+		channels++
+	}
+	if channels == 0 {
+		return 0
+	}
+	return total / channels
+}
+
+// FindSink returns the audio output of a Bluetooth device
+func FindSink(macAddr string) (Sink, bool, error) {
+	sinks, err := Sinks()
+	if err != nil {
+		return Sink{}, false, err
+	}
+	for _, sink := range sinks {
+		if sink.Address == strings.ToUpper(macAddr) {
+			return sink, true, nil
+		}
+	}
+	return Sink{}, false, nil
+}
+
+// DefaultSink returns the name of the default audio output
+func DefaultSink() (string, error) {
+	out, err := pactl("get-default-sink")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// SetDefaultSink makes an audio output the default, moving the playing streams to it
+func SetDefaultSink(name string) error {
+	_, err := pactl("set-default-sink", name)
+	return err
+}
+
+// SetSinkVolume sets the volume of all channels of an audio output in percent
+func SetSinkVolume(name string, percent int) error {
+	_, err := pactl("set-sink-volume", name, strconv.Itoa(percent)+"%")
+	return err
+}
+
+// Card is a sound card, for Bluetooth devices the set of their profiles
+type Card struct {
+	Name          string
+	Address       string   // MAC address of Bluetooth cards (uppercase), empty otherwise
+	ActiveProfile string   // e.g. a2dp-sink or headset-head-unit
+	Profiles      []string // Available profiles
+}
+
+// pactlCard is a card as listed by pactl -f json
+type pactlCard struct {
+	Name          string            `json:"name"`
+	Properties    map[string]string `json:"properties"`
+	ActiveProfile string            `json:"active_profile"`
+	Profiles      map[string]struct {
+		Available bool `json:"available"`
+	} `json:"profiles"`
+}
+
+// BluetoothCards returns the sound cards of Bluetooth devices
+func BluetoothCards() ([]Card, error) {
+	out, err := pactl("-f", "json", "list", "cards")
+	if err != nil {
+		return nil, err
+	}
+	var listed []pactlCard
+	if err := json.Unmarshal(out, &listed); err != nil {
+		return nil, fmt.Errorf("failed to parse pactl output: %w", err)
+	}
+
+	var cards []Card
+	for _, c := range listed {
+		address := bluetoothAddress(c.Properties)
+		if address == "" {
+			continue
+		}
+		card := Card{Name: c.Name, Address: address, ActiveProfile: c.ActiveProfile}
+		for name, profile := range c.Profiles {
+			if profile.Available {
+				card.Profiles = append(card.Profiles, name)
+			}
+		}
+		sort.Strings(card.Profiles)
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// bluetoothAddress returns the uppercase MAC address of a Bluetooth sink or card from its
+// properties, empty if it isn't a Bluetooth device. PipeWire sets api.bluez5.address,
+// PulseAudio device.string.
+func bluetoothAddress(properties map[string]string) string {
+	if address := properties["api.bluez5.address"]; address != "" {
+		return strings.ToUpper(address)
+	}
+	if properties["device.bus"] == "bluetooth" {
+		return strings.ToUpper(properties["device.string"])
+	}
+	return ""
+}
+
+// SetCardProfile switches the profile of a sound card
+func SetCardProfile(card string, profile string) error {
+	_, err := pactl("set-card-profile", card, profile)
+	return err
+}
+
+// pactlSourceOutput is a recording stream as listed by pactl -f json
+type pactlSourceOutput struct {
+	Source     int               `json:"source"`
+	Properties map[string]string `json:"properties"`
+}
+
+// pactlSource is a source as listed by pactl -f json
+type pactlSource struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+}
+
+// RecordingApplications returns the names of the applications recording from a microphone.
+// Streams recording the output of a sink (monitors, e.g. for visualizers) are not included.
+func RecordingApplications() ([]string, error) {
+	out, err := pactl("-f", "json", "list", "sources")
+	if err != nil {
+		return nil, err
+	}
+	var sources []pactlSource
+	if err := json.Unmarshal(out, &sources); err != nil {
+		return nil, fmt.Errorf("failed to parse pactl output: %w", err)
+	}
+	monitors := make(map[int]bool)
+	for _, source := range sources {
+		monitors[source.Index] = strings.HasSuffix(source.Name, ".monitor")
+	}
+
+	if out, err = pactl("-f", "json", "list", "source-outputs"); err != nil {
+		return nil, err
+	}
+	var outputs []pactlSourceOutput
+	if err := json.Unmarshal(out, &outputs); err != nil {
+		return nil, fmt.Errorf("failed to parse pactl output: %w", err)
+	}
+	var applications []string
+	for _, output := range outputs {
+		if monitors[output.Source] {
+			continue
+		}
+		applications = append(applications, output.Properties["application.name"])
+	}
+	return applications, nil
+}
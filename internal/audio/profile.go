@@ -0,0 +1,238 @@
+package audio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"linuxpods/internal/config"
+	"linuxpods/internal/podstate"
+)
+
+const (
+	// profileCheckDelay collects the bursts of sound server events (e.g. a new stream and
+	// the resulting card change) into one check
+	profileCheckDelay = 500 * time.Millisecond
+
+	// subscribeRestartDelay is the time before following the sound server again after
+	// pactl subscribe ended, e.g. because the sound server restarted
+	subscribeRestartDelay = 5 * time.Second
+)
+
+// ProfileMonitor reports the Bluetooth audio profile of the devices and whether an application
+// records from a microphone to the coordinator (podstate.AudioProfileChanged). Optionally it
+// switches the devices to the headset profile while a microphone is used, and back to the
+// previous profile afterwards.
+type ProfileMonitor struct {
+	coord *podstate.PodStateCoordinator
+
+	mu       sync.Mutex
+	config   config.AudioConfig
+	switched map[string]string // Card name -> profile before switching to the headset profile
+
+	recheck chan struct{} // Requests a check, e.g. when a device connected
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewProfileMonitor starts following the sound server for the devices of coord
+func NewProfileMonitor(coord *podstate.PodStateCoordinator, cfg config.AudioConfig) *ProfileMonitor {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &ProfileMonitor{
+		coord:    coord,
+		config:   cfg,
+		switched: make(map[string]string),
+		recheck:  make(chan struct{}, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	// The device may have no state yet when its card appears
+	coord.Subscribe(func(event podstate.Event) {
+		if _, ok := event.(podstate.DeviceConnected); ok {
+			select {
+			case p.recheck <- struct{}{}:
+			default:
+			}
+		}
+	})
+	go p.run()
+	return p
+}
+
+// SetConfig changes the settings, e.g. after the config file was reloaded
+func (p *ProfileMonitor) SetConfig(cfg config.AudioConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config = cfg
+}
+
+// Close stops following the sound server. Switched profiles are left as they are.
+func (p *ProfileMonitor) Close() error {
+	p.cancel()
+	<-p.done
+	return nil
+}
+
+// run follows the sound server until the monitor is closed
+func (p *ProfileMonitor) run() {
+	defer close(p.done)
+	for {
+		if err := p.watch(); err != nil && p.ctx.Err() == nil {
+			log.Printf("Warning: Audio profiles not followed: %v", err)
+		}
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(subscribeRestartDelay):
+		}
+	}
+}
+
+// watch checks the profiles whenever streams or cards of the sound server change, until
+// pactl subscribe ends
+func (p *ProfileMonitor) watch() error {
+	cmd := exec.CommandContext(p.ctx, "pactl", "subscribe")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("pactl subscribe failed: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("pactl subscribe failed: %w", err)
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	p.check()
+	check := time.NewTimer(profileCheckDelay)
+	check.Stop()
+	defer check.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return cmd.Wait()
+			}
+			// e.g. "Event 'new' on source-output #57" or "Event 'change' on card #44"
+			if strings.Contains(line, "source-output") || strings.Contains(line, "card") {
+				check.Reset(profileCheckDelay)
+			}
+		case <-p.recheck:
+			check.Reset(profileCheckDelay)
+		case <-check.C:
+			p.check()
+		}
+	}
+}
+
+// check reports the profiles of the known devices and switches them if configured
+func (p *ProfileMonitor) check() {
+	cards, err := BluetoothCards()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	applications, err := RecordingApplications()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	microphoneInUse := len(applications) > 0
+
+	states := p.coord.GetDeviceStates()
+	present := make(map[string]bool, len(cards))
+	for _, card := range cards {
+		present[card.Name] = true
+		if _, known := states[card.Address]; !known {
+			continue
+		}
+		active := p.switchProfile(card, microphoneInUse, applications)
+		p.coord.SetAudioState(card.Address, ParseProfile(active), microphoneInUse)
+	}
+
+	// Forget the cards of disconnected devices
+	p.mu.Lock()
+	for name := range p.switched {
+		if !present[name] {
+			delete(p.switched, name)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// switchProfile switches a card to the headset profile while a microphone is used and back
+// afterwards, if configured. It returns the active profile of the card.
+func (p *ProfileMonitor) switchProfile(card Card, microphoneInUse bool, applications []string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	previous, switched := p.switched[card.Name]
+
+	switch {
+	case microphoneInUse && !switched && p.config.SwitchProfile && ParseProfile(card.ActiveProfile) == podstate.AudioProfileA2DP:
+		headset := headsetProfile(card.Profiles)
+		if headset == "" {
+			return card.ActiveProfile
+		}
+		if err := SetCardProfile(card.Name, headset); err != nil {
+			log.Printf("Warning: Failed to switch %s to the headset profile: %v", card.Address, err)
+			return card.ActiveProfile
+		}
+		log.Printf("Switched %s to the headset profile for the microphone (%s)", card.Address, strings.Join(applications, ", "))
+		p.switched[card.Name] = card.ActiveProfile
+		return headset
+	case !microphoneInUse && switched:
+		delete(p.switched, card.Name)
+		// Keep a profile the user selected meanwhile
+		if ParseProfile(card.ActiveProfile) != podstate.AudioProfileHeadset {
+			return card.ActiveProfile
+		}
+		if err := SetCardProfile(card.Name, previous); err != nil {
+			log.Printf("Warning: Failed to restore the profile of %s: %v", card.Address, err)
+			return card.ActiveProfile
+		}
+		log.Printf("Restored the %s profile of %s", previous, card.Address)
+		return previous
+	}
+	return card.ActiveProfile
+}
+
+// ParseProfile returns the kind of a card profile name of PipeWire (e.g. a2dp-sink,
+// headset-head-unit) or PulseAudio (e.g. a2dp_sink, handsfree_head_unit)
+func ParseProfile(name string) podstate.AudioProfile {
+	switch {
+	case name == "":
+		return podstate.AudioProfileUnknown
+	case name == "off":
+		return podstate.AudioProfileOff
+	case strings.Contains(name, "a2dp"):
+		return podstate.AudioProfileA2DP
+	case strings.Contains(name, "head-unit") || strings.Contains(name, "head_unit"):
+		return podstate.AudioProfileHeadset
+	default:
+		return podstate.AudioProfileUnknown
+	}
+}
+
+// headsetProfile returns the first headset profile of a card's profiles, empty if there is none
+func headsetProfile(profiles []string) string {
+	for _, profile := range profiles {
+		if ParseProfile(profile) == podstate.AudioProfileHeadset {
+			return profile
+		}
+	}
+	return ""
+}
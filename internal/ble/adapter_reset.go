@@ -0,0 +1,85 @@
+package ble
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linuxpods/internal/retry"
+)
+
+// restartBackoff retries restarting discovery while the adapter is coming back up
+var restartBackoff = retry.Backoff{
+	Initial:     500 * time.Millisecond,
+	Max:         10 * time.Second,
+	Multiplier:  2,
+	Jitter:      0.2,
+	MaxAttempts: 8,
+}
+
+// isAdapterReset reports whether the signal means that discovery was lost and has to be
+// started again: the adapter was powered on (Bluetooth toggled, hci0 reset) or BlueZ
+// itself (re)started.
+func isAdapterReset(signal *dbus.Signal, adapterPath dbus.ObjectPath) bool {
+	switch signal.Name {
+	case "org.freedesktop.DBus.Properties.PropertiesChanged":
+		if signal.Path != adapterPath || len(signal.Body) < 2 {
+			return false
+		}
+		iface, ok := signal.Body[0].(string)
+		if !ok || iface != "org.bluez.Adapter1" {
+			return false
+		}
+		changes, ok := signal.Body[1].(map[string]dbus.Variant)
+		if !ok {
+			return false
+		}
+		powered, ok := changes["Powered"].Value().(bool)
+		return ok && powered
+
+	case "org.freedesktop.DBus.NameOwnerChanged":
+		// Body: name, old owner, new owner. An empty new owner means BlueZ stopped.
+		if len(signal.Body) < 3 {
+			return false
+		}
+		name, _ := signal.Body[0].(string)
+		newOwner, _ := signal.Body[2].(string)
+		return name == bluezService && newOwner != ""
+	}
+	return false
+}
+
+// restartDiscovery starts discovery again after an adapter reset, retrying until the
+// adapter accepts it. It does nothing if discovery was stopped or paused (see Cadence) in
+// the meantime.
+func (s *Scanner) restartDiscovery() {
+	s.restartMu.Lock()
+	defer s.restartMu.Unlock()
+
+	err := retry.Do(s.ctx, restartBackoff, func(ctx context.Context) error {
+		s.mu.Lock()
+		discovering := s.discovering && !s.paused
+		s.mu.Unlock()
+		if !discovering {
+			return nil
+		}
+
+		err := s.startDiscovery()
+		var dbusErr dbus.Error
+		if errors.As(err, &dbusErr) && dbusErr.Name == "org.bluez.Error.InProgress" {
+			// Discovery is already running
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		if s.ctx.Err() == nil {
+			log.Printf("Failed to restart BLE discovery after adapter reset: %v", err)
+		}
+		return
+	}
+	log.Printf("BLE discovery restarted after adapter reset")
+}
@@ -0,0 +1,137 @@
+package ble
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Cadence is how the scanner alternates between discovering and pausing. Discovery keeps the
+// adapter's radio listening, pausing it saves power when fresh data isn't needed.
+type Cadence struct {
+	Scan  time.Duration // How long discovery runs before pausing
+	Pause time.Duration // How long discovery pauses, zero for continuous discovery
+	Off   bool          // No discovery at all
+}
+
+// ContinuousCadence keeps discovery running, the default
+var ContinuousCadence = Cadence{}
+
+// continuous reports whether discovery never pauses
+func (c Cadence) continuous() bool {
+	return !c.Off && (c.Pause <= 0 || c.Scan <= 0)
+}
+
+func (c Cadence) String() string {
+	switch {
+	case c.Off:
+		return "off"
+	case c.continuous():
+		return "continuous"
+	default:
+		return c.Scan.String() + " every " + (c.Scan + c.Pause).String()
+	}
+}
+
+// SetCadence changes how discovery alternates between scanning and pausing
+// (ContinuousCadence by default). It applies once discovery was started.
+func (s *Scanner) SetCadence(cadence Cadence) {
+	s.mu.Lock()
+	changed := s.cadence != cadence
+	s.cadence = cadence
+	s.mu.Unlock()
+
+	if changed {
+		log.Printf("BLE: Scan cadence %s", cadence)
+		select {
+		case s.cadenceChanged <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// cadenceLoop starts and stops discovery following the cadence until the scanner is closed
+func (s *Scanner) cadenceLoop() {
+	for {
+		s.mu.Lock()
+		cadence := s.cadence
+		s.mu.Unlock()
+
+		switch {
+		case cadence.Off:
+			s.setPaused(true)
+			if !s.waitCadence(0) {
+				return
+			}
+		case cadence.continuous():
+			s.setPaused(false)
+			if !s.waitCadence(0) {
+				return
+			}
+		default:
+			s.setPaused(false)
+			if !s.waitCadence(cadence.Scan) {
+				return
+			}
+			s.mu.Lock()
+			unchanged := s.cadence == cadence
+			s.mu.Unlock()
+			if !unchanged {
+				continue
+			}
+			s.setPaused(true)
+			if !s.waitCadence(cadence.Pause) {
+				return
+			}
+		}
+	}
+}
+
+// waitCadence waits for d (forever if zero) or a cadence change. It reports false once
+// the scanner is closed.
+func (s *Scanner) waitCadence(d time.Duration) bool {
+	var timeout <-chan time.Time
+	if d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-s.ctx.Done():
+		return false
+	case <-s.cadenceChanged:
+	case <-timeout:
+	}
+	return true
+}
+
+// setPaused stops or resumes discovery for the cadence. Errors are only logged, e.g. while
+// the adapter is powered off discovery can't be started; it is restarted when the adapter
+// comes back (see restartDiscovery).
+func (s *Scanner) setPaused(paused bool) {
+	s.mu.Lock()
+	if s.paused == paused || !s.discovering {
+		s.paused = paused
+		s.mu.Unlock()
+		return
+	}
+	s.paused = paused
+	s.mu.Unlock()
+
+	obj := s.conn.Object(bluezService, s.adapterPath)
+	var err error
+	if paused {
+		err = obj.Call("org.bluez.Adapter1.StopDiscovery", 0).Err
+	} else {
+		err = s.startDiscovery()
+	}
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) && (dbusErr.Name == "org.bluez.Error.InProgress" || dbusErr.Name == "org.bluez.Error.NotReady") {
+		return // Already running, or the adapter is off
+	}
+	if err != nil && s.ctx.Err() == nil {
+		log.Printf("Warning: BLE discovery not changed for the scan cadence: %v", err)
+	}
+}
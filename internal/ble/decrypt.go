@@ -12,8 +12,14 @@ import (
 //   - encryptedData: The 16-byte encrypted payload (bytes 9-24 from advertisement)
 //   - key: The 16-byte encryption key (IRK or ENC_KEY from proximity keys)
 //
-// Returns the decrypted 16-byte payload.
+// Returns the decrypted 16-byte payload. The payload is validated with DefaultDecryptionRules.
 func DecryptProximityPayload(encryptedData []byte, key []byte) ([]byte, error) {
+	return DecryptProximityPayloadWithRules(encryptedData, key, DefaultDecryptionRules)
+}
+
+// DecryptProximityPayloadWithRules decrypts the encrypted portion of a proximity pairing
+// advertisement like DecryptProximityPayload, validating the result with the given rules.
+func DecryptProximityPayloadWithRules(encryptedData []byte, key []byte, rules DecryptionRules) ([]byte, error) {
 	if len(encryptedData) != 16 {
 		return nil, fmt.Errorf("encrypted data must be 16 bytes, got %d", len(encryptedData))
 	}
@@ -34,15 +40,9 @@ func DecryptProximityPayload(encryptedData []byte, key []byte) ([]byte, error) {
 	decrypted := make([]byte, 16)
 	block.Decrypt(decrypted, encryptedData)
 
-	// Validate decrypted data using known magic bytes
 	// If wrong key is used, AES will "succeed" but produce garbage data
-	// These patterns help identify correct decryption:
-	//   - Byte 0, upper nibble (bits 4-7): Must be 0x0
-	//   - Byte 4: Must be 0x2D (magic/validation marker)
-	if len(decrypted) >= 5 {
-		if (decrypted[0]&0xF0) != 0 || decrypted[4] != 0x2D {
-			return nil, fmt.Errorf("decryption validation failed: incorrect encryption key")
-		}
+	if err := rules.Validate(decrypted); err != nil {
+		return nil, err
 	}
 
 	return decrypted, nil
@@ -0,0 +1,84 @@
+package ble
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rateWindow is the length of the window used to compute advertisement rates
+const rateWindow = 10 * time.Second
+
+// ScannerMetrics is a snapshot of the scanner's throughput counters
+type ScannerMetrics struct {
+	Advertisements          uint64    // Apple manufacturer data frames received
+	ParseSuccesses          uint64    // Frames successfully parsed as proximity pairing data
+	ParseFailures           uint64    // Frames that were not (valid) proximity pairing data
+	AdvertisementsPerSecond float64   // Average rate over the last completed window
+	Since                   time.Time // When the counters started
+}
+
+// scannerMetrics collects throughput counters for a Scanner.
+// All methods are safe for concurrent use.
+type scannerMetrics struct {
+	advertisements atomic.Uint64
+	parseSuccesses atomic.Uint64
+	parseFailures  atomic.Uint64
+	since          time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	windowCount uint64
+	lastRate    float64
+}
+
+func newScannerMetrics() *scannerMetrics {
+	now := time.Now()
+	return &scannerMetrics{since: now, windowStart: now}
+}
+
+// recordAdvertisement counts a received Apple advertisement and updates the rate window
+func (sm *scannerMetrics) recordAdvertisement() {
+	sm.advertisements.Add(1)
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.rollWindow(time.Now())
+	sm.windowCount++
+}
+
+// recordParse counts the outcome of parsing an advertisement
+func (sm *scannerMetrics) recordParse(err error) {
+	if err != nil {
+		sm.parseFailures.Add(1)
+	} else {
+		sm.parseSuccesses.Add(1)
+	}
+}
+
+// rollWindow closes the current rate window if it has elapsed. Must be called with mu held.
+func (sm *scannerMetrics) rollWindow(now time.Time) {
+	elapsed := now.Sub(sm.windowStart)
+	if elapsed < rateWindow {
+		return
+	}
+	sm.lastRate = float64(sm.windowCount) / elapsed.Seconds()
+	sm.windowStart = now
+	sm.windowCount = 0
+}
+
+// snapshot returns the current counter values
+func (sm *scannerMetrics) snapshot() ScannerMetrics {
+	sm.mu.Lock()
+	sm.rollWindow(time.Now())
+	rate := sm.lastRate
+	sm.mu.Unlock()
+
+	return ScannerMetrics{
+		Advertisements:          sm.advertisements.Load(),
+		ParseSuccesses:          sm.parseSuccesses.Load(),
+		ParseFailures:           sm.parseFailures.Load(),
+		AdvertisementsPerSecond: rate,
+		Since:                   sm.since,
+	}
+}
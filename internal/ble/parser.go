@@ -1,6 +1,11 @@
 package ble
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+
+	"linuxpods/internal/aap"
+)
 
 const (
 	proximityType = 0x07
@@ -20,19 +25,77 @@ type ProximityData struct {
 	LeftCharging    bool
 	RightCharging   bool
 	CaseCharging    bool
+	Battery         *uint8 // Single battery of AirPods Max and other headphones, nil for earbuds
+	Charging        bool   // Charging status of the single battery
 	LeftInEar       bool
 	RightInEar      bool
+	LeftInCase      bool
+	RightInCase     bool
 	LidOpen         bool
+	LidOpenCount    uint8 // Counts lid openings, wraps around after 7 (see LidOpenCountMask)
+	InCase          bool  // The pod sending the advertisement is in the case
 	Color           uint8
 	ConnectionState uint8
-	IsFlipped       bool   // true if right pod is primary
-	RawData         []byte // raw unencrypted payload for debugging
+	RSSI            int16     // Signal strength in dBm as reported by BlueZ, 0 if unknown
+	IsFlipped       bool      // true if right pod is primary
+	RawData         []byte    // raw unencrypted payload for debugging
+	LastSeen        time.Time // When the advertisement was received (zero if not received by a scanner)
+	Format          PayloadFormat
 
 	// Decrypted portion (only if encryption key was available)
 	HasDecrypted bool   // true if decrypted data was processed
 	RawDecrypted []byte // raw decrypted 16-byte payload for debugging
 }
 
+// PayloadFormat is the layout of a proximity pairing payload, detected from its length
+type PayloadFormat int
+
+const (
+	// PayloadShort is sent by older firmware: model, status, battery and charging only (6-8 bytes)
+	PayloadShort PayloadFormat = iota + 1
+	// PayloadUnencrypted has all unencrypted fields but no encrypted block (9-24 bytes)
+	PayloadUnencrypted
+	// PayloadEncrypted has the unencrypted fields followed by the encrypted block (25+ bytes)
+	PayloadEncrypted
+)
+
+func (f PayloadFormat) String() string {
+	switch f {
+	case PayloadShort:
+		return "Short"
+	case PayloadUnencrypted:
+		return "Unencrypted"
+	case PayloadEncrypted:
+		return "Encrypted"
+	default:
+		return "Unknown"
+	}
+}
+
+const (
+	// minPayloadLength covers prefix(1) + model(2) + status(1) + battery(1) + charging/case(1)
+	minPayloadLength = 6
+	// unencryptedPayloadLength adds lid counter(1) + color(1) + lid(1)
+	unencryptedPayloadLength = 9
+	// encryptedPayloadLength adds the encrypted block(16)
+	encryptedPayloadLength = unencryptedPayloadLength + 16
+)
+
+// detectPayloadFormat detects the format of a payload from its length
+func detectPayloadFormat(payload []byte) PayloadFormat {
+	switch {
+	case len(payload) >= encryptedPayloadLength:
+		return PayloadEncrypted
+	case len(payload) >= unencryptedPayloadLength:
+		return PayloadUnencrypted
+	default:
+		return PayloadShort
+	}
+}
+
+// LidOpenCountMask selects the lid open counter bits of payload byte 6
+const LidOpenCountMask = 0x07
+
 // ParseProximityData parses Apple Continuity proximity pairing advertisement.
 // This function is exported for use in debugging tools.
 func ParseProximityData(data []byte) (*ProximityData, error) {
@@ -53,8 +116,8 @@ func ParseProximityData(data []byte) (*ProximityData, error) {
 
 	payload := data[2 : 2+length]
 
-	// Minimum payload: prefix(1) + model(2) + status(1) + battery(1) + charging(1) + case(1) + lid(1) + color(1) + suffix(1) = 10 bytes
-	if len(payload) < 10 {
+	// Older firmware sends shorter payloads, the fields after the charging byte are optional
+	if len(payload) < minPayloadLength {
 		return nil, fmt.Errorf("payload too short")
 	}
 
@@ -66,11 +129,17 @@ func ParseProximityData(data []byte) (*ProximityData, error) {
 	pd := &ProximityData{
 		DeviceModel: uint16(payload[1])<<8 | uint16(payload[2]),
 		Status:      payload[3],
+		Format:      detectPayloadFormat(payload),
 		RawData:     append([]byte(nil), payload...), // Copy payload for debugging
 	}
 
-	// Parse color from byte 7
-	if len(payload) > 7 {
+	// Lid counter, color and lid state are missing in short payloads
+	if pd.Format != PayloadShort {
+		// Parse the lid open counter from byte 6. The low three bits are incremented each time
+		// the case lid is opened, the meaning of the other bits is unknown.
+		pd.LidOpenCount = payload[6] & LidOpenCountMask
+
+		// Parse color from byte 7
 		pd.Color = payload[7]
 	}
 
@@ -92,6 +161,7 @@ func ParseProximityData(data []byte) (*ProximityData, error) {
 	xorFactor := primaryLeft != thisInCase // XOR operation for ear detection
 
 	pd.IsFlipped = isFlipped
+	pd.InCase = thisInCase
 
 	// Parse battery levels from byte 4 using nibbles
 	// Nibbles may be swapped based on orientation
@@ -108,10 +178,7 @@ func ParseProximityData(data []byte) (*ProximityData, error) {
 	pd.RightBattery = DecodeBattery(rightNibble)
 
 	// Case battery from byte 5 - use simple decoding like AirPods batteries
-	if len(payload) > 5 {
-		caseBatteryRaw := payload[5]
-		pd.CaseBattery = DecodeBattery(caseBatteryRaw & 0x0F)
-	}
+	pd.CaseBattery = DecodeBattery(payload[5] & 0x0F)
 
 	// Parse charging status from byte 5
 	chargingByte := payload[5]
@@ -128,6 +195,16 @@ func ParseProximityData(data []byte) (*ProximityData, error) {
 		pd.LeftCharging, pd.RightCharging = pd.RightCharging, pd.LeftCharging
 	}
 
+	// Single-battery devices (AirPods Max) report their battery in the primary pod's
+	// nibble and charging bit, which are the high nibble and the fourth bit regardless
+	// of orientation. The other fields don't apply.
+	if pd.HasSingleBattery() {
+		pd.Battery = DecodeBattery((batteryByte >> 4) & 0x0F)
+		pd.Charging = ((chargingByte >> (8 - 4)) & 0x01) != 0
+		pd.LeftBattery, pd.RightBattery, pd.CaseBattery = nil, nil, nil
+		pd.LeftCharging, pd.RightCharging, pd.CaseCharging = false, false, false
+	}
+
 	// Parse ear detection from status byte (byte 3)
 	pd.LeftInEar = (statusByte & 0x08) != 0
 	pd.RightInEar = (statusByte & 0x02) != 0
@@ -136,10 +213,21 @@ func ParseProximityData(data []byte) (*ProximityData, error) {
 		pd.LeftInEar, pd.RightInEar = pd.RightInEar, pd.LeftInEar
 	}
 
+	// Parse case status from status byte: bit 2 is set while both pods are in the case, bit 4
+	// while only one is. That one is the primary pod if it is in the case (bit 6), the other
+	// pod otherwise. Headphones without a case don't set these bits.
+	switch {
+	case statusByte&0x04 != 0:
+		pd.LeftInCase, pd.RightInCase = true, true
+	case statusByte&0x10 != 0:
+		pd.LeftInCase = primaryLeft == thisInCase
+		pd.RightInCase = !pd.LeftInCase
+	}
+
 	// Parse lid status from byte 8 (lid byte), bit 3
 	// Based on LibrePods: ((lid >> 3) & 0x01) == 0 means lid is open
 	// Encrypted?
-	if len(payload) > 8 {
+	if pd.Format != PayloadShort {
 		lidByte := payload[8]
 		pd.LidOpen = ((lidByte >> 3) & 0x01) == 0
 	}
@@ -153,6 +241,15 @@ func ParseProximityData(data []byte) (*ProximityData, error) {
 	return pd, nil
 }
 
+// EncryptedPayload returns the encrypted block of the payload (the last 16 bytes),
+// or nil if the payload has none
+func (pd *ProximityData) EncryptedPayload() []byte {
+	if pd.Format != PayloadEncrypted {
+		return nil
+	}
+	return pd.RawData[len(pd.RawData)-16:]
+}
+
 // AddDecryptedData merges decrypted battery data into an existing ProximityData struct.
 // This overwrites the approximate battery levels from BLE with accurate (1%) levels.
 //
@@ -174,6 +271,15 @@ func (pd *ProximityData) AddDecryptedData(decrypted []byte) error {
 	pd.HasDecrypted = true
 	pd.RawDecrypted = append([]byte(nil), decrypted...) // Copy for debugging
 
+	// Single-battery devices report their battery as the first pod
+	if pd.HasSingleBattery() {
+		if level := decrypted[1] & 0x7F; level <= 100 {
+			pd.Battery = &level
+			pd.Charging = decrypted[1]&0x80 != 0
+		}
+		return nil
+	}
+
 	// Parse battery data from decrypted bytes
 	if len(decrypted) >= 4 {
 		// Byte 1 - First pod
@@ -223,6 +329,22 @@ func (pd *ProximityData) AddDecryptedData(decrypted []byte) error {
 	return nil
 }
 
+// HasSingleBattery reports whether the device model has a single battery (AirPods Max and
+// other headphones) instead of two pods and a case
+func (pd *ProximityData) HasSingleBattery() bool {
+	caps, known := aap.ModelCapabilities(pd.DeviceModel)
+	return known && caps.SingleBattery()
+}
+
+// Docked reports whether the pods are stowed in the closed case: the lid is closed, the
+// advertising pod is in the case and no pod is in an ear
+func (pd *ProximityData) Docked() bool {
+	if pd.Format == PayloadShort || pd.HasSingleBattery() {
+		return false // The lid state is unknown
+	}
+	return !pd.LidOpen && pd.InCase && !pd.LeftInEar && !pd.RightInEar
+}
+
 // DecodeBattery decodes a battery nibble value
 // 0x0-0x9: 0-90% in 10% increments
 // 0xA-0xE: 100%
@@ -296,18 +418,42 @@ func DecodeConnectionState(state uint8) string {
 	}
 }
 
+// IsConnectedState reports whether a connection state byte shows the device connected to a
+// host (idle, playing music or in a call)
+func IsConnectedState(state uint8) bool {
+	switch state {
+	case 0x04, 0x05, 0x06, 0x07, 0x09:
+		return true
+	default:
+		return false
+	}
+}
+
+// ConnectionStateName returns a stable identifier of a connection state byte for APIs, e.g.
+// "music", or "" if the state is unknown
+func ConnectionStateName(state uint8) string {
+	switch state {
+	case 0x00:
+		return "disconnected"
+	case 0x04:
+		return "idle"
+	case 0x05:
+		return "music"
+	case 0x06:
+		return "call"
+	case 0x07:
+		return "ringing"
+	case 0x09:
+		return "hanging-up"
+	default:
+		return ""
+	}
+}
+
 // DecodeModelName returns the human-readable model name for a device model code
 func DecodeModelName(deviceModel uint16) string {
-	switch deviceModel {
-	case 0x0220:
-		return "AirPods (2nd gen)"
-	case 0x0e20:
-		return "AirPods Pro"
-	case 0x2420:
-		return "AirPods Pro (2nd gen)"
-	case 0x2720:
-		return "AirPods Pro 3"
-	default:
-		return fmt.Sprintf("Unknown (0x%04X)", deviceModel)
+	if model, ok := aap.LookupModel(deviceModel); ok {
+		return model.Name
 	}
+	return fmt.Sprintf("Unknown (0x%04X)", deviceModel)
 }
@@ -0,0 +1,349 @@
+package ble_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linuxpods/internal/annotator"
+	"linuxpods/internal/ble"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the parser corpus")
+
+// proximityFrame wraps a proximity pairing payload in its type and length header
+func proximityFrame(payload ...byte) []byte {
+	return append([]byte{0x07, byte(len(payload))}, payload...)
+}
+
+// padPayload extends a payload with zero bytes to the given length
+func padPayload(payload []byte, length int) []byte {
+	return append(payload, make([]byte, length-len(payload))...)
+}
+
+func level(v uint8) *uint8 { return &v }
+
+func levelValue(level *uint8) any {
+	if level == nil {
+		return nil
+	}
+	return *level
+}
+
+func TestParseProximityData(t *testing.T) {
+	// AirPods Pro, left pod primary, right pod in ear, left 90%, right 80%,
+	// case 70%, left pod and case charging
+	earbuds := []byte{0x01, 0x0e, 0x20, 0x22, 0x98, 0x57}
+	// Same with the right pod primary, which swaps the battery nibbles and charging bits
+	flipped := []byte{0x01, 0x0e, 0x20, 0x02, 0x98, 0x57}
+	// AirPods Max, same status, battery and charging bytes
+	headphones := []byte{0x01, 0x0a, 0x20, 0x22, 0x98, 0x57}
+	// Lid open count 2, color 1, lid open, connection state 0x44
+	unencrypted := []byte{0x0a, 0x01, 0x00, 0x44}
+
+	type want struct {
+		format          ble.PayloadFormat
+		left, right     *uint8
+		caseLevel       *uint8
+		battery         *uint8
+		leftCharging    bool
+		rightCharging   bool
+		caseCharging    bool
+		charging        bool
+		lidOpen         bool
+		lidOpenCount    uint8
+		color           uint8
+		connectionState uint8
+		encrypted       bool
+	}
+	tests := []struct {
+		name    string
+		frame   []byte
+		want    want
+		wantErr bool
+	}{
+		{name: "empty", frame: nil, wantErr: true},
+		{name: "wrong type", frame: []byte{0x10, 0x06, 0x01, 0x0e, 0x20, 0x22, 0x98, 0x57}, wantErr: true},
+		{name: "incomplete", frame: []byte{0x07, 0x06, 0x01, 0x0e, 0x20}, wantErr: true},
+		{name: "5 bytes", frame: proximityFrame(earbuds[:5]...), wantErr: true},
+		{name: "invalid prefix", frame: proximityFrame(0x02, 0x0e, 0x20, 0x22, 0x98, 0x57), wantErr: true},
+		{
+			name:  "6 bytes",
+			frame: proximityFrame(earbuds...),
+			want: want{
+				format: ble.PayloadShort,
+				left:   level(90), right: level(80), caseLevel: level(70),
+				leftCharging: true, caseCharging: true,
+			},
+		},
+		{
+			name:  "8 bytes",
+			frame: proximityFrame(append(earbuds, 0x0a, 0x01)...),
+			want: want{
+				format: ble.PayloadShort,
+				left:   level(90), right: level(80), caseLevel: level(70),
+				leftCharging: true, caseCharging: true,
+			},
+		},
+		{
+			name:  "9 bytes",
+			frame: proximityFrame(append(earbuds, unencrypted[:3]...)...),
+			want: want{
+				format: ble.PayloadUnencrypted,
+				left:   level(90), right: level(80), caseLevel: level(70),
+				leftCharging: true, caseCharging: true,
+				lidOpen: true, lidOpenCount: 2, color: 1,
+			},
+		},
+		{
+			name:  "24 bytes",
+			frame: proximityFrame(padPayload(append(earbuds, unencrypted...), 24)...),
+			want: want{
+				format: ble.PayloadUnencrypted,
+				left:   level(90), right: level(80), caseLevel: level(70),
+				leftCharging: true, caseCharging: true,
+				lidOpen: true, lidOpenCount: 2, color: 1, connectionState: 0x44,
+			},
+		},
+		{
+			name:  "25 bytes",
+			frame: proximityFrame(padPayload(append(earbuds, unencrypted...), 25)...),
+			want: want{
+				format: ble.PayloadEncrypted,
+				left:   level(90), right: level(80), caseLevel: level(70),
+				leftCharging: true, caseCharging: true,
+				lidOpen: true, lidOpenCount: 2, color: 1, connectionState: 0x44,
+				encrypted: true,
+			},
+		},
+		{
+			name:  "flipped",
+			frame: proximityFrame(flipped...),
+			want: want{
+				format: ble.PayloadShort,
+				left:   level(80), right: level(90), caseLevel: level(70),
+				rightCharging: true, caseCharging: true,
+			},
+		},
+		{
+			name:  "single battery",
+			frame: proximityFrame(headphones...),
+			want: want{
+				format:  ble.PayloadShort,
+				battery: level(90), charging: true,
+			},
+		},
+		{
+			name:  "single battery flipped",
+			frame: proximityFrame(0x01, 0x0a, 0x20, 0x02, 0x98, 0x57),
+			want: want{
+				format:  ble.PayloadShort,
+				battery: level(90), charging: true,
+			},
+		},
+		{
+			name:  "single battery 25 bytes",
+			frame: proximityFrame(padPayload(append(headphones, unencrypted...), 25)...),
+			want: want{
+				format:  ble.PayloadEncrypted,
+				battery: level(90), charging: true,
+				lidOpen: true, lidOpenCount: 2, color: 1, connectionState: 0x44,
+				encrypted: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ble.ParseProximityData(tt.frame)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseProximityData() = %+v, want an error", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseProximityData() error: %v", err)
+			}
+
+			if data.Format != tt.want.format {
+				t.Errorf("Format = %v, want %v", data.Format, tt.want.format)
+			}
+			levels := []struct {
+				name      string
+				got, want *uint8
+			}{
+				{"LeftBattery", data.LeftBattery, tt.want.left},
+				{"RightBattery", data.RightBattery, tt.want.right},
+				{"CaseBattery", data.CaseBattery, tt.want.caseLevel},
+				{"Battery", data.Battery, tt.want.battery},
+			}
+			for _, l := range levels {
+				if levelValue(l.got) != levelValue(l.want) {
+					t.Errorf("%s = %v, want %v", l.name, levelValue(l.got), levelValue(l.want))
+				}
+			}
+			flags := []struct {
+				name      string
+				got, want bool
+			}{
+				{"LeftCharging", data.LeftCharging, tt.want.leftCharging},
+				{"RightCharging", data.RightCharging, tt.want.rightCharging},
+				{"CaseCharging", data.CaseCharging, tt.want.caseCharging},
+				{"Charging", data.Charging, tt.want.charging},
+				{"LidOpen", data.LidOpen, tt.want.lidOpen},
+			}
+			for _, f := range flags {
+				if f.got != f.want {
+					t.Errorf("%s = %t, want %t", f.name, f.got, f.want)
+				}
+			}
+			if data.LidOpenCount != tt.want.lidOpenCount {
+				t.Errorf("LidOpenCount = %d, want %d", data.LidOpenCount, tt.want.lidOpenCount)
+			}
+			if data.Color != tt.want.color {
+				t.Errorf("Color = %d, want %d", data.Color, tt.want.color)
+			}
+			if data.ConnectionState != tt.want.connectionState {
+				t.Errorf("ConnectionState = %#x, want %#x", data.ConnectionState, tt.want.connectionState)
+			}
+
+			encrypted := data.EncryptedPayload()
+			if tt.want.encrypted {
+				payload := tt.frame[2:]
+				if !bytes.Equal(encrypted, payload[len(payload)-16:]) {
+					t.Errorf("EncryptedPayload() = %x, want the last 16 bytes of the payload", encrypted)
+				}
+			} else if encrypted != nil {
+				t.Errorf("EncryptedPayload() = %x, want nil", encrypted)
+			}
+		})
+	}
+}
+
+// recording is a corpus file of testdata and the frames read from it
+type recording struct {
+	path   string
+	frames [][]byte
+}
+
+// loadRecordings reads the advertisement recordings (see ble_record) of testdata. The synthetic
+// ones are not recorded from devices, see testdata/README.md.
+func loadRecordings(tb testing.TB) []recording {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.jsonl"))
+	if err != nil {
+		tb.Fatalf("Failed to list corpus: %v", err)
+	}
+	if len(paths) == 0 {
+		tb.Fatal("No recordings in testdata")
+	}
+
+	var recordings []recording
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			tb.Fatalf("Failed to open corpus file: %v", err)
+		}
+		frames, err := ble.ReadRecording(file)
+		file.Close()
+		if err != nil {
+			tb.Fatalf("Failed to read %s: %v", path, err)
+		}
+
+		rec := recording{path: path}
+		for _, frame := range frames {
+			rec.frames = append(rec.frames, frame.Data)
+		}
+		recordings = append(recordings, rec)
+	}
+	return recordings
+}
+
+// TestGolden compares the parse results of the corpus with the golden files next to it.
+// Run with -update to rewrite them after an intended parser change.
+func TestGolden(t *testing.T) {
+	for _, rec := range loadRecordings(t) {
+		t.Run(filepath.Base(rec.path), func(t *testing.T) {
+			var out strings.Builder
+			for i, frame := range rec.frames {
+				fmt.Fprintf(&out, "=== #%d %s\n%s\n", i+1, hex.EncodeToString(frame), annotator.DescribeAdvertisement(frame))
+			}
+
+			goldenPath := strings.TrimSuffix(rec.path, ".jsonl") + ".golden"
+			if *update {
+				if err := os.WriteFile(goldenPath, []byte(out.String()), 0o644); err != nil {
+					t.Fatalf("Failed to write golden file: %v", err)
+				}
+				return
+			}
+
+			golden, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("Failed to read golden file: %v (run with -update to create it)", err)
+			}
+			expected := strings.Split(string(golden), "\n")
+			actual := strings.Split(out.String(), "\n")
+			for i := range max(len(expected), len(actual)) {
+				var e, a string
+				if i < len(expected) {
+					e = expected[i]
+				}
+				if i < len(actual) {
+					a = actual[i]
+				}
+				if e != a {
+					t.Fatalf("Parse results differ from %s at line %d:\n  expected: %s\n  actual:   %s", goldenPath, i+1, e, a)
+				}
+			}
+		})
+	}
+}
+
+// FuzzParseProximityData checks that advertisements, which anyone in Bluetooth range can
+// send, neither panic the parser nor produce implausible values
+func FuzzParseProximityData(f *testing.F) {
+	for _, rec := range loadRecordings(f) {
+		for _, frame := range rec.frames {
+			f.Add(frame)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		data, err := ble.ParseProximityData(frame)
+		if err != nil {
+			return
+		}
+		checkLevels(t, data)
+		_ = annotator.ProximityPayload(data.RawData)
+		_ = data.String()
+
+		encrypted := data.EncryptedPayload()
+		if encrypted == nil {
+			return
+		}
+		if len(encrypted) != 16 {
+			t.Fatalf("Encrypted payload of %d bytes", len(encrypted))
+		}
+		// Treat the encrypted block as decrypted data, it is random enough
+		if err := data.AddDecryptedData(encrypted); err != nil {
+			t.Fatalf("Merging decrypted data: %v", err)
+		}
+		checkLevels(t, data)
+		_ = annotator.DecryptedPayload(encrypted, data.IsFlipped)
+	})
+}
+
+// checkLevels fails the test if a battery level is above 100%
+func checkLevels(t *testing.T, data *ble.ProximityData) {
+	t.Helper()
+	for _, level := range []*uint8{data.LeftBattery, data.RightBattery, data.CaseBattery, data.Battery} {
+		if level != nil && *level > 100 {
+			t.Errorf("Battery level %d%% in %+v", *level, data)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package ble
+
+import (
+	"fmt"
+)
+
+// Proximity is a rough distance estimate derived from the RSSI of advertisements
+type Proximity int
+
+const (
+	ProximityUnknown Proximity = iota // No RSSI reported
+	ProximityNear                     // Within a few meters, e.g. on the desk or in a pocket
+	ProximityFar                      // In another room or further away
+)
+
+// nearRSSIThreshold is the weakest RSSI in dBm still considered near.
+// RSSI varies a lot with orientation and obstacles (e.g. a closed case in a bag),
+// so only two coarse buckets are distinguished.
+const nearRSSIThreshold = -65
+
+func (p Proximity) String() string {
+	switch p {
+	case ProximityNear:
+		return "Near"
+	case ProximityFar:
+		return "Far"
+	case ProximityUnknown:
+		return "Unknown"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(p))
+	}
+}
+
+// EstimateProximity estimates the distance to a device from the RSSI of its advertisements.
+// An RSSI of 0 means no RSSI was reported.
+func EstimateProximity(rssi int16) Proximity {
+	switch {
+	case rssi == 0:
+		return ProximityUnknown
+	case rssi >= nearRSSIThreshold:
+		return ProximityNear
+	default:
+		return ProximityFar
+	}
+}
@@ -0,0 +1,154 @@
+package ble
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"linuxpods/internal/aap"
+)
+
+// RecordedFrame is a single raw Apple manufacturer data frame in an advertisement recording.
+//
+// Recordings are stored as JSON lines, one frame per line:
+//
+//	{"time":"2025-01-01T12:00:00.123456789Z","address":"AA:BB:CC:DD:EE:FF","rssi":-60,"data":"07190114202b..."}
+//
+// Frames are recorded before parsing, so recordings also contain advertisements of
+// models that ParseProximityData doesn't support yet.
+type RecordedFrame struct {
+	Time    time.Time    `json:"time"`
+	Address string       `json:"address"`
+	RSSI    int16        `json:"rssi,omitempty"`
+	Data    aap.HexBytes `json:"data"`
+}
+
+// frameRecorder writes frames to a JSON lines recording.
+// All methods are safe for concurrent use.
+type frameRecorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// record writes a frame. Write errors are ignored so that a failing recording
+// never breaks scanning.
+func (r *frameRecorder) record(frame RecordedFrame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.enc.Encode(frame)
+}
+
+// ReadRecording reads all frames from a JSON lines advertisement recording
+func ReadRecording(r io.Reader) ([]RecordedFrame, error) {
+	var frames []RecordedFrame
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var frame RecordedFrame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, fmt.Errorf("invalid recording line %d: %w", line, err)
+		}
+		frames = append(frames, frame)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	return frames, nil
+}
+
+// ReplaySource feeds the frames of a recording back through ParseProximityData.
+// It implements podstate.AdvertisementSource, so a recording can be run through
+// the coordinator without Bluetooth hardware.
+type ReplaySource struct {
+	frames []RecordedFrame
+	speed  float64
+
+	metrics *scannerMetrics
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// NewReplaySource creates a source that replays the frames with their original timing,
+// sped up by the given factor. A speed of 0 replays all frames without delay.
+func NewReplaySource(frames []RecordedFrame, speed float64) *ReplaySource {
+	return &ReplaySource{
+		frames:  frames,
+		speed:   speed,
+		metrics: newScannerMetrics(),
+		closed:  make(chan struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving the parsed advertisements of the recording.
+// Frames that fail to parse are counted in the metrics and skipped. The channel is
+// closed at the end of the recording or when the source is closed.
+// Advertisements are timestamped with the replay time, so that state timeouts behave
+// like during the recording.
+func (s *ReplaySource) Subscribe() <-chan Advertisement {
+	advertisements := make(chan Advertisement)
+	go func() {
+		defer close(advertisements)
+
+		for i, frame := range s.frames {
+			if i > 0 && s.speed > 0 {
+				delay := time.Duration(float64(frame.Time.Sub(s.frames[i-1].Time)) / s.speed)
+				if delay > 0 {
+					timer := time.NewTimer(delay)
+					select {
+					case <-timer.C:
+					case <-s.closed:
+						timer.Stop()
+						return
+					}
+				}
+			}
+
+			ad, ok := s.parseFrame(frame)
+			if !ok {
+				continue
+			}
+			select {
+			case advertisements <- ad:
+			case <-s.closed:
+				return
+			}
+		}
+	}()
+	return advertisements
+}
+
+// parseFrame parses a recorded frame like the scanner parses a received one
+func (s *ReplaySource) parseFrame(frame RecordedFrame) (Advertisement, bool) {
+	s.metrics.recordAdvertisement()
+	data, err := ParseProximityData(frame.Data)
+	s.metrics.recordParse(err)
+	if err != nil {
+		return Advertisement{}, false
+	}
+	data.RSSI = frame.RSSI
+	data.LastSeen = time.Now()
+
+	return Advertisement{Data: data, Address: frame.Address, Received: data.LastSeen}, true
+}
+
+// Metrics returns the replay's throughput counters
+func (s *ReplaySource) Metrics() ScannerMetrics {
+	return s.metrics.snapshot()
+}
+
+// Close stops the replay and closes the subscriber channels
+func (s *ReplaySource) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
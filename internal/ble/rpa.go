@@ -0,0 +1,89 @@
+package ble
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// ResolvePrivateAddress checks whether a resolvable private address (RPA) was generated
+// from the given Identity Resolving Key (IRK), as defined in the Bluetooth Core
+// Specification (Vol 3, Part H, 2.2.2, random address hash function ah).
+//
+// An RPA consists of a 24-bit random part (prand, most significant half, top bits 0b01)
+// and a 24-bit hash: hash = ah(IRK, prand) = AES-128(IRK, 0...0 || prand) mod 2^24.
+//
+// The byte order of IRKs differs between tools (the specification uses most significant
+// octet first, some tools store the key reversed), so both orders are tried.
+func ResolvePrivateAddress(irk []byte, macAddr string) (bool, error) {
+	if len(irk) != 16 {
+		return false, fmt.Errorf("IRK must be 16 bytes, got %d", len(irk))
+	}
+
+	addr, err := hex.DecodeString(strings.ReplaceAll(macAddr, ":", ""))
+	if err != nil || len(addr) != 6 {
+		return false, fmt.Errorf("invalid MAC address %q", macAddr)
+	}
+
+	// Only resolvable private addresses (top two bits 0b01) can be resolved
+	if addr[0]&0xC0 != 0x40 {
+		return false, nil
+	}
+	prand, hash := addr[:3], addr[3:]
+
+	reversed := slices.Clone(irk)
+	slices.Reverse(reversed)
+
+	for _, key := range [][]byte{irk, reversed} {
+		expected, err := addressHash(key, prand)
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(expected, hash) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// GenerateResolvablePrivateAddress creates a new random resolvable private address from an IRK,
+// like AirPods do when rotating their BLE address. It is used to simulate devices.
+func GenerateResolvablePrivateAddress(irk []byte) (string, error) {
+	if len(irk) != 16 {
+		return "", fmt.Errorf("IRK must be 16 bytes, got %d", len(irk))
+	}
+
+	prand := make([]byte, 3)
+	if _, err := rand.Read(prand); err != nil {
+		return "", fmt.Errorf("failed to generate random part: %w", err)
+	}
+	prand[0] = prand[0]&0x3F | 0x40 // Top two bits 0b01 mark a resolvable private address
+
+	hash, err := addressHash(irk, prand)
+	if err != nil {
+		return "", err
+	}
+
+	addr := append(prand, hash...)
+	return strings.ToUpper(net.HardwareAddr(addr).String()), nil
+}
+
+// addressHash computes the 24-bit hash of a resolvable private address:
+// ah(IRK, prand) = AES-128(IRK, 0...0 || prand) mod 2^24
+func addressHash(irk []byte, prand []byte) ([]byte, error) {
+	block, err := aes.NewCipher(irk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+
+	var plaintext, ciphertext [16]byte
+	copy(plaintext[13:], prand)
+	block.Encrypt(ciphertext[:], plaintext[:])
+	return ciphertext[13:], nil
+}
@@ -19,8 +19,13 @@
 package ble
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/godbus/dbus/v5"
@@ -28,40 +33,151 @@ import (
 
 const (
 	bluezService   = "org.bluez"
-	adapterPath    = "/org/bluez/hci0"
 	appleCompanyID = 0x004C
+
+	// defaultAdapter is the adapter scanned when none is configured
+	defaultAdapter = "hci0"
 )
 
+// minDiscoveryRSSI is the discovery filter's RSSI threshold in dBm.
+// Weaker advertisements come from devices too far away to be the user's AirPods.
+const minDiscoveryRSSI int16 = -90
+
+// maxTrackedDevices bounds the per-device state of the dispatch loop. The state is
+// reset when more devices have been seen, e.g. in crowded places.
+const maxTrackedDevices = 512
+
+// subscriberBuffer is the number of advertisements buffered per subscriber.
+// Advertisements are dropped for subscribers that fall further behind.
+const subscriberBuffer = 16
+
+// Advertisement is a proximity pairing advertisement received by the scanner
+type Advertisement struct {
+	Data     *ProximityData
+	Address  string    // Random BLE address the advertisement was sent from
+	Received time.Time // When the advertisement was received
+}
+
 // Scanner handles BLE advertisement scanning
 type Scanner struct {
-	conn   *dbus.Conn
-	signal chan *dbus.Signal
+	conn        *dbus.Conn
+	adapterPath dbus.ObjectPath // e.g. /org/bluez/hci0
+	signal      chan *dbus.Signal
+	metrics     *scannerMetrics
+
+	dispatchOnce sync.Once
+	restartMu    sync.Mutex // Serializes discovery restarts after adapter resets
+	ctx          context.Context
+	cancel       context.CancelFunc
+	rssi         map[dbus.ObjectPath]int16         // Last RSSI per device (only accessed by the dispatch loop)
+	lastAds      map[dbus.ObjectPath]Advertisement // Last advertisement per device (only accessed by the dispatch loop)
+
+	mu          sync.Mutex
+	subscribers []chan Advertisement
+	recorder    *frameRecorder // Records raw frames if set by Record
+	discovering bool           // Discovery was started and not stopped, restart it after adapter resets
+	cadence     Cadence        // How discovery alternates between scanning and pausing
+	paused      bool           // Discovery is paused by the cadence
+	closed      bool
+
+	cadenceChanged chan struct{} // Wakes up the cadence loop when the cadence changed
 }
 
-// NewScanner creates a new BLE scanner
+// NewScanner creates a new BLE scanner for the default adapter (hci0)
 func NewScanner() (*Scanner, error) {
+	return NewScannerForAdapter("")
+}
+
+// NewScannerForAdapter creates a new BLE scanner for an adapter such as hci1.
+// An empty name selects the default adapter.
+func NewScannerForAdapter(adapter string) (*Scanner, error) {
+	if adapter == "" {
+		adapter = defaultAdapter
+	}
+	if strings.ContainsAny(adapter, "/'") {
+		return nil, fmt.Errorf("invalid adapter name %q", adapter)
+	}
+
 	conn, err := dbus.ConnectSystemBus()
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to system bus: %w", err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Scanner{
-		conn:   conn,
-		signal: make(chan *dbus.Signal, 10),
+		conn:        conn,
+		adapterPath: dbus.ObjectPath("/org/bluez/" + adapter),
+		signal:      make(chan *dbus.Signal, 10),
+		metrics:     newScannerMetrics(),
+		ctx:         ctx,
+		cancel:      cancel,
+		rssi:        make(map[dbus.ObjectPath]int16),
+		lastAds:     make(map[dbus.ObjectPath]Advertisement),
+
+		cadenceChanged: make(chan struct{}, 1),
 	}, nil
 }
 
-// StartDiscovery begins BLE scanning
+// StartDiscovery begins BLE scanning.
+// Advertisements are delivered to the channels returned by Subscribe.
+// Discovery is restarted automatically when the adapter is powered back on or BlueZ restarts.
 func (s *Scanner) StartDiscovery() error {
-	obj := s.conn.Object(bluezService, adapterPath)
+	if err := s.startDiscovery(); err != nil {
+		return err
+	}
+
+	// Subscribe to PropertiesChanged signals of devices (advertisements) and the adapter (power
+	// changes), and to BlueZ restarts. Filtering by sender, path and interface in the match
+	// rules keeps the bus daemon from waking us up for unrelated signals.
+	rules := []string{
+		"type='signal',sender='" + bluezService + "',interface='org.freedesktop.DBus.Properties',member='PropertiesChanged'," +
+			"path_namespace='" + string(s.adapterPath) + "',arg0='org.bluez.Device1'",
+		"type='signal',sender='" + bluezService + "',interface='org.freedesktop.DBus.Properties',member='PropertiesChanged'," +
+			"path='" + string(s.adapterPath) + "',arg0='org.bluez.Adapter1'",
+		"type='signal',interface='org.freedesktop.DBus',member='NameOwnerChanged',arg0='" + bluezService + "'",
+	}
+	for _, rule := range rules {
+		if err := s.conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, rule).Err; err != nil {
+			return fmt.Errorf("failed to add match rule: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.discovering = true
+	s.mu.Unlock()
 
-	// Set a discovery filter for LE only
+	s.dispatchOnce.Do(func() {
+		s.conn.Signal(s.signal)
+		go s.dispatchLoop()
+		go s.cadenceLoop()
+	})
+
+	return nil
+}
+
+// startDiscovery applies the discovery filter and starts discovery on the adapter
+func (s *Scanner) startDiscovery() error {
+	obj := s.conn.Object(bluezService, s.adapterPath)
+
+	// Only report LE devices in range, and only when their advertisement changes
 	filter := map[string]interface{}{
-		"Transport": "le",
+		"Transport":     "le",
+		"RSSI":          minDiscoveryRSSI,
+		"DuplicateData": false,
 	}
 
 	if err := obj.Call("org.bluez.Adapter1.SetDiscoveryFilter", 0, filter).Err; err != nil {
-		return fmt.Errorf("failed to set discovery filter: %w", err)
+		// BlueZ before 5.48 doesn't know DuplicateData - fall back to the LE transport only
+		var dbusErr dbus.Error
+		if !errors.As(err, &dbusErr) || dbusErr.Name != "org.bluez.Error.InvalidArguments" {
+			return fmt.Errorf("failed to set discovery filter: %w", err)
+		}
+		filter = map[string]interface{}{
+			"Transport": "le",
+		}
+		if err := obj.Call("org.bluez.Adapter1.SetDiscoveryFilter", 0, filter).Err; err != nil {
+			return fmt.Errorf("failed to set discovery filter: %w", err)
+		}
 	}
 
 	// Start discovery
@@ -69,86 +185,202 @@ func (s *Scanner) StartDiscovery() error {
 		return fmt.Errorf("failed to start discovery: %w", err)
 	}
 
-	// Subscribe to PropertiesChanged signals
-	rule := "type='signal',interface='org.freedesktop.DBus.Properties',member='PropertiesChanged'"
-	if err := s.conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, rule).Err; err != nil {
-		return fmt.Errorf("failed to add match rule: %w", err)
-	}
-
-	s.conn.Signal(s.signal)
-
 	return nil
 }
 
 // StopDiscovery stops BLE scanning
 func (s *Scanner) StopDiscovery() error {
-	obj := s.conn.Object(bluezService, adapterPath)
+	s.mu.Lock()
+	s.discovering = false
+	s.mu.Unlock()
+
+	obj := s.conn.Object(bluezService, s.adapterPath)
 	return obj.Call("org.bluez.Adapter1.StopDiscovery", 0).Err
 }
 
-// ScanForAirPods scans for AirPods advertisements and returns proximity data and device address
-func (s *Scanner) ScanForAirPods(timeout time.Duration) (*ProximityData, string, error) {
-	timer := time.NewTimer(timeout)
-	defer timer.Stop()
+// Subscribe returns a channel that receives every AirPods advertisement from now on,
+// until the scanner is closed. Each subscriber gets its own channel. A subscriber
+// that doesn't keep up misses advertisements instead of blocking the scanner.
+func (s *Scanner) Subscribe() <-chan Advertisement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := make(chan Advertisement, subscriberBuffer)
+	if s.closed {
+		close(ch)
+		return ch
+	}
+	s.subscribers = append(s.subscribers, ch)
+	return ch
+}
+
+// Record writes every Apple manufacturer data frame received from now on to w as
+// JSON lines (see RecordedFrame), including frames that fail to parse.
+// Passing nil stops recording.
+func (s *Scanner) Record(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if w == nil {
+		s.recorder = nil
+		return
+	}
+	s.recorder = &frameRecorder{enc: json.NewEncoder(w)}
+}
+
+// dispatchLoop parses the D-Bus signals and publishes the advertisements to all subscribers.
+// It ends when the D-Bus connection is closed.
+func (s *Scanner) dispatchLoop() {
+	defer s.closeSubscribers()
+
+	for signal := range s.signal {
+		if isAdapterReset(signal, s.adapterPath) {
+			go s.restartDiscovery()
+			continue
+		}
+		if ad, ok := s.parseSignal(signal); ok {
+			s.publish(ad)
+		}
+	}
+}
+
+// parseSignal extracts an AirPods advertisement from a PropertiesChanged signal
+func (s *Scanner) parseSignal(signal *dbus.Signal) (Advertisement, bool) {
+	// Only device signals carry advertisements - check the path before looking at the body
+	if signal.Name != "org.freedesktop.DBus.Properties.PropertiesChanged" ||
+		!strings.HasPrefix(string(signal.Path), string(s.adapterPath)+"/dev_") || len(signal.Body) < 2 {
+		return Advertisement{}, false
+	}
+
+	iface, ok := signal.Body[0].(string)
+	if !ok || iface != "org.bluez.Device1" {
+		return Advertisement{}, false
+	}
+
+	changes, ok := signal.Body[1].(map[string]dbus.Variant)
+	if !ok {
+		return Advertisement{}, false
+	}
+
+	if len(s.rssi) > maxTrackedDevices {
+		s.rssi = make(map[dbus.ObjectPath]int16)
+		s.lastAds = make(map[dbus.ObjectPath]Advertisement)
+	}
+
+	// RSSI is often reported in a separate signal - remember it for the next advertisement
+	rssiChanged := false
+	if rssiVar, ok := changes["RSSI"]; ok {
+		if rssi, ok := rssiVar.Value().(int16); ok {
+			s.rssi[signal.Path] = rssi
+			rssiChanged = true
+		}
+	}
+
+	// Check for Apple manufacturer data
+	mfgDataVar, ok := changes["ManufacturerData"]
+	if !ok {
+		if rssiChanged {
+			return s.refreshAdvertisement(signal.Path)
+		}
+		return Advertisement{}, false
+	}
+	mfgData, ok := mfgDataVar.Value().(map[uint16]dbus.Variant)
+	if !ok {
+		return Advertisement{}, false
+	}
+	appleDataVar, ok := mfgData[appleCompanyID]
+	if !ok {
+		return Advertisement{}, false
+	}
+	appleData, ok := appleDataVar.Value().([]byte)
+	if !ok {
+		return Advertisement{}, false
+	}
+
+	// Extract MAC address from D-Bus path
+	// Path format: /org/bluez/hci0/dev_XX_XX_XX_XX_XX_XX
+	address := extractMacFromPath(string(signal.Path))
+	received := time.Now()
+
+	s.mu.Lock()
+	recorder := s.recorder
+	s.mu.Unlock()
+	if recorder != nil {
+		recorder.record(RecordedFrame{
+			Time:    received,
+			Address: address,
+			RSSI:    s.rssi[signal.Path],
+			Data:    append([]byte(nil), appleData...),
+		})
+	}
+
+	// Parse proximity pairing data
+	s.metrics.recordAdvertisement()
+	data, err := ParseProximityData(appleData)
+	s.metrics.recordParse(err)
+	if err != nil {
+		return Advertisement{}, false
+	}
+	data.RSSI = s.rssi[signal.Path]
+	data.LastSeen = received
+
+	ad := Advertisement{
+		Data:     data,
+		Address:  address,
+		Received: received,
+	}
+	s.lastAds[signal.Path] = ad
+	return ad, true
+}
+
+// refreshAdvertisement republishes the last advertisement of a device with its new RSSI.
+// BlueZ only signals changed advertisements (DuplicateData is disabled), so RSSI updates
+// are the only sign that a device with an unchanged advertisement is still in range.
+func (s *Scanner) refreshAdvertisement(path dbus.ObjectPath) (Advertisement, bool) {
+	ad, ok := s.lastAds[path]
+	if !ok {
+		return Advertisement{}, false
+	}
 
-	for {
+	// Copy the data, subscribers may still hold the previous advertisement
+	data := *ad.Data
+	data.RSSI = s.rssi[path]
+	data.LastSeen = time.Now()
+	ad.Data = &data
+	ad.Received = data.LastSeen
+	s.lastAds[path] = ad
+	return ad, true
+}
+
+// publish delivers an advertisement to all subscribers without blocking
+func (s *Scanner) publish(ad Advertisement) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ch := range s.subscribers {
 		select {
-		case <-timer.C:
-			return nil, "", fmt.Errorf("scan timeout")
-
-		case signal, ok := <-s.signal:
-
-			// Debugging message for an unexpected closed dbus channel
-			if !ok {
-				log.Println("Error: This should not happen. DBUS channel closed.")
-				continue
-			}
-
-			if signal.Name != "org.freedesktop.DBus.Properties.PropertiesChanged" {
-				continue
-			}
-
-			if len(signal.Body) < 2 {
-				continue
-			}
-
-			iface, ok := signal.Body[0].(string)
-			if !ok || iface != "org.bluez.Device1" {
-				continue
-			}
-
-			changes, ok := signal.Body[1].(map[string]dbus.Variant)
-			if !ok {
-				continue
-			}
-
-			// Check for manufacturer data
-			if mfgDataVar, ok := changes["ManufacturerData"]; ok {
-				mfgData, ok := mfgDataVar.Value().(map[uint16]dbus.Variant)
-				if !ok {
-					continue
-				}
-
-				// Look for Apple manufacturer data
-				if appleDataVar, ok := mfgData[appleCompanyID]; ok {
-					appleData, ok := appleDataVar.Value().([]byte)
-					if !ok {
-						continue
-					}
-
-					// Parse proximity pairing data
-					if data, err := ParseProximityData(appleData); err == nil {
-						// Extract MAC address from D-Bus path
-						// Path format: /org/bluez/hci0/dev_XX_XX_XX_XX_XX_XX
-						macAddr := extractMacFromPath(string(signal.Path))
-						return data, macAddr, nil
-					}
-				}
-			}
+		case ch <- ad:
+		default:
+			// Subscriber is behind - drop the advertisement for it
 		}
 	}
 }
 
+// closeSubscribers closes all subscriber channels, ending their receive loops
+func (s *Scanner) closeSubscribers() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for _, ch := range s.subscribers {
+		close(ch)
+	}
+	s.subscribers = nil
+}
+
 // extractMacFromPath extracts MAC address from BlueZ D-Bus device path
 // Example: /org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF -> AA:BB:CC:DD:EE:FF
 func extractMacFromPath(path string) string {
@@ -177,8 +409,16 @@ func extractMacFromPath(path string) string {
 	return mac
 }
 
-// Close closes the scanner
+// Metrics returns a snapshot of the scanner's throughput counters
+func (s *Scanner) Metrics() ScannerMetrics {
+	return s.metrics.snapshot()
+}
+
+// Close closes the scanner and all subscriber channels
 func (s *Scanner) Close() error {
 	_ = s.StopDiscovery()
-	return s.conn.Close()
+	s.cancel()
+	err := s.conn.Close()
+	s.closeSubscribers()
+	return err
 }
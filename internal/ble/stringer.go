@@ -10,6 +10,20 @@ func (pd *ProximityData) String() string {
 	}
 	result := fmt.Sprintf("AirPods Battery (%s):\n", accuracy)
 
+	// Headphones with a single battery (AirPods Max)
+	if pd.HasSingleBattery() {
+		result += fmt.Sprintf("  Battery: ")
+		if pd.Battery != nil {
+			result += fmt.Sprintf("%d%% ", *pd.Battery)
+			if pd.Charging {
+				result += "(Charging)"
+			}
+		} else {
+			result += "Unknown"
+		}
+		return result + fmt.Sprintf("\n  Model: 0x%04X", pd.DeviceModel)
+	}
+
 	// Left AirPod
 	result += fmt.Sprintf("  Left:  ")
 	if pd.LeftBattery != nil {
@@ -20,6 +34,9 @@ func (pd *ProximityData) String() string {
 		if pd.LeftInEar {
 			result += "[In Ear]"
 		}
+		if pd.LeftInCase {
+			result += "[In Case]"
+		}
 	} else {
 		result += "Unknown"
 	}
@@ -34,6 +51,9 @@ func (pd *ProximityData) String() string {
 		if pd.RightInEar {
 			result += "[In Ear]"
 		}
+		if pd.RightInCase {
+			result += "[In Case]"
+		}
 	} else {
 		result += "Unknown"
 	}
@@ -51,13 +71,20 @@ func (pd *ProximityData) String() string {
 
 	// Lid status
 	result += fmt.Sprintf("\n  Lid:   ")
-	if pd.LidOpen {
+	switch {
+	case pd.Format == PayloadShort:
+		result += "Unknown"
+	case pd.LidOpen:
 		result += "Open"
-	} else {
+	default:
 		result += "Closed"
 	}
+	if pd.Format != PayloadShort {
+		result += fmt.Sprintf(" (open count %d)", pd.LidOpenCount)
+	}
 
 	result += fmt.Sprintf("\n  Model: 0x%04X", pd.DeviceModel)
+	result += fmt.Sprintf("\n  Format: %s (%d bytes)", pd.Format, len(pd.RawData))
 
 	// Color
 	result += fmt.Sprintf("\n  Color: %s", DecodeColor(pd.Color))
@@ -73,6 +100,11 @@ func (pd *ProximityData) String() string {
 		result += "Normal (Left pod is primary)"
 	}
 
+	// Signal strength
+	if pd.RSSI != 0 {
+		result += fmt.Sprintf("\n  Signal: %d dBm (%s)", pd.RSSI, EstimateProximity(pd.RSSI))
+	}
+
 	// Raw data
 	result += fmt.Sprintf("\n\n  Raw Data: ")
 	for i, b := range pd.RawData {
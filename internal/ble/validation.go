@@ -0,0 +1,94 @@
+package ble
+
+import (
+	"fmt"
+	"sort"
+)
+
+// unknownBatteryLevel is the level of a battery that is not available, e.g. of a pod
+// that is out of range
+const unknownBatteryLevel = 0x7F
+
+// DecryptionRules are plausibility checks for a decrypted proximity payload.
+// Decrypting with a wrong key "succeeds" but produces random bytes, which the rules reject.
+// The fewer bits the rules check, the more often a wrong key passes, which matters when
+// many keys are tried to identify a device. Firmware generations differ in the bytes they
+// set, so the rules are versioned and can be selected with ParseDecryptionRules.
+type DecryptionRules struct {
+	Name string
+
+	ZeroBits     map[int]byte // Byte index -> bits that must be zero
+	Constants    map[int]byte // Byte index -> required value
+	BatteryBytes []int        // Byte indexes of battery levels (bit 7 charging, bits 0-6 level)
+}
+
+var (
+	// DecryptionRulesLegacy only checks the magic bytes. A wrong key passes 1 in 4096 times.
+	DecryptionRulesLegacy = DecryptionRules{
+		Name:      "legacy",
+		ZeroBits:  map[int]byte{0: 0xF0},
+		Constants: map[int]byte{4: 0x2D},
+	}
+
+	// DecryptionRulesV2 also requires plausible battery levels (at most 100%, or unknown)
+	// and rejects the reserved bits 3-7 of the flags in byte 0.
+	// A wrong key passes roughly 1 in 16000 times.
+	DecryptionRulesV2 = DecryptionRules{
+		Name:         "v2",
+		ZeroBits:     map[int]byte{0: 0xF8},
+		Constants:    map[int]byte{4: 0x2D},
+		BatteryBytes: []int{1, 2, 3},
+	}
+)
+
+// DefaultDecryptionRules are the rules used by DecryptProximityPayload
+var DefaultDecryptionRules = DecryptionRulesV2
+
+// decryptionRules lists the known rule sets by name
+var decryptionRules = map[string]DecryptionRules{
+	DecryptionRulesLegacy.Name: DecryptionRulesLegacy,
+	DecryptionRulesV2.Name:     DecryptionRulesV2,
+}
+
+// ParseDecryptionRules returns the rule set with the given name.
+// An empty string selects DefaultDecryptionRules.
+func ParseDecryptionRules(name string) (DecryptionRules, error) {
+	if name == "" {
+		return DefaultDecryptionRules, nil
+	}
+	rules, ok := decryptionRules[name]
+	if !ok {
+		names := make([]string, 0, len(decryptionRules))
+		for n := range decryptionRules {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return DefaultDecryptionRules, fmt.Errorf("unknown decryption rules %q (expected one of %v)", name, names)
+	}
+	return rules, nil
+}
+
+// Validate checks a decrypted payload against the rules
+func (r DecryptionRules) Validate(decrypted []byte) error {
+	if len(decrypted) != 16 {
+		return fmt.Errorf("decrypted data must be 16 bytes, got %d", len(decrypted))
+	}
+
+	for i, mask := range r.ZeroBits {
+		if decrypted[i]&mask != 0 {
+			return fmt.Errorf("decryption validation failed: incorrect encryption key (byte %d)", i)
+		}
+	}
+	for i, value := range r.Constants {
+		if decrypted[i] != value {
+			return fmt.Errorf("decryption validation failed: incorrect encryption key (byte %d)", i)
+		}
+	}
+	for _, i := range r.BatteryBytes {
+		if level := decrypted[i] & 0x7F; level > 100 && level != unknownBatteryLevel {
+			return fmt.Errorf("decryption validation failed: incorrect encryption key (battery %d%%)", level)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package ble_test
+
+import (
+	"testing"
+
+	"linuxpods/internal/ble"
+)
+
+// decryptedPayload returns a plausible decrypted payload with the given battery bytes
+func decryptedPayload(first, second, batteryCase byte) []byte {
+	decrypted := make([]byte, 16)
+	decrypted[1], decrypted[2], decrypted[3] = first, second, batteryCase
+	decrypted[4] = 0x2D
+	return decrypted
+}
+
+func TestDecryptionRulesValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		decrypted  func() []byte
+		wantLegacy bool // Accepted by DecryptionRulesLegacy
+		wantV2     bool // Accepted by DecryptionRulesV2
+	}{
+		{
+			name:       "valid",
+			decrypted:  func() []byte { return decryptedPayload(87, 0x80|93, 54) },
+			wantLegacy: true, wantV2: true,
+		},
+		{
+			name:       "unknown battery",
+			decrypted:  func() []byte { return decryptedPayload(87, 0x7F, 54) },
+			wantLegacy: true, wantV2: true,
+		},
+		{
+			name: "wrong magic byte",
+			decrypted: func() []byte {
+				d := decryptedPayload(87, 93, 54)
+				d[4] = 0x2C
+				return d
+			},
+		},
+		{
+			name: "upper flag bits set",
+			decrypted: func() []byte {
+				d := decryptedPayload(87, 93, 54)
+				d[0] = 0x10
+				return d
+			},
+		},
+		{
+			name:       "battery above 100%",
+			decrypted:  func() []byte { return decryptedPayload(87, 120, 54) },
+			wantLegacy: true,
+		},
+		{
+			name: "reserved flag bit set",
+			decrypted: func() []byte {
+				d := decryptedPayload(87, 93, 54)
+				d[0] = 0x08
+				return d
+			},
+			wantLegacy: true,
+		},
+		{
+			name:      "short payload",
+			decrypted: func() []byte { return decryptedPayload(87, 93, 54)[:15] },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, rules := range []struct {
+				rules ble.DecryptionRules
+				want  bool
+			}{
+				{ble.DecryptionRulesLegacy, tt.wantLegacy},
+				{ble.DecryptionRulesV2, tt.wantV2},
+			} {
+				err := rules.rules.Validate(tt.decrypted())
+				if accepted := err == nil; accepted != rules.want {
+					t.Errorf("%s: Validate() = %v, want accepted %t", rules.rules.Name, err, rules.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package bluez
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const adapterIface = "org.bluez.Adapter1"
+
+// adapterMatchRule receives the InterfacesAdded and InterfacesRemoved signals of BlueZ,
+// which announce adapters being plugged in or removed
+const adapterMatchRule = "type='signal',sender='org.bluez',interface='org.freedesktop.DBus.ObjectManager'"
+
+// findAdapters returns the paths of all adapters in the given BlueZ objects that support
+// battery providers, sorted (hci0 first)
+func findAdapters(objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant) []dbus.ObjectPath {
+	var adapters []dbus.ObjectPath
+	for path, interfaces := range objects {
+		_, isAdapter := interfaces[adapterIface]
+		_, hasManager := interfaces[batteryProviderManagerIface]
+		if isAdapter && hasManager {
+			adapters = append(adapters, path)
+		}
+	}
+	sort.Slice(adapters, func(i, j int) bool { return adapters[i] < adapters[j] })
+	return adapters
+}
+
+// selectAdapter returns the adapter to register the provider with: the adapter connected
+// AirPods are connected through, or the first adapter if no AirPods are connected.
+// It returns "" if there is no adapter.
+func selectAdapter(objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant) dbus.ObjectPath {
+	adapters := findAdapters(objects)
+	if len(adapters) == 0 {
+		return ""
+	}
+	for _, devicePath := range findAllAirPodsInObjects(objects) {
+		if adapter, ok := objects[dbus.ObjectPath(devicePath)]["org.bluez.Device1"]["Adapter"].Value().(dbus.ObjectPath); ok {
+			for _, a := range adapters {
+				if a == adapter {
+					return adapter
+				}
+			}
+		}
+	}
+	return adapters[0]
+}
+
+// managedObjects returns all BlueZ objects
+func (bp *BluezBatteryProvider) managedObjects() (map[dbus.ObjectPath]map[string]map[string]dbus.Variant, error) {
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	obj := bp.conn.Object(bluezService, "/")
+	if err := obj.Call("org.freedesktop.DBus.ObjectManager.GetManagedObjects", 0).Store(&objects); err != nil {
+		return nil, fmt.Errorf("failed to get managed objects: %w", err)
+	}
+	return objects, nil
+}
+
+// deviceAdapter returns the adapter a Bluetooth device is connected through
+func (bp *BluezBatteryProvider) deviceAdapter(devicePath string) (dbus.ObjectPath, error) {
+	obj := bp.conn.Object(bluezService, dbus.ObjectPath(devicePath))
+	variant, err := obj.GetProperty("org.bluez.Device1.Adapter")
+	if err != nil {
+		return "", fmt.Errorf("failed to get device adapter: %w", err)
+	}
+	adapter, ok := variant.Value().(dbus.ObjectPath)
+	if !ok {
+		return "", fmt.Errorf("adapter property is not an object path")
+	}
+	return adapter, nil
+}
+
+// useAdapter registers the provider with the given adapter, unregistering it from the
+// previous one. BlueZ only shows batteries of devices connected through the adapter the
+// provider is registered with. Must not be called with mu held: BlueZ queries the
+// provider's objects while registering.
+func (bp *BluezBatteryProvider) useAdapter(adapter dbus.ObjectPath) error {
+	bp.registerMu.Lock()
+	defer bp.registerMu.Unlock()
+
+	bp.mu.RLock()
+	previous := bp.adapter
+	bp.mu.RUnlock()
+	if adapter == previous {
+		return nil
+	}
+
+	if previous != "" {
+		_ = bp.unregister(previous) // The previous adapter may already be gone
+	}
+	if err := bp.register(adapter); err != nil {
+		bp.mu.Lock()
+		bp.adapter = ""
+		bp.mu.Unlock()
+		return err
+	}
+
+	bp.mu.Lock()
+	bp.adapter = adapter
+	bp.mu.Unlock()
+	log.Printf("Battery provider registered with adapter %s", adapter)
+	return nil
+}
+
+// useDeviceAdapter registers the provider with the adapter a device is connected through
+func (bp *BluezBatteryProvider) useDeviceAdapter(devicePath string) error {
+	adapter, err := bp.deviceAdapter(devicePath)
+	if err != nil {
+		return err
+	}
+	return bp.useAdapter(adapter)
+}
+
+// handleAdapterHotplug handles adapters being plugged in or removed. It reports whether
+// the signal was an adapter hotplug signal.
+func (bp *BluezBatteryProvider) handleAdapterHotplug(signal *dbus.Signal) bool {
+	switch signal.Name {
+	case "org.freedesktop.DBus.ObjectManager.InterfacesAdded":
+		if len(signal.Body) < 2 {
+			return true
+		}
+		path, _ := signal.Body[0].(dbus.ObjectPath)
+		interfaces, _ := signal.Body[1].(map[string]map[string]dbus.Variant)
+		if _, ok := interfaces[batteryProviderManagerIface]; !ok {
+			return true
+		}
+
+		bp.mu.RLock()
+		registered := bp.adapter != ""
+		bp.mu.RUnlock()
+		log.Printf("Bluetooth adapter %s added", path)
+		if !registered {
+			if err := bp.useAdapter(path); err != nil {
+				log.Printf("Failed to register battery provider with adapter %s: %v", path, err)
+			}
+		}
+		return true
+
+	case "org.freedesktop.DBus.ObjectManager.InterfacesRemoved":
+		if len(signal.Body) < 2 {
+			return true
+		}
+		path, _ := signal.Body[0].(dbus.ObjectPath)
+		interfaces, _ := signal.Body[1].([]string)
+		removed := false
+		for _, iface := range interfaces {
+			if iface == adapterIface {
+				removed = true
+			}
+		}
+		if !removed {
+			return true
+		}
+
+		bp.mu.Lock()
+		wasRegistered := bp.adapter == path
+		if wasRegistered {
+			bp.adapter = ""
+		}
+		bp.mu.Unlock()
+		log.Printf("Bluetooth adapter %s removed", path)
+		if !wasRegistered {
+			return true
+		}
+
+		// Continue on another adapter, if there is one
+		objects, err := bp.managedObjects()
+		if err != nil {
+			log.Printf("Failed to look for Bluetooth adapters: %v", err)
+			return true
+		}
+		if adapter := selectAdapter(objects); adapter != "" {
+			if err := bp.useAdapter(adapter); err != nil {
+				log.Printf("Failed to register battery provider with adapter %s: %v", adapter, err)
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package bluez
+
+import (
+	"sort"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// AdapterInfo describes a Bluetooth adapter (org.bluez.Adapter1), e.g. for bug reports
+type AdapterInfo struct {
+	Path         dbus.ObjectPath
+	Address      string
+	Name         string
+	Modalias     string // USB or other bus ID of the controller, e.g. "usb:v1D6Bp0246d0552"
+	Powered      bool
+	Discoverable bool
+	Discovering  bool
+}
+
+// Adapters returns the Bluetooth adapters, ordered by object path.
+// It returns an error if there is no adapter at all.
+func Adapters() ([]AdapterInfo, error) {
+	conn, adapters, err := findAllAdapters()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	infos := make([]AdapterInfo, 0, len(adapters))
+	for path, props := range adapters {
+		info := AdapterInfo{Path: path}
+		info.Address, _ = props["Address"].Value().(string)
+		info.Name, _ = props["Name"].Value().(string)
+		info.Modalias, _ = props["Modalias"].Value().(string)
+		info.Powered, _ = props["Powered"].Value().(bool)
+		info.Discoverable, _ = props["Discoverable"].Value().(bool)
+		info.Discovering, _ = props["Discovering"].Value().(bool)
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Path < infos[j].Path })
+	return infos, nil
+}
@@ -0,0 +1,77 @@
+package bluez
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// AdapterPowered checks if any Bluetooth adapter is powered on (org.bluez.Adapter1.Powered).
+// It returns an error if there is no adapter at all.
+func AdapterPowered() (bool, error) {
+	conn, adapters, err := findAllAdapters()
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	for _, props := range adapters {
+		if powered, _ := props["Powered"].Value().(bool); powered {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PowerOnAdapter powers on the first Bluetooth adapter (hci0) by setting
+// org.bluez.Adapter1.Powered. It does nothing if an adapter is already powered on.
+func PowerOnAdapter() error {
+	conn, adapters, err := findAllAdapters()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	paths := make([]dbus.ObjectPath, 0, len(adapters))
+	for path, props := range adapters {
+		if powered, _ := props["Powered"].Value().(bool); powered {
+			return nil
+		}
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+
+	obj := conn.Object(bluezService, paths[0])
+	if err := obj.SetProperty(adapterIface+".Powered", dbus.MakeVariant(true)); err != nil {
+		return fmt.Errorf("failed to power on %s: %w", paths[0], err)
+	}
+	return nil
+}
+
+// findAllAdapters connects to the system bus and returns the properties of all adapters,
+// including those without battery provider support. The caller must close the connection.
+func findAllAdapters() (*dbus.Conn, map[dbus.ObjectPath]map[string]dbus.Variant, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	if err := conn.Object(bluezService, "/").Call("org.freedesktop.DBus.ObjectManager.GetManagedObjects", 0).Store(&objects); err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("failed to get managed objects: %w", err)
+	}
+
+	adapters := make(map[dbus.ObjectPath]map[string]dbus.Variant)
+	for path, interfaces := range objects {
+		if props, ok := interfaces[adapterIface]; ok {
+			adapters[path] = props
+		}
+	}
+	if len(adapters) == 0 {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("no Bluetooth adapter found")
+	}
+	return conn, adapters, nil
+}
@@ -0,0 +1,98 @@
+package bluez
+
+import (
+	"log"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	agentManagerIface = "org.bluez.AgentManager1"
+	agentIface        = "org.bluez.Agent1"
+	agentPath         = "/com/github/mstroecker/linuxpods/agent"
+
+	// agentCapability makes BlueZ use "Just Works" pairing, like the AirPods themselves
+	agentCapability = "NoInputNoOutput"
+)
+
+// pairingAgent implements org.bluez.Agent1 for pairing a single device.
+// BlueZ asks the agent of the process that called Device1.Pair, so it must be registered
+// on the connection that pairs. Requests for other devices are rejected.
+type pairingAgent struct {
+	device dbus.ObjectPath
+}
+
+// registerAgent exports an agent for the device and registers it with BlueZ.
+// The returned function unregisters it again.
+func registerAgent(conn *dbus.Conn, device dbus.ObjectPath) (func(), error) {
+	agent := &pairingAgent{device: device}
+	if err := conn.Export(agent, agentPath, agentIface); err != nil {
+		return nil, err
+	}
+
+	manager := conn.Object(bluezService, "/org/bluez")
+	if err := manager.Call(agentManagerIface+".RegisterAgent", 0, dbus.ObjectPath(agentPath), agentCapability).Err; err != nil {
+		_ = conn.Export(nil, agentPath, agentIface)
+		return nil, err
+	}
+
+	return func() {
+		_ = manager.Call(agentManagerIface+".UnregisterAgent", 0, dbus.ObjectPath(agentPath)).Err
+		_ = conn.Export(nil, agentPath, agentIface)
+	}, nil
+}
+
+// accept accepts requests for the device being paired and rejects all others
+func (a *pairingAgent) accept(device dbus.ObjectPath, request string) *dbus.Error {
+	if device != a.device {
+		log.Printf("Pairing agent: rejected %s for %s", request, device)
+		return dbus.NewError("org.bluez.Error.Rejected", []interface{}{"not the device being paired"})
+	}
+	return nil
+}
+
+// Release implements org.bluez.Agent1.Release
+func (a *pairingAgent) Release() *dbus.Error {
+	return nil
+}
+
+// RequestPinCode implements org.bluez.Agent1.RequestPinCode (legacy pairing, unused by AirPods)
+func (a *pairingAgent) RequestPinCode(device dbus.ObjectPath) (string, *dbus.Error) {
+	return "", dbus.NewError("org.bluez.Error.Rejected", []interface{}{"PIN codes are not supported"})
+}
+
+// DisplayPinCode implements org.bluez.Agent1.DisplayPinCode
+func (a *pairingAgent) DisplayPinCode(device dbus.ObjectPath, pinCode string) *dbus.Error {
+	return a.accept(device, "DisplayPinCode")
+}
+
+// RequestPasskey implements org.bluez.Agent1.RequestPasskey
+func (a *pairingAgent) RequestPasskey(device dbus.ObjectPath) (uint32, *dbus.Error) {
+	return 0, dbus.NewError("org.bluez.Error.Rejected", []interface{}{"passkeys are not supported"})
+}
+
+// DisplayPasskey implements org.bluez.Agent1.DisplayPasskey
+func (a *pairingAgent) DisplayPasskey(device dbus.ObjectPath, passkey uint32, entered uint16) *dbus.Error {
+	return a.accept(device, "DisplayPasskey")
+}
+
+// RequestConfirmation implements org.bluez.Agent1.RequestConfirmation
+func (a *pairingAgent) RequestConfirmation(device dbus.ObjectPath, passkey uint32) *dbus.Error {
+	return a.accept(device, "RequestConfirmation")
+}
+
+// RequestAuthorization implements org.bluez.Agent1.RequestAuthorization
+func (a *pairingAgent) RequestAuthorization(device dbus.ObjectPath) *dbus.Error {
+	return a.accept(device, "RequestAuthorization")
+}
+
+// AuthorizeService implements org.bluez.Agent1.AuthorizeService
+func (a *pairingAgent) AuthorizeService(device dbus.ObjectPath, uuid string) *dbus.Error {
+	return a.accept(device, "AuthorizeService")
+}
+
+// Cancel implements org.bluez.Agent1.Cancel
+func (a *pairingAgent) Cancel() *dbus.Error {
+	log.Printf("Pairing agent: request cancelled by BlueZ")
+	return nil
+}
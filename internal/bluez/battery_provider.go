@@ -33,16 +33,26 @@
 //
 // # Testing
 //
-// See cmd/debug for test utilities that verify the D-Bus integration works correctly.
+// battery_provider_test.go checks the signatures of the signals and the GetManagedObjects
+// reply: BlueZ silently ignores a battery whose payload has the wrong types, so it never shows
+// up in GNOME Settings. cmd/debug_bluez_dbus_battery verifies the integration with a running
+// BlueZ.
 package bluez
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"path"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/introspect"
+
+	"linuxpods/internal/retry"
 )
 
 const (
@@ -52,14 +62,65 @@ const (
 	providerPath                = "/com/github/mstroecker/linuxpods/battery"
 )
 
+// registerBackoff is the retry policy for registering the provider with BlueZ
+var registerBackoff = retry.Backoff{
+	Initial:     500 * time.Millisecond,
+	Max:         5 * time.Second,
+	Multiplier:  2,
+	Jitter:      0.2,
+	MaxAttempts: 5,
+}
+
 // BatteryDevice represents a single battery device
 type BatteryDevice struct {
 	path       dbus.ObjectPath
 	percentage uint8
 	device     dbus.ObjectPath
+	address    string // MAC address of the device, "" if unknown
 	source     string
 }
 
+// batteryProperties returns the org.bluez.BatteryProvider1 properties of a battery device
+func (bd *BatteryDevice) batteryProperties() map[string]dbus.Variant {
+	return map[string]dbus.Variant{
+		"Percentage": dbus.MakeVariant(bd.percentage),
+		"Device":     dbus.MakeVariant(bd.device),
+		"Source":     dbus.MakeVariant(bd.source),
+	}
+}
+
+// interfaces returns the interfaces and properties of a battery object, as sent in
+// InterfacesAdded and GetManagedObjects
+func (bd *BatteryDevice) interfaces() map[string]map[string]dbus.Variant {
+	return map[string]map[string]dbus.Variant{
+		batteryProviderIface: bd.batteryProperties(),
+	}
+}
+
+// interfacesAddedBody returns the body of the InterfacesAdded signal announcing the battery
+func (bd *BatteryDevice) interfacesAddedBody() []interface{} {
+	return []interface{}{bd.path, bd.interfaces()}
+}
+
+// percentageChangedBody returns the body of the PropertiesChanged signal for a new percentage
+func (bd *BatteryDevice) percentageChangedBody() []interface{} {
+	changes := map[string]dbus.Variant{
+		"Percentage": dbus.MakeVariant(bd.percentage),
+	}
+	return []interface{}{batteryProviderIface, changes, []string{}}
+}
+
+// interfacesRemovedBody returns the body of the InterfacesRemoved signal removing the battery
+func (bd *BatteryDevice) interfacesRemovedBody() []interface{} {
+	return []interface{}{bd.path, []string{batteryProviderIface}}
+}
+
+// BatteryName returns the name of the battery of a device, derived from its object path,
+// e.g. dev_AA_BB_CC_DD_EE_FF for /org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF
+func BatteryName(devicePath string) string {
+	return path.Base(devicePath)
+}
+
 // AirPodsConnectionCallback is called when AirPods connect or disconnect
 type AirPodsConnectionCallback func(connected bool, devicePath string, macAddress string)
 
@@ -69,6 +130,8 @@ type BluezBatteryProvider struct {
 	devices            map[string]*BatteryDevice
 	mu                 sync.RWMutex
 	connectionCallback AirPodsConnectionCallback
+	adapter            dbus.ObjectPath // Adapter the provider is registered with, "" if none
+	registerMu         sync.Mutex      // Serializes moving the registration between adapters
 }
 
 // NewBluezBatteryProvider creates and registers a new battery provider with BlueZ
@@ -89,8 +152,21 @@ func NewBluezBatteryProvider() (*BluezBatteryProvider, error) {
 		return nil, fmt.Errorf("failed to export provider: %w", err)
 	}
 
-	// Register with BlueZ
-	if err := bp.register(); err != nil {
+	// Register with BlueZ (retried, since BlueZ may still be starting up, e.g. at login).
+	// Without an adapter the provider is registered once one is plugged in (see WatchForAirPods).
+	err = retry.Do(context.Background(), registerBackoff, func(ctx context.Context) error {
+		objects, err := bp.managedObjects()
+		if err != nil {
+			return err
+		}
+		adapter := selectAdapter(objects)
+		if adapter == "" {
+			log.Printf("No Bluetooth adapter found, waiting for one to be added")
+			return nil
+		}
+		return bp.useAdapter(adapter)
+	})
+	if err != nil {
 		_ = conn.Close()
 		return nil, fmt.Errorf("failed to register provider: %w", err)
 	}
@@ -106,35 +182,16 @@ func (bp *BluezBatteryProvider) exportProvider() error {
 	}
 
 	// Export introspection for the provider root
-	providerIntrospect := `
-<!DOCTYPE node PUBLIC "-//freedesktop//DTD D-BUS Object Introspection 1.0//EN"
-"http://www.freedesktop.org/standards/dbus/1.0/introspect.dtd">
-<node>
-	<interface name="org.freedesktop.DBus.ObjectManager">
-		<method name="GetManagedObjects">
-			<arg name="objects" type="a{oa{sa{sv}}}" direction="out"/>
-		</method>
-		<signal name="InterfacesAdded">
-			<arg name="object_path" type="o"/>
-			<arg name="interfaces_and_properties" type="a{sa{sv}}"/>
-		</signal>
-		<signal name="InterfacesRemoved">
-			<arg name="object_path" type="o"/>
-			<arg name="interfaces" type="as"/>
-		</signal>
-	</interface>
-</node>`
-
-	if err := bp.conn.Export(introspect.Introspectable(providerIntrospect), providerPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+	if err := bp.conn.Export(providerIntrospectable{bp}, providerPath, "org.freedesktop.DBus.Introspectable"); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// register registers this provider with BlueZ BatteryProviderManager
-func (bp *BluezBatteryProvider) register() error {
-	obj := bp.conn.Object(bluezService, "/org/bluez/hci0")
+// register registers this provider with the BatteryProviderManager of an adapter
+func (bp *BluezBatteryProvider) register(adapter dbus.ObjectPath) error {
+	obj := bp.conn.Object(bluezService, adapter)
 	call := obj.Call(batteryProviderManagerIface+".RegisterBatteryProvider", 0, dbus.ObjectPath(providerPath))
 	if call.Err != nil {
 		return fmt.Errorf("failed to register battery provider: %w", call.Err)
@@ -142,8 +199,24 @@ func (bp *BluezBatteryProvider) register() error {
 	return nil
 }
 
-// AddBattery adds a new battery device to the provider
+// unregister unregisters this provider from the BatteryProviderManager of an adapter
+func (bp *BluezBatteryProvider) unregister(adapter dbus.ObjectPath) error {
+	obj := bp.conn.Object(bluezService, adapter)
+	call := obj.Call(batteryProviderManagerIface+".UnregisterBatteryProvider", 0, dbus.ObjectPath(providerPath))
+	if call.Err != nil {
+		return fmt.Errorf("failed to unregister battery provider: %w", call.Err)
+	}
+	return nil
+}
+
+// AddBattery adds a new battery device to the provider.
+// The provider is moved to the adapter the device is connected through if necessary.
 func (bp *BluezBatteryProvider) AddBattery(name string, percentage uint8, devicePath string) error {
+	if err := bp.useDeviceAdapter(devicePath); err != nil {
+		return fmt.Errorf("failed to register with the device's adapter: %w", err)
+	}
+	address, _ := bp.GetDeviceAddress(devicePath)
+
 	bp.mu.Lock()
 	defer bp.mu.Unlock()
 
@@ -153,6 +226,7 @@ func (bp *BluezBatteryProvider) AddBattery(name string, percentage uint8, device
 		path:       batteryPath,
 		percentage: percentage,
 		device:     dbus.ObjectPath(devicePath),
+		address:    address,
 		source:     "LinuxPods",
 	}
 
@@ -162,45 +236,15 @@ func (bp *BluezBatteryProvider) AddBattery(name string, percentage uint8, device
 	}
 
 	// Export introspection for this battery object
-	batteryIntrospect := `
-<!DOCTYPE node PUBLIC "-//freedesktop//DTD D-BUS Object Introspection 1.0//EN"
-"http://www.freedesktop.org/standards/dbus/1.0/introspect.dtd">
-<node>
-	<interface name="org.bluez.BatteryProvider1">
-		<property name="Percentage" type="y" access="read"/>
-		<property name="Device" type="o" access="read"/>
-		<property name="Source" type="s" access="read"/>
-	</interface>
-	<interface name="org.freedesktop.DBus.Properties">
-		<method name="Get">
-			<arg name="interface_name" type="s" direction="in"/>
-			<arg name="property_name" type="s" direction="in"/>
-			<arg name="value" type="v" direction="out"/>
-		</method>
-		<method name="GetAll">
-			<arg name="interface_name" type="s" direction="in"/>
-			<arg name="properties" type="a{sv}" direction="out"/>
-		</method>
-	</interface>
-</node>`
-
-	if err := bp.conn.Export(introspect.Introspectable(batteryIntrospect), batteryPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+	if err := bp.conn.Export(introspect.NewIntrospectable(batteryNode()), batteryPath, "org.freedesktop.DBus.Introspectable"); err != nil {
 		return err
 	}
 
 	bp.devices[name] = device
 
 	// Emit InterfacesAdded signal to notify BlueZ of the new battery
-	interfaces := map[string]map[string]dbus.Variant{
-		batteryProviderIface: {
-			"Percentage": dbus.MakeVariant(percentage),
-			"Device":     dbus.MakeVariant(dbus.ObjectPath(devicePath)),
-			"Source":     dbus.MakeVariant("LinuxPods"),
-		},
-	}
-
 	if err := bp.conn.Emit(providerPath, "org.freedesktop.DBus.ObjectManager.InterfacesAdded",
-		batteryPath, interfaces); err != nil {
+		device.interfacesAddedBody()...); err != nil {
 		return fmt.Errorf("failed to emit InterfacesAdded signal: %w", err)
 	}
 
@@ -213,16 +257,11 @@ func (bd *BatteryDevice) Get(iface string, property string) (dbus.Variant, *dbus
 		return dbus.Variant{}, dbus.NewError("org.freedesktop.DBus.Error.UnknownInterface", []interface{}{iface})
 	}
 
-	switch property {
-	case "Percentage":
-		return dbus.MakeVariant(bd.percentage), nil
-	case "Device":
-		return dbus.MakeVariant(bd.device), nil
-	case "Source":
-		return dbus.MakeVariant(bd.source), nil
-	default:
+	value, ok := bd.batteryProperties()[property]
+	if !ok {
 		return dbus.Variant{}, dbus.NewError("org.freedesktop.DBus.Error.UnknownProperty", []interface{}{property})
 	}
+	return value, nil
 }
 
 // GetAll implements org.freedesktop.DBus.Properties.GetAll for BatteryDevice
@@ -231,11 +270,7 @@ func (bd *BatteryDevice) GetAll(iface string) (map[string]dbus.Variant, *dbus.Er
 		return nil, dbus.NewError("org.freedesktop.DBus.Error.UnknownInterface", []interface{}{iface})
 	}
 
-	return map[string]dbus.Variant{
-		"Percentage": dbus.MakeVariant(bd.percentage),
-		"Device":     dbus.MakeVariant(bd.device),
-		"Source":     dbus.MakeVariant(bd.source),
-	}, nil
+	return bd.batteryProperties(), nil
 }
 
 // Set implements org.freedesktop.DBus.Properties.Set for BatteryDevice (not used, all properties are read-only)
@@ -251,13 +286,7 @@ func (bp *BluezBatteryProvider) GetManagedObjects() (map[dbus.ObjectPath]map[str
 	objects := make(map[dbus.ObjectPath]map[string]map[string]dbus.Variant)
 
 	for _, device := range bp.devices {
-		objects[device.path] = map[string]map[string]dbus.Variant{
-			batteryProviderIface: {
-				"Percentage": dbus.MakeVariant(device.percentage),
-				"Device":     dbus.MakeVariant(device.device),
-				"Source":     dbus.MakeVariant(device.source),
-			},
-		}
+		objects[device.path] = device.interfaces()
 	}
 
 	return objects, nil
@@ -272,23 +301,41 @@ func (bp *BluezBatteryProvider) UpdateBatteryPercentage(name string, percentage
 	if !ok {
 		return fmt.Errorf("battery device %s not connected", name)
 	}
+	if device.percentage == percentage {
+		return nil
+	}
 
 	device.percentage = percentage
 
 	// Emit PropertiesChanged signal
-	changes := map[string]dbus.Variant{
-		"Percentage": dbus.MakeVariant(percentage),
-	}
-	invalidated := []string{}
-
 	if err := bp.conn.Emit(device.path, "org.freedesktop.DBus.Properties.PropertiesChanged",
-		batteryProviderIface, changes, invalidated); err != nil {
+		device.percentageChangedBody()...); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// HasBattery reports whether a battery device was added and not removed since
+func (bp *BluezBatteryProvider) HasBattery(name string) bool {
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
+	_, ok := bp.devices[name]
+	return ok
+}
+
+// BatteryNameForAddress returns the name of the battery of the device with the given MAC address
+func (bp *BluezBatteryProvider) BatteryNameForAddress(macAddr string) (string, bool) {
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
+	for name, device := range bp.devices {
+		if device.address != "" && strings.EqualFold(device.address, macAddr) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
 // RemoveBattery removes a battery device from the provider
 func (bp *BluezBatteryProvider) RemoveBattery(name string) error {
 	bp.mu.Lock()
@@ -302,9 +349,8 @@ func (bp *BluezBatteryProvider) RemoveBattery(name string) error {
 	batteryPath := device.path
 
 	// Emit InterfacesRemoved signal to notify BlueZ
-	interfaces := []string{batteryProviderIface}
 	if err := bp.conn.Emit(providerPath, "org.freedesktop.DBus.ObjectManager.InterfacesRemoved",
-		batteryPath, interfaces); err != nil {
+		device.interfacesRemovedBody()...); err != nil {
 		return fmt.Errorf("failed to emit InterfacesRemoved signal: %w", err)
 	}
 
@@ -321,76 +367,112 @@ func (bp *BluezBatteryProvider) RemoveBattery(name string) error {
 // DiscoverAirPodsDevice searches for connected AirPods using provider's existing connection
 func (bp *BluezBatteryProvider) DiscoverAirPodsDevice() (string, error) {
 	// Get all BlueZ managed objects
-	obj := bp.conn.Object(bluezService, "/")
-	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
-
-	err := obj.Call("org.freedesktop.DBus.ObjectManager.GetManagedObjects", 0).Store(&objects)
+	objects, err := bp.managedObjects()
 	if err != nil {
-		return "", fmt.Errorf("failed to get managed objects: %w", err)
+		return "", err
 	}
 
 	return findAirPodsInObjects(objects)
 }
 
-// findAirPodsInObjects searches for AirPods in the given BlueZ objects
-func findAirPodsInObjects(objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant) (string, error) {
-	// Search for AirPods devices
-	for path, interfaces := range objects {
-		// Check if this is a device object
-		if deviceProps, ok := interfaces["org.bluez.Device1"]; ok {
-			// Check device name/alias
-			if alias, ok := deviceProps["Alias"]; ok {
-				aliasStr, ok := alias.Value().(string)
-				if !ok {
-					continue
-				}
-				// Check if it's an AirPods device
-				if contains(aliasStr, "AirPods") {
-					// Check if device is connected
-					if connected, ok := deviceProps["Connected"]; ok {
-						if connBool, ok := connected.Value().(bool); ok && connBool {
-							return string(path), nil
-						}
-					}
-				}
-			}
+// ConnectedDevice identifies a connected Bluetooth device known to BlueZ
+type ConnectedDevice struct {
+	Path    string // BlueZ D-Bus object path
+	Address string // MAC address
+}
+
+// FindConnectedAirPods returns all connected AirPods using a short-lived system bus connection.
+// Unlike DiscoverAirPodsDevice, it does not require a registered provider, so it can be used
+// to restore AAP sessions at startup even when the battery provider is unavailable.
+func FindConnectedAirPods() ([]ConnectedDevice, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	obj := conn.Object(bluezService, "/")
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+
+	if err := obj.Call("org.freedesktop.DBus.ObjectManager.GetManagedObjects", 0).Store(&objects); err != nil {
+		return nil, fmt.Errorf("failed to get managed objects: %w", err)
+	}
+
+	var devices []ConnectedDevice
+	for _, devicePath := range findAllAirPodsInObjects(objects) {
+		address, ok := objects[dbus.ObjectPath(devicePath)]["org.bluez.Device1"]["Address"]
+		if !ok {
+			continue
+		}
+		if macAddr, ok := address.Value().(string); ok {
+			devices = append(devices, ConnectedDevice{Path: devicePath, Address: macAddr})
 		}
 	}
-	return "", fmt.Errorf("no connected AirPods device found")
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("no connected AirPods device found")
+	}
+
+	return devices, nil
 }
 
-// contains checks if a string contains a substring (case-insensitive helper)
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) &&
-		(s[:len(substr)] == substr || s[len(s)-len(substr):] == substr ||
-			findSubstring(s, substr)))
+// findAirPodsInObjects searches for the first connected AirPods in the given BlueZ objects
+func findAirPodsInObjects(objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant) (string, error) {
+	paths := findAllAirPodsInObjects(objects)
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no connected AirPods device found")
+	}
+	return paths[0], nil
 }
 
-func findSubstring(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
+// findAllAirPodsInObjects returns the paths of all connected AirPods in the given BlueZ objects, sorted
+func findAllAirPodsInObjects(objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant) []string {
+	var paths []string
+	for path, interfaces := range objects {
+		// Check if this is a device object
+		deviceProps, ok := interfaces["org.bluez.Device1"]
+		if !ok {
+			continue
+		}
+
+		// Check device model/alias
+		if !IsSupportedDevice(DeviceInfoFromProperties(deviceProps)) {
+			continue
+		}
+
+		// Check if device is connected
+		if connected, ok := deviceProps["Connected"]; ok {
+			if connBool, ok := connected.Value().(bool); ok && connBool {
+				paths = append(paths, string(path))
+			}
 		}
 	}
-	return false
+	sort.Strings(paths)
+	return paths
 }
 
-// WatchForAirPods monitors for AirPods connections and automatically registers battery
+// WatchForAirPods monitors for AirPods connections and automatically registers a battery
+// for each connected device (see BatteryName)
 func (bp *BluezBatteryProvider) WatchForAirPods() error {
 	// First, check if AirPods are already connected (using provider's existing connection)
-	if device, err := bp.DiscoverAirPodsDevice(); err == nil {
-		if err := bp.AddBattery("airpods_battery", 36, device); err == nil {
+	objects, err := bp.managedObjects()
+	if err != nil {
+		return err
+	}
+	for _, device := range findAllAirPodsInObjects(objects) {
+		if err := bp.AddBattery(BatteryName(device), 36, device); err == nil {
 			log.Printf("Battery provider registered for device: %s", device)
-			log.Println("Note: GNOME Settings shows one battery per device. Use LinuxPods app for all three batteries.")
-
-			// Notify connection callback
-			if macAddr, err := bp.GetDeviceAddress(device); err == nil {
-				bp.mu.RLock()
-				cb := bp.connectionCallback
-				bp.mu.RUnlock()
-				if cb != nil {
-					cb(true, device, macAddr)
-				}
+		} else {
+			log.Printf("Failed to add battery for already connected device %s: %v", device, err)
+		}
+
+		// Notify connection callback even if the battery could not be added,
+		// so the AAP session is still established for already connected AirPods
+		if macAddr, err := bp.GetDeviceAddress(device); err == nil {
+			bp.mu.RLock()
+			cb := bp.connectionCallback
+			bp.mu.RUnlock()
+			if cb != nil {
+				cb(true, device, macAddr)
 			}
 		}
 	}
@@ -401,6 +483,13 @@ func (bp *BluezBatteryProvider) WatchForAirPods() error {
 		return fmt.Errorf("failed to add match rule: %w", err)
 	}
 
+	// Watch for adapters being plugged in or removed, and for BlueZ restarts
+	for _, rule := range []string{adapterMatchRule, bluezOwnerMatchRule} {
+		if err := bp.conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, rule).Err; err != nil {
+			return fmt.Errorf("failed to add match rule: %w", err)
+		}
+	}
+
 	// Create channel for signals
 	signalChan := make(chan *dbus.Signal, 10)
 	bp.conn.Signal(signalChan)
@@ -408,6 +497,9 @@ func (bp *BluezBatteryProvider) WatchForAirPods() error {
 	// Monitor signals in background
 	go func() {
 		for signal := range signalChan {
+			if bp.handleAdapterHotplug(signal) || bp.handleBluezRestart(signal) {
+				continue
+			}
 			if signal.Name != "org.freedesktop.DBus.Properties.PropertiesChanged" {
 				continue
 			}
@@ -430,16 +522,12 @@ func (bp *BluezBatteryProvider) WatchForAirPods() error {
 			if connectedVar, ok := changes["Connected"]; ok {
 				devicePath := string(signal.Path)
 				if connected, ok := connectedVar.Value().(bool); ok {
-					// Check if it's AirPods
-					if alias := bp.getDeviceAlias(devicePath); contains(alias, "AirPods") {
+					// Check if it's AirPods or Beats
+					if bp.isSupportedDevice(devicePath) {
 						if connected {
 							// Device connected
-							bp.mu.Lock()
-							_, exists := bp.devices["airpods_battery"]
-							bp.mu.Unlock()
-
-							if !exists {
-								if err := bp.AddBattery("airpods_battery", 36, devicePath); err == nil {
+							if !bp.HasBattery(BatteryName(devicePath)) {
+								if err := bp.AddBattery(BatteryName(devicePath), 36, devicePath); err == nil {
 									log.Printf("Battery provider registered for newly connected device: %s", devicePath)
 								}
 							}
@@ -454,12 +542,22 @@ func (bp *BluezBatteryProvider) WatchForAirPods() error {
 								}
 							}
 						} else {
-							// Device disconnected
+							// Device disconnected (the device object and its address remain available).
+							// Remove its battery, otherwise GNOME Settings keeps showing the last level.
+							if bp.HasBattery(BatteryName(devicePath)) {
+								if err := bp.RemoveBattery(BatteryName(devicePath)); err != nil {
+									log.Printf("Failed to remove battery of disconnected device %s: %v", devicePath, err)
+								} else {
+									log.Printf("Battery provider removed for disconnected device: %s", devicePath)
+								}
+							}
+
+							macAddr, _ := bp.GetDeviceAddress(devicePath)
 							bp.mu.RLock()
 							cb := bp.connectionCallback
 							bp.mu.RUnlock()
 							if cb != nil {
-								cb(false, devicePath, "")
+								cb(false, devicePath, macAddr)
 							}
 						}
 					}
@@ -471,17 +569,14 @@ func (bp *BluezBatteryProvider) WatchForAirPods() error {
 	return nil
 }
 
-// getDeviceAlias retrieves the alias/name of a Bluetooth device
-func (bp *BluezBatteryProvider) getDeviceAlias(devicePath string) string {
+// isSupportedDevice checks if a Bluetooth device is a pair of AirPods or Beats headphones
+func (bp *BluezBatteryProvider) isSupportedDevice(devicePath string) bool {
 	obj := bp.conn.Object(bluezService, dbus.ObjectPath(devicePath))
-	variant, err := obj.GetProperty("org.bluez.Device1.Alias")
-	if err != nil {
-		return ""
-	}
-	if alias, ok := variant.Value().(string); ok {
-		return alias
+	var props map[string]dbus.Variant
+	if err := obj.Call("org.freedesktop.DBus.Properties.GetAll", 0, "org.bluez.Device1").Store(&props); err != nil {
+		return false
 	}
-	return ""
+	return IsSupportedDevice(DeviceInfoFromProperties(props))
 }
 
 // GetDeviceAddress retrieves the MAC address of a Bluetooth device
@@ -506,10 +601,13 @@ func (bp *BluezBatteryProvider) SetConnectionCallback(callback AirPodsConnection
 
 // Close unregisters the provider and closes the D-Bus connection
 func (bp *BluezBatteryProvider) Close() error {
-	obj := bp.conn.Object(bluezService, "/org/bluez/hci0")
-	call := obj.Call(batteryProviderManagerIface+".UnregisterBatteryProvider", 0, dbus.ObjectPath(providerPath))
-	if call.Err != nil {
-		return call.Err
+	bp.mu.RLock()
+	adapter := bp.adapter
+	bp.mu.RUnlock()
+	if adapter != "" {
+		if err := bp.unregister(adapter); err != nil {
+			return err
+		}
 	}
 	_ = bp.conn.Close()
 	return nil
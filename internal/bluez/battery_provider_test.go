@@ -0,0 +1,170 @@
+package bluez
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const testDevicePath = "/org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF"
+
+func testBattery(name string, percentage uint8) *BatteryDevice {
+	return &BatteryDevice{
+		path:       dbus.ObjectPath(providerPath + "/" + name),
+		percentage: percentage,
+		device:     testDevicePath,
+		address:    "AA:BB:CC:DD:EE:FF",
+		source:     "LinuxPods",
+	}
+}
+
+// checkSignature checks that a signal body has the signature BlueZ expects and survives
+// encoding as a D-Bus message
+func checkSignature(t *testing.T, name string, want string, body ...interface{}) {
+	t.Helper()
+	if got := dbus.SignatureOf(body...).String(); got != want {
+		t.Fatalf("%s signature = %q, want %q", name, got, want)
+	}
+
+	msg := &dbus.Message{
+		Type: dbus.TypeSignal,
+		Headers: map[dbus.HeaderField]dbus.Variant{
+			dbus.FieldPath:      dbus.MakeVariant(dbus.ObjectPath(providerPath)),
+			dbus.FieldInterface: dbus.MakeVariant("org.freedesktop.DBus.ObjectManager"),
+			dbus.FieldMember:    dbus.MakeVariant(name),
+			dbus.FieldSignature: dbus.MakeVariant(dbus.SignatureOf(body...)),
+		},
+		Body: body,
+	}
+	var buf bytes.Buffer
+	if err := msg.EncodeTo(&buf, binary.LittleEndian); err != nil {
+		t.Fatalf("Encoding %s: %v", name, err)
+	}
+	decoded, err := dbus.DecodeMessage(&buf)
+	if err != nil {
+		t.Fatalf("Decoding %s: %v", name, err)
+	}
+	if got := dbus.SignatureOf(decoded.Body...).String(); got != want {
+		t.Fatalf("%s signature after decoding = %q, want %q", name, got, want)
+	}
+}
+
+// checkBatteryProperties checks that the properties are known BatteryProvider1 properties with
+// the D-Bus types BlueZ expects. If complete is true, all properties must be present (as
+// required for InterfacesAdded and GetManagedObjects).
+func checkBatteryProperties(t *testing.T, props map[string]dbus.Variant, complete bool) {
+	t.Helper()
+	for name, value := range props {
+		want, ok := batteryPropertySignatures[name]
+		if !ok {
+			t.Errorf("Unknown battery property %q", name)
+			continue
+		}
+		if got := value.Signature().String(); got != want {
+			t.Errorf("Type of battery property %q = %q, want %q", name, got, want)
+		}
+	}
+	if complete {
+		for name := range batteryPropertySignatures {
+			if _, ok := props[name]; !ok {
+				t.Errorf("Missing battery property %q", name)
+			}
+		}
+	}
+
+	// The device must be a valid BlueZ object path
+	if device, ok := props["Device"]; ok {
+		if path, ok := device.Value().(dbus.ObjectPath); !ok || !path.IsValid() {
+			t.Errorf("Invalid battery device path %v", device.Value())
+		}
+	}
+}
+
+// checkInterfaces checks the interfaces and properties of a battery object in InterfacesAdded
+// and GetManagedObjects
+func checkInterfaces(t *testing.T, interfaces map[string]map[string]dbus.Variant) {
+	t.Helper()
+	props, ok := interfaces[batteryProviderIface]
+	if !ok || len(interfaces) != 1 {
+		t.Fatalf("Battery object exposes %v, want exactly %s", interfaces, batteryProviderIface)
+	}
+	checkBatteryProperties(t, props, true)
+}
+
+func TestInterfacesAdded(t *testing.T) {
+	body := testBattery("dev_AA_BB_CC_DD_EE_FF", 84).interfacesAddedBody()
+	checkSignature(t, "InterfacesAdded", InterfacesAddedSignature, body...)
+	checkInterfaces(t, body[1].(map[string]map[string]dbus.Variant))
+}
+
+func TestPropertiesChanged(t *testing.T) {
+	body := testBattery("dev_AA_BB_CC_DD_EE_FF", 84).percentageChangedBody()
+	checkSignature(t, "PropertiesChanged", PropertiesChangedSignature, body...)
+
+	if iface := body[0].(string); iface != batteryProviderIface {
+		t.Errorf("PropertiesChanged interface = %q, want %q", iface, batteryProviderIface)
+	}
+	changes := body[1].(map[string]dbus.Variant)
+	checkBatteryProperties(t, changes, false)
+	if got := changes["Percentage"].Value(); got != uint8(84) {
+		t.Errorf("Percentage = %v, want 84", got)
+	}
+}
+
+func TestInterfacesRemoved(t *testing.T) {
+	body := testBattery("dev_AA_BB_CC_DD_EE_FF", 84).interfacesRemovedBody()
+	checkSignature(t, "InterfacesRemoved", InterfacesRemovedSignature, body...)
+
+	if interfaces := body[1].([]string); len(interfaces) != 1 || interfaces[0] != batteryProviderIface {
+		t.Errorf("InterfacesRemoved interfaces = %v, want [%s]", interfaces, batteryProviderIface)
+	}
+}
+
+func TestGetManagedObjects(t *testing.T) {
+	bp := &BluezBatteryProvider{devices: map[string]*BatteryDevice{
+		"dev_AA_BB_CC_DD_EE_FF": testBattery("dev_AA_BB_CC_DD_EE_FF", 84),
+		"dev_11_22_33_44_55_66": testBattery("dev_11_22_33_44_55_66", 36),
+	}}
+
+	objects, dbusErr := bp.GetManagedObjects()
+	if dbusErr != nil {
+		t.Fatalf("GetManagedObjects: %v", dbusErr)
+	}
+	checkSignature(t, "GetManagedObjects", ManagedObjectsSignature, objects)
+	if len(objects) != 2 {
+		t.Fatalf("GetManagedObjects returned %d objects, want 2", len(objects))
+	}
+	for path, interfaces := range objects {
+		if !path.IsValid() {
+			t.Errorf("Invalid battery object path %q", path)
+		}
+		checkInterfaces(t, interfaces)
+	}
+}
+
+func TestBatteryDeviceProperties(t *testing.T) {
+	battery := testBattery("dev_AA_BB_CC_DD_EE_FF", 84)
+
+	props, dbusErr := battery.GetAll(batteryProviderIface)
+	if dbusErr != nil {
+		t.Fatalf("GetAll: %v", dbusErr)
+	}
+	checkBatteryProperties(t, props, true)
+
+	for name, want := range batteryPropertySignatures {
+		value, dbusErr := battery.Get(batteryProviderIface, name)
+		if dbusErr != nil {
+			t.Errorf("Get(%q): %v", name, dbusErr)
+		} else if got := value.Signature().String(); got != want {
+			t.Errorf("Type of Get(%q) = %q, want %q", name, got, want)
+		}
+	}
+	if _, dbusErr := battery.Get(batteryProviderIface, "Unknown"); dbusErr == nil {
+		t.Error("Get of an unknown property succeeded")
+	}
+	if _, dbusErr := battery.GetAll("org.bluez.Device1"); dbusErr == nil {
+		t.Error("GetAll of another interface succeeded")
+	}
+}
@@ -0,0 +1,109 @@
+package bluez
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Timeouts of the org.bluez.Device1 calls. Pairing exchanges keys and takes longest.
+const (
+	connectTimeout    = 30 * time.Second
+	disconnectTimeout = 10 * time.Second
+	pairTimeout       = 60 * time.Second
+)
+
+// ConnectDevice connects a paired device via Bluetooth (org.bluez.Device1.Connect).
+// The AAP connection follows through the battery provider's connection callback.
+func ConnectDevice(macAddr string) error {
+	return callDevice(macAddr, "Connect", connectTimeout)
+}
+
+// DisconnectDevice disconnects a device from Bluetooth (org.bluez.Device1.Disconnect)
+func DisconnectDevice(macAddr string) error {
+	return callDevice(macAddr, "Disconnect", disconnectTimeout)
+}
+
+// PairDevice pairs with a device in pairing mode (org.bluez.Device1.Pair) and trusts it,
+// so it can reconnect on its own. The device must have been discovered, e.g. by
+// FindPairableDevice. Pairing an already paired device only trusts it.
+func PairDevice(macAddr string) error {
+	conn, devicePath, err := findDevice(macAddr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	unregister, err := registerAgent(conn, devicePath)
+	if err != nil {
+		return fmt.Errorf("failed to register pairing agent: %w", err)
+	}
+	defer unregister()
+
+	if err := call(conn, devicePath, "Pair", pairTimeout); err != nil {
+		var dbusErr dbus.Error
+		if !errors.As(err, &dbusErr) || dbusErr.Name != "org.bluez.Error.AlreadyExists" {
+			return err
+		}
+	}
+	obj := conn.Object(bluezService, devicePath)
+	if err := obj.SetProperty("org.bluez.Device1.Trusted", dbus.MakeVariant(true)); err != nil {
+		return fmt.Errorf("failed to trust %s: %w", macAddr, err)
+	}
+	return nil
+}
+
+// callDevice calls a method of the org.bluez.Device1 object of a device using a short-lived system bus connection
+func callDevice(macAddr string, method string, timeout time.Duration) error {
+	conn, devicePath, err := findDevice(macAddr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	return call(conn, devicePath, method, timeout)
+}
+
+// call calls a method without arguments of an org.bluez.Device1 object
+func call(conn *dbus.Conn, devicePath dbus.ObjectPath, method string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	obj := conn.Object(bluezService, devicePath)
+	if err := obj.CallWithContext(ctx, "org.bluez.Device1."+method, 0).Err; err != nil {
+		return fmt.Errorf("failed to %s %s: %w", strings.ToLower(method), devicePath, err)
+	}
+	return nil
+}
+
+// findDevice connects to the system bus and finds the BlueZ object of a device by its MAC address.
+// The caller must close the connection.
+func findDevice(macAddr string) (*dbus.Conn, dbus.ObjectPath, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	if err := conn.Object(bluezService, "/").Call("org.freedesktop.DBus.ObjectManager.GetManagedObjects", 0).Store(&objects); err != nil {
+		_ = conn.Close()
+		return nil, "", fmt.Errorf("failed to get managed objects: %w", err)
+	}
+
+	for path, interfaces := range objects {
+		props, ok := interfaces["org.bluez.Device1"]
+		if !ok {
+			continue
+		}
+		if address, _ := props["Address"].Value().(string); strings.EqualFold(address, macAddr) {
+			return conn, path, nil
+		}
+	}
+
+	_ = conn.Close()
+	return nil, "", fmt.Errorf("device %s not known to BlueZ", macAddr)
+}
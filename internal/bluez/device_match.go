@@ -0,0 +1,104 @@
+package bluez
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+
+	"linuxpods/internal/aap"
+)
+
+// appleModaliasPrefix starts the Modalias of devices using Apple's Bluetooth SIG vendor ID (0x004C).
+// The product ID that follows is the model code of the proximity pairing advertisements
+// with its bytes swapped, e.g. p2014 for model 0x1420.
+const appleModaliasPrefix = "bluetooth:v004Cp"
+
+// audioVideoMajorClass is the major device class of headphones and headsets (bits 8-12 of the Class of Device)
+const audioVideoMajorClass = 0x04
+
+// supportedAliasNames are lowercase name fragments of supported devices.
+// They are only used when BlueZ doesn't know the Modalias of a device.
+var supportedAliasNames = []string{"airpods", "beats"}
+
+// DeviceInfo holds the BlueZ properties of a device that identify supported devices
+type DeviceInfo struct {
+	Address  string
+	Alias    string
+	Modalias string
+	Class    uint32 // Class of Device, 0 if unknown
+}
+
+// DeviceInfoFromProperties reads the DeviceInfo from the properties of an org.bluez.Device1 object
+func DeviceInfoFromProperties(props map[string]dbus.Variant) DeviceInfo {
+	var info DeviceInfo
+	info.Address, _ = props["Address"].Value().(string)
+	info.Alias, _ = props["Alias"].Value().(string)
+	info.Modalias, _ = props["Modalias"].Value().(string)
+	info.Class, _ = props["Class"].Value().(uint32)
+	return info
+}
+
+// allowedAddresses returns the MAC addresses of LINUXPODS_DEVICES (comma-separated, uppercase).
+// They are treated as supported devices whatever their properties, e.g. for models that
+// BlueZ reports without a Modalias and under a custom name.
+var allowedAddresses = sync.OnceValue(func() map[string]bool {
+	addresses := make(map[string]bool)
+	for _, address := range strings.Split(os.Getenv("LINUXPODS_DEVICES"), ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses[strings.ToUpper(address)] = true
+		}
+	}
+	return addresses
+})
+
+// IsSupportedDevice reports whether a BlueZ device is a pair of AirPods or Beats headphones:
+//   - its address is in LINUXPODS_DEVICES
+//   - its Modalias has Apple's vendor ID and a known model, or another model with an
+//     audio device class (new models)
+//   - if BlueZ doesn't know the Modalias, its alias contains "AirPods" or "Beats" and its
+//     device class, if known, is audio
+//
+// Renamed devices are recognized by their Modalias.
+func IsSupportedDevice(info DeviceInfo) bool {
+	if allowedAddresses()[strings.ToUpper(info.Address)] {
+		return true
+	}
+
+	audioClass := info.Class == 0 || (info.Class>>8)&0x1F == audioVideoMajorClass
+	if info.Modalias != "" {
+		model, ok := modaliasModel(info.Modalias)
+		if !ok {
+			return false
+		}
+		if _, known := aap.LookupModel(model); known {
+			return true
+		}
+		return info.Class != 0 && audioClass
+	}
+
+	if !audioClass {
+		return false
+	}
+	lowerAlias := strings.ToLower(info.Alias)
+	for _, name := range supportedAliasNames {
+		if strings.Contains(lowerAlias, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// modaliasModel extracts the proximity pairing model code from an Apple Modalias
+func modaliasModel(modalias string) (uint16, bool) {
+	if !strings.HasPrefix(strings.ToUpper(modalias), strings.ToUpper(appleModaliasPrefix)) {
+		return 0, false
+	}
+	var product uint16
+	if _, err := fmt.Sscanf(modalias[len(appleModaliasPrefix):], "%04X", &product); err != nil {
+		return 0, false
+	}
+	return product<<8 | product>>8, true
+}
@@ -0,0 +1,109 @@
+package bluez
+
+import (
+	"encoding/xml"
+	"sort"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+// objectManagerIntrospection describes org.freedesktop.DBus.ObjectManager of the provider root
+var objectManagerIntrospection = introspect.Interface{
+	Name: "org.freedesktop.DBus.ObjectManager",
+	Methods: []introspect.Method{
+		{Name: "GetManagedObjects", Args: []introspect.Arg{
+			{Name: "objects", Type: ManagedObjectsSignature, Direction: "out"},
+		}},
+	},
+	Signals: []introspect.Signal{
+		{Name: "InterfacesAdded", Args: []introspect.Arg{
+			{Name: "object_path", Type: "o"},
+			{Name: "interfaces_and_properties", Type: "a{sa{sv}}"},
+		}},
+		{Name: "InterfacesRemoved", Args: []introspect.Arg{
+			{Name: "object_path", Type: "o"},
+			{Name: "interfaces", Type: "as"},
+		}},
+	},
+}
+
+// propertiesIntrospection describes org.freedesktop.DBus.Properties as implemented by BatteryDevice
+var propertiesIntrospection = introspect.Interface{
+	Name: "org.freedesktop.DBus.Properties",
+	Methods: []introspect.Method{
+		{Name: "Get", Args: []introspect.Arg{
+			{Name: "interface_name", Type: "s", Direction: "in"},
+			{Name: "property_name", Type: "s", Direction: "in"},
+			{Name: "value", Type: "v", Direction: "out"},
+		}},
+		{Name: "GetAll", Args: []introspect.Arg{
+			{Name: "interface_name", Type: "s", Direction: "in"},
+			{Name: "properties", Type: "a{sv}", Direction: "out"},
+		}},
+		{Name: "Set", Args: []introspect.Arg{
+			{Name: "interface_name", Type: "s", Direction: "in"},
+			{Name: "property_name", Type: "s", Direction: "in"},
+			{Name: "value", Type: "v", Direction: "in"},
+		}},
+	},
+}
+
+// batteryProviderIntrospection describes org.bluez.BatteryProvider1, generated from batteryPropertySignatures
+func batteryProviderIntrospection() introspect.Interface {
+	names := make([]string, 0, len(batteryPropertySignatures))
+	for name := range batteryPropertySignatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	iface := introspect.Interface{Name: batteryProviderIface}
+	for _, name := range names {
+		iface.Properties = append(iface.Properties, introspect.Property{
+			Name:   name,
+			Type:   batteryPropertySignatures[name],
+			Access: "read",
+		})
+	}
+	return iface
+}
+
+// batteryNode returns the introspection data of a battery object
+func batteryNode() *introspect.Node {
+	return &introspect.Node{
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			propertiesIntrospection,
+			batteryProviderIntrospection(),
+		},
+	}
+}
+
+// providerIntrospectable implements org.freedesktop.DBus.Introspectable for the provider root.
+// The data is generated on each call, so it lists the current batteries as child nodes.
+type providerIntrospectable struct {
+	bp *BluezBatteryProvider
+}
+
+// Introspect implements org.freedesktop.DBus.Introspectable.Introspect
+func (pi providerIntrospectable) Introspect() (string, *dbus.Error) {
+	node := introspect.Node{
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			objectManagerIntrospection,
+		},
+	}
+
+	pi.bp.mu.RLock()
+	for name := range pi.bp.devices {
+		node.Children = append(node.Children, introspect.Node{Name: name})
+	}
+	pi.bp.mu.RUnlock()
+	sort.Slice(node.Children, func(i, j int) bool { return node.Children[i].Name < node.Children[j].Name })
+
+	data, err := xml.Marshal(node)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return introspect.IntrospectDeclarationString + string(data), nil
+}
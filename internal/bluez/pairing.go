@@ -0,0 +1,139 @@
+package bluez
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// PairableDevice is an unpaired supported device found by FindPairableDevice
+type PairableDevice struct {
+	Path    dbus.ObjectPath
+	Address string
+	Name    string
+}
+
+// FindPairableDevice discovers Bluetooth devices until it finds AirPods in pairing mode
+// (case open, button on the back held until the light flashes white) or ctx is done.
+// It uses a short-lived system bus connection and stops discovering when it returns.
+func FindPairableDevice(ctx context.Context) (PairableDevice, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return PairableDevice{}, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// Devices appearing, and known devices being seen again or resolving their name
+	if err := conn.AddMatchSignal(dbus.WithMatchSender(bluezService), dbus.WithMatchInterface("org.freedesktop.DBus.ObjectManager")); err != nil {
+		return PairableDevice{}, fmt.Errorf("failed to add match rule: %w", err)
+	}
+	if err := conn.AddMatchSignal(dbus.WithMatchSender(bluezService), dbus.WithMatchInterface("org.freedesktop.DBus.Properties")); err != nil {
+		return PairableDevice{}, fmt.Errorf("failed to add match rule: %w", err)
+	}
+	signals := make(chan *dbus.Signal, 32)
+	conn.Signal(signals)
+
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	if err := conn.Object(bluezService, "/").Call("org.freedesktop.DBus.ObjectManager.GetManagedObjects", 0).Store(&objects); err != nil {
+		return PairableDevice{}, fmt.Errorf("failed to get managed objects: %w", err)
+	}
+	var adapter dbus.ObjectPath
+	for path, interfaces := range objects {
+		if _, ok := interfaces[adapterIface]; ok && (adapter == "" || path < adapter) {
+			adapter = path
+		}
+	}
+	if adapter == "" {
+		return PairableDevice{}, fmt.Errorf("no Bluetooth adapter found")
+	}
+
+	// AirPods are paired over BR/EDR. BlueZ merges the filter with the LE filter of the BLE scanner.
+	adapterObj := conn.Object(bluezService, adapter)
+	filter := map[string]interface{}{"Transport": "bredr"}
+	if err := adapterObj.Call(adapterIface+".SetDiscoveryFilter", 0, filter).Err; err != nil {
+		return PairableDevice{}, fmt.Errorf("failed to set discovery filter: %w", err)
+	}
+	if err := adapterObj.Call(adapterIface+".StartDiscovery", 0).Err; err != nil {
+		return PairableDevice{}, fmt.Errorf("failed to start discovery: %w", err)
+	}
+	defer func() { _ = adapterObj.Call(adapterIface+".StopDiscovery", 0).Err }()
+
+	for path, interfaces := range objects {
+		if device, ok := pairableDevice(path, interfaces["org.bluez.Device1"]); ok {
+			return device, nil
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return PairableDevice{}, fmt.Errorf("no AirPods in pairing mode found")
+
+		case signal := <-signals:
+			var path dbus.ObjectPath
+			switch signal.Name {
+			case "org.freedesktop.DBus.ObjectManager.InterfacesAdded":
+				if len(signal.Body) > 0 {
+					path, _ = signal.Body[0].(dbus.ObjectPath)
+				}
+			case "org.freedesktop.DBus.Properties.PropertiesChanged":
+				if len(signal.Body) > 0 && signal.Body[0] == "org.bluez.Device1" {
+					path = signal.Path
+				}
+			}
+			if path == "" {
+				continue
+			}
+
+			var props map[string]dbus.Variant
+			if err := conn.Object(bluezService, path).Call("org.freedesktop.DBus.Properties.GetAll", 0, "org.bluez.Device1").Store(&props); err != nil {
+				continue
+			}
+			if device, ok := pairableDevice(path, props); ok {
+				return device, nil
+			}
+		}
+	}
+}
+
+// pairableDevice reports whether a device is a supported device that is not paired yet and was
+// seen by the current discovery (BlueZ only reports an RSSI for devices in range)
+func pairableDevice(path dbus.ObjectPath, props map[string]dbus.Variant) (PairableDevice, bool) {
+	if props == nil {
+		return PairableDevice{}, false
+	}
+	paired, _ := props["Paired"].Value().(bool)
+	_, inRange := props["RSSI"]
+	info := DeviceInfoFromProperties(props)
+	if paired || !inRange || !IsSupportedDevice(info) {
+		return PairableDevice{}, false
+	}
+	return PairableDevice{Path: path, Address: info.Address, Name: info.Alias}, true
+}
+
+// HasPairedAirPods reports whether AirPods or Beats are paired to this machine,
+// using a short-lived system bus connection
+func HasPairedAirPods() (bool, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	if err := conn.Object(bluezService, "/").Call("org.freedesktop.DBus.ObjectManager.GetManagedObjects", 0).Store(&objects); err != nil {
+		return false, fmt.Errorf("failed to get managed objects: %w", err)
+	}
+
+	for _, interfaces := range objects {
+		props, ok := interfaces["org.bluez.Device1"]
+		if !ok {
+			continue
+		}
+		if paired, _ := props["Paired"].Value().(bool); paired && IsSupportedDevice(DeviceInfoFromProperties(props)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
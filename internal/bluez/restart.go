@@ -0,0 +1,91 @@
+package bluez
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/godbus/dbus/v5"
+
+	"linuxpods/internal/retry"
+)
+
+// bluezOwnerMatchRule receives the NameOwnerChanged signals of BlueZ starting or stopping
+const bluezOwnerMatchRule = "type='signal',sender='org.freedesktop.DBus',interface='org.freedesktop.DBus',member='NameOwnerChanged',arg0='org.bluez'"
+
+// handleBluezRestart registers the provider again after bluetoothd restarted, which forgets
+// all registrations. It reports whether the signal was a NameOwnerChanged signal.
+func (bp *BluezBatteryProvider) handleBluezRestart(signal *dbus.Signal) bool {
+	if signal.Name != "org.freedesktop.DBus.NameOwnerChanged" {
+		return false
+	}
+	// Body: name, old owner, new owner. An empty new owner means BlueZ stopped.
+	if len(signal.Body) < 3 {
+		return true
+	}
+	name, _ := signal.Body[0].(string)
+	newOwner, _ := signal.Body[2].(string)
+	if name != bluezService {
+		return true
+	}
+
+	bp.mu.Lock()
+	bp.adapter = ""
+	bp.mu.Unlock()
+
+	if newOwner == "" {
+		log.Printf("BlueZ stopped, waiting for it to restart")
+		return true
+	}
+	log.Printf("BlueZ restarted, registering the battery provider again")
+	go bp.reregister()
+	return true
+}
+
+// reregister exports and registers the provider and all batteries again.
+// Batteries of devices that no longer exist are removed, they are added again when the
+// device connects.
+func (bp *BluezBatteryProvider) reregister() {
+	if err := bp.exportProvider(); err != nil {
+		log.Printf("Failed to export battery provider: %v", err)
+		return
+	}
+
+	// BlueZ needs a moment to load its adapters after starting
+	err := retry.Do(context.Background(), registerBackoff, func(ctx context.Context) error {
+		objects, err := bp.managedObjects()
+		if err != nil {
+			return err
+		}
+		adapter := selectAdapter(objects)
+		if adapter == "" {
+			return fmt.Errorf("no Bluetooth adapter found")
+		}
+		return bp.useAdapter(adapter)
+	})
+	if err != nil {
+		log.Printf("Failed to register battery provider after BlueZ restart: %v", err)
+		return
+	}
+
+	bp.mu.RLock()
+	batteries := make(map[string]BatteryDevice, len(bp.devices))
+	for name, device := range bp.devices {
+		batteries[name] = *device
+	}
+	bp.mu.RUnlock()
+
+	for name, battery := range batteries {
+		if err := bp.RemoveBattery(name); err != nil {
+			log.Printf("Failed to remove battery %s: %v", name, err)
+		}
+		if _, err := bp.GetDeviceAddress(string(battery.device)); err != nil {
+			log.Printf("Device %s of battery %s is gone, waiting for it to connect", battery.device, name)
+			continue
+		}
+		if err := bp.AddBattery(name, battery.percentage, string(battery.device)); err != nil {
+			log.Printf("Failed to restore battery %s: %v", name, err)
+		}
+	}
+	log.Printf("Battery provider restored after BlueZ restart (%d batteries)", len(batteries))
+}
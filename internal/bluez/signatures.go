@@ -0,0 +1,26 @@
+package bluez
+
+// D-Bus signatures BlueZ expects for the provider's signals and properties.
+// A mismatch does not produce an error on the bus - BlueZ silently ignores the battery,
+// so it never shows up in GNOME Settings. battery_provider_test.go checks the payloads
+// against these.
+const (
+	// InterfacesAddedSignature is the signature of ObjectManager.InterfacesAdded (object path, interfaces and properties)
+	InterfacesAddedSignature = "oa{sa{sv}}"
+
+	// InterfacesRemovedSignature is the signature of ObjectManager.InterfacesRemoved (object path, interfaces)
+	InterfacesRemovedSignature = "oas"
+
+	// PropertiesChangedSignature is the signature of Properties.PropertiesChanged (interface, changed, invalidated)
+	PropertiesChangedSignature = "sa{sv}as"
+
+	// ManagedObjectsSignature is the signature of the GetManagedObjects reply
+	ManagedObjectsSignature = "a{oa{sa{sv}}}"
+)
+
+// batteryPropertySignatures maps each org.bluez.BatteryProvider1 property to its D-Bus type
+var batteryPropertySignatures = map[string]string{
+	"Percentage": "y",
+	"Device":     "o",
+	"Source":     "s",
+}
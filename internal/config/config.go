@@ -0,0 +1,467 @@
+// Package config loads the LinuxPods configuration file, by default
+// $XDG_CONFIG_HOME/linuxpods/config.toml (~/.config/linuxpods/config.toml).
+//
+// The file is optional, missing and invalid keys keep their defaults. Environment variables
+// (LINUXPODS_*) override the file. Example:
+//
+//	[scan]
+//	update_interval = "3s"         # Minimum time between BLE updates of a device
+//	fast_update_interval = "250ms" # The same during fast scan bursts
+//	fast_scan = true               # Update quickly after the case is opened or the AirPods connect
+//	stale_after = "30s"            # Show battery data as outdated without updates
+//	show_foreign = false           # Also show AirPods that are not paired to this machine
+//	battery_smoothing = 5          # BLE readings the shown level is the median of (1 disables)
+//	mode = "continuous"            # continuous, or adaptive to scan less while no window is open
+//	scan_window = "5s"             # How long each scan runs, unless scanning continuously
+//	scan_pause = "3s"              # Adaptive: pause between scans while a window is open or a battery is low
+//	idle_pause = "60s"             # Adaptive: pause between scans otherwise
+//	suspended_pause = "5s"         # Continuous: pause between scans while the AirPods are suspended in the closed case
+//	pause_on_power_saver = true    # Adaptive: don't scan while the power saver is on
+//
+//	[bluetooth]
+//	adapter = "hci0"               # Adapter for BLE scanning (restart required)
+//	suspend_in_case = true         # Close the AAP connection while the AirPods are in the closed case
+//
+//	[gnome_settings]
+//	battery = "lowest"             # Level shown in GNOME Settings: lowest, left, right, case or average
+//
+//	[notifications]
+//	enabled = false                # Notify about low batteries
+//	low_battery = 20               # Notify when a battery drops below this level (percent)
+//	device_switched = true         # Notify when the AirPods switch to another device
+//	left_behind = true             # Notify when the AirPods seem to have been left behind
+//
+//	[tray]
+//	mode = "auto"                  # auto, tray, window or off (restart required)
+//	close_to_tray = true           # Hide the window when it is closed while there is a tray or mini window
+//	icon_style = "auto"            # auto (by the desktop's color scheme), color, light or dark
+//
+//	[debug]
+//	page = false                   # Show the Debug tab with live BLE and AAP packets (or start with --debug)
+//
+//	[media]
+//	pause_on_ear_removal = true    # Pause media players when both AirPods are taken out
+//	resume_on_ear_insertion = true # Resume them when an AirPod is put back in
+//
+//	[audio]
+//	switch_output = true           # Make the AirPods the default output when they connect
+//	remember_volume = true         # Restore the last volume of each device when it connects
+//	switch_profile = false         # Use the headset profile while an application records, then switch back
+//
+//	[state_log]
+//	format = "off"                 # off, csv (append rows to path) or journal (write them to the log)
+//	path = ""                      # CSV file, ~/.local/share/linuxpods/state-log.csv if empty
+//	interval = "1m"                # Time between two entries
+//
+//	[aliases]
+//	"AA:BB:CC:DD:EE:FF" = "Work AirPods"
+//
+//	[auto_connect]
+//	"AA:BB:CC:DD:EE:FF" = false    # Don't open the AAP connection when the device connects
+//
+//	[ignored]
+//	"11:22:33:44:55:66" = true     # Don't show or connect to the device
+//
+// LINUXPODS_STATE_LOG (csv:PATH, journal or off) overrides the state log format and path.
+// The aliases, auto_connect and ignored sections are the per-device settings (see Device).
+// The daemon and the GUI reload the file when it changes.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Scan modes
+const (
+	ScanModeContinuous = "continuous"
+	ScanModeAdaptive   = "adaptive"
+)
+
+// Sections of the per-device settings, keyed by MAC address
+const (
+	AliasesSection     = "aliases"
+	AutoConnectSection = "auto_connect"
+	IgnoredSection     = "ignored"
+)
+
+// State log formats
+const (
+	StateLogOff     = "off"
+	StateLogCSV     = "csv"
+	StateLogJournal = "journal"
+)
+
+// GNOME Settings battery choices
+const (
+	BatteryLowest  = "lowest"
+	BatteryLeft    = "left"
+	BatteryRight   = "right"
+	BatteryCase    = "case"
+	BatteryAverage = "average"
+)
+
+// Config is the LinuxPods configuration. Zero durations and a zero smoothing window
+// select the coordinator's defaults.
+type Config struct {
+	Scan          ScanConfig
+	Bluetooth     BluetoothConfig
+	GNOMESettings GNOMESettingsConfig
+	Notifications NotificationsConfig
+	Tray          TrayConfig
+	Debug         DebugConfig
+	Media         MediaConfig
+	Audio         AudioConfig
+	StateLog      StateLogConfig
+
+	// Aliases are names for devices by MAC address (uppercase), shown instead of the model name
+	Aliases map[string]string
+	// AutoConnect selects by MAC address (uppercase) whether the AAP connection is opened when
+	// a device connects. Devices that are not listed connect.
+	AutoConnect map[string]bool
+	// Ignored are the devices by MAC address (uppercase) that are neither shown nor connected to
+	Ignored map[string]bool
+}
+
+// DeviceConfig are the settings of a single device
+type DeviceConfig struct {
+	Alias       string
+	AutoConnect bool
+	Ignored     bool
+}
+
+// Device returns the settings of a device
+func (c Config) Device(macAddr string) DeviceConfig {
+	macAddr = strings.ToUpper(macAddr)
+	autoConnect, ok := c.AutoConnect[macAddr]
+	return DeviceConfig{
+		Alias:       c.Aliases[macAddr],
+		AutoConnect: autoConnect || !ok,
+		Ignored:     c.Ignored[macAddr],
+	}
+}
+
+// Devices returns the settings of all devices that have any, by MAC address (uppercase)
+func (c Config) Devices() map[string]DeviceConfig {
+	devices := make(map[string]DeviceConfig)
+	for _, section := range []map[string]bool{c.AutoConnect, c.Ignored} {
+		for macAddr := range section {
+			devices[macAddr] = c.Device(macAddr)
+		}
+	}
+	for macAddr := range c.Aliases {
+		devices[macAddr] = c.Device(macAddr)
+	}
+	return devices
+}
+
+// ScanConfig configures BLE scanning and how its data is shown
+type ScanConfig struct {
+	UpdateInterval     time.Duration
+	FastUpdateInterval time.Duration
+	FastScan           bool
+	StaleAfter         time.Duration
+	ShowForeign        bool
+	BatterySmoothing   int
+
+	Mode              string // One of the ScanMode* choices
+	ScanWindow        time.Duration
+	ScanPause         time.Duration
+	IdlePause         time.Duration
+	SuspendedPause    time.Duration
+	PauseOnPowerSaver bool
+}
+
+// BluetoothConfig selects the Bluetooth adapter and how the connection is used
+type BluetoothConfig struct {
+	Adapter       string // e.g. hci0, "" for the default adapter
+	SuspendInCase bool
+}
+
+// GNOMESettingsConfig configures the battery reported to GNOME Settings via BlueZ
+type GNOMESettingsConfig struct {
+	Battery string // One of the Battery* choices
+}
+
+// NotificationsConfig configures desktop notifications
+type NotificationsConfig struct {
+	Enabled        bool // Low battery notifications
+	LowBattery     int  // Percent
+	DeviceSwitched bool
+	LeftBehind     bool
+}
+
+// TrayConfig configures the system tray
+type TrayConfig struct {
+	Mode        string // auto, tray, window or off
+	CloseToTray bool
+	IconStyle   string // auto, color, light (for light panels) or dark (for dark panels)
+}
+
+// DebugConfig configures the tools for developers in the GUI
+type DebugConfig struct {
+	Page bool
+}
+
+// MediaConfig configures the control of media players by ear detection (MPRIS)
+type MediaConfig struct {
+	PauseOnEarRemoval    bool
+	ResumeOnEarInsertion bool
+}
+
+// AudioConfig configures the audio output switching to the AirPods (PipeWire or PulseAudio)
+type AudioConfig struct {
+	SwitchOutput   bool
+	RememberVolume bool
+	SwitchProfile  bool
+}
+
+// StateLogConfig configures the periodic state log
+type StateLogConfig struct {
+	Format   string        // One of the StateLog* formats
+	Path     string        // CSV file, "" for the default file
+	Interval time.Duration // Time between entries
+}
+
+// Default returns the configuration used without a config file
+func Default() Config {
+	return Config{
+		Scan:          ScanConfig{FastScan: true, Mode: ScanModeContinuous, PauseOnPowerSaver: true},
+		Bluetooth:     BluetoothConfig{SuspendInCase: true},
+		GNOMESettings: GNOMESettingsConfig{Battery: BatteryLowest},
+		Notifications: NotificationsConfig{LowBattery: 20, DeviceSwitched: true, LeftBehind: true},
+		Tray:          TrayConfig{Mode: "auto", CloseToTray: true, IconStyle: "auto"},
+		Media:         MediaConfig{PauseOnEarRemoval: true, ResumeOnEarInsertion: true},
+		Audio:         AudioConfig{SwitchOutput: true, RememberVolume: true},
+		StateLog:      StateLogConfig{Format: StateLogOff, Interval: time.Minute},
+		Aliases:       map[string]string{},
+		AutoConnect:   map[string]bool{},
+		Ignored:       map[string]bool{},
+	}
+}
+
+// Path returns the path of the config file: LINUXPODS_CONFIG, or config.toml in the
+// linuxpods directory of the user's config directory
+func Path() string {
+	if path := os.Getenv("LINUXPODS_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "linuxpods", "config.toml")
+}
+
+// Load reads the config file. A missing file yields the default configuration.
+// Invalid lines and values are skipped and keep their defaults, so a typo doesn't reset the
+// other settings. They are returned as Warnings (wrapped) together with the configuration.
+func Load(path string) (Config, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Default(), nil
+	}
+	if err != nil {
+		return Default(), fmt.Errorf("failed to open config: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	doc, err := parseTOML(file)
+	var warnings Warnings
+	if err != nil && !errors.As(err, &warnings) {
+		return Default(), fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	cfg, decodeWarnings := decode(doc)
+	warnings = append(warnings, decodeWarnings...)
+	if len(warnings) > 0 {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, warnings)
+	}
+	return cfg, nil
+}
+
+// LoadOrDefault reads the config file, logging errors. Invalid values keep their defaults.
+func LoadOrDefault(path string) Config {
+	cfg, err := Load(path)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return cfg
+}
+
+// decode fills a configuration from a parsed document. Invalid values are skipped and
+// returned as warnings.
+func decode(doc document) (Config, Warnings) {
+	cfg := Default()
+	d := decoder{doc: doc}
+
+	d.duration("scan", "update_interval", &cfg.Scan.UpdateInterval)
+	d.duration("scan", "fast_update_interval", &cfg.Scan.FastUpdateInterval)
+	d.bool("scan", "fast_scan", &cfg.Scan.FastScan)
+	d.duration("scan", "stale_after", &cfg.Scan.StaleAfter)
+	d.bool("scan", "show_foreign", &cfg.Scan.ShowForeign)
+	d.int("scan", "battery_smoothing", &cfg.Scan.BatterySmoothing, 0, 100)
+	d.string("scan", "mode", &cfg.Scan.Mode, ScanModeContinuous, ScanModeAdaptive)
+	d.duration("scan", "scan_window", &cfg.Scan.ScanWindow)
+	d.duration("scan", "scan_pause", &cfg.Scan.ScanPause)
+	d.duration("scan", "idle_pause", &cfg.Scan.IdlePause)
+	d.duration("scan", "suspended_pause", &cfg.Scan.SuspendedPause)
+	d.bool("scan", "pause_on_power_saver", &cfg.Scan.PauseOnPowerSaver)
+
+	d.string("bluetooth", "adapter", &cfg.Bluetooth.Adapter)
+	d.bool("bluetooth", "suspend_in_case", &cfg.Bluetooth.SuspendInCase)
+
+	d.string("gnome_settings", "battery", &cfg.GNOMESettings.Battery,
+		BatteryLowest, BatteryLeft, BatteryRight, BatteryCase, BatteryAverage)
+
+	d.bool("notifications", "enabled", &cfg.Notifications.Enabled)
+	d.int("notifications", "low_battery", &cfg.Notifications.LowBattery, 1, 100)
+	d.bool("notifications", "device_switched", &cfg.Notifications.DeviceSwitched)
+	d.bool("notifications", "left_behind", &cfg.Notifications.LeftBehind)
+
+	d.string("tray", "mode", &cfg.Tray.Mode, "auto", "tray", "window", "off")
+	d.bool("tray", "close_to_tray", &cfg.Tray.CloseToTray)
+	d.string("tray", "icon_style", &cfg.Tray.IconStyle, "auto", "color", "light", "dark")
+
+	d.bool("debug", "page", &cfg.Debug.Page)
+
+	d.bool("media", "pause_on_ear_removal", &cfg.Media.PauseOnEarRemoval)
+	d.bool("media", "resume_on_ear_insertion", &cfg.Media.ResumeOnEarInsertion)
+
+	d.bool("audio", "switch_output", &cfg.Audio.SwitchOutput)
+	d.bool("audio", "remember_volume", &cfg.Audio.RememberVolume)
+	d.bool("audio", "switch_profile", &cfg.Audio.SwitchProfile)
+
+	d.string("state_log", "format", &cfg.StateLog.Format, StateLogOff, StateLogCSV, StateLogJournal)
+	d.string("state_log", "path", &cfg.StateLog.Path)
+	d.duration("state_log", "interval", &cfg.StateLog.Interval)
+
+	for key, v := range doc[AliasesSection] {
+		name, ok := v.v.(string)
+		if !ok {
+			d.fail(v, "alias of %s must be a string", key)
+			continue
+		}
+		cfg.Aliases[strings.ToUpper(key)] = name
+	}
+	d.deviceBools(AutoConnectSection, cfg.AutoConnect)
+	d.deviceBools(IgnoredSection, cfg.Ignored)
+
+	// Unknown keys are most likely typos, but a newer version's keys shouldn't break older ones.
+	// The keys of the per-device sections are MAC addresses.
+	for section, keys := range doc {
+		if section == AliasesSection || section == AutoConnectSection || section == IgnoredSection {
+			continue
+		}
+		for key, v := range keys {
+			if !d.used[section][key] {
+				name := key
+				if section != "" {
+					name = section + "." + key
+				}
+				log.Printf("Warning: config line %d: unknown key %s", v.line, name)
+			}
+		}
+	}
+
+	return cfg, d.warnings
+}
+
+// decoder decodes typed values from a document, collecting the invalid values
+type decoder struct {
+	doc      document
+	used     map[string]map[string]bool
+	warnings Warnings
+}
+
+// get returns the value of a key and marks it as used
+func (d *decoder) get(section string, key string) (value, bool) {
+	if d.used == nil {
+		d.used = make(map[string]map[string]bool)
+	}
+	if d.used[section] == nil {
+		d.used[section] = make(map[string]bool)
+	}
+	d.used[section][key] = true
+	v, ok := d.doc[section][key]
+	return v, ok
+}
+
+// fail records a warning for the value's line
+func (d *decoder) fail(v value, format string, args ...interface{}) {
+	d.warnings = append(d.warnings, fmt.Errorf("line %d: %s", v.line, fmt.Sprintf(format, args...)))
+}
+
+func (d *decoder) bool(section string, key string, target *bool) {
+	if v, ok := d.get(section, key); ok {
+		if b, ok := v.v.(bool); ok {
+			*target = b
+		} else {
+			d.fail(v, "%s.%s must be true or false", section, key)
+		}
+	}
+}
+
+func (d *decoder) int(section string, key string, target *int, min int, max int) {
+	if v, ok := d.get(section, key); ok {
+		n, ok := v.v.(int64)
+		if !ok || n < int64(min) || n > int64(max) {
+			d.fail(v, "%s.%s must be an integer from %d to %d", section, key, min, max)
+			return
+		}
+		*target = int(n)
+	}
+}
+
+func (d *decoder) duration(section string, key string, target *time.Duration) {
+	if v, ok := d.get(section, key); ok {
+		s, ok := v.v.(string)
+		duration, err := time.ParseDuration(s)
+		if !ok || err != nil || duration <= 0 {
+			d.fail(v, "%s.%s must be a positive duration such as \"30s\"", section, key)
+			return
+		}
+		*target = duration
+	}
+}
+
+// deviceBools decodes a per-device section of booleans keyed by MAC address
+func (d *decoder) deviceBools(section string, target map[string]bool) {
+	for key, v := range d.doc[section] {
+		b, ok := v.v.(bool)
+		if !ok {
+			d.fail(v, "%s of %s must be true or false", section, key)
+			continue
+		}
+		target[strings.ToUpper(key)] = b
+	}
+}
+
+// string decodes a string, which must be one of choices if any are given
+func (d *decoder) string(section string, key string, target *string, choices ...string) {
+	v, ok := d.get(section, key)
+	if !ok {
+		return
+	}
+	s, ok := v.v.(string)
+	if !ok {
+		d.fail(v, "%s.%s must be a string", section, key)
+		return
+	}
+	if len(choices) > 0 {
+		valid := false
+		for _, choice := range choices {
+			valid = valid || s == choice
+		}
+		if !valid {
+			d.fail(v, "%s.%s must be one of %s", section, key, strings.Join(choices, ", "))
+			return
+		}
+	}
+	*target = s
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadSkipsInvalidValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `[scan]
+update_interval = "soon"   # Invalid, keeps the default
+stale_after = "45s"
+unknown_key = 1            # Unknown keys are only logged
+
+[notifications]
+low_battery = 30
+
+[aliases]
+"AA:BB:CC:DD:EE:FF" = "Work AirPods"
+
+[ignored]
+"11:22:33:44:55:66" = "yes" # Invalid
+"11:22:33:44:55:77" = true
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	var warnings Warnings
+	if !errors.As(err, &warnings) || len(warnings) != 2 {
+		t.Fatalf("Load error = %v, want 2 warnings", err)
+	}
+
+	if cfg.Scan.UpdateInterval != Default().Scan.UpdateInterval {
+		t.Errorf("UpdateInterval = %s, want the default", cfg.Scan.UpdateInterval)
+	}
+	if cfg.Scan.StaleAfter != 45*time.Second {
+		t.Errorf("StaleAfter = %s, want 45s", cfg.Scan.StaleAfter)
+	}
+	if cfg.Notifications.LowBattery != 30 {
+		t.Errorf("LowBattery = %d, want 30", cfg.Notifications.LowBattery)
+	}
+	if alias := cfg.Device("aa:bb:cc:dd:ee:ff").Alias; alias != "Work AirPods" {
+		t.Errorf("Alias = %q, want Work AirPods", alias)
+	}
+	if !cfg.Device("11:22:33:44:55:77").Ignored || cfg.Device("11:22:33:44:55:66").Ignored {
+		t.Errorf("Ignored = %v, want only 11:22:33:44:55:77", cfg.Ignored)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing.toml"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Tray.Mode != Default().Tray.Mode || cfg.StateLog.Format != StateLogOff {
+		t.Errorf("Load of a missing file = %+v, want the defaults", cfg)
+	}
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// value is a parsed value with the line it was defined on
+type value struct {
+	v    interface{} // string, int64 or bool
+	line int
+}
+
+// document maps section name -> key -> value. Keys before the first section are in "".
+type document map[string]map[string]value
+
+// Warnings are the problems of a config file that were skipped, one per line or key
+type Warnings []error
+
+func (w Warnings) Error() string {
+	messages := make([]string, len(w))
+	for i, err := range w {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// parseTOML parses the subset of TOML the config file needs: [section] headers,
+// key = value pairs with bare or quoted keys, and string, integer and boolean values.
+// Arrays, tables in tables, floats and dates are not supported.
+//
+// Invalid lines are skipped (and the keys of an invalid section), so the rest of the file
+// still applies. They are returned as Warnings together with the document.
+func parseTOML(r io.Reader) (document, error) {
+	doc := document{"": {}}
+	section := ""
+	skipSection := false // Keys of an invalid or duplicate section header are skipped
+	var warnings Warnings
+	warn := func(line int, format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...)))
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(stripComment(scanner.Text()))
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") {
+			skipSection = true
+			if !strings.HasSuffix(text, "]") {
+				warn(line, "invalid section header %q", text)
+				continue
+			}
+			name := strings.TrimSpace(text[1 : len(text)-1])
+			if name == "" || strings.ContainsAny(name, "[]\"") {
+				warn(line, "invalid section name %q", name)
+				continue
+			}
+			if _, exists := doc[name]; exists {
+				warn(line, "duplicate section [%s]", name)
+				continue
+			}
+			section, skipSection = name, false
+			doc[section] = map[string]value{}
+			continue
+		}
+		if skipSection {
+			continue
+		}
+
+		key, rest, err := parseKey(text)
+		if err != nil {
+			warn(line, "%v", err)
+			continue
+		}
+		rest = strings.TrimSpace(rest)
+		if !strings.HasPrefix(rest, "=") {
+			warn(line, "expected '=' after key %q", key)
+			continue
+		}
+		v, err := parseValue(strings.TrimSpace(rest[1:]))
+		if err != nil {
+			warn(line, "%v", err)
+			continue
+		}
+		if _, exists := doc[section][key]; exists {
+			warn(line, "duplicate key %q", key)
+			continue
+		}
+		doc[section][key] = value{v: v, line: line}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(warnings) > 0 {
+		return doc, warnings
+	}
+	return doc, nil
+}
+
+// stripComment removes a # comment that is not inside a string
+func stripComment(line string) string {
+	inString, escaped := false, false
+	for i, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && inString:
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case c == '#' && !inString:
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseKey parses a bare or quoted key at the start of text and returns it with the remaining text
+func parseKey(text string) (string, string, error) {
+	if strings.HasPrefix(text, "\"") {
+		end := strings.Index(text[1:], "\"")
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quoted key")
+		}
+		return text[1 : end+1], text[end+2:], nil
+	}
+
+	end := strings.IndexFunc(text, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-')
+	})
+	if end == 0 {
+		return "", "", fmt.Errorf("expected a key")
+	}
+	if end < 0 {
+		end = len(text)
+	}
+	return text[:end], text[end:], nil
+}
+
+// parseValue parses a string, integer or boolean value
+func parseValue(text string) (interface{}, error) {
+	switch {
+	case text == "":
+		return nil, fmt.Errorf("missing value")
+	case text == "true":
+		return true, nil
+	case text == "false":
+		return false, nil
+	case strings.HasPrefix(text, "\""):
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", text)
+		}
+		return s, nil
+	}
+
+	n, err := strconv.ParseInt(strings.ReplaceAll(text, "_", ""), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported value %s (expected a string, integer or boolean)", text)
+	}
+	return n, nil
+}
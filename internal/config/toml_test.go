@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		want     map[string]map[string]interface{}
+		warnings []string // Lines with warnings, e.g. "line 2"
+	}{
+		{
+			name:  "sections and types",
+			input: "top = 1\n[scan]\nfast_scan = true\nmode = \"adaptive\"\nbattery_smoothing = 1_000\n",
+			want: map[string]map[string]interface{}{
+				"":     {"top": int64(1)},
+				"scan": {"fast_scan": true, "mode": "adaptive", "battery_smoothing": int64(1000)},
+			},
+		},
+		{
+			name:  "comments",
+			input: "# comment\n[tray] # section comment\nmode = \"tray\" # key comment\nicon_style = \"a#b\"\n",
+			want: map[string]map[string]interface{}{
+				"":     {},
+				"tray": {"mode": "tray", "icon_style": "a#b"},
+			},
+		},
+		{
+			name:  "quoting",
+			input: "[aliases]\n\"AA:BB:CC:DD:EE:FF\" = \"Work \\\"Pods\\\"\"\nbare-key_1 = \"tab\\there\"\n",
+			want: map[string]map[string]interface{}{
+				"":        {},
+				"aliases": {"AA:BB:CC:DD:EE:FF": "Work \"Pods\"", "bare-key_1": "tab\there"},
+			},
+		},
+		{
+			name:  "invalid lines are skipped",
+			input: "[scan]\nmode = adaptive\nfast_scan = true\n\"open = 1\nstale_after\n",
+			want: map[string]map[string]interface{}{
+				"":     {},
+				"scan": {"fast_scan": true},
+			},
+			warnings: []string{"line 2", "line 4", "line 5"},
+		},
+		{
+			name:  "keys of invalid and duplicate sections are skipped",
+			input: "[scan\nmode = \"a\"\n[tray]\nmode = \"tray\"\n[tray]\nmode = \"off\"\n[debug]\npage = true\n",
+			want: map[string]map[string]interface{}{
+				"":      {},
+				"tray":  {"mode": "tray"},
+				"debug": {"page": true},
+			},
+			warnings: []string{"line 1", "line 5"},
+		},
+		{
+			name:  "duplicate key",
+			input: "[tray]\nmode = \"tray\"\nmode = \"off\"\n",
+			want: map[string]map[string]interface{}{
+				"":     {},
+				"tray": {"mode": "tray"},
+			},
+			warnings: []string{"line 3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parseTOML(strings.NewReader(tt.input))
+			var warnings Warnings
+			if err != nil && !errors.As(err, &warnings) {
+				t.Fatalf("parseTOML: %v", err)
+			}
+			if len(warnings) != len(tt.warnings) {
+				t.Fatalf("Warnings = %v, want %d for %v", warnings, len(tt.warnings), tt.warnings)
+			}
+			for i, prefix := range tt.warnings {
+				if !strings.HasPrefix(warnings[i].Error(), prefix+":") {
+					t.Errorf("Warning %d = %q, want %s", i, warnings[i], prefix)
+				}
+			}
+
+			got := make(map[string]map[string]interface{}, len(doc))
+			for section, keys := range doc {
+				got[section] = make(map[string]interface{}, len(keys))
+				for key, v := range keys {
+					got[section][key] = v.v
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTOML = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
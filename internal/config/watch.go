@@ -0,0 +1,67 @@
+package config
+
+import (
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+// watchInterval is how often the config file is checked for changes
+const watchInterval = 2 * time.Second
+
+// Watch calls onChange with the new configuration whenever the config file is created,
+// changed or removed (the defaults apply then). Invalid values are logged and keep their
+// defaults like in Load. A file that can't be read is logged and ignored, the previous
+// configuration stays in effect. The returned function stops watching.
+func Watch(path string, onChange func(Config)) (stop func()) {
+	done := make(chan struct{})
+	last := fileVersion(path)
+
+	go func() {
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			current := fileVersion(path)
+			if current == last {
+				continue
+			}
+			last = current
+
+			cfg, err := Load(path)
+			var warnings Warnings
+			if errors.As(err, &warnings) {
+				log.Printf("Warning: %v", err)
+			} else if err != nil {
+				log.Printf("Warning: %v, keeping the previous configuration", err)
+				continue
+			}
+			log.Printf("Reloaded configuration from %s", path)
+			onChange(cfg)
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// version identifies a version of a file by its modification time and size
+type version struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+// fileVersion returns the current version of a file
+func fileVersion(path string) version {
+	info, err := os.Stat(path)
+	if err != nil {
+		return version{}
+	}
+	return version{exists: true, modTime: info.ModTime(), size: info.Size()}
+}
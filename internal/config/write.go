@@ -0,0 +1,151 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SetValue changes a key of the config file, e.g. for a switch in the UI, and keeps the rest
+// of the file including comments. A missing key is added to its section, a missing section
+// is appended. value is a string, int or bool. Watchers of the file pick up the change.
+func SetValue(path string, section string, key string, value interface{}) error {
+	formatted, err := formatValue(value)
+	if err != nil {
+		return err
+	}
+	entry := formatKey(key) + " = " + formatted
+	return editLines(path, func(lines []string) []string {
+		return setLine(lines, section, key, entry)
+	})
+}
+
+// RemoveValue removes a key from the config file, so its default applies again, e.g. when
+// the alias of a device is cleared. A missing key is not an error.
+func RemoveValue(path string, section string, key string) error {
+	return editLines(path, func(lines []string) []string {
+		return removeLine(lines, section, key)
+	})
+}
+
+// editLines rewrites the lines of the config file with edit. The file is replaced at once,
+// so watchers never read a partially written file.
+func editLines(path string, edit func(lines []string) []string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	// The file must stay valid, don't edit what couldn't be read back
+	if _, err := parseTOML(strings.NewReader(string(data))); err != nil {
+		return fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	lines = edit(lines)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// setLine replaces the line of a key in a section with entry, keeping its comment, or adds it
+func setLine(lines []string, section string, key string, entry string) []string {
+	current := ""
+	sectionFound := section == ""
+	insertAt := 0 // After the last key of the section
+	if section != "" {
+		insertAt = -1
+	}
+
+	for i, line := range lines {
+		code := stripComment(line)
+		text := strings.TrimSpace(code)
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			current = strings.TrimSpace(strings.Trim(text, "[]"))
+			if current == section {
+				sectionFound = true
+				insertAt = i + 1
+			}
+			continue
+		}
+		if current != section {
+			continue
+		}
+		insertAt = i + 1
+		if k, _, err := parseKey(text); err == nil && k == key {
+			if comment := line[len(code):]; comment != "" {
+				entry += strings.Repeat(" ", max(1, len(code)-len(entry))) + comment
+			}
+			lines[i] = entry
+			return lines
+		}
+	}
+
+	if !sectionFound {
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		return append(lines, "["+section+"]", entry)
+	}
+	return append(lines[:insertAt], append([]string{entry}, lines[insertAt:]...)...)
+}
+
+// removeLine removes the line of a key in a section
+func removeLine(lines []string, section string, key string) []string {
+	current := ""
+	for i, line := range lines {
+		text := strings.TrimSpace(stripComment(line))
+		if strings.HasPrefix(text, "[") {
+			current = strings.TrimSpace(strings.Trim(text, "[]"))
+			continue
+		}
+		if current != section || text == "" {
+			continue
+		}
+		if k, _, err := parseKey(text); err == nil && k == key {
+			return append(lines[:i], lines[i+1:]...)
+		}
+	}
+	return lines
+}
+
+// formatKey returns a key, quoted if it is not a bare key
+func formatKey(key string) string {
+	if k, rest, err := parseKey(key); err == nil && k == key && rest == "" {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+// formatValue returns the TOML representation of a string, int or bool
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("unsupported config value %v", value)
+	}
+}
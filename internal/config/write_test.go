@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial string
+		section string
+		key     string
+		value   interface{}
+		want    string
+	}{
+		{
+			name:    "new file",
+			section: "tray", key: "mode", value: "tray",
+			want: "[tray]\nmode = \"tray\"\n",
+		},
+		{
+			name:    "replace keeps the comment",
+			initial: "[tray]\nmode = \"auto\"   # Where to show the battery\n",
+			section: "tray", key: "mode", value: "window",
+			want: "[tray]\nmode = \"window\" # Where to show the battery\n",
+		},
+		{
+			name:    "add to the end of the section",
+			initial: "[tray]\nmode = \"auto\"\n\n[debug]\npage = true\n",
+			section: "tray", key: "close_to_tray", value: false,
+			want: "[tray]\nmode = \"auto\"\nclose_to_tray = false\n\n[debug]\npage = true\n",
+		},
+		{
+			name:    "append a missing section",
+			initial: "# My settings\n[tray]\nmode = \"auto\"\n",
+			section: "notifications", key: "low_battery", value: 15,
+			want: "# My settings\n[tray]\nmode = \"auto\"\n\n[notifications]\nlow_battery = 15\n",
+		},
+		{
+			name:    "quoted key",
+			initial: "[aliases]\n\"AA:BB:CC:DD:EE:FF\" = \"Old\"\n",
+			section: "aliases", key: "AA:BB:CC:DD:EE:FF", value: "New \"name\"",
+			want: "[aliases]\n\"AA:BB:CC:DD:EE:FF\" = \"New \\\"name\\\"\"\n",
+		},
+		{
+			name:    "same key in another section",
+			initial: "[tray]\nmode = \"auto\"\n[scan]\nmode = \"continuous\"\n",
+			section: "scan", key: "mode", value: "adaptive",
+			want: "[tray]\nmode = \"auto\"\n[scan]\nmode = \"adaptive\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "linuxpods", "config.toml")
+			if tt.initial != "" {
+				writeFile(t, path, tt.initial)
+			}
+			if err := SetValue(path, tt.section, tt.key, tt.value); err != nil {
+				t.Fatalf("SetValue: %v", err)
+			}
+			if got := readFile(t, path); got != tt.want {
+				t.Errorf("File after SetValue =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeFile(t, path, "[aliases]\n\"AA:BB:CC:DD:EE:FF\" = \"Work\" # Office\n\"11:22:33:44:55:66\" = \"Home\"\n")
+
+	if err := RemoveValue(path, "aliases", "AA:BB:CC:DD:EE:FF"); err != nil {
+		t.Fatalf("RemoveValue: %v", err)
+	}
+	if err := RemoveValue(path, "aliases", "00:00:00:00:00:00"); err != nil {
+		t.Errorf("RemoveValue of a missing key: %v", err)
+	}
+	if got, want := readFile(t, path), "[aliases]\n\"11:22:33:44:55:66\" = \"Home\"\n"; got != want {
+		t.Errorf("File after RemoveValue = %q, want %q", got, want)
+	}
+}
+
+func TestSetValueRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeFile(t, path, "# LinuxPods\n[scan]\nstale_after = \"30s\" # Outdated after\n")
+
+	edits := []struct {
+		section, key string
+		value        interface{}
+	}{
+		{"scan", "stale_after", "1m"},
+		{"notifications", "enabled", true},
+		{"notifications", "low_battery", 10},
+		{AliasesSection, "aa:bb:cc:dd:ee:ff", "Work AirPods"},
+		{IgnoredSection, "11:22:33:44:55:66", true},
+	}
+	for _, e := range edits {
+		if err := SetValue(path, e.section, e.key, e.value); err != nil {
+			t.Fatalf("SetValue(%s.%s): %v", e.section, e.key, err)
+		}
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load after editing: %v", err)
+	}
+	if cfg.Scan.StaleAfter.String() != "1m0s" || !cfg.Notifications.Enabled || cfg.Notifications.LowBattery != 10 {
+		t.Errorf("Loaded scan %+v and notifications %+v, want the edited values", cfg.Scan, cfg.Notifications)
+	}
+	if cfg.Device("AA:BB:CC:DD:EE:FF").Alias != "Work AirPods" || !cfg.Device("11:22:33:44:55:66").Ignored {
+		t.Errorf("Loaded devices %v, want the alias and the ignored device", cfg.Devices())
+	}
+	if got := readFile(t, path); got[:12] != "# LinuxPods\n" {
+		t.Errorf("Leading comment lost: %q", got)
+	}
+}
+
+func TestSetValueInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeFile(t, path, "[tray\nmode = auto\n")
+
+	err := SetValue(path, "tray", "mode", "tray")
+	var warnings Warnings
+	if !errors.As(err, &warnings) {
+		t.Fatalf("SetValue on an invalid file = %v, want the parse warnings", err)
+	}
+	if got := readFile(t, path); got != "[tray\nmode = auto\n" {
+		t.Errorf("Invalid file was changed: %q", got)
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
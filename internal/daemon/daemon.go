@@ -0,0 +1,471 @@
+// Package daemon runs the background part of LinuxPods: the state coordinator (BLE scanning
+// and AAP connections), the BlueZ battery provider for GNOME Settings, the D-Bus API, the
+// state socket, the battery history and the state log. It is used by the headless daemon
+// (cmd/daemon) and by the GUI when no daemon is running. It also pauses media players when
+// the AirPods are taken out of the ears (MPRIS) and switches the audio output to connected
+// AirPods.
+package daemon
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"linuxpods/internal/audio"
+	"linuxpods/internal/bluez"
+	"linuxpods/internal/config"
+	"linuxpods/internal/dbusapi"
+	"linuxpods/internal/mpris"
+	"linuxpods/internal/podstate"
+	"linuxpods/internal/stateexport"
+	"linuxpods/internal/statelog"
+	"linuxpods/internal/upower"
+	"linuxpods/internal/util"
+)
+
+// upowerCheckDelay is how long after connecting the battery is expected in UPower
+const upowerCheckDelay = 10 * time.Second
+
+// Daemon is a running coordinator with its background services
+type Daemon struct {
+	Coordinator *podstate.PodStateCoordinator
+
+	service       *dbusapi.Service
+	bluezProvider *bluez.BluezBatteryProvider
+	stateLog      *statelog.Logger
+	stopStateLog  func() // Stops feeding the state log with updates
+	stateExport   *stateexport.Server
+	mediaClient   *mpris.Client
+	earControl    *mpris.EarControl
+	audioSwitcher *audio.OutputSwitcher
+	audioProfiles *audio.ProfileMonitor
+	stopWatch     func() // Stops watching the config file
+
+	mu     sync.Mutex
+	config config.Config
+	closed bool
+}
+
+// Start creates the coordinator, configured by the config file and the LINUXPODS_* environment
+// variables, and starts the background services. Failing services are logged and skipped, only
+// a failing coordinator is an error. Changes of the config file are applied while running.
+func Start() (*Daemon, error) {
+	configPath := config.Path()
+	cfg := config.LoadOrDefault(configPath)
+
+	// Create a centralized AirPods state coordinator
+	// This coordinates BLE scanning, AAP connections, and notifies all components via events
+	// LINUXPODS_KEY_STORE sets the key store file, "off" keeps the keys in memory only
+	opts := []podstate.Option{podstate.WithDevicePreferences(devicePreferences(cfg))}
+	if keyStore := keyStoreOption(os.Getenv("LINUXPODS_KEY_STORE")); keyStore != nil {
+		opts = append(opts, keyStore)
+	}
+	podCoord, err := podstate.NewPodStateCoordinatorForAdapter(cfg.Bluetooth.Adapter, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pod state coordinator: %w", err)
+	}
+	configure(podCoord, cfg)
+
+	d := &Daemon{Coordinator: podCoord, config: cfg}
+
+	// Publish the state on the session bus (org.linuxpods.Daemon1) for the GUI, scripts and shell extensions
+	if d.service, err = dbusapi.NewService(podCoord); err != nil {
+		log.Printf("Warning: Failed to publish D-Bus service: %v", err)
+	}
+
+	// === Create Bluez Provider ===
+	d.bluezProvider = createBluezBatteryProvider(podCoord, d.gnomeBattery)
+
+	// === Control Media Players by Ear Detection ===
+	if d.mediaClient, err = mpris.NewClient(); err != nil {
+		log.Printf("Warning: Media players won't be paused by ear detection: %v", err)
+	} else {
+		d.earControl = mpris.NewEarControl(d.mediaClient, podCoord, cfg.Media)
+	}
+
+	// === Switch the Audio Output to Connected AirPods ===
+	volumesPath, err := audio.DefaultVolumesPath()
+	if err != nil {
+		log.Printf("Warning: Volumes of the devices not saved: %v", err)
+	}
+	d.audioSwitcher = audio.NewOutputSwitcher(podCoord, cfg.Audio, volumesPath)
+	d.audioProfiles = audio.NewProfileMonitor(podCoord, cfg.Audio)
+
+	// === Record Battery History ===
+	// LINUXPODS_BATTERY_HISTORY sets the history file, "off" keeps the history in memory only
+	enableBatteryHistory(podCoord, os.Getenv("LINUXPODS_BATTERY_HISTORY"))
+
+	// === Keep the Last State Across Restarts ===
+	// LINUXPODS_LAST_STATE sets the last state file, "off" disables it
+	enableLastState(podCoord, os.Getenv("LINUXPODS_LAST_STATE"))
+
+	// === Create State Log ===
+	d.setStateLog(cfg.StateLog)
+
+	// === Export the State over a Unix Socket ===
+	// LINUXPODS_STATE_SOCKET sets the socket path, "off" disables the socket
+	d.stateExport = createStateExport(podCoord, os.Getenv("LINUXPODS_STATE_SOCKET"))
+
+	d.stopWatch = config.Watch(configPath, d.reload)
+
+	return d, nil
+}
+
+// Close stops the background services and the coordinator
+func (d *Daemon) Close() error {
+	d.stopWatch()
+	d.mu.Lock()
+	d.closed = true
+	d.closeStateLog()
+	d.mu.Unlock()
+	if d.stateExport != nil {
+		_ = d.stateExport.Close()
+	}
+	if d.bluezProvider != nil {
+		_ = d.bluezProvider.Close()
+	}
+	_ = d.audioSwitcher.Close()
+	_ = d.audioProfiles.Close()
+	if d.mediaClient != nil {
+		_ = d.mediaClient.Close()
+	}
+	if d.service != nil {
+		_ = d.service.Close()
+	}
+	return d.Coordinator.Close()
+}
+
+// reload applies a changed config file
+func (d *Daemon) reload(cfg config.Config) {
+	d.mu.Lock()
+	old := d.config
+	d.config = cfg
+	d.mu.Unlock()
+
+	if cfg.Bluetooth.Adapter != old.Bluetooth.Adapter {
+		log.Printf("Warning: bluetooth.adapter changed, restart LinuxPods to scan with %q", cfg.Bluetooth.Adapter)
+	}
+	configure(d.Coordinator, cfg)
+	if d.earControl != nil {
+		d.earControl.SetConfig(cfg.Media)
+	}
+	d.audioSwitcher.SetConfig(cfg.Audio)
+	d.audioProfiles.SetConfig(cfg.Audio)
+	if cfg.StateLog != old.StateLog {
+		d.setStateLog(cfg.StateLog)
+	}
+}
+
+// setStateLog replaces the state log by one with the given settings, or stops it if it is off
+func (d *Daemon) setStateLog(cfg config.StateLogConfig) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+
+	d.closeStateLog()
+	if logConfig, ok := stateLogConfig(cfg); ok {
+		d.stateLog, d.stopStateLog = createStateLog(logConfig, d.Coordinator)
+	}
+}
+
+// closeStateLog stops the state log, if any. d.mu must be held.
+func (d *Daemon) closeStateLog() {
+	if d.stateLog == nil {
+		return
+	}
+	d.stopStateLog()
+	_ = d.stateLog.Close()
+	d.stateLog, d.stopStateLog = nil, nil
+}
+
+// gnomeBattery returns the configured battery choice for GNOME Settings
+func (d *Daemon) gnomeBattery() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.config.GNOMESettings.Battery
+}
+
+// configure applies the coordinator settings of the config file and the environment,
+// which overrides the file
+func configure(podCoord *podstate.PodStateCoordinator, cfg config.Config) {
+	podCoord.SetUpdateIntervals(cfg.Scan.UpdateInterval, cfg.Scan.FastUpdateInterval)
+	podCoord.SetFastScanEnabled(cfg.Scan.FastScan)
+	podCoord.SetSuspendInCase(cfg.Bluetooth.SuspendInCase)
+	podCoord.SetScanPolicy(scanPolicy(cfg.Scan))
+	podCoord.SetDevicePreferences(devicePreferences(cfg))
+
+	staleness := podstate.DefaultStaleness
+	if cfg.Scan.StaleAfter > 0 {
+		staleness.StaleAfter = cfg.Scan.StaleAfter
+	}
+	// LINUXPODS_STALE_AFTER sets how long battery data is shown as current without updates (e.g. 1m)
+	if value := os.Getenv("LINUXPODS_STALE_AFTER"); value != "" {
+		if staleAfter, err := time.ParseDuration(value); err != nil || staleAfter <= 0 {
+			log.Printf("Warning: invalid LINUXPODS_STALE_AFTER %q, using %s", value, staleness.StaleAfter)
+		} else {
+			staleness.StaleAfter = staleAfter
+		}
+	}
+	podCoord.SetStaleness(staleness)
+
+	// LINUXPODS_SHOW_FOREIGN=1 also shows AirPods that are not paired to this machine
+	podCoord.SetShowForeignDevices(cfg.Scan.ShowForeign || os.Getenv("LINUXPODS_SHOW_FOREIGN") == "1")
+
+	// LINUXPODS_BATTERY_SMOOTHING is read by the coordinator itself
+	if os.Getenv("LINUXPODS_BATTERY_SMOOTHING") == "" {
+		window := cfg.Scan.BatterySmoothing
+		if window == 0 {
+			window = podstate.DefaultSmoothingWindow
+		}
+		podCoord.SetBatterySmoothing(window)
+	}
+}
+
+// devicePreferences returns the per-device settings of the config file
+func devicePreferences(cfg config.Config) map[string]podstate.DevicePreferences {
+	devices := cfg.Devices()
+	preferences := make(map[string]podstate.DevicePreferences, len(devices))
+	for macAddr, device := range devices {
+		preferences[macAddr] = podstate.DevicePreferences{
+			Alias:       device.Alias,
+			AutoConnect: device.AutoConnect,
+			Ignored:     device.Ignored,
+		}
+	}
+	return preferences
+}
+
+// scanPolicy returns the scan cadence of the config file. Zero durations keep the defaults.
+func scanPolicy(cfg config.ScanConfig) podstate.ScanPolicy {
+	policy := podstate.DefaultScanPolicy
+	if cfg.Mode == config.ScanModeAdaptive {
+		policy.Mode = podstate.ScanAdaptive
+	}
+	if cfg.ScanWindow > 0 {
+		policy.Active.Scan = cfg.ScanWindow
+		policy.Idle.Scan = cfg.ScanWindow
+		policy.Suspended.Scan = cfg.ScanWindow
+	}
+	if cfg.ScanPause > 0 {
+		policy.Active.Pause = cfg.ScanPause
+	}
+	if cfg.IdlePause > 0 {
+		policy.Idle.Pause = cfg.IdlePause
+	}
+	if cfg.SuspendedPause > 0 {
+		policy.Suspended.Pause = cfg.SuspendedPause
+	}
+	policy.PauseOnPowerSaver = cfg.PauseOnPowerSaver
+	return policy
+}
+
+// enableBatteryHistory records the battery history to a file, the default file if path is empty
+func enableBatteryHistory(podCoord *podstate.PodStateCoordinator, path string) {
+	if path == "off" {
+		return
+	}
+	if path == "" {
+		var err error
+		if path, err = podstate.DefaultBatteryHistoryPath(); err != nil {
+			log.Printf("Warning: Battery history not saved: %v", err)
+			return
+		}
+	}
+	if err := podCoord.SetBatteryHistoryFile(path); err != nil {
+		log.Printf("Warning: Battery history not saved: %v", err)
+	}
+}
+
+// keyStoreOption returns the option that keeps the keys in a file, the default file if path
+// is empty, or nil if they are only kept in memory
+func keyStoreOption(path string) podstate.Option {
+	if path == "off" {
+		return nil
+	}
+	if path == "" {
+		var err error
+		if path, err = podstate.DefaultKeyStorePath(); err != nil {
+			log.Printf("Warning: Keys are not saved: %v", err)
+			return nil
+		}
+	}
+	return podstate.WithKeyStoreFile(path)
+}
+
+// enableLastState restores and saves the last state of the own devices, in the default file
+// if path is empty
+func enableLastState(podCoord *podstate.PodStateCoordinator, path string) {
+	if path == "off" {
+		return
+	}
+	if path == "" {
+		var err error
+		if path, err = podstate.DefaultLastStatePath(); err != nil {
+			log.Printf("Warning: Last state not saved: %v", err)
+			return
+		}
+	}
+	if err := podCoord.SetLastStateFile(path); err != nil {
+		log.Printf("Warning: Last state not restored: %v", err)
+	}
+}
+
+// createStateExport listens on the state socket, the default socket if path is empty
+func createStateExport(podCoord *podstate.PodStateCoordinator, path string) *stateexport.Server {
+	if path == "off" {
+		return nil
+	}
+	if path == "" {
+		var err error
+		if path, err = stateexport.DefaultSocketPath(); err != nil {
+			log.Printf("Warning: State not exported: %v", err)
+			return nil
+		}
+	}
+	server, err := stateexport.NewServer(path, podCoord)
+	if err != nil {
+		log.Printf("Warning: State not exported: %v", err)
+		return nil
+	}
+	return server
+}
+
+// stateLogConfig returns the state log settings of the config file, whose format and path
+// LINUXPODS_STATE_LOG (csv:PATH, journal or off) overrides. It reports false if the log is off.
+func stateLogConfig(cfg config.StateLogConfig) (statelog.Config, bool) {
+	logConfig := statelog.Config{Sink: statelog.Sink(cfg.Format), Path: cfg.Path, Interval: cfg.Interval}
+	if spec := os.Getenv("LINUXPODS_STATE_LOG"); spec == config.StateLogOff {
+		return logConfig, false
+	} else if spec != "" {
+		override, err := statelog.ParseConfig(spec)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			return logConfig, false
+		}
+		logConfig.Sink, logConfig.Path = override.Sink, override.Path
+	}
+
+	if logConfig.Sink == config.StateLogOff {
+		return logConfig, false
+	}
+	if logConfig.Sink == statelog.SinkCSV && logConfig.Path == "" {
+		path, err := statelog.DefaultPath()
+		if err != nil {
+			log.Printf("Warning: State log not written: %v", err)
+			return logConfig, false
+		}
+		logConfig.Path = path
+	}
+	return logConfig, true
+}
+
+// createStateLog creates the state logger and feeds it with state updates until unsubscribe is called
+func createStateLog(config statelog.Config, podCoord *podstate.PodStateCoordinator) (stateLog *statelog.Logger, unsubscribe func()) {
+	stateLog, err := statelog.NewLogger(config)
+	if err != nil {
+		log.Printf("Warning: Failed to create state log: %v", err)
+		return nil, nil
+	}
+
+	return stateLog, podCoord.Subscribe(podstate.OnStatesChanged(stateLog.Update))
+}
+
+// createBluezBatteryProvider creates and configures the BlueZ battery provider.
+// batteryChoice returns which battery to show in GNOME Settings (config.Battery*).
+func createBluezBatteryProvider(podCoord *podstate.PodStateCoordinator, batteryChoice func() string) *bluez.BluezBatteryProvider {
+	bluezProvider, err := bluez.NewBluezBatteryProvider()
+	if err != nil {
+		log.Printf("Warning: Failed to create BlueZ battery provider: %v", err)
+		log.Println("Battery won't appear in GNOME Settings, but UI will still work")
+		return nil
+	}
+
+	// Set connection callback to manage AAP connection
+	bluezProvider.SetConnectionCallback(func(connected bool, devicePath string, macAddr string) {
+		if connected {
+			log.Printf("AirPods connected: %s (MAC: %s)", devicePath, macAddr)
+			// Capture the rapidly changing state right after connecting
+			podCoord.StartFastScan()
+			if !podCoord.ShouldAutoConnect(macAddr) {
+				log.Printf("Not opening the AAP connection to %s, turned off for this device", macAddr)
+			} else {
+				// Connect in the background: this runs on the provider's signal loop, which
+				// must not be blocked by retries. DisconnectAAP stops the attempt.
+				podCoord.ReconnectAAP(macAddr)
+			}
+			go checkUPower(macAddr)
+		} else {
+			log.Printf("AirPods disconnected: %s (MAC: %s)", devicePath, macAddr)
+			podCoord.DisconnectAAP(macAddr)
+		}
+	})
+
+	// Watch for AirPods connections
+	if err := bluezProvider.WatchForAirPods(); err != nil {
+		log.Printf("Warning: Failed to watch for AirPods: %v", err)
+	}
+
+	// Update the battery when it changes, and when a device connects with its battery known from BLE
+	updateBattery := func(macAddr string, state *podstate.PodState) {
+		// Batteries are only shown for connected devices
+		name, ok := bluezProvider.BatteryNameForAddress(macAddr)
+		if !ok || state == nil {
+			return
+		}
+		batteryLevel := gnomeBatteryLevel(state, batteryChoice())
+		if err := bluezProvider.UpdateBatteryPercentage(name, uint8(batteryLevel)); err != nil {
+			log.Printf("Update BlueZ battery: %v", err)
+		}
+	}
+	podCoord.Subscribe(func(event podstate.Event) {
+		switch e := event.(type) {
+		case podstate.BatteryChanged:
+			updateBattery(e.Address, e.State)
+		case podstate.DeviceConnected:
+			updateBattery(e.Address, podCoord.GetDeviceStates()[e.Address])
+		}
+	})
+
+	return bluezProvider
+}
+
+// gnomeBatteryLevel returns the battery level shown in GNOME Settings. The lowest battery is
+// the default, being the most useful for knowing when to charge.
+func gnomeBatteryLevel(state *podstate.PodState, choice string) int {
+	if state.Capabilities.SingleBattery() {
+		return util.MinOr(state.Battery, nil, 0)
+	}
+	switch choice {
+	case config.BatteryLeft:
+		return util.MinOr(state.LeftBattery, nil, 0)
+	case config.BatteryRight:
+		return util.MinOr(state.RightBattery, nil, 0)
+	case config.BatteryCase:
+		return util.MinOr(state.CaseBattery, nil, 0)
+	case config.BatteryAverage:
+		if state.LeftBattery != nil && state.RightBattery != nil {
+			return (*state.LeftBattery + *state.RightBattery) / 2
+		}
+	}
+	return util.MinOr(state.LeftBattery, state.RightBattery, 0)
+}
+
+// checkUPower logs whether UPower, which desktops like XFCE and MATE read batteries from,
+// picked up the battery that BlueZ created from the provider
+func checkUPower(macAddr string) {
+	time.Sleep(upowerCheckDelay)
+
+	device, found, err := upower.FindDevice(macAddr)
+	switch {
+	case err != nil:
+		log.Printf("UPower not available, battery only shown via BlueZ: %v", err)
+	case !found:
+		log.Printf("Warning: UPower doesn't list %s, desktops reading batteries from UPower won't show it (UPower with BlueZ battery support required)", macAddr)
+	default:
+		log.Printf("UPower shows %s at %.0f%% (%s)", device.Model, device.Percentage, device.Path)
+	}
+}
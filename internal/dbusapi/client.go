@@ -0,0 +1,397 @@
+package dbusapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/podstate"
+)
+
+// Client is a podstate.Backend that forwards all calls to the coordinator of a running
+// daemon (cmd/daemon) over the session bus. The GUI uses it instead of running its own
+// coordinator, so battery reporting continues when the window is closed.
+type Client struct {
+	conn *dbus.Conn
+	obj  dbus.BusObject
+
+	events podstate.EventBus
+
+	settingsCallbacks podstate.Subscribers[podstate.SettingsCallback]
+}
+
+var _ podstate.Backend = (*Client)(nil)
+
+// NewClient connects to the daemon on the session bus.
+// It fails if no daemon is running.
+func NewClient() (*Client, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+
+	var running bool
+	if err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, ServiceName).Store(&running); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to look for the daemon: %w", err)
+	}
+	if !running {
+		_ = conn.Close()
+		return nil, fmt.Errorf("daemon %s is not running", ServiceName)
+	}
+
+	c := &Client{
+		conn: conn,
+		obj:  conn.Object(ServiceName, ObjectPath),
+	}
+
+	// Daemon signals, and the daemon (re)starting
+	if err := conn.AddMatchSignal(dbus.WithMatchObjectPath(ObjectPath), dbus.WithMatchInterface(Interface)); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to add match rule: %w", err)
+	}
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchSender("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, ServiceName),
+	); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to add match rule: %w", err)
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+
+	// The current states for new subscribers, updates follow as signals
+	c.events.PublishStates(c.GetDeviceStates())
+	go c.handleSignals(signals)
+
+	return c, nil
+}
+
+// handleSignals notifies callbacks of the daemon's state and setting updates
+func (c *Client) handleSignals(signals <-chan *dbus.Signal) {
+	for signal := range signals {
+		switch signal.Name {
+		case Interface + ".StatesChanged":
+			var statesJSON string
+			if err := dbus.Store(signal.Body, &statesJSON); err != nil {
+				continue
+			}
+			var states map[string]*podstate.PodState
+			if err := json.Unmarshal([]byte(statesJSON), &states); err != nil {
+				log.Printf("D-Bus: Invalid states from daemon: %v", err)
+				continue
+			}
+			c.events.PublishStates(states)
+
+		case Interface + ".DeviceEvent":
+			var address, name string
+			if err := dbus.Store(signal.Body, &address, &name); err != nil {
+				continue
+			}
+			if event, ok := deviceEvent(address, name); ok {
+				c.events.Publish(event)
+			}
+
+		case Interface + ".SettingChanged":
+			var macAddr string
+			var id uint8
+			var value []byte
+			if err := dbus.Store(signal.Body, &macAddr, &id, &value); err != nil {
+				continue
+			}
+			cmd := controlCommand(id, value)
+			c.settingsCallbacks.Each(func(cb podstate.SettingsCallback) { cb(macAddr, cmd) })
+			if event, ok := podstate.SettingEvent(macAddr, cmd); ok {
+				c.events.Publish(event)
+			}
+
+		case "org.freedesktop.DBus.NameOwnerChanged":
+			var name, oldOwner, newOwner string
+			if err := dbus.Store(signal.Body, &name, &oldOwner, &newOwner); err != nil || name != ServiceName {
+				continue
+			}
+			if newOwner == "" {
+				log.Printf("D-Bus: Daemon stopped, waiting for it to restart")
+				continue
+			}
+			log.Printf("D-Bus: Daemon restarted")
+			c.events.PublishStates(c.GetDeviceStates())
+			c.settingsCallbacks.Each(c.replaySettings)
+		}
+	}
+}
+
+// replaySettings reports all settings the connected devices reported so far to cb
+func (c *Client) replaySettings(cb podstate.SettingsCallback) {
+	var settings []reportedSetting
+	if err := c.obj.Call(Interface+".GetSettings", 0).Store(&settings); err != nil {
+		log.Printf("D-Bus: Failed to get settings from daemon: %v", err)
+		return
+	}
+	for _, setting := range settings {
+		cb(setting.Address, controlCommand(setting.ID, setting.Value))
+	}
+}
+
+// controlCommand creates a control command from a setting sent over the bus
+func controlCommand(id uint8, value []byte) aap.ControlCommand {
+	cmd := aap.ControlCommand{ID: aap.ControlCommandID(id)}
+	copy(cmd.Value[:], value)
+	return cmd
+}
+
+// call calls a method of the daemon without results
+func (c *Client) call(method string, args ...interface{}) error {
+	return c.obj.Call(Interface+"."+method, 0, args...).Err
+}
+
+// callJSON calls a method of the daemon that returns JSON and decodes the result into v
+func (c *Client) callJSON(method string, v interface{}, args ...interface{}) error {
+	var data string
+	if err := c.obj.Call(Interface+"."+method, 0, args...).Store(&data); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), v)
+}
+
+// Subscribe registers a handler to be notified of state changes and returns the function
+// that removes it. If states are known, the handler receives them right away in a
+// StatesChanged event, like with the coordinator.
+func (c *Client) Subscribe(handler podstate.EventHandler) (unsubscribe func()) {
+	return c.events.Subscribe(handler)
+}
+
+// RegisterSettingsCallback registers a callback to be notified of device setting values and
+// returns the function that removes it. Settings that are already known are reported to the
+// new callback immediately.
+func (c *Client) RegisterSettingsCallback(cb podstate.SettingsCallback) (unregister func()) {
+	unregister = c.settingsCallbacks.Add(cb)
+	go podstate.CallSafely(func() { c.replaySettings(cb) })
+	return unregister
+}
+
+// GetDeviceStates returns the current states of all devices
+func (c *Client) GetDeviceStates() map[string]*podstate.PodState {
+	states := make(map[string]*podstate.PodState)
+	if err := c.callJSON("GetStatesJSON", &states); err != nil {
+		log.Printf("D-Bus: Failed to get states from daemon: %v", err)
+	}
+	return states
+}
+
+// ConnectAAP opens an AAP connection to a device
+func (c *Client) ConnectAAP(macAddr string) error {
+	return c.call("ConnectDevice", macAddr)
+}
+
+// ReconnectAAP connects to a device via AAP in the background, retrying with backoff
+func (c *Client) ReconnectAAP(macAddr string) {
+	if err := c.call("ReconnectDevice", macAddr); err != nil {
+		log.Printf("D-Bus: Failed to reconnect %s: %v", macAddr, err)
+	}
+}
+
+// DisconnectAAP closes the AAP connection to a device
+func (c *Client) DisconnectAAP(macAddr string) {
+	if err := c.call("DisconnectDevice", macAddr); err != nil {
+		log.Printf("D-Bus: Failed to disconnect %s: %v", macAddr, err)
+	}
+}
+
+// IsAAPConnected reports whether the daemon has an AAP connection to a device
+func (c *Client) IsAAPConnected(macAddr string) bool {
+	return slices.Contains(c.GetConnectedDeviceMacs(), macAddr)
+}
+
+// GetConnectedDeviceMacs returns the MAC addresses of all devices with an AAP connection, sorted
+func (c *Client) GetConnectedDeviceMacs() []string {
+	var macs []string
+	if err := c.obj.Call(Interface+".GetConnectedDevices", 0).Store(&macs); err != nil {
+		log.Printf("D-Bus: Failed to get connected devices from daemon: %v", err)
+		return nil
+	}
+	slices.Sort(macs)
+	return macs
+}
+
+// StartFastScan starts a burst of frequent BLE updates
+func (c *Client) StartFastScan() {
+	if err := c.call("StartFastScan"); err != nil {
+		log.Printf("D-Bus: Failed to start fast scan: %v", err)
+	}
+}
+
+// SetInteractive reports whether a window shows the state, to scan actively
+func (c *Client) SetInteractive(interactive bool) {
+	if err := c.call("SetInteractive", interactive); err != nil {
+		log.Printf("D-Bus: Failed to set interactive: %v", err)
+	}
+}
+
+// ConnectBluetooth connects a paired device via Bluetooth
+func (c *Client) ConnectBluetooth(macAddr string) error {
+	return c.call("ConnectBluetooth", macAddr)
+}
+
+// DisconnectBluetooth disconnects a device from Bluetooth
+func (c *Client) DisconnectBluetooth(macAddr string) error {
+	return c.call("DisconnectBluetooth", macAddr)
+}
+
+// FindPairableDevice searches for AirPods in pairing mode for up to timeout
+func (c *Client) FindPairableDevice(timeout time.Duration) (podstate.PairableDevice, error) {
+	var device podstate.PairableDevice
+	err := c.obj.Call(Interface+".FindPairableDevice", 0, uint32(timeout/time.Second)).Store(&device.Address, &device.Name)
+	return device, err
+}
+
+// PairBluetooth pairs with a device in pairing mode
+func (c *Client) PairBluetooth(macAddr string) error {
+	return c.call("PairBluetooth", macAddr)
+}
+
+// BluetoothPowered checks if a Bluetooth adapter is turned on
+func (c *Client) BluetoothPowered() (bool, error) {
+	var powered bool
+	err := c.obj.Call(Interface+".BluetoothPowered", 0).Store(&powered)
+	return powered, err
+}
+
+// PowerOnBluetooth turns on the Bluetooth adapter
+func (c *Client) PowerOnBluetooth() error {
+	return c.call("PowerOnBluetooth")
+}
+
+// RequestEncryptionKeys retrieves the encryption keys of a connected device
+func (c *Client) RequestEncryptionKeys(macAddr string) error {
+	return c.call("RequestKeys", macAddr)
+}
+
+// ImportKeys stores the keys of a device that is not paired to this machine
+func (c *Client) ImportKeys(macAddr string, encKey []byte, irk []byte) error {
+	// D-Bus has no nil arrays, the daemon treats empty keys as missing
+	return c.call("ImportKeys", macAddr, append([]byte{}, encKey...), append([]byte{}, irk...))
+}
+
+// SetEncryptionKey sets the ENC_KEY of a device. A nil key removes it.
+func (c *Client) SetEncryptionKey(macAddr string, encKey []byte) error {
+	return c.call("SetEncryptionKey", macAddr, append([]byte{}, encKey...))
+}
+
+// ExportKeys makes the daemon write the keys of all devices to a file
+func (c *Client) ExportKeys(path string) error {
+	return c.call("ExportKeys", path)
+}
+
+// ImportKeysFile makes the daemon import the keys of a file and returns the number of devices
+func (c *Client) ImportKeysFile(path string) (int, error) {
+	var n uint32
+	err := c.obj.Call(Interface+".ImportKeysFile", 0, path).Store(&n)
+	return int(n), err
+}
+
+// SetNoiseMode switches the noise control mode of a device
+func (c *Client) SetNoiseMode(macAddr string, mode aap.NoiseControlMode) error {
+	name, ok := noiseModeNames[mode]
+	if !ok {
+		return fmt.Errorf("invalid noise control mode %s", mode)
+	}
+	return c.call("SetNoiseMode", macAddr, name)
+}
+
+// SetNoiseControlCycle sets the noise control modes of the press and hold gesture
+func (c *Client) SetNoiseControlCycle(macAddr string, cycle aap.NoiseControlCycle) error {
+	return c.call("SetNoiseControlCycle", macAddr, uint8(cycle))
+}
+
+// SetMicrophoneMode selects which bud's microphone a device uses
+func (c *Client) SetMicrophoneMode(macAddr string, mode aap.MicrophoneMode) error {
+	return c.call("SetMicrophoneMode", macAddr, uint8(mode))
+}
+
+// SetEarDetection enables or disables automatic ear detection
+func (c *Client) SetEarDetection(macAddr string, enabled bool) error {
+	return c.call("SetEarDetection", macAddr, enabled)
+}
+
+// SetToneVolume sets the volume of the tones played by a device
+func (c *Client) SetToneVolume(macAddr string, volume uint8) error {
+	return c.call("SetToneVolume", macAddr, volume)
+}
+
+// SetPressAndHold sets the press and hold action of each bud of a device
+func (c *Client) SetPressAndHold(macAddr string, pressAndHold aap.PressAndHold) error {
+	return c.call("SetPressAndHold", macAddr, uint8(pressAndHold.Left), uint8(pressAndHold.Right))
+}
+
+// SetPressSpeed sets how fast the stem of a device must be pressed repeatedly
+func (c *Client) SetPressSpeed(macAddr string, speed aap.PressSpeed) error {
+	return c.call("SetPressSpeed", macAddr, uint8(speed))
+}
+
+// SetHoldDuration sets how long the stem of a device must be held for press and hold
+func (c *Client) SetHoldDuration(macAddr string, duration aap.HoldDuration) error {
+	return c.call("SetHoldDuration", macAddr, uint8(duration))
+}
+
+// SetVolumeSwipe enables or disables volume control by swiping the stem
+func (c *Client) SetVolumeSwipe(macAddr string, enabled bool) error {
+	return c.call("SetVolumeSwipe", macAddr, enabled)
+}
+
+// BatteryHistory returns the battery samples of a device recorded by the daemon since a point in time
+func (c *Client) BatteryHistory(macAddr string, since time.Time) []podstate.BatterySample {
+	var samples []podstate.BatterySample
+	if err := c.callJSON("GetBatteryHistoryJSON", &samples, macAddr, since.Unix()); err != nil {
+		log.Printf("D-Bus: Failed to get battery history from daemon: %v", err)
+	}
+	return samples
+}
+
+// BatteryEstimates returns the drain rates and remaining times of a device's batteries
+func (c *Client) BatteryEstimates(macAddr string) []podstate.BatteryEstimate {
+	var estimates []podstate.BatteryEstimate
+	if err := c.callJSON("GetBatteryEstimatesJSON", &estimates, macAddr); err != nil {
+		log.Printf("D-Bus: Failed to get battery estimates from daemon: %v", err)
+	}
+	return estimates
+}
+
+// Metrics returns the daemon's coordinator metrics
+func (c *Client) Metrics() podstate.Metrics {
+	var metrics podstate.Metrics
+	if err := c.callJSON("GetMetricsJSON", &metrics); err != nil {
+		log.Printf("D-Bus: Failed to get metrics from daemon: %v", err)
+	}
+	return metrics
+}
+
+// AAPDiagnostics returns the unparsed AAP packets of the connected devices
+func (c *Client) AAPDiagnostics() map[string][]aap.UnparsedPacket {
+	diagnostics := make(map[string][]aap.UnparsedPacket)
+	if err := c.callJSON("GetDiagnosticsJSON", &diagnostics); err != nil {
+		log.Printf("D-Bus: Failed to get diagnostics from daemon: %v", err)
+	}
+	return diagnostics
+}
+
+// PacketLog returns the recent raw packets of the daemon after a sequence number
+func (c *Client) PacketLog(after uint64) []podstate.LoggedPacket {
+	var packets []podstate.LoggedPacket
+	if err := c.callJSON("GetPacketLogJSON", &packets, after); err != nil {
+		log.Printf("D-Bus: Failed to get the packet log from daemon: %v", err)
+	}
+	return packets
+}
+
+// Close closes the session bus connection. The daemon keeps running.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
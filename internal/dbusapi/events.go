@@ -0,0 +1,74 @@
+package dbusapi
+
+import "linuxpods/internal/podstate"
+
+// Names of the events sent as DeviceEvent signals. The battery and ear events follow from
+// StatesChanged and the noise mode from SettingChanged, the client derives them itself.
+const (
+	eventLidOpened           = "lid-opened"
+	eventLidClosed           = "lid-closed"
+	eventDeviceConnecting    = "connecting"
+	eventDeviceConnectFailed = "connect-failed"
+	eventDeviceConnected     = "connected"
+	eventDeviceDisconnected  = "disconnected"
+	eventDeviceSuspended     = "suspended"
+	eventDeviceSwitchedAway  = "switched-away"
+	eventDeviceLeftBehind    = "left-behind"
+	eventKeysStored          = "keys-stored"
+)
+
+// deviceEventName returns the address and name of an event sent as DeviceEvent signal
+func deviceEventName(event podstate.Event) (string, string, bool) {
+	switch e := event.(type) {
+	case podstate.LidOpened:
+		return e.Address, eventLidOpened, true
+	case podstate.LidClosed:
+		return e.Address, eventLidClosed, true
+	case podstate.DeviceConnecting:
+		return e.Address, eventDeviceConnecting, true
+	case podstate.DeviceConnectFailed:
+		return e.Address, eventDeviceConnectFailed, true
+	case podstate.DeviceConnected:
+		return e.Address, eventDeviceConnected, true
+	case podstate.DeviceDisconnected:
+		return e.Address, eventDeviceDisconnected, true
+	case podstate.DeviceSuspended:
+		return e.Address, eventDeviceSuspended, true
+	case podstate.DeviceSwitchedAway:
+		return e.Address, eventDeviceSwitchedAway, true
+	case podstate.DeviceLeftBehind:
+		return e.Address, eventDeviceLeftBehind, true
+	case podstate.KeysStored:
+		return e.Address, eventKeysStored, true
+	default:
+		return "", "", false
+	}
+}
+
+// deviceEvent returns the event of a DeviceEvent signal
+func deviceEvent(address string, name string) (podstate.Event, bool) {
+	switch name {
+	case eventLidOpened:
+		return podstate.LidOpened{Address: address}, true
+	case eventLidClosed:
+		return podstate.LidClosed{Address: address}, true
+	case eventDeviceConnecting:
+		return podstate.DeviceConnecting{Address: address}, true
+	case eventDeviceConnectFailed:
+		return podstate.DeviceConnectFailed{Address: address}, true
+	case eventDeviceConnected:
+		return podstate.DeviceConnected{Address: address}, true
+	case eventDeviceDisconnected:
+		return podstate.DeviceDisconnected{Address: address}, true
+	case eventDeviceSuspended:
+		return podstate.DeviceSuspended{Address: address}, true
+	case eventDeviceSwitchedAway:
+		return podstate.DeviceSwitchedAway{Address: address}, true
+	case eventDeviceLeftBehind:
+		return podstate.DeviceLeftBehind{Address: address}, true
+	case eventKeysStored:
+		return podstate.KeysStored{Address: address}, true
+	default:
+		return nil, false
+	}
+}
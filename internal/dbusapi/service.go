@@ -0,0 +1,709 @@
+// Package dbusapi publishes the state of the PodStateCoordinator on the session bus,
+// so GNOME Shell extensions, scripts and other desktops can use LinuxPods without
+// linking Go code.
+//
+// The service owns the name org.linuxpods.Daemon and exports:
+//
+//   - /org/linuxpods/Daemon1 (org.linuxpods.Daemon1): methods to read the state and
+//     control devices, and the Devices property listing the device objects
+//   - /org/linuxpods/Daemon1/devices/dev_AA_BB_CC_DD_EE_FF (org.linuxpods.Device1):
+//     one object per device with its state as properties
+//
+// All properties emit org.freedesktop.DBus.Properties.PropertiesChanged. See
+// docs/dbus-api.md for the full interface.
+//
+// The interface also has methods and signals for the GUI (see Client), which exchange
+// the complete podstate.PodState as JSON. Their format follows the Go types and is not
+// meant for other clients.
+package dbusapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/ble"
+	"linuxpods/internal/podstate"
+)
+
+const (
+	ServiceName     = "org.linuxpods.Daemon"
+	ObjectPath      = dbus.ObjectPath("/org/linuxpods/Daemon1")
+	Interface       = "org.linuxpods.Daemon1"
+	DeviceInterface = "org.linuxpods.Device1"
+
+	devicePathPrefix = "/org/linuxpods/Daemon1/devices/dev_"
+)
+
+// daemonIntrospection describes the methods of org.linuxpods.Daemon1
+const daemonIntrospection = `
+	<method name="GetState">
+		<arg name="devices" type="a{sa{sv}}" direction="out"/>
+	</method>
+	<method name="SetNoiseMode">
+		<arg name="address" type="s" direction="in"/>
+		<arg name="mode" type="s" direction="in"/>
+	</method>
+	<method name="ConnectDevice">
+		<arg name="address" type="s" direction="in"/>
+	</method>
+	<method name="RequestKeys">
+		<arg name="address" type="s" direction="in"/>
+	</method>
+	<method name="ConnectBluetooth">
+		<arg name="address" type="s" direction="in"/>
+	</method>
+	<method name="DisconnectBluetooth">
+		<arg name="address" type="s" direction="in"/>
+	</method>
+	<method name="FindPairableDevice">
+		<arg name="timeout" type="u" direction="in"/>
+		<arg name="address" type="s" direction="out"/>
+		<arg name="name" type="s" direction="out"/>
+	</method>
+	<method name="PairBluetooth">
+		<arg name="address" type="s" direction="in"/>
+	</method>
+	<method name="BluetoothPowered">
+		<arg name="powered" type="b" direction="out"/>
+	</method>
+	<method name="PowerOnBluetooth"/>
+	<method name="DisconnectDevice">
+		<arg name="address" type="s" direction="in"/>
+	</method>
+	<method name="ReconnectDevice">
+		<arg name="address" type="s" direction="in"/>
+	</method>
+	<method name="GetConnectedDevices">
+		<arg name="addresses" type="as" direction="out"/>
+	</method>
+	<method name="StartFastScan"/>
+	<method name="SetInteractive">
+		<arg name="interactive" type="b" direction="in"/>
+	</method>
+	<method name="ImportKeys">
+		<arg name="address" type="s" direction="in"/>
+		<arg name="enc_key" type="ay" direction="in"/>
+		<arg name="irk" type="ay" direction="in"/>
+	</method>
+	<method name="SetEncryptionKey">
+		<arg name="address" type="s" direction="in"/>
+		<arg name="enc_key" type="ay" direction="in"/>
+	</method>
+	<method name="ExportKeys">
+		<arg name="path" type="s" direction="in"/>
+	</method>
+	<method name="ImportKeysFile">
+		<arg name="path" type="s" direction="in"/>
+		<arg name="devices" type="u" direction="out"/>
+	</method>
+	<method name="SetNoiseControlCycle">
+		<arg name="address" type="s" direction="in"/>
+		<arg name="cycle" type="y" direction="in"/>
+	</method>
+	<method name="SetMicrophoneMode">
+		<arg name="address" type="s" direction="in"/>
+		<arg name="mode" type="y" direction="in"/>
+	</method>
+	<method name="SetEarDetection">
+		<arg name="address" type="s" direction="in"/>
+		<arg name="enabled" type="b" direction="in"/>
+	</method>
+	<method name="SetToneVolume">
+		<arg name="address" type="s" direction="in"/>
+		<arg name="volume" type="y" direction="in"/>
+	</method>
+	<method name="SetPressAndHold">
+		<arg name="address" type="s" direction="in"/>
+		<arg name="left" type="y" direction="in"/>
+		<arg name="right" type="y" direction="in"/>
+	</method>
+	<method name="SetPressSpeed">
+		<arg name="address" type="s" direction="in"/>
+		<arg name="speed" type="y" direction="in"/>
+	</method>
+	<method name="SetHoldDuration">
+		<arg name="address" type="s" direction="in"/>
+		<arg name="duration" type="y" direction="in"/>
+	</method>
+	<method name="SetVolumeSwipe">
+		<arg name="address" type="s" direction="in"/>
+		<arg name="enabled" type="b" direction="in"/>
+	</method>
+	<method name="GetSettings">
+		<arg name="settings" type="a(syay)" direction="out"/>
+	</method>
+	<method name="GetStatesJSON">
+		<arg name="states" type="s" direction="out"/>
+	</method>
+	<method name="GetBatteryHistoryJSON">
+		<arg name="address" type="s" direction="in"/>
+		<arg name="since" type="x" direction="in"/>
+		<arg name="samples" type="s" direction="out"/>
+	</method>
+	<method name="GetBatteryEstimatesJSON">
+		<arg name="address" type="s" direction="in"/>
+		<arg name="estimates" type="s" direction="out"/>
+	</method>
+	<method name="GetMetricsJSON">
+		<arg name="metrics" type="s" direction="out"/>
+	</method>
+	<method name="GetDiagnosticsJSON">
+		<arg name="diagnostics" type="s" direction="out"/>
+	</method>
+	<method name="GetPacketLogJSON">
+		<arg name="after" type="t" direction="in"/>
+		<arg name="packets" type="s" direction="out"/>
+	</method>
+	<signal name="StatesChanged">
+		<arg name="states" type="s"/>
+	</signal>
+	<signal name="SettingChanged">
+		<arg name="address" type="s"/>
+		<arg name="id" type="y"/>
+		<arg name="value" type="ay"/>
+	</signal>
+	<signal name="DeviceEvent">
+		<arg name="address" type="s"/>
+		<arg name="event" type="s"/>
+	</signal>`
+
+// noiseModeNames are the names of the noise control modes used by the API
+var noiseModeNames = map[aap.NoiseControlMode]string{
+	aap.NoiseControlOff:          "off",
+	aap.NoiseControlANC:          "noise-cancellation",
+	aap.NoiseControlTransparency: "transparency",
+	aap.NoiseControlAdaptive:     "adaptive",
+}
+
+// audioProfileNames are the names of the audio profiles used by the API
+var audioProfileNames = map[podstate.AudioProfile]string{
+	podstate.AudioProfileUnknown: "",
+	podstate.AudioProfileOff:     "off",
+	podstate.AudioProfileA2DP:    "a2dp",
+	podstate.AudioProfileHeadset: "headset",
+}
+
+// proximityNames are the names of the estimated distances used by the API
+var proximityNames = map[ble.Proximity]string{
+	ble.ProximityUnknown: "",
+	ble.ProximityNear:    "near",
+	ble.ProximityFar:     "far",
+}
+
+// Service publishes the coordinator state as org.linuxpods.Daemon1
+type Service struct {
+	conn  *dbus.Conn
+	coord *podstate.PodStateCoordinator
+
+	mu      sync.Mutex
+	root    *prop.Properties
+	devices map[string]*prop.Properties // MAC address -> properties of the exported device object
+	closed  bool
+}
+
+// NewService connects to the session bus, exports the API and claims the service name.
+// It fails if another instance already owns the name.
+func NewService(coord *podstate.PodStateCoordinator) (*Service, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+
+	s := &Service{
+		conn:    conn,
+		coord:   coord,
+		devices: make(map[string]*prop.Properties),
+	}
+	if err := s.export(); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to export %s: %w", Interface, err)
+	}
+
+	reply, err := conn.RequestName(ServiceName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to request name %s: %w", ServiceName, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		_ = conn.Close()
+		return nil, fmt.Errorf("name %s is already taken, is LinuxPods already running?", ServiceName)
+	}
+
+	coord.Subscribe(s.handleEvent)
+	coord.RegisterSettingsCallback(func(macAddr string, cmd aap.ControlCommand) {
+		s.emit("SettingChanged", macAddr, uint8(cmd.ID), cmd.Value[:])
+		if cmd.ID == aap.ControlListeningMode {
+			s.update(coord.GetDeviceStates())
+		}
+	})
+	s.update(coord.GetDeviceStates())
+
+	return s, nil
+}
+
+// export exports the methods, properties and introspection data of the root object
+func (s *Service) export() error {
+	if err := s.conn.Export(daemonMethods{s}, ObjectPath, Interface); err != nil {
+		return err
+	}
+
+	root, err := prop.Export(s.conn, ObjectPath, prop.Map{
+		Interface: {
+			"Devices": {Value: []dbus.ObjectPath{}, Emit: prop.EmitTrue},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	s.root = root
+
+	node := fmt.Sprintf(`<node>
+	<interface name="%s">%s
+		<property name="Devices" type="ao" access="read"/>
+	</interface>%s%s
+</node>`, Interface, daemonIntrospection, introspect.IntrospectDataString, prop.IntrospectDataString)
+	return s.conn.Export(introspect.Introspectable(node), ObjectPath, "org.freedesktop.DBus.Introspectable")
+}
+
+// handleEvent publishes the events of the coordinator: the states as properties and
+// StatesChanged signal, and the events that don't follow from them as DeviceEvent signal
+func (s *Service) handleEvent(event podstate.Event) {
+	if e, ok := event.(podstate.StatesChanged); ok {
+		s.update(e.States)
+		return
+	}
+	if address, name, ok := deviceEventName(event); ok {
+		s.emit("DeviceEvent", address, name)
+	}
+}
+
+// update exports, updates and removes the device objects to match the coordinator states
+func (s *Service) update(states map[string]*podstate.PodState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	devicesChanged := false
+	for macAddr, state := range states {
+		values := s.deviceProperties(macAddr, state)
+		props, ok := s.devices[macAddr]
+		if !ok {
+			var err error
+			if props, err = s.exportDevice(macAddr, values); err != nil {
+				log.Printf("D-Bus: Failed to export device %s: %v", macAddr, err)
+				continue
+			}
+			s.devices[macAddr] = props
+			devicesChanged = true
+			continue
+		}
+		for name, value := range values {
+			if props.GetMust(DeviceInterface, name) != value.Value() {
+				props.SetMust(DeviceInterface, name, value.Value())
+			}
+		}
+	}
+	for macAddr := range s.devices {
+		if _, ok := states[macAddr]; !ok {
+			s.unexportDevice(macAddr)
+			delete(s.devices, macAddr)
+			devicesChanged = true
+		}
+	}
+
+	if statesJSON, err := json.Marshal(podstate.RedactKeys(states)); err == nil {
+		s.emitLocked("StatesChanged", string(statesJSON))
+	}
+
+	if devicesChanged {
+		paths := make([]dbus.ObjectPath, 0, len(s.devices))
+		for macAddr := range s.devices {
+			paths = append(paths, devicePath(macAddr))
+		}
+		sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+		s.root.SetMust(Interface, "Devices", paths)
+	}
+}
+
+// emit emits a signal of the root object
+func (s *Service) emit(name string, values ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emitLocked(name, values...)
+}
+
+// emitLocked emits a signal of the root object. Must be called with mu held.
+func (s *Service) emitLocked(name string, values ...interface{}) {
+	if s.closed {
+		return
+	}
+	if err := s.conn.Emit(ObjectPath, Interface+"."+name, values...); err != nil {
+		log.Printf("D-Bus: Failed to emit %s: %v", name, err)
+	}
+}
+
+// exportDevice exports the object of a device with the given properties
+func (s *Service) exportDevice(macAddr string, values map[string]dbus.Variant) (*prop.Properties, error) {
+	path := devicePath(macAddr)
+	deviceProps := make(map[string]*prop.Prop, len(values))
+	for name, value := range values {
+		deviceProps[name] = &prop.Prop{Value: value.Value(), Emit: prop.EmitTrue}
+	}
+	props, err := prop.Export(s.conn, path, prop.Map{DeviceInterface: deviceProps})
+	if err != nil {
+		return nil, err
+	}
+
+	node := &introspect.Node{
+		Name: string(path),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{Name: DeviceInterface, Properties: props.Introspection(DeviceInterface)},
+		},
+	}
+	if err := s.conn.Export(introspect.NewIntrospectable(node), path, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return nil, err
+	}
+	return props, nil
+}
+
+// unexportDevice removes the object of a device from the bus
+func (s *Service) unexportDevice(macAddr string) {
+	path := devicePath(macAddr)
+	_ = s.conn.Export(nil, path, "org.freedesktop.DBus.Properties")
+	_ = s.conn.Export(nil, path, "org.freedesktop.DBus.Introspectable")
+}
+
+// deviceProperties returns the org.linuxpods.Device1 properties of a device state.
+// Unknown battery levels are -1.
+func (s *Service) deviceProperties(macAddr string, state *podstate.PodState) map[string]dbus.Variant {
+	level := func(value *int) int32 {
+		if value == nil {
+			return -1
+		}
+		return int32(*value)
+	}
+	noiseMode := ""
+	if mode, ok := s.coord.GetNoiseMode(macAddr); ok {
+		noiseMode = noiseModeNames[mode]
+	}
+
+	return map[string]dbus.Variant{
+		"Address":       dbus.MakeVariant(macAddr),
+		"Name":          dbus.MakeVariant(state.ModelName),
+		"Alias":         dbus.MakeVariant(state.Alias),
+		"Source":        dbus.MakeVariant(state.Source.String()),
+		"Connected":     dbus.MakeVariant(state.Source == podstate.DataSourceAAP),
+		"OwnDevice":     dbus.MakeVariant(state.IsOwnDevice),
+		"Stale":         dbus.MakeVariant(state.Stale),
+		"LastSeen":      dbus.MakeVariant(state.LastSeen.Unix()),
+		"LeftBattery":   dbus.MakeVariant(level(state.LeftBattery)),
+		"RightBattery":  dbus.MakeVariant(level(state.RightBattery)),
+		"CaseBattery":   dbus.MakeVariant(level(state.CaseBattery)),
+		"Battery":       dbus.MakeVariant(level(state.Battery)),
+		"LeftCharging":  dbus.MakeVariant(state.LeftCharging),
+		"RightCharging": dbus.MakeVariant(state.RightCharging),
+		"CaseCharging":  dbus.MakeVariant(state.CaseCharging),
+		"Charging":      dbus.MakeVariant(state.Charging),
+		"LeftInEar":     dbus.MakeVariant(state.LeftInEar),
+		"RightInEar":    dbus.MakeVariant(state.RightInEar),
+		"LeftInCase":    dbus.MakeVariant(state.LeftInCase),
+		"RightInCase":   dbus.MakeVariant(state.RightInCase),
+		"LidOpen":       dbus.MakeVariant(state.LidOpen),
+		"LidOpenCount":  dbus.MakeVariant(state.LidOpenCount),
+		"NoiseMode":     dbus.MakeVariant(noiseMode),
+
+		"RSSI":            dbus.MakeVariant(state.RSSI),
+		"Proximity":       dbus.MakeVariant(proximityNames[state.Proximity]),
+		"Status":          dbus.MakeVariant(state.Status),
+		"ConnectionState": dbus.MakeVariant(connectionState(state)),
+
+		"AudioProfile":    dbus.MakeVariant(audioProfileNames[state.AudioProfile]),
+		"MicrophoneInUse": dbus.MakeVariant(state.MicrophoneInUse),
+	}
+}
+
+// connectionState returns the name of the BLE connection state of a device, empty if it is
+// unknown or wasn't received via BLE
+func connectionState(state *podstate.PodState) string {
+	if _, ok := state.Sources[podstate.FieldAdvertisement]; !ok {
+		return ""
+	}
+	return ble.ConnectionStateName(state.ConnectionState)
+}
+
+// devicePath returns the object path of a device, e.g. .../devices/dev_AA_BB_CC_DD_EE_FF
+func devicePath(macAddr string) dbus.ObjectPath {
+	return dbus.ObjectPath(devicePathPrefix + strings.ReplaceAll(strings.ToUpper(macAddr), ":", "_"))
+}
+
+// Close releases the service name and closes the session bus connection
+func (s *Service) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.conn.Close()
+}
+
+// daemonMethods implements the methods of org.linuxpods.Daemon1.
+// It is a separate type so that only these methods are exported on the bus.
+type daemonMethods struct {
+	s *Service
+}
+
+// GetState returns the properties of all devices by MAC address
+func (d daemonMethods) GetState() (map[string]map[string]dbus.Variant, *dbus.Error) {
+	states := d.s.coord.GetDeviceStates()
+	result := make(map[string]map[string]dbus.Variant, len(states))
+	for macAddr, state := range states {
+		result[macAddr] = d.s.deviceProperties(macAddr, state)
+	}
+	return result, nil
+}
+
+// SetNoiseMode switches the noise control mode of a connected device
+func (d daemonMethods) SetNoiseMode(address string, mode string) *dbus.Error {
+	for m, name := range noiseModeNames {
+		if name == mode {
+			return toDBusError(d.s.coord.SetNoiseMode(address, m))
+		}
+	}
+	return dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs",
+		[]interface{}{fmt.Sprintf("unknown noise mode %q (expected off, noise-cancellation, transparency or adaptive)", mode)})
+}
+
+// ConnectDevice opens an AAP connection to a device connected via Bluetooth
+func (d daemonMethods) ConnectDevice(address string) *dbus.Error {
+	return toDBusError(d.s.coord.ConnectAAP(address))
+}
+
+// RequestKeys retrieves the encryption keys of a connected device
+func (d daemonMethods) RequestKeys(address string) *dbus.Error {
+	return toDBusError(d.s.coord.RequestEncryptionKeys(address))
+}
+
+// ConnectBluetooth connects a paired device via Bluetooth
+func (d daemonMethods) ConnectBluetooth(address string) *dbus.Error {
+	return toDBusError(d.s.coord.ConnectBluetooth(address))
+}
+
+// DisconnectBluetooth disconnects a device from Bluetooth
+func (d daemonMethods) DisconnectBluetooth(address string) *dbus.Error {
+	return toDBusError(d.s.coord.DisconnectBluetooth(address))
+}
+
+// FindPairableDevice searches for AirPods in pairing mode for up to timeout seconds
+func (d daemonMethods) FindPairableDevice(timeout uint32) (string, string, *dbus.Error) {
+	device, err := d.s.coord.FindPairableDevice(time.Duration(timeout) * time.Second)
+	if err != nil {
+		return "", "", toDBusError(err)
+	}
+	return device.Address, device.Name, nil
+}
+
+// PairBluetooth pairs with a device in pairing mode
+func (d daemonMethods) PairBluetooth(address string) *dbus.Error {
+	return toDBusError(d.s.coord.PairBluetooth(address))
+}
+
+// BluetoothPowered checks if a Bluetooth adapter is turned on
+func (d daemonMethods) BluetoothPowered() (bool, *dbus.Error) {
+	powered, err := d.s.coord.BluetoothPowered()
+	return powered, toDBusError(err)
+}
+
+// PowerOnBluetooth turns on the Bluetooth adapter
+func (d daemonMethods) PowerOnBluetooth() *dbus.Error {
+	return toDBusError(d.s.coord.PowerOnBluetooth())
+}
+
+// toDBusError converts an error into a D-Bus error reply
+func toDBusError(err error) *dbus.Error {
+	if err == nil {
+		return nil
+	}
+	return dbus.NewError(Interface+".Error.Failed", []interface{}{err.Error()})
+}
+
+// DisconnectDevice closes the AAP connection to a device and stops reconnecting
+func (d daemonMethods) DisconnectDevice(address string) *dbus.Error {
+	d.s.coord.DisconnectAAP(address)
+	return nil
+}
+
+// ReconnectDevice connects to a device via AAP in the background, retrying with backoff
+func (d daemonMethods) ReconnectDevice(address string) *dbus.Error {
+	d.s.coord.ReconnectAAP(address)
+	return nil
+}
+
+// GetConnectedDevices returns the addresses of all devices with an AAP connection
+func (d daemonMethods) GetConnectedDevices() ([]string, *dbus.Error) {
+	macs := d.s.coord.GetConnectedDeviceMacs()
+	if macs == nil {
+		macs = []string{}
+	}
+	return macs, nil
+}
+
+// StartFastScan starts a burst of frequent BLE updates
+func (d daemonMethods) StartFastScan() *dbus.Error {
+	d.s.coord.StartFastScan()
+	return nil
+}
+
+// SetInteractive reports whether a window shows the state (see PodStateCoordinator.SetInteractive)
+func (d daemonMethods) SetInteractive(interactive bool) *dbus.Error {
+	d.s.coord.SetInteractive(interactive)
+	return nil
+}
+
+// ImportKeys stores the keys of a device that is not paired to this machine.
+// An empty key is treated as missing.
+func (d daemonMethods) ImportKeys(address string, encKey []byte, irk []byte) *dbus.Error {
+	if len(encKey) == 0 {
+		encKey = nil
+	}
+	if len(irk) == 0 {
+		irk = nil
+	}
+	return toDBusError(d.s.coord.ImportKeys(address, encKey, irk))
+}
+
+// SetEncryptionKey sets the ENC_KEY of a device. An empty key removes it.
+func (d daemonMethods) SetEncryptionKey(address string, encKey []byte) *dbus.Error {
+	if len(encKey) == 0 {
+		encKey = nil
+	}
+	return toDBusError(d.s.coord.SetEncryptionKey(address, encKey))
+}
+
+// ExportKeys writes the keys of all devices to a file. The daemon writes the file, so the
+// keys are never sent over the bus.
+func (d daemonMethods) ExportKeys(path string) *dbus.Error {
+	return toDBusError(d.s.coord.ExportKeys(path))
+}
+
+// ImportKeysFile imports the keys of a file written by ExportKeys and returns the number of devices
+func (d daemonMethods) ImportKeysFile(path string) (uint32, *dbus.Error) {
+	n, err := d.s.coord.ImportKeysFile(path)
+	return uint32(n), toDBusError(err)
+}
+
+// SetNoiseControlCycle sets the noise control modes of the press and hold gesture
+func (d daemonMethods) SetNoiseControlCycle(address string, cycle uint8) *dbus.Error {
+	return toDBusError(d.s.coord.SetNoiseControlCycle(address, aap.NoiseControlCycle(cycle)))
+}
+
+// SetMicrophoneMode selects which bud's microphone the device uses
+func (d daemonMethods) SetMicrophoneMode(address string, mode uint8) *dbus.Error {
+	return toDBusError(d.s.coord.SetMicrophoneMode(address, aap.MicrophoneMode(mode)))
+}
+
+// SetEarDetection enables or disables automatic ear detection
+func (d daemonMethods) SetEarDetection(address string, enabled bool) *dbus.Error {
+	return toDBusError(d.s.coord.SetEarDetection(address, enabled))
+}
+
+// SetToneVolume sets the volume of the tones played by the device
+func (d daemonMethods) SetToneVolume(address string, volume uint8) *dbus.Error {
+	return toDBusError(d.s.coord.SetToneVolume(address, volume))
+}
+
+// SetPressAndHold sets the press and hold action of each bud
+func (d daemonMethods) SetPressAndHold(address string, left uint8, right uint8) *dbus.Error {
+	pressAndHold := aap.PressAndHold{Left: aap.PressAndHoldAction(left), Right: aap.PressAndHoldAction(right)}
+	return toDBusError(d.s.coord.SetPressAndHold(address, pressAndHold))
+}
+
+// SetPressSpeed sets how fast the stem must be pressed repeatedly
+func (d daemonMethods) SetPressSpeed(address string, speed uint8) *dbus.Error {
+	return toDBusError(d.s.coord.SetPressSpeed(address, aap.PressSpeed(speed)))
+}
+
+// SetHoldDuration sets how long the stem must be held for press and hold
+func (d daemonMethods) SetHoldDuration(address string, duration uint8) *dbus.Error {
+	return toDBusError(d.s.coord.SetHoldDuration(address, aap.HoldDuration(duration)))
+}
+
+// SetVolumeSwipe enables or disables volume control by swiping the stem
+func (d daemonMethods) SetVolumeSwipe(address string, enabled bool) *dbus.Error {
+	return toDBusError(d.s.coord.SetVolumeSwipe(address, enabled))
+}
+
+// reportedSetting is a setting value reported by a device, as returned by GetSettings
+type reportedSetting struct {
+	Address string
+	ID      uint8
+	Value   []byte
+}
+
+// GetSettings returns all settings reported by connected devices
+func (d daemonMethods) GetSettings() ([]reportedSetting, *dbus.Error) {
+	settings := []reportedSetting{}
+	for _, macAddr := range d.s.coord.GetConnectedDeviceMacs() {
+		deviceSettings, err := d.s.coord.GetDeviceSettings(macAddr)
+		if err != nil {
+			continue
+		}
+		for id, value := range deviceSettings.Raw {
+			settings = append(settings, reportedSetting{Address: macAddr, ID: uint8(id), Value: value[:]})
+		}
+	}
+	return settings, nil
+}
+
+// GetStatesJSON returns all device states as JSON
+func (d daemonMethods) GetStatesJSON() (string, *dbus.Error) {
+	return marshalJSON(podstate.RedactKeys(d.s.coord.GetDeviceStates()))
+}
+
+// GetBatteryHistoryJSON returns the battery samples of a device recorded since a Unix time as JSON
+func (d daemonMethods) GetBatteryHistoryJSON(address string, since int64) (string, *dbus.Error) {
+	return marshalJSON(d.s.coord.BatteryHistory(address, time.Unix(since, 0)))
+}
+
+// GetBatteryEstimatesJSON returns the drain rates and remaining times of a device's batteries as JSON
+func (d daemonMethods) GetBatteryEstimatesJSON(address string) (string, *dbus.Error) {
+	return marshalJSON(d.s.coord.BatteryEstimates(address))
+}
+
+// GetMetricsJSON returns the coordinator metrics as JSON
+func (d daemonMethods) GetMetricsJSON() (string, *dbus.Error) {
+	return marshalJSON(d.s.coord.Metrics())
+}
+
+// GetDiagnosticsJSON returns the unparsed AAP packets of the connected devices as JSON
+func (d daemonMethods) GetDiagnosticsJSON() (string, *dbus.Error) {
+	return marshalJSON(d.s.coord.AAPDiagnostics())
+}
+
+// GetPacketLogJSON returns the recent raw packets after a sequence number as JSON
+func (d daemonMethods) GetPacketLogJSON(after uint64) (string, *dbus.Error) {
+	return marshalJSON(d.s.coord.PacketLog(after))
+}
+
+// marshalJSON encodes a value as JSON for a method reply
+func marshalJSON(v interface{}) (string, *dbus.Error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", toDBusError(fmt.Errorf("failed to encode JSON: %w", err))
+	}
+	return string(data), nil
+}
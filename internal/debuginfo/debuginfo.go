@@ -0,0 +1,200 @@
+// Package debuginfo bundles what is needed to investigate a bug report into a tarball: the
+// build, the Bluetooth adapters, the device states and models, the recently received
+// packets with their parse results, the coordinator metrics and the recent log output.
+//
+// Encryption keys are never included: the device states are redacted and key packets are
+// not in the packet log.
+package debuginfo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/annotator"
+	"linuxpods/internal/bluez"
+	"linuxpods/internal/podstate"
+	"linuxpods/internal/version"
+)
+
+// daemonUnit is the systemd user unit of the daemon, whose journal is included if it runs
+const daemonUnit = "linuxpods-daemon.service"
+
+// journalLines is how many lines of the daemon's journal are included
+const journalLines = "2000"
+
+// FileName returns the default name of a bundle created now
+func FileName() string {
+	return "linuxpods-debug-" + time.Now().Format("20060102-150405") + ".tar.gz"
+}
+
+// Write writes a gzipped tarball with the debug information to w. backend is the coordinator
+// of the GUI or the client of the daemon. Parts that can't be collected, e.g. without a
+// Bluetooth adapter, contain the error instead.
+func Write(w io.Writer, backend podstate.Backend) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	states := podstate.RedactKeys(backend.GetDeviceStates())
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"info.txt", []byte(Summary())},
+		{"adapters.txt", adapters()},
+		{"devices.json", marshal(states)},
+		{"models.txt", models(states)},
+		{"packets.txt", packets(backend.PacketLog(0))},
+		{"metrics.json", marshal(backend.Metrics())},
+		{"unparsed_aap.json", marshal(backend.AAPDiagnostics())},
+		{"log.txt", logs()},
+		{"daemon_journal.txt", daemonJournal()},
+	}
+
+	modTime := time.Now()
+	for _, file := range files {
+		header := &tar.Header{Name: file.name, Mode: 0o644, Size: int64(len(file.data)), ModTime: modTime}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write tarball: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress tarball: %w", err)
+	}
+	return nil
+}
+
+// WriteFile writes the debug information to a tarball at path
+func WriteFile(path string, backend podstate.Backend) error {
+	var buf bytes.Buffer
+	if err := Write(&buf, backend); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to save debug info: %w", err)
+	}
+	return nil
+}
+
+// Summary describes the build and the system, e.g. for the About window
+func Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "LinuxPods %s\n", version.String())
+	fmt.Fprintf(&b, "Go: %s\n", runtime.Version())
+	fmt.Fprintf(&b, "OS: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	if kernel, err := os.ReadFile("/proc/version"); err == nil {
+		fmt.Fprintf(&b, "Kernel: %s\n", strings.TrimSpace(string(kernel)))
+	}
+	for _, env := range []string{"XDG_CURRENT_DESKTOP", "XDG_SESSION_TYPE", "LANG"} {
+		fmt.Fprintf(&b, "%s: %s\n", env, os.Getenv(env))
+	}
+	fmt.Fprintf(&b, "Created: %s\n", time.Now().Format(time.RFC3339))
+	return b.String()
+}
+
+// adapters describes the Bluetooth adapters
+func adapters() []byte {
+	infos, err := bluez.Adapters()
+	if err != nil {
+		return []byte(fmt.Sprintf("Error: %v\n", err))
+	}
+	var b strings.Builder
+	for _, info := range infos {
+		fmt.Fprintf(&b, "%s: %s (%s)\n", info.Path, info.Address, info.Name)
+		fmt.Fprintf(&b, "  Modalias: %s\n", info.Modalias)
+		fmt.Fprintf(&b, "  Powered: %t, Discoverable: %t, Discovering: %t\n", info.Powered, info.Discoverable, info.Discovering)
+	}
+	return []byte(b.String())
+}
+
+// models describes the model of each device as identified from its model code, with the
+// firmware reported over AAP
+func models(states map[string]*podstate.PodState) []byte {
+	addresses := make([]string, 0, len(states))
+	for macAddr := range states {
+		addresses = append(addresses, macAddr)
+	}
+	sort.Strings(addresses)
+
+	var b strings.Builder
+	for _, macAddr := range addresses {
+		state := states[macAddr]
+		fmt.Fprintf(&b, "%s: model 0x%04X, color 0x%02X\n", macAddr, state.DeviceModel, state.Color)
+		if model, ok := aap.LookupModel(state.DeviceModel); ok {
+			fmt.Fprintf(&b, "  Known model: %s (%s)\n", model.Name, model.Family)
+		} else {
+			fmt.Fprintf(&b, "  Unknown model, all features are enabled\n")
+		}
+		fmt.Fprintf(&b, "  Capabilities: %+v\n", state.Capabilities)
+		if info := state.DeviceInfo; info != nil {
+			fmt.Fprintf(&b, "  Firmware: %s, model number: %s\n", info.FirmwareVersion, info.ModelNumber)
+		}
+	}
+	return []byte(b.String())
+}
+
+// packets lists the packets of the packet log with their parse results
+func packets(log []podstate.LoggedPacket) []byte {
+	var b strings.Builder
+	for _, packet := range log {
+		fmt.Fprintf(&b, "%s %s %s", packet.Time.Format("15:04:05.000"), packet.Kind, packet.Address)
+		if packet.RSSI != 0 {
+			fmt.Fprintf(&b, " %d dBm", packet.RSSI)
+		}
+		fmt.Fprintf(&b, "\n  %s\n", hex.EncodeToString(packet.Data))
+		var description string
+		if packet.Kind == podstate.PacketBLE {
+			description = annotator.DescribeAdvertisement(packet.Data)
+		} else {
+			description = annotator.DescribeAAPPacket(packet.Data)
+		}
+		for _, line := range strings.Split(strings.TrimRight(description, "\n"), "\n") {
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+	return []byte(b.String())
+}
+
+// logs returns the recent log output of this process
+func logs() []byte {
+	if recentLogs == nil {
+		return []byte("Log output is not captured\n")
+	}
+	return recentLogs.bytes()
+}
+
+// daemonJournal returns the end of the daemon's journal, if it runs as a systemd user service
+func daemonJournal() []byte {
+	output, err := exec.Command("journalctl", "--user", "--unit", daemonUnit, "--lines", journalLines, "--no-pager").CombinedOutput()
+	if err != nil {
+		return []byte(fmt.Sprintf("Error: %v\n%s", err, output))
+	}
+	return output
+}
+
+// marshal encodes a value as indented JSON, or the error
+func marshal(v interface{}) []byte {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return []byte(fmt.Sprintf("Error: %v\n", err))
+	}
+	return data
+}
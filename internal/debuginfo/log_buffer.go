@@ -0,0 +1,51 @@
+package debuginfo
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// logBufferSize is how many bytes of the most recent log output are kept for bug reports
+const logBufferSize = 256 * 1024
+
+// logBuffer keeps the end of the log output. It is safe for concurrent use.
+type logBuffer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+// Write appends log output, dropping the oldest output beyond logBufferSize
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.data = append(b.data, p...)
+	if excess := len(b.data) - logBufferSize; excess > 0 {
+		// Drop whole lines, so the kept output starts with a complete line
+		if i := bytes.IndexByte(b.data[excess:], '\n'); i >= 0 {
+			excess += i + 1
+		}
+		b.data = append(b.data[:0], b.data[excess:]...)
+	}
+	return len(p), nil
+}
+
+// bytes returns a copy of the kept output
+func (b *logBuffer) bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.data...)
+}
+
+// recentLogs is the log output kept since CaptureLogs was called
+var recentLogs *logBuffer
+
+// CaptureLogs keeps the most recent output of the standard logger for bug reports, in
+// addition to writing it to stderr. It is called once at startup.
+func CaptureLogs() {
+	recentLogs = &logBuffer{}
+	log.SetOutput(io.MultiWriter(os.Stderr, recentLogs))
+}
@@ -0,0 +1,232 @@
+// Package demo runs the coordinator against a simulated pair of AirPods that plays a scripted
+// scene in a loop: taken out of the case, worn, one pod removed, put back and charged, with the
+// battery levels changing along the way. It feeds the GUI in --demo mode, so the GTK code can
+// be worked on and screenshots taken without AirPods or a Bluetooth adapter.
+package demo
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/podstate"
+	"linuxpods/internal/simulator"
+)
+
+const (
+	// DeviceAddress is the real MAC address of the simulated AirPods
+	DeviceAddress = "DE:40:00:00:00:01"
+
+	// AirPods Pro (2nd generation, USB-C)
+	deviceModel = 0x2420
+
+	advertisementInterval = 500 * time.Millisecond
+
+	// tick is how often the battery levels change
+	tick = time.Second
+)
+
+// scene is a step of the script: where the pods are for how long
+type scene struct {
+	name      string
+	duration  time.Duration
+	placement simulator.Placement
+	primary   aap.BatteryComponent
+	cable     bool // The case is charging from a cable
+}
+
+// script is played in a loop. It ends with the pods back in the case, where it starts.
+var script = []scene{
+	{"In the closed case, charging", 12 * time.Second, placement(aap.EarStatusInCase, aap.EarStatusInCase, false), aap.ComponentLeft, true},
+	{"Lid opened", 5 * time.Second, placement(aap.EarStatusInCase, aap.EarStatusInCase, true), aap.ComponentLeft, false},
+	{"In both ears", 20 * time.Second, placement(aap.EarStatusInEar, aap.EarStatusInEar, false), aap.ComponentLeft, false},
+	{"Left pod taken out", 8 * time.Second, placement(aap.EarStatusOutOfEar, aap.EarStatusInEar, false), aap.ComponentRight, false},
+	{"Back in both ears", 15 * time.Second, placement(aap.EarStatusInEar, aap.EarStatusInEar, false), aap.ComponentRight, false},
+	{"Put back in the case", 5 * time.Second, placement(aap.EarStatusInCase, aap.EarStatusInCase, true), aap.ComponentLeft, false},
+}
+
+func placement(left, right aap.EarStatus, lidOpen bool) simulator.Placement {
+	return simulator.Placement{Left: left, Right: right, LidOpen: lidOpen}
+}
+
+// Demo is a running demo: the coordinator and the simulated device it shows
+type Demo struct {
+	Coordinator *podstate.PodStateCoordinator
+
+	device *simulator.Device
+	source *simulator.AdvertisementSource
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// Start creates the simulated device and a coordinator for it, connects it and plays the
+// script until Close.
+func Start() (*Demo, error) {
+	encKey, irk := make([]byte, 16), make([]byte, 16)
+	if _, err := rand.Read(encKey); err != nil {
+		return nil, fmt.Errorf("failed to generate keys: %w", err)
+	}
+	if _, err := rand.Read(irk); err != nil {
+		return nil, fmt.Errorf("failed to generate keys: %w", err)
+	}
+	device, err := simulator.NewDevice(DeviceAddress, deviceModel, encKey, irk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create simulated device: %w", err)
+	}
+	bleMac, err := device.ResolvableAddress()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create simulated device: %w", err)
+	}
+	device.SetBattery(simulator.Battery{Left: 84, Right: 79, Case: 62})
+	device.SetPlacement(script[0].placement)
+
+	d := &Demo{
+		device: device,
+		source: device.NewAdvertisementSource(bleMac, advertisementInterval),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	bt := &bluetooth{demo: d, connected: true}
+	d.Coordinator = podstate.NewPodStateCoordinatorWithSource(d.source,
+		podstate.WithAAPDialer(device.Dial),
+		podstate.WithBluetooth(bt),
+	)
+	// The keys are known, like those of AirPods whose keys were fetched before
+	if err := d.Coordinator.ImportKeys(DeviceAddress, encKey, irk); err != nil {
+		_ = d.Coordinator.Close()
+		return nil, fmt.Errorf("failed to import keys: %w", err)
+	}
+	log.Printf("Demo: Simulating AirPods %s", DeviceAddress)
+
+	// Connect like real AirPods do when LinuxPods starts
+	go d.connect()
+	go d.play()
+	return d, nil
+}
+
+// Close stops the script and the coordinator
+func (d *Demo) Close() error {
+	close(d.stop)
+	<-d.done
+	_ = d.source.Close()
+	return d.Coordinator.Close()
+}
+
+// play loops through the script, changing the battery levels every tick
+func (d *Demo) play() {
+	defer close(d.done)
+
+	for {
+		for _, s := range script {
+			log.Printf("Demo: %s", s.name)
+			d.device.SetPrimary(s.primary)
+			d.device.SetPlacement(s.placement)
+
+			ticker := time.NewTicker(tick)
+			end := time.After(s.duration)
+		scene:
+			for {
+				select {
+				case <-ticker.C:
+					d.device.SetBattery(nextBattery(d.device.Battery(), s))
+				case <-end:
+					break scene
+				case <-d.stop:
+					ticker.Stop()
+					return
+				}
+			}
+			ticker.Stop()
+		}
+	}
+}
+
+// connect opens the AAP session of the simulated device
+func (d *Demo) connect() {
+	if err := d.Coordinator.ConnectAAP(DeviceAddress); err != nil {
+		log.Printf("Demo: Failed to connect: %v", err)
+	}
+}
+
+// nextBattery returns the battery levels one tick later: pods in an ear drain, pods in the
+// case charge from it, and the case charges while it is on the cable
+func nextBattery(b simulator.Battery, s scene) simulator.Battery {
+	b.Left, b.LeftCharging = nextPodLevel(b.Left, s.placement.Left, &b.Case)
+	b.Right, b.RightCharging = nextPodLevel(b.Right, s.placement.Right, &b.Case)
+	b.CaseCharging = s.cable
+	if s.cable {
+		b.Case = min(b.Case+2, 100)
+	}
+	return b
+}
+
+// nextPodLevel returns the level of a pod one tick later and whether it is charging, taking
+// the charge from the case
+func nextPodLevel(level uint8, status aap.EarStatus, caseLevel *uint8) (uint8, bool) {
+	switch {
+	case status == aap.EarStatusInEar:
+		return max(level, 2) - 1, false
+	case status == aap.EarStatusInCase && level < 100 && *caseLevel > 1:
+		*caseLevel--
+		return min(level+3, 100), true
+	}
+	return level, false
+}
+
+// bluetooth pretends the simulated device is paired and connected. Connecting it opens its
+// AAP session, pairing finds nothing.
+// It implements podstate.BluetoothController.
+type bluetooth struct {
+	demo *Demo
+
+	mu        sync.Mutex
+	connected bool
+}
+
+func (b *bluetooth) ConnectedAirPods() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.connected {
+		return nil, nil
+	}
+	return []string{DeviceAddress}, nil
+}
+
+func (b *bluetooth) Connect(macAddr string) error {
+	if !strings.EqualFold(macAddr, DeviceAddress) {
+		return fmt.Errorf("no simulated device with address %s", macAddr)
+	}
+	b.mu.Lock()
+	b.connected = true
+	b.mu.Unlock()
+	go b.demo.connect()
+	return nil
+}
+
+func (b *bluetooth) Disconnect(macAddr string) error {
+	if !strings.EqualFold(macAddr, DeviceAddress) {
+		return fmt.Errorf("no simulated device with address %s", macAddr)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.connected = false
+	return nil
+}
+
+func (b *bluetooth) FindPairable(ctx context.Context) (podstate.PairableDevice, error) {
+	<-ctx.Done()
+	return podstate.PairableDevice{}, fmt.Errorf("no AirPods in pairing mode in the demo: %w", ctx.Err())
+}
+
+func (b *bluetooth) Pair(macAddr string) error {
+	return errors.New("pairing is not available in the demo")
+}
+
+func (b *bluetooth) Powered() (bool, error) { return true, nil }
+func (b *bluetooth) PowerOn() error         { return nil }
@@ -0,0 +1,119 @@
+// Package i18n translates user-facing strings with gettext message catalogs.
+//
+// Catalogs are standard gettext .mo files for the "linuxpods" domain, so translations
+// can be maintained with the usual tools (xgettext, msgmerge, msgfmt) from po/linuxpods.pot.
+// Unlike GTK's own gettext integration, this works for every surface, including the
+// system tray menu and tooltips, which are rendered outside of GTK.
+//
+// Catalogs are searched in:
+//   - $LINUXPODS_LOCALEDIR/<lang>/LC_MESSAGES/linuxpods.mo
+//   - ./locale/<lang>/LC_MESSAGES/linuxpods.mo (development builds)
+//   - /usr/local/share/locale and /usr/share/locale
+//
+// The language is taken from LANGUAGE, LC_ALL, LC_MESSAGES or LANG (first non-empty).
+// Untranslated strings are returned unchanged.
+package i18n
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Domain is the gettext domain of LinuxPods catalogs
+const Domain = "linuxpods"
+
+var (
+	mu      sync.RWMutex
+	catalog map[string]string
+)
+
+// Init loads the catalog for the user's language. It is safe to call T before Init
+// (strings are returned untranslated).
+func Init() {
+	for _, lang := range languages() {
+		for _, dir := range localeDirs() {
+			path := filepath.Join(dir, lang, "LC_MESSAGES", Domain+".mo")
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+
+			messages, err := parseMO(data)
+			if err != nil {
+				log.Printf("Warning: Failed to load translations from %s: %v", path, err)
+				continue
+			}
+
+			mu.Lock()
+			catalog = messages
+			mu.Unlock()
+			log.Printf("Loaded %d translations from %s", len(messages), path)
+			return
+		}
+	}
+}
+
+// T returns the translation of msgid, or msgid itself if it isn't translated
+func T(msgid string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if translation, ok := catalog[msgid]; ok && translation != "" {
+		return translation
+	}
+	return msgid
+}
+
+// N marks msgid for translation without translating it, for strings that are defined before
+// the catalog is loaded (e.g. in package-level tables) and translated with T where they are shown
+func N(msgid string) string {
+	return msgid
+}
+
+// Tf translates the format string and formats it with the arguments
+func Tf(format string, args ...interface{}) string {
+	return fmt.Sprintf(T(format), args...)
+}
+
+// languages returns the candidate catalog names for the user's locale, most specific first.
+// For example "de_DE.UTF-8@euro" yields de_DE.UTF-8@euro, de_DE, de.
+func languages() []string {
+	var value string
+	for _, env := range []string{"LANGUAGE", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value = os.Getenv(env); value != "" {
+			break
+		}
+	}
+
+	var langs []string
+	// LANGUAGE may contain a colon-separated priority list
+	for _, locale := range strings.Split(value, ":") {
+		if locale == "" || locale == "C" || locale == "POSIX" {
+			continue
+		}
+		langs = append(langs, locale)
+
+		base := locale
+		if i := strings.IndexAny(base, ".@"); i >= 0 {
+			base = base[:i]
+			langs = append(langs, base)
+		}
+		if i := strings.Index(base, "_"); i >= 0 {
+			langs = append(langs, base[:i])
+		}
+	}
+	return langs
+}
+
+// localeDirs returns the directories searched for catalogs
+func localeDirs() []string {
+	var dirs []string
+	if dir := os.Getenv("LINUXPODS_LOCALEDIR"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	return append(dirs, "locale", "/usr/local/share/locale", "/usr/share/locale")
+}
@@ -0,0 +1,87 @@
+package i18n
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// gettext .mo file magic numbers (little and big endian)
+const (
+	moMagicLE = 0x950412de
+	moMagicBE = 0xde120495
+)
+
+// parseMO parses a gettext .mo catalog into a msgid -> translation map.
+//
+// File format (all values are 32-bit integers):
+//
+//	Offset 0:  Magic number
+//	Offset 4:  File format revision
+//	Offset 8:  Number of strings N
+//	Offset 12: Offset of the original strings table
+//	Offset 16: Offset of the translated strings table
+//
+// Each table has N entries of (length, offset). For plural forms only the singular
+// form is used; strings with a message context are skipped.
+func parseMO(data []byte) (map[string]string, error) {
+	if len(data) < 20 {
+		return nil, fmt.Errorf("file too short (%d bytes)", len(data))
+	}
+
+	var order binary.ByteOrder
+	switch binary.LittleEndian.Uint32(data) {
+	case moMagicLE:
+		order = binary.LittleEndian
+	case moMagicBE:
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("not a gettext catalog (magic 0x%08X)", binary.LittleEndian.Uint32(data))
+	}
+
+	count := order.Uint32(data[8:])
+	originals := order.Uint32(data[12:])
+	translations := order.Uint32(data[16:])
+
+	messages := make(map[string]string, count)
+	for i := uint32(0); i < count; i++ {
+		msgid, err := moString(data, order, originals+i*8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid original string %d: %w", i, err)
+		}
+		translation, err := moString(data, order, translations+i*8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid translated string %d: %w", i, err)
+		}
+
+		// Skip the header entry (empty msgid) and strings with a context (ctx\x04msgid)
+		if msgid == "" || strings.IndexByte(msgid, 0x04) >= 0 {
+			continue
+		}
+
+		messages[untilNull(msgid)] = untilNull(translation)
+	}
+
+	return messages, nil
+}
+
+// moString reads the string described by the table entry at offset
+func moString(data []byte, order binary.ByteOrder, entry uint32) (string, error) {
+	if uint64(entry)+8 > uint64(len(data)) {
+		return "", fmt.Errorf("table entry out of range")
+	}
+	length := order.Uint32(data[entry:])
+	offset := order.Uint32(data[entry+4:])
+	if uint64(offset)+uint64(length) > uint64(len(data)) {
+		return "", fmt.Errorf("string out of range")
+	}
+	return string(data[offset : offset+length]), nil
+}
+
+// untilNull returns s up to the first null byte (the singular form of plural entries)
+func untilNull(s string) string {
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package indicator
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	statusNotifierWatcherName  = "org.kde.StatusNotifierWatcher"
+	statusNotifierWatcherPath  = "/StatusNotifierWatcher"
+	statusNotifierWatcherIface = "org.kde.StatusNotifierWatcher"
+)
+
+// Mode selects how the tray indicator is presented
+type Mode string
+
+const (
+	ModeAuto   Mode = "auto"   // Use the tray if a StatusNotifier host exists, otherwise the mini window
+	ModeTray   Mode = "tray"   // Always use the system tray
+	ModeWindow Mode = "window" // Use a floating GTK mini window instead of the tray
+	ModeOff    Mode = "off"    // Disable the tray entirely
+)
+
+// ParseMode parses a tray mode. An empty string selects ModeAuto.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeAuto:
+		return ModeAuto, nil
+	case ModeTray, ModeWindow, ModeOff:
+		return Mode(s), nil
+	default:
+		return ModeAuto, fmt.Errorf("unknown tray mode %q (expected auto, tray, window or off)", s)
+	}
+}
+
+// ResolveMode resolves ModeAuto to a concrete mode by checking for a StatusNotifier host.
+// Other modes are returned unchanged.
+func ResolveMode(mode Mode) Mode {
+	if mode != ModeAuto {
+		return mode
+	}
+
+	available, err := StatusNotifierHostAvailable()
+	if err != nil {
+		log.Printf("Could not detect a StatusNotifier host: %v", err)
+	}
+	if available {
+		return ModeTray
+	}
+
+	log.Println("No StatusNotifier host found (on GNOME, install the AppIndicator extension) - using the mini window instead of the tray")
+	return ModeWindow
+}
+
+// StatusNotifierHostAvailable reports whether a StatusNotifierItem host is registered on the
+// session bus. Without a host, the system tray icon is never shown (e.g. GNOME without the
+// AppIndicator extension) and systray fails silently.
+func StatusNotifierHostAvailable() (bool, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	var hasOwner bool
+	if err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, statusNotifierWatcherName).Store(&hasOwner); err != nil {
+		return false, fmt.Errorf("failed to query %s: %w", statusNotifierWatcherName, err)
+	}
+	if !hasOwner {
+		return false, nil
+	}
+
+	obj := conn.Object(statusNotifierWatcherName, statusNotifierWatcherPath)
+	variant, err := obj.GetProperty(statusNotifierWatcherIface + ".IsStatusNotifierHostRegistered")
+	if err != nil {
+		return false, fmt.Errorf("failed to query host registration: %w", err)
+	}
+
+	registered, ok := variant.Value().(bool)
+	return ok && registered, nil
+}
@@ -0,0 +1,195 @@
+package indicator
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+)
+
+// iconSize is the width and height of the rendered tray icon. The tray host scales it down
+// to the panel size.
+const iconSize = 64
+
+// maxGlyphScale is the size of a glyph pixel in icon pixels when the text fits. Three digits
+// and the charging bolt are drawn smaller.
+const maxGlyphScale = 3
+
+var (
+	lightColor = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	darkColor  = color.RGBA{0x2E, 0x34, 0x36, 0xFF} // The foreground of light Adwaita panels
+)
+
+// iconPalette is how a variant of the icon is drawn
+type iconPalette struct {
+	symbolic bool        // The AirPods are drawn as a silhouette in the text color
+	text     color.RGBA  // Color of the battery level
+	outline  *color.RGBA // Outline of the battery level, nil for none
+}
+
+// palette returns how an icon style is drawn. styles must be resolved (see IconStyle.resolve).
+func palette(style IconStyle) iconPalette {
+	switch style {
+	case IconLight:
+		return iconPalette{symbolic: true, text: darkColor}
+	case IconDark:
+		return iconPalette{symbolic: true, text: lightColor}
+	default:
+		// The colored image is meant for any panel, so the level is outlined
+		return iconPalette{text: lightColor, outline: &darkColor}
+	}
+}
+
+// glyphs are the characters of the battery level in a 5×7 pixel font. The tray can't use the
+// fonts of the desktop, and a built-in font looks the same on every panel.
+var glyphs = map[rune][7]string{
+	'0': {".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'1': {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	'⚡': {"...#.", "..#..", ".#...", "#####", "...#.", "..#..", ".#..."},
+}
+
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+)
+
+// renderIcon draws the tray icon in a resolved style: the AirPods image with the battery level
+// in percent below it, followed by a bolt while charging. Without a level (-1) the image fills
+// the icon. It returns the icon as PNG, as systray expects.
+func renderIcon(base image.Image, style IconStyle, level int, charging bool) ([]byte, error) {
+	colors := palette(style)
+	if colors.symbolic {
+		base = silhouette(base, colors.text)
+	}
+	icon := image.NewRGBA(image.Rect(0, 0, iconSize, iconSize))
+	if level < 0 {
+		drawScaled(icon, fitRect(base.Bounds(), icon.Bounds()), base)
+		return encodeIcon(icon)
+	}
+
+	text := strconv.Itoa(level)
+	if charging {
+		text += "⚡"
+	}
+	runes := []rune(text)
+
+	// The largest scale that fits the width, keeping one pixel for the outline on each side
+	scale := maxGlyphScale
+	for scale > 1 && textWidth(len(runes), scale)+2 > iconSize {
+		scale--
+	}
+	textHeight := glyphHeight * scale
+	textTop := iconSize - textHeight - 1
+
+	drawScaled(icon, fitRect(base.Bounds(), image.Rect(0, 0, iconSize, textTop-1)), base)
+
+	left := (iconSize - textWidth(len(runes), scale)) / 2
+	for _, r := range runes {
+		drawGlyph(icon, glyphs[r], image.Pt(left, textTop), scale, colors)
+		left += (glyphWidth + 1) * scale
+	}
+	return encodeIcon(icon)
+}
+
+// encodeIcon encodes a rendered icon as PNG
+func encodeIcon(icon image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, icon); err != nil {
+		return nil, fmt.Errorf("failed to encode tray icon: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// textWidth returns the width of n glyphs with one glyph pixel between them
+func textWidth(n int, scale int) int {
+	return (n*(glyphWidth+1) - 1) * scale
+}
+
+// drawGlyph draws a glyph with its top left corner at pos, outlined if the palette has an
+// outline color
+func drawGlyph(dst *image.RGBA, glyph [7]string, pos image.Point, scale int, colors iconPalette) {
+	type pass struct {
+		color image.Image
+		grow  int
+	}
+	passes := []pass{{image.NewUniform(colors.text), 0}}
+	if colors.outline != nil {
+		passes = append([]pass{{image.NewUniform(*colors.outline), 1}}, passes...)
+	}
+	for _, pass := range passes {
+		for y, row := range glyph {
+			for x, pixel := range row {
+				if pixel != '#' {
+					continue
+				}
+				r := image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale).Add(pos).Inset(-pass.grow)
+				draw.Draw(dst, r, pass.color, image.Point{}, draw.Over)
+			}
+		}
+	}
+}
+
+// silhouette returns the shape of an image in a single color, keeping its transparency
+func silhouette(src image.Image, c color.RGBA) image.Image {
+	b := src.Bounds()
+	dst := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			_, _, _, a := src.At(x, y).RGBA()
+			a8 := uint32(a >> 8)
+			// Premultiplied by the alpha of the source pixel
+			dst.SetRGBA(x, y, color.RGBA{
+				uint8(uint32(c.R) * a8 / 0xFF), uint8(uint32(c.G) * a8 / 0xFF), uint8(uint32(c.B) * a8 / 0xFF), uint8(a8),
+			})
+		}
+	}
+	return dst
+}
+
+// fitRect returns the largest rectangle with the aspect ratio of src centered in area
+func fitRect(src, area image.Rectangle) image.Rectangle {
+	w, h := area.Dx(), src.Dy()*area.Dx()/max(src.Dx(), 1)
+	if h > area.Dy() {
+		w, h = src.Dx()*area.Dy()/max(src.Dy(), 1), area.Dy()
+	}
+	topLeft := area.Min.Add(image.Pt((area.Dx()-w)/2, (area.Dy()-h)/2))
+	return image.Rectangle{Min: topLeft, Max: topLeft.Add(image.Pt(w, h))}
+}
+
+// drawScaled draws src scaled to r, averaging the source pixels covered by each pixel
+func drawScaled(dst *image.RGBA, r image.Rectangle, src image.Image) {
+	sb := src.Bounds()
+	if r.Empty() || sb.Empty() {
+		return
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sy0 := sb.Min.Y + (y-r.Min.Y)*sb.Dy()/r.Dy()
+		sy1 := max(sb.Min.Y+(y-r.Min.Y+1)*sb.Dy()/r.Dy(), sy0+1)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sx0 := sb.Min.X + (x-r.Min.X)*sb.Dx()/r.Dx()
+			sx1 := max(sb.Min.X+(x-r.Min.X+1)*sb.Dx()/r.Dx(), sx0+1)
+
+			// Premultiplied sums, so transparent pixels don't darken the edges
+			var rs, gs, bs, as, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					rs, gs, bs, as = rs+cr, gs+cg, bs+cb, as+ca
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(rs / n), uint16(gs / n), uint16(bs / n), uint16(as / n)})
+		}
+	}
+}
@@ -1,10 +1,14 @@
 package indicator
 
 import (
+	"bytes"
 	"fmt"
-	"linuxpods/internal/util"
+	"image"
+	_ "image/png" // Decodes the tray icon
+	"linuxpods/assets"
+	"linuxpods/internal/i18n"
 	"log"
-	"os"
+	"sync"
 
 	"fyne.io/systray"
 )
@@ -31,103 +35,91 @@ const (
 
 // Indicator manages the system tray icon and menu
 type Indicator struct {
-	batteries         BatteryLevels
-	noiseMode         NoiseMode
 	onShowWindow      func()
 	onQuit            func()
-	onNoiseModeChange func(NoiseMode)
-
-	// Menu items
-	batteryItems   [3]*systray.MenuItem
-	noiseModeItems map[NoiseMode]*systray.MenuItem
+	onNoiseModeChange func(address string, mode NoiseMode)
+	onReconnect       func(address string)
+
+	// The menu is built for the devices once the tray is ready, see UpdateDevices
+	menuMu   sync.Mutex
+	ready    bool
+	devices  []Device
+	layout   []string         // Addresses of the devices with a submenu, see menuLayout
+	sections []*deviceSection // Items of each device
+	menuDone chan struct{}    // Closed when the menu is rebuilt
+
+	// The header shows whether LinuxPods is disconnected, then Reconnect connects the device
+	// at reconnectAddress
+	header           *systray.MenuItem
+	reconnect        *systray.MenuItem
+	reconnectAddress string
+
+	// Noise control mode reported by each device (by address), see SetNoiseMode
+	noiseModes map[string]NoiseMode
+
+	// The icon shows the lowest battery level, it is only rendered again when that changes
+	iconMu       sync.Mutex
+	baseIcon     []byte      // The AirPods image, shown as is while no level is known
+	baseImage    image.Image // Decoded baseIcon, nil if it can't be decoded
+	iconLevel    int         // Level shown in the icon, -1 for the plain AirPods image
+	iconCharging bool
+	iconStyle    IconStyle
+	colorScheme  ColorScheme // Of the desktop, picks the variant of IconAuto
+	stopWatch    func()      // Stops following the color scheme, nil if not followed
 }
 
-// New creates and initializes a new system tray indicator
-func New(onShowWindow, onQuit func(), onNoiseModeChange func(NoiseMode)) *Indicator {
+// New creates and initializes a new system tray indicator. onNoiseModeChange is called with
+// the address of the device whose noise control mode was selected in the menu, onReconnect
+// with the address of the device to connect when Reconnect is selected.
+func New(onShowWindow, onQuit func(), onNoiseModeChange func(address string, mode NoiseMode), onReconnect func(address string)) *Indicator {
 	return &Indicator{
-		batteries:         BatteryLevels{},
-		noiseMode:         Transparency,
 		onShowWindow:      onShowWindow,
 		onQuit:            onQuit,
 		onNoiseModeChange: onNoiseModeChange,
-		noiseModeItems:    make(map[NoiseMode]*systray.MenuItem),
+		onReconnect:       onReconnect,
+		noiseModes:        make(map[string]NoiseMode),
+		iconLevel:         -1,
+		iconStyle:         IconAuto,
 	}
 }
 
 // Start initializes the system tray indicator
 func (ind *Indicator) Start() {
+	// Follow the color scheme of the desktop for IconAuto
+	stopWatch, err := watchColorScheme(ind.setColorScheme)
+	if err != nil {
+		log.Printf("Could not detect the color scheme, the tray icon is colored: %v", err)
+	}
+	ind.iconMu.Lock()
+	ind.stopWatch = stopWatch
+	ind.iconMu.Unlock()
+
 	go systray.Run(ind.onReady, ind.onExit)
 }
 
 // Stop terminates the system tray indicator
 func (ind *Indicator) Stop() {
+	ind.iconMu.Lock()
+	if ind.stopWatch != nil {
+		ind.stopWatch()
+		ind.stopWatch = nil
+	}
+	ind.iconMu.Unlock()
 	systray.Quit()
 }
 
 // onReady is called when systray is ready
 func (ind *Indicator) onReady() {
-	iconData, err := loadIcon("assets/tray_icon3.png")
-	if err != nil {
-		log.Printf("Warning: Failed to load tray icon: %v", err)
-	} else {
-		systray.SetIcon(iconData)
-	}
+	ind.loadBaseIcon()
 
 	systray.SetTitle("LinuxPods")
-	systray.SetTooltip("Searching for AirPods...")
-
-	// Create battery level display items (non-clickable)
-	systray.AddMenuItem("Battery Levels", "Current battery status").Disable()
-	systray.AddSeparator()
-
-	ind.batteryItems[0] = systray.AddMenuItem("  Left:  --", "Left AirPod battery")
-	ind.batteryItems[0].Disable()
-
-	ind.batteryItems[1] = systray.AddMenuItem("  Right: --", "Right AirPod battery")
-	ind.batteryItems[1].Disable()
-
-	ind.batteryItems[2] = systray.AddMenuItem("  Case:  --", "Case battery")
-	ind.batteryItems[2].Disable()
-
-	systray.AddSeparator()
-
-	systray.AddMenuItem("Noise Control", "Noise control mode").Disable()
-
-	ind.noiseModeItems[Transparency] = systray.AddMenuItemCheckbox("Transparency", "Hear the world around you", true)
-	ind.noiseModeItems[Adaptive] = systray.AddMenuItemCheckbox("Adaptive", "Automatically adjusts", false)
-	ind.noiseModeItems[NoiseCancelling] = systray.AddMenuItemCheckbox("Noise Cancelling", "Block background noise", false)
-	ind.noiseModeItems[Off] = systray.AddMenuItemCheckbox("Off", "Noise control disabled", false)
-
-	systray.AddSeparator()
-
-	// Actions
-	mOpen := systray.AddMenuItem("Open LinuxPods", "Show the main window")
-	mQuit := systray.AddMenuItem("Quit", "Exit LinuxPods")
-
-	// Handle menu clicks
-	go func() {
-		for {
-			select {
-			case <-ind.noiseModeItems[Transparency].ClickedCh:
-				ind.setNoiseMode(Transparency)
-			case <-ind.noiseModeItems[Adaptive].ClickedCh:
-				ind.setNoiseMode(Adaptive)
-			case <-ind.noiseModeItems[NoiseCancelling].ClickedCh:
-				ind.setNoiseMode(NoiseCancelling)
-			case <-ind.noiseModeItems[Off].ClickedCh:
-				ind.setNoiseMode(Off)
-			case <-mOpen.ClickedCh:
-				if ind.onShowWindow != nil {
-					ind.onShowWindow()
-				}
-			case <-mQuit.ClickedCh:
-				if ind.onQuit != nil {
-					ind.onQuit()
-				}
-				return
-			}
-		}
-	}()
+	systray.SetTooltip(i18n.T("Searching for AirPods..."))
+
+	ind.menuMu.Lock()
+	defer ind.menuMu.Unlock()
+	ind.ready = true
+	ind.buildMenu(ind.devices)
+	ind.updateSectionsLocked()
 }
 
 // onExit is called when 'systray' is exiting
@@ -135,71 +127,82 @@ func (ind *Indicator) onExit() {
 	log.Println("System tray indicator exited")
 }
 
-// setNoiseMode updates the noise control mode
-func (ind *Indicator) setNoiseMode(mode NoiseMode) {
-	// Uncheck all modes
-	for _, item := range ind.noiseModeItems {
-		item.Uncheck()
+// loadBaseIcon loads the AirPods image and shows it, or the battery level if it is already known
+func (ind *Indicator) loadBaseIcon() {
+	iconData, err := loadIcon("tray_icon3.png")
+	if err != nil {
+		log.Printf("Warning: Failed to load tray icon: %v", err)
+		return
 	}
-
-	// Check the selected mode
-	ind.noiseModeItems[mode].Check()
-	ind.noiseMode = mode
-
-	// Call the callback
-	if ind.onNoiseModeChange != nil {
-		ind.onNoiseModeChange(mode)
+	img, _, err := image.Decode(bytes.NewReader(iconData))
+	if err != nil {
+		log.Printf("Warning: Failed to decode tray icon: %v", err)
 	}
 
-	log.Printf("Noise mode changed to: %s", mode)
+	ind.iconMu.Lock()
+	defer ind.iconMu.Unlock()
+	ind.baseIcon, ind.baseImage = iconData, img
+	ind.setIconLocked()
 }
 
-// UpdateBatteryLevels updates the displayed battery levels
-func (ind *Indicator) UpdateBatteryLevels(left, right, caseLevel *int, leftCharging, rightCharging, caseCharging bool) {
-	ind.batteries.Left = left
-	ind.batteries.Right = right
-	ind.batteries.Case = caseLevel
-	ind.batteries.LeftCharging = leftCharging
-	ind.batteries.RightCharging = rightCharging
-	ind.batteries.CaseCharging = caseCharging
-
-	// Find the lowest battery for tooltip
-	lowest := util.MinOr(left, right, -1)
-
-	if lowest != -1 {
-		systray.SetTooltip(fmt.Sprintf("AirPods Pro - %d%%", lowest))
-	} else {
-		systray.SetTooltip("Searching for AirPods...")
+// updateIcon shows a battery level (-1 if unknown) in the tray icon
+func (ind *Indicator) updateIcon(level int, charging bool) {
+	ind.iconMu.Lock()
+	defer ind.iconMu.Unlock()
+	if level == ind.iconLevel && charging == ind.iconCharging {
+		return
 	}
+	ind.iconLevel, ind.iconCharging = level, charging
+	ind.setIconLocked()
+}
 
-	// Update menu items with charging indicators
-	updateBatteryMenuItem(ind.batteryItems[0], "Left", left, leftCharging)
-	updateBatteryMenuItem(ind.batteryItems[1], "Right", right, rightCharging)
-	updateBatteryMenuItem(ind.batteryItems[2], "Case", caseLevel, caseCharging)
+// SetIconStyle selects how the tray icon is drawn (tray.icon_style of the config file)
+func (ind *Indicator) SetIconStyle(style IconStyle) {
+	ind.iconMu.Lock()
+	defer ind.iconMu.Unlock()
+	if style == ind.iconStyle {
+		return
+	}
+	ind.iconStyle = style
+	ind.setIconLocked()
 }
 
-// updateBatteryMenuItem updates a single battery menu item with level and charging status
-func updateBatteryMenuItem(item *systray.MenuItem, label string, level *int, charging bool) {
-	if item == nil {
+// setColorScheme redraws the icon for a changed color scheme of the desktop
+func (ind *Indicator) setColorScheme(scheme ColorScheme) {
+	ind.iconMu.Lock()
+	defer ind.iconMu.Unlock()
+	if scheme == ind.colorScheme {
 		return
 	}
+	ind.colorScheme = scheme
+	ind.setIconLocked()
+}
 
-	if level != nil {
-		chargingIndicator := ""
-		if charging {
-			chargingIndicator = " ⚡"
-		}
-		item.SetTitle(fmt.Sprintf("  %-5s: %d%%%s", label, *level, chargingIndicator))
-	} else {
-		item.SetTitle(fmt.Sprintf("  %-5s: --", label))
+// setIconLocked renders the icon for the current level and style and passes it to the tray.
+// Must be called with iconMu held.
+func (ind *Indicator) setIconLocked() {
+	if ind.baseIcon == nil {
+		return // Not loaded yet
+	}
+	style := ind.iconStyle.resolve(ind.colorScheme)
+	if ind.baseImage == nil || (ind.iconLevel < 0 && style == IconColor) {
+		systray.SetIcon(ind.baseIcon)
+		return
+	}
+	icon, err := renderIcon(ind.baseImage, style, ind.iconLevel, ind.iconCharging)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		systray.SetIcon(ind.baseIcon)
+		return
 	}
+	systray.SetIcon(icon)
 }
 
-// loadIcon loads icon data from a file
-func loadIcon(path string) ([]byte, error) {
-	data, err := os.ReadFile(path)
+// loadIcon loads the data of an icon embedded by the assets package
+func loadIcon(name string) ([]byte, error) {
+	data, err := assets.Read(name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read icon file: %w", err)
+		return nil, fmt.Errorf("failed to read icon: %w", err)
 	}
 	return data, nil
 }
@@ -0,0 +1,375 @@
+package indicator
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/util"
+
+	"fyne.io/systray"
+)
+
+// Device is a device shown in the tray menu
+type Device struct {
+	Address    string // Real MAC address, "" while only its random BLE address is known
+	Name       string
+	Batteries  BatteryLevels
+	Headphones bool // A single battery (AirPods Max) in Battery and Charging instead of Batteries
+	Battery    *int // nil if unknown
+	Charging   bool
+	NoiseModes map[NoiseMode]bool // Supported noise control modes
+	Stale      bool               // No data source is active, the levels are the last known ones
+}
+
+// lowestBattery returns the lowest battery level of the pods or the headphones, -1 if unknown,
+// and whether that battery is charging
+func (d Device) lowestBattery() (int, bool) {
+	if d.Headphones {
+		if d.Battery == nil {
+			return -1, false
+		}
+		return *d.Battery, d.Charging
+	}
+	b := d.Batteries
+	lowest := util.MinOr(b.Left, b.Right, -1)
+	charging := (b.Left != nil && *b.Left == lowest && b.LeftCharging) || (b.Right != nil && *b.Right == lowest && b.RightCharging)
+	return lowest, charging
+}
+
+// title returns the name of the device with its lowest battery level, e.g. "AirPods Pro - 84%",
+// or that it is disconnected
+func (d Device) title() string {
+	name := d.Name
+	if name == "" {
+		name = i18n.T("AirPods")
+	}
+	if d.Stale {
+		return i18n.Tf("%s - Disconnected", name)
+	}
+	if level, _ := d.lowestBattery(); level >= 0 {
+		return i18n.Tf("%s - %d%%", name, level)
+	}
+	return name
+}
+
+// noiseModes are the noise control modes in menu order
+var noiseModes = []NoiseMode{Transparency, Adaptive, NoiseCancelling, Off}
+
+// menuAdder adds items to the top level of the menu or to a submenu
+type menuAdder struct {
+	parent *systray.MenuItem // nil for the top level
+}
+
+func (m menuAdder) add(title, tooltip string) *systray.MenuItem {
+	if m.parent == nil {
+		return systray.AddMenuItem(title, tooltip)
+	}
+	return m.parent.AddSubMenuItem(title, tooltip)
+}
+
+func (m menuAdder) addCheckbox(title, tooltip string, checked bool) *systray.MenuItem {
+	if m.parent == nil {
+		return systray.AddMenuItemCheckbox(title, tooltip, checked)
+	}
+	return m.parent.AddSubMenuItemCheckbox(title, tooltip, checked)
+}
+
+func (m menuAdder) addSeparator() {
+	if m.parent == nil {
+		systray.AddSeparator()
+	} else {
+		m.parent.AddSeparator()
+	}
+}
+
+// deviceSection is the battery items and the noise control items of a device, at the top level
+// of the menu for a single device or in a submenu of each device
+type deviceSection struct {
+	address        string
+	submenu        *systray.MenuItem // Item opening the submenu, nil at the top level
+	batteryItems   [3]*systray.MenuItem
+	noiseModeItems map[NoiseMode]*systray.MenuItem
+}
+
+// addDeviceSection adds the items of a device
+func addDeviceSection(m menuAdder) *deviceSection {
+	s := &deviceSection{submenu: m.parent, noiseModeItems: make(map[NoiseMode]*systray.MenuItem)}
+
+	s.batteryItems[0] = m.add(batteryMenuTitle(i18n.T("Left"), nil, false), i18n.T("Left AirPod battery"))
+	s.batteryItems[1] = m.add(batteryMenuTitle(i18n.T("Right"), nil, false), i18n.T("Right AirPod battery"))
+	s.batteryItems[2] = m.add(batteryMenuTitle(i18n.T("Case"), nil, false), i18n.T("Case battery"))
+
+	m.addSeparator()
+
+	m.add(i18n.T("Noise Control"), i18n.T("Noise control mode")).Disable()
+	s.noiseModeItems[Transparency] = m.addCheckbox(i18n.T("Transparency"), i18n.T("Hear the world around you"), false)
+	s.noiseModeItems[Adaptive] = m.addCheckbox(i18n.T("Adaptive"), i18n.T("Automatically adjusts"), false)
+	s.noiseModeItems[NoiseCancelling] = m.addCheckbox(i18n.T("Noise Cancelling"), i18n.T("Block background noise"), false)
+	s.noiseModeItems[Off] = m.addCheckbox(i18n.T("Off"), i18n.T("Noise control disabled"), false)
+	return s
+}
+
+// update shows the battery levels and the supported noise control modes of a device, with the
+// mode it reported checked ("" if unknown)
+func (s *deviceSection) update(d Device, mode NoiseMode) {
+	s.address = d.Address
+	if s.submenu != nil {
+		s.submenu.SetTitle(d.title())
+	}
+
+	if d.Headphones {
+		// The left, right and case items are replaced by one battery item
+		setBatteryItem(s.batteryItems[0], i18n.T("Battery"), d.Battery, d.Charging, d.Stale)
+		s.batteryItems[1].Hide()
+		s.batteryItems[2].Hide()
+	} else {
+		b := d.Batteries
+		setBatteryItem(s.batteryItems[0], i18n.T("Left"), b.Left, b.LeftCharging, d.Stale)
+		setBatteryItem(s.batteryItems[1], i18n.T("Right"), b.Right, b.RightCharging, d.Stale)
+		setBatteryItem(s.batteryItems[2], i18n.T("Case"), b.Case, b.CaseCharging, d.Stale)
+		s.batteryItems[1].Show()
+		s.batteryItems[2].Show()
+	}
+
+	for mode, item := range s.noiseModeItems {
+		if d.NoiseModes[mode] {
+			item.Show()
+		} else {
+			item.Hide()
+		}
+	}
+	s.checkNoiseMode(mode)
+}
+
+// checkNoiseMode checks the item of a noise control mode and unchecks the others. No item is
+// checked for an unknown mode ("").
+func (s *deviceSection) checkNoiseMode(mode NoiseMode) {
+	for m, item := range s.noiseModeItems {
+		if m == mode {
+			item.Check()
+		} else {
+			item.Uncheck()
+		}
+	}
+}
+
+// menuLayout returns what the menu is built for: the address of each device with a submenu,
+// nothing for a single device shown at the top level
+func menuLayout(devices []Device) []string {
+	if len(devices) < 2 {
+		return nil
+	}
+	addresses := make([]string, len(devices))
+	for i, d := range devices {
+		addresses[i] = d.Address
+	}
+	return addresses
+}
+
+// buildMenu replaces the menu with one for the devices: a single device is shown at the top
+// level, several get a submenu each. Must be called with menuMu held.
+func (ind *Indicator) buildMenu(devices []Device) {
+	if ind.menuDone != nil {
+		close(ind.menuDone) // Stops handling the clicks of the old items
+	}
+	systray.ResetMenu()
+	done := make(chan struct{})
+	ind.menuDone = done
+	ind.layout = menuLayout(devices)
+	ind.sections = nil
+
+	ind.header = systray.AddMenuItem(i18n.T("Battery Levels"), i18n.T("Current battery status"))
+	ind.header.Disable()
+	systray.AddSeparator()
+
+	if ind.layout == nil {
+		ind.sections = append(ind.sections, addDeviceSection(menuAdder{}))
+	} else {
+		for _, d := range devices {
+			submenu := systray.AddMenuItem(d.title(), i18n.T("Battery levels and noise control of this device"))
+			ind.sections = append(ind.sections, addDeviceSection(menuAdder{parent: submenu}))
+		}
+	}
+
+	systray.AddSeparator()
+
+	// Actions. Reconnect is only shown while disconnected.
+	ind.reconnect = systray.AddMenuItem(i18n.T("Reconnect"), i18n.T("Connect the AirPods via Bluetooth"))
+	ind.reconnect.Hide()
+	mReconnect := ind.reconnect
+	mOpen := systray.AddMenuItem(i18n.T("Open LinuxPods"), i18n.T("Show the main window"))
+	mQuit := systray.AddMenuItem(i18n.T("Quit"), i18n.T("Exit LinuxPods"))
+
+	for _, section := range ind.sections {
+		for mode, item := range section.noiseModeItems {
+			go ind.handleNoiseModeClicks(section, mode, item, done)
+		}
+	}
+	go func() {
+		for {
+			select {
+			case <-mReconnect.ClickedCh:
+				ind.menuMu.Lock()
+				address := ind.reconnectAddress
+				ind.menuMu.Unlock()
+				if address != "" && ind.onReconnect != nil {
+					log.Printf("Reconnecting %s from the tray", address)
+					go ind.onReconnect(address) // Connecting takes a few seconds
+				}
+			case <-mOpen.ClickedCh:
+				if ind.onShowWindow != nil {
+					ind.onShowWindow()
+				}
+			case <-mQuit.ClickedCh:
+				if ind.onQuit != nil {
+					ind.onQuit()
+				}
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+// handleNoiseModeClicks selects the noise control mode of an item when it is clicked, until
+// the menu is rebuilt. The item is only checked once the device reports the new mode (see
+// SetNoiseMode), so the menu never shows a mode the device didn't switch to.
+func (ind *Indicator) handleNoiseModeClicks(section *deviceSection, mode NoiseMode, item *systray.MenuItem, done <-chan struct{}) {
+	for {
+		select {
+		case <-item.ClickedCh:
+			ind.menuMu.Lock()
+			address := section.address
+			// Some trays toggle a checkbox when it is clicked, show the reported mode again
+			section.checkNoiseMode(ind.noiseModes[address])
+			ind.menuMu.Unlock()
+
+			log.Printf("Noise mode of %s changed from the tray to %s", address, mode)
+			if ind.onNoiseModeChange != nil {
+				ind.onNoiseModeChange(address, mode)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// UpdateDevices shows the devices in the menu. The first device is the one the icon and the
+// tooltip show, it is only stale if all devices are: then the menu shows that LinuxPods is
+// disconnected and offers to reconnect the first device. A single device is shown directly in
+// the menu, with several each gets a submenu. The menu is only rebuilt when the devices
+// change, not for new battery levels.
+func (ind *Indicator) UpdateDevices(devices []Device) {
+	var first Device
+	if len(devices) > 0 {
+		first = devices[0]
+	}
+	level, charging := first.lowestBattery()
+	switch {
+	case first.Stale:
+		// The last known levels are not shown as if they were current
+		systray.SetTooltip(first.title())
+		level, charging = -1, false
+	case level >= 0:
+		systray.SetTooltip(first.title())
+	default:
+		systray.SetTooltip(i18n.T("Searching for AirPods..."))
+	}
+	ind.updateIcon(level, charging)
+
+	ind.menuMu.Lock()
+	defer ind.menuMu.Unlock()
+	ind.devices = devices
+	if !ind.ready {
+		return // Built with the devices when the tray is ready
+	}
+	if !slices.Equal(menuLayout(devices), ind.layout) {
+		ind.buildMenu(devices)
+	}
+	ind.updateSectionsLocked()
+}
+
+// updateSectionsLocked shows the current devices in the built menu. Must be called with
+// menuMu held.
+func (ind *Indicator) updateSectionsLocked() {
+	// Disconnected if even the first device is stale. Without its real address (only seen
+	// via BLE) there is nothing to reconnect.
+	ind.reconnectAddress = ""
+	if len(ind.devices) > 0 && ind.devices[0].Stale {
+		ind.header.SetTitle(i18n.T("Disconnected"))
+		ind.reconnectAddress = ind.devices[0].Address
+	} else {
+		ind.header.SetTitle(i18n.T("Battery Levels"))
+	}
+	if ind.reconnectAddress != "" {
+		ind.reconnect.Show()
+	} else {
+		ind.reconnect.Hide()
+	}
+
+	if len(ind.devices) == 0 {
+		// Nothing found yet, the single section shows unknown levels
+		ind.sections[0].update(Device{Batteries: BatteryLevels{}, NoiseModes: allNoiseModes()}, "")
+		return
+	}
+	for i, section := range ind.sections {
+		if i < len(ind.devices) {
+			d := ind.devices[i]
+			section.update(d, ind.noiseModes[d.Address])
+		}
+	}
+}
+
+// SetNoiseMode shows the noise control mode a device reported, whether it was selected in the
+// menu, on the device itself or from another device. An empty mode means it is unknown, e.g.
+// after the device disconnected.
+func (ind *Indicator) SetNoiseMode(address string, mode NoiseMode) {
+	ind.menuMu.Lock()
+	defer ind.menuMu.Unlock()
+	if mode == "" {
+		delete(ind.noiseModes, address)
+	} else {
+		ind.noiseModes[address] = mode
+	}
+	for _, section := range ind.sections {
+		if section.address == address {
+			section.checkNoiseMode(mode)
+		}
+	}
+}
+
+// allNoiseModes returns every noise control mode as supported
+func allNoiseModes() map[NoiseMode]bool {
+	supported := make(map[NoiseMode]bool, len(noiseModes))
+	for _, mode := range noiseModes {
+		supported[mode] = true
+	}
+	return supported
+}
+
+// setBatteryItem shows a battery level in its menu item. The item only displays the level, it
+// is greyed out (disabled) while the level is unknown or not current.
+func setBatteryItem(item *systray.MenuItem, label string, level *int, charging, stale bool) {
+	item.SetTitle(batteryMenuTitle(label, level, charging))
+	if level == nil || stale {
+		item.Disable()
+	} else {
+		item.Enable()
+	}
+}
+
+// batteryMenuTitle formats the title of a battery menu item
+func batteryMenuTitle(label string, level *int, charging bool) string {
+	if level == nil {
+		return fmt.Sprintf("  %-5s: --", label)
+	}
+
+	chargingIndicator := ""
+	if charging {
+		chargingIndicator = " ⚡"
+	}
+	return fmt.Sprintf("  %-5s: %d%%%s", label, *level, chargingIndicator)
+}
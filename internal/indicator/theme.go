@@ -0,0 +1,117 @@
+package indicator
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	portalName          = "org.freedesktop.portal.Desktop"
+	portalPath          = "/org/freedesktop/portal/desktop"
+	portalSettingsIface = "org.freedesktop.portal.Settings"
+
+	appearanceNamespace = "org.freedesktop.appearance"
+	colorSchemeKey      = "color-scheme"
+)
+
+// IconStyle selects how the tray icon is drawn
+type IconStyle string
+
+const (
+	IconAuto  IconStyle = "auto"  // Light or dark by the desktop's color scheme, colored without a preference
+	IconColor IconStyle = "color" // The colored AirPods image
+	IconLight IconStyle = "light" // Dark symbolic (monochrome) icon for light panels
+	IconDark  IconStyle = "dark"  // Light symbolic (monochrome) icon for dark panels
+)
+
+// ParseIconStyle parses an icon style. An empty string selects IconAuto.
+func ParseIconStyle(s string) (IconStyle, error) {
+	switch IconStyle(s) {
+	case "", IconAuto:
+		return IconAuto, nil
+	case IconColor, IconLight, IconDark:
+		return IconStyle(s), nil
+	default:
+		return IconAuto, fmt.Errorf("unknown tray icon style %q (expected auto, color, light or dark)", s)
+	}
+}
+
+// ColorScheme is the color scheme the user prefers, as reported by the XDG desktop portal
+type ColorScheme uint32
+
+const (
+	ColorSchemeDefault ColorScheme = 0 // No preference
+	ColorSchemeDark    ColorScheme = 1
+	ColorSchemeLight   ColorScheme = 2
+)
+
+// resolve returns the variant of the icon to draw with the desktop's color scheme
+func (s IconStyle) resolve(scheme ColorScheme) IconStyle {
+	if s != IconAuto {
+		return s
+	}
+	switch scheme {
+	case ColorSchemeDark:
+		return IconDark
+	case ColorSchemeLight:
+		return IconLight
+	default:
+		return IconColor
+	}
+}
+
+// watchColorScheme calls onChange with the color scheme of the desktop and again whenever the
+// user changes it, until the returned function is called. It fails without a desktop portal.
+func watchColorScheme(onChange func(ColorScheme)) (func(), error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+
+	// Subscribe before reading, so no change is missed in between
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(portalPath),
+		dbus.WithMatchInterface(portalSettingsIface),
+		dbus.WithMatchMember("SettingChanged"),
+	); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to watch the color scheme: %w", err)
+	}
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+
+	var value dbus.Variant
+	if err := conn.Object(portalName, portalPath).Call(portalSettingsIface+".ReadOne", 0, appearanceNamespace, colorSchemeKey).Store(&value); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to read the color scheme: %w", err)
+	}
+	onChange(colorScheme(value))
+
+	go func() {
+		for signal := range signals {
+			if len(signal.Body) != 3 || signal.Body[0] != appearanceNamespace || signal.Body[1] != colorSchemeKey {
+				continue
+			}
+			if value, ok := signal.Body[2].(dbus.Variant); ok {
+				onChange(colorScheme(value))
+			}
+		}
+	}()
+	// Closing the connection closes the signal channel
+	return func() { _ = conn.Close() }, nil
+}
+
+// colorScheme decodes the color-scheme setting. Older portals wrap it in a second variant.
+func colorScheme(value dbus.Variant) ColorScheme {
+	if inner, ok := value.Value().(dbus.Variant); ok {
+		value = inner
+	}
+	scheme, ok := value.Value().(uint32)
+	if !ok {
+		log.Printf("Warning: Unexpected color scheme %v", value)
+		return ColorSchemeDefault
+	}
+	return ColorScheme(scheme)
+}
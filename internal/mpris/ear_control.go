@@ -0,0 +1,131 @@
+package mpris
+
+import (
+	"log"
+	"sync"
+
+	"linuxpods/internal/config"
+	"linuxpods/internal/podstate"
+)
+
+// EarControl pauses the playing media players when both pods of a connected device are taken
+// out of the ears, and resumes the players it paused when a pod is put back in, like macOS.
+// Players that were paused by the user are left alone.
+type EarControl struct {
+	client    *Client
+	connected func(macAddr string) bool // Only connected devices play the audio
+
+	mu     sync.Mutex
+	config config.MediaConfig
+	inEar  map[string]bool // Whether a pod of a device is in an ear, by MAC address (unknown if missing)
+	paused []string        // Players paused by EarControl, to be resumed
+	calls  sync.Mutex      // Serializes the D-Bus calls to the players
+}
+
+// NewEarControl creates an ear control for the events of backend
+func NewEarControl(client *Client, backend podstate.Backend, cfg config.MediaConfig) *EarControl {
+	e := &EarControl{
+		client:    client,
+		connected: backend.IsAAPConnected,
+		config:    cfg,
+		inEar:     make(map[string]bool),
+	}
+	backend.Subscribe(e.handleEvent)
+	return e
+}
+
+// SetConfig changes the settings, e.g. after the config file was reloaded
+func (e *EarControl) SetConfig(cfg config.MediaConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.config = cfg
+	if !cfg.ResumeOnEarInsertion {
+		e.paused = nil
+	}
+}
+
+// handleEvent tracks the ear state of the connected devices
+func (e *EarControl) handleEvent(event podstate.Event) {
+	switch ev := event.(type) {
+	case podstate.EarStateChanged:
+		e.earStateChanged(ev.Address, ev.LeftInEar || ev.RightInEar)
+	case podstate.DeviceDisconnected:
+		// The audio moved to another output, don't resume it when the device comes back
+		e.mu.Lock()
+		delete(e.inEar, ev.Address)
+		e.paused = nil
+		e.mu.Unlock()
+	}
+}
+
+// earStateChanged pauses or resumes the players when the pods of a device left or entered
+// the ears. The first state of a device only sets the baseline.
+func (e *EarControl) earStateChanged(macAddr string, inEar bool) {
+	if !e.connected(macAddr) {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	wasInEar, known := e.inEar[macAddr]
+	e.inEar[macAddr] = inEar
+	if !known || wasInEar == inEar {
+		return
+	}
+
+	switch {
+	case !inEar && e.config.PauseOnEarRemoval:
+		log.Printf("AirPods %s taken out of the ears, pausing media", macAddr)
+		go e.pausePlaying()
+	case inEar && e.config.ResumeOnEarInsertion && len(e.paused) > 0:
+		log.Printf("AirPods %s put back in, resuming media", macAddr)
+		players := e.paused
+		e.paused = nil
+		go e.resume(players)
+	}
+}
+
+// pausePlaying pauses all playing players and remembers them for resuming
+func (e *EarControl) pausePlaying() {
+	e.calls.Lock()
+	defer e.calls.Unlock()
+
+	players, err := e.client.Players()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+
+	var paused []string
+	for _, name := range players {
+		status, err := e.client.PlaybackStatus(name)
+		if err != nil || status != StatusPlaying {
+			continue
+		}
+		if err := e.client.Pause(name); err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		paused = append(paused, name)
+	}
+
+	e.mu.Lock()
+	e.paused = append(e.paused, paused...)
+	e.mu.Unlock()
+}
+
+// resume resumes players that are still paused
+func (e *EarControl) resume(players []string) {
+	e.calls.Lock()
+	defer e.calls.Unlock()
+
+	for _, name := range players {
+		// The user may have stopped or closed the player meanwhile
+		if status, err := e.client.PlaybackStatus(name); err != nil || status != StatusPaused {
+			continue
+		}
+		if err := e.client.Play(name); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
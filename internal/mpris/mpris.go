@@ -0,0 +1,85 @@
+// Package mpris controls media players through the MPRIS D-Bus interface
+// (org.mpris.MediaPlayer2.Player), which all common Linux players and browsers implement.
+// It is used to pause playback when the AirPods are taken out of the ears (see EarControl).
+package mpris
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	namePrefix  = "org.mpris.MediaPlayer2."
+	objectPath  = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+	playerIface = "org.mpris.MediaPlayer2.Player"
+)
+
+// Playback statuses of a player
+const (
+	StatusPlaying = "Playing"
+	StatusPaused  = "Paused"
+	StatusStopped = "Stopped"
+)
+
+// Client controls the media players of the session bus
+type Client struct {
+	conn *dbus.Conn
+}
+
+// NewClient connects to the session bus
+func NewClient() (*Client, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Players returns the bus names of all running media players
+func (c *Client) Players() ([]string, error) {
+	var names []string
+	if err := c.conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names); err != nil {
+		return nil, fmt.Errorf("failed to list bus names: %w", err)
+	}
+
+	var players []string
+	for _, name := range names {
+		if strings.HasPrefix(name, namePrefix) {
+			players = append(players, name)
+		}
+	}
+	return players, nil
+}
+
+// PlaybackStatus returns the status of a player: StatusPlaying, StatusPaused or StatusStopped
+func (c *Client) PlaybackStatus(player string) (string, error) {
+	variant, err := c.conn.Object(player, objectPath).GetProperty(playerIface + ".PlaybackStatus")
+	if err != nil {
+		return "", fmt.Errorf("failed to get playback status of %s: %w", player, err)
+	}
+	status, _ := variant.Value().(string)
+	return status, nil
+}
+
+// Pause pauses a player
+func (c *Client) Pause(player string) error {
+	if err := c.conn.Object(player, objectPath).Call(playerIface+".Pause", 0).Err; err != nil {
+		return fmt.Errorf("failed to pause %s: %w", player, err)
+	}
+	return nil
+}
+
+// Play resumes a player
+func (c *Client) Play(player string) error {
+	if err := c.conn.Object(player, objectPath).Call(playerIface+".Play", 0).Err; err != nil {
+		return fmt.Errorf("failed to resume %s: %w", player, err)
+	}
+	return nil
+}
+
+// Close closes the session bus connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,560 @@
+package podstate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/retry"
+)
+
+// aapSession is an active AAP connection to a single device.
+// The coordinator maintains one session per connected device, keyed by MAC address,
+// each with its own read loop.
+type aapSession struct {
+	macAddr  string
+	conn     aap.Conn
+	unparsed aap.PacketRecorder // Diagnostics-only record of packets no parser understands
+
+	settings map[aap.ControlCommandID]aap.ControlCommand // Last reported setting values (guarded by the coordinator mutex)
+
+	keyResponses chan []aap.ProximityKey // Key responses received by the read loop, for RequestEncryptionKeys
+}
+
+// restoreAAPSessions establishes AAP connections to all AirPods that are already connected
+// when the coordinator starts. Without this, accurate AAP data would only become available
+// after the AirPods disconnect and reconnect, since BlueZ only signals connection changes.
+func (m *PodStateCoordinator) restoreAAPSessions() {
+	addresses, err := m.bluetooth.ConnectedAirPods()
+	if err != nil {
+		log.Printf("No connected AirPods found at startup: %v", err)
+		return
+	}
+
+	for _, macAddr := range addresses {
+		if !m.ShouldAutoConnect(macAddr) {
+			log.Printf("AirPods already connected at startup: %s (not connecting, turned off for this device)", macAddr)
+			continue
+		}
+		log.Printf("AirPods already connected at startup: %s", macAddr)
+		if err := m.ConnectAAP(macAddr); err != nil {
+			log.Printf("Warning: Failed to restore AAP session for %s: %v", macAddr, err)
+			log.Println("Falling back to BLE for battery monitoring (approximate) while retrying")
+			m.ReconnectAAP(macAddr)
+		}
+	}
+}
+
+// connectBackoff retries failed AAP connections for a few seconds. Right after connecting via
+// Bluetooth, devices may still be negotiating their profiles and refuse the connection.
+var connectBackoff = retry.Backoff{
+	Initial:     500 * time.Millisecond,
+	Max:         2 * time.Second,
+	Multiplier:  2,
+	Jitter:      0.2,
+	MaxAttempts: 4,
+}
+
+// ConnectAAP connects to AirPods via AAP for accurate battery monitoring.
+// Several devices can be connected at the same time, each with its own session.
+// Connecting to a device that already has a session is a no-op. Transient errors
+// (see aap.IsTransient) are retried with connectBackoff. Subscribers are notified
+// with DeviceConnecting, followed by DeviceConnected or DeviceConnectFailed unless the
+// coordinator was closed meanwhile.
+func (m *PodStateCoordinator) ConnectAAP(macAddr string) error {
+	return m.connectAAP(m.ctx, macAddr, connectBackoff)
+}
+
+// connectAAP opens an AAP session, retrying transient errors with the given backoff until
+// ctx is done. The connection events are published once, not for every attempt. A connection
+// cancelled by ctx (DisconnectAAP or Close) didn't fail, and is not reported.
+func (m *PodStateCoordinator) connectAAP(ctx context.Context, macAddr string, backoff retry.Backoff) error {
+	if m.IsAAPConnected(macAddr) {
+		return nil
+	}
+
+	m.events.Publish(DeviceConnecting{Address: macAddr})
+	attempt := 0
+	err := retry.Do(ctx, backoff, func(ctx context.Context) error {
+		attempt++
+		err := m.connectAAPOnce(ctx, macAddr)
+		switch {
+		case err == nil:
+			return nil
+		case !aap.IsTransient(err) || ctx.Err() != nil:
+			return retry.Permanent(err)
+		default:
+			log.Printf("AAP connection attempt %d to %s failed: %v", attempt, macAddr, err)
+			return err
+		}
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			m.events.Publish(DeviceConnectFailed{Address: macAddr})
+		}
+		return err
+	}
+	return nil
+}
+
+// connectAAPOnce opens an AAP session to a device without retrying.
+// No session is added once ctx is done, e.g. when DisconnectAAP cancelled a reconnect.
+func (m *PodStateCoordinator) connectAAPOnce(ctx context.Context, macAddr string) error {
+	m.mu.RLock()
+	_, exists := m.aapSessions[macAddr]
+	dial := m.dialAAP
+	m.mu.RUnlock()
+
+	// Already connected to this device (e.g. session restored at startup and
+	// the BlueZ provider reported the same connection)
+	if exists {
+		return nil
+	}
+
+	// Create new AAP connection
+	client, err := dial(macAddr)
+	if err != nil {
+		return fmt.Errorf("failed to create AAP client: %w", err)
+	}
+
+	// Connect to AirPods
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect AAP: %w", err)
+	}
+
+	// Send handshake
+	if err := client.Handshake(); err != nil {
+		_ = client.Close()
+		return fmt.Errorf("failed to send handshake: %w", err)
+	}
+
+	// Wait for handshake to process
+	select {
+	case <-time.After(500 * time.Millisecond):
+	case <-ctx.Done():
+		_ = client.Close()
+		return ctx.Err()
+	}
+
+	// Request battery status and a dump of all settings (reported as notifications
+	// with the same request, so the UI can initialize all controls)
+	if err := aap.RequestDeviceSettings(client); err != nil {
+		_ = client.Close()
+		return fmt.Errorf("failed to request battery and settings: %w", err)
+	}
+
+	// Enable special features
+	if err := aap.EnableSpecialFeatures(client); err != nil {
+		_ = client.Close()
+		return fmt.Errorf("failed to enable features: %w", err)
+	}
+
+	session := &aapSession{
+		macAddr:      macAddr,
+		conn:         client,
+		settings:     make(map[aap.ControlCommandID]aap.ControlCommand),
+		keyResponses: make(chan []aap.ProximityKey, 1),
+	}
+
+	m.mu.Lock()
+	if err := ctx.Err(); err != nil {
+		// Closed or disconnected while connecting - the sessions were already closed
+		m.mu.Unlock()
+		_ = client.Close()
+		return err
+	}
+	if _, exists := m.aapSessions[macAddr]; exists {
+		// Another caller connected concurrently - keep the existing session
+		m.mu.Unlock()
+		_ = client.Close()
+		return nil
+	}
+	m.aapSessions[macAddr] = session
+	delete(m.suspended, macAddr)
+	m.mu.Unlock()
+
+	log.Printf("AAP connected successfully to %s - using accurate battery data (1%% precision)", macAddr)
+	m.events.Publish(DeviceConnected{Address: macAddr})
+
+	// Start AAP reading loop for this device. Closing the session interrupts it.
+	m.spawn(func() { m.aapReadLoop(session) })
+
+	return nil
+}
+
+// aapReconnect is a running background reconnect attempt
+type aapReconnect struct {
+	cancel context.CancelFunc
+}
+
+// ReconnectAAP connects to the device via AAP in the background, retrying with backoff.
+// It is used when connecting fails right after the device connected (it may not accept AAP
+// connections yet) and when an established session breaks. DisconnectAAP stops the attempt.
+func (m *PodStateCoordinator) ReconnectAAP(macAddr string) {
+	m.mu.Lock()
+	if _, running := m.reconnects[macAddr]; running {
+		m.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	attempt := &aapReconnect{cancel: cancel}
+	m.reconnects[macAddr] = attempt
+	m.mu.Unlock()
+
+	m.spawn(func() {
+		defer func() {
+			m.mu.Lock()
+			if m.reconnects[macAddr] == attempt {
+				delete(m.reconnects, macAddr)
+			}
+			m.mu.Unlock()
+			cancel()
+		}()
+
+		if err := m.connectAAP(ctx, macAddr, retry.DefaultBackoff); err != nil {
+			log.Printf("AAP reconnect to %s stopped: %v", macAddr, err)
+		}
+	})
+}
+
+// DisconnectAAP disconnects the AAP session of the given device
+// and stops reconnect attempts to it
+func (m *PodStateCoordinator) DisconnectAAP(macAddr string) {
+	m.mu.Lock()
+	session, ok := m.aapSessions[macAddr]
+	if ok {
+		delete(m.aapSessions, macAddr)
+	}
+	if attempt, running := m.reconnects[macAddr]; running {
+		attempt.cancel()
+		delete(m.reconnects, macAddr)
+	}
+	// Don't resume a suspended session, the device is gone
+	delete(m.suspended, macAddr)
+	m.mu.Unlock()
+
+	if ok {
+		_ = session.conn.Close()
+		log.Printf("AAP disconnected from %s - using BLE for battery data", macAddr)
+		m.watchHandoff(macAddr)
+		m.events.Publish(DeviceDisconnected{Address: macAddr})
+	}
+}
+
+// removeSession removes the session if it is still the active session of its device.
+// It reports whether the session was removed, i.e. it wasn't disconnected or replaced before.
+func (m *PodStateCoordinator) removeSession(session *aapSession) bool {
+	m.mu.Lock()
+	if current, ok := m.aapSessions[session.macAddr]; !ok || current != session {
+		m.mu.Unlock()
+		return false
+	}
+	delete(m.aapSessions, session.macAddr)
+	m.mu.Unlock()
+
+	m.watchHandoff(session.macAddr)
+	m.events.Publish(DeviceDisconnected{Address: session.macAddr})
+	m.detectLeftBehind(session.macAddr)
+	return true
+}
+
+// IsAAPConnected reports whether an AAP session is active for the given device
+func (m *PodStateCoordinator) IsAAPConnected(macAddr string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.aapSessions[macAddr]
+	return ok
+}
+
+// GetConnectedDeviceMacs returns the MAC addresses of all devices with an active AAP session, sorted
+func (m *PodStateCoordinator) GetConnectedDeviceMacs() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	macs := make([]string, 0, len(m.aapSessions))
+	for macAddr := range m.aapSessions {
+		macs = append(macs, macAddr)
+	}
+	sort.Strings(macs)
+	return macs
+}
+
+// AAPDiagnostics returns the unparsed AAP packets of each connected device, keyed by MAC address.
+// It is intended for diagnostics only, e.g. to identify sensor data in unknown packets.
+func (m *PodStateCoordinator) AAPDiagnostics() map[string][]aap.UnparsedPacket {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	diagnostics := make(map[string][]aap.UnparsedPacket, len(m.aapSessions))
+	for macAddr, session := range m.aapSessions {
+		diagnostics[macAddr] = session.unparsed.Snapshot()
+	}
+	return diagnostics
+}
+
+// aapReadLoop continuously reads AAP packets of a single session and updates its device state
+func (m *PodStateCoordinator) aapReadLoop(session *aapSession) {
+	macAddr := session.macAddr
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		default:
+		}
+
+		packet, err := session.conn.ReadPacket()
+		if err != nil {
+			log.Printf("AAP read error (%s): %v", macAddr, err)
+			_ = session.conn.Close()
+
+			// The session broke while the device is still connected - try to get it back
+			if m.removeSession(session) && m.ctx.Err() == nil {
+				m.ReconnectAAP(macAddr)
+			}
+			return
+		}
+
+		session.unparsed.Record(packet)
+		if !aap.IsKeyPacket(packet) { // Keys stay out of the log, which is sent over D-Bus
+			m.packets.record(PacketAAP, macAddr, 0, packet, time.Now())
+		}
+
+		// Try to parse the battery packet
+		if aap.IsBatteryPacket(packet) {
+			batteryInfo, err := aap.ParseBatteryPacket(packet)
+			if err != nil {
+				log.Printf("AAP battery parse error (%s): %v", macAddr, err)
+				continue
+			}
+			// Convert AAP battery info to PodState
+			state := m.aapToState(batteryInfo, packet, macAddr)
+			m.handleStateUpdate(macAddr, state)
+		}
+
+		// Try to parse the ear status (in ear, out of ear, in case)
+		if aap.IsEarStatusPacket(packet) {
+			info, err := aap.ParseEarStatusPacket(packet)
+			if err == nil {
+				m.handleEarStatus(macAddr, info)
+			}
+		}
+
+		// Try to parse the device information (firmware and serial numbers), sent once
+		if aap.IsDeviceInfoPacket(packet) {
+			info, err := aap.ParseDeviceInfoPacket(packet)
+			if err == nil {
+				m.handleDeviceInfo(macAddr, info)
+			}
+		}
+
+		// Try to parse setting notifications (read-back of control commands)
+		if aap.IsControlCommandPacket(packet) {
+			cmd, err := aap.ParseControlCommand(packet)
+			if err == nil {
+				m.handleControlCommand(session, cmd)
+			}
+		}
+
+		// Try to parse the proximity keys
+		if aap.IsKeyPacket(packet) {
+			proximityKeys, err := aap.ParseProximityKeys(packet)
+			if err == nil {
+				// The ENC_KEY decrypts the advertisements. The IRK identifies the device
+				// from its random BLE address, even when the payload can't be decrypted.
+				encKey := aap.FindEncryptionKey(proximityKeys)
+				irk := aap.FindIRK(proximityKeys)
+				if len(irk) != 16 {
+					irk = nil
+				}
+				if encKey != nil || irk != nil {
+					m.storeKeys(macAddr, encKey, irk)
+				}
+
+				// Hand the keys to a waiting RequestEncryptionKeys call, if any
+				select {
+				case session.keyResponses <- proximityKeys:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// getBatteryFromAAP is a helper function that converts AAP Battery data to PodState fields.
+// It returns the battery level (or nil if unavailable) and charging status.
+func getBatteryFromAAP(battery *aap.Battery) (*int, bool) {
+	if battery != nil {
+		level := int(battery.Level)
+		return &level, battery.Status == aap.StatusCharging
+	}
+	return nil, false
+}
+
+// aapToState converts AAP battery info to PodState
+func (m *PodStateCoordinator) aapToState(info *aap.BatteryInfo, rawPacket []byte, macAddr string) *PodState {
+	state := &PodState{
+		Source:  DataSourceAAP,
+		RealMac: macAddr, // AAP uses the real (permanent) MAC address
+		// CurrentBLEMac is empty for AAP connections (no BLE randomization)
+		RawData:     rawPacket,
+		LastSeen:    time.Now(),
+		IsOwnDevice: true,
+	}
+
+	// Convert battery information from AAP to PodState
+	state.LeftBattery, state.LeftCharging = getBatteryFromAAP(info.Left)
+	state.RightBattery, state.RightCharging = getBatteryFromAAP(info.Right)
+	state.CaseBattery, state.CaseCharging = getBatteryFromAAP(info.Case)
+	state.Battery, state.Charging = getBatteryFromAAP(info.Single)
+
+	// The battery status reports the primary pod first, and is resent when the pods switch roles
+	fields := []Field{FieldBattery}
+	switch info.Primary {
+	case aap.ComponentLeft:
+		state.PrimaryPod = PodSideLeft
+		fields = append(fields, FieldPrimaryPod)
+	case aap.ComponentRight:
+		state.PrimaryPod = PodSideRight
+		fields = append(fields, FieldPrimaryPod)
+	}
+	// The other fields are reported separately (ear status notifications) or only via BLE
+	// (lid, signal strength and model), and are kept from the previous state by mergeStates
+	state.Sources = withSource(nil, DataSourceAAP, state.LastSeen, fields...)
+
+	// All features are assumed until the model is known from BLE
+	state.Capabilities = aap.AllCapabilities
+
+	m.mu.RLock()
+	// Look up the encryption key for this device
+	if encKey, ok := m.encryptionKeys[macAddr]; ok {
+		// Make a copy of the key
+		state.EncryptionKey = make([]byte, len(encKey))
+		copy(state.EncryptionKey, encKey)
+	}
+	m.mu.RUnlock()
+
+	return state
+}
+
+// handleEarStatus updates the in-ear status of a device from an AAP ear status notification
+func (m *PodStateCoordinator) handleEarStatus(macAddr string, info *aap.EarStatusInfo) {
+	m.updateState(macAddr, func(state *PodState) bool {
+		primaryInEar := info.Primary == aap.EarStatusInEar
+		secondaryInEar := info.Secondary == aap.EarStatusInEar
+		primaryInCase := info.Primary == aap.EarStatusInCase
+		secondaryInCase := info.Secondary == aap.EarStatusInCase
+		if state.PrimaryPod == PodSideRight {
+			state.LeftInEar, state.RightInEar = secondaryInEar, primaryInEar
+			state.LeftInCase, state.RightInCase = secondaryInCase, primaryInCase
+		} else {
+			state.LeftInEar, state.RightInEar = primaryInEar, secondaryInEar
+			state.LeftInCase, state.RightInCase = primaryInCase, secondaryInCase
+		}
+		state.Sources = withSource(state.Sources, DataSourceAAP, time.Now(), FieldEarStatus)
+		return true
+	})
+}
+
+// handleDeviceInfo stores the device information of a device. It is usually sent before the
+// battery, so it may create the state.
+func (m *PodStateCoordinator) handleDeviceInfo(macAddr string, info *aap.DeviceInfo) {
+	log.Printf("AAP: %s is %s (%s), firmware %s", macAddr, info.Name, info.ModelNumber, info.FirmwareVersion)
+	state := &PodState{
+		Source:       DataSourceAAP,
+		RealMac:      macAddr,
+		LastSeen:     time.Now(),
+		IsOwnDevice:  true,
+		Capabilities: aap.AllCapabilities, // Until the model is known from BLE
+		DeviceInfo:   info,
+	}
+	state.Sources = withSource(nil, DataSourceAAP, state.LastSeen, FieldDeviceInfo)
+	m.handleStateUpdate(macAddr, state)
+}
+
+// activeSession returns the AAP session of a device, or an error if it is not connected via AAP
+func (m *PodStateCoordinator) activeSession(macAddr string) (*aapSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.aapSessions[macAddr]
+	if !ok {
+		return nil, fmt.Errorf("no active AAP connection to %s - connect to AirPods first", macAddr)
+	}
+	return session, nil
+}
+
+// activeAAPConn returns the AAP connection of a device, or an error if it is not connected via AAP
+func (m *PodStateCoordinator) activeAAPConn(macAddr string) (aap.Conn, error) {
+	session, err := m.activeSession(macAddr)
+	if err != nil {
+		return nil, err
+	}
+	return session.conn, nil
+}
+
+// SetKeyRetrieval configures how long RequestEncryptionKeys waits for the keys and how often
+// it repeats the request (aap.DefaultKeyRetrieval by default)
+func (m *PodStateCoordinator) SetKeyRetrieval(config aap.KeyRetrieval) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keyRetrieval = config
+}
+
+// RequestEncryptionKeys requests encryption keys from connected AirPods via AAP and waits
+// until they are received and stored. The request is repeated if the AirPods don't answer
+// in time (see SetKeyRetrieval).
+// Returns an error if no AAP connection is active or if no keys were received.
+func (m *PodStateCoordinator) RequestEncryptionKeys(macAddr string) error {
+	session, err := m.activeSession(macAddr)
+	if err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	config := m.keyRetrieval
+	m.mu.RUnlock()
+
+	// The keys are stored by aapReadLoop, which also delivers them to keyResponses
+	log.Printf("Requesting encryption keys from %s", macAddr)
+	keys, err := aap.RetrieveProximityKeys(session.conn, session.keyResponses, config)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve encryption keys: %w", err)
+	}
+	if aap.FindEncryptionKey(keys) == nil {
+		return fmt.Errorf("key response from %s contains no encryption key", macAddr)
+	}
+
+	return nil
+}
+
+// SetNoiseControlCycle sets which noise control modes the stem press and hold gesture
+// cycles through. This requires an active AAP connection to the device.
+func (m *PodStateCoordinator) SetNoiseControlCycle(macAddr string, cycle aap.NoiseControlCycle) error {
+	client, err := m.activeAAPConn(macAddr)
+	if err != nil {
+		return err
+	}
+
+	if err := aap.SetNoiseControlCycle(client, cycle); err != nil {
+		return fmt.Errorf("failed to set noise control cycle: %w", err)
+	}
+
+	log.Printf("Noise control cycle of %s set to: %s", macAddr, cycle)
+	return nil
+}
+
+// closeAAPSessions closes all AAP sessions
+func (m *PodStateCoordinator) closeAAPSessions() {
+	m.mu.Lock()
+	sessions := m.aapSessions
+	m.aapSessions = make(map[string]*aapSession)
+	m.mu.Unlock()
+
+	for _, session := range sessions {
+		_ = session.conn.Close()
+	}
+}
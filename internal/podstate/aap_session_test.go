@@ -0,0 +1,119 @@
+package podstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/retry"
+)
+
+func TestConnectAAPBatteryPacket(t *testing.T) {
+	conn := aap.NewFakeConn()
+	m, _ := newTestCoordinator(t, WithAAPDialer(func(macAddr string) (aap.Conn, error) {
+		if macAddr != testMac {
+			t.Errorf("Dialed %s, want %s", macAddr, testMac)
+		}
+		return conn, nil
+	}))
+
+	if err := m.ConnectAAP(testMac); err != nil {
+		t.Fatalf("ConnectAAP: %v", err)
+	}
+	if !m.IsAAPConnected(testMac) {
+		t.Fatal("No AAP session after ConnectAAP")
+	}
+	if len(conn.Sent()) == 0 {
+		t.Fatal("Nothing sent to the device, want the handshake and requests")
+	}
+
+	// Right pod primary: 84% discharging, left 77% charging, case 50% discharging
+	conn.Push([]byte{
+		0x04, 0x00, 0x04, 0x00, 0x04, 0x00, 0x03,
+		byte(aap.ComponentRight), 0x01, 84, byte(aap.StatusDischarging), 0x01,
+		byte(aap.ComponentLeft), 0x01, 77, byte(aap.StatusCharging), 0x01,
+		byte(aap.ComponentCase), 0x01, 50, byte(aap.StatusDischarging), 0x01,
+	})
+
+	state := waitForState(t, m, testMac, func(s *PodState) bool { return s.Source == DataSourceAAP })
+	if got := [3]int{intValue(state.LeftBattery), intValue(state.RightBattery), intValue(state.CaseBattery)}; got != [3]int{77, 84, 50} {
+		t.Errorf("Left, right and case battery = %v, want [77 84 50]", got)
+	}
+	if !state.LeftCharging || state.RightCharging || state.CaseCharging {
+		t.Errorf("Charging left %t, right %t, case %t, want only left", state.LeftCharging, state.RightCharging, state.CaseCharging)
+	}
+	if state.PrimaryPod != PodSideRight {
+		t.Errorf("PrimaryPod = %s, want Right", state.PrimaryPod)
+	}
+	if state.RealMac != testMac || !state.IsOwnDevice {
+		t.Errorf("RealMac = %q, IsOwnDevice = %t, want %s and true", state.RealMac, state.IsOwnDevice, testMac)
+	}
+}
+
+func TestConnectAAPRetriesTransientError(t *testing.T) {
+	conn := aap.NewFakeConn()
+	dials := 0
+	m, _ := newTestCoordinator(t, WithAAPDialer(func(macAddr string) (aap.Conn, error) {
+		dials++
+		if dials == 1 {
+			// The device is still connecting its audio profiles
+			return nil, fmt.Errorf("failed to connect to AirPods: %w", syscall.EHOSTDOWN)
+		}
+		return conn, nil
+	}))
+
+	if err := m.ConnectAAP(testMac); err != nil {
+		t.Fatalf("ConnectAAP: %v", err)
+	}
+	if dials != 2 {
+		t.Errorf("Dialed %d times, want 2", dials)
+	}
+	if !m.IsAAPConnected(testMac) {
+		t.Error("No AAP session after the retry")
+	}
+}
+
+func TestConnectAAPStopsOnFatalError(t *testing.T) {
+	dials := 0
+	m, _ := newTestCoordinator(t, WithAAPDialer(func(macAddr string) (aap.Conn, error) {
+		dials++
+		return nil, fmt.Errorf("failed to create L2CAP socket: %w", syscall.EACCES)
+	}))
+
+	err := m.ConnectAAP(testMac)
+	if !errors.Is(err, syscall.EACCES) {
+		t.Fatalf("ConnectAAP = %v, want EACCES", err)
+	}
+	if dials != 1 {
+		t.Errorf("Dialed %d times, want 1 (no retry of a fatal error)", dials)
+	}
+	if m.IsAAPConnected(testMac) {
+		t.Error("AAP session after a failed connection")
+	}
+}
+
+func TestConnectAAPCancelledNotReported(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m, _ := newTestCoordinator(t, WithAAPDialer(func(macAddr string) (aap.Conn, error) {
+		cancel() // e.g. DisconnectAAP during the attempt
+		return nil, fmt.Errorf("failed to connect to AirPods: %w", syscall.EHOSTDOWN)
+	}))
+
+	var failed atomic.Bool
+	m.Subscribe(func(event Event) {
+		if _, ok := event.(DeviceConnectFailed); ok {
+			failed.Store(true)
+		}
+	})
+
+	if err := m.connectAAP(ctx, testMac, retry.DefaultBackoff); err == nil {
+		t.Fatal("connectAAP succeeded, want an error")
+	}
+	if failed.Load() {
+		t.Error("DeviceConnectFailed published for a cancelled connection")
+	}
+}
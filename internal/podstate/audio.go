@@ -0,0 +1,50 @@
+package podstate
+
+import (
+	"log"
+	"time"
+)
+
+// AudioProfile is the Bluetooth audio profile of a device, as reported by the sound server
+type AudioProfile int
+
+const (
+	AudioProfileUnknown AudioProfile = iota
+	AudioProfileOff                  // Connected without audio
+	AudioProfileA2DP                 // High quality playback, no microphone
+	AudioProfileHeadset              // HFP/HSP: microphone, but low quality playback
+)
+
+func (p AudioProfile) String() string {
+	switch p {
+	case AudioProfileOff:
+		return "Off"
+	case AudioProfileA2DP:
+		return "A2DP"
+	case AudioProfileHeadset:
+		return "Headset"
+	default:
+		return "Unknown"
+	}
+}
+
+// SetAudioState sets the audio profile of a device and whether an application records from
+// the microphone. The coordinator doesn't talk to the sound server itself, the audio
+// integration (audio.ProfileMonitor) reports it. Nothing happens if the device has no state.
+func (m *PodStateCoordinator) SetAudioState(macAddr string, profile AudioProfile, microphoneInUse bool) {
+	m.mu.RLock()
+	previous, ok := m.deviceStates[macAddr]
+	m.mu.RUnlock()
+	if !ok || previous.AudioProfile == profile && previous.MicrophoneInUse == microphoneInUse {
+		return
+	}
+
+	if previous.AudioProfile != profile {
+		log.Printf("Audio profile of %s: %s -> %s", macAddr, previous.AudioProfile, profile)
+	}
+	state := *previous
+	state.AudioProfile = profile
+	state.MicrophoneInUse = microphoneInUse
+	state.Sources = withSource(state.Sources, DataSourceAudio, time.Now(), FieldAudio)
+	m.handleStateUpdate(macAddr, &state)
+}
@@ -0,0 +1,58 @@
+package podstate
+
+import (
+	"time"
+
+	"linuxpods/internal/aap"
+)
+
+// Backend is the part of the coordinator that the UI uses. It is implemented by
+// PodStateCoordinator, when the GUI runs the coordinator itself, and by dbusapi.Client,
+// which forwards all calls to the coordinator of the background daemon (cmd/daemon).
+type Backend interface {
+	Subscribe(handler EventHandler) (unsubscribe func())
+	RegisterSettingsCallback(cb SettingsCallback) (unregister func())
+	GetDeviceStates() map[string]*PodState
+
+	ConnectAAP(macAddr string) error
+	ReconnectAAP(macAddr string)
+	DisconnectAAP(macAddr string)
+	IsAAPConnected(macAddr string) bool
+	GetConnectedDeviceMacs() []string
+	StartFastScan()
+	SetInteractive(interactive bool)
+
+	ConnectBluetooth(macAddr string) error
+	DisconnectBluetooth(macAddr string) error
+	FindPairableDevice(timeout time.Duration) (PairableDevice, error)
+	PairBluetooth(macAddr string) error
+	BluetoothPowered() (bool, error)
+	PowerOnBluetooth() error
+
+	RequestEncryptionKeys(macAddr string) error
+	ImportKeys(macAddr string, encKey []byte, irk []byte) error
+	SetEncryptionKey(macAddr string, encKey []byte) error
+	ExportKeys(path string) error
+	ImportKeysFile(path string) (int, error)
+
+	SetNoiseMode(macAddr string, mode aap.NoiseControlMode) error
+	SetNoiseControlCycle(macAddr string, cycle aap.NoiseControlCycle) error
+	SetMicrophoneMode(macAddr string, mode aap.MicrophoneMode) error
+	SetEarDetection(macAddr string, enabled bool) error
+	SetToneVolume(macAddr string, volume uint8) error
+	SetPressAndHold(macAddr string, pressAndHold aap.PressAndHold) error
+	SetPressSpeed(macAddr string, speed aap.PressSpeed) error
+	SetHoldDuration(macAddr string, duration aap.HoldDuration) error
+	SetVolumeSwipe(macAddr string, enabled bool) error
+
+	BatteryHistory(macAddr string, since time.Time) []BatterySample
+	BatteryEstimates(macAddr string) []BatteryEstimate
+
+	Metrics() Metrics
+	AAPDiagnostics() map[string][]aap.UnparsedPacket
+	PacketLog(after uint64) []LoggedPacket
+
+	Close() error
+}
+
+var _ Backend = (*PodStateCoordinator)(nil)
@@ -0,0 +1,106 @@
+package podstate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"linuxpods/internal/bluez"
+)
+
+// BluetoothController connects and pairs devices via Bluetooth. The coordinator uses BlueZ,
+// unless another controller is set with WithBluetooth (e.g. a fake for testing).
+type BluetoothController interface {
+	// ConnectedAirPods returns the MAC addresses of the connected AirPods
+	ConnectedAirPods() ([]string, error)
+	Connect(macAddr string) error
+	Disconnect(macAddr string) error
+	// FindPairable searches for AirPods in pairing mode until ctx is done
+	FindPairable(ctx context.Context) (PairableDevice, error)
+	Pair(macAddr string) error
+	// Powered checks if Bluetooth is turned on
+	Powered() (bool, error)
+	PowerOn() error
+}
+
+// bluezController is the BluetoothController using BlueZ over the system bus
+type bluezController struct{}
+
+func (bluezController) ConnectedAirPods() ([]string, error) {
+	devices, err := bluez.FindConnectedAirPods()
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, len(devices))
+	for i, device := range devices {
+		addresses[i] = device.Address
+	}
+	return addresses, nil
+}
+
+func (bluezController) Connect(macAddr string) error    { return bluez.ConnectDevice(macAddr) }
+func (bluezController) Disconnect(macAddr string) error { return bluez.DisconnectDevice(macAddr) }
+func (bluezController) Pair(macAddr string) error       { return bluez.PairDevice(macAddr) }
+func (bluezController) Powered() (bool, error)          { return bluez.AdapterPowered() }
+func (bluezController) PowerOn() error                  { return bluez.PowerOnAdapter() }
+
+func (bluezController) FindPairable(ctx context.Context) (PairableDevice, error) {
+	device, err := bluez.FindPairableDevice(ctx)
+	if err != nil {
+		return PairableDevice{}, err
+	}
+	return PairableDevice{Address: device.Address, Name: device.Name}, nil
+}
+
+// ConnectBluetooth connects a paired device via Bluetooth. The AAP session is opened
+// when the battery provider reports the connection.
+func (m *PodStateCoordinator) ConnectBluetooth(macAddr string) error {
+	if err := m.bluetooth.Connect(macAddr); err != nil {
+		return fmt.Errorf("failed to connect %s: %w", macAddr, err)
+	}
+	return nil
+}
+
+// DisconnectBluetooth closes the AAP session of a device and disconnects it from Bluetooth
+func (m *PodStateCoordinator) DisconnectBluetooth(macAddr string) error {
+	m.DisconnectAAP(macAddr)
+	if err := m.bluetooth.Disconnect(macAddr); err != nil {
+		return fmt.Errorf("failed to disconnect %s: %w", macAddr, err)
+	}
+	return nil
+}
+
+// PairableDevice is an unpaired device in pairing mode
+type PairableDevice struct {
+	Address string
+	Name    string
+}
+
+// FindPairableDevice searches for AirPods in pairing mode for up to timeout
+func (m *PodStateCoordinator) FindPairableDevice(timeout time.Duration) (PairableDevice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return m.bluetooth.FindPairable(ctx)
+}
+
+// PairBluetooth pairs with a device in pairing mode, found by FindPairableDevice
+func (m *PodStateCoordinator) PairBluetooth(macAddr string) error {
+	if err := m.bluetooth.Pair(macAddr); err != nil {
+		return fmt.Errorf("failed to pair %s: %w", macAddr, err)
+	}
+	return nil
+}
+
+// BluetoothPowered checks if a Bluetooth adapter is turned on. It returns an error if
+// there is no adapter.
+func (m *PodStateCoordinator) BluetoothPowered() (bool, error) {
+	return m.bluetooth.Powered()
+}
+
+// PowerOnBluetooth turns on the Bluetooth adapter
+func (m *PodStateCoordinator) PowerOnBluetooth() error {
+	if err := m.bluetooth.PowerOn(); err != nil {
+		return fmt.Errorf("failed to turn on Bluetooth: %w", err)
+	}
+	return nil
+}
@@ -3,7 +3,7 @@
 // PodStateCoordinator handles:
 //   - BLE scanning for AirPods data (battery, charging, in-ear detection)
 //   - AAP client for accurate data (1% accuracy, requires connection)
-//   - Notifying UI and other components of state updates via callbacks
+//   - Notifying UI and other components of state changes via events (see Event)
 //
 // Data Source Priority:
 //   - AAP (accurate, 1%) is used when AirPods are connected
@@ -11,37 +11,152 @@
 package podstate
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"strconv"
 	"sync"
 	"time"
 
 	"linuxpods/internal/aap"
 	"linuxpods/internal/ble"
+	"linuxpods/internal/upower"
 )
 
-// UpdateCallback is called when AirPods state data is updated
-// The map key is the device MAC address
-type UpdateCallback func(map[string]*PodState)
+// AAPDialer creates an unconnected AAP connection for the given MAC address.
+// The coordinator uses it to open AAP sessions, which allows replacing the
+// L2CAP client with an in-memory aap.FakeConn for testing.
+type AAPDialer func(macAddr string) (aap.Conn, error)
+
+// defaultAAPDialer creates a real L2CAP AAP client using the transport selected by
+// LINUXPODS_AAP_TRANSPORT: l2cap (default, raw socket) or profile (BlueZ Profile1 over
+// D-Bus, for sandboxed environments such as Flatpak)
+func defaultAAPDialer(macAddr string) (aap.Conn, error) {
+	transport, err := aap.ParseTransport(os.Getenv("LINUXPODS_AAP_TRANSPORT"))
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return aap.NewClient(macAddr, aap.WithTransport(transport))
+}
+
+// Option configures a coordinator when it is created
+type Option func(*PodStateCoordinator)
+
+// WithAAPDialer opens the AAP connections with dialer instead of the L2CAP client
+func WithAAPDialer(dialer AAPDialer) Option {
+	return func(m *PodStateCoordinator) {
+		if dialer != nil {
+			m.dialAAP = dialer
+		}
+	}
+}
+
+// WithBluetooth connects and pairs devices with bt instead of BlueZ
+func WithBluetooth(bt BluetoothController) Option {
+	return func(m *PodStateCoordinator) {
+		if bt != nil {
+			m.bluetooth = bt
+		}
+	}
+}
+
+// defaultDecryptionRules returns the decryption rules selected by LINUXPODS_DECRYPT_RULES:
+// v2 (default) or legacy, for firmware whose advertisements the stricter rules reject
+func defaultDecryptionRules() ble.DecryptionRules {
+	rules, err := ble.ParseDecryptionRules(os.Getenv("LINUXPODS_DECRYPT_RULES"))
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return rules
+}
+
+// defaultSmoothingWindow returns the battery smoothing window selected by
+// LINUXPODS_BATTERY_SMOOTHING (DefaultSmoothingWindow by default, 0 disables smoothing)
+func defaultSmoothingWindow() int {
+	value := os.Getenv("LINUXPODS_BATTERY_SMOOTHING")
+	if value == "" {
+		return DefaultSmoothingWindow
+	}
+	window, err := strconv.Atoi(value)
+	if err != nil || window < 0 {
+		log.Printf("Warning: invalid LINUXPODS_BATTERY_SMOOTHING %q, using %d", value, DefaultSmoothingWindow)
+		return DefaultSmoothingWindow
+	}
+	return window
+}
 
 // PodStateCoordinator manages complete AirPods state and coordinates updates
 type PodStateCoordinator struct {
-	scanner   *ble.Scanner
-	aapClient *aap.Client
+	scanner   AdvertisementSource
+	dialAAP   AAPDialer
+	bluetooth BluetoothController
+
+	events EventBus
+
+	settingsCallbacks Subscribers[SettingsCallback]
 
 	mu             sync.RWMutex
-	callbacks      []UpdateCallback
-	deviceStates   map[string]*PodState // MAC address -> PodState
-	aapConnected   bool
-	aapMacAddr     string            // MAC address of currently connected AAP device
-	encryptionKeys map[string][]byte // MAC address -> ENC_KEY for decrypting BLE advertisements
+	deviceStates   map[string]*PodState   // MAC address -> PodState
+	stateVersion   uint64                 // Number of the last state snapshot (see snapshotLocked)
+	aapSessions    map[string]*aapSession // MAC address -> active AAP session
+	encryptionKeys map[string][]byte      // MAC address -> ENC_KEY for decrypting BLE advertisements
+	irks           map[string][]byte      // MAC address -> IRK for resolving random BLE addresses
+	resolvedAddrs  map[string]string      // Random BLE address -> MAC address, resolved with an IRK
+
+	showForeign bool                         // Show unattributed advertisements although the own devices' keys are known
+	preferences map[string]DevicePreferences // Uppercase MAC address -> settings made by the user
+
+	scanPolicy        ScanPolicy
+	interactive       bool                 // A window shows the state (see SetInteractive)
+	scanPolicyChanged chan struct{}        // Wakes up scanPolicyLoop
+	powerSaver        func() (bool, error) // Reports whether the power saver of the system is on
+
+	fastScanEnabled    bool
+	fastScanUntil      time.Time     // End of the current fast scan burst
+	updateInterval     time.Duration // Minimum time between BLE state updates of a device
+	fastUpdateInterval time.Duration // The same during fast scan bursts
+
+	lastBLEUpdate map[string]time.Time // Device MAC -> time of its last BLE state update (only used by bleUpdateLoop)
+	lidStates     map[string]lidState  // Device MAC -> lid state of its last advertisement (only used by bleUpdateLoop)
+
+	smoothingWindow  int                        // Number of BLE battery readings the shown level is the median of
+	batteryHistories map[string]*batteryHistory // Device MAC -> recent BLE battery readings (only used by bleUpdateLoop)
+
+	history   batteryRecorder // Battery samples of the own devices (see BatteryHistory)
+	lastState lastStateStore  // Last states of the own devices, kept across restarts
+	keyStore  keyStore        // File the keys are kept in across restarts
 
-	stopChan chan struct{}
+	metrics coordinatorMetrics
+	packets packetLog // Recent raw packets for the debug view (see PacketLog)
+
+	reconnects map[string]*aapReconnect // MAC address -> running reconnect attempt
+	handoffs   map[string]time.Time     // MAC address -> when the device disconnected, while checked for a handoff
+
+	signalTrends map[string][]rssiSample // MAC address -> signal strengths of the recent advertisements (see detectLeftBehind)
+
+	suspendInCase bool                 // Close the AAP session while the pods are in the closed case
+	suspended     map[string]struct{}  // MAC addresses with an AAP session closed while in the closed case
+	dockedSince   map[string]time.Time // MAC address -> first advertisement in the closed case of a connected device
+
+	keyRetrieval    aap.KeyRetrieval    // Timeout and attempts of RequestEncryptionKeys
+	decryptionRules ble.DecryptionRules // Plausibility checks for decrypted advertisements
+	staleness       Staleness           // When states without new data are marked stale and removed
+
+	ctx    context.Context // Canceled when the coordinator is closed
+	cancel context.CancelFunc
+	wg     sync.WaitGroup // Goroutines started with spawn, waited for by Close
 }
 
-// NewPodStateCoordinator creates a new AirPods state manager
-func NewPodStateCoordinator() (*PodStateCoordinator, error) {
-	scanner, err := ble.NewScanner()
+// NewPodStateCoordinator creates a new AirPods state manager scanning with the default adapter
+func NewPodStateCoordinator(opts ...Option) (*PodStateCoordinator, error) {
+	return NewPodStateCoordinatorForAdapter("", opts...)
+}
+
+// NewPodStateCoordinatorForAdapter creates a new AirPods state manager scanning with an adapter
+// such as hci1. An empty name selects the default adapter.
+func NewPodStateCoordinatorForAdapter(adapter string, opts ...Option) (*PodStateCoordinator, error) {
+	scanner, err := ble.NewScannerForAdapter(adapter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create BLE scanner: %w", err)
 	}
@@ -52,35 +167,87 @@ func NewPodStateCoordinator() (*PodStateCoordinator, error) {
 		return nil, fmt.Errorf("failed to start BLE discovery: %w", err)
 	}
 
+	m := NewPodStateCoordinatorWithSource(scanner, opts...)
+
+	// Connect to AirPods that were already connected before the app started
+	m.spawn(m.restoreAAPSessions)
+
+	return m, nil
+}
+
+// NewPodStateCoordinatorWithSource creates an AirPods state manager that reads BLE
+// advertisements from the given source instead of the BlueZ scanner.
+// Unlike NewPodStateCoordinator it doesn't look for AirPods connected via BlueZ,
+// AAP sessions are only opened by ConnectAAP. It is used to run the coordinator
+// against a simulated device, usually together with WithAAPDialer.
+func NewPodStateCoordinatorWithSource(source AdvertisementSource, opts ...Option) *PodStateCoordinator {
+	ctx, cancel := context.WithCancel(context.Background())
 	m := &PodStateCoordinator{
-		scanner:        scanner,
-		callbacks:      make([]UpdateCallback, 0),
-		deviceStates:   make(map[string]*PodState),
-		encryptionKeys: make(map[string][]byte),
-		stopChan:       make(chan struct{}),
+		scanner:            source,
+		dialAAP:            defaultAAPDialer,
+		bluetooth:          bluezController{},
+		deviceStates:       make(map[string]*PodState),
+		aapSessions:        make(map[string]*aapSession),
+		encryptionKeys:     make(map[string][]byte),
+		irks:               make(map[string][]byte),
+		resolvedAddrs:      make(map[string]string),
+		scanPolicy:         DefaultScanPolicy,
+		scanPolicyChanged:  make(chan struct{}, 1),
+		powerSaver:         upower.PowerSaverActive,
+		fastScanEnabled:    true,
+		updateInterval:     DefaultUpdateInterval,
+		fastUpdateInterval: DefaultFastUpdateInterval,
+		lastBLEUpdate:      make(map[string]time.Time),
+		lidStates:          make(map[string]lidState),
+		smoothingWindow:    defaultSmoothingWindow(),
+		batteryHistories:   make(map[string]*batteryHistory),
+		reconnects:         make(map[string]*aapReconnect),
+		handoffs:           make(map[string]time.Time),
+		signalTrends:       make(map[string][]rssiSample),
+		suspendInCase:      true,
+		suspended:          make(map[string]struct{}),
+		dockedSince:        make(map[string]time.Time),
+		keyRetrieval:       aap.DefaultKeyRetrieval,
+		decryptionRules:    defaultDecryptionRules(),
+		staleness:          DefaultStaleness,
+		ctx:                ctx,
+		cancel:             cancel,
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
 
 	// Start the state update loop
-	go m.bleUpdateLoop()
+	m.spawn(m.bleUpdateLoop)
+	m.spawn(m.scanPolicyLoop)
 
-	return m, nil
+	return m
 }
 
-// RegisterCallback registers a callback to be notified of state updates
-func (m *PodStateCoordinator) RegisterCallback(cb UpdateCallback) {
+// SetAAPDialer replaces the dialer used to open AAP connections of a running coordinator
+// (see WithAAPDialer). Passing nil restores the default L2CAP client.
+func (m *PodStateCoordinator) SetAAPDialer(dialer AAPDialer) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.callbacks = append(m.callbacks, cb)
-
-	// If we have cached states, immediately notify the new callback
-	if len(m.deviceStates) > 0 {
-		// Create a copy of the states map
-		statesCopy := make(map[string]*PodState, len(m.deviceStates))
-		for addr, state := range m.deviceStates {
-			statesCopy[addr] = state
-		}
-		go cb(statesCopy)
+	if dialer == nil {
+		dialer = defaultAAPDialer
 	}
+	m.dialAAP = dialer
+}
+
+// SetDecryptionRules selects the plausibility checks for decrypted BLE advertisements
+// (LINUXPODS_DECRYPT_RULES or ble.DefaultDecryptionRules by default)
+func (m *PodStateCoordinator) SetDecryptionRules(rules ble.DecryptionRules) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.decryptionRules = rules
+}
+
+// Subscribe registers a handler to be notified of state changes and returns the function
+// that removes it. If states are known, the handler receives them right away in a
+// StatesChanged event.
+func (m *PodStateCoordinator) Subscribe(handler EventHandler) (unsubscribe func()) {
+	return m.events.Subscribe(handler)
 }
 
 // GetDeviceStates returns a copy of all device states
@@ -95,232 +262,225 @@ func (m *PodStateCoordinator) GetDeviceStates() map[string]*PodState {
 	return statesCopy
 }
 
-// GetConnectedDeviceMac returns the MAC address of the currently connected AAP device
-// Returns empty string if no AAP connection is active
-func (m *PodStateCoordinator) GetConnectedDeviceMac() string {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	if m.aapConnected {
-		return m.aapMacAddr
-	}
-	return ""
-}
-
-// bleUpdateLoop continuously scans for AirPods and updates battery data
+// bleUpdateLoop processes the advertisements of the BLE source until the coordinator is closed
 func (m *PodStateCoordinator) bleUpdateLoop() {
+	advertisements := m.scanner.Subscribe()
+	staleness := time.NewTicker(stalenessCheckInterval)
+	defer staleness.Stop()
+
 	for {
 		select {
-		case <-m.stopChan:
+		case <-m.ctx.Done():
 			return
-		default:
-			// Only scan BLE if AAP is not connected (AAP is more accurate)
-			m.mu.RLock()
-			aapActive := m.aapConnected
-			m.mu.RUnlock()
-
-			if !aapActive {
-				// Scan for AirPods with 5-second timeout
-				data, randomMac, err := m.scanner.ScanForAirPods(5 * time.Second)
-				if err == nil {
-					// Try to decrypt with all available keys to find the real device
-					// BLE advertisements use randomized MAC addresses for privacy, so we need to
-					// try all keys to identify which device this advertisement is from
-					realMac := m.tryDecryptAndIdentify(data, randomMac)
-					state := m.bleToState(data, realMac, randomMac)
-					m.handleStateUpdate(realMac, state)
-				}
+		case ad, ok := <-advertisements:
+			if !ok {
+				return // Source closed
 			}
-
-			// Wait before next scan
-			time.Sleep(3 * time.Second)
+			m.handleAdvertisement(ad)
+		case now := <-staleness.C:
+			m.checkStaleness(now)
 		}
 	}
 }
 
-// handleStateUpdate processes new state data and notifies all listeners
-// macAddr is the MAC address of the device this state is for
-func (m *PodStateCoordinator) handleStateUpdate(macAddr string, state *PodState) {
-	m.mu.Lock()
-	m.deviceStates[macAddr] = state
+// maxTrackedBLEUpdates bounds lastBLEUpdate, which also collects the random addresses of
+// unidentified devices
+const maxTrackedBLEUpdates = 256
 
-	// Create a copy of states to send to callbacks
-	statesCopy := make(map[string]*PodState, len(m.deviceStates))
-	for addr, s := range m.deviceStates {
-		statesCopy[addr] = s
+// pruneBLEUpdates forgets the update times of devices that haven't advertised for a while
+func (m *PodStateCoordinator) pruneBLEUpdates(now time.Time) {
+	if len(m.lastBLEUpdate) <= maxTrackedBLEUpdates {
+		return
+	}
+	m.mu.RLock()
+	interval := m.updateInterval
+	m.mu.RUnlock()
+	for mac, last := range m.lastBLEUpdate {
+		if now.Sub(last) > interval {
+			delete(m.lastBLEUpdate, mac)
+		}
 	}
+}
 
-	callbacks := make([]UpdateCallback, len(m.callbacks))
-	copy(callbacks, m.callbacks)
-	m.mu.Unlock()
+// handleAdvertisement updates the state of the device that sent an advertisement.
+// Updates of the same device are limited to one per bleUpdateInterval. Advertisements of
+// ignored devices are only logged for the debug view.
+func (m *PodStateCoordinator) handleAdvertisement(ad ble.Advertisement) {
+	data, randomMac := ad.Data, ad.Address
+	m.packets.record(PacketBLE, randomMac, data.RSSI, append([]byte{0x07, byte(len(data.RawData))}, data.RawData...), ad.Received)
+
+	// Try to decrypt with all available keys to find the real device
+	// BLE advertisements use randomized MAC addresses for privacy, so we need to
+	// try all keys to identify which device this advertisement is from
+	realMac := m.tryDecryptAndIdentify(data, randomMac)
+	if m.isIgnored(realMac) {
+		return
+	}
+	m.detectLidEvents(realMac, data)
+	m.detectHandoff(realMac, data, ad.Received)
+	m.trackSignal(realMac, randomMac, data, ad.Received)
+	m.checkSuspend(realMac, data, ad.Received)
+
+	if last, ok := m.lastBLEUpdate[realMac]; ok && ad.Received.Sub(last) < m.updateIntervalFor(realMac) {
+		return
+	}
+	m.lastBLEUpdate[realMac] = ad.Received
+	m.pruneBLEUpdates(ad.Received)
+
+	if m.shouldUseBLE(realMac, randomMac) {
+		// For devices connected via AAP, the state keeps the more accurate AAP values
+		// (see mergeStates) and takes the lid, signal and model from the advertisement
+		state := m.bleToState(data, realMac, randomMac)
+		m.smoothBattery(realMac, state, data, ad.Received)
+		m.handleStateUpdate(realMac, state)
+	}
+}
 
-	// Notify all registered callbacks
-	for _, cb := range callbacks {
-		cb(statesCopy)
+// shouldUseBLE decides whether a BLE advertisement should update the device state.
+// Advertisements attributed to a known device always do. While any AAP session is active,
+// advertisements that could not be attributed to a known device are skipped: they most
+// likely come from the connected AirPods themselves, whose BLE address is randomized.
+// Unattributed advertisements are also skipped if they are foreign (see isForeign).
+func (m *PodStateCoordinator) shouldUseBLE(realMac string, randomMac string) bool {
+	identified := realMac != randomMac
+	if identified {
+		return true
 	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.aapSessions) == 0 && (m.showForeign || !m.isForeignLocked())
 }
 
-// ConnectAAP connects to AirPods via AAP for accurate battery monitoring
-func (m *PodStateCoordinator) ConnectAAP(macAddr string) error {
+// isForeignLocked reports whether advertisements that could not be attributed to a known
+// device come from someone else's AirPods. This is only known once the keys of this
+// machine's AirPods are known, which identify all of their advertisements. Until then
+// every device could be the user's own. Must be called with mu held.
+func (m *PodStateCoordinator) isForeignLocked() bool {
+	return len(m.encryptionKeys) > 0 || len(m.irks) > 0
+}
+
+// SetShowForeignDevices selects whether AirPods that are not paired to this machine
+// (e.g. of people walking by) are shown once the own AirPods' keys are known.
+// They are hidden by default.
+func (m *PodStateCoordinator) SetShowForeignDevices(show bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.showForeign = show
+}
 
-	// Close existing AAP connection if any
-	if m.aapClient != nil {
-		_ = m.aapClient.Close()
-		m.aapClient = nil
-		m.aapConnected = false
+// handleStateUpdate merges new state data into the state of a device (see mergeStates) and
+// notifies all listeners. macAddr is the MAC address of the device this state is for.
+// Updates of ignored devices are dropped.
+func (m *PodStateCoordinator) handleStateUpdate(macAddr string, update *PodState) {
+	m.mu.Lock()
+	if m.preferencesLocked(macAddr).Ignored {
+		m.mu.Unlock()
+		return
 	}
+	previous := m.deviceStates[macAddr]
+	_, aapActive := m.aapSessions[macAddr]
+	state := mergeStates(previous, update, aapActive)
+	lidOpened := isLidOpenEvent(previous, state)
 
-	// Create new AAP client
-	client, err := aap.NewClient(macAddr)
-	if err != nil {
-		return fmt.Errorf("failed to create AAP client: %w", err)
+	// Only headphones without a case report a single battery (e.g. AirPods Max over AAP,
+	// before the model is known from BLE)
+	if state.Battery != nil {
+		state.Capabilities.HasCase = false
 	}
-
-	// Connect to AirPods
-	if err := client.Connect(); err != nil {
-		return fmt.Errorf("failed to connect AAP: %w", err)
+	if previous != nil && previous.PrimaryPod != PodSideUnknown &&
+		state.PrimaryPod != PodSideUnknown && state.PrimaryPod != previous.PrimaryPod {
+		log.Printf("Primary pod of %s switched: %s -> %s", macAddr, previous.PrimaryPod, state.PrimaryPod)
 	}
+	state.Alias = m.preferencesLocked(macAddr).Alias
+	m.deviceStates[macAddr] = state
 
-	// Send handshake
-	if err := client.Handshake(); err != nil {
-		_ = client.Close()
-		return fmt.Errorf("failed to send handshake: %w", err)
+	// Create a copy of states to send to subscribers
+	snapshot := m.snapshotLocked()
+	m.mu.Unlock()
+
+	m.history.record(macAddr, state)
+	if lidOpened {
+		m.StartFastScan()
 	}
 
-	// Wait for handshake to process
-	time.Sleep(500 * time.Millisecond)
+	m.publishStates(snapshot)
+}
 
-	// Request battery status
-	if err := client.RequestBatteryStatus(); err != nil {
-		_ = client.Close()
-		return fmt.Errorf("failed to request battery: %w", err)
+// updateState applies update to a copy of the current state of a device and notifies all
+// listeners if update reports a change. Nothing happens if the device has no state yet.
+// It is used for notifications that only carry some of the state fields.
+func (m *PodStateCoordinator) updateState(macAddr string, update func(state *PodState) bool) {
+	m.mu.RLock()
+	previous, ok := m.deviceStates[macAddr]
+	m.mu.RUnlock()
+	if !ok {
+		return
 	}
 
-	// Enable special features
-	if err := client.EnableSpecialFeatures(); err != nil {
-		_ = client.Close()
-		return fmt.Errorf("failed to enable features: %w", err)
+	state := *previous
+	if update(&state) {
+		state.LastSeen = time.Now()
+		state.Stale = false
+		m.handleStateUpdate(macAddr, &state)
 	}
-
-	m.aapClient = client
-	m.aapConnected = true
-	m.aapMacAddr = macAddr
-
-	log.Printf("AAP connected successfully to %s - using accurate battery data (1%% precision)", macAddr)
-	log.Println("BLE scanning paused while AAP is active")
-
-	// Start AAP reading loop
-	go m.aapReadLoop()
-
-	return nil
 }
 
-// DisconnectAAP disconnects the AAP client
-func (m *PodStateCoordinator) DisconnectAAP() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// stateSnapshot is a copy of the device states to publish, numbered in the order it was taken
+type stateSnapshot struct {
+	states  map[string]*PodState
+	version uint64
+}
 
-	if m.aapClient != nil {
-		_ = m.aapClient.Close()
-		m.aapClient = nil
-		m.aapConnected = false
-		m.aapMacAddr = ""
-		log.Println("AAP disconnected - resuming BLE scanning for battery data")
+// snapshotLocked copies the device states for publishing. The states are published after
+// unlocking, so concurrent changes (e.g. of an advertisement and an AAP packet) may be
+// published in the wrong order. The version lets the event bus drop the older snapshot.
+// m.mu must be held for writing.
+func (m *PodStateCoordinator) snapshotLocked() stateSnapshot {
+	m.stateVersion++
+	states := make(map[string]*PodState, len(m.deviceStates))
+	for addr, s := range m.deviceStates {
+		states[addr] = s
 	}
+	return stateSnapshot{states: states, version: m.stateVersion}
 }
 
-// aapReadLoop continuously reads AAP packets and updates battery data
-func (m *PodStateCoordinator) aapReadLoop() {
-	for {
-		m.mu.RLock()
-		client := m.aapClient
-		connected := m.aapConnected
-		macAddr := m.aapMacAddr
-		m.mu.RUnlock()
-
-		if !connected || client == nil {
-			return
-		}
-
-		select {
-		case <-m.stopChan:
-			return
-		default:
-			packet, err := client.ReadPacket()
-			if err != nil {
-				log.Printf("AAP read error: %v", err)
-				m.DisconnectAAP()
-				return
-			}
-
-			// Try to parse the battery packet
-			if aap.IsBatteryPacket(packet) {
-				batteryInfo, err := aap.ParseBatteryPacket(packet)
-				if err != nil {
-					log.Printf("AAP battery parse error: %v", err)
-				}
-				// Convert AAP battery info to PodState
-				state := m.aapToState(batteryInfo, packet, macAddr)
-				m.handleStateUpdate(macAddr, state)
-			}
-
-			// Try to parse the proximity keys
-			if aap.IsKeyPacket(packet) {
-				proximityKeys, err := aap.ParseProximityKeys(packet)
-				if err == nil {
-					// Extract and store the ENC_KEY
-					encKey := aap.FindEncryptionKey(proximityKeys)
-					if encKey != nil {
-						m.mu.Lock()
-						m.encryptionKeys[macAddr] = encKey
-
-						// Update the existing state to include the encryption key
-						if existingState, ok := m.deviceStates[macAddr]; ok {
-							existingState.EncryptionKey = make([]byte, len(encKey))
-							copy(existingState.EncryptionKey, encKey)
-						}
-						m.mu.Unlock()
-
-						log.Printf("Stored encryption key for device %s (%d bytes)", macAddr, len(encKey))
-
-						// Notify callbacks of the updated state
-						m.mu.RLock()
-						statesCopy := make(map[string]*PodState, len(m.deviceStates))
-						for addr, s := range m.deviceStates {
-							statesCopy[addr] = s
-						}
-						callbacks := make([]UpdateCallback, len(m.callbacks))
-						copy(callbacks, m.callbacks)
-						m.mu.RUnlock()
-
-						for _, cb := range callbacks {
-							cb(statesCopy)
-						}
-					}
-				}
-			}
-		}
-	}
+// publishStates sends the events of a state change to all subscribers and records the latency
+func (m *PodStateCoordinator) publishStates(snapshot stateSnapshot) {
+	start := time.Now()
+	m.events.PublishVersionedStates(snapshot.states, snapshot.version)
+	m.metrics.recordNotification(time.Since(start))
 }
 
 // bleToState converts BLE ProximityData to PodState
 func (m *PodStateCoordinator) bleToState(data *ble.ProximityData, realMac string, bleMac string) *PodState {
 	state := &PodState{
-		Source:        DataSourceBLE,
-		LeftCharging:  data.LeftCharging,
-		RightCharging: data.RightCharging,
-		CaseCharging:  data.CaseCharging,
-		LeftInEar:     data.LeftInEar,
-		RightInEar:    data.RightInEar,
-		LidOpen:       data.LidOpen,
-		DeviceModel:   data.DeviceModel,
-		ModelName:     ble.DecodeModelName(data.DeviceModel),
-		Color:         data.Color,
-		RealMac:       realMac,
-		CurrentBLEMac: bleMac,
-		RawData:       data.RawData,
-	}
+		Source:          DataSourceBLE,
+		LeftCharging:    data.LeftCharging,
+		RightCharging:   data.RightCharging,
+		CaseCharging:    data.CaseCharging,
+		LeftInEar:       data.LeftInEar,
+		RightInEar:      data.RightInEar,
+		LeftInCase:      data.LeftInCase,
+		RightInCase:     data.RightInCase,
+		LidOpen:         data.LidOpen,
+		LidOpenCount:    data.LidOpenCount,
+		RSSI:            data.RSSI,
+		Proximity:       ble.EstimateProximity(data.RSSI),
+		Status:          data.Status,
+		ConnectionState: data.ConnectionState,
+		LastSeen:        data.LastSeen,
+		DeviceModel:     data.DeviceModel,
+		ModelName:       ble.DecodeModelName(data.DeviceModel),
+		Color:           data.Color,
+		RealMac:         realMac,
+		CurrentBLEMac:   bleMac,
+		IsOwnDevice:     realMac != bleMac, // Identified with the keys of a device paired to this machine
+		Decrypted:       data.HasDecrypted,
+		RawData:         data.RawData,
+	}
+	state.Sources = withSource(nil, DataSourceBLE, data.LastSeen,
+		FieldBattery, FieldEarStatus, FieldLid, FieldSignal, FieldAdvertisement, FieldModel, FieldPrimaryPod)
 
 	// Convert battery levels from *uint8 to *int
 	if data.LeftBattery != nil {
@@ -335,6 +495,13 @@ func (m *PodStateCoordinator) bleToState(data *ble.ProximityData, realMac string
 		level := int(*data.CaseBattery)
 		state.CaseBattery = &level
 	}
+	if data.Battery != nil {
+		level := int(*data.Battery)
+		state.Battery = &level
+	}
+	state.Charging = data.Charging
+
+	state.Capabilities, _ = aap.ModelCapabilities(data.DeviceModel)
 
 	// Convert IsFlipped to PrimaryPod
 	if data.IsFlipped {
@@ -355,67 +522,6 @@ func (m *PodStateCoordinator) bleToState(data *ble.ProximityData, realMac string
 	return state
 }
 
-// getBatteryFromAAP is a helper function that converts AAP Battery data to PodState fields.
-// It returns the battery level (or nil if unavailable) and charging status.
-func getBatteryFromAAP(battery *aap.Battery) (*int, bool) {
-	if battery != nil {
-		level := int(battery.Level)
-		return &level, battery.Status == aap.StatusCharging
-	}
-	return nil, false
-}
-
-// aapToState converts AAP battery info to PodState
-func (m *PodStateCoordinator) aapToState(info *aap.BatteryInfo, rawPacket []byte, macAddr string) *PodState {
-	state := &PodState{
-		Source:  DataSourceAAP,
-		RealMac: macAddr, // AAP uses the real (permanent) MAC address
-		// CurrentBLEMac is empty for AAP connections (no BLE randomization)
-		RawData: rawPacket,
-	}
-
-	// Convert battery information from AAP to PodState
-	state.LeftBattery, state.LeftCharging = getBatteryFromAAP(info.Left)
-	state.RightBattery, state.RightCharging = getBatteryFromAAP(info.Right)
-	state.CaseBattery, state.CaseCharging = getBatteryFromAAP(info.Case)
-
-	// AAP doesn't provide in-ear detection, lid state, device model, color, or primary pod
-	// These fields remain at their zero values
-
-	// Look up the encryption key for this device
-	m.mu.RLock()
-	if encKey, ok := m.encryptionKeys[macAddr]; ok {
-		// Make a copy of the key
-		state.EncryptionKey = make([]byte, len(encKey))
-		copy(state.EncryptionKey, encKey)
-	}
-	m.mu.RUnlock()
-
-	return state
-}
-
-// RequestEncryptionKeys requests encryption keys from connected AirPods via AAP.
-// This requires an active AAP connection to work.
-// Returns an error if no AAP connection is active or if the request fails.
-func (m *PodStateCoordinator) RequestEncryptionKeys() error {
-	m.mu.RLock()
-	client := m.aapClient
-	connected := m.aapConnected
-	m.mu.RUnlock()
-
-	if !connected || client == nil {
-		return fmt.Errorf("no active AAP connection - connect to AirPods first")
-	}
-
-	// Request the keys - they will be automatically stored when received in aapReadLoop
-	if err := client.RequestProximityKeys(); err != nil {
-		return fmt.Errorf("failed to request encryption keys: %w", err)
-	}
-
-	log.Println("Encryption key request sent - keys will be stored when received")
-	return nil
-}
-
 // HasEncryptionKeys checks if any encryption keys have been stored
 func (m *PodStateCoordinator) HasEncryptionKeys() bool {
 	m.mu.RLock()
@@ -450,13 +556,27 @@ func (m *PodStateCoordinator) GetAllEncryptionKeys() map[string][]byte {
 //
 // Returns the real MAC address (from the key that worked), or the random MAC address if no key worked.
 func (m *PodStateCoordinator) tryDecryptAndIdentify(data *ble.ProximityData, randomMac string) string {
-	// Extract encrypted portion (bytes 9-24 of the payload)
-	if len(data.RawData) < 25 {
-		// No encrypted data or payload too short
-		return randomMac
+	m.mu.RLock()
+	rules := m.decryptionRules
+	m.mu.RUnlock()
+
+	// A known IRK identifies the device directly from the random address
+	if realMac, ok := m.resolveWithIRK(randomMac); ok {
+		if key := m.GetEncryptionKey(realMac); key != nil && data.EncryptedPayload() != nil {
+			m.metrics.decryptAttempts.Add(1)
+			decrypted, err := ble.DecryptProximityPayloadWithRules(data.EncryptedPayload(), key, rules)
+			if err == nil && data.AddDecryptedData(decrypted) == nil {
+				m.metrics.decryptSuccesses.Add(1)
+			}
+		}
+		return realMac
 	}
 
-	encryptedPortion := data.RawData[9:25]
+	// Extract encrypted portion (short and legacy payloads have none)
+	encryptedPortion := data.EncryptedPayload()
+	if encryptedPortion == nil {
+		return randomMac
+	}
 
 	// Try all stored encryption keys
 	m.mu.RLock()
@@ -470,7 +590,8 @@ func (m *PodStateCoordinator) tryDecryptAndIdentify(data *ble.ProximityData, ran
 
 	// Try each key
 	for realMac, key := range keysCopy {
-		decrypted, err := ble.DecryptProximityPayload(encryptedPortion, key)
+		m.metrics.decryptAttempts.Add(1)
+		decrypted, err := ble.DecryptProximityPayloadWithRules(encryptedPortion, key, rules)
 		if err != nil {
 			// Decryption failed (wrong key or validation failed)
 			continue
@@ -479,6 +600,7 @@ func (m *PodStateCoordinator) tryDecryptAndIdentify(data *ble.ProximityData, ran
 		// Decryption succeeded, and validation passed - use this key
 		err = data.AddDecryptedData(decrypted)
 		if err == nil {
+			m.metrics.decryptSuccesses.Add(1)
 			log.Printf("BLE: Identified device %s (random MAC: %s) via encryption key", realMac, randomMac)
 			return realMac
 		}
@@ -491,14 +613,37 @@ func (m *PodStateCoordinator) tryDecryptAndIdentify(data *ble.ProximityData, ran
 	return randomMac
 }
 
-// Close stops the pod state manager and cleans up resources
+// spawn runs f in a goroutine that Close waits for. f must return once ctx is canceled.
+// Nothing is started after the coordinator was closed.
+func (m *PodStateCoordinator) spawn(f func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ctx.Err() != nil {
+		return
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		f()
+	}()
+}
+
+// Close stops the pod state manager and cleans up resources. It returns once all goroutines
+// of the coordinator have stopped, which may take until a running AAP connect attempt ends.
 func (m *PodStateCoordinator) Close() error {
-	close(m.stopChan)
+	// Canceled under the lock, so that spawn doesn't start goroutines while waiting for them
+	m.mu.Lock()
+	m.cancel()
+	m.mu.Unlock()
+
+	// Closing the sessions interrupts the read loops
+	m.closeAAPSessions()
+	m.wg.Wait()
 
-	// Close AAP client first
-	if m.aapClient != nil {
-		_ = m.aapClient.Close()
+	if err := m.history.close(); err != nil {
+		log.Printf("Warning: Failed to close battery history: %v", err)
 	}
+	m.lastState.close()
 
 	if m.scanner != nil {
 		if err := m.scanner.Close(); err != nil {
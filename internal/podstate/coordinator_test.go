@@ -0,0 +1,176 @@
+package podstate
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"linuxpods/internal/ble"
+	"linuxpods/internal/simulator"
+)
+
+// testMac is the real MAC address of the device in the tests
+const testMac = "AA:BB:CC:DD:EE:01"
+
+// Keys of the simulated device in the tests
+var (
+	testEncKey = bytes.Repeat([]byte{0x11}, 16)
+	testIRK    = bytes.Repeat([]byte{0x22}, 16)
+)
+
+// fakeSource is an AdvertisementSource whose advertisements are sent by the test
+type fakeSource struct {
+	advertisements chan ble.Advertisement
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{advertisements: make(chan ble.Advertisement)}
+}
+
+func (s *fakeSource) Subscribe() <-chan ble.Advertisement { return s.advertisements }
+func (s *fakeSource) Metrics() ble.ScannerMetrics         { return ble.ScannerMetrics{} }
+func (s *fakeSource) Close() error                        { return nil }
+
+// newTestCoordinator creates a coordinator reading from a fake source, closed when the test ends
+func newTestCoordinator(t *testing.T, opts ...Option) (*PodStateCoordinator, *fakeSource) {
+	t.Helper()
+	source := newFakeSource()
+	m := NewPodStateCoordinatorWithSource(source, opts...)
+	t.Cleanup(func() {
+		if err := m.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+	return m, source
+}
+
+// waitForState waits until the state of a device satisfies ok and returns it
+func waitForState(t *testing.T, m *PodStateCoordinator, macAddr string, ok func(*PodState) bool) *PodState {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if state := m.GetDeviceStates()[macAddr]; state != nil && ok(state) {
+			return state
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("State of %s not reached, last state: %+v", macAddr, m.GetDeviceStates()[macAddr])
+	return nil
+}
+
+// intValue returns the value of an optional level, -1 if it is unknown
+func intValue(level *int) int {
+	if level == nil {
+		return -1
+	}
+	return *level
+}
+
+// newTestDevice creates a simulated AirPods Pro 3 with the test address and keys.
+// It reports left 87%, right 93% and case 54% (see simulator.NewDevice).
+func newTestDevice(t *testing.T) *simulator.Device {
+	t.Helper()
+	device, err := simulator.NewDevice(testMac, 0x2720, testEncKey, testIRK)
+	if err != nil {
+		t.Fatalf("NewDevice: %v", err)
+	}
+	return device
+}
+
+// parseAdvertisement parses the current advertisement of a simulated device
+func parseAdvertisement(t *testing.T, device *simulator.Device) *ble.ProximityData {
+	t.Helper()
+	frame, err := device.Advertisement()
+	if err != nil {
+		t.Fatalf("Advertisement: %v", err)
+	}
+	data, err := ble.ParseProximityData(frame)
+	if err != nil {
+		t.Fatalf("ParseProximityData: %v", err)
+	}
+	return data
+}
+
+// resolvableAddress returns a new random address of a simulated device
+func resolvableAddress(t *testing.T, device *simulator.Device) string {
+	t.Helper()
+	addr, err := device.ResolvableAddress()
+	if err != nil {
+		t.Fatalf("ResolvableAddress: %v", err)
+	}
+	return addr
+}
+
+func TestTryDecryptAndIdentify(t *testing.T) {
+	const staticMac = "C1:22:33:44:55:66" // Not resolvable, identified by decryption only
+	otherKey := bytes.Repeat([]byte{0x33}, 16)
+
+	tests := []struct {
+		name          string
+		encKey, irk   []byte
+		randomMac     bool // Advertise from a resolvable address instead of staticMac
+		wantMac       string
+		wantDecrypted bool
+	}{
+		{name: "no keys", wantMac: staticMac},
+		{name: "encryption key", encKey: testEncKey, wantMac: testMac, wantDecrypted: true},
+		{name: "wrong encryption key", encKey: otherKey, wantMac: staticMac},
+		{name: "IRK", encKey: testEncKey, irk: testIRK, randomMac: true, wantMac: testMac, wantDecrypted: true},
+		{name: "IRK without encryption key", irk: testIRK, randomMac: true, wantMac: testMac},
+		{name: "IRK with wrong encryption key", encKey: otherKey, irk: testIRK, randomMac: true, wantMac: testMac},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestCoordinator(t)
+			device := newTestDevice(t)
+			if tt.encKey != nil || tt.irk != nil {
+				if err := m.ImportKeys(testMac, tt.encKey, tt.irk); err != nil {
+					t.Fatalf("ImportKeys: %v", err)
+				}
+			}
+
+			bleMac := staticMac
+			if tt.randomMac {
+				bleMac = resolvableAddress(t, device)
+			}
+			data := parseAdvertisement(t, device)
+			got := m.tryDecryptAndIdentify(data, bleMac)
+
+			if got != tt.wantMac {
+				t.Errorf("tryDecryptAndIdentify() = %s, want %s", got, tt.wantMac)
+			}
+			if data.HasDecrypted != tt.wantDecrypted {
+				t.Fatalf("HasDecrypted = %t, want %t", data.HasDecrypted, tt.wantDecrypted)
+			}
+			// The decrypted levels have 1% precision, the unencrypted ones 10%
+			if tt.wantDecrypted && (*data.LeftBattery != 87 || *data.RightBattery != 93 || *data.CaseBattery != 54) {
+				t.Errorf("Decrypted levels = %d, %d, %d, want 87, 93, 54", *data.LeftBattery, *data.RightBattery, *data.CaseBattery)
+			}
+		})
+	}
+}
+
+func TestAdvertisementUpdatesIdentifiedDevice(t *testing.T) {
+	m, source := newTestCoordinator(t)
+	device := newTestDevice(t)
+	if err := m.ImportKeys(testMac, testEncKey, testIRK); err != nil {
+		t.Fatalf("ImportKeys: %v", err)
+	}
+
+	bleMac := resolvableAddress(t, device)
+	data := parseAdvertisement(t, device)
+	data.LastSeen = time.Now()
+	source.advertisements <- ble.Advertisement{Data: data, Address: bleMac, Received: data.LastSeen}
+
+	state := waitForState(t, m, testMac, func(s *PodState) bool { return s.Decrypted })
+	if got := [3]int{intValue(state.LeftBattery), intValue(state.RightBattery), intValue(state.CaseBattery)}; got != [3]int{87, 93, 54} {
+		t.Errorf("Left, right and case battery = %v, want [87 93 54]", got)
+	}
+	if state.RealMac != testMac || state.CurrentBLEMac != bleMac || !state.IsOwnDevice {
+		t.Errorf("RealMac = %q, CurrentBLEMac = %q, IsOwnDevice = %t, want %s, %s and true",
+			state.RealMac, state.CurrentBLEMac, state.IsOwnDevice, testMac, bleMac)
+	}
+	if _, ok := m.GetDeviceStates()[bleMac]; ok {
+		t.Errorf("State stored under the random address %s", bleMac)
+	}
+}
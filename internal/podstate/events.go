@@ -0,0 +1,242 @@
+package podstate
+
+import (
+	"sync"
+
+	"linuxpods/internal/aap"
+)
+
+// Event is a change reported by the coordinator. Subscribers switch on the concrete type:
+// StatesChanged for the complete state, or the granular events for the changes they care about.
+type Event interface {
+	event()
+}
+
+// StatesChanged carries all device states after a change. It follows the granular events
+// of the change, and is sent to new subscribers with the current states.
+type StatesChanged struct {
+	States map[string]*PodState
+}
+
+// BatteryChanged is sent when a battery level or charging state of a device changed
+type BatteryChanged struct {
+	Address string
+	State   *PodState
+}
+
+// EarStateChanged is sent when a pod was put in or taken out of an ear
+type EarStateChanged struct {
+	Address    string
+	LeftInEar  bool
+	RightInEar bool
+}
+
+// LidOpened is sent when the case lid of a device was opened. Address is the real address
+// if the device was identified, its random BLE address otherwise.
+type LidOpened struct {
+	Address string
+}
+
+// LidClosed is sent when the case lid of a device was closed (see LidOpened)
+type LidClosed struct {
+	Address string
+}
+
+// NoiseModeChanged is sent when a device reports its noise control mode
+type NoiseModeChanged struct {
+	Address string
+	Mode    aap.NoiseControlMode
+}
+
+// DeviceConnecting is sent when connecting to a device via AAP starts. It is followed by
+// DeviceConnected or DeviceConnectFailed.
+type DeviceConnecting struct {
+	Address string
+}
+
+// DeviceConnectFailed is sent when connecting to a device via AAP failed after all retries
+type DeviceConnectFailed struct {
+	Address string
+}
+
+// DeviceConnected is sent when an AAP connection to a device was opened
+type DeviceConnected struct {
+	Address string
+}
+
+// DeviceDisconnected is sent when the AAP connection to a device was closed or lost
+type DeviceDisconnected struct {
+	Address string
+}
+
+// DeviceSuspended is sent instead of DeviceDisconnected when the AAP connection to a device
+// was closed because its pods are in the closed case (see SetSuspendInCase). DeviceConnecting
+// follows once the lid opens.
+type DeviceSuspended struct {
+	Address string
+}
+
+// DeviceSwitchedAway is sent when a device disconnected from this machine and connected to
+// another one shortly after, e.g. because the AirPods switched to an iPhone
+type DeviceSwitchedAway struct {
+	Address string
+}
+
+// DeviceLeftBehind is sent when the signal of an own device faded rapidly before it disappeared
+// while nobody wore the pods, i.e. the AirPods were probably left behind
+type DeviceLeftBehind struct {
+	Address string
+}
+
+// AudioProfileChanged is sent when the audio profile of a device changed, or an application
+// started or stopped recording from the microphone
+type AudioProfileChanged struct {
+	Address         string
+	Profile         AudioProfile
+	MicrophoneInUse bool
+}
+
+// KeysStored is sent when the ENC_KEY or IRK of a device was retrieved or imported
+type KeysStored struct {
+	Address string
+}
+
+func (StatesChanged) event()       {}
+func (BatteryChanged) event()      {}
+func (EarStateChanged) event()     {}
+func (LidOpened) event()           {}
+func (LidClosed) event()           {}
+func (NoiseModeChanged) event()    {}
+func (DeviceConnecting) event()    {}
+func (DeviceConnectFailed) event() {}
+func (DeviceConnected) event()     {}
+func (DeviceDisconnected) event()  {}
+func (DeviceSuspended) event()     {}
+func (DeviceSwitchedAway) event()  {}
+func (DeviceLeftBehind) event()    {}
+func (AudioProfileChanged) event() {}
+func (KeysStored) event()          {}
+
+// EventHandler is called for every event. Handlers are called from the goroutine that
+// caused the change (the subscriber's for the initial states, see EventBus.Subscribe) and
+// must not block. A panicking handler is logged and skipped.
+type EventHandler func(Event)
+
+// OnStatesChanged returns a handler that calls f with the states of StatesChanged events,
+// for subscribers that show the complete state
+func OnStatesChanged(f func(states map[string]*PodState)) EventHandler {
+	return func(event Event) {
+		if e, ok := event.(StatesChanged); ok {
+			f(e.States)
+		}
+	}
+}
+
+// EventBus delivers events to subscribers. It is used by the coordinator and by
+// dbusapi.Client, which rebuilds the events of the daemon's coordinator.
+type EventBus struct {
+	handlers Subscribers[EventHandler]
+
+	mu        sync.Mutex
+	published map[string]*PodState // States of the last StatesChanged event
+	version   uint64               // Version of the published states (see PublishVersionedStates)
+}
+
+// Subscribe registers a handler for all events and returns the function that removes it.
+// If states were published before, the handler receives them right away in a StatesChanged
+// event, before Subscribe returns. Newer states can't be published meanwhile, so they always
+// arrive after the initial states. The handler must not publish states for this event.
+func (b *EventBus) Subscribe(handler EventHandler) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.published) > 0 {
+		CallSafely(func() { handler(StatesChanged{States: b.published}) })
+	}
+	return b.handlers.Add(handler)
+}
+
+// Publish delivers events to all handlers
+func (b *EventBus) Publish(events ...Event) {
+	for _, event := range events {
+		b.handlers.Each(func(handler EventHandler) { handler(event) })
+	}
+}
+
+// PublishStates delivers the granular events derived from the changes since the last
+// published states (see DiffStates), followed by a StatesChanged event. states must not be
+// modified afterwards.
+func (b *EventBus) PublishStates(states map[string]*PodState) {
+	b.publishStates(states, 0)
+}
+
+// PublishVersionedStates is PublishStates for snapshots numbered in the order they were
+// taken. A snapshot that is older than the published states is dropped, so concurrent
+// publishers can't make the states go back.
+func (b *EventBus) PublishVersionedStates(states map[string]*PodState, version uint64) {
+	b.publishStates(states, version)
+}
+
+// publishStates publishes states of the given version, or regardless of the version if it is 0
+func (b *EventBus) publishStates(states map[string]*PodState, version uint64) {
+	b.mu.Lock()
+	if version != 0 {
+		if version <= b.version {
+			b.mu.Unlock()
+			return
+		}
+		b.version = version
+	}
+	events := DiffStates(b.published, states)
+	b.published = states
+	b.mu.Unlock()
+
+	b.Publish(append(events, StatesChanged{States: states})...)
+}
+
+// DiffStates returns the BatteryChanged, EarStateChanged and AudioProfileChanged events for the differences
+// between two sets of device states. The other events don't follow from the states alone.
+func DiffStates(previous map[string]*PodState, current map[string]*PodState) []Event {
+	var events []Event
+	for macAddr, state := range current {
+		old, known := previous[macAddr]
+		if !known {
+			old = &PodState{}
+		}
+		if old == state {
+			continue
+		}
+		if batteryChanged(old, state) {
+			events = append(events, BatteryChanged{Address: macAddr, State: state})
+		}
+		if old.LeftInEar != state.LeftInEar || old.RightInEar != state.RightInEar {
+			events = append(events, EarStateChanged{Address: macAddr, LeftInEar: state.LeftInEar, RightInEar: state.RightInEar})
+		}
+		if old.AudioProfile != state.AudioProfile || old.MicrophoneInUse != state.MicrophoneInUse {
+			events = append(events, AudioProfileChanged{Address: macAddr, Profile: state.AudioProfile, MicrophoneInUse: state.MicrophoneInUse})
+		}
+	}
+	return events
+}
+
+// batteryChanged reports whether a battery level or charging state differs between two states
+func batteryChanged(a *PodState, b *PodState) bool {
+	equal := func(x, y *int) bool {
+		return x == nil && y == nil || x != nil && y != nil && *x == *y
+	}
+	return !equal(a.LeftBattery, b.LeftBattery) || !equal(a.RightBattery, b.RightBattery) ||
+		!equal(a.CaseBattery, b.CaseBattery) || !equal(a.Battery, b.Battery) ||
+		a.LeftCharging != b.LeftCharging || a.RightCharging != b.RightCharging ||
+		a.CaseCharging != b.CaseCharging || a.Charging != b.Charging
+}
+
+// SettingEvent returns the event for a setting reported by a device, if there is one
+func SettingEvent(macAddr string, cmd aap.ControlCommand) (Event, bool) {
+	if cmd.ID != aap.ControlListeningMode {
+		return nil, false
+	}
+	mode, err := aap.ParseNoiseControlMode(&cmd)
+	if err != nil {
+		return nil, false
+	}
+	return NoiseModeChanged{Address: macAddr, Mode: mode}, true
+}
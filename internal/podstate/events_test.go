@@ -0,0 +1,60 @@
+package podstate
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestEventBusInitialStatesBeforeNewer(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		var bus EventBus
+		bus.PublishStates(map[string]*PodState{testMac: {Alias: "old"}})
+
+		var mu sync.Mutex
+		var received []string
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bus.PublishStates(map[string]*PodState{testMac: {Alias: "new"}})
+		}()
+		bus.Subscribe(OnStatesChanged(func(states map[string]*PodState) {
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, states[testMac].Alias)
+		}))
+		wg.Wait()
+
+		mu.Lock()
+		if len(received) == 0 || received[len(received)-1] != "new" {
+			t.Fatalf("Received states %v, want the newest states last", received)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestEventBusDropsOlderStates(t *testing.T) {
+	var bus EventBus
+	level := func(n int) *int { return &n }
+	var batteries []int
+	bus.Subscribe(func(event Event) {
+		if e, ok := event.(BatteryChanged); ok {
+			batteries = append(batteries, *e.State.Battery)
+		}
+	})
+
+	// The snapshot of version 2 is published after the newer one of version 3
+	bus.PublishVersionedStates(map[string]*PodState{testMac: {Battery: level(80)}}, 1)
+	bus.PublishVersionedStates(map[string]*PodState{testMac: {Battery: level(60)}}, 3)
+	bus.PublishVersionedStates(map[string]*PodState{testMac: {Battery: level(70)}}, 2)
+
+	if len(batteries) != 2 || batteries[0] != 80 || batteries[1] != 60 {
+		t.Errorf("BatteryChanged levels = %v, want [80 60]", batteries)
+	}
+	bus.mu.Lock()
+	published := *bus.published[testMac].Battery
+	bus.mu.Unlock()
+	if published != 60 {
+		t.Errorf("Published battery = %d, want 60", published)
+	}
+}
@@ -0,0 +1,92 @@
+package podstate
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// DefaultUpdateInterval is the minimum time between two BLE state updates of a device.
+	// AirPods advertise several times per second, most advertisements repeat the last state.
+	DefaultUpdateInterval = 3 * time.Second
+
+	// DefaultFastUpdateInterval is the minimum time between BLE state updates during a fast scan burst
+	DefaultFastUpdateInterval = 250 * time.Millisecond
+
+	// FastScanDuration is how long a fast scan burst lasts
+	FastScanDuration = 30 * time.Second
+)
+
+// SetUpdateIntervals sets the minimum time between two BLE state updates of a device,
+// normally and during fast scan bursts. Zero selects the default.
+func (m *PodStateCoordinator) SetUpdateIntervals(normal time.Duration, fast time.Duration) {
+	if normal <= 0 {
+		normal = DefaultUpdateInterval
+	}
+	if fast <= 0 {
+		fast = DefaultFastUpdateInterval
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updateInterval = normal
+	m.fastUpdateInterval = fast
+}
+
+// SetFastScanEnabled enables or disables fast scan bursts (enabled by default).
+// Disabling it also ends a running burst.
+func (m *PodStateCoordinator) SetFastScanEnabled(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fastScanEnabled = enabled
+	if !enabled {
+		m.fastScanUntil = time.Time{}
+	}
+}
+
+// StartFastScan temporarily lets BLE advertisements update the state at a high rate for FastScanDuration.
+// The state changes rapidly after the case is opened or a device connects (e.g. battery of buds
+// that were just docked), which the normal update interval would mostly miss.
+// It is triggered automatically when a lid-open is detected, and does nothing while disabled.
+func (m *PodStateCoordinator) StartFastScan() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.fastScanEnabled {
+		return
+	}
+
+	if !m.fastScanActiveLocked() {
+		log.Printf("BLE: Starting fast scan burst for %v", FastScanDuration)
+		m.updateScanCadence()
+	}
+	m.fastScanUntil = time.Now().Add(FastScanDuration)
+}
+
+// IsFastScanActive reports whether a fast scan burst is running
+func (m *PodStateCoordinator) IsFastScanActive() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.fastScanActiveLocked()
+}
+
+// fastScanActiveLocked reports whether a fast scan burst is running. m.mu must be held.
+func (m *PodStateCoordinator) fastScanActiveLocked() bool {
+	return time.Now().Before(m.fastScanUntil)
+}
+
+// bleUpdateInterval returns the minimum time between two BLE state updates of a device
+func (m *PodStateCoordinator) bleUpdateInterval() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.fastScanActiveLocked() {
+		return m.fastUpdateInterval
+	}
+	return m.updateInterval
+}
+
+// isLidOpenEvent reports whether a state update reflects the case lid being opened
+func isLidOpenEvent(previous *PodState, state *PodState) bool {
+	if state.Source != DataSourceBLE || !state.LidOpen {
+		return false
+	}
+	return previous == nil || previous.Source != DataSourceBLE || !previous.LidOpen
+}
@@ -0,0 +1,131 @@
+package podstate
+
+import (
+	"time"
+)
+
+// Field is a group of PodState fields that is always received together from one source
+type Field int
+
+const (
+	FieldBattery       Field = iota // Battery levels, charging states and the raw data they were decoded from
+	FieldEarStatus                  // LeftInEar, RightInEar
+	FieldLid                        // LidOpen, LidOpenCount
+	FieldSignal                     // RSSI, Proximity
+	FieldModel                      // DeviceModel, ModelName, Color, Capabilities
+	FieldPrimaryPod                 // PrimaryPod
+	FieldAudio                      // AudioProfile, MicrophoneInUse
+	FieldAdvertisement              // Status, ConnectionState
+	FieldDeviceInfo                 // DeviceInfo
+)
+
+func (f Field) String() string {
+	switch f {
+	case FieldBattery:
+		return "battery"
+	case FieldEarStatus:
+		return "ear status"
+	case FieldLid:
+		return "lid"
+	case FieldSignal:
+		return "signal"
+	case FieldAdvertisement:
+		return "advertisement"
+	case FieldModel:
+		return "model"
+	case FieldPrimaryPod:
+		return "primary pod"
+	case FieldAudio:
+		return "audio"
+	case FieldDeviceInfo:
+		return "device info"
+	default:
+		return "unknown"
+	}
+}
+
+// FieldSource is where the value of a field came from and when it was received
+type FieldSource struct {
+	Source  DataSource
+	Updated time.Time
+}
+
+// withSource returns a copy of sources with the source of fields set. The map of a state
+// is shared with its copies and must not be modified.
+func withSource(sources map[Field]FieldSource, source DataSource, updated time.Time, fields ...Field) map[Field]FieldSource {
+	result := make(map[Field]FieldSource, len(sources)+len(fields))
+	for field, s := range sources {
+		result[field] = s
+	}
+	for _, field := range fields {
+		result[field] = FieldSource{Source: source, Updated: updated}
+	}
+	return result
+}
+
+// mergeStates combines an update of a device with its previous state. Fields the update
+// doesn't provide (see PodState.Sources) are kept, so BLE-only fields like the lid and the
+// model survive AAP updates. AAP values are more accurate than BLE values and are kept while
+// they are current, i.e. while the AAP session is active (aapActive).
+func mergeStates(previous *PodState, update *PodState, aapActive bool) *PodState {
+	merged := *update
+	if previous == nil {
+		return &merged
+	}
+
+	merged.Sources = make(map[Field]FieldSource, len(previous.Sources)+len(update.Sources))
+	for field, prev := range previous.Sources {
+		next, provided := update.Sources[field]
+		keep := !provided ||
+			prev.Source == DataSourceAAP && next.Source != DataSourceAAP && aapActive
+		if keep {
+			copyField(&merged, previous, field)
+			merged.Sources[field] = prev
+		}
+	}
+	for field, next := range update.Sources {
+		if _, kept := merged.Sources[field]; !kept {
+			merged.Sources[field] = next
+		}
+	}
+
+	// The battery is the main information, its source is the source of the state
+	if battery, ok := merged.Sources[FieldBattery]; ok {
+		merged.Source = battery.Source
+	}
+	// AAP packets are sent over the connection to the real address, keep the BLE address
+	if merged.CurrentBLEMac == "" {
+		merged.CurrentBLEMac = previous.CurrentBLEMac
+	}
+	merged.IsOwnDevice = merged.IsOwnDevice || previous.IsOwnDevice
+	return &merged
+}
+
+// copyField copies the values of a field from src to dst
+func copyField(dst *PodState, src *PodState, field Field) {
+	switch field {
+	case FieldBattery:
+		dst.LeftBattery, dst.RightBattery, dst.CaseBattery = src.LeftBattery, src.RightBattery, src.CaseBattery
+		dst.LeftCharging, dst.RightCharging, dst.CaseCharging = src.LeftCharging, src.RightCharging, src.CaseCharging
+		dst.Battery, dst.Charging = src.Battery, src.Charging
+		dst.Decrypted, dst.RawData = src.Decrypted, src.RawData
+	case FieldEarStatus:
+		dst.LeftInEar, dst.RightInEar = src.LeftInEar, src.RightInEar
+		dst.LeftInCase, dst.RightInCase = src.LeftInCase, src.RightInCase
+	case FieldLid:
+		dst.LidOpen, dst.LidOpenCount = src.LidOpen, src.LidOpenCount
+	case FieldSignal:
+		dst.RSSI, dst.Proximity = src.RSSI, src.Proximity
+	case FieldAdvertisement:
+		dst.Status, dst.ConnectionState = src.Status, src.ConnectionState
+	case FieldModel:
+		dst.DeviceModel, dst.ModelName, dst.Color = src.DeviceModel, src.ModelName, src.Color
+		dst.Capabilities = src.Capabilities
+	case FieldPrimaryPod:
+		dst.PrimaryPod = src.PrimaryPod
+	case FieldAudio:
+		dst.AudioProfile, dst.MicrophoneInUse = src.AudioProfile, src.MicrophoneInUse
+	case FieldDeviceInfo:
+		dst.DeviceInfo = src.DeviceInfo
+	}
+}
@@ -0,0 +1,147 @@
+package podstate
+
+import (
+	"testing"
+	"time"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/ble"
+)
+
+func TestMergeStates(t *testing.T) {
+	now := time.Now()
+	level := func(v int) *int { return &v }
+
+	// bleState is an advertisement update with battery, lid and model
+	bleState := func(battery int, lidOpen bool) *PodState {
+		return &PodState{
+			Source:        DataSourceBLE,
+			LeftBattery:   level(battery),
+			RightBattery:  level(battery),
+			LidOpen:       lidOpen,
+			DeviceModel:   0x2720,
+			CurrentBLEMac: "4A:11:22:33:44:01",
+			Sources:       withSource(nil, DataSourceBLE, now, FieldBattery, FieldLid, FieldModel),
+		}
+	}
+	// aapState is an AAP battery update, which doesn't know the lid or the BLE address
+	aapState := func(battery int) *PodState {
+		return &PodState{
+			Source:       DataSourceAAP,
+			LeftBattery:  level(battery),
+			RightBattery: level(battery),
+			IsOwnDevice:  true,
+			Sources:      withSource(nil, DataSourceAAP, now, FieldBattery),
+		}
+	}
+
+	tests := []struct {
+		name        string
+		previous    *PodState
+		update      *PodState
+		aapActive   bool
+		wantBattery int
+		wantSource  DataSource
+		wantLidOpen bool
+		wantBLEMac  string
+		wantOwn     bool
+	}{
+		{
+			name:        "first update",
+			update:      bleState(80, true),
+			wantBattery: 80, wantSource: DataSourceBLE, wantLidOpen: true, wantBLEMac: "4A:11:22:33:44:01",
+		},
+		{
+			name:        "AAP update keeps BLE fields",
+			previous:    bleState(80, true),
+			update:      aapState(87),
+			aapActive:   true,
+			wantBattery: 87, wantSource: DataSourceAAP, wantLidOpen: true, wantBLEMac: "4A:11:22:33:44:01", wantOwn: true,
+		},
+		{
+			name:        "BLE update keeps AAP battery while AAP is active",
+			previous:    mergeStates(bleState(80, true), aapState(87), true),
+			update:      bleState(90, false),
+			aapActive:   true,
+			wantBattery: 87, wantSource: DataSourceAAP, wantLidOpen: false, wantBLEMac: "4A:11:22:33:44:01", wantOwn: true,
+		},
+		{
+			name:        "BLE update replaces AAP battery after AAP ended",
+			previous:    mergeStates(bleState(80, true), aapState(87), true),
+			update:      bleState(90, false),
+			wantBattery: 90, wantSource: DataSourceBLE, wantLidOpen: false, wantBLEMac: "4A:11:22:33:44:01", wantOwn: true,
+		},
+		{
+			name:        "AAP update replaces AAP battery",
+			previous:    mergeStates(bleState(80, true), aapState(87), true),
+			update:      aapState(86),
+			aapActive:   true,
+			wantBattery: 86, wantSource: DataSourceAAP, wantLidOpen: true, wantBLEMac: "4A:11:22:33:44:01", wantOwn: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeStates(tt.previous, tt.update, tt.aapActive)
+
+			if got := intValue(merged.LeftBattery); got != tt.wantBattery {
+				t.Errorf("LeftBattery = %d, want %d", got, tt.wantBattery)
+			}
+			if merged.Source != tt.wantSource || merged.Sources[FieldBattery].Source != tt.wantSource {
+				t.Errorf("Source = %s, battery source = %s, want %s", merged.Source, merged.Sources[FieldBattery].Source, tt.wantSource)
+			}
+			if merged.LidOpen != tt.wantLidOpen {
+				t.Errorf("LidOpen = %t, want %t", merged.LidOpen, tt.wantLidOpen)
+			}
+			if merged.DeviceModel != 0x2720 {
+				t.Errorf("DeviceModel = %#x, want 0x2720", merged.DeviceModel)
+			}
+			if merged.CurrentBLEMac != tt.wantBLEMac {
+				t.Errorf("CurrentBLEMac = %q, want %q", merged.CurrentBLEMac, tt.wantBLEMac)
+			}
+			if merged.IsOwnDevice != tt.wantOwn {
+				t.Errorf("IsOwnDevice = %t, want %t", merged.IsOwnDevice, tt.wantOwn)
+			}
+			if tt.previous != nil && merged == tt.previous {
+				t.Error("mergeStates modified the previous state instead of returning a new one")
+			}
+		})
+	}
+}
+
+func TestAdvertisementKeepsAAPBattery(t *testing.T) {
+	conn := aap.NewFakeConn()
+	m, source := newTestCoordinator(t, WithAAPDialer(func(string) (aap.Conn, error) { return conn, nil }))
+	device := newTestDevice(t)
+	if err := m.ImportKeys(testMac, testEncKey, testIRK); err != nil {
+		t.Fatalf("ImportKeys: %v", err)
+	}
+	if err := m.ConnectAAP(testMac); err != nil {
+		t.Fatalf("ConnectAAP: %v", err)
+	}
+
+	// Left 77%, right 84%, case 50%, unlike the 87%, 93% and 54% of the advertisement
+	conn.Push([]byte{
+		0x04, 0x00, 0x04, 0x00, 0x04, 0x00, 0x03,
+		byte(aap.ComponentLeft), 0x01, 77, byte(aap.StatusDischarging), 0x01,
+		byte(aap.ComponentRight), 0x01, 84, byte(aap.StatusDischarging), 0x01,
+		byte(aap.ComponentCase), 0x01, 50, byte(aap.StatusDischarging), 0x01,
+	})
+	waitForState(t, m, testMac, func(s *PodState) bool { return s.Source == DataSourceAAP })
+
+	bleMac := resolvableAddress(t, device)
+	data := parseAdvertisement(t, device)
+	data.LastSeen = time.Now()
+	source.advertisements <- ble.Advertisement{Data: data, Address: bleMac, Received: data.LastSeen}
+
+	state := waitForState(t, m, testMac, func(s *PodState) bool { return s.CurrentBLEMac == bleMac })
+	if got := [3]int{intValue(state.LeftBattery), intValue(state.RightBattery), intValue(state.CaseBattery)}; got != [3]int{77, 84, 50} {
+		t.Errorf("Left, right and case battery = %v, want the AAP levels [77 84 50]", got)
+	}
+	if state.Source != DataSourceAAP {
+		t.Errorf("Source = %s, want AAP", state.Source)
+	}
+	if !state.LidOpen || state.DeviceModel != device.Model || state.Sources[FieldLid].Source != DataSourceBLE {
+		t.Errorf("LidOpen = %t, DeviceModel = %#x, lid source = %s, want the advertisement's lid and model",
+			state.LidOpen, state.DeviceModel, state.Sources[FieldLid].Source)
+	}
+}
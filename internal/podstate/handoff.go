@@ -0,0 +1,52 @@
+package podstate
+
+import (
+	"log"
+	"time"
+
+	"linuxpods/internal/ble"
+)
+
+const (
+	// handoffWindow is how long the advertisements of a disconnected device are checked for a
+	// connection to another device
+	handoffWindow = time.Minute
+
+	// handoffGrace skips the advertisements right after disconnecting, which may still show
+	// the connection to this machine
+	handoffGrace = 3 * time.Second
+)
+
+// watchHandoff starts checking the advertisements of a device that disconnected for a
+// connection to another device (see detectHandoff)
+func (m *PodStateCoordinator) watchHandoff(macAddr string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handoffs[macAddr] = time.Now()
+}
+
+// detectHandoff publishes DeviceSwitchedAway when a device that disconnected from this
+// machine advertises a connection to another host within handoffWindow, e.g. because the
+// AirPods switched to an iPhone. It is only called by the BLE loop.
+func (m *PodStateCoordinator) detectHandoff(macAddr string, data *ble.ProximityData, received time.Time) {
+	m.mu.Lock()
+	disconnected, watching := m.handoffs[macAddr]
+	_, connected := m.aapSessions[macAddr]
+	switch {
+	case !watching:
+		m.mu.Unlock()
+		return
+	case connected || received.Sub(disconnected) > handoffWindow:
+		delete(m.handoffs, macAddr)
+		m.mu.Unlock()
+		return
+	case received.Sub(disconnected) < handoffGrace || !ble.IsConnectedState(data.ConnectionState):
+		m.mu.Unlock()
+		return
+	}
+	delete(m.handoffs, macAddr)
+	m.mu.Unlock()
+
+	log.Printf("BLE: %s switched to another device (%s)", macAddr, ble.DecodeConnectionState(data.ConnectionState))
+	m.events.Publish(DeviceSwitchedAway{Address: macAddr})
+}
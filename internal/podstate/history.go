@@ -0,0 +1,360 @@
+package podstate
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/util"
+)
+
+const (
+	// historyRetention is how long battery samples are kept
+	historyRetention = 7 * 24 * time.Hour
+
+	// estimateWindow is how far back the drain rate of a battery is measured
+	estimateWindow = 6 * time.Hour
+
+	// minEstimateSpan and minEstimateDrop are the minimum duration and level drop of the
+	// measured discharge, shorter or flatter discharges don't give a meaningful rate
+	minEstimateSpan = 10 * time.Minute
+	minEstimateDrop = 2
+)
+
+// historyComponents are the batteries recorded in the history, with their CSV names
+var historyComponents = map[aap.BatteryComponent]string{
+	aap.ComponentLeft:   "left",
+	aap.ComponentRight:  "right",
+	aap.ComponentCase:   "case",
+	aap.ComponentSingle: "headphones",
+}
+
+// BatterySample is a battery level of a device. A sample is recorded whenever the level
+// or charging state of a battery changes.
+type BatterySample struct {
+	Time      time.Time
+	Component aap.BatteryComponent
+	Level     int
+	Charging  bool
+}
+
+// BatteryEstimate is the drain rate of a battery that is not charging and the time until it is empty
+type BatteryEstimate struct {
+	Component aap.BatteryComponent
+	Level     int
+	DrainRate float64       // Percent per hour
+	Remaining time.Duration // From now
+}
+
+// batteryRecorder records the battery samples of the own devices, and appends them to a CSV
+// file if one is set (see SetBatteryHistoryFile)
+type batteryRecorder struct {
+	mu      sync.Mutex
+	samples map[string][]BatterySample // MAC address -> samples, oldest first
+	file    *os.File
+	writer  *csv.Writer
+}
+
+// DefaultBatteryHistoryPath returns the battery history file in the user's data directory:
+// $XDG_DATA_HOME/linuxpods/battery-history.csv (~/.local/share/linuxpods/battery-history.csv)
+func DefaultBatteryHistoryPath() (string, error) {
+	dir, err := util.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "battery-history.csv"), nil
+}
+
+// SetBatteryHistoryFile loads the battery samples of a CSV file and appends new samples to it.
+// Samples older than a week are removed from the file. Without a file, samples are only kept
+// in memory until the coordinator is closed.
+//
+// Columns: timestamp, device, component (left, right, case or headphones), level, charging
+func (m *PodStateCoordinator) SetBatteryHistoryFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	samples, err := readHistory(path, time.Now().Add(-historyRetention))
+	if err != nil {
+		return err
+	}
+	// Rewrite the file without the expired samples
+	if err := writeHistory(path, samples); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+
+	h := &m.history
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.file != nil {
+		_ = h.file.Close()
+	}
+	h.file, h.writer = file, csv.NewWriter(file)
+	if h.samples == nil {
+		h.samples = make(map[string][]BatterySample)
+	}
+	for macAddr, loaded := range samples {
+		h.samples[macAddr] = append(loaded, h.samples[macAddr]...)
+	}
+	log.Printf("Recording battery history to %s", path)
+	return nil
+}
+
+// readHistory reads the samples of a history file newer than since. A missing file has no samples.
+func readHistory(path string, since time.Time) (map[string][]BatterySample, error) {
+	samples := make(map[string][]BatterySample)
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return samples, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 5
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return samples, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		macAddr, sample, err := parseSample(record)
+		if err != nil {
+			log.Printf("Warning: Skipping invalid battery history entry %v: %v", record, err)
+			continue
+		}
+		if sample.Time.After(since) {
+			samples[macAddr] = append(samples[macAddr], sample)
+		}
+	}
+}
+
+// writeHistory replaces a history file with the given samples
+func writeHistory(path string, samples map[string][]BatterySample) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	writer := csv.NewWriter(file)
+	for macAddr, deviceSamples := range samples {
+		for _, sample := range deviceSamples {
+			_ = writer.Write(formatSample(macAddr, sample))
+		}
+	}
+	writer.Flush()
+	if err := errors.Join(writer.Error(), file.Close()); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// formatSample returns the CSV record of a sample
+func formatSample(macAddr string, sample BatterySample) []string {
+	return []string{
+		sample.Time.Format(time.RFC3339),
+		macAddr,
+		historyComponents[sample.Component],
+		strconv.Itoa(sample.Level),
+		strconv.FormatBool(sample.Charging),
+	}
+}
+
+// parseSample parses a CSV record written by formatSample
+func parseSample(record []string) (string, BatterySample, error) {
+	timestamp, err := time.Parse(time.RFC3339, record[0])
+	if err != nil {
+		return "", BatterySample{}, err
+	}
+	level, err := strconv.Atoi(record[3])
+	if err != nil {
+		return "", BatterySample{}, err
+	}
+	charging, err := strconv.ParseBool(record[4])
+	if err != nil {
+		return "", BatterySample{}, err
+	}
+	for component, name := range historyComponents {
+		if name == record[2] {
+			return strings.ToUpper(record[1]), BatterySample{Time: timestamp, Component: component, Level: level, Charging: charging}, nil
+		}
+	}
+	return "", BatterySample{}, fmt.Errorf("unknown component %q", record[2])
+}
+
+// record adds a sample for every battery of a state whose level or charging state changed
+func (h *batteryRecorder) record(macAddr string, state *PodState) {
+	if !state.IsOwnDevice || state.Stale {
+		return
+	}
+	batteries := []struct {
+		component aap.BatteryComponent
+		level     *int
+		charging  bool
+	}{
+		{aap.ComponentLeft, state.LeftBattery, state.LeftCharging},
+		{aap.ComponentRight, state.RightBattery, state.RightCharging},
+		{aap.ComponentCase, state.CaseBattery, state.CaseCharging},
+		{aap.ComponentSingle, state.Battery, state.Charging},
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.samples == nil {
+		h.samples = make(map[string][]BatterySample)
+	}
+	for _, battery := range batteries {
+		if battery.level == nil {
+			continue
+		}
+		if last, ok := h.lastLocked(macAddr, battery.component); ok && last.Level == *battery.level && last.Charging == battery.charging {
+			continue
+		}
+		sample := BatterySample{Time: state.LastSeen, Component: battery.component, Level: *battery.level, Charging: battery.charging}
+		h.samples[macAddr] = append(h.samples[macAddr], sample)
+		if h.writer != nil {
+			_ = h.writer.Write(formatSample(macAddr, sample))
+		}
+	}
+	if h.writer != nil {
+		h.writer.Flush()
+		if err := h.writer.Error(); err != nil {
+			log.Printf("Warning: Failed to write battery history: %v", err)
+		}
+	}
+
+	// Drop expired samples
+	samples := h.samples[macAddr]
+	expired := 0
+	for expired < len(samples) && time.Since(samples[expired].Time) > historyRetention {
+		expired++
+	}
+	h.samples[macAddr] = samples[expired:]
+}
+
+// lastLocked returns the last sample of a battery. Must be called with mu held.
+func (h *batteryRecorder) lastLocked(macAddr string, component aap.BatteryComponent) (BatterySample, bool) {
+	samples := h.samples[macAddr]
+	for i := len(samples) - 1; i >= 0; i-- {
+		if samples[i].Component == component {
+			return samples[i], true
+		}
+	}
+	return BatterySample{}, false
+}
+
+// close closes the history file
+func (h *batteryRecorder) close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.file == nil {
+		return nil
+	}
+	err := h.file.Close()
+	h.file, h.writer = nil, nil
+	return err
+}
+
+// BatteryHistory returns the battery samples of a device recorded since a point in time, oldest first
+func (m *PodStateCoordinator) BatteryHistory(macAddr string, since time.Time) []BatterySample {
+	h := &m.history
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var samples []BatterySample
+	for _, sample := range h.samples[macAddr] {
+		if !sample.Time.Before(since) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// BatteryEstimates returns the drain rate and remaining time of each battery of a device that
+// has been discharging long enough to measure it
+func (m *PodStateCoordinator) BatteryEstimates(macAddr string) []BatteryEstimate {
+	now := time.Now()
+	samples := m.BatteryHistory(macAddr, now.Add(-estimateWindow))
+
+	var estimates []BatteryEstimate
+	for _, component := range []aap.BatteryComponent{aap.ComponentLeft, aap.ComponentRight, aap.ComponentCase, aap.ComponentSingle} {
+		if estimate, ok := estimateBattery(samples, component, now); ok {
+			estimates = append(estimates, estimate)
+		}
+	}
+	return estimates
+}
+
+// estimateBattery measures the drain rate of a battery over its current discharge: the
+// samples since it was last charging or its level last increased
+func estimateBattery(samples []BatterySample, component aap.BatteryComponent, now time.Time) (BatteryEstimate, bool) {
+	var first, last *BatterySample
+	for i := len(samples) - 1; i >= 0; i-- {
+		sample := &samples[i]
+		if sample.Component != component {
+			continue
+		}
+		if sample.Charging || first != nil && sample.Level < first.Level {
+			break
+		}
+		if last == nil {
+			last = sample
+		}
+		first = sample
+	}
+	if last == nil || last.Charging {
+		return BatteryEstimate{}, false
+	}
+
+	span := last.Time.Sub(first.Time)
+	drop := first.Level - last.Level
+	if span < minEstimateSpan || drop < minEstimateDrop {
+		return BatteryEstimate{}, false
+	}
+	rate := float64(drop) / span.Hours()
+	remaining := time.Duration(float64(last.Level)/rate*float64(time.Hour)) - now.Sub(last.Time)
+	return BatteryEstimate{
+		Component: component,
+		Level:     last.Level,
+		DrainRate: rate,
+		Remaining: max(remaining, 0),
+	}, true
+}
+
+// TimeRemaining returns the shortest remaining time of the pods or headphones, i.e. how long
+// the device can still be used. The case is not considered.
+func TimeRemaining(estimates []BatteryEstimate) (time.Duration, bool) {
+	var remaining time.Duration
+	found := false
+	for _, estimate := range estimates {
+		if estimate.Component == aap.ComponentCase {
+			continue
+		}
+		if !found || estimate.Remaining < remaining {
+			remaining = estimate.Remaining
+			found = true
+		}
+	}
+	return remaining, found
+}
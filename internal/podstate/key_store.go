@@ -0,0 +1,113 @@
+package podstate
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"linuxpods/internal/util"
+)
+
+// keyStore keeps the ENC_KEYs and IRKs of all devices in a file readable only by the user, so
+// devices are still recognized after a restart without retrieving or importing their keys
+// again (see WithKeyStoreFile). The file has the format of ExportKeys.
+type keyStore struct {
+	mu   sync.Mutex // Serializes the writes of the file
+	path string     // Empty if the keys are only kept in memory
+}
+
+// DefaultKeyStorePath returns the key store file in the user's data directory:
+// $XDG_DATA_HOME/linuxpods/keys.json (~/.local/share/linuxpods/keys.json)
+func DefaultKeyStorePath() (string, error) {
+	dir, err := util.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "keys.json"), nil
+}
+
+// WithKeyStoreFile loads the keys of a key store file before the coordinator starts scanning,
+// and saves retrieved, imported and removed keys to it. A missing file is created with the
+// first key. A file that can't be read is left alone and the keys are only kept in memory.
+func WithKeyStoreFile(path string) Option {
+	return func(m *PodStateCoordinator) {
+		devices, err := readKeyStore(path)
+		if err != nil {
+			log.Printf("Warning: Keys are not saved: %v", err)
+			return
+		}
+		m.keyStore.path = path
+
+		for macAddr, device := range devices {
+			if device.encKey != nil {
+				m.encryptionKeys[macAddr] = device.encKey
+			}
+			if device.irk != nil {
+				m.irks[macAddr] = device.irk
+			}
+		}
+		if len(devices) > 0 {
+			log.Printf("Loaded the keys of %d device(s) from %s", len(devices), path)
+		}
+	}
+}
+
+// saveKeys writes all keys to the key store file, if there is one
+func (m *PodStateCoordinator) saveKeys() {
+	m.keyStore.mu.Lock()
+	defer m.keyStore.mu.Unlock()
+	if m.keyStore.path == "" {
+		return
+	}
+
+	// Encoded under the store lock, so the last write has the latest keys
+	m.mu.RLock()
+	data, _, err := encodeKeyFile(m.encryptionKeys, m.irks)
+	m.mu.RUnlock()
+	if err == nil {
+		err = writeKeyStore(m.keyStore.path, data)
+	}
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// readKeyStore reads the keys of a key store file. A missing file has no keys.
+func readKeyStore(path string) (map[string]deviceKeys, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]deviceKeys{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key store: %w", err)
+	}
+	devices, err := decodeKeyFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("key store %s: %w", path, err)
+	}
+	return devices, nil
+}
+
+// writeKeyStore replaces a key store file. It is only readable by the user.
+func writeKeyStore(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save keys: %w", err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(tmpPath, 0o600); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to save keys: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to save keys: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package podstate
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+
+	m, _ := newTestCoordinator(t, WithKeyStoreFile(path))
+	var keysStored atomic.Int32
+	m.Subscribe(func(event Event) {
+		if _, ok := event.(KeysStored); ok {
+			keysStored.Add(1)
+		}
+	})
+	if err := m.ImportKeys(testMac, testEncKey, testIRK); err != nil {
+		t.Fatalf("ImportKeys: %v", err)
+	}
+	if got := keysStored.Load(); got != 1 {
+		t.Errorf("KeysStored published %d times, want 1", got)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Key store not written: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("Key store mode = %o, want 600", mode)
+	}
+
+	// A restarted coordinator knows the keys
+	restarted, _ := newTestCoordinator(t, WithKeyStoreFile(path))
+	if key := restarted.GetEncryptionKey(testMac); !bytes.Equal(key, testEncKey) {
+		t.Errorf("Loaded ENC_KEY = %x, want %x", key, testEncKey)
+	}
+	restarted.mu.RLock()
+	irk := restarted.irks[testMac]
+	restarted.mu.RUnlock()
+	if !bytes.Equal(irk, testIRK) {
+		t.Errorf("Loaded IRK = %x, want %x", irk, testIRK)
+	}
+
+	// Removed keys are gone after a restart, the IRK stays
+	if err := restarted.SetEncryptionKey(testMac, nil); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+	again, _ := newTestCoordinator(t, WithKeyStoreFile(path))
+	if key := again.GetEncryptionKey(testMac); key != nil {
+		t.Errorf("ENC_KEY after removing it = %x, want none", key)
+	}
+	again.mu.RLock()
+	irk = again.irks[testMac]
+	again.mu.RUnlock()
+	if !bytes.Equal(irk, testIRK) {
+		t.Errorf("IRK after removing the ENC_KEY = %x, want %x", irk, testIRK)
+	}
+}
+
+func TestKeyStoreInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	m, _ := newTestCoordinator(t, WithKeyStoreFile(path))
+	if err := m.ImportKeys(testMac, testEncKey, nil); err != nil {
+		t.Fatalf("ImportKeys: %v", err)
+	}
+	// The broken file is left alone instead of being replaced
+	if data, err := os.ReadFile(path); err != nil || string(data) != "not json" {
+		t.Errorf("Key store = %q, %v, want it unchanged", data, err)
+	}
+}
@@ -0,0 +1,208 @@
+package podstate
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+)
+
+// keyFile is the format of exported keys: the keys of each device by MAC address, named and
+// base64 encoded like LibrePods stores them. Hex encoded keys are accepted on import.
+//
+//	{
+//	  "AA:BB:CC:DD:EE:FF": {"IRK": "base64...", "ENC_KEY": "base64..."}
+//	}
+type keyFile map[string]keyFileEntry
+
+type keyFileEntry struct {
+	IRK    string `json:"IRK,omitempty"`
+	EncKey string `json:"ENC_KEY,omitempty"`
+}
+
+// deviceKeys are the decoded keys of a device in a key file, either may be nil
+type deviceKeys struct {
+	encKey []byte
+	irk    []byte
+}
+
+// SetEncryptionKey sets the ENC_KEY of a device, e.g. pasted from LibrePods, and shows it in
+// the device's state. A nil key removes it.
+func (m *PodStateCoordinator) SetEncryptionKey(macAddr string, encKey []byte) error {
+	macAddr, err := normalizeMAC(macAddr)
+	if err != nil {
+		return err
+	}
+	if encKey == nil {
+		m.removeEncryptionKey(macAddr)
+		return nil
+	}
+	if len(encKey) != 16 {
+		return fmt.Errorf("ENC_KEY must be 16 bytes, got %d", len(encKey))
+	}
+	m.storeKeys(macAddr, encKey, nil)
+	return nil
+}
+
+// storeKeys stores the ENC_KEY and IRK of a device (either may be nil), saves them in the key
+// store and publishes the updated state
+func (m *PodStateCoordinator) storeKeys(macAddr string, encKey []byte, irk []byte) {
+	m.mu.Lock()
+	if encKey != nil {
+		m.encryptionKeys[macAddr] = append([]byte(nil), encKey...)
+
+		// Update the existing state to include the encryption key. Published states are
+		// shared with subscribers, so the state is replaced by an updated copy.
+		if existingState, ok := m.deviceStates[macAddr]; ok {
+			updated := *existingState
+			updated.EncryptionKey = append([]byte(nil), encKey...)
+			m.deviceStates[macAddr] = &updated
+		}
+	}
+	if irk != nil {
+		m.irks[macAddr] = append([]byte(nil), irk...)
+	}
+	snapshot := m.snapshotLocked()
+	m.mu.Unlock()
+
+	log.Printf("Stored keys for device %s (ENC_KEY: %t, IRK: %t)", macAddr, encKey != nil, irk != nil)
+	m.saveKeys()
+
+	m.events.Publish(KeysStored{Address: macAddr})
+	if encKey != nil {
+		m.publishStates(snapshot)
+	}
+}
+
+// removeEncryptionKey forgets the ENC_KEY of a device and publishes the updated state
+func (m *PodStateCoordinator) removeEncryptionKey(macAddr string) {
+	m.mu.Lock()
+	delete(m.encryptionKeys, macAddr)
+	if state, ok := m.deviceStates[macAddr]; ok {
+		updated := *state
+		updated.EncryptionKey = nil
+		m.deviceStates[macAddr] = &updated
+	}
+	snapshot := m.snapshotLocked()
+	m.mu.Unlock()
+
+	log.Printf("Removed encryption key of device %s", macAddr)
+	m.saveKeys()
+	m.publishStates(snapshot)
+}
+
+// ExportKeys writes the ENC_KEYs and IRKs of all devices to a file readable only by the user
+func (m *PodStateCoordinator) ExportKeys(path string) error {
+	m.mu.RLock()
+	data, devices, err := encodeKeyFile(m.encryptionKeys, m.irks)
+	m.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if devices == 0 {
+		return fmt.Errorf("no keys to export")
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write keys: %w", err)
+	}
+	log.Printf("Exported keys of %d devices to %s", devices, path)
+	return nil
+}
+
+// ImportKeysFile imports the keys of a file written by ExportKeys or in the same format,
+// like ImportKeys for each device. It returns the number of devices.
+func (m *PodStateCoordinator) ImportKeysFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read keys: %w", err)
+	}
+	// All entries are checked first, so a broken file imports nothing
+	devices, err := decodeKeyFile(data)
+	if err != nil {
+		return 0, err
+	}
+
+	for macAddr, device := range devices {
+		if err := m.ImportKeys(macAddr, device.encKey, device.irk); err != nil {
+			return 0, fmt.Errorf("failed to import keys of %s: %w", macAddr, err)
+		}
+	}
+	return len(devices), nil
+}
+
+// encodeKeyFile encodes ENC_KEYs and IRKs by MAC address as a key file and returns the
+// number of devices in it
+func encodeKeyFile(encKeys map[string][]byte, irks map[string][]byte) ([]byte, int, error) {
+	keys := keyFile{}
+	for macAddr, encKey := range encKeys {
+		entry := keys[macAddr]
+		entry.EncKey = base64.StdEncoding.EncodeToString(encKey)
+		keys[macAddr] = entry
+	}
+	for macAddr, irk := range irks {
+		entry := keys[macAddr]
+		entry.IRK = base64.StdEncoding.EncodeToString(irk)
+		keys[macAddr] = entry
+	}
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode keys: %w", err)
+	}
+	return append(data, '\n'), len(keys), nil
+}
+
+// decodeKeyFile decodes and checks the keys of a key file, by MAC address (uppercase)
+func decodeKeyFile(data []byte) (map[string]deviceKeys, error) {
+	var keys keyFile
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("invalid key file: %w", err)
+	}
+
+	devices := make(map[string]deviceKeys, len(keys))
+	for macAddr, entry := range keys {
+		normalized, err := normalizeMAC(macAddr)
+		if err != nil {
+			return nil, err
+		}
+		encKey, err := decodeKey(entry.EncKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ENC_KEY of %s: %w", macAddr, err)
+		}
+		irk, err := decodeKey(entry.IRK)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IRK of %s: %w", macAddr, err)
+		}
+		if (encKey != nil && len(encKey) != 16) || (irk != nil && len(irk) != 16) || (encKey == nil && irk == nil) {
+			return nil, fmt.Errorf("keys of %s must be 16 bytes", macAddr)
+		}
+		devices[normalized] = deviceKeys{encKey: encKey, irk: irk}
+	}
+	return devices, nil
+}
+
+// decodeKey decodes a hex or base64 encoded key. An empty string yields nil.
+func decodeKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if key, err := hex.DecodeString(strings.NewReplacer(" ", "", ":", "").Replace(s)); err == nil {
+		return key, nil
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// normalizeMAC validates a MAC address and returns it in uppercase
+func normalizeMAC(macAddr string) (string, error) {
+	hwAddr, err := net.ParseMAC(macAddr)
+	if err != nil || len(hwAddr) != 6 {
+		return "", fmt.Errorf("invalid MAC address %q", macAddr)
+	}
+	return strings.ToUpper(hwAddr.String()), nil
+}
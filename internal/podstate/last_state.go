@@ -0,0 +1,191 @@
+package podstate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"linuxpods/internal/util"
+)
+
+// lastStateSaveDelay collects the frequent state updates into one write of the last state file
+const lastStateSaveDelay = 30 * time.Second
+
+// lastStateStore keeps the last state of the own devices in a file, to show them right away
+// after a restart (see SetLastStateFile)
+type lastStateStore struct {
+	mu     sync.Mutex
+	path   string
+	saved  map[string]*PodState // MAC address -> last state, including devices that are gone
+	dirty  bool
+	timer  *time.Timer // Pending save, nil if none
+	closed bool
+}
+
+// DefaultLastStatePath returns the last state file in the user's data directory:
+// $XDG_DATA_HOME/linuxpods/last-state.json (~/.local/share/linuxpods/last-state.json)
+func DefaultLastStatePath() (string, error) {
+	dir, err := util.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "last-state.json"), nil
+}
+
+// SetLastStateFile loads the last known states of the own devices from a file and saves them
+// to it while running. Loaded states are shown as stale with the source DataSourceStored until
+// the devices send new data. A missing file is not an error.
+func (m *PodStateCoordinator) SetLastStateFile(path string) error {
+	saved, err := readLastState(path)
+	if err != nil {
+		return err
+	}
+
+	s := &m.lastState
+	s.mu.Lock()
+	s.path = path
+	s.saved = saved
+	s.mu.Unlock()
+
+	m.mu.Lock()
+	restored := 0
+	for macAddr, state := range saved {
+		if _, ok := m.deviceStates[macAddr]; ok || m.preferencesLocked(macAddr).Ignored {
+			continue
+		}
+		stored := *state
+		stored.Source = DataSourceStored
+		stored.Sources = withSource(nil, DataSourceStored, state.LastSeen, FieldBattery, FieldModel, FieldPrimaryPod, FieldDeviceInfo)
+		stored.IsOwnDevice = true
+		stored.Stale = true
+		stored.Alias = m.preferencesLocked(macAddr).Alias
+		m.deviceStates[macAddr] = &stored
+		restored++
+	}
+	snapshot := m.snapshotLocked()
+	m.mu.Unlock()
+
+	m.Subscribe(OnStatesChanged(m.lastState.update))
+	if restored > 0 {
+		log.Printf("Restored the last state of %d device(s) from %s", restored, path)
+		m.publishStates(snapshot)
+	}
+	return nil
+}
+
+// update schedules saving the states of the own devices that received new data
+func (s *lastStateStore) update(states map[string]*PodState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	for macAddr, state := range states {
+		if !state.IsOwnDevice || state.Source == DataSourceStored {
+			continue
+		}
+		if saved, ok := s.saved[macAddr]; ok && saved.LastSeen.Equal(state.LastSeen) {
+			continue
+		}
+		s.saved[macAddr] = savedState(state)
+		s.dirty = true
+	}
+	if s.dirty && s.timer == nil {
+		s.timer = time.AfterFunc(lastStateSaveDelay, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.timer = nil
+			s.saveLocked()
+		})
+	}
+}
+
+// close saves pending changes and stops saving
+func (s *lastStateStore) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.saveLocked()
+	s.closed = true
+}
+
+// saveLocked writes the states if they changed since the last write. s.mu must be held.
+func (s *lastStateStore) saveLocked() {
+	if !s.dirty || s.closed {
+		return
+	}
+	if err := writeLastState(s.path, s.saved); err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	s.dirty = false
+}
+
+// savedState returns the part of a state that is worth keeping across restarts. The ear,
+// lid, signal and audio fields only apply while the device is around, and the keys are
+// kept in the key store file, which unlike this file is only readable by the user
+// (see WithKeyStoreFile).
+func savedState(state *PodState) *PodState {
+	return &PodState{
+		LeftBattery:   state.LeftBattery,
+		RightBattery:  state.RightBattery,
+		CaseBattery:   state.CaseBattery,
+		Battery:       state.Battery,
+		Charging:      state.Charging,
+		LeftCharging:  state.LeftCharging,
+		RightCharging: state.RightCharging,
+		CaseCharging:  state.CaseCharging,
+		DeviceModel:   state.DeviceModel,
+		ModelName:     state.ModelName,
+		Color:         state.Color,
+		PrimaryPod:    state.PrimaryPod,
+		Capabilities:  state.Capabilities,
+		DeviceInfo:    state.DeviceInfo,
+		RealMac:       state.RealMac,
+		LastSeen:      state.LastSeen,
+	}
+}
+
+// readLastState reads the states of a last state file. A missing file has no states.
+func readLastState(path string) (map[string]*PodState, error) {
+	states := make(map[string]*PodState)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read last state: %w", err)
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("invalid last state file %s: %w", path, err)
+	}
+	return states, nil
+}
+
+// writeLastState replaces a last state file
+func writeLastState(path string, states map[string]*PodState) error {
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode last state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save last state: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to save last state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package podstate
+
+import (
+	"log"
+	"time"
+
+	"linuxpods/internal/ble"
+)
+
+const (
+	// signalTrendWindow is how long the signal strength of a device is kept to tell whether
+	// it was left behind
+	signalTrendWindow = time.Minute
+
+	// leftBehindDrop is how much the signal must have dropped within signalTrendWindow
+	// before the device disappeared, in dB
+	leftBehindDrop = 15
+)
+
+// rssiSample is the signal strength of one advertisement
+type rssiSample struct {
+	received time.Time
+	rssi     int16
+}
+
+// trackSignal records the signal strength of an advertisement of an identified device, for
+// detectLeftBehind. It is only called by the BLE loop.
+func (m *PodStateCoordinator) trackSignal(macAddr string, randomMac string, data *ble.ProximityData, received time.Time) {
+	if macAddr == randomMac || data.RSSI == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	samples := m.signalTrends[macAddr]
+	expired := 0
+	for expired < len(samples) && received.Sub(samples[expired].received) > signalTrendWindow {
+		expired++
+	}
+	m.signalTrends[macAddr] = append(samples[expired:], rssiSample{received: received, rssi: data.RSSI})
+}
+
+// leftBehindLocked reports whether the signal of a device dropped rapidly before the device
+// disappeared or its AAP connection was lost, and nobody wears the pods: the machine moved away
+// from the AirPods. The signal trend is consumed, so a device is reported once until it is
+// seen again. m.mu must be held.
+func (m *PodStateCoordinator) leftBehindLocked(macAddr string, state *PodState, now time.Time) bool {
+	samples := m.signalTrends[macAddr]
+	delete(m.signalTrends, macAddr)
+	if len(samples) < 2 || state == nil || !state.IsOwnDevice || state.LeftInEar || state.RightInEar {
+		return false
+	}
+
+	last := samples[len(samples)-1]
+	if now.Sub(last.received) > signalTrendWindow || ble.EstimateProximity(last.rssi) != ble.ProximityFar {
+		return false
+	}
+	strongest := last.rssi
+	for _, sample := range samples {
+		strongest = max(strongest, sample.rssi)
+	}
+	return strongest-last.rssi >= leftBehindDrop
+}
+
+// detectLeftBehind publishes DeviceLeftBehind when the AAP connection of a device was lost
+// after its signal dropped rapidly. Devices without a connection are checked when they stop
+// advertising (see checkStaleness).
+func (m *PodStateCoordinator) detectLeftBehind(macAddr string) {
+	m.mu.Lock()
+	leftBehind := m.leftBehindLocked(macAddr, m.deviceStates[macAddr], time.Now())
+	m.mu.Unlock()
+
+	if leftBehind {
+		m.publishLeftBehind(macAddr)
+	}
+}
+
+// publishLeftBehind logs and publishes DeviceLeftBehind
+func (m *PodStateCoordinator) publishLeftBehind(macAddr string) {
+	log.Printf("BLE: Signal of %s faded before it disappeared, it was probably left behind", macAddr)
+	m.events.Publish(DeviceLeftBehind{Address: macAddr})
+}
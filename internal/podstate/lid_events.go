@@ -0,0 +1,47 @@
+package podstate
+
+import (
+	"log"
+
+	"linuxpods/internal/ble"
+)
+
+// lidState is the lid state of a device from its last advertisement
+type lidState struct {
+	open  bool
+	count uint8
+}
+
+// detectLidEvents compares the lid state of an advertisement with the previous one of the
+// device and publishes LidOpened and LidClosed events for changes. A changed lid open counter shows an opening
+// even if the advertisements sent while the lid was open were missed.
+// It is only called by the BLE loop, no events are sent for the first advertisement of a device.
+func (m *PodStateCoordinator) detectLidEvents(macAddr string, data *ble.ProximityData) {
+	// Devices without a case don't report the lid
+	if data.HasSingleBattery() {
+		return
+	}
+
+	if len(m.lidStates) > maxTrackedBLEUpdates {
+		m.lidStates = make(map[string]lidState)
+	}
+	previous, known := m.lidStates[macAddr]
+	current := lidState{open: data.LidOpen, count: data.LidOpenCount}
+	m.lidStates[macAddr] = current
+	if !known || current == previous {
+		return
+	}
+
+	// A closed lid with a changed counter was opened and closed between two advertisements
+	var events []Event
+	opened := current.count != previous.count || (current.open && !previous.open)
+	if opened {
+		log.Printf("BLE: Lid of %s opened", macAddr)
+		events = append(events, LidOpened{Address: macAddr})
+	}
+	if !current.open && (previous.open || opened) {
+		log.Printf("BLE: Lid of %s closed", macAddr)
+		events = append(events, LidClosed{Address: macAddr})
+	}
+	m.events.Publish(events...)
+}
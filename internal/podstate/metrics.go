@@ -0,0 +1,84 @@
+package podstate
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"linuxpods/internal/ble"
+)
+
+// Metrics is a snapshot of the coordinator's processing counters.
+// It is intended for diagnostics, e.g. to tune scanning behavior.
+type Metrics struct {
+	// Scanner throughput (advertisements/sec, parse successes/failures)
+	Scanner ble.ScannerMetrics
+
+	// BLE payload decryption
+	DecryptAttempts  uint64 // Number of (advertisement, key) decryption attempts
+	DecryptSuccesses uint64 // Number of attempts that identified a device
+
+	// Random BLE addresses resolved to a device with its IRK
+	AddressResolutions uint64
+
+	// Callback latency (time to deliver the events of a state change to all subscribers)
+	Notifications       uint64
+	LastCallbackLatency time.Duration
+	AvgCallbackLatency  time.Duration
+	MaxCallbackLatency  time.Duration
+}
+
+// coordinatorMetrics collects processing counters for the coordinator.
+// All methods are safe for concurrent use.
+type coordinatorMetrics struct {
+	decryptAttempts  atomic.Uint64
+	decryptSuccesses atomic.Uint64
+	resolutions      atomic.Uint64
+
+	mu            sync.Mutex
+	notifications uint64
+	lastLatency   time.Duration
+	maxLatency    time.Duration
+	totalLatency  time.Duration
+}
+
+// recordNotification records how long it took to notify all subscribers
+func (cm *coordinatorMetrics) recordNotification(latency time.Duration) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.notifications++
+	cm.lastLatency = latency
+	cm.totalLatency += latency
+	if latency > cm.maxLatency {
+		cm.maxLatency = latency
+	}
+}
+
+// snapshot returns the current counter values (without scanner metrics)
+func (cm *coordinatorMetrics) snapshot() Metrics {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	metrics := Metrics{
+		DecryptAttempts:     cm.decryptAttempts.Load(),
+		DecryptSuccesses:    cm.decryptSuccesses.Load(),
+		AddressResolutions:  cm.resolutions.Load(),
+		Notifications:       cm.notifications,
+		LastCallbackLatency: cm.lastLatency,
+		MaxCallbackLatency:  cm.maxLatency,
+	}
+	if cm.notifications > 0 {
+		metrics.AvgCallbackLatency = cm.totalLatency / time.Duration(cm.notifications)
+	}
+	return metrics
+}
+
+// Metrics returns a snapshot of the coordinator and scanner metrics
+func (m *PodStateCoordinator) Metrics() Metrics {
+	metrics := m.metrics.snapshot()
+	if m.scanner != nil {
+		metrics.Scanner = m.scanner.Metrics()
+	}
+	return metrics
+}
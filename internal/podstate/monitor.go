@@ -0,0 +1,78 @@
+package podstate
+
+import (
+	"fmt"
+	"log"
+
+	"linuxpods/internal/ble"
+)
+
+// ImportKeys registers keys obtained elsewhere (e.g. from LibrePods on Android) for a device,
+// so its BLE advertisements can be identified and decrypted without ever connecting it via AAP
+// ("monitor mode", e.g. to watch a family member's AirPods battery).
+//
+// encKey (ENC_KEY) decrypts the encrypted part of advertisements, irk (IRK) resolves the
+// randomized BLE address to the device. Either may be nil, but not both. The keys are saved
+// in the key store (see WithKeyStoreFile), so the device is still recognized after a restart.
+func (m *PodStateCoordinator) ImportKeys(macAddr string, encKey []byte, irk []byte) error {
+	macAddr, err := normalizeMAC(macAddr)
+	if err != nil {
+		return err
+	}
+
+	if encKey == nil && irk == nil {
+		return fmt.Errorf("no key to import")
+	}
+	if encKey != nil && len(encKey) != 16 {
+		return fmt.Errorf("ENC_KEY must be 16 bytes, got %d", len(encKey))
+	}
+	if irk != nil && len(irk) != 16 {
+		return fmt.Errorf("IRK must be 16 bytes, got %d", len(irk))
+	}
+
+	// Like keys retrieved via AAP, so a device that is already shown is decrypted right away
+	// and the keys are kept in the key store
+	m.storeKeys(macAddr, encKey, irk)
+	log.Printf("Imported keys for device %s (ENC_KEY: %t, IRK: %t)", macAddr, encKey != nil, irk != nil)
+	return nil
+}
+
+// maxResolvedAddrs bounds the cache of resolved random addresses, which grows as devices
+// rotate their address (about every 15 minutes)
+const maxResolvedAddrs = 64
+
+// resolveWithIRK returns the real MAC address of the device whose IRK resolves the random address.
+// Resolved addresses are cached, since a device keeps its random address for several minutes.
+func (m *PodStateCoordinator) resolveWithIRK(randomMac string) (string, bool) {
+	m.mu.RLock()
+	if realMac, ok := m.resolvedAddrs[randomMac]; ok {
+		m.mu.RUnlock()
+		return realMac, true
+	}
+	irks := make(map[string][]byte, len(m.irks))
+	for mac, irk := range m.irks {
+		irks[mac] = irk
+	}
+	m.mu.RUnlock()
+
+	for realMac, irk := range irks {
+		resolved, err := ble.ResolvePrivateAddress(irk, randomMac)
+		if err != nil {
+			log.Printf("BLE: Failed to resolve %s with IRK of %s: %v", randomMac, realMac, err)
+			continue
+		}
+		if resolved {
+			m.mu.Lock()
+			if len(m.resolvedAddrs) >= maxResolvedAddrs {
+				clear(m.resolvedAddrs) // Old addresses are no longer used by the devices
+			}
+			m.resolvedAddrs[randomMac] = realMac
+			m.mu.Unlock()
+			m.metrics.resolutions.Add(1)
+
+			log.Printf("BLE: Identified device %s (random MAC: %s) via IRK", realMac, randomMac)
+			return realMac, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,38 @@
+package podstate
+
+import (
+	"bytes"
+	"testing"
+
+	"linuxpods/internal/ble"
+)
+
+func TestResolveWithIRK(t *testing.T) {
+	m, _ := newTestCoordinator(t)
+	device := newTestDevice(t)
+	if err := m.ImportKeys(testMac, nil, testIRK); err != nil {
+		t.Fatalf("ImportKeys: %v", err)
+	}
+
+	randomMac := resolvableAddress(t, device)
+	for i := range 2 {
+		realMac, ok := m.resolveWithIRK(randomMac)
+		if !ok || realMac != testMac {
+			t.Fatalf("resolveWithIRK(%s) = %q, %t, want %s", randomMac, realMac, ok, testMac)
+		}
+		// The second lookup is answered from the cache
+		if got := m.Metrics().AddressResolutions; got != 1 {
+			t.Errorf("AddressResolutions after %d lookups = %d, want 1", i+1, got)
+		}
+	}
+
+	otherMac, err := ble.GenerateResolvablePrivateAddress(bytes.Repeat([]byte{0x33}, 16))
+	if err != nil {
+		t.Fatalf("GenerateResolvablePrivateAddress: %v", err)
+	}
+	for _, addr := range []string{otherMac, "C1:22:33:44:55:66", "invalid"} {
+		if realMac, ok := m.resolveWithIRK(addr); ok {
+			t.Errorf("resolveWithIRK(%s) = %s, want no match", addr, realMac)
+		}
+	}
+}
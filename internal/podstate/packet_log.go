@@ -0,0 +1,73 @@
+package podstate
+
+import (
+	"sync"
+	"time"
+)
+
+// packetLogSize is how many packets the packet log keeps
+const packetLogSize = 500
+
+// PacketKind tells which protocol a logged packet belongs to
+type PacketKind string
+
+const (
+	PacketBLE PacketKind = "ble" // Apple manufacturer data frame of an advertisement
+	PacketAAP PacketKind = "aap" // Packet received over an AAP connection
+)
+
+// LoggedPacket is a raw packet received by the coordinator, kept for the debug view
+type LoggedPacket struct {
+	Seq     uint64 // Increasing number, for fetching only the packets after the last one seen
+	Time    time.Time
+	Kind    PacketKind
+	Address string // Random BLE address of an advertisement, MAC address of an AAP connection
+	RSSI    int16  // Signal strength of an advertisement, 0 if unknown
+	Data    []byte // Frame in the format of BLE recordings (see ble.RecordedFrame) or the AAP packet
+}
+
+// packetLog keeps the most recent packets. All methods are safe for concurrent use.
+type packetLog struct {
+	mu      sync.Mutex
+	packets []LoggedPacket // Oldest first
+	seq     uint64
+}
+
+// record adds a packet, dropping the oldest one if the log is full. data is copied.
+func (l *packetLog) record(kind PacketKind, address string, rssi int16, data []byte, received time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	if len(l.packets) >= packetLogSize {
+		l.packets = append(l.packets[:0], l.packets[1:]...)
+	}
+	l.packets = append(l.packets, LoggedPacket{
+		Seq:     l.seq,
+		Time:    received,
+		Kind:    kind,
+		Address: address,
+		RSSI:    rssi,
+		Data:    append([]byte(nil), data...),
+	})
+}
+
+// after returns the packets after the one with the given sequence number, oldest first
+func (l *packetLog) after(seq uint64) []LoggedPacket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, packet := range l.packets {
+		if packet.Seq > seq {
+			return append([]LoggedPacket(nil), l.packets[i:]...)
+		}
+	}
+	return nil
+}
+
+// PacketLog returns the most recent BLE advertisements and AAP packets received after the
+// packet with the given sequence number (0 for all), oldest first. It is intended for the
+// debug view, to collect captures of unsupported models and packets.
+func (m *PodStateCoordinator) PacketLog(after uint64) []LoggedPacket {
+	return m.packets.after(after)
+}
@@ -0,0 +1,93 @@
+package podstate
+
+import (
+	"log"
+	"strings"
+)
+
+// DevicePreferences are the settings the user made for a single device
+type DevicePreferences struct {
+	Alias       string // Name shown instead of the model name (PodState.Alias), empty if none
+	AutoConnect bool   // Open the AAP connection when the device connects via Bluetooth
+	Ignored     bool   // Neither show the device nor connect to it
+}
+
+// DefaultDevicePreferences apply to devices without preferences
+var DefaultDevicePreferences = DevicePreferences{AutoConnect: true}
+
+// WithDevicePreferences sets the preferences of the devices before the coordinator looks for
+// connected devices, so ignored devices aren't connected at startup (see SetDevicePreferences)
+func WithDevicePreferences(preferences map[string]DevicePreferences) Option {
+	return func(m *PodStateCoordinator) {
+		m.preferences = normalizePreferences(preferences)
+	}
+}
+
+// SetDevicePreferences sets the preferences of the devices by MAC address. Devices that are
+// not listed get DefaultDevicePreferences. Devices that become ignored are disconnected and
+// removed from the states.
+func (m *PodStateCoordinator) SetDevicePreferences(preferences map[string]DevicePreferences) {
+	m.mu.Lock()
+	m.preferences = normalizePreferences(preferences)
+
+	changed := false
+	var ignored []string
+	for macAddr, state := range m.deviceStates {
+		prefs := m.preferencesLocked(macAddr)
+		if prefs.Ignored {
+			delete(m.deviceStates, macAddr)
+			ignored = append(ignored, macAddr)
+			changed = true
+			continue
+		}
+		if prefs.Alias != state.Alias {
+			updated := *state
+			updated.Alias = prefs.Alias
+			m.deviceStates[macAddr] = &updated
+			changed = true
+		}
+	}
+	snapshot := m.snapshotLocked()
+	m.mu.Unlock()
+
+	for _, macAddr := range ignored {
+		log.Printf("Ignoring %s", macAddr)
+		m.DisconnectAAP(macAddr)
+	}
+	if changed {
+		m.publishStates(snapshot)
+	}
+}
+
+// ShouldAutoConnect reports whether the AAP connection of a device is opened when it connects
+// via Bluetooth. It is not for ignored devices and those the user turned it off for.
+func (m *PodStateCoordinator) ShouldAutoConnect(macAddr string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	prefs := m.preferencesLocked(macAddr)
+	return prefs.AutoConnect && !prefs.Ignored
+}
+
+// isIgnored reports whether the user chose to ignore a device
+func (m *PodStateCoordinator) isIgnored(macAddr string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.preferencesLocked(macAddr).Ignored
+}
+
+// preferencesLocked returns the preferences of a device. Must be called with mu held.
+func (m *PodStateCoordinator) preferencesLocked(macAddr string) DevicePreferences {
+	if prefs, ok := m.preferences[strings.ToUpper(macAddr)]; ok {
+		return prefs
+	}
+	return DefaultDevicePreferences
+}
+
+// normalizePreferences returns a copy of preferences keyed by uppercase MAC addresses
+func normalizePreferences(preferences map[string]DevicePreferences) map[string]DevicePreferences {
+	normalized := make(map[string]DevicePreferences, len(preferences))
+	for macAddr, prefs := range preferences {
+		normalized[strings.ToUpper(macAddr)] = prefs
+	}
+	return normalized
+}
@@ -0,0 +1,164 @@
+package podstate
+
+import (
+	"log"
+	"time"
+
+	"linuxpods/internal/ble"
+)
+
+const (
+	// scanPolicyInterval is how often the adaptive scan cadence is chosen again
+	scanPolicyInterval = 10 * time.Second
+
+	// powerSaverCheckInterval is how often the power profile is read
+	powerSaverCheckInterval = time.Minute
+
+	// lowBatteryScanLevel is the battery level below which the adaptive cadence keeps scanning
+	// actively, to notice the AirPods running out
+	lowBatteryScanLevel = 20
+)
+
+// ScanMode selects how the BLE scan cadence is chosen
+type ScanMode int
+
+const (
+	ScanContinuous ScanMode = iota // Discovery runs, except while suspended (see ScanPolicy.Suspended)
+	ScanAdaptive                   // Discovery follows ScanPolicy.Active or ScanPolicy.Idle
+)
+
+// ScanPolicy configures the BLE scan cadence of the adaptive mode. The active cadence is used
+// while a window shows the state (see SetInteractive) and while a battery is low, the idle
+// cadence otherwise. Fast scan bursts scan continuously, and scanning pauses while the power
+// saver is on.
+//
+// The continuous mode uses the suspended cadence while no AAP connection is open because the
+// pods are in the closed case (see SetSuspendInCase). It only needs to notice the lid opening.
+type ScanPolicy struct {
+	Mode              ScanMode
+	Active            ble.Cadence
+	Idle              ble.Cadence
+	Suspended         ble.Cadence
+	PauseOnPowerSaver bool
+}
+
+// DefaultScanPolicy scans continuously, for 5 seconds every 10 seconds while suspended. Its
+// adaptive cadences scan for 5 seconds, pausing 3 seconds when active and a minute when idle.
+var DefaultScanPolicy = ScanPolicy{
+	Mode:              ScanContinuous,
+	Active:            ble.Cadence{Scan: 5 * time.Second, Pause: 3 * time.Second},
+	Idle:              ble.Cadence{Scan: 5 * time.Second, Pause: time.Minute},
+	Suspended:         ble.Cadence{Scan: 5 * time.Second, Pause: 5 * time.Second},
+	PauseOnPowerSaver: true,
+}
+
+// cadenceSetter is implemented by advertisement sources whose discovery can be paused,
+// like ble.Scanner
+type cadenceSetter interface {
+	SetCadence(cadence ble.Cadence)
+}
+
+// SetScanPolicy configures the BLE scan cadence (DefaultScanPolicy by default)
+func (m *PodStateCoordinator) SetScanPolicy(policy ScanPolicy) {
+	m.mu.Lock()
+	m.scanPolicy = policy
+	m.mu.Unlock()
+	m.updateScanCadence()
+}
+
+// SetInteractive reports whether a window shows the state, which makes the adaptive scan
+// cadence scan actively
+func (m *PodStateCoordinator) SetInteractive(interactive bool) {
+	m.mu.Lock()
+	m.interactive = interactive
+	m.mu.Unlock()
+	m.updateScanCadence()
+}
+
+// updateScanCadence wakes up the scan policy loop to choose the cadence again
+func (m *PodStateCoordinator) updateScanCadence() {
+	select {
+	case m.scanPolicyChanged <- struct{}{}:
+	default:
+	}
+}
+
+// scanPolicyLoop chooses the scan cadence when the policy or the situation changes, until
+// the coordinator is closed
+func (m *PodStateCoordinator) scanPolicyLoop() {
+	setter, ok := m.scanner.(cadenceSetter)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(scanPolicyInterval)
+	defer ticker.Stop()
+	var powerSaver bool
+	var powerCheck time.Time
+	for {
+		m.mu.RLock()
+		policy := m.scanPolicy
+		m.mu.RUnlock()
+
+		if policy.Mode == ScanAdaptive && policy.PauseOnPowerSaver && time.Since(powerCheck) >= powerSaverCheckInterval {
+			active, err := m.powerSaver()
+			if err != nil {
+				log.Printf("Warning: Power profile unknown: %v", err)
+			}
+			if active && !powerSaver {
+				log.Printf("BLE: Power saver on, pausing the scan")
+			}
+			powerSaver, powerCheck = active, time.Now()
+		}
+		setter.SetCadence(m.chooseCadence(policy, powerSaver))
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.scanPolicyChanged:
+		case <-ticker.C:
+		}
+	}
+}
+
+// chooseCadence returns the scan cadence for the current situation
+func (m *PodStateCoordinator) chooseCadence(policy ScanPolicy, powerSaver bool) ble.Cadence {
+	if policy.Mode == ScanAdaptive && policy.PauseOnPowerSaver && powerSaver {
+		return ble.Cadence{Off: true}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.fastScanActiveLocked() {
+		return ble.ContinuousCadence
+	}
+	if policy.Mode != ScanAdaptive {
+		if len(m.suspended) > 0 && len(m.aapSessions) == 0 {
+			return policy.Suspended
+		}
+		return ble.ContinuousCadence
+	}
+	if m.interactive {
+		return policy.Active
+	}
+	for macAddr, state := range m.deviceStates {
+		// Suspended pods are charging in the case
+		if _, suspended := m.suspended[macAddr]; suspended {
+			continue
+		}
+		if state.IsOwnDevice && !state.Stale && lowBattery(state) {
+			return policy.Active
+		}
+	}
+	return policy.Idle
+}
+
+// lowBattery reports whether a battery of a device is below lowBatteryScanLevel
+func lowBattery(state *PodState) bool {
+	for _, level := range []*int{state.LeftBattery, state.RightBattery, state.Battery} {
+		if level != nil && *level < lowBatteryScanLevel {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package podstate
+
+import (
+	"sort"
+)
+
+// SelectDevice picks the device to show when only one can be displayed (main window, tray).
+// Devices connected via AAP are preferred, then devices identified by their keys, then the
+// device with the strongest signal. Stale devices are only selected if there are no others. Ties are broken by MAC address, so the choice is stable
+// across updates. It returns "" and nil if there are no devices.
+func SelectDevice(states map[string]*PodState) (string, *PodState) {
+	macs := make([]string, 0, len(states))
+	for macAddr := range states {
+		macs = append(macs, macAddr)
+	}
+	sort.Slice(macs, func(i, j int) bool {
+		a, b := states[macs[i]], states[macs[j]]
+		if rankA, rankB := selectionRank(a), selectionRank(b); rankA != rankB {
+			return rankA > rankB
+		}
+		if a.RSSI != b.RSSI {
+			// Unknown RSSI (0) sorts last
+			return a.RSSI != 0 && (b.RSSI == 0 || a.RSSI > b.RSSI)
+		}
+		return macs[i] < macs[j]
+	})
+
+	if len(macs) == 0 {
+		return "", nil
+	}
+	return macs[0], states[macs[0]]
+}
+
+// selectionRank ranks a device by how reliable its state is
+func selectionRank(state *PodState) int {
+	switch {
+	case state.Stale:
+		return -1
+	case state.Source == DataSourceAAP:
+		return 2
+	case state.IsOwnDevice:
+		return 1 // Identified via ENC_KEY or IRK
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,279 @@
+package podstate
+
+import (
+	"fmt"
+	"log"
+
+	"linuxpods/internal/aap"
+)
+
+// SettingsCallback is called when a device reports the current value of a setting
+// (read-back of control commands), e.g. after connecting or after a setting was changed
+type SettingsCallback func(macAddr string, cmd aap.ControlCommand)
+
+// reportedSetting is a setting value reported by a device
+type reportedSetting struct {
+	macAddr string
+	cmd     aap.ControlCommand
+}
+
+// RegisterSettingsCallback registers a callback to be notified of device setting values and
+// returns the function that removes it. Settings that are already known are reported to the
+// new callback immediately.
+func (m *PodStateCoordinator) RegisterSettingsCallback(cb SettingsCallback) (unregister func()) {
+	unregister = m.settingsCallbacks.Add(cb)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var known []reportedSetting
+	for macAddr, session := range m.aapSessions {
+		for _, cmd := range session.settings {
+			known = append(known, reportedSetting{macAddr: macAddr, cmd: cmd})
+		}
+	}
+	if len(known) > 0 {
+		go CallSafely(func() {
+			for _, setting := range known {
+				cb(setting.macAddr, setting.cmd)
+			}
+		})
+	}
+	return unregister
+}
+
+// GetControlSetting returns the last reported value of a device setting
+func (m *PodStateCoordinator) GetControlSetting(macAddr string, id aap.ControlCommandID) (aap.ControlCommand, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.aapSessions[macAddr]
+	if !ok {
+		return aap.ControlCommand{}, false
+	}
+	cmd, ok := session.settings[id]
+	return cmd, ok
+}
+
+// handleControlCommand stores a setting value reported by the device and notifies callbacks
+func (m *PodStateCoordinator) handleControlCommand(session *aapSession, cmd *aap.ControlCommand) {
+	m.mu.Lock()
+	session.settings[cmd.ID] = *cmd
+	m.mu.Unlock()
+
+	log.Printf("AAP setting reported by %s: %s = % X", session.macAddr, cmd.ID, cmd.Value)
+
+	m.settingsCallbacks.Each(func(cb SettingsCallback) { cb(session.macAddr, *cmd) })
+	if event, ok := SettingEvent(session.macAddr, *cmd); ok {
+		m.events.Publish(event)
+	}
+}
+
+// SetMicrophoneMode selects which bud's microphone the device uses.
+// This requires an active AAP connection to the device.
+func (m *PodStateCoordinator) SetMicrophoneMode(macAddr string, mode aap.MicrophoneMode) error {
+	client, err := m.activeAAPConn(macAddr)
+	if err != nil {
+		return err
+	}
+
+	if err := aap.SetMicrophoneMode(client, mode); err != nil {
+		return fmt.Errorf("failed to set microphone mode: %w", err)
+	}
+
+	log.Printf("Microphone mode of %s set to: %s", macAddr, mode)
+	return nil
+}
+
+// GetMicrophoneMode returns the microphone mode last reported by the device
+func (m *PodStateCoordinator) GetMicrophoneMode(macAddr string) (aap.MicrophoneMode, bool) {
+	cmd, ok := m.GetControlSetting(macAddr, aap.ControlMicrophoneMode)
+	if !ok {
+		return 0, false
+	}
+	mode, err := aap.ParseMicrophoneMode(&cmd)
+	if err != nil {
+		return 0, false
+	}
+	return mode, true
+}
+
+// SetEarDetection enables or disables automatic ear detection on the device.
+// This requires an active AAP connection to the device.
+func (m *PodStateCoordinator) SetEarDetection(macAddr string, enabled bool) error {
+	client, err := m.activeAAPConn(macAddr)
+	if err != nil {
+		return err
+	}
+
+	if err := aap.SetEarDetection(client, enabled); err != nil {
+		return fmt.Errorf("failed to set ear detection: %w", err)
+	}
+
+	log.Printf("Automatic ear detection of %s set to: %t", macAddr, enabled)
+	return nil
+}
+
+// GetEarDetection returns whether automatic ear detection is enabled, as last reported by the device
+func (m *PodStateCoordinator) GetEarDetection(macAddr string) (bool, bool) {
+	cmd, ok := m.GetControlSetting(macAddr, aap.ControlEarDetection)
+	if !ok {
+		return false, false
+	}
+	enabled, err := aap.ParseEarDetection(&cmd)
+	if err != nil {
+		return false, false
+	}
+	return enabled, true
+}
+
+// SetToneVolume sets the volume of the tones and alerts played by the device.
+// This requires an active AAP connection to the device.
+func (m *PodStateCoordinator) SetToneVolume(macAddr string, volume uint8) error {
+	client, err := m.activeAAPConn(macAddr)
+	if err != nil {
+		return err
+	}
+
+	if err := aap.SetToneVolume(client, volume); err != nil {
+		return fmt.Errorf("failed to set tone volume: %w", err)
+	}
+
+	log.Printf("Tone volume of %s set to: %d%%", macAddr, volume)
+	return nil
+}
+
+// GetToneVolume returns the tone volume last reported by the device
+func (m *PodStateCoordinator) GetToneVolume(macAddr string) (uint8, bool) {
+	cmd, ok := m.GetControlSetting(macAddr, aap.ControlChimeVolume)
+	if !ok {
+		return 0, false
+	}
+	volume, err := aap.ParseToneVolume(&cmd)
+	if err != nil {
+		return 0, false
+	}
+	return volume, true
+}
+
+// SetPressAndHold sets what pressing and holding the stem of each bud does.
+// This requires an active AAP connection to the device.
+func (m *PodStateCoordinator) SetPressAndHold(macAddr string, pressAndHold aap.PressAndHold) error {
+	client, err := m.activeAAPConn(macAddr)
+	if err != nil {
+		return err
+	}
+
+	if err := aap.SetPressAndHold(client, pressAndHold); err != nil {
+		return fmt.Errorf("failed to set press and hold: %w", err)
+	}
+
+	log.Printf("Press and hold of %s set to: %s", macAddr, pressAndHold)
+	return nil
+}
+
+// SetPressSpeed sets how fast the stem must be pressed repeatedly.
+// This requires an active AAP connection to the device.
+func (m *PodStateCoordinator) SetPressSpeed(macAddr string, speed aap.PressSpeed) error {
+	client, err := m.activeAAPConn(macAddr)
+	if err != nil {
+		return err
+	}
+
+	if err := aap.SetPressSpeed(client, speed); err != nil {
+		return fmt.Errorf("failed to set press speed: %w", err)
+	}
+
+	log.Printf("Press speed of %s set to: %s", macAddr, speed)
+	return nil
+}
+
+// SetHoldDuration sets how long the stem must be held to count as press and hold.
+// This requires an active AAP connection to the device.
+func (m *PodStateCoordinator) SetHoldDuration(macAddr string, duration aap.HoldDuration) error {
+	client, err := m.activeAAPConn(macAddr)
+	if err != nil {
+		return err
+	}
+
+	if err := aap.SetHoldDuration(client, duration); err != nil {
+		return fmt.Errorf("failed to set hold duration: %w", err)
+	}
+
+	log.Printf("Hold duration of %s set to: %s", macAddr, duration)
+	return nil
+}
+
+// SetVolumeSwipe enables or disables changing the volume by swiping the stem.
+// This requires an active AAP connection to the device.
+func (m *PodStateCoordinator) SetVolumeSwipe(macAddr string, enabled bool) error {
+	client, err := m.activeAAPConn(macAddr)
+	if err != nil {
+		return err
+	}
+
+	if err := aap.SetVolumeSwipe(client, enabled); err != nil {
+		return fmt.Errorf("failed to set volume swipe: %w", err)
+	}
+
+	log.Printf("Volume swipe of %s set to: %t", macAddr, enabled)
+	return nil
+}
+
+// SetNoiseMode switches the noise control mode of the device.
+// This requires an active AAP connection to the device.
+func (m *PodStateCoordinator) SetNoiseMode(macAddr string, mode aap.NoiseControlMode) error {
+	client, err := m.activeAAPConn(macAddr)
+	if err != nil {
+		return err
+	}
+
+	if err := aap.SetNoiseControlMode(client, mode); err != nil {
+		return fmt.Errorf("failed to set noise control mode: %w", err)
+	}
+
+	log.Printf("Noise control mode of %s set to: %s", macAddr, mode)
+	return nil
+}
+
+// GetNoiseMode returns the noise control mode last reported by the device
+func (m *PodStateCoordinator) GetNoiseMode(macAddr string) (aap.NoiseControlMode, bool) {
+	cmd, ok := m.GetControlSetting(macAddr, aap.ControlListeningMode)
+	if !ok {
+		return 0, false
+	}
+	mode, err := aap.ParseNoiseControlMode(&cmd)
+	if err != nil {
+		return 0, false
+	}
+	return mode, true
+}
+
+// GetNoiseControlCycle returns the press and hold cycle last reported by the device
+func (m *PodStateCoordinator) GetNoiseControlCycle(macAddr string) (aap.NoiseControlCycle, bool) {
+	cmd, ok := m.GetControlSetting(macAddr, aap.ControlListeningModeConfigs)
+	if !ok {
+		return 0, false
+	}
+	cycle, err := aap.ParseNoiseControlCycle(&cmd)
+	if err != nil {
+		return 0, false
+	}
+	return cycle, true
+}
+
+// GetDeviceSettings returns all settings the device reported since connecting
+func (m *PodStateCoordinator) GetDeviceSettings(macAddr string) (*aap.DeviceSettings, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.aapSessions[macAddr]
+	if !ok {
+		return nil, fmt.Errorf("no active AAP connection to %s - connect to AirPods first", macAddr)
+	}
+
+	settings := &aap.DeviceSettings{Raw: make(map[aap.ControlCommandID][4]byte, len(session.settings))}
+	for _, cmd := range session.settings {
+		settings.Apply(&cmd)
+	}
+	return settings, nil
+}
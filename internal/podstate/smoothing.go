@@ -0,0 +1,106 @@
+package podstate
+
+import (
+	"slices"
+	"time"
+
+	"linuxpods/internal/ble"
+)
+
+const (
+	// DefaultSmoothingWindow is the number of BLE battery readings the shown level is the median of
+	DefaultSmoothingWindow = 5
+
+	// smoothingResetAfter is how long a device may go without BLE updates before its battery
+	// history is discarded, e.g. while it was connected via AAP
+	smoothingResetAfter = time.Minute
+)
+
+// Battery components in batteryHistory.components
+const (
+	smoothLeft = iota
+	smoothRight
+	smoothCase
+	smoothSingle
+	smoothComponents
+)
+
+// batteryHistory holds the recent battery readings of a device from unencrypted advertisements
+type batteryHistory struct {
+	updated    time.Time
+	components [smoothComponents]componentHistory
+}
+
+// componentHistory holds the recent readings of one battery and the level last shown for it
+type componentHistory struct {
+	readings []int
+	charging bool
+	shown    *int
+}
+
+// add adds a reading and returns the level to show: the median of the last window readings,
+// which never increases while the battery is not charging. A nil level (battery unknown) or a
+// change of the charging state restarts the history.
+func (h *componentHistory) add(level *int, charging bool, window int) *int {
+	if level == nil {
+		*h = componentHistory{}
+		return nil
+	}
+	if charging != h.charging {
+		*h = componentHistory{charging: charging}
+	}
+
+	h.readings = append(h.readings, *level)
+	if len(h.readings) > window {
+		h.readings = h.readings[len(h.readings)-window:]
+	}
+	sorted := slices.Clone(h.readings)
+	slices.Sort(sorted)
+	median := sorted[(len(sorted)-1)/2] // Lower median for an even number of readings
+
+	if !charging && h.shown != nil && median > *h.shown {
+		median = *h.shown
+	}
+	h.shown = &median
+	return &median
+}
+
+// SetBatterySmoothing sets the number of BLE battery readings whose median is shown
+// (LINUXPODS_BATTERY_SMOOTHING or DefaultSmoothingWindow by default). 0 or 1 disables smoothing.
+func (m *PodStateCoordinator) SetBatterySmoothing(window int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.smoothingWindow = window
+}
+
+// smoothBattery smooths the battery levels of a state created from an unencrypted advertisement.
+// These levels only have 10% steps and flap between two neighboring values, which would make
+// the shown level jump back and forth. Decrypted levels are exact and not smoothed.
+// It is only called by the BLE loop.
+func (m *PodStateCoordinator) smoothBattery(macAddr string, state *PodState, data *ble.ProximityData, now time.Time) {
+	m.mu.RLock()
+	window := m.smoothingWindow
+	m.mu.RUnlock()
+
+	history, ok := m.batteryHistories[macAddr]
+	if window <= 1 || data.HasDecrypted {
+		if ok {
+			delete(m.batteryHistories, macAddr)
+		}
+		return
+	}
+	if !ok || now.Sub(history.updated) > smoothingResetAfter {
+		if len(m.batteryHistories) > maxTrackedBLEUpdates {
+			m.batteryHistories = make(map[string]*batteryHistory)
+		}
+		history = &batteryHistory{}
+		m.batteryHistories[macAddr] = history
+	}
+	history.updated = now
+
+	c := &history.components
+	state.LeftBattery = c[smoothLeft].add(state.LeftBattery, state.LeftCharging, window)
+	state.RightBattery = c[smoothRight].add(state.RightBattery, state.RightCharging, window)
+	state.CaseBattery = c[smoothCase].add(state.CaseBattery, state.CaseCharging, window)
+	state.Battery = c[smoothSingle].add(state.Battery, state.Charging, window)
+}
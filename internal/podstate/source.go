@@ -0,0 +1,20 @@
+package podstate
+
+import (
+	"linuxpods/internal/ble"
+)
+
+// AdvertisementSource delivers AirPods BLE advertisements to the coordinator.
+// It is implemented by ble.Scanner, and can be replaced (e.g. by a simulated device)
+// with NewPodStateCoordinatorWithSource.
+type AdvertisementSource interface {
+	// Subscribe returns a channel delivering every AirPods advertisement together with
+	// the (random) BLE address it was sent from. The channel is closed by Close.
+	Subscribe() <-chan ble.Advertisement
+
+	// Metrics returns the source's throughput counters
+	Metrics() ble.ScannerMetrics
+
+	// Close releases the source's resources
+	Close() error
+}
@@ -0,0 +1,87 @@
+package podstate
+
+import (
+	"log"
+	"time"
+)
+
+// Staleness configures what happens to device states that receive no new data
+type Staleness struct {
+	// StaleAfter is how long a state is shown as current without a new advertisement or
+	// AAP packet. Older states are marked Stale. States of devices with an active AAP
+	// session never become stale, the connection itself shows the device is present.
+	StaleAfter time.Duration
+
+	// RemoveAfter is how long a device only seen via BLE is kept after its last advertisement.
+	// AirPods rotate their random address periodically, so unidentified devices reappear under
+	// a new address and the old entry would otherwise stay forever.
+	RemoveAfter time.Duration
+}
+
+// DefaultStaleness marks states stale after 30 seconds and removes BLE-only devices after 2 minutes
+var DefaultStaleness = Staleness{
+	StaleAfter:  30 * time.Second,
+	RemoveAfter: 2 * time.Minute,
+}
+
+// stalenessCheckInterval is how often states are checked for staleness
+const stalenessCheckInterval = 5 * time.Second
+
+// SetStaleness configures when states are marked stale and removed (DefaultStaleness by default)
+func (m *PodStateCoordinator) SetStaleness(config Staleness) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.staleness = config
+}
+
+// checkStaleness marks the states that received no data for StaleAfter as stale, removes
+// the devices only seen via BLE that haven't advertised for RemoveAfter, and notifies
+// subscribers if anything changed. Own devices whose signal faded before they became stale
+// were probably left behind (see leftBehindLocked).
+func (m *PodStateCoordinator) checkStaleness(now time.Time) {
+	m.mu.Lock()
+	var expired, leftBehind []string
+	changed := false
+	for macAddr, state := range m.deviceStates {
+		if _, connected := m.aapSessions[macAddr]; connected {
+			continue
+		}
+
+		age := now.Sub(state.LastSeen)
+		switch {
+		case state.Source == DataSourceBLE && age > m.staleness.RemoveAfter:
+			delete(m.deviceStates, macAddr)
+			delete(m.signalTrends, macAddr)
+			expired = append(expired, macAddr)
+			changed = true
+		case age > m.staleness.StaleAfter && !state.Stale:
+			if m.leftBehindLocked(macAddr, state, now) {
+				leftBehind = append(leftBehind, macAddr)
+			}
+			// Replace the state instead of modifying it, subscribers may still read the old one
+			stale := *state
+			stale.Stale = true
+			m.deviceStates[macAddr] = &stale
+			changed = true
+		}
+	}
+	if !changed {
+		m.mu.Unlock()
+		return
+	}
+
+	snapshot := m.snapshotLocked()
+	m.mu.Unlock()
+
+	for _, macAddr := range expired {
+		delete(m.lastBLEUpdate, macAddr)
+		delete(m.lidStates, macAddr)
+		delete(m.batteryHistories, macAddr)
+		log.Printf("BLE: Device %s is no longer advertising, removed", macAddr)
+	}
+
+	m.publishStates(snapshot)
+	for _, macAddr := range leftBehind {
+		m.publishLeftBehind(macAddr)
+	}
+}
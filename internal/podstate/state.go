@@ -1,5 +1,12 @@
 package podstate
 
+import (
+	"time"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/ble"
+)
+
 // DataSource indicates where the state data originated from
 type DataSource int
 
@@ -7,6 +14,8 @@ const (
 	DataSourceUnknown DataSource = iota
 	DataSourceBLE                // BLE advertisements (approximate, 5-10% accuracy)
 	DataSourceAAP                // AAP protocol (accurate, 1% accuracy)
+	DataSourceAudio              // Sound server (PipeWire or PulseAudio), only for the audio fields
+	DataSourceStored             // Last known state, saved before the previous exit (see SetLastStateFile)
 )
 
 func (d DataSource) String() string {
@@ -15,6 +24,10 @@ func (d DataSource) String() string {
 		return "BLE"
 	case DataSourceAAP:
 		return "AAP"
+	case DataSourceAudio:
+		return "Audio"
+	case DataSourceStored:
+		return "Stored"
 	default:
 		return "Unknown"
 	}
@@ -43,36 +56,82 @@ func (p PodSide) String() string {
 // PodState represents the complete state of AirPods, independent of data source.
 // This is the unified state object that the PodStateCoordinator provides to all consumers.
 type PodState struct {
-	// Data source indicator
+	// Source of the battery levels, the main information of the state
 	Source DataSource
 
+	// Where each group of fields came from and when. Updates of different sources are merged,
+	// e.g. the battery from AAP with the lid state from BLE (empty for states read via D-Bus).
+	Sources map[Field]FieldSource
+
 	// Battery levels (0-100), nil if unknown
 	LeftBattery  *int
 	RightBattery *int
 	CaseBattery  *int
 
+	// Battery of single-battery devices such as AirPods Max (0-100), nil for earbuds.
+	// Left, right and case fields are unset for these devices.
+	Battery  *int
+	Charging bool
+
+	// The BLE battery levels were decrypted with the ENC_KEY and are accurate to 1%, like
+	// the AAP levels. Undecrypted BLE levels are rounded to 10%.
+	Decrypted bool
+
 	// Charging status
 	LeftCharging  bool
 	RightCharging bool
 	CaseCharging  bool
 
-	// In-ear detection
-	LeftInEar  bool
-	RightInEar bool
+	// In-ear detection, and whether the pods are in the case
+	LeftInEar   bool
+	RightInEar  bool
+	LeftInCase  bool
+	RightInCase bool
+
+	// Case state. LidOpenCount counts the lid openings and wraps around after 7 (from BLE
+	// only), a change shows the case was opened even if no advertisement saw it open.
+	LidOpen      bool
+	LidOpenCount uint8
+
+	// Bluetooth audio profile and whether an application records from the microphone,
+	// reported by the sound server while the device is connected
+	AudioProfile    AudioProfile
+	MicrophoneInUse bool
 
-	// Case state
-	LidOpen bool
+	// Signal strength of the last BLE advertisement in dBm (0 if unknown) and the distance
+	// estimated from it
+	RSSI      int16
+	Proximity ble.Proximity
+
+	// Status byte and connection state byte of the last BLE advertisement (see
+	// ble.DecodeConnectionState). The connection state shows whether the AirPods are
+	// connected to some host and what they are doing, e.g. playing music or in a call.
+	Status          uint8
+	ConnectionState uint8
 
 	// Device information
 	DeviceModel uint16
-	ModelName   string  // Human-readable model name (from BLE only, empty for AAP)
+	ModelName   string  // Human-readable model name (from BLE only)
+	Alias       string  // Name given by the user in the config file, empty if none
 	Color       uint8   // AirPods color code
 	PrimaryPod  PodSide // Which pod is the primary (determines left/right orientation)
 
+	// Features supported by the device model (all features if the model is unknown)
+	Capabilities aap.Capabilities
+
+	// Firmware version, serial numbers and model number reported once per AAP connection,
+	// nil until the device was connected
+	DeviceInfo *aap.DeviceInfo
+
 	// MAC addresses
 	RealMac       string // Real (permanent) MAC address from AAP connection
 	CurrentBLEMac string // Current randomized BLE MAC address (changes periodically for privacy)
 
+	// IsOwnDevice is set for devices connected via AAP or identified by their IRK or ENC_KEY,
+	// i.e. AirPods paired to this machine (or imported in monitor mode). Other devices are only
+	// shown until the keys of the own AirPods are known (see SetShowForeignDevices).
+	IsOwnDevice bool
+
 	// Encryption key for decrypting BLE advertisements (ENC_KEY from proximity pairing)
 	// This is the 16-byte key retrieved via AAP that allows decrypting encrypted portions
 	// of BLE proximity pairing advertisements for accurate battery levels
@@ -80,4 +139,33 @@ type PodState struct {
 
 	// Raw data from source (for debugging/future use)
 	RawData []byte
+
+	// When the data was received. States without new data are marked Stale, and devices
+	// only seen via BLE are removed when they stop advertising (see Staleness).
+	LastSeen time.Time
+	Stale    bool
+}
+
+// DisplayName returns the name to show for the device: its alias, or its model name
+func (s *PodState) DisplayName() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.ModelName
+}
+
+// RedactKeys returns copies of the states whose encryption keys are replaced with zeros, so
+// that the keys don't leave the coordinator, e.g. over the bus or in a bug report. Readers
+// only need to know whether a key exists.
+func RedactKeys(states map[string]*PodState) map[string]*PodState {
+	redacted := make(map[string]*PodState, len(states))
+	for macAddr, state := range states {
+		if state.EncryptionKey != nil {
+			copied := *state
+			copied.EncryptionKey = make([]byte, len(state.EncryptionKey))
+			state = &copied
+		}
+		redacted[macAddr] = state
+	}
+	return redacted
 }
@@ -0,0 +1,62 @@
+package podstate
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// Subscribers is a list of handlers that can be removed again. A panicking handler is
+// logged and doesn't affect the other handlers or the caller.
+type Subscribers[T any] struct {
+	mu      sync.Mutex
+	nextID  uint64
+	entries []subscriber[T]
+}
+
+type subscriber[T any] struct {
+	id      uint64
+	handler T
+}
+
+// Add adds a handler and returns the function that removes it. Removing it twice is harmless.
+func (s *Subscribers[T]) Add(handler T) (remove func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	id := s.nextID
+	s.entries = append(s.entries, subscriber[T]{id: id, handler: handler})
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, entry := range s.entries {
+			if entry.id == id {
+				s.entries = append(s.entries[:i:i], s.entries[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Each calls call with every handler, recovering from panics. Handlers added or removed
+// meanwhile are not affected.
+func (s *Subscribers[T]) Each(call func(handler T)) {
+	s.mu.Lock()
+	entries := s.entries
+	s.mu.Unlock()
+
+	for _, entry := range entries {
+		CallSafely(func() { call(entry.handler) })
+	}
+}
+
+// CallSafely calls f and logs a panic instead of crashing, for calling handlers of other components
+func CallSafely(f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Error: handler panicked: %v\n%s", r, debug.Stack())
+		}
+	}()
+	f()
+}
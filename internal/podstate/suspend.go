@@ -0,0 +1,102 @@
+package podstate
+
+import (
+	"log"
+	"time"
+
+	"linuxpods/internal/ble"
+)
+
+const (
+	// suspendDelay is how long the pods must stay in the closed case before the AAP
+	// connection is closed, so that briefly docking them doesn't drop it
+	suspendDelay = 10 * time.Second
+
+	// suspendedUpdateInterval is the minimum time between BLE state updates of a suspended
+	// device. Only the lid matters while it is in the closed case, which is checked on
+	// every advertisement.
+	suspendedUpdateInterval = 30 * time.Second
+)
+
+// SetSuspendInCase selects whether the AAP connection of a device is closed while its pods
+// are in the closed case, and opened again when the lid opens (enabled by default). The
+// connection keeps the radios of the AirPods busy although nothing can be played. While
+// suspended, the scan follows ScanPolicy.Suspended.
+func (m *PodStateCoordinator) SetSuspendInCase(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.suspendInCase = enabled
+}
+
+// IsAAPSuspended reports whether the AAP connection of a device is closed because its pods
+// are in the closed case
+func (m *PodStateCoordinator) IsAAPSuspended(macAddr string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, suspended := m.suspended[macAddr]
+	return suspended
+}
+
+// checkSuspend closes the AAP connection of a device once its pods stayed in the closed case
+// for suspendDelay, and opens it again when an advertisement shows the lid open or a pod in
+// an ear. It is only called by the BLE loop.
+func (m *PodStateCoordinator) checkSuspend(macAddr string, data *ble.ProximityData, received time.Time) {
+	docked := data.Docked()
+
+	m.mu.Lock()
+	if _, suspended := m.suspended[macAddr]; suspended {
+		if !data.LidOpen && !data.LeftInEar && !data.RightInEar {
+			m.mu.Unlock()
+			return
+		}
+		delete(m.suspended, macAddr)
+		m.mu.Unlock()
+		m.updateScanCadence()
+
+		log.Printf("AAP: Case of %s opened, resuming the connection", macAddr)
+		m.spawn(func() {
+			if err := m.ConnectAAP(macAddr); err != nil {
+				log.Printf("Warning: Failed to resume AAP session for %s: %v", macAddr, err)
+				m.ReconnectAAP(macAddr)
+			}
+		})
+		return
+	}
+
+	session, connected := m.aapSessions[macAddr]
+	if !docked || !connected || !m.suspendInCase {
+		delete(m.dockedSince, macAddr)
+		m.mu.Unlock()
+		return
+	}
+	since, ok := m.dockedSince[macAddr]
+	if !ok {
+		m.dockedSince[macAddr] = received
+		m.mu.Unlock()
+		return
+	}
+	if received.Sub(since) < suspendDelay {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.dockedSince, macAddr)
+	delete(m.aapSessions, macAddr)
+	m.suspended[macAddr] = struct{}{}
+	m.mu.Unlock()
+
+	// The read loop ends without reconnecting, the session is no longer registered
+	_ = session.conn.Close()
+	log.Printf("AAP: %s is in the closed case, suspending the connection", macAddr)
+	m.events.Publish(DeviceSuspended{Address: macAddr})
+	m.updateScanCadence()
+}
+
+// updateIntervalFor returns the minimum time between two BLE state updates of a device,
+// longer while its AAP connection is suspended
+func (m *PodStateCoordinator) updateIntervalFor(macAddr string) time.Duration {
+	interval := m.bleUpdateInterval()
+	if m.IsAAPSuspended(macAddr) && !m.IsFastScanActive() {
+		interval = max(interval, suspendedUpdateInterval)
+	}
+	return interval
+}
@@ -0,0 +1,75 @@
+package podstate
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/ble"
+)
+
+func TestSuspendInCase(t *testing.T) {
+	m, _ := newTestCoordinator(t, WithAAPDialer(func(macAddr string) (aap.Conn, error) {
+		return aap.NewFakeConn(), nil
+	}))
+
+	var mu sync.Mutex
+	var events []Event
+	m.Subscribe(func(event Event) {
+		switch event.(type) {
+		case DeviceSuspended, DeviceDisconnected, DeviceConnected:
+			mu.Lock()
+			events = append(events, event)
+			mu.Unlock()
+		}
+	})
+
+	if err := m.ConnectAAP(testMac); err != nil {
+		t.Fatalf("ConnectAAP: %v", err)
+	}
+	if cadence := m.chooseCadence(DefaultScanPolicy, false); cadence != ble.ContinuousCadence {
+		t.Errorf("Cadence while connected = %s, want continuous", cadence)
+	}
+
+	docked := &ble.ProximityData{Format: ble.PayloadEncrypted, InCase: true}
+	start := time.Now()
+	m.checkSuspend(testMac, docked, start)
+	m.checkSuspend(testMac, docked, start.Add(suspendDelay))
+	if !m.IsAAPSuspended(testMac) || m.IsAAPConnected(testMac) {
+		t.Fatal("AAP connection not suspended in the closed case")
+	}
+	if cadence := m.chooseCadence(DefaultScanPolicy, false); cadence != DefaultScanPolicy.Suspended {
+		t.Errorf("Cadence while suspended = %s, want %s", cadence, DefaultScanPolicy.Suspended)
+	}
+
+	opened := &ble.ProximityData{Format: ble.PayloadEncrypted, InCase: true, LidOpen: true}
+	m.checkSuspend(testMac, opened, start.Add(suspendDelay+time.Second))
+	received := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for received() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !m.IsAAPConnected(testMac) || m.IsAAPSuspended(testMac) {
+		t.Fatal("AAP connection not resumed after the lid opened")
+	}
+	if cadence := m.chooseCadence(DefaultScanPolicy, false); cadence != ble.ContinuousCadence {
+		t.Errorf("Cadence after resuming = %s, want continuous", cadence)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []Event{DeviceConnected{Address: testMac}, DeviceSuspended{Address: testMac}, DeviceConnected{Address: testMac}}
+	if len(events) != len(want) {
+		t.Fatalf("Events = %+v, want %+v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("Event %d = %#v, want %#v", i, events[i], want[i])
+		}
+	}
+}
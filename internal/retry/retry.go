@@ -0,0 +1,94 @@
+// Package retry provides jittered exponential backoff shared across subsystems
+// (AAP reconnects, BlueZ provider registration, ...), so each of them doesn't
+// need its own retry loop.
+//
+// Usage:
+//
+//	err := retry.Do(ctx, retry.DefaultBackoff, func(ctx context.Context) error {
+//		return connect()
+//	})
+//
+// Return retry.Permanent(err) from the operation to stop retrying early.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff configures the delays between attempts
+type Backoff struct {
+	Initial     time.Duration // Delay before the second attempt
+	Max         time.Duration // Upper bound for a single delay
+	Multiplier  float64       // Growth factor of the delay per attempt
+	Jitter      float64       // Random fraction (0-1) the delay is varied by, to avoid synchronized retries
+	MaxAttempts int           // Number of attempts in total, 0 for unlimited (until the context is done)
+}
+
+// DefaultBackoff retries for roughly a minute: 1s, 2s, 4s, 8s, 16s, 30s (±20%)
+var DefaultBackoff = Backoff{
+	Initial:     time.Second,
+	Max:         30 * time.Second,
+	Multiplier:  2,
+	Jitter:      0.2,
+	MaxAttempts: 7,
+}
+
+// Delay returns the delay after the given failed attempt (starting at 1), including jitter
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt-1))
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		delay += delay * b.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// permanentError marks an error that should not be retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps an error to stop retrying immediately
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Do calls op until it succeeds, returns a permanent error, the attempts are exhausted or
+// the context is done. It returns nil on success and the last error otherwise.
+func Do(ctx context.Context, b Backoff, op func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := op(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if b.MaxAttempts > 0 && attempt >= b.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		timer := time.NewTimer(b.Delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
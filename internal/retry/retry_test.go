@@ -0,0 +1,100 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelayGrowth(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Multiplier: 2}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond}
+	for i, w := range want {
+		if got := b.Delay(i + 1); got != w {
+			t.Errorf("Delay(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+func TestBackoffDelayBounds(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 4 * time.Second, Multiplier: 2, Jitter: 0.25}
+	for attempt := 1; attempt <= 10; attempt++ {
+		base := time.Duration(1<<(attempt-1)) * time.Second
+		if base > b.Max {
+			base = b.Max
+		}
+		low, high := base*3/4, base*5/4
+		for i := 0; i < 100; i++ {
+			if got := b.Delay(attempt); got < low || got > high {
+				t.Fatalf("Delay(%d) = %s, want %s to %s", attempt, got, low, high)
+			}
+		}
+	}
+}
+
+func TestDoMaxAttempts(t *testing.T) {
+	errFailed := errors.New("failed")
+	calls := 0
+	err := Do(context.Background(), Backoff{Initial: time.Millisecond, MaxAttempts: 3}, func(ctx context.Context) error {
+		calls++
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Errorf("Do() = %v, want the last error", err)
+	}
+	if calls != 3 {
+		t.Errorf("op called %d times, want 3", calls)
+	}
+}
+
+func TestDoSucceeds(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), Backoff{Initial: time.Millisecond, MaxAttempts: 5}, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Do() = %v, want nil", err)
+	}
+	if calls != 3 {
+		t.Errorf("op called %d times, want 3", calls)
+	}
+}
+
+func TestDoPermanent(t *testing.T) {
+	errFatal := errors.New("fatal")
+	calls := 0
+	err := Do(context.Background(), Backoff{Initial: time.Millisecond}, func(ctx context.Context) error {
+		calls++
+		return Permanent(errFatal)
+	})
+	if err != errFatal {
+		t.Errorf("Do() = %v, want the unwrapped permanent error", err)
+	}
+	if calls != 1 {
+		t.Errorf("op called %d times, want 1", calls)
+	}
+	if Permanent(nil) != nil {
+		t.Error("Permanent(nil) != nil")
+	}
+}
+
+func TestDoContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := Do(ctx, Backoff{Initial: time.Hour}, func(ctx context.Context) error {
+		calls++
+		cancel()
+		return errors.New("failed")
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() = %v, want context.Canceled", err)
+	}
+	if calls != 1 {
+		t.Errorf("op called %d times, want 1", calls)
+	}
+}
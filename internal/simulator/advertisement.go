@@ -0,0 +1,229 @@
+package simulator
+
+import (
+	"crypto/aes"
+	"fmt"
+	"sync"
+	"time"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/ble"
+)
+
+// proximityPayloadLength is the length of the proximity pairing payload (prefix to encrypted portion)
+const proximityPayloadLength = 25
+
+// Advertisement builds the Apple manufacturer data of a proximity pairing advertisement
+// with the current battery state and placement. Like real AirPods, the left and right values
+// are swapped when the right pod is primary. Bytes 9-24 of the payload hold the battery
+// levels with 1% precision, encrypted with the ENC_KEY.
+func (d *Device) Advertisement() ([]byte, error) {
+	battery := d.Battery()
+	d.mu.Lock()
+	placement, lidOpenCount := d.placement, d.lidOpenCount
+	d.mu.Unlock()
+
+	// Values are broadcast in primary/secondary order
+	primaryLeft := d.Primary() != aap.ComponentRight
+	first, second := battery.Left, battery.Right
+	firstCharging, secondCharging := battery.LeftCharging, battery.RightCharging
+	firstPlace, secondPlace := placement.Left, placement.Right
+	if !primaryLeft {
+		first, second = second, first
+		firstCharging, secondCharging = secondCharging, firstCharging
+		firstPlace, secondPlace = secondPlace, firstPlace
+	}
+	status := statusByte(primaryLeft, firstPlace, secondPlace)
+
+	payload := make([]byte, proximityPayloadLength)
+	payload[0] = 0x01 // Prefix
+	payload[1] = byte(d.Model >> 8)
+	payload[2] = byte(d.Model)
+	payload[3] = status
+	payload[4] = batteryNibble(first)<<4 | batteryNibble(second)
+	payload[5] = batteryNibble(battery.Case)
+	if battery.CaseCharging {
+		payload[5] |= 0x40
+	}
+	if secondCharging {
+		payload[5] |= 0x20
+	}
+	if firstCharging {
+		payload[5] |= 0x10
+	}
+	payload[6] = lidOpenCount & ble.LidOpenCountMask
+	payload[7] = 0x00 // Color: white
+	if !placement.LidOpen {
+		payload[8] = 0x08 // Lid closed (bit 3)
+	}
+
+	// Encrypted portion, see ble.ProximityData.AddDecryptedData
+	plain := make([]byte, 16)
+	plain[1] = batteryByte(first, firstCharging)
+	plain[2] = batteryByte(second, secondCharging)
+	plain[3] = batteryByte(battery.Case, battery.CaseCharging)
+	plain[4] = 0x2D // Validation marker
+
+	block, err := aes.NewCipher(d.EncKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+	block.Encrypt(payload[9:25], plain)
+
+	return append([]byte{0x07, proximityPayloadLength}, payload...), nil
+}
+
+// statusByte encodes the placement of the primary and the secondary pod as parsed by
+// ble.ParseProximityData: bit 5 is set if the left pod is primary, bit 6 if the primary pod
+// is in the case, bit 2 if both pods are in the case and bit 4 if only one is. The in ear
+// bits 3 and 1 are swapped when exactly one of bits 5 and 6 is set.
+func statusByte(primaryLeft bool, primary, secondary aap.EarStatus) byte {
+	var status byte
+	if primaryLeft {
+		status |= 0x20
+	}
+	primaryInCase := primary == aap.EarStatusInCase
+	if primaryInCase {
+		status |= 0x40
+	}
+	switch {
+	case primaryInCase && secondary == aap.EarStatusInCase:
+		status |= 0x04
+	case primaryInCase || secondary == aap.EarStatusInCase:
+		status |= 0x10
+	}
+
+	leftInEar, rightInEar := primary == aap.EarStatusInEar, secondary == aap.EarStatusInEar
+	if !primaryLeft {
+		leftInEar, rightInEar = rightInEar, leftInEar
+	}
+	if primaryLeft != primaryInCase {
+		leftInEar, rightInEar = rightInEar, leftInEar
+	}
+	if leftInEar {
+		status |= 0x08
+	}
+	if rightInEar {
+		status |= 0x02
+	}
+	return status
+}
+
+// batteryNibble encodes a battery level as broadcast in the unencrypted portion (10% steps)
+func batteryNibble(level uint8) uint8 {
+	return min(level/10, 0x0A)
+}
+
+// batteryByte encodes a battery level as stored in the encrypted portion
+func batteryByte(level uint8, charging bool) uint8 {
+	b := level & 0x7F
+	if charging {
+		b |= 0x80
+	}
+	return b
+}
+
+// AdvertisementSource broadcasts the advertisements of a simulated device.
+// It implements podstate.AdvertisementSource.
+type AdvertisementSource struct {
+	device   *Device
+	interval time.Duration
+
+	mu      sync.Mutex
+	bleMac  string
+	metrics ble.ScannerMetrics
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// NewAdvertisementSource creates a source that broadcasts one advertisement of the device
+// per interval from the given random BLE address
+func (d *Device) NewAdvertisementSource(bleMac string, interval time.Duration) *AdvertisementSource {
+	return &AdvertisementSource{
+		device:   d,
+		interval: interval,
+		bleMac:   bleMac,
+		metrics:  ble.ScannerMetrics{Since: time.Now()},
+		closed:   make(chan struct{}),
+	}
+}
+
+// ResolvableAddress generates a new random BLE address of the device that resolves with its IRK
+func (d *Device) ResolvableAddress() (string, error) {
+	return ble.GenerateResolvablePrivateAddress(d.IRK)
+}
+
+// SetBLEMac changes the random BLE address, like AirPods do periodically for privacy
+func (s *AdvertisementSource) SetBLEMac(bleMac string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bleMac = bleMac
+}
+
+// Subscribe returns a channel receiving one advertisement of the simulated device per
+// interval, until the source is closed
+func (s *AdvertisementSource) Subscribe() <-chan ble.Advertisement {
+	advertisements := make(chan ble.Advertisement)
+	go func() {
+		defer close(advertisements)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-s.closed:
+				return
+			}
+
+			ad, err := s.advertise()
+			if err != nil {
+				continue
+			}
+			select {
+			case advertisements <- ad:
+			case <-s.closed:
+				return
+			}
+		}
+	}()
+	return advertisements
+}
+
+// advertise builds and parses the current advertisement of the simulated device
+func (s *AdvertisementSource) advertise() (ble.Advertisement, error) {
+	manufacturerData, err := s.device.Advertisement()
+	if err != nil {
+		return ble.Advertisement{}, err
+	}
+	data, err := ble.ParseProximityData(manufacturerData)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics.Advertisements++
+	if err != nil {
+		s.metrics.ParseFailures++
+		return ble.Advertisement{}, err
+	}
+	s.metrics.ParseSuccesses++
+	data.LastSeen = time.Now()
+	return ble.Advertisement{Data: data, Address: s.bleMac, Received: data.LastSeen}, nil
+}
+
+// Metrics returns the number of broadcast advertisements
+func (s *AdvertisementSource) Metrics() ble.ScannerMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metrics := s.metrics
+	if s.interval > 0 {
+		metrics.AdvertisementsPerSecond = float64(time.Second) / float64(s.interval)
+	}
+	return metrics
+}
+
+// Close stops broadcasting and closes the subscriber channels
+func (s *AdvertisementSource) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
@@ -0,0 +1,257 @@
+// Package simulator provides a simulated AirPods device for running LinuxPods without hardware.
+//
+// A Device answers AAP requests over an in-memory aap.FakeConn (battery status, device
+// information, ear status, settings and proximity keys) and broadcasts proximity pairing advertisements whose encrypted
+// portion is encrypted with the device's ENC_KEY, just like real AirPods. It is used
+// with podstate.NewPodStateCoordinatorWithSource and podstate.WithAAPDialer
+// to exercise the complete coordinator pipeline, e.g. by cmd/integration_test.
+package simulator
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"linuxpods/internal/aap"
+)
+
+// Device is a simulated pair of AirPods.
+// All methods are safe for concurrent use.
+type Device struct {
+	Address string // Real (public) MAC address, used for AAP
+	Model   uint16 // Device model as broadcast in advertisements
+	EncKey  []byte // ENC_KEY for encrypting advertisements (16 bytes)
+	IRK     []byte // IRK reported together with the ENC_KEY (16 bytes)
+
+	mu            sync.Mutex
+	battery       Battery
+	primary       aap.BatteryComponent // Primary pod (left or right)
+	listeningMode aap.NoiseControlMode
+	placement     Placement
+	lidOpenCount  uint8 // Incremented each time the lid is opened, see ble.LidOpenCountMask
+	conns         []*aap.FakeConn
+}
+
+// Battery is the simulated battery state
+type Battery struct {
+	Left, Right, Case                         uint8 // Levels in percent
+	LeftCharging, RightCharging, CaseCharging bool
+}
+
+// Placement is where the simulated pods are and whether the lid of the case is open
+type Placement struct {
+	Left, Right aap.EarStatus // In ear, out of ear or in the case
+	LidOpen     bool
+}
+
+// NewDevice creates a simulated device with the given real MAC address and keys
+func NewDevice(address string, model uint16, encKey, irk []byte) (*Device, error) {
+	if len(encKey) != 16 {
+		return nil, fmt.Errorf("encryption key must be 16 bytes, got %d", len(encKey))
+	}
+	if len(irk) != 16 {
+		return nil, fmt.Errorf("IRK must be 16 bytes, got %d", len(irk))
+	}
+
+	return &Device{
+		Address:       strings.ToUpper(address),
+		Model:         model,
+		EncKey:        append([]byte(nil), encKey...),
+		IRK:           append([]byte(nil), irk...),
+		battery:       Battery{Left: 87, Right: 93, Case: 54, CaseCharging: true},
+		primary:       aap.ComponentLeft,
+		listeningMode: aap.NoiseControlANC,
+		placement:     Placement{Left: aap.EarStatusInCase, Right: aap.EarStatusInCase, LidOpen: true},
+	}, nil
+}
+
+// SetBattery updates the simulated battery state and notifies connected AAP clients
+func (d *Device) SetBattery(battery Battery) {
+	d.mu.Lock()
+	d.battery = battery
+	conns := append([]*aap.FakeConn(nil), d.conns...)
+	d.mu.Unlock()
+
+	packet := d.batteryPacket()
+	for _, conn := range conns {
+		conn.Push(packet)
+	}
+}
+
+// SetPrimary switches the primary pod (aap.ComponentLeft or aap.ComponentRight) and
+// resends the battery status to connected AAP clients, like the AirPods do after a role switch
+func (d *Device) SetPrimary(primary aap.BatteryComponent) {
+	d.mu.Lock()
+	d.primary = primary
+	conns := append([]*aap.FakeConn(nil), d.conns...)
+	d.mu.Unlock()
+
+	packet := d.batteryPacket()
+	for _, conn := range conns {
+		conn.Push(packet)
+	}
+}
+
+// SetPlacement moves the pods and opens or closes the lid. Connected AAP clients are notified
+// of the new ear status, the advertisements report it from the next one on.
+func (d *Device) SetPlacement(placement Placement) {
+	d.mu.Lock()
+	if placement.LidOpen && !d.placement.LidOpen {
+		d.lidOpenCount++
+	}
+	d.placement = placement
+	conns := append([]*aap.FakeConn(nil), d.conns...)
+	d.mu.Unlock()
+
+	packet := d.earStatusPacket()
+	for _, conn := range conns {
+		conn.Push(packet)
+	}
+}
+
+// Placement returns where the pods are and whether the lid is open
+func (d *Device) Placement() Placement {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.placement
+}
+
+// Primary returns the primary pod
+func (d *Device) Primary() aap.BatteryComponent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.primary
+}
+
+// Battery returns the simulated battery state
+func (d *Device) Battery() Battery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.battery
+}
+
+// Dial creates an AAP connection to the simulated device. It matches podstate.AAPDialer.
+func (d *Device) Dial(macAddr string) (aap.Conn, error) {
+	if !strings.EqualFold(macAddr, d.Address) {
+		return nil, fmt.Errorf("no simulated device with address %s", macAddr)
+	}
+
+	conn := aap.NewFakeConn()
+	conn.Responder = d.respond
+
+	d.mu.Lock()
+	d.conns = append(d.conns, conn)
+	d.mu.Unlock()
+
+	return conn, nil
+}
+
+// respond returns the packets the device replies with to a request
+func (d *Device) respond(packet []byte) [][]byte {
+	opcode, ok := aap.PacketOpcode(packet)
+	if !ok {
+		return nil
+	}
+
+	switch opcode {
+	case opcodeBatteryRequest:
+		// The battery request also makes the device report all settings
+		d.mu.Lock()
+		mode := d.listeningMode
+		d.mu.Unlock()
+		return [][]byte{
+			d.deviceInfoPacket(),
+			d.batteryPacket(),
+			d.earStatusPacket(),
+			aap.BuildControlCommand(aap.ControlListeningMode, uint8(mode)),
+		}
+	case opcodeKeyRequest:
+		return [][]byte{d.keyPacket()}
+	}
+
+	// Settings are confirmed by echoing the new value
+	if cmd, err := aap.ParseControlCommand(packet); err == nil {
+		if cmd.ID == aap.ControlListeningMode {
+			d.mu.Lock()
+			d.listeningMode = aap.NoiseControlMode(cmd.Value[0])
+			d.mu.Unlock()
+		}
+		return [][]byte{append([]byte(nil), packet...)}
+	}
+	return nil
+}
+
+// Opcodes of the requests the simulated device answers, see internal/aap/client.go
+const (
+	opcodeBatteryRequest = 0x0F
+	opcodeKeyRequest     = 0x30
+)
+
+// batteryPacket builds an AAP battery notification with the current battery state.
+// The primary pod is reported first.
+// Format: 04 00 04 00 04 00 [count] ([component] 01 [level] [status] 01)...
+func (d *Device) batteryPacket() []byte {
+	battery := d.Battery()
+
+	type component struct {
+		component aap.BatteryComponent
+		level     uint8
+		charging  bool
+	}
+	left := component{aap.ComponentLeft, battery.Left, battery.LeftCharging}
+	right := component{aap.ComponentRight, battery.Right, battery.RightCharging}
+	components := []component{left, right}
+	if d.Primary() == aap.ComponentRight {
+		components = []component{right, left}
+	}
+	components = append(components, component{aap.ComponentCase, battery.Case, battery.CaseCharging})
+
+	packet := []byte{0x04, 0x00, 0x04, 0x00, 0x04, 0x00, byte(len(components))}
+	for _, c := range components {
+		status := aap.StatusDischarging
+		if c.charging {
+			status = aap.StatusCharging
+		}
+		packet = append(packet, byte(c.component), 0x01, c.level, byte(status), 0x01)
+	}
+	return packet
+}
+
+// earStatusPacket builds an AAP ear status notification with the current placement.
+// The primary pod is reported first.
+// Format: 04 00 04 00 06 00 [primary] [secondary]
+func (d *Device) earStatusPacket() []byte {
+	placement := d.Placement()
+	primary, secondary := placement.Left, placement.Right
+	if d.Primary() == aap.ComponentRight {
+		primary, secondary = secondary, primary
+	}
+	return []byte{0x04, 0x00, 0x04, 0x00, 0x06, 0x00, byte(primary), byte(secondary)}
+}
+
+// FirmwareVersion is the firmware version the simulated device reports
+const FirmwareVersion = "7A304"
+
+// deviceInfoPacket builds an AAP device information packet with made-up serial numbers
+// Format: 04 00 04 00 1D 00 [header bytes] [name] 00 [model number] 00 [manufacturer] 00 ...
+func (d *Device) deviceInfoPacket() []byte {
+	packet := []byte{0x04, 0x00, 0x04, 0x00, 0x1D, 0x00, 0x02, 0xED, 0x00, 0x04, 0x00}
+	for _, field := range []string{
+		"Simulated AirPods", "A2968", "Apple Inc.", "SIMCASE0001", FirmwareVersion, FirmwareVersion,
+		"1.0.0", "com.apple.accessoryupdater.uarp", "SIMLEFT0001", "SIMRIGHT0001",
+	} {
+		packet = append(packet, field...)
+		packet = append(packet, 0x00)
+	}
+	return packet
+}
+
+// keyPacket builds an AAP proximity key response containing the IRK and ENC_KEY
+func (d *Device) keyPacket() []byte {
+	packet := []byte{0x04, 0x00, 0x04, 0x00, 0x31, 0x00, 0x02}
+	packet = append(packet, byte(aap.KeyTypeIRK), 0x00, byte(len(d.IRK)), 0x00)
+	packet = append(packet, d.IRK...)
+	packet = append(packet, byte(aap.KeyTypeENCKEY), 0x00, byte(len(d.EncKey)), 0x00)
+	packet = append(packet, d.EncKey...)
+	return packet
+}
@@ -0,0 +1,302 @@
+// Package stateexport streams the device states as JSON lines over a Unix socket, for status
+// bars and scripts that don't use D-Bus:
+//
+//	socat - UNIX-CONNECT:$XDG_RUNTIME_DIR/linuxpods/state.sock
+//
+// Every client first gets the current states, then one line after every change. A line holds
+// all devices, so clients only need the last line they read:
+//
+//	{"time":"2026-01-02T15:04:05+01:00","devices":[{"address":"AA:BB:CC:DD:EE:FF","left_battery":80,...}]}
+//
+// Unknown battery levels are null. The socket is read-only, anything sent to it is ignored.
+package stateexport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/ble"
+	"linuxpods/internal/podstate"
+	"linuxpods/internal/util"
+)
+
+const (
+	// clientQueue is the number of lines buffered for a client. Clients that fall further
+	// behind are disconnected.
+	clientQueue = 16
+
+	// writeTimeout disconnects clients that stopped reading
+	writeTimeout = 5 * time.Second
+)
+
+// noiseModeNames are the names of the noise control modes, as in the D-Bus API
+var noiseModeNames = map[aap.NoiseControlMode]string{
+	aap.NoiseControlOff:          "off",
+	aap.NoiseControlANC:          "noise-cancellation",
+	aap.NoiseControlTransparency: "transparency",
+	aap.NoiseControlAdaptive:     "adaptive",
+}
+
+// connectionState returns the name of the BLE connection state as in the D-Bus API, empty if
+// it is unknown or wasn't received via BLE
+func connectionState(state *podstate.PodState) string {
+	if _, ok := state.Sources[podstate.FieldAdvertisement]; !ok {
+		return ""
+	}
+	return ble.ConnectionStateName(state.ConnectionState)
+}
+
+// DefaultSocketPath returns the socket path: $XDG_RUNTIME_DIR/linuxpods/state.sock
+func DefaultSocketPath() (string, error) {
+	dir, err := util.RuntimeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state.sock"), nil
+}
+
+// message is one line sent to the clients
+type message struct {
+	Time    time.Time     `json:"time"`
+	Devices []deviceState `json:"devices"`
+}
+
+// deviceState is the exported state of a device. The keys are left out.
+type deviceState struct {
+	Address         string `json:"address"`
+	Name            string `json:"name"`
+	Alias           string `json:"alias,omitempty"`
+	Source          string `json:"source"`
+	Connected       bool   `json:"connected"`
+	OwnDevice       bool   `json:"own_device"`
+	Stale           bool   `json:"stale"`
+	LastSeen        int64  `json:"last_seen"`
+	LeftBattery     *int   `json:"left_battery"`
+	RightBattery    *int   `json:"right_battery"`
+	CaseBattery     *int   `json:"case_battery"`
+	Battery         *int   `json:"battery,omitempty"` // Single-battery devices such as AirPods Max
+	LeftCharging    bool   `json:"left_charging"`
+	RightCharging   bool   `json:"right_charging"`
+	CaseCharging    bool   `json:"case_charging"`
+	Charging        bool   `json:"charging"`
+	LeftInEar       bool   `json:"left_in_ear"`
+	RightInEar      bool   `json:"right_in_ear"`
+	LeftInCase      bool   `json:"left_in_case"`
+	RightInCase     bool   `json:"right_in_case"`
+	LidOpen         bool   `json:"lid_open"`
+	LidOpenCount    uint8  `json:"lid_open_count"`
+	NoiseMode       string `json:"noise_mode,omitempty"`
+	RSSI            int16  `json:"rssi"`                       // 0 if unknown
+	ConnectionState string `json:"connection_state,omitempty"` // BLE connection state, e.g. "music"
+}
+
+// Server accepts clients on the socket and sends them the states of the coordinator
+type Server struct {
+	path     string
+	listener net.Listener
+	coord    *podstate.PodStateCoordinator
+
+	mu          sync.Mutex
+	clients     map[net.Conn]chan []byte
+	last        []byte // Last line, sent to new clients
+	closed      bool
+	unsubscribe func()
+
+	wg sync.WaitGroup
+}
+
+// NewServer listens on the socket at path and starts streaming the states of coord.
+// An existing socket is replaced unless another LinuxPods instance still listens on it.
+func NewServer(path string, coord *podstate.PodStateCoordinator) (*Server, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("state socket %s is already in use", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove old state socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to restrict state socket: %w", err)
+	}
+
+	s := &Server{
+		path:     path,
+		listener: listener,
+		coord:    coord,
+		clients:  make(map[net.Conn]chan []byte),
+	}
+	// The first StatesChanged carries the current states
+	s.unsubscribe = coord.Subscribe(func(event podstate.Event) {
+		if e, ok := event.(podstate.StatesChanged); ok {
+			s.broadcast(e.States)
+		}
+	})
+
+	s.wg.Add(1)
+	go s.accept()
+	log.Printf("State export listening on %s", path)
+	return s, nil
+}
+
+// Close disconnects the clients and removes the socket
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	for conn, lines := range s.clients {
+		close(lines)
+		_ = conn.Close()
+		delete(s.clients, conn)
+	}
+	s.mu.Unlock()
+
+	s.unsubscribe()
+	err := s.listener.Close()
+	s.wg.Wait()
+	_ = os.Remove(s.path)
+	return err
+}
+
+// accept registers new clients until the server is closed
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Warning: State export stopped: %v", err)
+			}
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		lines := make(chan []byte, clientQueue)
+		if s.last != nil {
+			lines <- s.last
+		}
+		s.clients[conn] = lines
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serve(conn, lines)
+	}
+}
+
+// serve writes the lines to a client until it disconnects or the server is closed
+func (s *Server) serve(conn net.Conn, lines chan []byte) {
+	defer s.wg.Done()
+	for line := range lines {
+		_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := conn.Write(line); err != nil {
+			s.disconnect(conn)
+			// Drain until disconnect closed the channel
+			for range lines {
+			}
+			return
+		}
+	}
+}
+
+// disconnect closes a client connection, unless the server did already
+func (s *Server) disconnect(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lines, ok := s.clients[conn]; ok {
+		close(lines)
+		_ = conn.Close()
+		delete(s.clients, conn)
+	}
+}
+
+// broadcast sends the states to all clients
+func (s *Server) broadcast(states map[string]*podstate.PodState) {
+	line, err := json.Marshal(s.message(states))
+	if err != nil {
+		log.Printf("Warning: Failed to encode state: %v", err)
+		return
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.last = line
+	for conn, lines := range s.clients {
+		select {
+		case lines <- line:
+		default:
+			// Too slow, don't block the coordinator
+			close(lines)
+			_ = conn.Close()
+			delete(s.clients, conn)
+		}
+	}
+}
+
+// message converts the states into a line, with the devices sorted by address
+func (s *Server) message(states map[string]*podstate.PodState) message {
+	msg := message{Time: time.Now(), Devices: make([]deviceState, 0, len(states))}
+	for macAddr, state := range states {
+		noiseMode := ""
+		if mode, ok := s.coord.GetNoiseMode(macAddr); ok {
+			noiseMode = noiseModeNames[mode]
+		}
+		msg.Devices = append(msg.Devices, deviceState{
+			Address:         macAddr,
+			Name:            state.ModelName,
+			Alias:           state.Alias,
+			Source:          state.Source.String(),
+			Connected:       state.Source == podstate.DataSourceAAP,
+			OwnDevice:       state.IsOwnDevice,
+			Stale:           state.Stale,
+			LastSeen:        state.LastSeen.Unix(),
+			LeftBattery:     state.LeftBattery,
+			RightBattery:    state.RightBattery,
+			CaseBattery:     state.CaseBattery,
+			Battery:         state.Battery,
+			LeftCharging:    state.LeftCharging,
+			RightCharging:   state.RightCharging,
+			CaseCharging:    state.CaseCharging,
+			Charging:        state.Charging,
+			LeftInEar:       state.LeftInEar,
+			RightInEar:      state.RightInEar,
+			LeftInCase:      state.LeftInCase,
+			RightInCase:     state.RightInCase,
+			LidOpen:         state.LidOpen,
+			LidOpenCount:    state.LidOpenCount,
+			NoiseMode:       noiseMode,
+			RSSI:            state.RSSI,
+			ConnectionState: connectionState(state),
+		})
+	}
+	sort.Slice(msg.Devices, func(i, j int) bool { return msg.Devices[i].Address < msg.Devices[j].Address })
+	return msg
+}
@@ -0,0 +1,243 @@
+// Package statelog periodically records AirPods state as a lightweight log.
+//
+// It is meant for users who just want to graph battery levels over time (e.g. in a
+// spreadsheet) without running MQTT or Prometheus. Two sinks are supported:
+//   - csv: appends rows to a CSV file (header is written once for new files)
+//   - journal: writes one line per device to the standard log, which ends up in the
+//     systemd journal when LinuxPods runs as a user service
+//
+// Columns: timestamp, device, left, right, case, left_charging, right_charging,
+// case_charging, left_in_ear, right_in_ear, source, battery, charging. The last two
+// hold the single battery of AirPods Max. Unknown battery levels are empty.
+package statelog
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"linuxpods/internal/podstate"
+	"linuxpods/internal/util"
+)
+
+// DefaultInterval is the default time between two log entries
+const DefaultInterval = time.Minute
+
+// csvHeader is the header row of CSV logs
+var csvHeader = []string{
+	"timestamp", "device", "left", "right", "case",
+	"left_charging", "right_charging", "case_charging",
+	"left_in_ear", "right_in_ear", "source",
+	"battery", "charging",
+}
+
+// Sink selects where log entries are written
+type Sink string
+
+const (
+	SinkCSV     Sink = "csv"
+	SinkJournal Sink = "journal"
+)
+
+// Config selects the sink and cadence of a Logger
+type Config struct {
+	Sink     Sink
+	Path     string        // CSV file path (SinkCSV only)
+	Interval time.Duration // Time between entries (DefaultInterval if zero)
+}
+
+// ParseConfig parses a sink specification of the form "csv:PATH" or "journal"
+func ParseConfig(spec string) (Config, error) {
+	sink, path, _ := strings.Cut(spec, ":")
+	switch Sink(sink) {
+	case SinkCSV:
+		if path == "" {
+			return Config{}, fmt.Errorf("csv state log requires a file path (csv:PATH)")
+		}
+		return Config{Sink: SinkCSV, Path: path}, nil
+	case SinkJournal:
+		return Config{Sink: SinkJournal}, nil
+	default:
+		return Config{}, fmt.Errorf("unknown state log sink %q (expected csv:PATH or journal)", sink)
+	}
+}
+
+// DefaultPath returns the default CSV file, state-log.csv in the LinuxPods data directory
+func DefaultPath() (string, error) {
+	dir, err := util.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state-log.csv"), nil
+}
+
+// Logger writes the latest state of every device at a fixed interval
+type Logger struct {
+	sink     Sink
+	file     *os.File
+	writer   *csv.Writer
+	interval time.Duration
+
+	mu     sync.Mutex
+	states map[string]*podstate.PodState
+
+	stopChan chan struct{}
+	doneChan chan struct{}
+}
+
+// NewLogger creates a logger and starts writing entries.
+// Feed it with state updates by registering Update as a coordinator callback.
+func NewLogger(config Config) (*Logger, error) {
+	l := &Logger{
+		sink:     config.Sink,
+		interval: config.Interval,
+		states:   make(map[string]*podstate.PodState),
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+	}
+	if l.interval <= 0 {
+		l.interval = DefaultInterval
+	}
+
+	switch config.Sink {
+	case SinkCSV:
+		if err := os.MkdirAll(filepath.Dir(config.Path), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create state log directory: %w", err)
+		}
+		file, err := os.OpenFile(config.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open state log: %w", err)
+		}
+		l.file = file
+		l.writer = csv.NewWriter(file)
+
+		// Only write the header for new (empty) files, so appending keeps a single header
+		if info, err := file.Stat(); err == nil && info.Size() == 0 {
+			if err := l.writeCSV(csvHeader); err != nil {
+				_ = file.Close()
+				return nil, err
+			}
+		}
+	case SinkJournal:
+	default:
+		return nil, fmt.Errorf("unknown state log sink %q", config.Sink)
+	}
+
+	go l.run()
+	return l, nil
+}
+
+// Update stores the latest device states, e.g. of podstate.StatesChanged events
+func (l *Logger) Update(states map[string]*podstate.PodState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for addr, state := range states {
+		l.states[addr] = state
+	}
+}
+
+// run writes entries until the logger is closed
+func (l *Logger) run() {
+	defer close(l.doneChan)
+
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stopChan:
+			return
+		case now := <-ticker.C:
+			if err := l.writeEntries(now); err != nil {
+				log.Printf("Warning: Failed to write state log: %v", err)
+			}
+		}
+	}
+}
+
+// writeEntries writes one entry per device, sorted by device address.
+// Stale states are skipped, they would repeat outdated values.
+func (l *Logger) writeEntries(now time.Time) error {
+	l.mu.Lock()
+	addrs := make([]string, 0, len(l.states))
+	for addr := range l.states {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	rows := make([][]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if l.states[addr].Stale {
+			continue
+		}
+		rows = append(rows, formatRow(now, addr, l.states[addr]))
+	}
+	l.mu.Unlock()
+
+	for _, row := range rows {
+		if l.sink == SinkJournal {
+			log.Printf("State: %s", strings.Join(row, ","))
+			continue
+		}
+		if err := l.writeCSV(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCSV writes and flushes a single CSV row
+func (l *Logger) writeCSV(row []string) error {
+	if err := l.writer.Write(row); err != nil {
+		return fmt.Errorf("failed to write CSV row: %w", err)
+	}
+	l.writer.Flush()
+	if err := l.writer.Error(); err != nil {
+		return fmt.Errorf("failed to flush CSV row: %w", err)
+	}
+	return nil
+}
+
+// formatRow converts a device state into a log row
+func formatRow(now time.Time, addr string, state *podstate.PodState) []string {
+	return []string{
+		now.Format(time.RFC3339),
+		addr,
+		formatLevel(state.LeftBattery),
+		formatLevel(state.RightBattery),
+		formatLevel(state.CaseBattery),
+		strconv.FormatBool(state.LeftCharging),
+		strconv.FormatBool(state.RightCharging),
+		strconv.FormatBool(state.CaseCharging),
+		strconv.FormatBool(state.LeftInEar),
+		strconv.FormatBool(state.RightInEar),
+		state.Source.String(),
+		formatLevel(state.Battery),
+		strconv.FormatBool(state.Charging),
+	}
+}
+
+// formatLevel formats a battery level, leaving unknown levels empty
+func formatLevel(level *int) string {
+	if level == nil {
+		return ""
+	}
+	return strconv.Itoa(*level)
+}
+
+// Close stops the logger and closes the log file
+func (l *Logger) Close() error {
+	close(l.stopChan)
+	<-l.doneChan
+
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,52 @@
+package statelog
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"linuxpods/internal/podstate"
+)
+
+func TestFormatRow(t *testing.T) {
+	level := func(v int) *int { return &v }
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		state *podstate.PodState
+		want  []string
+	}{
+		{
+			name: "earbuds",
+			state: &podstate.PodState{
+				LeftBattery:  level(80),
+				RightBattery: level(70),
+				LeftCharging: true,
+				LeftInEar:    true,
+				Source:       podstate.DataSourceAAP,
+			},
+			want: []string{"2026-01-02T03:04:05Z", "AA:BB", "80", "70", "", "true", "false", "false", "true", "false", "AAP", "", "false"},
+		},
+		{
+			name: "single battery",
+			state: &podstate.PodState{
+				Battery:  level(55),
+				Charging: true,
+				Source:   podstate.DataSourceAAP,
+			},
+			want: []string{"2026-01-02T03:04:05Z", "AA:BB", "", "", "", "false", "false", "false", "false", "false", "AAP", "55", "true"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatRow(now, "AA:BB", tt.state)
+			if len(got) != len(csvHeader) {
+				t.Errorf("row has %d columns, header has %d", len(got), len(csvHeader))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("formatRow() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"context"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/debuginfo"
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+	"linuxpods/internal/version"
+)
+
+// projectURL is the home page of LinuxPods, where bugs are reported
+const projectURL = "https://github.com/mstroecker/LinuxPods"
+
+// addAboutActions adds the win.about action, showing the About window, and the
+// win.save-debug-info action, saving a tarball for bug reports
+func addAboutActions(win *adw.ApplicationWindow, podCoord podstate.Backend, toasts *toaster) {
+	about := gio.NewSimpleAction("about", nil)
+	about.ConnectActivate(func(*glib.Variant) { showAboutWindow(win) })
+	win.AddAction(about)
+
+	saveDebugInfo := gio.NewSimpleAction("save-debug-info", nil)
+	saveDebugInfo.ConnectActivate(func(*glib.Variant) { saveDebugInfoFile(win, podCoord, toasts) })
+	win.AddAction(saveDebugInfo)
+}
+
+// showAboutWindow shows the version, the commit LinuxPods was built from and the credits.
+// Its troubleshooting page shows the same summary as the debug info.
+func showAboutWindow(win *adw.ApplicationWindow) {
+	about := adw.NewAboutWindow()
+	about.SetTransientFor(&win.Window)
+	about.SetModal(true)
+	about.SetApplicationName("LinuxPods")
+	about.SetApplicationIcon("audio-headphones")
+	about.SetVersion(version.String())
+	about.SetComments(i18n.T("Battery levels and controls of AirPods on Linux"))
+	about.SetWebsite(projectURL)
+	about.SetIssueURL(projectURL + "/issues")
+	about.SetLicenseType(gtk.LicenseAGPL30)
+	about.SetDebugInfo(debuginfo.Summary())
+	about.SetDebugInfoFilename("linuxpods-info.txt")
+	about.AddAcknowledgementSection(i18n.T("Protocol Research"), []string{
+		"LibrePods https://github.com/kavishdevar/librepods",
+		"furiousMAC/continuity https://github.com/furiousMAC/continuity",
+	})
+	about.Present()
+}
+
+// saveDebugInfoFile asks where to save the debug info and saves it there
+func saveDebugInfoFile(win *adw.ApplicationWindow, podCoord podstate.Backend, toasts *toaster) {
+	dialog := gtk.NewFileDialog()
+	dialog.SetTitle(i18n.T("Save Debug Info"))
+	dialog.SetInitialName(debuginfo.FileName())
+	dialog.Save(context.Background(), &win.Window, func(res gio.AsyncResulter) {
+		file, err := dialog.SaveFinish(res)
+		if err != nil {
+			return // Canceled
+		}
+		path := file.Path()
+		go func() {
+			err := debuginfo.WriteFile(path, podCoord)
+			glib.IdleAdd(func() {
+				// Toast titles are markup
+				if err != nil {
+					toasts.show(glib.MarkupEscapeText(i18n.Tf("Error: %v", err)))
+				} else {
+					toasts.show(glib.MarkupEscapeText(i18n.Tf("Saved to %s", path)))
+				}
+			})
+		}()
+	})
+}
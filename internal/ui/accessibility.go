@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/i18n"
+)
+
+// componentNames are the names of the device parts, announced by screen readers for their
+// images and battery levels (translated where they are used)
+var componentNames = map[string]string{
+	"left":       i18n.N("Left AirPod"),
+	"right":      i18n.N("Right AirPod"),
+	"case":       i18n.N("Case"),
+	"headphones": i18n.N("Headphones"),
+}
+
+// levelDescriptions describe the battery level of each device part to screen readers
+// (translated where they are used)
+var levelDescriptions = map[string]string{
+	"left":       i18n.N("Battery of the left AirPod"),
+	"right":      i18n.N("Battery of the right AirPod"),
+	"case":       i18n.N("Battery of the case"),
+	"headphones": i18n.N("Battery of the headphones"),
+}
+
+// setAccessibleLabel sets the name screen readers announce for a widget without a visible
+// label, e.g. an icon button or an image
+func setAccessibleLabel(widget gtk.Accessibler, label string) {
+	widget.UpdateProperty([]gtk.AccessibleProperty{gtk.AccessiblePropertyLabel}, []glib.Value{*glib.NewValue(label)})
+}
+
+// setAccessibleDescription sets the description screen readers announce after the name
+func setAccessibleDescription(widget gtk.Accessibler, description string) {
+	widget.UpdateProperty([]gtk.AccessibleProperty{gtk.AccessiblePropertyDescription}, []glib.Value{*glib.NewValue(description)})
+}
+
+// setAccessibleValueText sets the text screen readers announce as the value of a level bar
+func setAccessibleValueText(widget gtk.Accessibler, text string) {
+	widget.UpdateProperty([]gtk.AccessibleProperty{gtk.AccessiblePropertyValueText}, []glib.Value{*glib.NewValue(text)})
+}
+
+// labelLevelBy makes screen readers announce a battery level by the labels showing its
+// percentage and state, e.g. "80% In ear · Charging". Mnemonic labels are the level's
+// labelled-by relation, so the announcement follows the labels without updating the level.
+func labelLevelBy(bar *gtk.LevelBar, labels ...*gtk.Label) {
+	// The relation lists the last added label first
+	for i := len(labels) - 1; i >= 0; i-- {
+		bar.AddMnemonicLabel(labels[i])
+	}
+}
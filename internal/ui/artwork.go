@@ -0,0 +1,96 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/assets"
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// artworkDir holds product images of specific models, named after the model code and color
+// code of the advertisements: 2720_00_left.png for a white AirPods Pro 3 left pod, or
+// 2720_left.png for the model in any color. The parts are left, right, case and headphones.
+const artworkDir = "models"
+
+// genericArtwork is shown for the parts of models without their own images. Like artworkDir,
+// the names refer to the images embedded by the assets package.
+var genericArtwork = map[string]string{
+	"left":  "left_airpod_pro3.png",
+	"right": "right_airpod_pro3.png",
+	"case":  "airpod_case.png",
+}
+
+// artworkPath returns the image of a part of a device: the image of its model and color,
+// of its model, or the generic image. It returns "" for parts without any image.
+func artworkPath(state *podstate.PodState, part string) string {
+	for _, name := range []string{
+		fmt.Sprintf("%04x_%02x_%s.png", state.DeviceModel, state.Color, part),
+		fmt.Sprintf("%04x_%s.png", state.DeviceModel, part),
+	} {
+		path := artworkDir + "/" + name
+		if assets.Exists(path) {
+			return path
+		}
+	}
+	return genericArtwork[part]
+}
+
+// artworkImage is an image of a device part that follows the model and color of the shown device
+type artworkImage struct {
+	image *gtk.Image
+	part  string
+	icon  string // Icon shown if there is no image of the part
+	path  string // Shown image, "" for the icon
+}
+
+// newArtworkImage creates the image of a part, showing the generic image until a device is shown
+func newArtworkImage(part string, icon string, size int) *artworkImage {
+	a := &artworkImage{image: gtk.NewImage(), part: part, icon: icon}
+	a.image.SetPixelSize(size)
+	setAccessibleLabel(a.image, i18n.T(componentNames[part]))
+	a.show(genericArtwork[part])
+	return a
+}
+
+// update shows the image of the part of a device
+func (a *artworkImage) update(state *podstate.PodState) {
+	if path := artworkPath(state, a.part); path != a.path {
+		a.show(path)
+	}
+}
+
+// show shows an embedded image, or the icon if path is "" or the image can't be loaded
+func (a *artworkImage) show(path string) {
+	a.path = path
+	if path == "" {
+		a.image.SetFromIconName(a.icon)
+		return
+	}
+
+	texture, err := loadTexture(path)
+	if err != nil {
+		log.Printf("Warning: Failed to load image %s: %v", path, err)
+		a.image.SetFromIconName(a.icon)
+		return
+	}
+	a.image.SetFromPaintable(texture)
+}
+
+// loadTexture decodes an embedded image
+func loadTexture(path string) (*gdk.Texture, error) {
+	data, err := assets.Read(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	texture, err := gdk.NewTextureFromBytes(glib.NewBytes(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return texture, nil
+}
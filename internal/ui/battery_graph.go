@@ -0,0 +1,302 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/cairo"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// batteryGraphRefreshSeconds is how often the graph fetches the history, so it moves along
+// while the levels don't change
+const batteryGraphRefreshSeconds = 60
+
+// batteryGraphRanges are the time spans the graph can show (translated where they are shown)
+var batteryGraphRanges = []struct {
+	label string
+	span  time.Duration
+}{
+	{i18n.N("6 Hours"), 6 * time.Hour},
+	{i18n.N("24 Hours"), 24 * time.Hour},
+	{i18n.N("7 Days"), 7 * 24 * time.Hour},
+}
+
+// batteryGraphComponents are the batteries drawn by the graph, with the color of their line
+// (from the GNOME palette) and the legend label
+var batteryGraphComponents = []struct {
+	component aap.BatteryComponent
+	color     [3]float64
+	hex       string
+	label     string
+}{
+	{aap.ComponentLeft, [3]float64{0.21, 0.52, 0.89}, "#3584e4", i18n.N("Left")},
+	{aap.ComponentRight, [3]float64{0.90, 0.38, 0.00}, "#e66100", i18n.N("Right")},
+	{aap.ComponentCase, [3]float64{0.15, 0.64, 0.41}, "#26a269", i18n.N("Case")},
+	{aap.ComponentSingle, [3]float64{0.21, 0.52, 0.89}, "#3584e4", i18n.N("Headphones")},
+}
+
+// Margins of the plot within the drawing area, leaving room for the axis labels
+const (
+	graphMarginLeft   = 40
+	graphMarginRight  = 8
+	graphMarginTop    = 8
+	graphMarginBottom = 20
+)
+
+// batteryGraph is the "Battery History" group, which draws the levels of the shown device
+// over the last hours or days. Spans in which a battery was charging are shaded in its color,
+// so a bud that drains faster than the other stands out.
+type batteryGraph struct {
+	group   *adw.PreferencesGroup
+	area    *gtk.DrawingArea
+	legend  map[aap.BatteryComponent]*gtk.Label
+	backend podstate.Backend
+
+	macAddr string        // Real MAC address of the shown device, "" if unknown
+	span    time.Duration // Shown time span, up to now
+	samples []podstate.BatterySample
+	fetched time.Time // When samples were fetched, the end of the plot
+}
+
+// createBatteryGraph builds the battery history group with its range buttons and legend
+func createBatteryGraph(podCoord podstate.Backend) *batteryGraph {
+	g := &batteryGraph{
+		group:   adw.NewPreferencesGroup(),
+		area:    gtk.NewDrawingArea(),
+		legend:  make(map[aap.BatteryComponent]*gtk.Label),
+		backend: podCoord,
+		span:    batteryGraphRanges[0].span,
+	}
+	g.group.SetTitle(i18n.T("Battery History"))
+	g.group.SetDescription(i18n.T("Shaded spans are charging"))
+
+	// Linked buttons choosing the time span
+	ranges := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	ranges.AddCSSClass("linked")
+	ranges.SetVAlign(gtk.AlignCenter)
+	var first *gtk.ToggleButton
+	for _, r := range batteryGraphRanges {
+		span := r.span
+		button := gtk.NewToggleButtonWithLabel(i18n.T(r.label))
+		button.AddCSSClass("flat")
+		if first == nil {
+			first = button
+			button.SetActive(true)
+		} else {
+			button.SetGroup(first)
+		}
+		button.Connect("toggled", func() {
+			if button.Active() && g.span != span {
+				g.span = span
+				g.refresh()
+			}
+		})
+		ranges.Append(button)
+	}
+	g.group.SetHeaderSuffix(ranges)
+
+	g.area.SetContentHeight(160)
+	g.area.SetHExpand(true)
+	g.area.SetDrawFunc(g.draw)
+
+	legend := gtk.NewBox(gtk.OrientationHorizontal, 16)
+	legend.SetHAlign(gtk.AlignCenter)
+	for _, c := range batteryGraphComponents {
+		label := gtk.NewLabel("")
+		label.SetMarkup(`<span foreground="` + c.hex + `">●</span> ` + glib.MarkupEscapeText(i18n.T(c.label)))
+		label.AddCSSClass("caption")
+		legend.Append(label)
+		g.legend[c.component] = label
+	}
+
+	// The card draws the frame, the inner box keeps the plot off its edges
+	card := gtk.NewBox(gtk.OrientationVertical, 0)
+	card.AddCSSClass("card")
+	content := gtk.NewBox(gtk.OrientationVertical, 6)
+	content.SetMarginTop(12)
+	content.SetMarginBottom(12)
+	content.SetMarginStart(12)
+	content.SetMarginEnd(12)
+	content.Append(g.area)
+	content.Append(legend)
+	card.Append(content)
+	g.group.Add(card)
+
+	// New samples are recorded when a battery changes, the timer moves the plot along otherwise
+	podCoord.Subscribe(func(event podstate.Event) {
+		if e, ok := event.(podstate.BatteryChanged); ok {
+			glib.IdleAdd(func() {
+				if e.Address == g.macAddr {
+					g.refresh()
+				}
+			})
+		}
+	})
+	timer := glib.TimeoutSecondsAdd(batteryGraphRefreshSeconds, func() bool {
+		g.refresh()
+		return true
+	})
+	g.area.ConnectUnrealize(func() { glib.SourceRemove(timer) })
+
+	return g
+}
+
+// show selects the device whose history is drawn
+func (g *batteryGraph) show(state *podstate.PodState) {
+	single := state.Capabilities.SingleBattery()
+	g.legend[aap.ComponentLeft].SetVisible(!single)
+	g.legend[aap.ComponentRight].SetVisible(!single)
+	g.legend[aap.ComponentCase].SetVisible(!single && state.Capabilities.HasCase)
+	g.legend[aap.ComponentSingle].SetVisible(single)
+
+	if state.RealMac != g.macAddr {
+		g.macAddr = state.RealMac
+		g.samples = nil
+		g.refresh()
+	}
+}
+
+// refresh fetches the history of the shown device and redraws the graph. The daemon is called
+// over D-Bus, so the history is fetched in the background.
+func (g *batteryGraph) refresh() {
+	macAddr, span := g.macAddr, g.span
+	if macAddr == "" {
+		g.samples, g.fetched = nil, time.Now()
+		g.area.QueueDraw()
+		return
+	}
+	go func() {
+		now := time.Now()
+		samples := g.backend.BatteryHistory(macAddr, now.Add(-span))
+		glib.IdleAdd(func() {
+			if macAddr != g.macAddr || span != g.span {
+				return // Another device or span was chosen meanwhile
+			}
+			g.samples, g.fetched = samples, now
+			g.area.QueueDraw()
+		})
+	}()
+}
+
+// draw draws the grid, the charging spans and the level of each battery
+func (g *batteryGraph) draw(area *gtk.DrawingArea, cr *cairo.Context, width, height int) {
+	fg := area.Color()
+	r, gr, b := float64(fg.Red()), float64(fg.Green()), float64(fg.Blue())
+
+	plotW := float64(width - graphMarginLeft - graphMarginRight)
+	plotH := float64(height - graphMarginTop - graphMarginBottom)
+	if plotW <= 0 || plotH <= 0 {
+		return
+	}
+	end := g.fetched
+	if end.IsZero() {
+		end = time.Now()
+	}
+	start := end.Add(-g.span)
+	x := func(t time.Time) float64 {
+		return graphMarginLeft + plotW*float64(t.Sub(start))/float64(g.span)
+	}
+	y := func(level int) float64 {
+		return graphMarginTop + plotH*(1-float64(level)/100)
+	}
+
+	cr.SetFontSize(10)
+
+	// Horizontal grid lines with the levels
+	cr.SetLineWidth(1)
+	for level := 0; level <= 100; level += 25 {
+		cr.SetSourceRGBA(r, gr, b, 0.15)
+		cr.MoveTo(graphMarginLeft, math.Round(y(level))+0.5)
+		cr.LineTo(graphMarginLeft+plotW, math.Round(y(level))+0.5)
+		cr.Stroke()
+
+		if level%50 == 0 {
+			text := fmt.Sprintf("%d%%", level)
+			extents := cr.TextExtents(text)
+			cr.SetSourceRGBA(r, gr, b, 0.6)
+			cr.MoveTo(graphMarginLeft-6-extents.XAdvance, y(level)+extents.Height/2)
+			cr.ShowText(text)
+		}
+	}
+
+	// Times along the bottom: hours for up to a day, days for longer spans
+	layout := "15:04"
+	if g.span > 24*time.Hour {
+		layout = "Mon"
+	}
+	for i := 0; i <= 3; i++ {
+		t := start.Add(g.span * time.Duration(i) / 3)
+		text := t.Format(layout)
+		extents := cr.TextExtents(text)
+		tx := x(t) - extents.XAdvance/2
+		tx = max(graphMarginLeft, min(tx, graphMarginLeft+plotW-extents.XAdvance))
+		cr.SetSourceRGBA(r, gr, b, 0.6)
+		cr.MoveTo(tx, float64(height)-4)
+		cr.ShowText(text)
+	}
+
+	if len(g.samples) == 0 {
+		text := i18n.T("No battery history yet")
+		extents := cr.TextExtents(text)
+		cr.SetSourceRGBA(r, gr, b, 0.6)
+		cr.MoveTo(graphMarginLeft+(plotW-extents.XAdvance)/2, graphMarginTop+plotH/2)
+		cr.ShowText(text)
+		return
+	}
+
+	for _, c := range batteryGraphComponents {
+		if !g.legend[c.component].Visible() {
+			continue
+		}
+		g.drawComponent(cr, c.component, c.color, x, y, end, plotH)
+	}
+}
+
+// drawComponent draws the level of one battery as steps, since a sample is only recorded when
+// the level changes, and shades the spans in which it was charging
+func (g *batteryGraph) drawComponent(cr *cairo.Context, component aap.BatteryComponent, color [3]float64,
+	x func(time.Time) float64, y func(int) float64, end time.Time, plotH float64) {
+
+	var samples []podstate.BatterySample
+	for _, sample := range g.samples {
+		if sample.Component == component {
+			samples = append(samples, sample)
+		}
+	}
+	if len(samples) == 0 {
+		return
+	}
+
+	// Each sample holds until the next one, the last one until now
+	until := func(i int) time.Time {
+		if i+1 < len(samples) {
+			return samples[i+1].Time
+		}
+		return end
+	}
+
+	cr.SetSourceRGBA(color[0], color[1], color[2], 0.12)
+	for i, sample := range samples {
+		if sample.Charging {
+			cr.Rectangle(x(sample.Time), graphMarginTop, x(until(i))-x(sample.Time), plotH)
+		}
+	}
+	cr.Fill()
+
+	cr.SetSourceRGB(color[0], color[1], color[2])
+	cr.SetLineWidth(2)
+	cr.MoveTo(x(samples[0].Time), y(samples[0].Level))
+	for i, sample := range samples {
+		cr.LineTo(x(sample.Time), y(sample.Level))
+		cr.LineTo(x(until(i)), y(sample.Level))
+	}
+	cr.Stroke()
+}
@@ -0,0 +1,85 @@
+package ui
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/i18n"
+)
+
+// Battery levels (fractions) up to which a level bar is shown in red and yellow
+const (
+	batteryCriticalLevel = 0.15
+	batteryLowLevel      = 0.30
+)
+
+// style is the stylesheet of the GUI, see loadStyle
+//
+//go:embed style.css
+var style string
+
+// styleLoaded is set once the stylesheet was added to the display, windows created on
+// later activations share it
+var styleLoaded bool
+
+// loadStyle adds the GUI's stylesheet to the default display
+func loadStyle() {
+	if styleLoaded {
+		return
+	}
+	provider := gtk.NewCSSProvider()
+	provider.LoadFromString(style)
+	gtk.StyleContextAddProviderForDisplay(gdk.DisplayGetDefault(), provider, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
+	styleLoaded = true
+}
+
+// newBatteryLevel creates the battery level bar of a device part with the percentage on top
+// of it. The level is colored by the offsets instead of the theme's defaults, which look the
+// same for most levels: red up to 15%, yellow up to 30% and green above.
+func newBatteryLevel(part string) (*gtk.Overlay, *gtk.LevelBar, *gtk.Label) {
+	bar := gtk.NewLevelBar()
+	bar.SetMode(gtk.LevelBarModeContinuous)
+	bar.SetValue(0.0) // Start at 0, will be updated by scanner
+	bar.SetSizeRequest(100, 20)
+	bar.AddCSSClass("battery")
+	bar.RemoveOffsetValue(gtk.LEVEL_BAR_OFFSET_LOW)
+	bar.RemoveOffsetValue(gtk.LEVEL_BAR_OFFSET_HIGH)
+	bar.RemoveOffsetValue(gtk.LEVEL_BAR_OFFSET_FULL)
+	bar.AddOffsetValue("battery-critical", batteryCriticalLevel)
+	bar.AddOffsetValue("battery-low", batteryLowLevel)
+	bar.AddOffsetValue("battery-ok", 1.0)
+	setAccessibleDescription(bar, i18n.T(levelDescriptions[part]))
+
+	label := gtk.NewLabel("--")
+	label.AddCSSClass("battery-percent")
+	label.SetHAlign(gtk.AlignCenter)
+	label.SetVAlign(gtk.AlignCenter)
+
+	overlay := gtk.NewOverlay()
+	overlay.SetChild(bar)
+	overlay.AddOverlay(label)
+	return overlay, bar, label
+}
+
+// showLevel shows a battery level, or "--" if it is unknown. The level shimmers while
+// the component charges.
+func showLevel(bar *gtk.LevelBar, label *gtk.Label, level *int, charging bool) {
+	if level == nil {
+		bar.SetValue(0.0)
+		bar.RemoveCSSClass("charging")
+		label.SetText("--")
+		setAccessibleValueText(bar, i18n.T("Unknown"))
+		return
+	}
+	bar.SetValue(float64(*level) / 100.0)
+	setAccessibleValueText(bar, fmt.Sprintf("%d%%", *level))
+	if charging {
+		bar.AddCSSClass("charging")
+	} else {
+		bar.RemoveCSSClass("charging")
+	}
+	label.SetText(fmt.Sprintf("%d%%", *level))
+}
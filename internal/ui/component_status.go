@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// newComponentStatus creates the label below a battery level that shows the state of the
+// component, e.g. "In ear" or "In case · Charging"
+func newComponentStatus() *gtk.Label {
+	label := gtk.NewLabel("")
+	label.AddCSSClass("caption")
+	label.AddCSSClass("dim-label")
+	label.SetJustify(gtk.JustifyCenter)
+	label.SetWrap(true)
+	label.SetMaxWidthChars(14)
+	return label
+}
+
+// setComponentStatus shows the states of a component, hiding the label if there are none
+func setComponentStatus(label *gtk.Label, states []string) {
+	label.SetText(strings.Join(states, " · "))
+	label.SetVisible(len(states) > 0)
+}
+
+// podStatus returns the states of a pod. Pods without a level are out of range of the case
+// and the other pod (e.g. lost), or their battery is empty.
+func podStatus(level *int, charging bool, inEar bool, inCase bool) []string {
+	if level == nil {
+		return []string{i18n.T("Unavailable")}
+	}
+	var states []string
+	switch {
+	case inEar:
+		states = append(states, i18n.T("In ear"))
+	case inCase:
+		states = append(states, i18n.T("In case"))
+	}
+	if charging {
+		states = append(states, i18n.T("Charging"))
+	}
+	return states
+}
+
+// caseStatus returns the states of the case. Its level is only known while a pod is in it.
+func caseStatus(state *podstate.PodState) []string {
+	if state.CaseBattery == nil {
+		return []string{i18n.T("Unavailable")}
+	}
+	states := []string{i18n.T("Lid closed")}
+	if state.LidOpen {
+		states[0] = i18n.T("Lid open")
+	}
+	if state.CaseCharging {
+		states = append(states, i18n.T("Charging"))
+	}
+	return states
+}
+
+// headphoneStatus returns the states of headphones with a single battery
+func headphoneStatus(state *podstate.PodState) []string {
+	if state.Battery == nil {
+		return []string{i18n.T("Unavailable")}
+	}
+	var states []string
+	if state.LeftInEar || state.RightInEar {
+		states = append(states, i18n.T("On head"))
+	}
+	if state.Charging {
+		states = append(states, i18n.T("Charging"))
+	}
+	return states
+}
@@ -0,0 +1,348 @@
+package ui
+
+import (
+	"log"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/config"
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// defaultNoiseControlCycle is the press and hold cycle AirPods ship with
+var defaultNoiseControlCycle = aap.NewNoiseControlCycle(aap.NoiseControlTransparency, aap.NoiseControlANC)
+
+// createNoiseControlCycleGroup builds the "Noise Control Cycle" group that selects which
+// noise control modes the stem press and hold gesture cycles through
+func createNoiseControlCycleGroup(podCoord podstate.Backend) *adw.PreferencesGroup {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle(i18n.T("Noise Control Cycle"))
+	group.SetDescription(i18n.T("Noise control modes to cycle through when pressing and holding the stem"))
+
+	cycle := defaultNoiseControlCycle
+	checkButtons := make(map[aap.NoiseControlMode]*gtk.CheckButton)
+
+	// Set while the check buttons are updated from a device notification, so it isn't sent back
+	updating := false
+
+	for _, mode := range aap.NoiseControlModes {
+		name, desc := noiseControlModeText(mode)
+		row := adw.NewActionRow()
+		row.SetTitle(name)
+		row.SetSubtitle(desc)
+
+		checkButton := gtk.NewCheckButton()
+		setAccessibleLabel(checkButton, name)
+		checkButton.SetActive(cycle.Contains(mode))
+		checkButtons[mode] = checkButton
+
+		checkButton.Connect("toggled", func() {
+			if updating {
+				return
+			}
+			newCycle := aap.NewNoiseControlCycle()
+			for _, m := range aap.NoiseControlModes {
+				if checkButtons[m].Active() {
+					newCycle |= aap.NewNoiseControlCycle(m)
+				}
+			}
+			if newCycle == cycle {
+				return
+			}
+
+			// The cycle needs at least two modes - revert the toggle otherwise
+			if err := newCycle.Validate(); err != nil {
+				checkButton.SetActive(true)
+				return
+			}
+			cycle = newCycle
+
+			// Apply the cycle to every device connected via AAP
+			go func() {
+				for _, macAddr := range podCoord.GetConnectedDeviceMacs() {
+					if err := podCoord.SetNoiseControlCycle(macAddr, newCycle); err != nil {
+						log.Printf("Failed to set noise control cycle: %v", err)
+					}
+				}
+			}()
+		})
+
+		row.AddPrefix(checkButton)
+		row.SetActivatableWidget(checkButton)
+		group.Add(row)
+	}
+
+	// Show the cycle reported by the device
+	podCoord.RegisterSettingsCallback(func(macAddr string, cmd aap.ControlCommand) {
+		if cmd.ID != aap.ControlListeningModeConfigs {
+			return
+		}
+		reported, err := aap.ParseNoiseControlCycle(&cmd)
+		if err != nil || reported.Validate() != nil {
+			log.Printf("Invalid noise control cycle from %s: %v", macAddr, cmd.Value)
+			return
+		}
+		glib.IdleAdd(func() {
+			cycle = reported
+			updating = true
+			for _, mode := range aap.NoiseControlModes {
+				checkButtons[mode].SetActive(reported.Contains(mode))
+			}
+			updating = false
+		})
+	})
+
+	return group
+}
+
+// microphoneModeNames are the names of the microphone modes (translated where they are shown)
+var microphoneModeNames = map[aap.MicrophoneMode]string{
+	aap.MicrophoneAutomatic:   i18n.N("Automatic"),
+	aap.MicrophoneAlwaysRight: i18n.N("Always Right"),
+	aap.MicrophoneAlwaysLeft:  i18n.N("Always Left"),
+}
+
+// createMicrophoneGroup builds the "Microphone" group that selects which bud's microphone is used
+func createMicrophoneGroup(podCoord podstate.Backend) *adw.PreferencesGroup {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle(i18n.T("Microphone"))
+	group.SetDescription(i18n.T("Useful if one bud's microphone is broken or the bud is lost"))
+
+	names := make([]string, len(aap.MicrophoneModes))
+	for i, mode := range aap.MicrophoneModes {
+		names[i] = i18n.T(microphoneModeNames[mode])
+	}
+
+	row := adw.NewComboRow()
+	row.SetTitle(i18n.T("Microphone"))
+	row.SetSubtitle(i18n.T("Which AirPod's microphone is used for calls"))
+	row.SetModel(gtk.NewStringList(names))
+	row.SetSelected(0) // Automatic
+
+	// Set while the selection is updated from a device notification, so it isn't sent back
+	updating := false
+
+	row.Connect("notify::selected", func() {
+		if updating {
+			return
+		}
+		selected := int(row.Selected())
+		if selected < 0 || selected >= len(aap.MicrophoneModes) {
+			return
+		}
+		mode := aap.MicrophoneModes[selected]
+
+		// Apply the mode to every device connected via AAP
+		go func() {
+			for _, macAddr := range podCoord.GetConnectedDeviceMacs() {
+				if err := podCoord.SetMicrophoneMode(macAddr, mode); err != nil {
+					log.Printf("Failed to set microphone mode: %v", err)
+				}
+			}
+		}()
+	})
+
+	// Show the mode reported by the device
+	podCoord.RegisterSettingsCallback(func(macAddr string, cmd aap.ControlCommand) {
+		if cmd.ID != aap.ControlMicrophoneMode {
+			return
+		}
+		mode, err := aap.ParseMicrophoneMode(&cmd)
+		if err != nil {
+			log.Printf("Invalid microphone mode from %s: %v", macAddr, err)
+			return
+		}
+		glib.IdleAdd(func() {
+			for i, m := range aap.MicrophoneModes {
+				if m == mode && row.Selected() != uint(i) {
+					updating = true
+					row.SetSelected(uint(i))
+					updating = false
+				}
+			}
+		})
+	})
+
+	group.Add(row)
+	return group
+}
+
+// createEarDetectionGroup builds the "Ear Detection" group that toggles automatic ear detection on the device
+func createEarDetectionGroup(podCoord podstate.Backend) *adw.PreferencesGroup {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle(i18n.T("Ear Detection"))
+
+	row := adw.NewActionRow()
+	row.SetTitle(i18n.T("Automatic Ear Detection"))
+	row.SetSubtitle(i18n.T("Pause playback when an AirPod is taken out of your ear"))
+
+	earDetectionSwitch := gtk.NewSwitch()
+	earDetectionSwitch.SetActive(true) // Enabled by default on all AirPods
+	earDetectionSwitch.SetVAlign(gtk.AlignCenter)
+	row.AddSuffix(earDetectionSwitch)
+	row.SetActivatableWidget(earDetectionSwitch)
+
+	// Set while the switch is updated from a device notification, so it isn't sent back
+	updating := false
+
+	earDetectionSwitch.Connect("notify::active", func() {
+		if updating {
+			return
+		}
+		enabled := earDetectionSwitch.Active()
+
+		// Apply the setting to every device connected via AAP
+		go func() {
+			for _, macAddr := range podCoord.GetConnectedDeviceMacs() {
+				if err := podCoord.SetEarDetection(macAddr, enabled); err != nil {
+					log.Printf("Failed to set ear detection: %v", err)
+				}
+			}
+		}()
+	})
+
+	// Show the setting reported by the device
+	podCoord.RegisterSettingsCallback(func(macAddr string, cmd aap.ControlCommand) {
+		if cmd.ID != aap.ControlEarDetection {
+			return
+		}
+		enabled, err := aap.ParseEarDetection(&cmd)
+		if err != nil {
+			log.Printf("Invalid ear detection setting from %s: %v", macAddr, err)
+			return
+		}
+		glib.IdleAdd(func() {
+			if earDetectionSwitch.Active() != enabled {
+				updating = true
+				earDetectionSwitch.SetActive(enabled)
+				updating = false
+			}
+		})
+	})
+
+	group.Add(row)
+	return group
+}
+
+// createMediaGroup builds the "Media" group that selects whether media players are paused and
+// resumed when the AirPods are taken out of and put back in the ears
+func createMediaGroup(cfg config.MediaConfig) *adw.PreferencesGroup {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle(i18n.T("Media"))
+	group.SetDescription(i18n.T("Control media players with ear detection"))
+
+	pauseSwitch := addConfigSwitch(group, i18n.T("Pause When Removed"), i18n.T("Pause playback when both AirPods are taken out"),
+		"media", "pause_on_ear_removal", cfg.PauseOnEarRemoval)
+	resumeSwitch := addConfigSwitch(group, i18n.T("Resume When Inserted"), i18n.T("Resume playback when an AirPod is put back in"),
+		"media", "resume_on_ear_insertion", cfg.ResumeOnEarInsertion)
+
+	// Resuming only applies to players that were paused by ear detection
+	resumeSwitch.SetSensitive(pauseSwitch.Active())
+	pauseSwitch.Connect("notify::active", func() {
+		resumeSwitch.SetSensitive(pauseSwitch.Active())
+	})
+
+	return group
+}
+
+// createAudioOutputGroup builds the "Audio Output" group that selects whether the AirPods
+// become the default output when they connect, and whether their volume is restored
+func createAudioOutputGroup(cfg config.AudioConfig) *adw.PreferencesGroup {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle(i18n.T("Audio Output"))
+
+	addConfigSwitch(group, i18n.T("Switch Output"), i18n.T("Play audio on the AirPods when they connect"),
+		"audio", "switch_output", cfg.SwitchOutput)
+	addConfigSwitch(group, i18n.T("Remember Volume"), i18n.T("Restore the last volume of each device when it connects"),
+		"audio", "remember_volume", cfg.RememberVolume)
+	addConfigSwitch(group, i18n.T("Switch Profile for Calls"), i18n.T("Use the headset profile only while an application records from the microphone"),
+		"audio", "switch_profile", cfg.SwitchProfile)
+
+	return group
+}
+
+// addConfigSwitch adds a row with a switch for a boolean key of the config file to a group.
+// Toggling the switch saves the key, from which the daemon picks it up.
+func addConfigSwitch(group *adw.PreferencesGroup, title string, subtitle string, section string, key string, active bool) *gtk.Switch {
+	row := adw.NewActionRow()
+	row.SetTitle(title)
+	row.SetSubtitle(subtitle)
+
+	configSwitch := gtk.NewSwitch()
+	configSwitch.SetActive(active)
+	configSwitch.SetVAlign(gtk.AlignCenter)
+	row.AddSuffix(configSwitch)
+	row.SetActivatableWidget(configSwitch)
+
+	configSwitch.Connect("notify::active", func() {
+		if err := config.SetValue(config.Path(), section, key, configSwitch.Active()); err != nil {
+			log.Printf("Failed to save %s.%s: %v", section, key, err)
+		}
+	})
+
+	group.Add(row)
+	return configSwitch
+}
+
+// createToneVolumeGroup builds the "Tone Volume" group with a slider for the volume of the
+// tones and alerts played by the AirPods, e.g. the connection chime
+func createToneVolumeGroup(podCoord podstate.Backend) *adw.PreferencesGroup {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle(i18n.T("Tone Volume"))
+	group.SetDescription(i18n.T("Volume of the connection chime and other alerts played by the AirPods"))
+
+	row := adw.NewActionRow()
+	row.SetTitle(i18n.T("Volume"))
+
+	scale := gtk.NewScaleWithRange(gtk.OrientationHorizontal, aap.MinToneVolume, aap.MaxToneVolume, 5)
+	scale.SetValue(aap.MaxToneVolume) // Full volume by default
+	scale.SetDrawValue(true)
+	scale.SetHExpand(true)
+	scale.SetVAlign(gtk.AlignCenter)
+	row.AddSuffix(scale)
+
+	// Set while the slider is updated from a device notification, so it isn't sent back
+	updating := false
+
+	scale.Connect("value-changed", func() {
+		if updating {
+			return
+		}
+		volume := uint8(scale.Value())
+
+		// Apply the volume to every device connected via AAP
+		go func() {
+			for _, macAddr := range podCoord.GetConnectedDeviceMacs() {
+				if err := podCoord.SetToneVolume(macAddr, volume); err != nil {
+					log.Printf("Failed to set tone volume: %v", err)
+				}
+			}
+		}()
+	})
+
+	// Show the volume reported by the device
+	podCoord.RegisterSettingsCallback(func(macAddr string, cmd aap.ControlCommand) {
+		if cmd.ID != aap.ControlChimeVolume {
+			return
+		}
+		volume, err := aap.ParseToneVolume(&cmd)
+		if err != nil {
+			log.Printf("Invalid tone volume from %s: %v", macAddr, err)
+			return
+		}
+		glib.IdleAdd(func() {
+			if uint8(scale.Value()) != volume {
+				updating = true
+				scale.SetValue(float64(volume))
+				updating = false
+			}
+		})
+	})
+
+	group.Add(row)
+	return group
+}
@@ -0,0 +1,184 @@
+package ui
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/annotator"
+	"linuxpods/internal/ble"
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+const (
+	// debugViewRefreshMillis is how often the debug view fetches new packets while it is shown
+	debugViewRefreshMillis = 1000
+
+	// debugViewRows is how many packets the debug view shows, older ones are removed
+	debugViewRows = 200
+)
+
+// debugView is the Debug tab showing the BLE advertisements and AAP packets as they are
+// received, with their field maps and parse results. Packets can be copied as test vectors
+// for the parser corpus (internal/ble/testdata and internal/aap/testdata), so users can
+// contribute captures of unsupported models.
+type debugView struct {
+	box     *gtk.Box
+	list    *gtk.ListBox
+	rows    []*adw.ExpanderRow // Newest first
+	backend podstate.Backend
+
+	lastSeq  uint64 // Sequence number of the newest shown packet
+	fetching bool
+	paused   bool
+}
+
+// createDebugView builds the debug view and starts fetching packets
+func createDebugView(podCoord podstate.Backend, toasts *toaster) *debugView {
+	v := &debugView{
+		box:     gtk.NewBox(gtk.OrientationVertical, 12),
+		list:    gtk.NewListBox(),
+		backend: podCoord,
+	}
+	v.box.SetMarginTop(20)
+	v.box.SetMarginBottom(20)
+	v.box.SetMarginStart(20)
+	v.box.SetMarginEnd(20)
+
+	header := adw.NewPreferencesGroup()
+	header.SetTitle(i18n.T("Live Packets"))
+	header.SetDescription(i18n.T("BLE advertisements and AAP packets as they are received. Copy a packet as a test vector to report an unsupported model or packet."))
+	pauseButton := gtk.NewToggleButtonWithLabel(i18n.T("Pause"))
+	pauseButton.SetVAlign(gtk.AlignCenter)
+	pauseButton.Connect("toggled", func() { v.paused = pauseButton.Active() })
+	header.SetHeaderSuffix(pauseButton)
+	v.box.Append(header)
+
+	v.list.AddCSSClass("boxed-list")
+	v.list.SetSelectionMode(gtk.SelectionNone)
+	placeholder := gtk.NewLabel(i18n.T("Waiting for packets..."))
+	placeholder.AddCSSClass("dim-label")
+	placeholder.SetMarginTop(12)
+	placeholder.SetMarginBottom(12)
+	v.list.SetPlaceholder(placeholder)
+	v.box.Append(v.list)
+
+	// Only fetch while the tab is shown, the daemon is called over D-Bus
+	timer := glib.TimeoutAdd(debugViewRefreshMillis, func() bool {
+		if v.box.Mapped() && !v.paused {
+			v.refresh(toasts)
+		}
+		return true
+	})
+	v.box.ConnectUnrealize(func() { glib.SourceRemove(timer) })
+
+	return v
+}
+
+// refresh fetches the packets received since the last refresh in the background and adds them
+func (v *debugView) refresh(toasts *toaster) {
+	if v.fetching {
+		return
+	}
+	v.fetching = true
+	after := v.lastSeq
+	go func() {
+		packets := v.backend.PacketLog(after)
+		if len(packets) > debugViewRows {
+			packets = packets[len(packets)-debugViewRows:]
+		}
+		glib.IdleAdd(func() {
+			v.fetching = false
+			for _, packet := range packets {
+				v.add(packet, toasts)
+			}
+		})
+	}()
+}
+
+// add shows a packet at the top of the list
+func (v *debugView) add(packet podstate.LoggedPacket, toasts *toaster) {
+	if packet.Seq <= v.lastSeq {
+		return
+	}
+	v.lastSeq = packet.Seq
+
+	title := fmt.Sprintf("%s • %s • %s", packet.Time.Format("15:04:05.000"), packetKindName(packet.Kind), packet.Address)
+	if packet.RSSI != 0 {
+		title += fmt.Sprintf(" • %d dBm", packet.RSSI)
+	}
+	pr := &packetRow{row: adw.NewExpanderRow()}
+	pr.row.SetTitle(title)
+	pr.row.AddCSSClass("monospace")
+
+	// Field map and parse result. Annotations of advertisements refer to the payload after
+	// the type and length bytes.
+	var description string
+	if packet.Kind == podstate.PacketBLE {
+		description = annotator.DescribeAdvertisement(packet.Data)
+		payload := packet.Data[min(2, len(packet.Data)):]
+		pr.update(payload, hex.EncodeToString(packet.Data), annotator.ProximityPayload(payload))
+	} else {
+		description = annotator.DescribeAAPPacket(packet.Data)
+		pr.update(packet.Data, hex.EncodeToString(packet.Data), annotator.AAPPacket(packet.Data))
+	}
+	result := adw.NewActionRow()
+	result.SetTitle(i18n.T("Parse result"))
+	result.SetSubtitle(glib.MarkupEscapeText(description))
+	result.SetSubtitleSelectable(true)
+	pr.row.AddRow(result)
+
+	copyButton := gtk.NewButtonFromIconName("edit-copy-symbolic")
+	copyButton.SetTooltipText(i18n.T("Copy as test vector"))
+	setAccessibleLabel(copyButton, i18n.T("Copy as test vector"))
+	copyButton.SetVAlign(gtk.AlignCenter)
+	copyButton.AddCSSClass("flat")
+	copyButton.ConnectClicked(func() {
+		line, err := testVector(packet)
+		if err != nil {
+			log.Printf("Warning: Failed to encode test vector: %v", err)
+			return
+		}
+		copyButton.Clipboard().SetText(line)
+		toasts.show(i18n.T("Test vector copied"))
+	})
+	pr.row.AddSuffix(copyButton)
+
+	v.list.Prepend(pr.row)
+	v.rows = append([]*adw.ExpanderRow{pr.row}, v.rows...)
+	if len(v.rows) > debugViewRows {
+		v.list.Remove(v.rows[len(v.rows)-1])
+		v.rows = v.rows[:len(v.rows)-1]
+	}
+}
+
+// packetKindName names the protocol of a packet
+func packetKindName(kind podstate.PacketKind) string {
+	if kind == podstate.PacketBLE {
+		return "BLE"
+	}
+	return "AAP"
+}
+
+// testVector encodes a packet as a line of the parser corpus: a BLE recording frame for
+// internal/ble/testdata, or an AAP capture packet for internal/aap/testdata
+func testVector(packet podstate.LoggedPacket) (string, error) {
+	var v interface{}
+	if packet.Kind == podstate.PacketBLE {
+		v = ble.RecordedFrame{Time: packet.Time, Address: packet.Address, RSSI: packet.RSSI, Data: packet.Data}
+	} else {
+		v = aap.CapturedPacket{Time: packet.Time, Direction: aap.CaptureInbound, Data: packet.Data}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
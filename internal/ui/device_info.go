@@ -0,0 +1,232 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/ble"
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// DeviceInfoWidgets holds the rows of the device info group
+type DeviceInfoWidgets struct {
+	RealMac   *copyableRow
+	BLEMac    *copyableRow
+	Signal    *adw.ActionRow
+	Bluetooth *bluetoothRow
+}
+
+// bluetoothRow shows whether the device is connected, with a button to connect or disconnect it
+type bluetoothRow struct {
+	row       *adw.ActionRow
+	button    *gtk.Button
+	macAddr   string // Real MAC address of the shown device, "" if unknown
+	connected bool
+	busy      bool              // A connect or disconnect call is running
+	progress  map[string]string // MAC address -> progress of an AAP connection, e.g. "Connecting..."
+}
+
+// copyableRow is an action row showing a value with a button to copy it to the clipboard
+type copyableRow struct {
+	row        *adw.ActionRow
+	copyButton *gtk.Button
+	value      string
+}
+
+// createDeviceInfoGroup builds the "Device" group showing the real and the current BLE MAC address
+// and the Bluetooth connection. The addresses are the values the debug tools need (e.g. debug_aap
+// and debug_decrypt).
+func createDeviceInfoGroup(podCoord podstate.Backend) (*adw.PreferencesGroup, *DeviceInfoWidgets) {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle(i18n.T("Device"))
+
+	widgets := &DeviceInfoWidgets{
+		RealMac: newCopyableRow(group, i18n.T("MAC Address"), i18n.T("Permanent address, used for AAP connections")),
+		BLEMac:  newCopyableRow(group, i18n.T("BLE Address"), i18n.T("Current randomized address of advertisements")),
+	}
+
+	widgets.Signal = adw.NewActionRow()
+	widgets.Signal.SetTitle(i18n.T("Signal"))
+	widgets.Signal.SetTooltipText(i18n.T("Strength of the last BLE advertisement, the estimated distance and what the AirPods report doing"))
+	widgets.Signal.SetSubtitle("--")
+	widgets.Signal.AddCSSClass("property")
+	group.Add(widgets.Signal)
+
+	widgets.Bluetooth = newBluetoothRow(group, podCoord)
+
+	return group, widgets
+}
+
+// newBluetoothRow adds the Bluetooth connection row to the group
+func newBluetoothRow(group *adw.PreferencesGroup, podCoord podstate.Backend) *bluetoothRow {
+	row := adw.NewActionRow()
+	row.SetTitle(i18n.T("Bluetooth"))
+	row.SetSubtitle("--")
+
+	button := gtk.NewButton()
+	button.SetLabel(i18n.T("Connect"))
+	button.SetVAlign(gtk.AlignCenter)
+	button.SetSensitive(false)
+	row.AddSuffix(button)
+
+	br := &bluetoothRow{row: row, button: button, progress: make(map[string]string)}
+	podCoord.Subscribe(func(event podstate.Event) {
+		switch e := event.(type) {
+		case podstate.DeviceConnecting:
+			glib.IdleAdd(func() { br.setProgress(e.Address, i18n.T("Connecting...")) })
+		case podstate.DeviceConnectFailed:
+			glib.IdleAdd(func() { br.setProgress(e.Address, i18n.T("Not connected (connection failed)")) })
+		case podstate.DeviceConnected:
+			glib.IdleAdd(func() { br.setProgress(e.Address, "") })
+		case podstate.DeviceDisconnected:
+			glib.IdleAdd(func() { br.setProgress(e.Address, "") })
+		case podstate.DeviceSuspended:
+			glib.IdleAdd(func() { br.setProgress(e.Address, i18n.T("Not connected (in the closed case)")) })
+		}
+	})
+	button.ConnectClicked(func() {
+		macAddr, connect := br.macAddr, !br.connected
+		if macAddr == "" {
+			return
+		}
+		br.busy = true
+		button.SetSensitive(false)
+		if connect {
+			button.SetLabel(i18n.T("Connecting..."))
+		} else {
+			button.SetLabel(i18n.T("Disconnecting..."))
+		}
+
+		// Connecting can take several seconds, don't block the UI
+		go func() {
+			var err error
+			if connect {
+				err = podCoord.ConnectBluetooth(macAddr)
+			} else {
+				err = podCoord.DisconnectBluetooth(macAddr)
+			}
+
+			glib.IdleAdd(func() {
+				br.busy = false
+				br.set(br.macAddr, br.connected)
+				if err != nil {
+					log.Printf("Bluetooth connection change of %s failed: %v", macAddr, err)
+					button.SetLabel(i18n.T("Error - Retry"))
+					button.SetTooltipText(err.Error())
+				} else {
+					button.SetTooltipText("")
+				}
+			})
+		}()
+	})
+
+	group.Add(row)
+	return br
+}
+
+// set shows the connection state of a device, macAddr is "" if its real address is unknown
+func (br *bluetoothRow) set(macAddr string, connected bool) {
+	br.macAddr = macAddr
+	br.connected = connected
+	if br.busy {
+		return
+	}
+
+	switch {
+	case macAddr == "":
+		br.row.SetSubtitle(i18n.T("Unknown (encryption key required)"))
+	case connected:
+		br.row.SetSubtitle(i18n.T("Connected"))
+	case br.progress[macAddr] != "":
+		br.row.SetSubtitle(br.progress[macAddr])
+	default:
+		br.row.SetSubtitle(i18n.T("Not connected"))
+	}
+	if connected {
+		br.button.SetLabel(i18n.T("Disconnect"))
+	} else {
+		br.button.SetLabel(i18n.T("Connect"))
+	}
+	br.button.SetSensitive(macAddr != "")
+}
+
+// setProgress shows the progress of an AAP connection to a device, "" when it is done
+func (br *bluetoothRow) setProgress(macAddr string, progress string) {
+	if progress == "" {
+		delete(br.progress, macAddr)
+	} else {
+		br.progress[macAddr] = progress
+	}
+	if macAddr == br.macAddr {
+		br.set(br.macAddr, br.connected)
+	}
+}
+
+// newCopyableRow adds a copyable row to the group
+func newCopyableRow(group *adw.PreferencesGroup, title string, tooltip string) *copyableRow {
+	row := adw.NewActionRow()
+	row.SetTitle(title)
+	row.SetTooltipText(tooltip)
+	row.SetSubtitle("--")
+	row.SetSubtitleSelectable(true)
+	row.AddCSSClass("property")
+
+	copyButton := gtk.NewButtonFromIconName("edit-copy-symbolic")
+	copyButton.SetTooltipText(i18n.T("Copy to clipboard"))
+	setAccessibleLabel(copyButton, i18n.Tf("Copy %s", title))
+	copyButton.SetVAlign(gtk.AlignCenter)
+	copyButton.AddCSSClass("flat")
+	copyButton.SetSensitive(false)
+	row.AddSuffix(copyButton)
+
+	cr := &copyableRow{row: row, copyButton: copyButton}
+	copyButton.ConnectClicked(func() {
+		if cr.value != "" {
+			copyButton.Clipboard().SetText(cr.value)
+		}
+	})
+
+	group.Add(row)
+	return cr
+}
+
+// set shows the value, or the placeholder if the value is empty (copying is disabled then)
+func (cr *copyableRow) set(value string, placeholder string) {
+	cr.value = value
+	if value == "" {
+		cr.row.SetSubtitle(placeholder)
+	} else {
+		cr.row.SetSubtitle(value)
+	}
+	cr.copyButton.SetSensitive(value != "")
+}
+
+// updateDeviceInfo shows the MAC addresses and the connection of the device state
+func updateDeviceInfo(widgets *DeviceInfoWidgets, state *podstate.PodState) {
+	// Without an encryption key, BLE states can't be attributed to the real device
+	// and carry the random address as RealMac
+	realMac := state.RealMac
+	if state.Source == podstate.DataSourceBLE && realMac == state.CurrentBLEMac {
+		realMac = ""
+	}
+	widgets.RealMac.set(realMac, i18n.T("Unknown (encryption key required)"))
+
+	widgets.BLEMac.set(state.CurrentBLEMac, i18n.T("Not available (connected via AAP)"))
+
+	signal := "--"
+	if state.RSSI != 0 {
+		signal = fmt.Sprintf("%d dBm (%s)", state.RSSI, state.Proximity)
+	}
+	if _, ok := state.Sources[podstate.FieldAdvertisement]; ok {
+		signal += ", " + ble.DecodeConnectionState(state.ConnectionState)
+	}
+	widgets.Signal.SetSubtitle(signal)
+
+	// Devices connected via Bluetooth have an AAP session
+	widgets.Bluetooth.set(realMac, state.Source == podstate.DataSourceAAP)
+}
@@ -0,0 +1,178 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/ble"
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// deviceInfoPage is the "Info" tab showing what is known about the shown device: its model
+// with a product image, the firmware and serial numbers reported via AAP, where the shown
+// data comes from, and the settings made for it
+type deviceInfoPage struct {
+	page    *adw.PreferencesPage
+	artwork *artworkImage
+	title   *gtk.Label
+
+	model        *adw.ActionRow
+	color        *adw.ActionRow
+	modelNumber  *adw.ActionRow
+	firmware     *adw.ActionRow
+	hardware     *adw.ActionRow
+	serial       *copyableRow
+	leftSerial   *copyableRow
+	rightSerial  *copyableRow
+	serialsGroup *adw.PreferencesGroup
+
+	macAddr  *copyableRow
+	source   *adw.ActionRow
+	accuracy *adw.ActionRow
+
+	settings *deviceSettingsGroup
+}
+
+// createDeviceInfoPage builds the info page, filled in by update
+func createDeviceInfoPage(toasts *toaster) *deviceInfoPage {
+	p := &deviceInfoPage{page: adw.NewPreferencesPage()}
+
+	// Product image and name
+	header := adw.NewPreferencesGroup()
+	headerBox := gtk.NewBox(gtk.OrientationVertical, 12)
+	headerBox.SetHAlign(gtk.AlignCenter)
+	p.artwork = newArtworkImage("case", "audio-headphones-symbolic", 128)
+	headerBox.Append(p.artwork.image)
+	p.title = gtk.NewLabel(i18n.T("No device"))
+	p.title.AddCSSClass("title-2")
+	headerBox.Append(p.title)
+	header.Add(headerBox)
+	p.page.Add(header)
+
+	model := adw.NewPreferencesGroup()
+	model.SetTitle(i18n.T("Model"))
+	p.model = newPropertyRow(model, i18n.T("Model"))
+	p.color = newPropertyRow(model, i18n.T("Color"))
+	p.modelNumber = newPropertyRow(model, i18n.T("Model Number"))
+	p.firmware = newPropertyRow(model, i18n.T("Firmware"))
+	p.hardware = newPropertyRow(model, i18n.T("Hardware Revision"))
+	p.page.Add(model)
+
+	p.serialsGroup = adw.NewPreferencesGroup()
+	p.serialsGroup.SetTitle(i18n.T("Serial Numbers"))
+	p.serialsGroup.SetDescription(i18n.T("Reported when the AirPods are connected"))
+	p.serial = newCopyableRow(p.serialsGroup, i18n.T("Case"), i18n.T("Serial number of the case, or of headphones without a case"))
+	p.leftSerial = newCopyableRow(p.serialsGroup, i18n.T("Left AirPod"), "")
+	p.rightSerial = newCopyableRow(p.serialsGroup, i18n.T("Right AirPod"), "")
+	p.page.Add(p.serialsGroup)
+
+	data := adw.NewPreferencesGroup()
+	data.SetTitle(i18n.T("Data"))
+	p.macAddr = newCopyableRow(data, i18n.T("MAC Address"), i18n.T("Permanent address, used for AAP connections"))
+	p.source = newPropertyRow(data, i18n.T("Source"))
+	p.accuracy = newPropertyRow(data, i18n.T("Battery Accuracy"))
+	p.page.Add(data)
+
+	p.settings = createDeviceSettingsGroup(toasts)
+	p.page.Add(p.settings.group)
+
+	return p
+}
+
+// newPropertyRow adds a row showing a value as its subtitle
+func newPropertyRow(group *adw.PreferencesGroup, title string) *adw.ActionRow {
+	row := adw.NewActionRow()
+	row.SetTitle(title)
+	row.SetSubtitle("--")
+	row.SetSubtitleSelectable(true)
+	row.AddCSSClass("property")
+	group.Add(row)
+	return row
+}
+
+// update shows a device state
+func (p *deviceInfoPage) update(state *podstate.PodState) {
+	p.artwork.part = "case"
+	if !state.Capabilities.HasCase {
+		p.artwork.part = "headphones"
+	}
+	p.artwork.update(state)
+
+	name := state.DisplayName()
+	if name == "" {
+		name = i18n.T("AirPods")
+	}
+	p.title.SetText(name)
+
+	p.model.SetSubtitle("--")
+	p.color.SetSubtitle("--")
+	if state.DeviceModel != 0 {
+		p.model.SetSubtitle(fmt.Sprintf("%s (0x%04X)", ble.DecodeModelName(state.DeviceModel), state.DeviceModel))
+		p.color.SetSubtitle(ble.DecodeColor(state.Color))
+	}
+
+	info := state.DeviceInfo
+	if info == nil {
+		info = &aap.DeviceInfo{}
+	}
+	p.modelNumber.SetSubtitle(orPlaceholder(info.ModelNumber))
+	p.firmware.SetSubtitle(orPlaceholder(info.FirmwareVersion))
+	p.hardware.SetSubtitle(orPlaceholder(info.HardwareRevision))
+	if state.Capabilities.HasCase {
+		p.serial.row.SetTitle(i18n.T("Case"))
+	} else {
+		p.serial.row.SetTitle(i18n.T("Headphones"))
+	}
+	p.serial.set(info.SerialNumber, "--")
+	p.leftSerial.set(info.LeftSerialNumber, "--")
+	p.rightSerial.set(info.RightSerialNumber, "--")
+	p.leftSerial.row.SetVisible(!state.Capabilities.SingleBattery())
+	p.rightSerial.row.SetVisible(!state.Capabilities.SingleBattery())
+
+	// Without an encryption key, BLE states carry the random address as RealMac
+	realMac := state.RealMac
+	if state.Source == podstate.DataSourceBLE && realMac == state.CurrentBLEMac {
+		realMac = ""
+	}
+	p.macAddr.set(realMac, i18n.T("Unknown (encryption key required)"))
+	p.settings.show(realMac)
+	p.source.SetSubtitle(dataSourceDescription(state))
+	p.accuracy.SetSubtitle(batteryAccuracy(state))
+}
+
+// dataSourceDescription describes where the battery levels of a state come from
+func dataSourceDescription(state *podstate.PodState) string {
+	switch state.Source {
+	case podstate.DataSourceAAP:
+		return i18n.T("AAP connection")
+	case podstate.DataSourceBLE:
+		if state.Decrypted {
+			return i18n.T("BLE advertisements (decrypted)")
+		}
+		return i18n.T("BLE advertisements")
+	case podstate.DataSourceStored:
+		return i18n.Tf("Last known state from %s", formatLastSeen(state.LastSeen))
+	default:
+		return state.Source.String()
+	}
+}
+
+// batteryAccuracy describes how accurate the battery levels of a state are
+func batteryAccuracy(state *podstate.PodState) string {
+	if state.Source == podstate.DataSourceAAP || state.Decrypted {
+		return "1%"
+	}
+	return i18n.T("10% (the encryption key gives 1%)")
+}
+
+// orPlaceholder returns the value, or "--" if it is empty
+func orPlaceholder(value string) string {
+	if value == "" {
+		return "--"
+	}
+	return value
+}
@@ -0,0 +1,103 @@
+package ui
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// deviceSelector chooses the device shown by the window when several are known, e.g. two
+// pairs of AirPods or AirPods and Beats. Its first entry follows podstate.SelectDevice.
+type deviceSelector struct {
+	dropDown *gtk.DropDown
+	list     *gtk.StringList
+	entries  []string // Labels after "Automatic", to skip updates that change nothing
+	macs     []string // MAC address of each entry after "Automatic"
+	selected string   // MAC address chosen by the user, "" to follow SelectDevice
+	updating bool     // Set while the entries are replaced, so it isn't taken as a choice
+	onChange func()   // Called when the user chose another device
+}
+
+// newDeviceSelector creates the dropdown, hidden until there is more than one device
+func newDeviceSelector() *deviceSelector {
+	ds := &deviceSelector{list: gtk.NewStringList([]string{i18n.T("Automatic")})}
+	ds.dropDown = gtk.NewDropDown(ds.list, nil)
+	ds.dropDown.SetHAlign(gtk.AlignCenter)
+	ds.dropDown.SetTooltipText(i18n.T("Device shown in this window"))
+	ds.dropDown.SetVisible(false)
+
+	ds.dropDown.Connect("notify::selected", func() {
+		if ds.updating {
+			return
+		}
+		ds.selected = ""
+		if i := int(ds.dropDown.Selected()) - 1; i >= 0 && i < len(ds.macs) {
+			ds.selected = ds.macs[i]
+		}
+		if ds.onChange != nil {
+			ds.onChange()
+		}
+	})
+	return ds
+}
+
+// update lists the devices of the states, sorted by name and address. The choice falls back
+// to automatic when the chosen device is gone.
+func (ds *deviceSelector) update(states map[string]*podstate.PodState) {
+	macs := make([]string, 0, len(states))
+	for macAddr := range states {
+		macs = append(macs, macAddr)
+	}
+	sort.Slice(macs, func(i, j int) bool {
+		a, b := deviceLabel(macs[i], states[macs[i]]), deviceLabel(macs[j], states[macs[j]])
+		if a != b {
+			return a < b
+		}
+		return macs[i] < macs[j]
+	})
+	entries := make([]string, len(macs))
+	for i, macAddr := range macs {
+		entries[i] = deviceLabel(macAddr, states[macAddr])
+	}
+
+	if _, ok := states[ds.selected]; !ok {
+		ds.selected = ""
+	}
+	ds.dropDown.SetVisible(len(macs) > 1)
+	if slices.Equal(entries, ds.entries) && slices.Equal(macs, ds.macs) {
+		return
+	}
+
+	ds.updating = true
+	ds.list.Splice(1, uint(len(ds.entries)), entries)
+	ds.entries, ds.macs = entries, macs
+	position := uint(0)
+	for i, macAddr := range macs {
+		if macAddr == ds.selected {
+			position = uint(i + 1)
+		}
+	}
+	ds.dropDown.SetSelected(position)
+	ds.updating = false
+}
+
+// current returns the device to show: the chosen one, or the one SelectDevice picks
+func (ds *deviceSelector) current(states map[string]*podstate.PodState) (string, *podstate.PodState) {
+	if state, ok := states[ds.selected]; ok {
+		return ds.selected, state
+	}
+	return podstate.SelectDevice(states)
+}
+
+// deviceLabel returns the entry of a device, e.g. "AirPods Pro (AA:BB:CC:DD:EE:FF)"
+func deviceLabel(macAddr string, state *podstate.PodState) string {
+	name := state.DisplayName()
+	if name == "" {
+		name = i18n.T("Unknown device")
+	}
+	return name + " (" + macAddr + ")"
+}
@@ -0,0 +1,184 @@
+package ui
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/config"
+	"linuxpods/internal/i18n"
+)
+
+// deviceSettingsGroup is the "Device Settings" group of the info page: the name of the shown
+// device, whether it is connected automatically and ignoring it. The settings are saved per
+// MAC address in the config file, the coordinator applies them when the file is reloaded.
+type deviceSettingsGroup struct {
+	group       *adw.PreferencesGroup
+	name        *adw.EntryRow
+	autoConnect *gtk.Switch
+
+	macAddr  string // Uppercase MAC address of the shown device, "" if unknown
+	updating bool   // The widgets are being set from the config file, don't save them back
+}
+
+// createDeviceSettingsGroup builds the device settings group, filled in by show
+func createDeviceSettingsGroup(toasts *toaster) *deviceSettingsGroup {
+	g := &deviceSettingsGroup{group: adw.NewPreferencesGroup()}
+	g.group.SetTitle(i18n.T("Device Settings"))
+	g.group.SetDescription(i18n.T("Saved for this device in the config file"))
+
+	g.name = adw.NewEntryRow()
+	g.name.SetTitle(i18n.T("Name"))
+	g.name.SetShowApplyButton(true)
+	g.name.ConnectApply(func() {
+		if alias := strings.TrimSpace(g.name.Text()); alias != "" {
+			saveDeviceSetting(toasts, config.AliasesSection, g.macAddr, alias)
+		} else {
+			removeDeviceSetting(toasts, config.AliasesSection, g.macAddr)
+		}
+	})
+	g.group.Add(g.name)
+
+	autoConnectRow := adw.NewActionRow()
+	autoConnectRow.SetTitle(i18n.T("Connect Automatically"))
+	autoConnectRow.SetSubtitle(i18n.T("Open the AAP connection for exact battery levels and controls when the device connects"))
+	g.autoConnect = gtk.NewSwitch()
+	g.autoConnect.SetVAlign(gtk.AlignCenter)
+	autoConnectRow.AddSuffix(g.autoConnect)
+	autoConnectRow.SetActivatableWidget(g.autoConnect)
+	g.autoConnect.Connect("notify::active", func() {
+		if !g.updating {
+			saveDeviceSetting(toasts, config.AutoConnectSection, g.macAddr, g.autoConnect.Active())
+		}
+	})
+	g.group.Add(autoConnectRow)
+
+	ignoreRow := adw.NewActionRow()
+	ignoreRow.SetTitle(i18n.T("Ignore Device"))
+	ignoreRow.SetSubtitle(i18n.T("Neither show nor connect to this device. Settings → Ignored Devices brings it back."))
+	ignoreButton := gtk.NewButton()
+	ignoreButton.SetLabel(i18n.T("Ignore"))
+	ignoreButton.SetVAlign(gtk.AlignCenter)
+	ignoreButton.AddCSSClass("destructive-action")
+	ignoreButton.ConnectClicked(func() {
+		if saveDeviceSetting(toasts, config.IgnoredSection, g.macAddr, true) {
+			toasts.show(i18n.Tf("Ignoring %s", toasts.name(g.macAddr)))
+		}
+	})
+	ignoreRow.AddSuffix(ignoreButton)
+	g.group.Add(ignoreRow)
+
+	return g
+}
+
+// show shows the settings of a device. Without its MAC address, i.e. without its encryption
+// key, there is nothing to save the settings for and the group is hidden. The widgets are
+// only set when another device is shown, so a name being typed isn't replaced by updates.
+func (g *deviceSettingsGroup) show(macAddr string) {
+	macAddr = strings.ToUpper(macAddr)
+	g.group.SetVisible(macAddr != "")
+	if macAddr == g.macAddr {
+		return
+	}
+	g.macAddr = macAddr
+
+	device := config.LoadOrDefault(config.Path()).Device(macAddr)
+	g.updating = true
+	g.name.SetText(device.Alias)
+	g.autoConnect.SetActive(device.AutoConnect)
+	g.updating = false
+}
+
+// saveDeviceSetting saves a setting of a device to the config file and reports whether it
+// was saved. Failures are toasted.
+func saveDeviceSetting(toasts *toaster, section string, macAddr string, value interface{}) bool {
+	if err := config.SetValue(config.Path(), section, macAddr, value); err != nil {
+		log.Printf("Failed to save %s of %s: %v", section, macAddr, err)
+		toasts.show(glib.MarkupEscapeText(i18n.Tf("Error: %v", err)))
+		return false
+	}
+	return true
+}
+
+// removeDeviceSetting removes a setting of a device from the config file, so its default applies
+func removeDeviceSetting(toasts *toaster, section string, macAddr string) bool {
+	if err := config.RemoveValue(config.Path(), section, macAddr); err != nil {
+		log.Printf("Failed to remove %s of %s: %v", section, macAddr, err)
+		toasts.show(glib.MarkupEscapeText(i18n.Tf("Error: %v", err)))
+		return false
+	}
+	return true
+}
+
+// ignoredDevicesGroup is the "Ignored Devices" group of the settings, listing the ignored
+// devices with a button to stop ignoring each. It is filled from the config file whenever it
+// is shown, so devices ignored from the info page or in the file appear.
+type ignoredDevicesGroup struct {
+	group *adw.PreferencesGroup
+	rows  []*adw.ActionRow
+}
+
+// createIgnoredDevicesGroup builds the ignored devices group
+func createIgnoredDevicesGroup(toasts *toaster) *ignoredDevicesGroup {
+	g := &ignoredDevicesGroup{group: adw.NewPreferencesGroup()}
+	g.group.SetTitle(i18n.T("Ignored Devices"))
+	g.group.SetDescription(i18n.T("Devices that are neither shown nor connected to"))
+	g.group.ConnectMap(func() { g.reload(toasts) })
+	g.reload(toasts)
+	return g
+}
+
+// reload lists the devices that are ignored in the config file
+func (g *ignoredDevicesGroup) reload(toasts *toaster) {
+	for _, row := range g.rows {
+		g.group.Remove(row)
+	}
+	g.rows = nil
+
+	cfg := config.LoadOrDefault(config.Path())
+	var addresses []string
+	for macAddr, ignored := range cfg.Ignored {
+		if ignored {
+			addresses = append(addresses, macAddr)
+		}
+	}
+	sort.Strings(addresses)
+
+	for _, macAddr := range addresses {
+		macAddr := macAddr
+		row := adw.NewActionRow()
+		row.SetTitle(macAddr)
+		// The name from the config file, or the one the device had while it was shown
+		name := cfg.Aliases[macAddr]
+		if name == "" {
+			name = toasts.names[macAddr]
+		}
+		if name != "" {
+			row.SetTitle(glib.MarkupEscapeText(name))
+			row.SetSubtitle(macAddr)
+		}
+		button := gtk.NewButton()
+		button.SetLabel(i18n.T("Stop Ignoring"))
+		button.SetVAlign(gtk.AlignCenter)
+		button.ConnectClicked(func() {
+			if removeDeviceSetting(toasts, config.IgnoredSection, macAddr) {
+				g.reload(toasts)
+			}
+		})
+		row.AddSuffix(button)
+		g.group.Add(row)
+		g.rows = append(g.rows, row)
+	}
+
+	if len(addresses) == 0 {
+		row := adw.NewActionRow()
+		row.SetTitle(i18n.T("No ignored devices"))
+		row.AddCSSClass("dim-label")
+		g.group.Add(row)
+		g.rows = append(g.rows, row)
+	}
+}
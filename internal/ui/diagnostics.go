@@ -0,0 +1,179 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/annotator"
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// diagnosticsRefreshSeconds is how often the diagnostics view polls the coordinator metrics
+const diagnosticsRefreshSeconds = 2
+
+// createDiagnosticsView builds the Diagnostics tab showing scanner and coordinator metrics
+func createDiagnosticsView(podCoord podstate.Backend) *gtk.Box {
+	diagnosticsBox := gtk.NewBox(gtk.OrientationVertical, 20)
+	diagnosticsBox.SetMarginTop(20)
+	diagnosticsBox.SetMarginBottom(20)
+	diagnosticsBox.SetMarginStart(20)
+	diagnosticsBox.SetMarginEnd(20)
+
+	// BLE scanner throughput
+	scannerGroup := adw.NewPreferencesGroup()
+	scannerGroup.SetTitle(i18n.T("BLE Scanner"))
+	scannerGroup.SetDescription(i18n.T("Apple Continuity advertisement throughput"))
+
+	adsPerSecond := addMetricRow(scannerGroup, i18n.T("Advertisements/sec"))
+	adsTotal := addMetricRow(scannerGroup, i18n.T("Advertisements received"))
+	parseSuccesses := addMetricRow(scannerGroup, i18n.T("Parse successes"))
+	parseFailures := addMetricRow(scannerGroup, i18n.T("Parse failures"))
+
+	diagnosticsBox.Append(scannerGroup)
+
+	// Coordinator processing
+	coordinatorGroup := adw.NewPreferencesGroup()
+	coordinatorGroup.SetTitle(i18n.T("Coordinator"))
+	coordinatorGroup.SetDescription(i18n.T("Decryption and state update processing"))
+
+	decryptAttempts := addMetricRow(coordinatorGroup, i18n.T("Decrypt attempts"))
+	decryptSuccesses := addMetricRow(coordinatorGroup, i18n.T("Decrypt successes"))
+	addressResolutions := addMetricRow(coordinatorGroup, i18n.T("Addresses resolved via IRK"))
+	notifications := addMetricRow(coordinatorGroup, i18n.T("State notifications"))
+	callbackLatency := addMetricRow(coordinatorGroup, i18n.T("Callback latency (last / avg / max)"))
+
+	diagnosticsBox.Append(coordinatorGroup)
+
+	// Unparsed AAP packets (rows are added as new opcodes appear)
+	aapGroup := adw.NewPreferencesGroup()
+	aapGroup.SetTitle(i18n.T("AAP Sensor Diagnostics"))
+	aapGroup.SetDescription(i18n.T("Packets not understood by LinuxPods, which may contain sensor data such as bud temperature"))
+	aapRows := make(map[string]*packetRow)
+
+	diagnosticsBox.Append(aapGroup)
+
+	// Last packet of each device (BLE advertisement or AAP battery packet), annotated
+	packetGroup := adw.NewPreferencesGroup()
+	packetGroup.SetTitle(i18n.T("Last Packet"))
+	packetGroup.SetDescription(i18n.T("Field map of the packet the current state was decoded from"))
+	packetRows := make(map[string]*packetRow)
+
+	diagnosticsBox.Append(packetGroup)
+
+	refresh := func() bool {
+		metrics := podCoord.Metrics()
+
+		adsPerSecond.SetText(fmt.Sprintf("%.1f", metrics.Scanner.AdvertisementsPerSecond))
+		adsTotal.SetText(fmt.Sprintf("%d", metrics.Scanner.Advertisements))
+		parseSuccesses.SetText(fmt.Sprintf("%d", metrics.Scanner.ParseSuccesses))
+		parseFailures.SetText(fmt.Sprintf("%d", metrics.Scanner.ParseFailures))
+
+		decryptAttempts.SetText(fmt.Sprintf("%d", metrics.DecryptAttempts))
+		decryptSuccesses.SetText(fmt.Sprintf("%d", metrics.DecryptSuccesses))
+		addressResolutions.SetText(fmt.Sprintf("%d", metrics.AddressResolutions))
+		notifications.SetText(fmt.Sprintf("%d", metrics.Notifications))
+		callbackLatency.SetText(fmt.Sprintf("%s / %s / %s",
+			formatLatency(metrics.LastCallbackLatency),
+			formatLatency(metrics.AvgCallbackLatency),
+			formatLatency(metrics.MaxCallbackLatency)))
+
+		for macAddr, packets := range podCoord.AAPDiagnostics() {
+			for _, packet := range packets {
+				key := fmt.Sprintf("%s/%02X", macAddr, packet.Opcode)
+				row, ok := aapRows[key]
+				if !ok {
+					row = newPacketRow(aapGroup, fmt.Sprintf("%s • Opcode 0x%02X", macAddr, packet.Opcode))
+					aapRows[key] = row
+				}
+				row.update(packet.Last, fmt.Sprintf("%d× • %s • %s", packet.Count,
+					packet.LastSeen.Format(time.TimeOnly), hex.EncodeToString(packet.Last)),
+					annotator.AAPPacket(packet.Last))
+			}
+		}
+
+		for macAddr, state := range podCoord.GetDeviceStates() {
+			row, ok := packetRows[macAddr]
+			if !ok {
+				row = newPacketRow(packetGroup, macAddr)
+				packetRows[macAddr] = row
+			}
+			annotations := annotator.ProximityPayload(state.RawData)
+			if state.Source == podstate.DataSourceAAP {
+				annotations = annotator.AAPPacket(state.RawData)
+			}
+			row.update(state.RawData, fmt.Sprintf("%s • %s", state.Source, hex.EncodeToString(state.RawData)), annotations)
+		}
+
+		return true // Keep polling
+	}
+	refresh()
+	glib.TimeoutSecondsAdd(diagnosticsRefreshSeconds, refresh)
+
+	return diagnosticsBox
+}
+
+// packetRow is an expander row showing a packet as hex, with one child row per annotated field
+type packetRow struct {
+	row      *adw.ExpanderRow
+	children []*adw.ActionRow
+	packet   []byte // Packet the child rows were created for
+}
+
+// newPacketRow adds a packet row with the given title to the group
+func newPacketRow(group *adw.PreferencesGroup, title string) *packetRow {
+	row := adw.NewExpanderRow()
+	row.SetTitle(title)
+	row.AddCSSClass("monospace")
+	group.Add(row)
+	return &packetRow{row: row}
+}
+
+// update shows a new packet. The field rows are only rebuilt if the packet changed.
+func (r *packetRow) update(packet []byte, subtitle string, annotations []annotator.Annotation) {
+	r.row.SetSubtitle(subtitle)
+	if r.children != nil && bytes.Equal(packet, r.packet) {
+		return
+	}
+	r.packet = append([]byte(nil), packet...)
+
+	for _, child := range r.children {
+		r.row.Remove(child)
+	}
+	r.children = r.children[:0]
+
+	for _, a := range annotations {
+		child := adw.NewActionRow()
+		child.SetTitle(a.Label())
+		child.SetSubtitle(strings.TrimSpace(a.Raw(packet) + "  " + a.Value))
+		r.row.AddRow(child)
+		r.children = append(r.children, child)
+	}
+}
+
+// addMetricRow adds a row with a value label suffix to the group and returns the label
+func addMetricRow(group *adw.PreferencesGroup, title string) *gtk.Label {
+	row := adw.NewActionRow()
+	row.SetTitle(title)
+
+	valueLabel := gtk.NewLabel("--")
+	valueLabel.AddCSSClass("dim-label")
+	valueLabel.AddCSSClass("numeric")
+	valueLabel.SetVAlign(gtk.AlignCenter)
+	row.AddSuffix(valueLabel)
+
+	group.Add(row)
+	return valueLabel
+}
+
+// formatLatency formats a callback latency with a precision suitable for display
+func formatLatency(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
@@ -0,0 +1,96 @@
+package ui
+
+import (
+	"log"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// bluetoothPollSeconds is how often the window checks if Bluetooth is turned on. BlueZ
+// doesn't reach the GUI through the daemon's signals, so the adapter is polled.
+const bluetoothPollSeconds = 5
+
+// emptyState is shown instead of the battery levels while no AirPods are known, with
+// guidance on what to do, and the banner offering to turn on Bluetooth while it is off
+type emptyState struct {
+	page    *adw.StatusPage
+	banner  *adw.Banner
+	backend podstate.Backend
+
+	powered bool // Last known adapter state, true until it is known
+}
+
+// createEmptyState builds the status page and the Bluetooth banner, and starts polling
+// the adapter state
+func createEmptyState(win *adw.ApplicationWindow, podCoord podstate.Backend) *emptyState {
+	e := &emptyState{
+		page:    adw.NewStatusPage(),
+		banner:  adw.NewBanner(i18n.T("Bluetooth is turned off")),
+		backend: podCoord,
+		powered: true,
+	}
+
+	pairButton := gtk.NewButtonWithLabel(i18n.T("Pair AirPods"))
+	pairButton.AddCSSClass("pill")
+	pairButton.AddCSSClass("suggested-action")
+	pairButton.SetHAlign(gtk.AlignCenter)
+	pairButton.ConnectClicked(func() {
+		ShowPairingWizard(&win.Window, podCoord)
+	})
+	e.page.SetChild(pairButton)
+
+	e.banner.SetButtonLabel(i18n.T("Turn on Bluetooth"))
+	e.banner.ConnectButtonClicked(e.powerOn)
+
+	e.show()
+	e.refresh()
+	timer := glib.TimeoutSecondsAdd(bluetoothPollSeconds, func() bool {
+		e.refresh()
+		return true
+	})
+	e.banner.ConnectUnrealize(func() { glib.SourceRemove(timer) })
+
+	return e
+}
+
+// refresh checks in the background if Bluetooth is turned on. The daemon is called over D-Bus.
+func (e *emptyState) refresh() {
+	go func() {
+		powered, err := e.backend.BluetoothPowered()
+		glib.IdleAdd(func() {
+			// Without an adapter there is nothing to turn on, the page still shows the guidance
+			e.powered = powered || err != nil
+			e.show()
+		})
+	}()
+}
+
+// powerOn turns on Bluetooth when the banner's button is clicked
+func (e *emptyState) powerOn() {
+	e.banner.SetRevealed(false)
+	go func() {
+		if err := e.backend.PowerOnBluetooth(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		glib.IdleAdd(e.refresh)
+	}()
+}
+
+// show shows the guidance matching the adapter state
+func (e *emptyState) show() {
+	e.banner.SetRevealed(!e.powered)
+	if !e.powered {
+		e.page.SetIconName("bluetooth-disabled-symbolic")
+		e.page.SetTitle(i18n.T("Bluetooth Is Off"))
+		e.page.SetDescription(i18n.T("Turn on Bluetooth to find your AirPods"))
+		return
+	}
+	e.page.SetIconName("audio-headphones-symbolic")
+	e.page.SetTitle(i18n.T("No AirPods Found"))
+	e.page.SetDescription(i18n.T("Open the case of your AirPods next to this computer, or pair new AirPods"))
+}
@@ -0,0 +1,158 @@
+package ui
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// createKeyImportGroup builds the "Monitor Device" group for importing keys of a device
+// that is never connected to this machine (e.g. keys retrieved with LibrePods on Android),
+// so its battery can be monitored from BLE advertisements alone
+func createKeyImportGroup(podCoord podstate.Backend) *adw.PreferencesGroup {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle(i18n.T("Monitor Device"))
+	group.SetDescription(i18n.T("Import keys retrieved elsewhere to monitor AirPods that are not connected to this computer"))
+
+	macRow := adw.NewEntryRow()
+	macRow.SetTitle(i18n.T("MAC Address"))
+	group.Add(macRow)
+
+	encKeyRow := adw.NewEntryRow()
+	encKeyRow.SetTitle(i18n.T("ENC_KEY (hex)"))
+	group.Add(encKeyRow)
+
+	irkRow := adw.NewEntryRow()
+	irkRow.SetTitle(i18n.T("IRK (hex, optional)"))
+	group.Add(irkRow)
+
+	statusRow := adw.NewActionRow()
+	statusRow.SetTitle(i18n.T("Import Keys"))
+
+	importButton := gtk.NewButton()
+	importButton.SetLabel(i18n.T("Import"))
+	importButton.SetVAlign(gtk.AlignCenter)
+	importButton.AddCSSClass("suggested-action")
+	statusRow.AddSuffix(importButton)
+	group.Add(statusRow)
+
+	importButton.ConnectClicked(func() {
+		encKey, err := parseHexKey(encKeyRow.Text())
+		if err != nil {
+			statusRow.SetSubtitle(i18n.Tf("Invalid ENC_KEY: %v", err))
+			return
+		}
+		irk, err := parseHexKey(irkRow.Text())
+		if err != nil {
+			statusRow.SetSubtitle(i18n.Tf("Invalid IRK: %v", err))
+			return
+		}
+
+		if err := podCoord.ImportKeys(strings.TrimSpace(macRow.Text()), encKey, irk); err != nil {
+			statusRow.SetSubtitle(i18n.Tf("Error: %v", err))
+			return
+		}
+
+		statusRow.SetSubtitle(i18n.T("Imported - the device appears once its advertisements are received"))
+		encKeyRow.SetText("")
+		irkRow.SetText("")
+	})
+
+	return group
+}
+
+// createKeyFileGroup builds the "Encryption Keys" group for exporting the keys of all devices
+// to a file and importing them, e.g. on another computer or from LibrePods
+func createKeyFileGroup(win *adw.ApplicationWindow, podCoord podstate.Backend) *adw.PreferencesGroup {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle(i18n.T("Encryption Keys"))
+	group.SetDescription(i18n.T("The key file contains the IRK and ENC_KEY of each device in the format used by LibrePods"))
+
+	exportRow := adw.NewActionRow()
+	exportRow.SetTitle(i18n.T("Export Keys"))
+	exportRow.SetSubtitle(i18n.T("Save the keys of all devices to a file"))
+	exportButton := gtk.NewButton()
+	exportButton.SetLabel(i18n.T("Export…"))
+	exportButton.SetVAlign(gtk.AlignCenter)
+	exportRow.AddSuffix(exportButton)
+	group.Add(exportRow)
+
+	importRow := adw.NewActionRow()
+	importRow.SetTitle(i18n.T("Import Keys"))
+	importRow.SetSubtitle(i18n.T("Load keys from a file"))
+	importButton := gtk.NewButton()
+	importButton.SetLabel(i18n.T("Import…"))
+	importButton.SetVAlign(gtk.AlignCenter)
+	importRow.AddSuffix(importButton)
+	group.Add(importRow)
+
+	exportButton.ConnectClicked(func() {
+		exportKeysFile(win, podCoord, func(path string, err error) {
+			if err != nil {
+				exportRow.SetSubtitle(i18n.Tf("Error: %v", err))
+			} else {
+				exportRow.SetSubtitle(i18n.Tf("Saved to %s", path))
+			}
+		})
+	})
+
+	importButton.ConnectClicked(func() {
+		dialog := gtk.NewFileDialog()
+		dialog.SetTitle(i18n.T("Import Keys"))
+		dialog.Open(context.Background(), &win.Window, func(res gio.AsyncResulter) {
+			file, err := dialog.OpenFinish(res)
+			if err != nil {
+				return // Canceled
+			}
+			path := file.Path()
+			go func() {
+				n, err := podCoord.ImportKeysFile(path)
+				glib.IdleAdd(func() {
+					if err != nil {
+						importRow.SetSubtitle(i18n.Tf("Error: %v", err))
+					} else {
+						importRow.SetSubtitle(i18n.Tf("Imported the keys of %d devices", n))
+					}
+				})
+			}()
+		})
+	})
+
+	return group
+}
+
+// parseHexKey parses a hex encoded key, ignoring spaces and colons. An empty string yields nil.
+func parseHexKey(s string) ([]byte, error) {
+	s = strings.NewReplacer(" ", "", ":", "").Replace(strings.TrimSpace(s))
+	if s == "" {
+		return nil, nil
+	}
+	return hex.DecodeString(s)
+}
+
+// exportKeysFile asks for a file and writes the keys of all devices to it. done is called
+// with the path and the result in the main loop, unless the dialog was canceled.
+func exportKeysFile(win *adw.ApplicationWindow, podCoord podstate.Backend, done func(path string, err error)) {
+	dialog := gtk.NewFileDialog()
+	dialog.SetTitle(i18n.T("Export Keys"))
+	dialog.SetInitialName("linuxpods-keys.json")
+	dialog.Save(context.Background(), &win.Window, func(res gio.AsyncResulter) {
+		file, err := dialog.SaveFinish(res)
+		if err != nil {
+			return // Canceled
+		}
+		path := file.Path()
+		go func() {
+			err := podCoord.ExportKeys(path)
+			glib.IdleAdd(func() { done(path, err) })
+		}()
+	})
+}
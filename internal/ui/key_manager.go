@@ -0,0 +1,220 @@
+package ui
+
+import (
+	"encoding/hex"
+	"log"
+	"sort"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// keyReader is implemented by backends running the coordinator in-process. The D-Bus client
+// doesn't implement it, keys are never sent over the bus. With the daemon, the keys are
+// exported to a file instead.
+type keyReader interface {
+	GetEncryptionKey(macAddr string) []byte
+}
+
+// keyRow is the row of a device in the "Device Keys" group. It expands to the actions on
+// the device's ENC_KEY: requesting it from the AirPods, pasting it, copying and deleting it.
+type keyRow struct {
+	macAddr       string
+	row           *adw.ExpanderRow
+	status        *gtk.Label
+	requestButton *gtk.Button
+	requesting    bool
+	pasteRow      *adw.EntryRow
+	storedRow     *adw.ActionRow
+}
+
+// createKeyManagerGroup builds the "Device Keys" group listing the known devices and whether
+// the encryption key for decrypting their BLE advertisements is stored
+func createKeyManagerGroup(win *adw.ApplicationWindow, podCoord podstate.Backend, toasts *toaster) *adw.PreferencesGroup {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle(i18n.T("Device Keys"))
+	group.SetDescription(i18n.T("The encryption key (ENC_KEY) decrypts the exact battery levels from BLE advertisements"))
+
+	placeholder := adw.NewActionRow()
+	placeholder.SetTitle(i18n.T("No devices found yet"))
+	group.Add(placeholder)
+
+	rows := make(map[string]*keyRow)
+	podCoord.Subscribe(podstate.OnStatesChanged(func(states map[string]*podstate.PodState) {
+		glib.IdleAdd(func() {
+			addresses := make([]string, 0, len(states))
+			for macAddr := range states {
+				addresses = append(addresses, macAddr)
+			}
+			sort.Strings(addresses)
+
+			for _, macAddr := range addresses {
+				r, ok := rows[macAddr]
+				if !ok {
+					r = newKeyRow(win, podCoord, toasts, macAddr)
+					rows[macAddr] = r
+					group.Add(r.row)
+				}
+				r.update(states[macAddr], podCoord.IsAAPConnected(macAddr))
+			}
+			for macAddr, r := range rows {
+				if _, ok := states[macAddr]; !ok {
+					group.Remove(r.row)
+					delete(rows, macAddr)
+				}
+			}
+			placeholder.SetVisible(len(rows) == 0)
+		})
+	}))
+
+	return group
+}
+
+// newKeyRow creates the row of a device
+func newKeyRow(win *adw.ApplicationWindow, podCoord podstate.Backend, toasts *toaster, macAddr string) *keyRow {
+	r := &keyRow{macAddr: macAddr, row: adw.NewExpanderRow()}
+	r.row.SetTitle(macAddr)
+
+	r.status = gtk.NewLabel("")
+	r.status.SetVAlign(gtk.AlignCenter)
+	r.row.AddSuffix(r.status)
+
+	// Retrieve the key over the AAP connection
+	requestRow := adw.NewActionRow()
+	requestRow.SetTitle(i18n.T("Request From AirPods"))
+	requestRow.SetSubtitle(i18n.T("Requires an AAP connection"))
+	r.requestButton = gtk.NewButtonWithLabel(i18n.T("Request Keys"))
+	r.requestButton.SetVAlign(gtk.AlignCenter)
+	r.requestButton.ConnectClicked(func() {
+		r.requesting = true
+		r.requestButton.SetSensitive(false)
+		r.requestButton.SetLabel(i18n.T("Requesting..."))
+
+		// Request keys in a goroutine to avoid blocking UI
+		go func() {
+			err := podCoord.RequestEncryptionKeys(macAddr)
+			glib.IdleAdd(func() {
+				r.requesting = false
+				r.requestButton.SetLabel(i18n.T("Request Keys"))
+				r.requestButton.SetSensitive(podCoord.IsAAPConnected(macAddr))
+				if err != nil {
+					log.Printf("Key retrieval from %s failed: %v", macAddr, err)
+					toasts.show(i18n.Tf("Failed to retrieve the keys of %s", toasts.name(macAddr)))
+				}
+			})
+		}()
+	})
+	requestRow.AddSuffix(r.requestButton)
+	r.row.AddRow(requestRow)
+
+	// Paste a key retrieved elsewhere, e.g. by LibrePods
+	r.pasteRow = adw.NewEntryRow()
+	r.pasteRow.SetTitle(i18n.T("Paste ENC_KEY (hex)"))
+	r.pasteRow.SetShowApplyButton(true)
+	r.pasteRow.ConnectApply(func() {
+		encKey, err := parseHexKey(r.pasteRow.Text())
+		if err == nil && encKey == nil {
+			return
+		}
+		if err == nil {
+			err = podCoord.SetEncryptionKey(macAddr, encKey)
+		}
+		if err != nil {
+			toasts.show(glib.MarkupEscapeText(i18n.Tf("Invalid ENC_KEY: %v", err)))
+			return
+		}
+		r.pasteRow.SetText("")
+	})
+	r.row.AddRow(r.pasteRow)
+
+	// The stored key, hidden until there is one
+	r.storedRow = adw.NewActionRow()
+	r.storedRow.SetTitle(i18n.T("Stored Key"))
+	if reader, ok := podCoord.(keyReader); ok {
+		copyButton := gtk.NewButtonFromIconName("edit-copy-symbolic")
+		copyButton.SetTooltipText(i18n.T("Copy to clipboard"))
+		setAccessibleLabel(copyButton, i18n.T("Copy to clipboard"))
+		copyButton.SetVAlign(gtk.AlignCenter)
+		copyButton.AddCSSClass("flat")
+		copyButton.ConnectClicked(func() {
+			encKey := reader.GetEncryptionKey(macAddr)
+			if encKey == nil {
+				toasts.show(i18n.T("The key is no longer stored"))
+				return
+			}
+			copyButton.Clipboard().SetText(hex.EncodeToString(encKey))
+			toasts.show(i18n.T("Key copied"))
+		})
+		r.storedRow.AddSuffix(copyButton)
+	} else {
+		saveButton := gtk.NewButtonFromIconName("document-save-symbolic")
+		saveButton.SetTooltipText(i18n.T("Export the keys to a file"))
+		setAccessibleLabel(saveButton, i18n.T("Export the keys to a file"))
+		saveButton.SetVAlign(gtk.AlignCenter)
+		saveButton.AddCSSClass("flat")
+		saveButton.ConnectClicked(func() {
+			exportKeysFile(win, podCoord, func(path string, err error) {
+				if err != nil {
+					toasts.show(glib.MarkupEscapeText(i18n.Tf("Error: %v", err)))
+					return
+				}
+				toasts.show(glib.MarkupEscapeText(i18n.Tf("Saved to %s", path)))
+			})
+		})
+		r.storedRow.AddSuffix(saveButton)
+	}
+	deleteButton := gtk.NewButtonFromIconName("user-trash-symbolic")
+	deleteButton.SetTooltipText(i18n.T("Delete the key"))
+	setAccessibleLabel(deleteButton, i18n.T("Delete the key"))
+	deleteButton.SetVAlign(gtk.AlignCenter)
+	deleteButton.AddCSSClass("flat")
+	deleteButton.AddCSSClass("destructive-action")
+	deleteButton.ConnectClicked(func() {
+		if err := podCoord.SetEncryptionKey(macAddr, nil); err != nil {
+			toasts.show(glib.MarkupEscapeText(i18n.Tf("Error: %v", err)))
+			return
+		}
+		toasts.show(i18n.Tf("Deleted the key of %s", toasts.name(macAddr)))
+	})
+	r.storedRow.AddSuffix(deleteButton)
+	r.row.AddRow(r.storedRow)
+
+	return r
+}
+
+// update shows the name, connection and key of a device
+func (r *keyRow) update(state *podstate.PodState, connected bool) {
+	subtitle := state.DisplayName()
+	if connected {
+		subtitle = joinNonEmpty(subtitle, i18n.T("Connected"))
+	} else if state.CurrentBLEMac != "" && state.CurrentBLEMac != r.macAddr {
+		// Show current BLE MAC if it's different from real MAC
+		subtitle = joinNonEmpty(subtitle, i18n.Tf("BLE: %s", state.CurrentBLEMac))
+	}
+	r.row.SetSubtitle(glib.MarkupEscapeText(subtitle))
+
+	hasKey := len(state.EncryptionKey) > 0
+	if hasKey {
+		r.status.SetText(i18n.T("Key stored"))
+		r.status.RemoveCSSClass("dim-label")
+		r.status.AddCSSClass("success")
+	} else {
+		r.status.SetText(i18n.T("No key"))
+		r.status.RemoveCSSClass("success")
+		r.status.AddCSSClass("dim-label")
+	}
+	r.storedRow.SetVisible(hasKey)
+	r.requestButton.SetSensitive(connected && !r.requesting)
+}
+
+// joinNonEmpty joins two parts of a subtitle with a bullet, leaving out an empty first part
+func joinNonEmpty(first string, second string) string {
+	if first == "" {
+		return second
+	}
+	return first + " • " + second
+}
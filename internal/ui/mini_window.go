@@ -0,0 +1,86 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// ActivateMiniWindow creates a small floating window with a compact battery summary.
+// It is used instead of the system tray on desktops without a StatusNotifier host.
+// onShowWindow is called when the user asks to open the main window.
+func ActivateMiniWindow(app *adw.Application, podCoord podstate.Backend, onShowWindow func()) *adw.Window {
+	win := adw.NewWindow()
+	win.SetApplication(&app.Application)
+	win.SetTitle("LinuxPods")
+	win.SetDefaultSize(260, -1)
+	win.SetResizable(false)
+
+	content := gtk.NewBox(gtk.OrientationVertical, 6)
+	content.SetMarginTop(12)
+	content.SetMarginBottom(12)
+	content.SetMarginStart(12)
+	content.SetMarginEnd(12)
+
+	batteryLabel := gtk.NewLabel(i18n.T("Searching for AirPods..."))
+	batteryLabel.AddCSSClass("title-4")
+	batteryLabel.AddCSSClass("numeric")
+	content.Append(batteryLabel)
+
+	openButton := gtk.NewButtonWithLabel(i18n.T("Open LinuxPods"))
+	openButton.AddCSSClass("pill")
+	openButton.SetHAlign(gtk.AlignCenter)
+	openButton.Connect("clicked", func() {
+		if onShowWindow != nil {
+			onShowWindow()
+		}
+	})
+	content.Append(openButton)
+
+	// Window handle allows moving the undecorated window by dragging its content
+	handle := gtk.NewWindowHandle()
+	handle.SetChild(content)
+	win.SetContent(handle)
+
+	podCoord.Subscribe(podstate.OnStatesChanged(func(states map[string]*podstate.PodState) {
+		glib.IdleAdd(func() {
+			// Show the connected or closest device
+			_, state := podstate.SelectDevice(states)
+			if state != nil && state.Stale {
+				batteryLabel.AddCSSClass("dim-label")
+			} else {
+				batteryLabel.RemoveCSSClass("dim-label")
+			}
+			switch {
+			case state == nil:
+				batteryLabel.SetText(i18n.T("Searching for AirPods..."))
+			case state.Capabilities.SingleBattery():
+				batteryLabel.SetText("🎧 " + formatMiniBattery(state.Battery, state.Charging))
+			default:
+				batteryLabel.SetText(i18n.Tf("L %s  R %s  C %s",
+					formatMiniBattery(state.LeftBattery, state.LeftCharging),
+					formatMiniBattery(state.RightBattery, state.RightCharging),
+					formatMiniBattery(state.CaseBattery, state.CaseCharging)))
+			}
+		})
+	}))
+
+	win.Present()
+	return win
+}
+
+// formatMiniBattery formats a battery level for the compact mini window
+func formatMiniBattery(level *int, charging bool) string {
+	if level == nil {
+		return "--"
+	}
+	if charging {
+		return fmt.Sprintf("%d%%⚡", *level)
+	}
+	return fmt.Sprintf("%d%%", *level)
+}
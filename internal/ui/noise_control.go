@@ -0,0 +1,166 @@
+package ui
+
+import (
+	"log"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// noiseControlModes are the modes of the noise control group in display order, with their
+// names and descriptions (translated where they are shown)
+var noiseControlModes = []struct {
+	mode aap.NoiseControlMode
+	name string
+	desc string
+}{
+	{aap.NoiseControlTransparency, i18n.N("Transparency"), i18n.N("Hear the world around you")},
+	{aap.NoiseControlAdaptive, i18n.N("Adaptive"), i18n.N("Automatically adjusts to your environment")},
+	{aap.NoiseControlANC, i18n.N("Noise Cancellation"), i18n.N("Block out background noise")},
+	{aap.NoiseControlOff, i18n.N("Off"), i18n.N("Noise control disabled")},
+}
+
+// noiseControlModeText returns the translated name and description of a noise control mode
+func noiseControlModeText(mode aap.NoiseControlMode) (name string, desc string) {
+	for _, opt := range noiseControlModes {
+		if opt.mode == mode {
+			return i18n.T(opt.name), i18n.T(opt.desc)
+		}
+	}
+	return mode.String(), ""
+}
+
+// noiseControlGroup is the "Noise Control" group, which shows and switches the noise control
+// mode of the device shown in the window. Switching requires an AAP connection.
+type noiseControlGroup struct {
+	group   *adw.PreferencesGroup
+	rows    map[aap.NoiseControlMode]*adw.ActionRow
+	buttons map[aap.NoiseControlMode]*gtk.CheckButton
+
+	macAddr   string                          // Real MAC address of the shown device, "" if unknown
+	connected bool                            // The shown device is connected via AAP
+	caps      aap.Capabilities                // Features of the shown device
+	modes     map[string]aap.NoiseControlMode // MAC address -> mode reported by the device
+	updating  bool                            // Set while the buttons show a reported mode, so it isn't sent back
+}
+
+// createNoiseControlGroup builds the noise control group with one radio button per mode
+func createNoiseControlGroup(podCoord podstate.Backend) *noiseControlGroup {
+	nc := &noiseControlGroup{
+		group:   adw.NewPreferencesGroup(),
+		rows:    make(map[aap.NoiseControlMode]*adw.ActionRow),
+		buttons: make(map[aap.NoiseControlMode]*gtk.CheckButton),
+		modes:   make(map[string]aap.NoiseControlMode),
+	}
+	nc.group.SetTitle(i18n.T("Noise Control"))
+
+	var firstButton *gtk.CheckButton
+	for _, opt := range noiseControlModes {
+		mode := opt.mode
+		row := adw.NewActionRow()
+		row.SetTitle(i18n.T(opt.name))
+		row.SetSubtitle(i18n.T(opt.desc))
+
+		button := gtk.NewCheckButton()
+		setAccessibleLabel(button, i18n.T(opt.name))
+		if firstButton == nil {
+			firstButton = button
+		} else {
+			button.SetGroup(firstButton)
+		}
+		button.Connect("toggled", func() {
+			if nc.updating || !button.Active() || nc.macAddr == "" {
+				return
+			}
+			nc.setMode(podCoord, nc.macAddr, mode)
+		})
+
+		row.AddPrefix(button)
+		row.SetActivatableWidget(button)
+		nc.group.Add(row)
+		nc.rows[mode] = row
+		nc.buttons[mode] = button
+	}
+
+	// Show the mode reported by the devices, including the ones already known
+	podCoord.RegisterSettingsCallback(func(macAddr string, cmd aap.ControlCommand) {
+		if cmd.ID != aap.ControlListeningMode {
+			return
+		}
+		mode, err := aap.ParseNoiseControlMode(&cmd)
+		if err != nil {
+			log.Printf("Invalid noise control mode from %s: %v", macAddr, err)
+			return
+		}
+		glib.IdleAdd(func() {
+			nc.modes[macAddr] = mode
+			nc.refresh()
+		})
+	})
+	podCoord.Subscribe(func(event podstate.Event) {
+		var macAddr string
+		switch e := event.(type) {
+		case podstate.DeviceDisconnected:
+			macAddr = e.Address
+		case podstate.DeviceSuspended:
+			macAddr = e.Address
+		default:
+			return
+		}
+		glib.IdleAdd(func() {
+			delete(nc.modes, macAddr)
+			nc.refresh()
+		})
+	})
+
+	nc.refresh()
+	return nc
+}
+
+// setMode switches the noise control mode of a device. The buttons show the mode the device
+// reports back, or return to the previous one if switching failed.
+func (nc *noiseControlGroup) setMode(podCoord podstate.Backend, macAddr string, mode aap.NoiseControlMode) {
+	go func() {
+		err := podCoord.SetNoiseMode(macAddr, mode)
+		if err != nil {
+			log.Printf("Failed to set noise control mode of %s: %v", macAddr, err)
+			glib.IdleAdd(nc.refresh)
+		}
+	}()
+}
+
+// show selects the device whose mode is shown
+func (nc *noiseControlGroup) show(state *podstate.PodState) {
+	nc.macAddr = state.RealMac
+	nc.connected = state.Source == podstate.DataSourceAAP
+	nc.caps = state.Capabilities
+	nc.refresh()
+}
+
+// refresh shows the mode of the shown device and which modes it supports
+func (nc *noiseControlGroup) refresh() {
+	nc.group.SetVisible(nc.caps.SupportsNoiseControl())
+	nc.rows[aap.NoiseControlTransparency].SetVisible(nc.caps.SupportsTransparency)
+	nc.rows[aap.NoiseControlAdaptive].SetVisible(nc.caps.SupportsAdaptive)
+	nc.rows[aap.NoiseControlANC].SetVisible(nc.caps.SupportsANC)
+
+	if nc.connected {
+		nc.group.SetDescription("")
+	} else {
+		nc.group.SetDescription(i18n.T("Connect the AirPods to change the mode"))
+	}
+
+	// No button is active until the device reported its mode
+	mode, known := nc.modes[nc.macAddr]
+	nc.updating = true
+	for m, button := range nc.buttons {
+		button.SetSensitive(nc.connected)
+		button.SetActive(known && m == mode)
+	}
+	nc.updating = false
+}
@@ -0,0 +1,254 @@
+package ui
+
+import (
+	"log"
+	"sync"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+
+	"linuxpods/internal/config"
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// LowBatteryNotifier sends a desktop notification when a battery of an own device drops
+// below the configured level. Each battery is notified about once until it is charged
+// above the level again.
+type LowBatteryNotifier struct {
+	app *adw.Application
+
+	mu       sync.Mutex
+	config   config.NotificationsConfig
+	notified map[string]bool // Batteries below the level that were notified about, by MAC address and battery
+	onChange func(config.NotificationsConfig)
+}
+
+// NewLowBatteryNotifier creates a notifier for the states of podCoord
+func NewLowBatteryNotifier(app *adw.Application, podCoord podstate.Backend, cfg config.NotificationsConfig) *LowBatteryNotifier {
+	n := &LowBatteryNotifier{
+		app:      app,
+		config:   cfg,
+		notified: make(map[string]bool),
+	}
+	podCoord.Subscribe(func(event podstate.Event) {
+		if e, ok := event.(podstate.BatteryChanged); ok {
+			n.update(e.Address, e.State)
+		}
+	})
+	return n
+}
+
+// Config returns the current notification settings
+func (n *LowBatteryNotifier) Config() config.NotificationsConfig {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.config
+}
+
+// SetConfig changes the notification settings, e.g. after the config file was reloaded
+func (n *LowBatteryNotifier) SetConfig(cfg config.NotificationsConfig) {
+	n.mu.Lock()
+	n.config = cfg
+	onChange := n.onChange
+	n.mu.Unlock()
+
+	if onChange != nil {
+		glib.IdleAdd(func() { onChange(cfg) })
+	}
+}
+
+// SetEnabled enables or disables the notifications for this session
+func (n *LowBatteryNotifier) SetEnabled(enabled bool) {
+	cfg := n.Config()
+	cfg.Enabled = enabled
+	n.SetConfig(cfg)
+}
+
+// setOnChange sets the function called on the GTK main thread when the settings change
+func (n *LowBatteryNotifier) setOnChange(onChange func(config.NotificationsConfig)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.onChange = onChange
+}
+
+// update checks the batteries of an own device against the configured level
+func (n *LowBatteryNotifier) update(macAddr string, state *podstate.PodState) {
+	if !state.IsOwnDevice || state.Stale {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	batteries := []struct {
+		name     string
+		level    *int
+		charging bool
+	}{
+		{i18n.N("Left AirPod"), state.LeftBattery, state.LeftCharging},
+		{i18n.N("Right AirPod"), state.RightBattery, state.RightCharging},
+		{i18n.N("Case"), state.CaseBattery, state.CaseCharging},
+		{i18n.N("Battery"), state.Battery, state.Charging},
+	}
+	for _, battery := range batteries {
+		if battery.level == nil {
+			continue
+		}
+		key := macAddr + "/" + battery.name
+		if *battery.level >= n.config.LowBattery {
+			delete(n.notified, key)
+			continue
+		}
+		if battery.charging || n.notified[key] {
+			continue
+		}
+		n.notified[key] = true
+		if n.config.Enabled {
+			n.send(macAddr, state, battery.name, *battery.level)
+		}
+	}
+}
+
+// send shows a low battery notification, replacing an earlier one of the same device
+func (n *LowBatteryNotifier) send(macAddr string, state *podstate.PodState, battery string, level int) {
+	name := state.DisplayName()
+	if name == "" {
+		name = i18n.T("AirPods")
+	}
+	title := i18n.Tf("%s battery low", name)
+	body := i18n.Tf("%s at %d%%", i18n.T(battery), level)
+
+	glib.IdleAdd(func() {
+		notification := gio.NewNotification(title)
+		notification.SetBody(body)
+		notification.SetIcon(gio.NewThemedIcon("battery-caution-symbolic"))
+		n.app.SendNotification("low-battery-"+macAddr, notification)
+	})
+}
+
+// DeviceSwitchedNotifier sends a desktop notification when a device switched to another
+// host, e.g. an iPhone, with a button to connect it to this machine again
+type DeviceSwitchedNotifier struct {
+	app      *adw.Application
+	podCoord podstate.Backend
+
+	mu     sync.Mutex
+	config config.NotificationsConfig
+}
+
+// NewDeviceSwitchedNotifier creates a notifier for the events of podCoord and adds the
+// app.reconnect-device action used by its notifications
+func NewDeviceSwitchedNotifier(app *adw.Application, podCoord podstate.Backend, cfg config.NotificationsConfig) *DeviceSwitchedNotifier {
+	n := &DeviceSwitchedNotifier{
+		app:      app,
+		podCoord: podCoord,
+		config:   cfg,
+	}
+
+	reconnect := gio.NewSimpleAction("reconnect-device", glib.NewVariantType("s"))
+	reconnect.ConnectActivate(func(parameter *glib.Variant) {
+		macAddr := parameter.String()
+		go func() {
+			if err := podCoord.ConnectBluetooth(macAddr); err != nil {
+				log.Printf("Warning: Failed to reconnect %s: %v", macAddr, err)
+			}
+		}()
+	})
+	app.AddAction(reconnect)
+
+	podCoord.Subscribe(func(event podstate.Event) {
+		if e, ok := event.(podstate.DeviceSwitchedAway); ok {
+			n.switched(e.Address)
+		}
+	})
+	return n
+}
+
+// SetConfig changes the notification settings, e.g. after the config file was reloaded
+func (n *DeviceSwitchedNotifier) SetConfig(cfg config.NotificationsConfig) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.config = cfg
+}
+
+// switched notifies about a device that switched to another host, if enabled
+func (n *DeviceSwitchedNotifier) switched(macAddr string) {
+	n.mu.Lock()
+	enabled := n.config.DeviceSwitched
+	n.mu.Unlock()
+	if !enabled {
+		return
+	}
+
+	name := i18n.T("AirPods")
+	if state, ok := n.podCoord.GetDeviceStates()[macAddr]; ok && state.DisplayName() != "" {
+		name = state.DisplayName()
+	}
+	title := i18n.Tf("%s switched to another device", name)
+
+	glib.IdleAdd(func() {
+		notification := gio.NewNotification(title)
+		notification.SetBody(i18n.T("They connected to a nearby iPhone, iPad or Mac."))
+		notification.SetIcon(gio.NewThemedIcon("audio-headphones-symbolic"))
+		notification.AddButtonWithTarget(i18n.T("Reconnect"), "app.reconnect-device", glib.NewVariantString(macAddr))
+		n.app.SendNotification("device-switched-"+macAddr, notification)
+	})
+}
+
+// LeftBehindNotifier sends a desktop notification when an own device was probably left
+// behind, i.e. its signal faded before it disappeared
+type LeftBehindNotifier struct {
+	app      *adw.Application
+	podCoord podstate.Backend
+
+	mu     sync.Mutex
+	config config.NotificationsConfig
+}
+
+// NewLeftBehindNotifier creates a notifier for the events of podCoord
+func NewLeftBehindNotifier(app *adw.Application, podCoord podstate.Backend, cfg config.NotificationsConfig) *LeftBehindNotifier {
+	n := &LeftBehindNotifier{
+		app:      app,
+		podCoord: podCoord,
+		config:   cfg,
+	}
+	podCoord.Subscribe(func(event podstate.Event) {
+		if e, ok := event.(podstate.DeviceLeftBehind); ok {
+			n.leftBehind(e.Address)
+		}
+	})
+	return n
+}
+
+// SetConfig changes the notification settings, e.g. after the config file was reloaded
+func (n *LeftBehindNotifier) SetConfig(cfg config.NotificationsConfig) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.config = cfg
+}
+
+// leftBehind notifies about a device that was left behind, if enabled
+func (n *LeftBehindNotifier) leftBehind(macAddr string) {
+	n.mu.Lock()
+	enabled := n.config.LeftBehind
+	n.mu.Unlock()
+	if !enabled {
+		return
+	}
+
+	name := i18n.T("AirPods")
+	if state, ok := n.podCoord.GetDeviceStates()[macAddr]; ok && state.DisplayName() != "" {
+		name = state.DisplayName()
+	}
+	title := i18n.Tf("You left your %s behind", name)
+
+	glib.IdleAdd(func() {
+		notification := gio.NewNotification(title)
+		notification.SetBody(i18n.T("Their signal faded and they are no longer nearby."))
+		notification.SetIcon(gio.NewThemedIcon("audio-headphones-symbolic"))
+		notification.SetPriority(gio.NotificationPriorityHigh)
+		n.app.SendNotification("left-behind-"+macAddr, notification)
+	})
+}
@@ -0,0 +1,155 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+const (
+	// pairingSearchTimeout is how long the wizard searches for AirPods in pairing mode
+	pairingSearchTimeout = 60 * time.Second
+
+	// pairingAAPTimeout is how long the wizard waits for the AAP connection after connecting
+	pairingAAPTimeout = 20 * time.Second
+)
+
+// pairingHint explains how to put AirPods in pairing mode (translated where it is shown)
+var pairingHint = i18n.N("Open the case next to this computer, then press and hold the button on the back " +
+	"until the status light flashes white.")
+
+// pairingWizard walks through pairing new AirPods: discovery, pairing, connecting and
+// retrieving the keys for reading the battery from BLE advertisements
+type pairingWizard struct {
+	win      *adw.Window
+	page     *adw.StatusPage
+	spinner  *gtk.Spinner
+	button   *gtk.Button
+	onButton func()
+
+	podCoord podstate.Backend
+}
+
+// ShowPairingWizard opens the pairing wizard. It is shown on the first start, when no AirPods
+// are paired yet, and from the settings.
+func ShowPairingWizard(parent *gtk.Window, podCoord podstate.Backend) {
+	w := &pairingWizard{podCoord: podCoord}
+
+	w.win = adw.NewWindow()
+	w.win.SetTitle(i18n.T("Pair AirPods"))
+	w.win.SetDefaultSize(400, 420)
+	w.win.SetModal(true)
+	if parent != nil {
+		w.win.SetTransientFor(parent)
+	}
+
+	w.page = adw.NewStatusPage()
+	w.page.SetIconName("bluetooth-symbolic")
+
+	box := gtk.NewBox(gtk.OrientationVertical, 12)
+	box.SetHAlign(gtk.AlignCenter)
+	w.spinner = gtk.NewSpinner()
+	w.spinner.SetSizeRequest(32, 32)
+	box.Append(w.spinner)
+	w.button = gtk.NewButton()
+	w.button.AddCSSClass("pill")
+	w.button.AddCSSClass("suggested-action")
+	w.button.ConnectClicked(func() {
+		if w.onButton != nil {
+			w.onButton()
+		}
+	})
+	box.Append(w.button)
+	w.page.SetChild(box)
+
+	toolbarView := adw.NewToolbarView()
+	toolbarView.AddTopBar(adw.NewHeaderBar())
+	toolbarView.SetContent(w.page)
+	w.win.SetContent(toolbarView)
+
+	w.showStart()
+	w.win.Present()
+}
+
+// show shows a step. With an empty button label, a spinner is shown instead of the button.
+func (w *pairingWizard) show(title string, description string, buttonLabel string, onButton func()) {
+	w.page.SetTitle(title)
+	w.page.SetDescription(description)
+	busy := buttonLabel == ""
+	w.spinner.SetVisible(busy)
+	w.spinner.SetSpinning(busy)
+	w.button.SetVisible(!busy)
+	w.button.SetLabel(buttonLabel)
+	w.onButton = onButton
+}
+
+// showStart shows the instructions for entering pairing mode
+func (w *pairingWizard) showStart() {
+	w.show(i18n.T("Pair AirPods"), i18n.T(pairingHint), i18n.T("Search"), w.run)
+}
+
+// run searches for, pairs and sets up AirPods in the background, showing each step
+func (w *pairingWizard) run() {
+	step := func(title string, description string) {
+		glib.IdleAdd(func() { w.show(title, description, "", nil) })
+	}
+	fail := func(title string, err error) {
+		log.Printf("Pairing: %s: %v", title, err)
+		glib.IdleAdd(func() { w.show(title, err.Error(), i18n.T("Try Again"), w.showStart) })
+	}
+
+	step(i18n.T("Searching..."), i18n.T(pairingHint))
+	go func() {
+		device, err := w.podCoord.FindPairableDevice(pairingSearchTimeout)
+		if err != nil {
+			fail(i18n.T("No AirPods Found"), err)
+			return
+		}
+		name := device.Name
+		if name == "" {
+			name = device.Address
+		}
+
+		step(i18n.Tf("Pairing %s", name), i18n.T("Keep the case open and close to this computer."))
+		if err := w.podCoord.PairBluetooth(device.Address); err != nil {
+			fail(i18n.T("Pairing Failed"), err)
+			return
+		}
+
+		step(i18n.Tf("Connecting %s", name), "")
+		if err := w.podCoord.ConnectBluetooth(device.Address); err != nil {
+			fail(i18n.T("Connection Failed"), err)
+			return
+		}
+
+		// The keys let LinuxPods read the battery from advertisements when not connected
+		step(i18n.Tf("Setting Up %s", name), i18n.T("Retrieving the keys for reading the battery when not connected"))
+		keysErr := w.retrieveKeys(device.Address)
+		description := i18n.Tf("%s is paired and connected.", name)
+		if keysErr != nil {
+			log.Printf("Pairing: key retrieval from %s failed: %v", device.Address, keysErr)
+			description += " " + i18n.T("The keys could not be retrieved, request them later under Settings → Development.")
+		}
+		glib.IdleAdd(func() { w.show(i18n.T("AirPods Ready"), description, i18n.T("Done"), w.win.Close) })
+	}()
+}
+
+// retrieveKeys waits for the AAP connection, which follows the Bluetooth connection, and
+// requests the encryption keys
+func (w *pairingWizard) retrieveKeys(macAddr string) error {
+	deadline := time.Now().Add(pairingAAPTimeout)
+	for !w.podCoord.IsAAPConnected(macAddr) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("no AAP connection to %s", macAddr)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return w.podCoord.RequestEncryptionKeys(macAddr)
+}
@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// shortcut is the keyboard shortcut of an action, listed in the shortcuts window
+type shortcut struct {
+	action string   // Detailed action name, e.g. "win.noise-mode(1)"
+	accels []string // Accelerators, the first one is shown in the shortcuts window
+	title  string   // Translated
+}
+
+// shortcutGroup is a group of the shortcuts window
+type shortcutGroup struct {
+	title     string // Translated
+	shortcuts []shortcut
+}
+
+// shortcutGroups returns the shortcuts of the main window. <Control>1 to 4 switch to the
+// noise control modes in the order of the noise control group.
+func shortcutGroups() []shortcutGroup {
+	general := shortcutGroup{title: i18n.T("General"), shortcuts: []shortcut{
+		{action: "win.refresh", accels: []string{"<Control>r", "F5"}, title: i18n.T("Refresh")},
+		{action: "win.show-info", accels: []string{"<Control>i"}, title: i18n.T("Show Device Info")},
+		{action: "win.show-help-overlay", accels: []string{"<Control>question"}, title: i18n.T("Keyboard Shortcuts")},
+		{action: "window.close", accels: []string{"<Control>w"}, title: i18n.T("Close Window")},
+		{action: "app.quit", accels: []string{"<Control>q"}, title: i18n.T("Quit")},
+	}}
+
+	noise := shortcutGroup{title: i18n.T("Noise Control")}
+	for i, opt := range noiseControlModes {
+		noise.shortcuts = append(noise.shortcuts, shortcut{
+			action: fmt.Sprintf("win.noise-mode(%d)", i+1),
+			accels: []string{fmt.Sprintf("<Control>%d", i+1)},
+			title:  i18n.T(opt.name),
+		})
+	}
+
+	return []shortcutGroup{general, noise}
+}
+
+// addShortcuts adds the actions of the main window, their accelerators, the shortcuts
+// window and the primary menu, so the window can be used with the keyboard alone.
+// The app.quit action is added by the application.
+func addShortcuts(win *adw.ApplicationWindow, headerBar *adw.HeaderBar, viewStack *adw.ViewStack,
+	widgets *BatteryWidgets, podCoord podstate.Backend, toasts *toaster) {
+	// Look for updates of the advertisements right away
+	refresh := gio.NewSimpleAction("refresh", nil)
+	refresh.ConnectActivate(func(*glib.Variant) {
+		go podCoord.StartFastScan()
+		toasts.show(i18n.T("Refreshing..."))
+	})
+	win.AddAction(refresh)
+
+	showInfo := gio.NewSimpleAction("show-info", nil)
+	showInfo.ConnectActivate(func(*glib.Variant) { viewStack.SetVisibleChildName("info") })
+	win.AddAction(showInfo)
+
+	// Selects a mode of the noise control group by its position, like clicking it. Modes the
+	// shown device doesn't support, or can't switch to while disconnected, are ignored.
+	noiseMode := gio.NewSimpleAction("noise-mode", glib.NewVariantType("i"))
+	noiseMode.ConnectActivate(func(parameter *glib.Variant) {
+		i := int(parameter.Int32()) - 1
+		if i < 0 || i >= len(noiseControlModes) {
+			return
+		}
+		mode := noiseControlModes[i].mode
+		button := widgets.NoiseControl.buttons[mode]
+		if widgets.NoiseControl.group.Visible() && widgets.NoiseControl.rows[mode].Visible() && button.Sensitive() {
+			button.SetActive(true)
+		}
+	})
+	win.AddAction(noiseMode)
+
+	groups := shortcutGroups()
+	app := win.Application()
+	for _, group := range groups {
+		for _, s := range group.shortcuts {
+			app.SetAccelsForAction(s.action, s.accels)
+		}
+	}
+	win.SetHelpOverlay(newShortcutsWindow(groups))
+
+	menu := gio.NewMenu()
+	menu.Append(i18n.T("Keyboard Shortcuts"), "win.show-help-overlay")
+	menu.Append(i18n.T("Save Debug Info…"), "win.save-debug-info")
+	menu.Append(i18n.T("About LinuxPods"), "win.about")
+	menu.Append(i18n.T("Quit"), "app.quit")
+	menuButton := gtk.NewMenuButton()
+	menuButton.SetIconName("open-menu-symbolic")
+	menuButton.SetTooltipText(i18n.T("Main Menu"))
+	setAccessibleLabel(menuButton, i18n.T("Main Menu"))
+	menuButton.SetMenuModel(menu)
+	headerBar.PackEnd(menuButton)
+}
+
+// newShortcutsWindow builds the shortcuts window listing the given shortcuts. Its sections
+// can only be created from a UI definition.
+func newShortcutsWindow(groups []shortcutGroup) *gtk.ShortcutsWindow {
+	var ui strings.Builder
+	ui.WriteString(`<interface><object class="GtkShortcutsWindow" id="shortcuts"><property name="modal">1</property>`)
+	ui.WriteString(`<child><object class="GtkShortcutsSection"><property name="section-name">shortcuts</property>`)
+	for _, group := range groups {
+		fmt.Fprintf(&ui, `<child><object class="GtkShortcutsGroup"><property name="title">%s</property>`,
+			glib.MarkupEscapeText(group.title))
+		for _, s := range group.shortcuts {
+			fmt.Fprintf(&ui, `<child><object class="GtkShortcutsShortcut"><property name="title">%s</property><property name="accelerator">%s</property></object></child>`,
+				glib.MarkupEscapeText(s.title), glib.MarkupEscapeText(s.accels[0]))
+		}
+		ui.WriteString(`</object></child>`)
+	}
+	ui.WriteString(`</object></child></object></interface>`)
+
+	builder := gtk.NewBuilderFromString(ui.String())
+	return builder.GetObject("shortcuts").Cast().(*gtk.ShortcutsWindow)
+}
@@ -0,0 +1,283 @@
+package ui
+
+import (
+	"log"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/aap"
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// pressAndHoldActionNames are the names of the press and hold actions (translated where they are shown)
+var pressAndHoldActionNames = map[aap.PressAndHoldAction]string{
+	aap.PressAndHoldNoiseControl: i18n.N("Noise Control"),
+	aap.PressAndHoldSiri:         i18n.N("Siri"),
+}
+
+// pressSpeedNames are the names of the press speeds (translated where they are shown)
+var pressSpeedNames = map[aap.PressSpeed]string{
+	aap.PressSpeedDefault: i18n.N("Default"),
+	aap.PressSpeedSlower:  i18n.N("Slower"),
+	aap.PressSpeedSlowest: i18n.N("Slowest"),
+}
+
+// holdDurationNames are the names of the hold durations (translated where they are shown)
+var holdDurationNames = map[aap.HoldDuration]string{
+	aap.HoldDurationDefault:  i18n.N("Default"),
+	aap.HoldDurationShorter:  i18n.N("Shorter"),
+	aap.HoldDurationShortest: i18n.N("Shortest"),
+}
+
+// defaultPressAndHold is the press and hold action AirPods ship with
+var defaultPressAndHold = aap.PressAndHold{Left: aap.PressAndHoldNoiseControl, Right: aap.PressAndHoldNoiseControl}
+
+// stemControls is the Controls tab configuring the stem gestures of the shown device: the
+// press and hold action of each bud, the noise control cycle, the press speed and hold
+// duration, and volume swipe. Groups the model doesn't support are hidden.
+type stemControls struct {
+	box     *gtk.Box
+	backend podstate.Backend
+
+	pressAndHoldGroup *adw.PreferencesGroup
+	cycleGroup        *adw.PreferencesGroup
+	timingGroup       *adw.PreferencesGroup
+	volumeSwipeGroup  *adw.PreferencesGroup
+
+	leftRow           *adw.ComboRow
+	rightRow          *adw.ComboRow
+	speedRow          *adw.ComboRow
+	durationRow       *adw.ComboRow
+	volumeSwipeSwitch *gtk.Switch
+
+	settings  map[string]*aap.DeviceSettings // Settings reported by each device
+	macAddr   string                         // Shown device
+	connected bool                           // Whether the shown device is connected via AAP
+	caps      aap.Capabilities               // Features of the shown device
+
+	// Set while the rows are updated from a device notification, so it isn't sent back
+	updating bool
+}
+
+// createStemControls builds the Controls tab. All groups are shown until a device is known.
+func createStemControls(podCoord podstate.Backend) *stemControls {
+	c := &stemControls{
+		box:      gtk.NewBox(gtk.OrientationVertical, 20),
+		backend:  podCoord,
+		settings: make(map[string]*aap.DeviceSettings),
+		caps:     aap.AllCapabilities,
+	}
+	c.box.SetMarginTop(20)
+	c.box.SetMarginBottom(20)
+	c.box.SetMarginStart(20)
+	c.box.SetMarginEnd(20)
+
+	// Press and hold action of each bud
+	c.pressAndHoldGroup = adw.NewPreferencesGroup()
+	c.pressAndHoldGroup.SetTitle(i18n.T("Press and Hold"))
+	actionNames := make([]string, len(aap.PressAndHoldActions))
+	for i, action := range aap.PressAndHoldActions {
+		actionNames[i] = i18n.T(pressAndHoldActionNames[action])
+	}
+	c.leftRow = c.addComboRow(c.pressAndHoldGroup, i18n.T("Left AirPod"), "", actionNames, c.applyPressAndHold)
+	c.rightRow = c.addComboRow(c.pressAndHoldGroup, i18n.T("Right AirPod"), "", actionNames, c.applyPressAndHold)
+	c.box.Append(c.pressAndHoldGroup)
+
+	// Noise control modes of the press and hold gesture
+	c.cycleGroup = createNoiseControlCycleGroup(podCoord)
+	c.box.Append(c.cycleGroup)
+
+	// Press speed and hold duration, e.g. for limited dexterity
+	c.timingGroup = adw.NewPreferencesGroup()
+	c.timingGroup.SetTitle(i18n.T("Timing"))
+	speedNames := make([]string, len(aap.PressSpeeds))
+	for i, speed := range aap.PressSpeeds {
+		speedNames[i] = i18n.T(pressSpeedNames[speed])
+	}
+	c.speedRow = c.addComboRow(c.timingGroup, i18n.T("Press Speed"),
+		i18n.T("How fast the stem must be pressed twice or three times"), speedNames, c.applyPressSpeed)
+	durationNames := make([]string, len(aap.HoldDurations))
+	for i, duration := range aap.HoldDurations {
+		durationNames[i] = i18n.T(holdDurationNames[duration])
+	}
+	c.durationRow = c.addComboRow(c.timingGroup, i18n.T("Press and Hold Duration"),
+		i18n.T("How long the stem must be held"), durationNames, c.applyHoldDuration)
+	c.box.Append(c.timingGroup)
+
+	// Volume swipe
+	c.volumeSwipeGroup = adw.NewPreferencesGroup()
+	volumeSwipeRow := adw.NewActionRow()
+	volumeSwipeRow.SetTitle(i18n.T("Volume Swipe"))
+	volumeSwipeRow.SetSubtitle(i18n.T("Change the volume by swiping up or down on the stem"))
+	c.volumeSwipeSwitch = gtk.NewSwitch()
+	c.volumeSwipeSwitch.SetActive(true) // Enabled by default
+	c.volumeSwipeSwitch.SetVAlign(gtk.AlignCenter)
+	c.volumeSwipeSwitch.Connect("notify::active", c.applyVolumeSwipe)
+	volumeSwipeRow.AddSuffix(c.volumeSwipeSwitch)
+	volumeSwipeRow.SetActivatableWidget(c.volumeSwipeSwitch)
+	c.volumeSwipeGroup.Add(volumeSwipeRow)
+	c.box.Append(c.volumeSwipeGroup)
+
+	// Keep the settings reported by each device, and show those of the shown device
+	podCoord.RegisterSettingsCallback(func(macAddr string, cmd aap.ControlCommand) {
+		switch cmd.ID {
+		case aap.ControlPressAndHoldMode, aap.ControlPressSpeed, aap.ControlHoldDuration, aap.ControlVolumeSwipe:
+		default:
+			return
+		}
+		glib.IdleAdd(func() {
+			settings, ok := c.settings[macAddr]
+			if !ok {
+				settings = &aap.DeviceSettings{}
+				c.settings[macAddr] = settings
+			}
+			settings.Apply(&cmd)
+			if macAddr == c.macAddr {
+				c.refresh()
+			}
+		})
+	})
+
+	c.refresh()
+	return c
+}
+
+// addComboRow adds a row choosing one of the given names to a group. changed is called
+// when the user selects another entry.
+func (c *stemControls) addComboRow(group *adw.PreferencesGroup, title string, subtitle string, names []string, changed func()) *adw.ComboRow {
+	row := adw.NewComboRow()
+	row.SetTitle(title)
+	if subtitle != "" {
+		row.SetSubtitle(subtitle)
+	}
+	row.SetModel(gtk.NewStringList(names))
+	row.Connect("notify::selected", func() {
+		if !c.updating {
+			changed()
+		}
+	})
+	group.Add(row)
+	return row
+}
+
+// show selects the device whose controls are shown
+func (c *stemControls) show(state *podstate.PodState) {
+	c.macAddr = state.RealMac
+	c.connected = state.Source == podstate.DataSourceAAP
+	c.caps = state.Capabilities
+	c.refresh()
+}
+
+// refresh shows the settings of the shown device and the groups its model supports
+func (c *stemControls) refresh() {
+	stem := c.caps.SupportsPressAndHold
+	c.pressAndHoldGroup.SetVisible(stem && c.caps.SupportsNoiseControl())
+	c.cycleGroup.SetVisible(c.caps.SupportsNoiseControl())
+	c.timingGroup.SetVisible(stem)
+	c.volumeSwipeGroup.SetVisible(c.caps.SupportsVolumeSwipe)
+
+	if c.connected {
+		c.pressAndHoldGroup.SetDescription(i18n.T("What pressing and holding the stem of each AirPod does"))
+	} else {
+		c.pressAndHoldGroup.SetDescription(i18n.T("Connect the AirPods to change the controls"))
+	}
+	for _, widget := range []gtk.Widgetter{c.leftRow, c.rightRow, c.speedRow, c.durationRow, c.volumeSwipeSwitch} {
+		gtk.BaseWidget(widget).SetSensitive(c.connected)
+	}
+
+	// Defaults until the device reported its settings
+	settings := c.settings[c.macAddr]
+	if settings == nil {
+		settings = &aap.DeviceSettings{}
+	}
+	pressAndHold := defaultPressAndHold
+	if settings.PressAndHold != nil {
+		pressAndHold = *settings.PressAndHold
+	}
+	speed := aap.PressSpeedDefault
+	if settings.PressSpeed != nil {
+		speed = *settings.PressSpeed
+	}
+	duration := aap.HoldDurationDefault
+	if settings.HoldDuration != nil {
+		duration = *settings.HoldDuration
+	}
+	volumeSwipe := settings.VolumeSwipe == nil || *settings.VolumeSwipe
+
+	c.updating = true
+	selectIndex(c.leftRow, aap.PressAndHoldActions, pressAndHold.Left)
+	selectIndex(c.rightRow, aap.PressAndHoldActions, pressAndHold.Right)
+	selectIndex(c.speedRow, aap.PressSpeeds, speed)
+	selectIndex(c.durationRow, aap.HoldDurations, duration)
+	c.volumeSwipeSwitch.SetActive(volumeSwipe)
+	c.updating = false
+}
+
+// selectIndex selects the entry of a combo row showing value
+func selectIndex[T comparable](row *adw.ComboRow, values []T, value T) {
+	for i, v := range values {
+		if v == value && row.Selected() != uint(i) {
+			row.SetSelected(uint(i))
+		}
+	}
+}
+
+// selected returns the value of the selected entry of a combo row
+func selected[T any](row *adw.ComboRow, values []T) (T, bool) {
+	i := int(row.Selected())
+	if i < 0 || i >= len(values) {
+		var zero T
+		return zero, false
+	}
+	return values[i], true
+}
+
+// apply sends a setting to the shown device in the background, logging failures
+func (c *stemControls) apply(name string, set func(macAddr string) error) {
+	macAddr := c.macAddr
+	if macAddr == "" || !c.connected {
+		return
+	}
+	go func() {
+		if err := set(macAddr); err != nil {
+			log.Printf("Failed to set %s of %s: %v", name, macAddr, err)
+		}
+	}()
+}
+
+// applyPressAndHold sends the press and hold actions selected for both buds
+func (c *stemControls) applyPressAndHold() {
+	left, okLeft := selected(c.leftRow, aap.PressAndHoldActions)
+	right, okRight := selected(c.rightRow, aap.PressAndHoldActions)
+	if !okLeft || !okRight {
+		return
+	}
+	pressAndHold := aap.PressAndHold{Left: left, Right: right}
+	c.apply("press and hold", func(macAddr string) error { return c.backend.SetPressAndHold(macAddr, pressAndHold) })
+}
+
+// applyPressSpeed sends the selected press speed
+func (c *stemControls) applyPressSpeed() {
+	if speed, ok := selected(c.speedRow, aap.PressSpeeds); ok {
+		c.apply("press speed", func(macAddr string) error { return c.backend.SetPressSpeed(macAddr, speed) })
+	}
+}
+
+// applyHoldDuration sends the selected hold duration
+func (c *stemControls) applyHoldDuration() {
+	if duration, ok := selected(c.durationRow, aap.HoldDurations); ok {
+		c.apply("hold duration", func(macAddr string) error { return c.backend.SetHoldDuration(macAddr, duration) })
+	}
+}
+
+// applyVolumeSwipe sends whether volume swipe is enabled
+func (c *stemControls) applyVolumeSwipe() {
+	if c.updating {
+		return
+	}
+	enabled := c.volumeSwipeSwitch.Active()
+	c.apply("volume swipe", func(macAddr string) error { return c.backend.SetVolumeSwipe(macAddr, enabled) })
+}
@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"linuxpods/internal/podstate"
+)
+
+// windowBackend is the backend as seen by a window. It removes the event handlers and
+// settings callbacks the window registered when the window is closed, so windows that are
+// opened again and again don't leave their handlers behind.
+type windowBackend struct {
+	podstate.Backend
+
+	mu            sync.Mutex
+	subscriptions []func()
+}
+
+// scopeToWindow returns a backend whose subscriptions end when win is destroyed. A window
+// that hides on close (to the tray) keeps them, so it is up to date when it is shown again.
+func scopeToWindow(podCoord podstate.Backend, win *gtk.Window) podstate.Backend {
+	b := &windowBackend{Backend: podCoord}
+	win.ConnectDestroy(b.unsubscribeAll)
+	return b
+}
+
+func (b *windowBackend) Subscribe(handler podstate.EventHandler) func() {
+	return b.track(b.Backend.Subscribe(handler))
+}
+
+func (b *windowBackend) RegisterSettingsCallback(cb podstate.SettingsCallback) func() {
+	return b.track(b.Backend.RegisterSettingsCallback(cb))
+}
+
+// track remembers a subscription for unsubscribeAll
+func (b *windowBackend) track(unsubscribe func()) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscriptions = append(b.subscriptions, unsubscribe)
+	return unsubscribe
+}
+
+// unsubscribeAll removes all subscriptions of the window
+func (b *windowBackend) unsubscribeAll() {
+	b.mu.Lock()
+	subscriptions := b.subscriptions
+	b.subscriptions = nil
+	b.mu.Unlock()
+
+	for _, unsubscribe := range subscriptions {
+		unsubscribe()
+	}
+}
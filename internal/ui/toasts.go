@@ -0,0 +1,65 @@
+package ui
+
+import (
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+
+	"linuxpods/internal/i18n"
+	"linuxpods/internal/podstate"
+)
+
+// toastTimeout is how long a toast is shown, in seconds
+const toastTimeout = 3
+
+// toaster shows brief in-app messages over the window's content when the AirPods connect or
+// disconnect and when their keys were retrieved, which would otherwise only be logged
+type toaster struct {
+	overlay *adw.ToastOverlay
+	names   map[string]string // Display names of the known devices by address
+}
+
+// createToaster creates the toast overlay and subscribes to the events that are toasted.
+// The window's content goes into the overlay.
+func createToaster(podCoord podstate.Backend) *toaster {
+	t := &toaster{overlay: adw.NewToastOverlay(), names: make(map[string]string)}
+
+	podCoord.Subscribe(func(event podstate.Event) {
+		switch e := event.(type) {
+		case podstate.StatesChanged:
+			glib.IdleAdd(func() {
+				for macAddr, state := range e.States {
+					t.names[macAddr] = state.DisplayName()
+				}
+			})
+		case podstate.DeviceConnected:
+			// The AAP connection replaces the BLE advertisements as the source of the levels
+			glib.IdleAdd(func() {
+				t.show(i18n.Tf("%s connected, showing exact battery levels", t.name(e.Address)))
+			})
+		case podstate.DeviceDisconnected:
+			glib.IdleAdd(func() { t.show(i18n.Tf("%s disconnected", t.name(e.Address))) })
+		case podstate.DeviceConnectFailed:
+			glib.IdleAdd(func() { t.show(i18n.Tf("Failed to connect to %s", t.name(e.Address))) })
+		case podstate.KeysStored:
+			glib.IdleAdd(func() { t.show(i18n.Tf("Encryption keys of %s stored", t.name(e.Address))) })
+		}
+	})
+
+	return t
+}
+
+// show shows a toast
+func (t *toaster) show(title string) {
+	toast := adw.NewToast(title)
+	toast.SetTimeout(toastTimeout)
+	t.overlay.AddToast(toast)
+}
+
+// name returns the display name of a device, or its address if it has none, escaped for
+// the toast's markup
+func (t *toaster) name(macAddr string) string {
+	if name := t.names[macAddr]; name != "" {
+		return glib.MarkupEscapeText(name)
+	}
+	return macAddr
+}
@@ -1,13 +1,17 @@
 package ui
 
 import (
-	"fmt"
+	"time"
 
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 
+	"linuxpods/internal/aap"
+	"linuxpods/internal/config"
+	"linuxpods/internal/i18n"
 	"linuxpods/internal/podstate"
+	"linuxpods/internal/version"
 )
 
 // BatteryWidgets holds references to UI elements for updating battery display
@@ -18,33 +22,84 @@ type BatteryWidgets struct {
 	LeftLabel   *gtk.Label
 	RightLabel  *gtk.Label
 	CaseLabel   *gtk.Label
-	StatusLabel *gtk.Label // For connection status, charging, etc.
+	StatusLabel *gtk.Label // For the model, audio profile, etc.
+
+	// State of each component below its level: in ear, in case, charging or unavailable
+	LeftStatus  *gtk.Label
+	RightStatus *gtk.Label
+	CaseStatus  *gtk.Label
+
+	// Single battery of headphones such as AirPods Max, shown instead of the pods and case
+	HeadphoneLevel  *gtk.LevelBar
+	HeadphoneLabel  *gtk.Label
+	HeadphoneStatus *gtk.Label
+	HeadphoneColumn *gtk.Box
+
+	// Controls that are hidden when the model doesn't support them
+	PodColumns      []*gtk.Box // Left and right pod
+	CaseColumn      *gtk.Box
+	NoiseControl    *noiseControlGroup
+	ConversationRow *adw.ActionRow
+	History         *batteryGraph
+
+	DeviceInfo *DeviceInfoWidgets
+	InfoPage   *deviceInfoPage
+	Controls   *stemControls
+	Artwork    []*artworkImage // Images of the pods, case and headphones
+
+	// Chooses the device shown when several are known
+	Devices *deviceSelector
+
+	// Shows the controls, or the empty state while no device is known
+	ControlStack *gtk.Stack
+	EmptyState   *emptyState
 }
 
-func Activate(app *adw.Application, podCoord *podstate.PodStateCoordinator) *adw.ApplicationWindow {
+// Activate creates the main window. debug shows the Debug tab regardless of the setting
+// (--debug).
+func Activate(app *adw.Application, podCoord podstate.Backend, notifier *LowBatteryNotifier, debug bool) *adw.ApplicationWindow {
+	loadStyle()
 	win := adw.NewApplicationWindow(&app.Application)
 	win.SetTitle("LinuxPods")
-	win.SetDefaultSize(400, 500)
 
-	batteryWidgets := setupUI(win, podCoord)
+	// A new window is created on every activation, its handlers end when it is closed
+	podCoord = scopeToWindow(podCoord, &win.Window)
+
+	batteryWidgets := setupUI(win, podCoord, notifier, debug)
+
+	// Scan actively while the window is shown (adaptive scan mode). The daemon is called
+	// over D-Bus, don't block the main thread.
+	win.ConnectMap(func() { go podCoord.SetInteractive(true) })
+	win.ConnectUnmap(func() { go podCoord.SetInteractive(false) })
 	win.Present()
 
+	// Show the device chosen in the device selector, by default the connected or closest one
+	var lastStates map[string]*podstate.PodState
+	showDevice := func() {
+		_, state := batteryWidgets.Devices.current(lastStates)
+		if state == nil {
+			batteryWidgets.ControlStack.SetVisibleChildName("empty")
+			return
+		}
+		batteryWidgets.ControlStack.SetVisibleChildName("device")
+		updateBatteryDisplay(batteryWidgets, state)
+	}
+	batteryWidgets.Devices.onChange = showDevice
+
 	// Register callback with pod state coordinator to update UI
-	podCoord.RegisterCallback(func(states map[string]*podstate.PodState) {
+	podCoord.Subscribe(podstate.OnStatesChanged(func(states map[string]*podstate.PodState) {
 		// Update UI on GTK main thread
 		glib.IdleAdd(func() {
-			// For now, just use the first device in the map
-			for _, state := range states {
-				updateBatteryDisplay(batteryWidgets, state)
-				break // Only use first device
-			}
+			lastStates = states
+			batteryWidgets.Devices.update(states)
+			showDevice()
 		})
-	})
+	}))
 
 	return win
 }
 
-func setupUI(win *adw.ApplicationWindow, podCoord *podstate.PodStateCoordinator) *BatteryWidgets {
+func setupUI(win *adw.ApplicationWindow, podCoord podstate.Backend, notifier *LowBatteryNotifier, debug bool) *BatteryWidgets {
 	// Create header bar with close button
 	headerBar := adw.NewHeaderBar()
 
@@ -58,17 +113,62 @@ func setupUI(win *adw.ApplicationWindow, podCoord *podstate.PodStateCoordinator)
 	headerBar.SetTitleWidget(viewSwitcher)
 
 	// Create the Control tab content
-	controlBox, batteryWidgets := createControlView()
-	viewStack.AddTitledWithIcon(controlBox, "control", "Control", "audio-headphones-symbolic")
+	controlBox, batteryWidgets := createControlView(podCoord)
+
+	// Guidance instead of empty levels until a device is found
+	batteryWidgets.EmptyState = createEmptyState(win, podCoord)
+	batteryWidgets.ControlStack = gtk.NewStack()
+	batteryWidgets.ControlStack.AddNamed(batteryWidgets.EmptyState.page, "empty")
+	batteryWidgets.ControlStack.AddNamed(scrollable(controlBox), "device")
+	batteryWidgets.ControlStack.SetVisibleChildName("empty")
+	viewStack.AddTitledWithIcon(batteryWidgets.ControlStack, "control", i18n.T("Control"), "audio-headphones-symbolic")
+
+	// Brief messages about connections and keys over all tabs
+	toasts := createToaster(podCoord)
+
+	// Create the Info tab content (model, firmware, serial numbers and device settings)
+	infoPage := createDeviceInfoPage(toasts)
+	batteryWidgets.InfoPage = infoPage
+	viewStack.AddTitledWithIcon(infoPage.page, "info", i18n.T("Info"), "help-about-symbolic")
+
+	// Create the Controls tab content (stem gestures of the shown device)
+	batteryWidgets.Controls = createStemControls(podCoord)
+	viewStack.AddTitledWithIcon(scrollable(batteryWidgets.Controls.box), "controls", i18n.T("Controls"), "input-touchpad-symbolic")
+
+	// The Debug tab follows the setting, unless it was enabled with --debug
+	var debugPage *adw.ViewStackPage
+	showDebugPage := func(enabled bool) {
+		if debugPage != nil {
+			debugPage.SetVisible(enabled || debug)
+		}
+	}
 
 	// Create the Settings tab content (placeholder for now)
-	settingsBox := createSettingsView(podCoord)
-	viewStack.AddTitledWithIcon(settingsBox, "settings", "Settings", "preferences-system-symbolic")
+	settingsBox := createSettingsView(win, podCoord, notifier, toasts, showDebugPage)
+	viewStack.AddTitledWithIcon(scrollable(settingsBox), "settings", i18n.T("Settings"), "preferences-system-symbolic")
+
+	// Create the Diagnostics tab content
+	diagnosticsBox := createDiagnosticsView(podCoord)
+	viewStack.AddTitledWithIcon(scrollable(diagnosticsBox), "diagnostics", i18n.T("Diagnostics"), "utilities-system-monitor-symbolic")
+
+	// Create the Debug tab content (live packets for developers)
+	debugView := createDebugView(podCoord, toasts)
+	debugPage = viewStack.AddTitledWithIcon(scrollable(debugView.box), "debug", i18n.T("Debug"), "utilities-terminal-symbolic")
+	showDebugPage(config.LoadOrDefault(config.Path()).Debug.Page)
+
+	// Keyboard shortcuts, the shortcuts window and the primary menu
+	addShortcuts(win, headerBar, viewStack, batteryWidgets, podCoord, toasts)
+	addAboutActions(win, podCoord, toasts)
+
+	// The size and tab of the last time the window was open, once all tabs exist
+	restoreWindowState(win, viewStack)
 
 	// Use ToolbarView for seamless GNOME design (no visual separation)
 	toolbarView := adw.NewToolbarView()
 	toolbarView.AddTopBar(headerBar)
-	toolbarView.SetContent(viewStack)
+	toolbarView.AddTopBar(batteryWidgets.EmptyState.banner)
+	toasts.overlay.SetChild(viewStack)
+	toolbarView.SetContent(toasts.overlay)
 
 	// Set the toolbar view as the window's content
 	win.SetContent(toolbarView)
@@ -76,7 +176,16 @@ func setupUI(win *adw.ApplicationWindow, podCoord *podstate.PodStateCoordinator)
 	return batteryWidgets
 }
 
-func createControlView() (*gtk.Box, *BatteryWidgets) {
+// scrollable wraps a tab's content in a vertically scrolling window, so growing
+// content doesn't enlarge the window
+func scrollable(child gtk.Widgetter) *gtk.ScrolledWindow {
+	scrolledWindow := gtk.NewScrolledWindow()
+	scrolledWindow.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	scrolledWindow.SetChild(child)
+	return scrolledWindow
+}
+
+func createControlView(podCoord podstate.Backend) (*gtk.Box, *BatteryWidgets) {
 	// Create main vertical box to hold all control elements
 	controlBox := gtk.NewBox(gtk.OrientationVertical, 20)
 	controlBox.SetMarginTop(20)
@@ -87,21 +196,22 @@ func createControlView() (*gtk.Box, *BatteryWidgets) {
 	// Create battery widgets structure
 	widgets := &BatteryWidgets{}
 
+	// Device selector, shown when there is more than one device
+	widgets.Devices = newDeviceSelector()
+	controlBox.Append(widgets.Devices.dropDown)
+
 	// Create horizontal box for battery indicators
 	batteryBox := gtk.NewBox(gtk.OrientationHorizontal, 20)
 	batteryBox.SetHAlign(gtk.AlignCenter)
 	batteryBox.SetVAlign(gtk.AlignStart)
 
-	// Define image paths for AirPods components
-	imagePaths := []string{
-		"assets/left_airpod_pro3.png",
-		"assets/right_airpod_pro3.png",
-		"assets/airpod_case.png",
-	}
+	// Images of the AirPods components, following the model and color of the shown device
+	parts := []string{"left", "right", "case"}
 
 	// Create references for each battery component
 	levelBars := []*gtk.LevelBar{}
 	labels := []*gtk.Label{}
+	statuses := []*gtk.Label{}
 
 	// Create three battery indicators with images
 	for i := 0; i < 3; i++ {
@@ -110,28 +220,48 @@ func createControlView() (*gtk.Box, *BatteryWidgets) {
 		columnBox.SetHAlign(gtk.AlignCenter)
 
 		// Add AirPod image
-		image := gtk.NewImageFromFile(imagePaths[i])
-		image.SetPixelSize(64)
-		columnBox.Append(image)
-
-		// Add battery indicator (LevelBar)
-		batteryLevel := gtk.NewLevelBar()
-		batteryLevel.SetMode(gtk.LevelBarModeContinuous)
-		batteryLevel.SetValue(0.0) // Start at 0, will be updated by scanner
-		batteryLevel.SetSizeRequest(100, 20)
-		columnBox.Append(batteryLevel)
-		levelBars = append(levelBars, batteryLevel)
+		image := newArtworkImage(parts[i], "audio-headphones-symbolic", 64)
+		columnBox.Append(image.image)
+		widgets.Artwork = append(widgets.Artwork, image)
 
-		// Add battery percentage label
-		percentLabel := gtk.NewLabel("--")
-		percentLabel.AddCSSClass("dim-label")
-		columnBox.Append(percentLabel)
+		// Add battery indicator (LevelBar) with the percentage on top
+		batteryOverlay, batteryLevel, percentLabel := newBatteryLevel(parts[i])
+		columnBox.Append(batteryOverlay)
+		levelBars = append(levelBars, batteryLevel)
 		labels = append(labels, percentLabel)
 
+		// Add component state (in ear, in case, charging)
+		status := newComponentStatus()
+		columnBox.Append(status)
+		statuses = append(statuses, status)
+		labelLevelBy(batteryLevel, percentLabel, status)
+
 		// Add column to battery box
 		batteryBox.Append(columnBox)
+		if i == 2 {
+			widgets.CaseColumn = columnBox
+		} else {
+			widgets.PodColumns = append(widgets.PodColumns, columnBox)
+		}
 	}
 
+	// Headphone layout with a single battery (AirPods Max), hidden until such a device is seen
+	headphoneColumn := gtk.NewBox(gtk.OrientationVertical, 10)
+	headphoneColumn.SetHAlign(gtk.AlignCenter)
+	headphoneImage := newArtworkImage("headphones", "audio-headphones-symbolic", 64)
+	headphoneColumn.Append(headphoneImage.image)
+	widgets.Artwork = append(widgets.Artwork, headphoneImage)
+	headphoneOverlay, headphoneLevel, headphoneLabel := newBatteryLevel("headphones")
+	headphoneColumn.Append(headphoneOverlay)
+	widgets.HeadphoneLevel = headphoneLevel
+	widgets.HeadphoneLabel = headphoneLabel
+	widgets.HeadphoneStatus = newComponentStatus()
+	headphoneColumn.Append(widgets.HeadphoneStatus)
+	labelLevelBy(headphoneLevel, headphoneLabel, widgets.HeadphoneStatus)
+	headphoneColumn.SetVisible(false)
+	batteryBox.Append(headphoneColumn)
+	widgets.HeadphoneColumn = headphoneColumn
+
 	// Store widget references
 	widgets.LeftLevel = levelBars[0]
 	widgets.RightLevel = levelBars[1]
@@ -139,75 +269,31 @@ func createControlView() (*gtk.Box, *BatteryWidgets) {
 	widgets.LeftLabel = labels[0]
 	widgets.RightLabel = labels[1]
 	widgets.CaseLabel = labels[2]
+	widgets.LeftStatus = statuses[0]
+	widgets.RightStatus = statuses[1]
+	widgets.CaseStatus = statuses[2]
 
 	// Add battery indicators to control box
 	controlBox.Append(batteryBox)
 
 	// Add status label for connection state, charging, etc.
-	statusLabel := gtk.NewLabel("Searching for AirPods...")
+	statusLabel := gtk.NewLabel(i18n.T("Searching for AirPods..."))
 	statusLabel.AddCSSClass("dim-label")
 	statusLabel.SetMarginTop(10)
 	controlBox.Append(statusLabel)
 	widgets.StatusLabel = statusLabel
 
-	// Create Noise Control section using Adwaita PreferencesGroup
-	noiseControlGroup := adw.NewPreferencesGroup()
-	noiseControlGroup.SetTitle("Noise Control")
-
-	// Define noise control options
-	options := []struct {
-		id    string
-		title string
-		desc  string
-	}{
-		{"transparency", "Transparency", "Hear the world around you"},
-		{"adaptive", "Adaptive", "Automatically adjusts to your environment"},
-		{"noise_cancelling", "Noise Cancelling", "Block out background noise"},
-		{"off", "Off", "Noise control disabled"},
-	}
-
-	var firstButton *gtk.CheckButton
-	for i, opt := range options {
-		// Create action row
-		row := adw.NewActionRow()
-		row.SetTitle(opt.title)
-		row.SetSubtitle(opt.desc)
-
-		// Create radio button
-		var radioButton *gtk.CheckButton
-		if i == 0 {
-			radioButton = gtk.NewCheckButton()
-			radioButton.SetActive(true) // Set first option as default
-			firstButton = radioButton
-		} else {
-			radioButton = gtk.NewCheckButton()
-			radioButton.SetGroup(firstButton)
-		}
-
-		// Connect signal handler
-		radioButton.Connect("toggled", func() {
-			if radioButton.Active() {
-				println("Noise Control changed to:", opt.title, "("+opt.id+")")
-				// Add your logic here to actually change the noise control setting
-			}
-		})
-
-		row.AddPrefix(radioButton)
-		row.SetActivatableWidget(radioButton)
-
-		noiseControlGroup.Add(row)
-	}
-
-	// Add noise control section to control box
-	controlBox.Append(noiseControlGroup)
+	// Noise control of the shown device
+	widgets.NoiseControl = createNoiseControlGroup(podCoord)
+	controlBox.Append(widgets.NoiseControl.group)
 
 	// Create Conversation Awareness section
 	conversationGroup := adw.NewPreferencesGroup()
-	conversationGroup.SetTitle("Features")
+	conversationGroup.SetTitle(i18n.T("Features"))
 
 	conversationRow := adw.NewActionRow()
-	conversationRow.SetTitle("Conversation Awareness")
-	conversationRow.SetSubtitle("Lower media volume when you start speaking")
+	conversationRow.SetTitle(i18n.T("Conversation Awareness"))
+	conversationRow.SetSubtitle(i18n.T("Lower media volume when you start speaking"))
 
 	conversationSwitch := gtk.NewSwitch()
 	conversationSwitch.SetActive(false)
@@ -224,14 +310,25 @@ func createControlView() (*gtk.Box, *BatteryWidgets) {
 	})
 
 	conversationGroup.Add(conversationRow)
+	widgets.ConversationRow = conversationRow
 
 	// Add conversation awareness section to control box
 	controlBox.Append(conversationGroup)
 
+	// Battery levels of the shown device over the last hours or days
+	widgets.History = createBatteryGraph(podCoord)
+	controlBox.Append(widgets.History.group)
+
+	// Add device info section (MAC addresses, Bluetooth connection) to control box
+	deviceInfoGroup, deviceInfo := createDeviceInfoGroup(podCoord)
+	controlBox.Append(deviceInfoGroup)
+	widgets.DeviceInfo = deviceInfo
+
 	return controlBox, widgets
 }
 
-func createSettingsView(podCoord *podstate.PodStateCoordinator) *gtk.Box {
+func createSettingsView(win *adw.ApplicationWindow, podCoord podstate.Backend, notifier *LowBatteryNotifier, toasts *toaster,
+	showDebugPage func(bool)) *gtk.Box {
 	// Create main vertical box for settings
 	settingsBox := gtk.NewBox(gtk.OrientationVertical, 20)
 	settingsBox.SetMarginTop(20)
@@ -241,13 +338,16 @@ func createSettingsView(podCoord *podstate.PodStateCoordinator) *gtk.Box {
 
 	// Create a preferences group for settings
 	settingsGroup := adw.NewPreferencesGroup()
-	settingsGroup.SetTitle("General")
-	settingsGroup.SetDescription("Application preferences")
+	settingsGroup.SetTitle(i18n.T("General"))
+	settingsGroup.SetDescription(i18n.T("Application preferences"))
+
+	// Settings of the daemon's services and of the GUI, saved to the config file
+	cfg := config.LoadOrDefault(config.Path())
 
 	// Add a sample setting row
 	autoConnectRow := adw.NewActionRow()
-	autoConnectRow.SetTitle("Auto-connect")
-	autoConnectRow.SetSubtitle("Automatically connect when AirPods are detected")
+	autoConnectRow.SetTitle(i18n.T("Auto-connect"))
+	autoConnectRow.SetSubtitle(i18n.T("Automatically connect when AirPods are detected"))
 
 	autoConnectSwitch := gtk.NewSwitch()
 	autoConnectSwitch.SetActive(true)
@@ -259,147 +359,91 @@ func createSettingsView(podCoord *podstate.PodStateCoordinator) *gtk.Box {
 
 	// Add another setting
 	notificationsRow := adw.NewActionRow()
-	notificationsRow.SetTitle("Battery notifications")
-	notificationsRow.SetSubtitle("Show notification when battery is low")
+	notificationsRow.SetTitle(i18n.T("Battery notifications"))
 
 	notificationsSwitch := gtk.NewSwitch()
-	notificationsSwitch.SetActive(false)
 	notificationsSwitch.SetVAlign(gtk.AlignCenter)
 	notificationsRow.AddSuffix(notificationsSwitch)
 	notificationsRow.SetActivatableWidget(notificationsSwitch)
 
-	settingsGroup.Add(notificationsRow)
+	// The switch starts from the config file (notifications.enabled) and applies to this session
+	showNotificationsConfig := func(cfg config.NotificationsConfig) {
+		notificationsRow.SetSubtitle(i18n.Tf("Notify when a battery drops below %d%%", cfg.LowBattery))
+		notificationsSwitch.SetActive(cfg.Enabled)
+	}
+	showNotificationsConfig(notifier.Config())
+	notifier.setOnChange(showNotificationsConfig)
+	notificationsSwitch.Connect("notify::active", func() {
+		if notificationsSwitch.Active() != notifier.Config().Enabled {
+			notifier.SetEnabled(notificationsSwitch.Active())
+		}
+	})
 
-	settingsBox.Append(settingsGroup)
+	settingsGroup.Add(notificationsRow)
 
-	// Create Development section
-	devGroup := adw.NewPreferencesGroup()
-	devGroup.SetTitle("Development")
-	devGroup.SetDescription("Encryption keys for decrypting BLE advertisements")
+	addConfigSwitch(settingsGroup, i18n.T("Close to Tray"), i18n.T("Keep running in the tray when the window is closed"),
+		"tray", "close_to_tray", cfg.Tray.CloseToTray)
 
-	// Keep track of device rows and their components
-	type DeviceRow struct {
-		row           *adw.ActionRow
-		keyLabel      *gtk.Label
-		requestButton *gtk.Button
-	}
-	deviceRows := make(map[string]*DeviceRow)
+	// Pair new AirPods without going to the Bluetooth settings
+	pairRow := adw.NewActionRow()
+	pairRow.SetTitle(i18n.T("Pair new AirPods"))
+	pairRow.SetSubtitle(i18n.T("Pair, connect and set up AirPods in pairing mode"))
 
-	// Register callback to update device list when states change
-	podCoord.RegisterCallback(func(states map[string]*podstate.PodState) {
-		glib.IdleAdd(func() {
-			// Get connected device MAC address
-			connectedMac := podCoord.GetConnectedDeviceMac()
-
-			// Update or create rows for each device
-			for macAddr, state := range states {
-				devRow, exists := deviceRows[macAddr]
-
-				if !exists {
-					// Create new row for this device
-					row := adw.NewActionRow()
-					row.SetTitle(macAddr)
-					if state.ModelName != "" {
-						row.SetSubtitle(state.ModelName)
-					}
-
-					// Create key status label
-					keyLabel := gtk.NewLabel("Not present")
-					keyLabel.AddCSSClass("dim-label")
-					keyLabel.SetVAlign(gtk.AlignCenter)
-					keyLabel.SetMarginEnd(8)
-					row.AddSuffix(keyLabel)
-
-					// Create request button
-					requestButton := gtk.NewButton()
-					requestButton.SetLabel("Request Keys")
-					requestButton.SetVAlign(gtk.AlignCenter)
-					requestButton.AddCSSClass("flat")
-					requestButton.SetSensitive(false) // Disabled by default
-					row.AddSuffix(requestButton)
-
-					// Button click handler (captures macAddr)
-					requestButton.Connect("clicked", func() {
-						requestButton.SetSensitive(false)
-						requestButton.SetLabel("Requesting...")
-
-						// Request keys in a goroutine to avoid blocking UI
-						go func() {
-							err := podCoord.RequestEncryptionKeys()
-
-							// Update UI on the main thread
-							glib.IdleAdd(func() {
-								if err != nil {
-									requestButton.SetLabel("Error - Retry")
-								} else {
-									requestButton.SetLabel("Request Keys")
-								}
-								// Re-enable if still connected
-								if podCoord.GetConnectedDeviceMac() == macAddr {
-									requestButton.SetSensitive(true)
-								}
-							})
-						}()
-					})
-
-					devRow = &DeviceRow{
-						row:           row,
-						keyLabel:      keyLabel,
-						requestButton: requestButton,
-					}
-					deviceRows[macAddr] = devRow
-					devGroup.Add(row)
-				}
-
-				// Update title with connection indicator or BLE MAC
-				title := macAddr
-				if macAddr == connectedMac {
-					title = macAddr + " • Connected"
-				} else if state.CurrentBLEMac != "" && state.CurrentBLEMac != macAddr {
-					// Show current BLE MAC if it's different from real MAC
-					title = macAddr + " • BLE: " + state.CurrentBLEMac
-				}
-				devRow.row.SetTitle(title)
-
-				// Update subtitle with model name
-				if state.ModelName != "" {
-					devRow.row.SetSubtitle(state.ModelName)
-				}
-
-				// Update key status
-				if state.EncryptionKey != nil && len(state.EncryptionKey) > 0 {
-					devRow.keyLabel.SetText("Present")
-					devRow.keyLabel.RemoveCSSClass("dim-label")
-					devRow.keyLabel.AddCSSClass("success")
-				} else {
-					devRow.keyLabel.SetText("Not present")
-					devRow.keyLabel.RemoveCSSClass("success")
-					devRow.keyLabel.AddCSSClass("dim-label")
-				}
-
-				// Enable/disable request button based on connection status
-				devRow.requestButton.SetSensitive(macAddr == connectedMac)
-			}
-
-			// Remove rows for devices that are no longer in the state
-			for macAddr, devRow := range deviceRows {
-				if _, exists := states[macAddr]; !exists {
-					devGroup.Remove(devRow.row)
-					delete(deviceRows, macAddr)
-				}
-			}
-		})
+	pairButton := gtk.NewButton()
+	pairButton.SetLabel(i18n.T("Pair"))
+	pairButton.SetVAlign(gtk.AlignCenter)
+	pairButton.ConnectClicked(func() {
+		ShowPairingWizard(&win.Window, podCoord)
 	})
+	pairRow.AddSuffix(pairButton)
+	pairRow.SetActivatableWidget(pairButton)
+
+	settingsGroup.Add(pairRow)
+
+	settingsBox.Append(settingsGroup)
 
-	settingsBox.Append(devGroup)
+	settingsBox.Append(createMicrophoneGroup(podCoord))
+	settingsBox.Append(createEarDetectionGroup(podCoord))
+	settingsBox.Append(createToneVolumeGroup(podCoord))
+
+	settingsBox.Append(createMediaGroup(cfg.Media))
+	settingsBox.Append(createAudioOutputGroup(cfg.Audio))
+
+	settingsBox.Append(createIgnoredDevicesGroup(toasts).group)
+
+	settingsBox.Append(createKeyManagerGroup(win, podCoord, toasts))
+	settingsBox.Append(createKeyImportGroup(podCoord))
+	settingsBox.Append(createKeyFileGroup(win, podCoord))
+
+	// Tools for developers
+	developerGroup := adw.NewPreferencesGroup()
+	developerGroup.SetTitle(i18n.T("Developer"))
+	debugSwitch := addConfigSwitch(developerGroup, i18n.T("Debug Page"), i18n.T("Show live BLE advertisements and AAP packets, to share captures of unsupported models"),
+		"debug", "page", cfg.Debug.Page)
+	debugSwitch.Connect("notify::active", func() { showDebugPage(debugSwitch.Active()) })
+
+	debugInfoRow := adw.NewActionRow()
+	debugInfoRow.SetTitle(i18n.T("Save Debug Info"))
+	debugInfoRow.SetSubtitle(i18n.T("Save logs, adapters, devices and recent packets to attach to a bug report"))
+	debugInfoButton := gtk.NewButton()
+	debugInfoButton.SetLabel(i18n.T("Save…"))
+	debugInfoButton.SetVAlign(gtk.AlignCenter)
+	debugInfoButton.SetActionName("win.save-debug-info")
+	debugInfoRow.AddSuffix(debugInfoButton)
+	debugInfoRow.SetActivatableWidget(debugInfoButton)
+	developerGroup.Add(debugInfoRow)
+	settingsBox.Append(developerGroup)
 
 	// Add About section
 	aboutGroup := adw.NewPreferencesGroup()
-	aboutGroup.SetTitle("About")
+	aboutGroup.SetTitle(i18n.T("About"))
 
 	aboutRow := adw.NewActionRow()
 	aboutRow.SetTitle("LinuxPods")
-	aboutRow.SetSubtitle("Version 0.1.0")
+	aboutRow.SetSubtitle(i18n.Tf("Version %s", version.String()))
+	aboutRow.AddSuffix(gtk.NewImageFromIconName("go-next-symbolic"))
+	aboutRow.SetActivatable(true)
+	aboutRow.ConnectActivated(func() { win.ActivateAction("win.about", nil) })
 
 	aboutGroup.Add(aboutRow)
 
@@ -410,59 +454,54 @@ func createSettingsView(podCoord *podstate.PodStateCoordinator) *gtk.Box {
 
 // updateBatteryDisplay updates the UI with battery data from PodState
 func updateBatteryDisplay(widgets *BatteryWidgets, state *podstate.PodState) {
-	// Update left AirPod
-	if state.LeftBattery != nil {
-		widgets.LeftLevel.SetValue(float64(*state.LeftBattery) / 100.0)
-		charging := ""
-		if state.LeftCharging {
-			charging = " ⚡"
-		}
-		inEar := ""
-		if state.LeftInEar {
-			inEar = " 👂"
-		}
-		widgets.LeftLabel.SetText(fmt.Sprintf("%d%%%s%s", *state.LeftBattery, charging, inEar))
-	} else {
-		widgets.LeftLevel.SetValue(0.0)
-		widgets.LeftLabel.SetText("--")
+	updateCapabilities(widgets, state.Capabilities)
+	updateDeviceInfo(widgets.DeviceInfo, state)
+	widgets.InfoPage.update(state)
+	widgets.Controls.show(state)
+	widgets.NoiseControl.show(state)
+	widgets.History.show(state)
+	for _, image := range widgets.Artwork {
+		image.update(state)
 	}
 
-	// Update right AirPod
-	if state.RightBattery != nil {
-		widgets.RightLevel.SetValue(float64(*state.RightBattery) / 100.0)
-		charging := ""
-		if state.RightCharging {
-			charging = " ⚡"
-		}
-		inEar := ""
-		if state.RightInEar {
-			inEar = " 👂"
-		}
-		widgets.RightLabel.SetText(fmt.Sprintf("%d%%%s%s", *state.RightBattery, charging, inEar))
-	} else {
-		widgets.RightLevel.SetValue(0.0)
-		widgets.RightLabel.SetText("--")
+	showLevel(widgets.LeftLevel, widgets.LeftLabel, state.LeftBattery, state.LeftCharging)
+	showLevel(widgets.RightLevel, widgets.RightLabel, state.RightBattery, state.RightCharging)
+	showLevel(widgets.CaseLevel, widgets.CaseLabel, state.CaseBattery, state.CaseCharging)
+	showLevel(widgets.HeadphoneLevel, widgets.HeadphoneLabel, state.Battery, state.Charging)
+	setComponentStatus(widgets.LeftStatus, podStatus(state.LeftBattery, state.LeftCharging, state.LeftInEar, state.LeftInCase))
+	setComponentStatus(widgets.RightStatus, podStatus(state.RightBattery, state.RightCharging, state.RightInEar, state.RightInCase))
+	setComponentStatus(widgets.CaseStatus, caseStatus(state))
+	setComponentStatus(widgets.HeadphoneStatus, headphoneStatus(state))
+
+	// Update status label with the model and other info
+	statusText := i18n.Tf("Model: 0x%04X", state.DeviceModel)
+	if state.AudioProfile == podstate.AudioProfileHeadset {
+		// Playback quality drops while the microphone is available
+		statusText += " • " + i18n.T("Call audio")
+	}
+	if state.Stale {
+		statusText += " • " + i18n.Tf("Last updated %s", formatLastSeen(state.LastSeen))
 	}
+	widgets.StatusLabel.SetText(statusText)
+}
 
-	// Update case
-	if state.CaseBattery != nil {
-		widgets.CaseLevel.SetValue(float64(*state.CaseBattery) / 100.0)
-		charging := ""
-		if state.CaseCharging {
-			charging = " ⚡"
-		}
-		widgets.CaseLabel.SetText(fmt.Sprintf("%d%%%s", *state.CaseBattery, charging))
-	} else {
-		widgets.CaseLevel.SetValue(0.0)
-		widgets.CaseLabel.SetText("--")
+// formatLastSeen returns the time of the last update, with the date if it was before today,
+// e.g. for the state restored from the previous run
+func formatLastSeen(lastSeen time.Time) string {
+	now := time.Now()
+	if y, m, d := lastSeen.Date(); y == now.Year() && m == now.Month() && d == now.Day() {
+		return lastSeen.Format(time.TimeOnly)
 	}
+	return lastSeen.Format("Jan 2 15:04")
+}
 
-	// Update status label with connection state and other info
-	statusText := fmt.Sprintf("Model: 0x%04X", state.DeviceModel)
-	if state.LidOpen {
-		statusText += " • Lid: Open"
-	} else {
-		statusText += " • Lid: Closed"
+// updateCapabilities hides the controls the device model doesn't support
+func updateCapabilities(widgets *BatteryWidgets, caps aap.Capabilities) {
+	single := caps.SingleBattery()
+	for _, column := range widgets.PodColumns {
+		column.SetVisible(!single)
 	}
-	widgets.StatusLabel.SetText(statusText)
+	widgets.HeadphoneColumn.SetVisible(single)
+	widgets.CaseColumn.SetVisible(caps.HasCase)
+	widgets.ConversationRow.SetVisible(caps.SupportsConversationAwareness)
 }
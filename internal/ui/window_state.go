@@ -0,0 +1,117 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+
+	"linuxpods/internal/util"
+)
+
+// Size of the main window on the first start
+const (
+	defaultWindowWidth  = 400
+	defaultWindowHeight = 500
+)
+
+// windowState is the size and tab of the main window, restored when it is opened again,
+// also after a restart
+type windowState struct {
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Maximized bool   `json:"maximized"`
+	Page      string `json:"page"` // Name of the selected tab, e.g. "control"
+}
+
+// windowStatePath returns the window state file in the user's data directory:
+// $XDG_DATA_HOME/linuxpods/window-state.json (~/.local/share/linuxpods/window-state.json)
+func windowStatePath() (string, error) {
+	dir, err := util.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "window-state.json"), nil
+}
+
+// loadWindowState reads the saved window state. Without a saved state, or if it can't be
+// read, the window opens with the default size on the Control tab.
+func loadWindowState() windowState {
+	state := windowState{Width: defaultWindowWidth, Height: defaultWindowHeight}
+	path, err := windowStatePath()
+	if err != nil {
+		return state
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to restore the window state: %v", err)
+		return windowState{Width: defaultWindowWidth, Height: defaultWindowHeight}
+	}
+	if state.Width <= 0 || state.Height <= 0 {
+		state.Width, state.Height = defaultWindowWidth, defaultWindowHeight
+	}
+	return state
+}
+
+// save replaces the window state file
+func (s windowState) save() error {
+	path, err := windowStatePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode window state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save window state: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to save window state: %w", err)
+	}
+	return nil
+}
+
+// restoreWindowState gives the window the size and tab it had when it was last closed, and
+// saves them whenever it is closed or hidden to the tray. Hidden tabs (the Debug tab when it
+// is turned off) are not restored.
+func restoreWindowState(win *adw.ApplicationWindow, viewStack *adw.ViewStack) {
+	state := loadWindowState()
+	win.SetDefaultSize(state.Width, state.Height)
+	if state.Maximized {
+		win.Maximize()
+	}
+	if child := viewStack.ChildByName(state.Page); state.Page != "" && child != nil && viewStack.Page(child).Visible() {
+		viewStack.SetVisibleChildName(state.Page)
+	}
+
+	saveState := func() {
+		// The default size is the size of the window when it is not maximized
+		width, height := win.DefaultSize()
+		state := windowState{Width: width, Height: height, Maximized: win.IsMaximized(), Page: viewStack.VisibleChildName()}
+		if err := state.save(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	// Quitting from the menu or the tray destroys the window without a close request
+	win.ConnectCloseRequest(func() bool {
+		saveState()
+		return false
+	})
+	win.ConnectUnrealize(saveState)
+}
@@ -0,0 +1,37 @@
+package upower
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// powerProfilesServices are the names of power-profiles-daemon, the current one first
+var powerProfilesServices = []struct {
+	name  string
+	path  dbus.ObjectPath
+	iface string
+}{
+	{"org.freedesktop.UPower.PowerProfiles", "/org/freedesktop/UPower/PowerProfiles", "org.freedesktop.UPower.PowerProfiles"},
+	{"net.hadess.PowerProfiles", "/net/hadess/PowerProfiles", "net.hadess.PowerProfiles"},
+}
+
+// PowerSaverActive reports whether the power-saver profile of power-profiles-daemon is
+// active, using a short-lived system bus connection. It is false if the daemon isn't running.
+func PowerSaverActive() (bool, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	for _, service := range powerProfilesServices {
+		profile, err := conn.Object(service.name, service.path).GetProperty(service.iface + ".ActiveProfile")
+		if err != nil {
+			continue
+		}
+		active, _ := profile.Value().(string)
+		return active == "power-saver", nil
+	}
+	return false, nil
+}
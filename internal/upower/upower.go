@@ -0,0 +1,71 @@
+// Package upower looks up the batteries of AirPods in UPower.
+//
+// Desktops like XFCE and MATE read batteries from UPower instead of BlueZ. UPower has no API
+// to add devices: it creates its Bluetooth devices from the org.bluez.Battery1 objects of
+// BlueZ, which BlueZ creates from the battery provider (internal/bluez). So the BlueZ provider
+// is also the UPower backend, and this package only checks that the batteries arrive. It
+// also reads the power profile, to scan less while the power saver is on.
+package upower
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	upowerService = "org.freedesktop.UPower"
+	upowerPath    = "/org/freedesktop/UPower"
+	upowerIface   = "org.freedesktop.UPower"
+	deviceIface   = "org.freedesktop.UPower.Device"
+)
+
+// Device is a UPower device
+type Device struct {
+	Path       dbus.ObjectPath
+	NativePath string  // Object path of the BlueZ device for Bluetooth devices
+	Model      string  // Device name
+	Serial     string  // MAC address for Bluetooth devices
+	Percentage float64 // Battery level in percent
+}
+
+// FindDevice returns the UPower device of a Bluetooth device using a short-lived system bus connection.
+// It returns false if UPower runs but doesn't list the device.
+func FindDevice(macAddr string) (Device, bool, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return Device{}, false, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	var paths []dbus.ObjectPath
+	if err := conn.Object(upowerService, upowerPath).Call(upowerIface+".EnumerateDevices", 0).Store(&paths); err != nil {
+		return Device{}, false, fmt.Errorf("failed to enumerate UPower devices: %w", err)
+	}
+
+	for _, path := range paths {
+		var props map[string]dbus.Variant
+		if err := conn.Object(upowerService, path).Call("org.freedesktop.DBus.Properties.GetAll", 0, deviceIface).Store(&props); err != nil {
+			continue
+		}
+		device := Device{Path: path}
+		device.NativePath, _ = props["NativePath"].Value().(string)
+		device.Model, _ = props["Model"].Value().(string)
+		device.Serial, _ = props["Serial"].Value().(string)
+		device.Percentage, _ = props["Percentage"].Value().(float64)
+		if device.matches(macAddr) {
+			return device, true, nil
+		}
+	}
+	return Device{}, false, nil
+}
+
+// matches reports whether the device is the Bluetooth device with the given MAC address
+func (d Device) matches(macAddr string) bool {
+	if strings.EqualFold(d.Serial, macAddr) {
+		return true
+	}
+	// e.g. /org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF
+	return strings.HasSuffix(strings.ToUpper(d.NativePath), "/DEV_"+strings.ToUpper(strings.ReplaceAll(macAddr, ":", "_")))
+}
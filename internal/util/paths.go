@@ -0,0 +1,31 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DataDir returns the LinuxPods directory in the user's data directory:
+// $XDG_DATA_HOME/linuxpods (~/.local/share/linuxpods)
+func DataDir() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find data directory: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "linuxpods"), nil
+}
+
+// RuntimeDir returns the LinuxPods directory in the user's runtime directory:
+// $XDG_RUNTIME_DIR/linuxpods (/run/user/UID/linuxpods)
+func RuntimeDir() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		return "", fmt.Errorf("failed to find runtime directory: XDG_RUNTIME_DIR is not set")
+	}
+	return filepath.Join(dir, "linuxpods"), nil
+}
@@ -0,0 +1,43 @@
+// Package version identifies the LinuxPods build, for the About window and bug reports
+package version
+
+import (
+	"runtime/debug"
+)
+
+// Number is the release of LinuxPods
+const Number = "0.1.0"
+
+// Commit returns the abbreviated VCS revision the binary was built from, with "-dirty" if
+// the tree had uncommitted changes, or "" if it is unknown (e.g. built without VCS info)
+func Commit() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// String returns the release with the commit, e.g. "0.1.0 (1a2b3c4d5e6f)"
+func String() string {
+	if commit := Commit(); commit != "" {
+		return Number + " (" + commit + ")"
+	}
+	return Number
+}